@@ -0,0 +1,305 @@
+// Copyright 2026 The GoPacket Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+// Package appdetect detects the application protocol of TCP streams and UDP
+// flows from their content, rather than from their ports as the
+// layers.TCPPort and layers.UDPPort decoders do.
+//
+// Signatures inspect the first bytes of a direction of a flow and return
+// their confidence that it carries their protocol.  A Detector runs its
+// signatures over data and returns the best Result, falling back to the
+// port-based layer type with a low confidence when no signature matches.
+// Detections accumulate the first bytes of both directions of a flow until
+// a signature is certain enough:
+//
+//	// in a reassembly.Stream
+//	func (s *stream) ReassembledSG(sg reassembly.ScatterGather, flushing bool, ac reassembly.AssemblerContext) {
+//		dir, _, _, _ := sg.Info()
+//		length, _ := sg.Lengths()
+//		if r, done := s.detection.Feed(dir == reassembly.TCPDirServerToClient, sg.Fetch(length)); done {
+//			// r.LayerType can start gopacket.NewPacket or a
+//			// gopacket.DecodingLayerParser for the data to come
+//		}
+//	}
+//
+// Some detected protocols, SSH for example, have a layer type in layers but
+// no decoder, and decode as gopacket.Payload.
+package appdetect
+
+import (
+	"sync"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// Confidence is how sure a signature is of its match.
+type Confidence uint8
+
+// Confidence levels, from none to a full signature match.
+const (
+	// ConfidenceNone means the data does not look like the protocol, or
+	// that there is not enough of it to tell.
+	ConfidenceNone Confidence = iota
+	// ConfidenceLow is given to weak patterns and to port-based guesses.
+	ConfidenceLow
+	// ConfidenceMedium is given to partial signatures.
+	ConfidenceMedium
+	// ConfidenceHigh is given to full signatures, which end detection.
+	ConfidenceHigh
+)
+
+func (c Confidence) String() string {
+	switch c {
+	case ConfidenceNone:
+		return "None"
+	case ConfidenceLow:
+		return "Low"
+	case ConfidenceMedium:
+		return "Medium"
+	case ConfidenceHigh:
+		return "High"
+	}
+	return "Unknown"
+}
+
+// Transport is a set of transport protocols.
+type Transport uint8
+
+// Transports signatures apply to.
+const (
+	TCP Transport = 1 << iota
+	UDP
+)
+
+// Signature recognizes a protocol from the first bytes of a direction of a
+// flow.
+type Signature struct {
+	LayerType gopacket.LayerType
+	// Transports are the transports the protocol runs over.
+	Transports Transport
+	// Match returns the confidence that data, the first bytes of a TCP
+	// stream or a UDP datagram, is of the protocol.
+	Match func(data []byte) Confidence
+}
+
+// Result is the outcome of a detection.
+type Result struct {
+	LayerType  gopacket.LayerType
+	Confidence Confidence
+}
+
+// Detector holds signatures.  It is safe for concurrent use.
+type Detector struct {
+	mu         sync.RWMutex
+	signatures []Signature
+}
+
+// NewDetector creates a detector with the given signatures.
+func NewDetector(signatures ...Signature) *Detector {
+	return &Detector{signatures: append([]Signature(nil), signatures...)}
+}
+
+// DefaultDetector holds the signatures of Signatures, and those added by
+// Register.
+var DefaultDetector = NewDetector(Signatures...)
+
+// Register adds a signature to DefaultDetector.
+func Register(s Signature) {
+	DefaultDetector.Register(s)
+}
+
+// Register adds a signature to the detector.  Signatures registered first
+// win ties.
+func (d *Detector) Register(s Signature) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.signatures = append(d.signatures, s)
+}
+
+// portLayerType returns the layer type the ports of a transport flow map to.
+func portLayerType(transport gopacket.Flow) gopacket.LayerType {
+	src, dst := transport.Endpoints()
+	var ports [2]gopacket.LayerType
+	for i, e := range []gopacket.Endpoint{dst, src} {
+		raw := e.Raw()
+		if len(raw) != 2 {
+			return gopacket.LayerTypePayload
+		}
+		port := uint16(raw[0])<<8 | uint16(raw[1])
+		switch e.EndpointType() {
+		case layers.EndpointTCPPort:
+			ports[i] = layers.TCPPort(port).LayerType()
+		case layers.EndpointUDPPort:
+			ports[i] = layers.UDPPort(port).LayerType()
+		default:
+			return gopacket.LayerTypePayload
+		}
+	}
+	if ports[0] != gopacket.LayerTypePayload {
+		return ports[0]
+	}
+	return ports[1]
+}
+
+// flowTransport returns the transports a transport flow may be, all of them
+// for flows which are neither TCP nor UDP.
+func flowTransport(transport gopacket.Flow) Transport {
+	switch transport.EndpointType() {
+	case layers.EndpointTCPPort:
+		return TCP
+	case layers.EndpointUDPPort:
+		return UDP
+	}
+	return TCP | UDP
+}
+
+// Detect returns the protocol of data, the first bytes of a direction of a
+// TCP stream or a UDP datagram of the given transport flow.  Only the
+// signatures of the transport of the flow are used, or all of them if it is
+// neither TCP nor UDP.  Ties are broken in favor of the port-based layer
+// type, which is also returned with ConfidenceLow if no signature matches.
+func (d *Detector) Detect(transport gopacket.Flow, data []byte) Result {
+	t := flowTransport(transport)
+	port := portLayerType(transport)
+	best := Result{LayerType: gopacket.LayerTypePayload}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	for _, s := range d.signatures {
+		if s.Transports&t == 0 {
+			continue
+		}
+		c := s.Match(data)
+		if c > best.Confidence || (c == best.Confidence && c != ConfidenceNone && s.LayerType == port) {
+			best = Result{s.LayerType, c}
+		}
+	}
+	if best.Confidence == ConfidenceNone && port != gopacket.LayerTypePayload {
+		best = Result{port, ConfidenceLow}
+	}
+	return best
+}
+
+// Decoder returns a decoder detecting the protocol of its data with the
+// signatures of the detector, and decoding it with the detected layer type
+// if its confidence is at least min, as a payload otherwise.  The transport
+// flow is taken from the packet being decoded if it has one.
+//
+//	p := gopacket.NewPacket(udp.Payload, appdetect.DefaultDetector.Decoder(appdetect.ConfidenceMedium), gopacket.Default)
+func (d *Detector) Decoder(min Confidence) gopacket.Decoder {
+	return gopacket.DecodeFunc(func(data []byte, p gopacket.PacketBuilder) error {
+		var transport gopacket.Flow
+		if pkt, ok := p.(interface {
+			TransportLayer() gopacket.TransportLayer
+		}); ok {
+			if tl := pkt.TransportLayer(); tl != nil {
+				transport = tl.TransportFlow()
+			}
+		}
+		r := d.Detect(transport, data)
+		if r.Confidence < min {
+			return gopacket.LayerTypePayload.Decode(data, p)
+		}
+		return r.LayerType.Decode(data, p)
+	})
+}
+
+// Detection limits of NewDetection.
+const (
+	// DefaultMaxBytes is the number of bytes of each direction of a TCP
+	// stream a Detection looks at.
+	DefaultMaxBytes = 512
+	// DefaultMaxDatagrams is the number of datagrams of each direction of
+	// a UDP flow a Detection looks at.
+	DefaultMaxDatagrams = 4
+)
+
+type detectionDirection struct {
+	buf       []byte
+	datagrams int
+	result    Result
+	done      bool
+}
+
+// Detection detects the protocol of a TCP stream or UDP flow from the first
+// bytes of its directions.  It is not safe for concurrent use.
+type Detection struct {
+	// MaxBytes and MaxDatagrams limit how much data of each direction is
+	// looked at, before settling for the best result so far.
+	MaxBytes     int
+	MaxDatagrams int
+
+	detector  *Detector
+	transport gopacket.Flow
+	udp       bool
+	dirs      [2]detectionDirection
+}
+
+// NewDetection starts the detection of the protocol of a TCP stream or UDP
+// flow, identified by its transport flow.
+func (d *Detector) NewDetection(transport gopacket.Flow) *Detection {
+	return &Detection{
+		MaxBytes:     DefaultMaxBytes,
+		MaxDatagrams: DefaultMaxDatagrams,
+		detector:     d,
+		transport:    transport,
+		udp:          transport.EndpointType() == layers.EndpointUDPPort,
+	}
+}
+
+// Feed passes data sent in a direction of the flow: by the source of its
+// transport flow, or by its destination if reverse.  For TCP streams, data
+// follows the data fed before in the same direction; for UDP flows, it is
+// a whole datagram.  It returns the result of the direction, and whether it
+// is final: once final, data fed in the direction is ignored.
+func (x *Detection) Feed(reverse bool, data []byte) (Result, bool) {
+	dir := &x.dirs[0]
+	transport := x.transport
+	if reverse {
+		dir = &x.dirs[1]
+		transport = transport.Reverse()
+	}
+	if dir.done {
+		return dir.result, true
+	}
+
+	var r Result
+	if x.udp {
+		r = x.detector.Detect(transport, data)
+		dir.datagrams++
+		dir.done = dir.datagrams >= x.MaxDatagrams
+	} else {
+		if room := x.MaxBytes - len(dir.buf); len(data) > room {
+			data = data[:room]
+		}
+		dir.buf = append(dir.buf, data...)
+		r = x.detector.Detect(transport, dir.buf)
+		dir.done = len(dir.buf) >= x.MaxBytes
+	}
+	if r.Confidence > dir.result.Confidence || dir.result.LayerType == 0 {
+		dir.result = r
+	}
+	if dir.result.Confidence == ConfidenceHigh {
+		dir.done = true
+	}
+	if dir.done {
+		dir.buf = nil
+	}
+	return dir.result, dir.done
+}
+
+// Result returns the most confident result of the two directions so far.
+func (x *Detection) Result() Result {
+	r := x.dirs[0].result
+	if x.dirs[1].result.Confidence > r.Confidence {
+		r = x.dirs[1].result
+	}
+	if r.LayerType == 0 {
+		r.LayerType = gopacket.LayerTypePayload
+	}
+	return r
+}
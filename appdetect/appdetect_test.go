@@ -0,0 +1,153 @@
+// Copyright 2026 The GoPacket Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package appdetect
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+func tcpFlow(sport, dport layers.TCPPort) gopacket.Flow {
+	return gopacket.NewFlow(layers.EndpointTCPPort, []byte{byte(sport >> 8), byte(sport)}, []byte{byte(dport >> 8), byte(dport)})
+}
+
+func udpFlow(sport, dport layers.UDPPort) gopacket.Flow {
+	return gopacket.NewFlow(layers.EndpointUDPPort, []byte{byte(sport >> 8), byte(sport)}, []byte{byte(dport >> 8), byte(dport)})
+}
+
+func dnsQuery(t *testing.T) []byte {
+	dns := &layers.DNS{ID: 1, RD: true, Questions: []layers.DNSQuestion{{Name: []byte("example.com"), Type: layers.DNSTypeA, Class: layers.DNSClassIN}}}
+	buf := gopacket.NewSerializeBuffer()
+	if err := dns.SerializeTo(buf, gopacket.SerializeOptions{FixLengths: true}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDetect(t *testing.T) {
+	dhcp := make([]byte, 300)
+	dhcp[0], dhcp[1], dhcp[2] = 1, 1, 6
+	copy(dhcp[236:], []byte{0x63, 0x82, 0x53, 0x63})
+	quic := make([]byte, 1200)
+	copy(quic, []byte{0xc3, 0, 0, 0, 1, 8})
+	ntp := make([]byte, 48)
+	ntp[0] = 4<<3 | 3
+
+	for _, test := range []struct {
+		name string
+		flow gopacket.Flow
+		data []byte
+		want Result
+	}{
+		{"HTTP on 8081", tcpFlow(40000, 8081), []byte("GET /index.html HTTP/1.1\r\nHost: x\r\n"), Result{layers.LayerTypeHTTP, ConfidenceHigh}},
+		{"HTTP response", tcpFlow(8081, 40000), []byte("HTTP/1.1 200 OK\r\n"), Result{layers.LayerTypeHTTP, ConfidenceHigh}},
+		{"partial HTTP", tcpFlow(40000, 8081), []byte("POST /upl"), Result{layers.LayerTypeHTTP, ConfidenceMedium}},
+		{"HTTP/2", tcpFlow(40000, 8080), []byte("PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n"), Result{layers.LayerTypeHTTP2, ConfidenceHigh}},
+		{"TLS on 8443", tcpFlow(40000, 8443), []byte{0x16, 3, 1, 0, 200, 1, 0, 0, 196, 3, 3}, Result{layers.LayerTypeTLS, ConfidenceHigh}},
+		{"SSH", tcpFlow(40000, 2222), []byte("SSH-2.0-OpenSSH_8.9\r\n"), Result{layers.LayerTypeSSH, ConfidenceHigh}},
+		{"SMB2", tcpFlow(40000, 445), []byte{0, 0, 0, 100, 0xfe, 'S', 'M', 'B', 64, 0}, Result{layers.LayerTypeSMB, ConfidenceHigh}},
+		{"BGP", tcpFlow(40000, 179), append(bytes.Repeat([]byte{0xff}, 16), 0, 19, 4), Result{layers.LayerTypeBGP, ConfidenceHigh}},
+		{"SIP over TCP", tcpFlow(40000, 5080), []byte("OPTIONS sip:bob@example.com SIP/2.0\r\n"), Result{layers.LayerTypeSIP, ConfidenceHigh}},
+		{"HTTP OPTIONS", tcpFlow(40000, 5080), []byte("OPTIONS * HTTP/1.1\r\n"), Result{layers.LayerTypeHTTP, ConfidenceHigh}},
+		{"SIP over UDP", udpFlow(5060, 5062), []byte("SIP/2.0 200 OK\r\n"), Result{layers.LayerTypeSIP, ConfidenceHigh}},
+		{"DNS on 5353", udpFlow(40000, 5353), dnsQuery(t), Result{layers.LayerTypeDNS, ConfidenceHigh}},
+		{"DHCP", udpFlow(68, 67), dhcp, Result{layers.LayerTypeDHCPv4, ConfidenceHigh}},
+		{"QUIC Initial", udpFlow(40000, 443), quic, Result{layers.LayerTypeQUIC, ConfidenceHigh}},
+		{"NTP", udpFlow(40000, 1123), ntp, Result{layers.LayerTypeNTP, ConfidenceMedium}},
+		{"TLS not over UDP", udpFlow(40000, 8443), []byte{0x16, 3, 1, 0, 200, 1, 0, 0, 196, 3, 3}, Result{gopacket.LayerTypePayload, ConfidenceNone}},
+		{"port fallback", udpFlow(40000, 53), []byte("garbage"), Result{layers.LayerTypeDNS, ConfidenceLow}},
+		{"unknown", tcpFlow(40000, 9999), []byte("garbage"), Result{gopacket.LayerTypePayload, ConfidenceNone}},
+	} {
+		if got := DefaultDetector.Detect(test.flow, test.data); got != test.want {
+			t.Errorf("%s: got %v/%v, want %v/%v", test.name, got.LayerType, got.Confidence, test.want.LayerType, test.want.Confidence)
+		}
+	}
+}
+
+func TestDetection(t *testing.T) {
+	d := NewDetector(Signatures...)
+	d.Register(Signature{
+		LayerType:  layers.LayerTypeModbusTCP,
+		Transports: TCP,
+		Match: func(data []byte) Confidence {
+			if len(data) >= 8 && data[2] == 0 && data[3] == 0 {
+				return ConfidenceMedium
+			}
+			return ConfidenceNone
+		},
+	})
+
+	// TCP: data accumulates until a signature is certain
+	x := d.NewDetection(tcpFlow(40000, 8081))
+	if r, done := x.Feed(false, []byte("GE")); done || r.Confidence != ConfidenceNone {
+		t.Errorf("2 bytes: %v/%v, %v", r.LayerType, r.Confidence, done)
+	}
+	if r, done := x.Feed(false, []byte("T /a")); done || r != (Result{layers.LayerTypeHTTP, ConfidenceMedium}) {
+		t.Errorf("request start: %v/%v, %v", r.LayerType, r.Confidence, done)
+	}
+	if r, done := x.Feed(false, []byte(" HTTP/1.0\r\n")); !done || r != (Result{layers.LayerTypeHTTP, ConfidenceHigh}) {
+		t.Errorf("request line: %v/%v, %v", r.LayerType, r.Confidence, done)
+	}
+	if r, done := x.Feed(true, []byte{0, 1, 0, 0, 0, 6, 1, 3}); done || r.LayerType != layers.LayerTypeModbusTCP {
+		t.Errorf("registered signature: %v/%v, %v", r.LayerType, r.Confidence, done)
+	}
+	if r := x.Result(); r.LayerType != layers.LayerTypeHTTP {
+		t.Errorf("result: %v", r.LayerType)
+	}
+
+	x.MaxBytes = 16
+	if _, done := x.Feed(true, bytes.Repeat([]byte{'x'}, 20)); !done {
+		t.Error("detection not done after MaxBytes")
+	}
+
+	// UDP: datagrams are looked at alone
+	x = d.NewDetection(udpFlow(40000, 5353))
+	query := dnsQuery(t)
+	if r, done := x.Feed(false, query[:6]); done || r.Confidence != ConfidenceNone {
+		t.Errorf("short datagram: %v/%v, %v", r.LayerType, r.Confidence, done)
+	}
+	if r, done := x.Feed(false, query); !done || r != (Result{layers.LayerTypeDNS, ConfidenceHigh}) {
+		t.Errorf("DNS query: %v/%v, %v", r.LayerType, r.Confidence, done)
+	}
+}
+
+func TestDecoder(t *testing.T) {
+	// TLS on a port layers does not know
+	record := []byte{0x15, 3, 3, 0, 2, 2, 40}
+	tcp := &layers.TCP{SrcPort: 40000, DstPort: 8443, DataOffset: 5}
+	ip := &layers.IPv4{Version: 4, TTL: 64, Protocol: layers.IPProtocolTCP, SrcIP: net.IP{10, 0, 0, 1}, DstIP: net.IP{10, 0, 0, 2}}
+	tcp.SetNetworkLayerForChecksum(ip)
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}, ip, tcp, gopacket.Payload(record)); err != nil {
+		t.Fatal(err)
+	}
+	p := gopacket.NewPacket(buf.Bytes(), layers.LayerTypeIPv4, gopacket.Default)
+	if p.Layer(layers.LayerTypeTLS) != nil {
+		t.Fatal("TLS decoded on 8443 without detection")
+	}
+
+	app := p.ApplicationLayer().Payload()
+	p = gopacket.NewPacket(app, DefaultDetector.Decoder(ConfidenceLow), gopacket.Default)
+	if tls, ok := p.Layer(layers.LayerTypeTLS).(*layers.TLS); !ok || len(tls.Alert) != 1 {
+		t.Errorf("TLS not decoded: %v", p)
+	}
+	p = gopacket.NewPacket(app, DefaultDetector.Decoder(ConfidenceHigh), gopacket.Default)
+	if p.Layer(gopacket.LayerTypePayload) == nil {
+		t.Errorf("not decoded as payload: %v", p)
+	}
+
+	r := DefaultDetector.Detect(tcp.TransportFlow(), app)
+	parser := gopacket.NewDecodingLayerParser(r.LayerType, &layers.TLS{})
+	var decoded []gopacket.LayerType
+	if err := parser.DecodeLayers(app, &decoded); err != nil || len(decoded) != 1 {
+		t.Errorf("parser: %v, %v", decoded, err)
+	}
+}
@@ -0,0 +1,245 @@
+// Copyright 2026 The GoPacket Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package appdetect
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/google/gopacket/layers"
+)
+
+// Signatures are the built-in signatures of DefaultDetector.
+var Signatures = []Signature{
+	{layers.LayerTypeHTTP, TCP, matchHTTP},
+	{layers.LayerTypeHTTP2, TCP, matchHTTP2},
+	{layers.LayerTypeTLS, TCP, matchTLS},
+	{layers.LayerTypeSSH, TCP, matchSSH},
+	{layers.LayerTypeSMB, TCP, matchSMB},
+	{layers.LayerTypeBGP, TCP, matchBGP},
+	{layers.LayerTypeSIP, TCP | UDP, matchSIP},
+	{layers.LayerTypeDNS, UDP, matchDNS},
+	{layers.LayerTypeDHCPv4, UDP, matchDHCPv4},
+	{layers.LayerTypeNTP, UDP, matchNTP},
+	{layers.LayerTypeQUIC, UDP, matchQUIC},
+}
+
+// matchPrefix returns whether data starts with prefix, and whether data is
+// too short to tell.
+func matchPrefix(data []byte, prefix string) (match, short bool) {
+	if len(data) < len(prefix) {
+		return false, bytes.HasPrefix([]byte(prefix), data)
+	}
+	return bytes.HasPrefix(data, []byte(prefix)), false
+}
+
+// matchRequestLine matches the request lines of text protocols: one of
+// methods, then a space, and version later on the line.
+func matchRequestLine(data []byte, methods []string, version string) Confidence {
+	for _, m := range methods {
+		if ok, _ := matchPrefix(data, m+" "); !ok {
+			continue
+		}
+		line := data
+		end := bytes.IndexByte(data, '\n')
+		if end >= 0 {
+			line = data[:end]
+		}
+		switch {
+		case bytes.Contains(line, []byte(version)):
+			return ConfidenceHigh
+		case end < 0:
+			return ConfidenceMedium
+		}
+		return ConfidenceLow
+	}
+	return ConfidenceNone
+}
+
+var httpMethods = []string{"GET", "POST", "HEAD", "PUT", "DELETE", "OPTIONS", "PATCH", "CONNECT", "TRACE"}
+
+func matchHTTP(data []byte) Confidence {
+	if ok, _ := matchPrefix(data, "HTTP/1."); ok {
+		if len(data) > 8 && data[8] == ' ' {
+			return ConfidenceHigh
+		}
+		return ConfidenceMedium
+	}
+	return matchRequestLine(data, httpMethods, " HTTP/1.")
+}
+
+func matchHTTP2(data []byte) Confidence {
+	// client connection preface
+	if ok, short := matchPrefix(data, "PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n"); ok {
+		return ConfidenceHigh
+	} else if short && len(data) >= 4 {
+		return ConfidenceMedium
+	}
+	// server preface: a SETTINGS frame on stream 0
+	if len(data) >= 9 && data[3] == 0x4 && data[4]&^1 == 0 && binary.BigEndian.Uint32(data[5:9]) == 0 {
+		length := int(data[0])<<16 | int(data[1])<<8 | int(data[2])
+		if length%6 == 0 && (data[4] == 0 || length == 0) {
+			return ConfidenceMedium
+		}
+	}
+	return ConfidenceNone
+}
+
+func matchTLS(data []byte) Confidence {
+	if len(data) < 5 || data[1] != 3 || data[2] > 4 {
+		return ConfidenceNone
+	}
+	length := binary.BigEndian.Uint16(data[3:5])
+	if length == 0 || length > 1<<14+2048 {
+		return ConfidenceNone
+	}
+	switch layers.TLSType(data[0]) {
+	case layers.TLSHandshake:
+		if len(data) > 5 && (data[5] == 1 || data[5] == 2) {
+			// ClientHello, ServerHello
+			return ConfidenceHigh
+		}
+		return ConfidenceMedium
+	case layers.TLSAlert, layers.TLSApplicationData, layers.TLSChangeCipherSpec:
+		return ConfidenceLow
+	}
+	return ConfidenceNone
+}
+
+func matchSSH(data []byte) Confidence {
+	if ok, _ := matchPrefix(data, "SSH-"); !ok {
+		return ConfidenceNone
+	}
+	if ok, _ := matchPrefix(data, "SSH-2.0-"); ok {
+		return ConfidenceHigh
+	}
+	if ok, _ := matchPrefix(data, "SSH-1.99-"); ok {
+		return ConfidenceHigh
+	}
+	return ConfidenceMedium
+}
+
+func matchSMB(data []byte) Confidence {
+	// NetBIOS session message, then an SMB1, SMB2 or SMB3 transform header
+	if len(data) < 8 || data[0] != 0 {
+		return ConfidenceNone
+	}
+	if string(data[5:8]) != "SMB" {
+		return ConfidenceNone
+	}
+	switch data[4] {
+	case 0xff, 0xfe, 0xfd:
+		return ConfidenceHigh
+	}
+	return ConfidenceNone
+}
+
+func matchBGP(data []byte) Confidence {
+	if len(data) < 19 {
+		return ConfidenceNone
+	}
+	for _, b := range data[:16] {
+		if b != 0xff {
+			return ConfidenceNone
+		}
+	}
+	length := binary.BigEndian.Uint16(data[16:18])
+	if length < 19 || length > 4096 || data[18] < 1 || data[18] > 5 {
+		return ConfidenceLow
+	}
+	return ConfidenceHigh
+}
+
+var sipMethods = []string{"INVITE", "REGISTER", "OPTIONS", "ACK", "BYE", "CANCEL", "SUBSCRIBE", "NOTIFY",
+	"MESSAGE", "INFO", "PRACK", "UPDATE", "REFER", "PUBLISH"}
+
+func matchSIP(data []byte) Confidence {
+	if ok, _ := matchPrefix(data, "SIP/2.0 "); ok {
+		return ConfidenceHigh
+	}
+	if c := matchRequestLine(data, sipMethods, " SIP/2.0"); c != ConfidenceLow {
+		return c
+	}
+	return ConfidenceNone
+}
+
+func matchDNS(data []byte) Confidence {
+	if len(data) < 12 {
+		return ConfidenceNone
+	}
+	flags := binary.BigEndian.Uint16(data[2:4])
+	qd := binary.BigEndian.Uint16(data[4:6])
+	an := binary.BigEndian.Uint16(data[6:8])
+	opcode := flags >> 11 & 0xf
+	if opcode > 5 || qd == 0 || qd > 16 || (flags&0x8000 == 0 && an != 0) {
+		return ConfidenceNone
+	}
+	// the first question: labels, then type and class
+	rest := data[12:]
+	for len(rest) != 0 && rest[0] != 0 {
+		l := int(rest[0])
+		if l > 63 || len(rest) < 1+l {
+			return ConfidenceLow
+		}
+		rest = rest[1+l:]
+	}
+	if len(rest) < 5 {
+		return ConfidenceLow
+	}
+	// IN, CH or ANY, with the mDNS unicast response bit
+	if class := binary.BigEndian.Uint16(rest[3:5]) & 0x7fff; class != 1 && class != 3 && class != 255 {
+		return ConfidenceLow
+	}
+	return ConfidenceHigh
+}
+
+func matchDHCPv4(data []byte) Confidence {
+	if len(data) < 240 || (data[0] != 1 && data[0] != 2) {
+		return ConfidenceNone
+	}
+	if binary.BigEndian.Uint32(data[236:240]) != 0x63825363 {
+		return ConfidenceNone
+	}
+	return ConfidenceHigh
+}
+
+func matchNTP(data []byte) Confidence {
+	if len(data) < 48 {
+		return ConfidenceNone
+	}
+	version := data[0] >> 3 & 0x7
+	mode := data[0] & 0x7
+	if version < 1 || version > 4 || mode < 1 || mode > 5 {
+		return ConfidenceNone
+	}
+	if len(data) == 48 && (mode == 3 || mode == 4) && version >= 3 {
+		return ConfidenceMedium
+	}
+	return ConfidenceLow
+}
+
+func matchQUIC(data []byte) Confidence {
+	// long header with the fixed bit set
+	if len(data) < 7 || data[0]&0xc0 != 0xc0 {
+		return ConfidenceNone
+	}
+	switch binary.BigEndian.Uint32(data[1:5]) {
+	case 1, 0x6b3343cf:
+		// v1, v2
+	default:
+		return ConfidenceNone
+	}
+	if int(data[5]) > 20 {
+		// destination connection ID too long
+		return ConfidenceNone
+	}
+	if len(data) >= 1200 {
+		// Initial packets are padded to 1200 bytes
+		return ConfidenceHigh
+	}
+	return ConfidenceMedium
+}
@@ -13,6 +13,7 @@ import (
 	"bufio"
 	"flag"
 	"io"
+	"io/ioutil"
 	"log"
 	"net/http"
 	"time"
@@ -21,8 +22,8 @@ import (
 	"github.com/google/gopacket/examples/util"
 	"github.com/google/gopacket/layers"
 	"github.com/google/gopacket/pcap"
-	"github.com/google/gopacket/tcpassembly"
-	"github.com/google/gopacket/tcpassembly/tcpreader"
+	"github.com/google/gopacket/reassembly"
+	"github.com/google/gopacket/reassembly/tcpreader"
 )
 
 var iface = flag.String("i", "eth0", "Interface to get packets from")
@@ -31,46 +32,62 @@ var snaplen = flag.Int("s", 1600, "SnapLen for pcap packet capture")
 var filter = flag.String("f", "tcp and dst port 80", "BPF filter for pcap")
 var logAllPackets = flag.Bool("v", false, "Logs every packet in great detail")
 
-// Build a simple HTTP request parser using tcpassembly.StreamFactory and tcpassembly.Stream interfaces
+// Build a simple HTTP request parser using reassembly.StreamFactory and reassembly.Stream interfaces
 
-// httpStreamFactory implements tcpassembly.StreamFactory
+// httpStreamFactory implements reassembly.StreamFactory
 type httpStreamFactory struct{}
 
 // httpStream will handle the actual decoding of http requests.
 type httpStream struct {
 	net, transport gopacket.Flow
-	r              tcpreader.ReaderStream
+	r              *tcpreader.Reader
 }
 
-func (h *httpStreamFactory) New(net, transport gopacket.Flow) tcpassembly.Stream {
+func (h *httpStreamFactory) New(net, transport gopacket.Flow, tcp *layers.TCP, ac reassembly.AssemblerContext) reassembly.Stream {
+	s := tcpreader.NewReaderStream(tcpreader.DefaultReaderStreamOptions)
 	hstream := &httpStream{
 		net:       net,
 		transport: transport,
-		r:         tcpreader.NewReaderStream(),
+		r:         s.Reader(reassembly.TCPDirClientToServer),
 	}
+	// Only requests are parsed, the other direction must not block assembly.
+	s.Reader(reassembly.TCPDirServerToClient).Close()
 	go hstream.run() // Important... we must guarantee that data from the reader stream is read.
 
-	// ReaderStream implements tcpassembly.Stream, so we can return a pointer to it.
-	return &hstream.r
+	// ReaderStream implements reassembly.Stream, so we can return it.
+	return s
 }
 
 func (h *httpStream) run() {
-	buf := bufio.NewReader(&h.r)
+	buf := bufio.NewReader(h.r)
 	for {
 		req, err := http.ReadRequest(buf)
 		if err == io.EOF {
 			// We must read until we see an EOF... very important!
 			return
+		} else if gap, ok := err.(*tcpreader.GapError); ok {
+			// Data is missing, look for the next request after the gap.
+			log.Println("Missing data in stream", h.net, h.transport, ":", gap)
+			buf.Reset(h.r)
 		} else if err != nil {
 			log.Println("Error reading stream", h.net, h.transport, ":", err)
 		} else {
-			bodyBytes := tcpreader.DiscardBytesToEOF(req.Body)
+			bodyBytes, _ := io.Copy(ioutil.Discard, req.Body)
 			req.Body.Close()
-			log.Println("Received request from stream", h.net, h.transport, ":", req, "with", bodyBytes, "bytes in request body")
+			log.Println("Received request from stream", h.net, h.transport, "at", h.r.CaptureInfo().Timestamp, ":", req, "with", bodyBytes, "bytes in request body")
 		}
 	}
 }
 
+// context implements reassembly.AssemblerContext
+type context struct {
+	CaptureInfo gopacket.CaptureInfo
+}
+
+func (c *context) GetCaptureInfo() gopacket.CaptureInfo {
+	return c.CaptureInfo
+}
+
 func main() {
 	defer util.Run()()
 	var handle *pcap.Handle
@@ -94,8 +111,8 @@ func main() {
 
 	// Set up assembly
 	streamFactory := &httpStreamFactory{}
-	streamPool := tcpassembly.NewStreamPool(streamFactory)
-	assembler := tcpassembly.NewAssembler(streamPool)
+	streamPool := reassembly.NewStreamPool(streamFactory)
+	assembler := reassembly.NewAssembler(streamPool)
 
 	log.Println("reading in packets")
 	// Read in packets, pass to assembler.
@@ -117,11 +134,12 @@ func main() {
 				continue
 			}
 			tcp := packet.TransportLayer().(*layers.TCP)
-			assembler.AssembleWithTimestamp(packet.NetworkLayer().NetworkFlow(), tcp, packet.Metadata().Timestamp)
+			c := context{packet.Metadata().CaptureInfo}
+			assembler.AssembleWithContext(packet.NetworkLayer().NetworkFlow(), tcp, &c)
 
 		case <-ticker:
 			// Every minute, flush connections that haven't seen activity in the past 2 minutes.
-			assembler.FlushOlderThan(time.Now().Add(time.Minute * -2))
+			assembler.FlushCloseOlderThan(time.Now().Add(time.Minute * -2))
 		}
 	}
 }
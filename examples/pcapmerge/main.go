@@ -0,0 +1,82 @@
+// Copyright 2026 The GoPacket Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+// The pcapmerge binary merges pcap, pcapng and snoop files into a single
+// pcapng file ordered by timestamp, like Wireshark's mergecap. Each
+// interface of the inputs becomes an interface of the output.
+//
+//	pcapmerge -w merged.pcapng a.pcap b.pcapng c.snoop
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/pcapgo"
+)
+
+var out = flag.String("w", "", "Filename to write the merged pcapng file to")
+
+// open returns a packet source reading f, detecting its format.
+func open(f io.Reader) (gopacket.PacketDataSource, error) {
+	r := bufio.NewReader(f)
+	magic, err := r.Peek(8)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case bytes.Equal(magic[:4], []byte{0x0a, 0x0d, 0x0d, 0x0a}):
+		return pcapgo.NewNgReader(r, pcapgo.NgReaderOptions{WantMixedLinkType: true})
+	case bytes.Equal(magic, []byte("snoop\x00\x00\x00")):
+		return pcapgo.NewSnoopReader(r)
+	default:
+		return pcapgo.NewReader(r)
+	}
+}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s -w output.pcapng input...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if *out == "" || flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var inputs []gopacket.PacketDataSource
+	for _, name := range flag.Args() {
+		f, err := os.Open(name)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		src, err := open(f)
+		if err != nil {
+			log.Fatalf("%s: %v", name, err)
+		}
+		inputs = append(inputs, src)
+	}
+
+	f, err := os.Create(*out)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := pcapgo.NgMerge(f, pcapgo.DefaultNgWriterOptions, inputs...); err != nil {
+		f.Close()
+		log.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		log.Fatal(err)
+	}
+}
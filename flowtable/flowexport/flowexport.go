@@ -0,0 +1,407 @@
+// Copyright 2026 The GoPacket Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+// Package flowexport turns observed packets into IPFIX or NetFlow v9
+// messages, the way routers and probes like softflowd do.
+//
+// Packets are aggregated into flows by 5-tuple with a flowtable.Table.  Flows
+// are exported when they have seen no packets for the inactive timeout, or
+// when they have lasted the active timeout, as one record per direction.
+// Messages are gopacket.SerializableLayers, ready to be sent over UDP to a
+// collector or written to a capture file:
+//
+//	e := flowexport.NewExporter(flowexport.Options{})
+//	for packet := range source.Packets() {
+//		e.Add(packet)
+//		for _, msg := range e.Expire(packet.Metadata().Timestamp) {
+//			buf := gopacket.NewSerializeBuffer()
+//			gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true}, msg)
+//			conn.Write(buf.Bytes())
+//		}
+//	}
+package flowexport
+
+import (
+	"encoding/binary"
+	"sync"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/flowtable"
+	"github.com/google/gopacket/layers"
+)
+
+// Options controls the behavior of an Exporter.
+type Options struct {
+	// Version is 9 for NetFlow v9, or 10 for IPFIX, the default.
+	Version uint16
+	// ActiveTimeout exports flows this long after their first packet, even
+	// if they still see packets.  Defaults to 30 minutes.
+	ActiveTimeout time.Duration
+	// InactiveTimeout exports flows that saw no packets for this long.
+	// Defaults to 15 seconds.
+	InactiveTimeout time.Duration
+	// ObservationDomainID is the IPFIX observation domain, or the NetFlow
+	// v9 source ID, of the messages.
+	ObservationDomainID uint32
+	// MaxMessageSize bounds the size of the messages, without their IP and
+	// UDP headers.  Defaults to 1400 bytes.
+	MaxMessageSize int
+	// TemplateRefresh is the number of messages after which templates are
+	// sent again, for collectors which missed them.  Templates are always
+	// sent in the first message.  Defaults to 20.
+	TemplateRefresh int
+}
+
+// Template IDs of the records of IPv4 and IPv6 flows.
+const (
+	TemplateIPv4 uint16 = 256
+	TemplateIPv6 uint16 = 257
+)
+
+// Values of the flowEndReason field of the records.
+const (
+	endIdle   = 1
+	endActive = 2
+	endForced = 4
+)
+
+// flowState holds the state of the directions of a flow that flowtable does
+// not keep.
+type flowState struct {
+	first, last [2]time.Time
+	tcpFlags    [2]uint8
+}
+
+// record is a record waiting to be exported.
+type record struct {
+	template uint16
+	fields   []layers.NetFlowField
+}
+
+// Exporter aggregates packets into flows and exports them.  It is safe for
+// concurrent use.
+type Exporter struct {
+	mu        sync.Mutex
+	options   Options
+	table     *flowtable.Table
+	templates *layers.NetFlowTemplateCache
+	// start is the SysUptime origin of NetFlow v9 messages
+	start    time.Time
+	pending  []record
+	sequence uint32
+	messages int
+}
+
+// NewExporter creates a new exporter.
+func NewExporter(options Options) *Exporter {
+	if options.Version == 0 {
+		options.Version = 10
+	}
+	if options.ActiveTimeout == 0 {
+		options.ActiveTimeout = 30 * time.Minute
+	}
+	if options.InactiveTimeout == 0 {
+		options.InactiveTimeout = 15 * time.Second
+	}
+	if options.MaxMessageSize == 0 {
+		options.MaxMessageSize = 1400
+	}
+	if options.TemplateRefresh == 0 {
+		options.TemplateRefresh = 20
+	}
+	e := &Exporter{
+		options:   options,
+		templates: layers.NewNetFlowTemplateCache(),
+	}
+	e.table = flowtable.NewTable(flowtable.Options{
+		IdleTimeout:   options.InactiveTimeout,
+		ActiveTimeout: options.ActiveTimeout,
+		New: func(f *flowtable.Flow, ci gopacket.CaptureInfo) {
+			f.Data = &flowState{}
+		},
+		Update: func(f *flowtable.Flow, d flowtable.Direction, ci gopacket.CaptureInfo) {
+			s := f.Data.(*flowState)
+			i := direction(d)
+			if s.first[i].IsZero() {
+				s.first[i] = ci.Timestamp
+			}
+			s.last[i] = ci.Timestamp
+		},
+		Expire: e.export,
+	})
+	for _, t := range e.Templates() {
+		e.templates.Add(gopacket.Endpoint{}, options.Version, options.ObservationDomainID, t)
+	}
+	return e
+}
+
+func direction(d flowtable.Direction) int {
+	if d == flowtable.Forward {
+		return 0
+	}
+	return 1
+}
+
+// Templates returns the templates of the records of the exporter.
+func (e *Exporter) Templates() []layers.NetFlowTemplate {
+	spec := func(t layers.NetFlowFieldType, length uint16) layers.NetFlowFieldSpec {
+		return layers.NetFlowFieldSpec{Type: t, Length: length}
+	}
+	var templates []layers.NetFlowTemplate
+	for _, t := range []struct {
+		id       uint16
+		length   uint16
+		src, dst layers.NetFlowFieldType
+	}{
+		{TemplateIPv4, 4, layers.NetFlowFieldSourceIPv4Address, layers.NetFlowFieldDestinationIPv4Address},
+		{TemplateIPv6, 16, layers.NetFlowFieldSourceIPv6Address, layers.NetFlowFieldDestinationIPv6Address},
+	} {
+		fields := []layers.NetFlowFieldSpec{
+			spec(t.src, t.length),
+			spec(t.dst, t.length),
+			spec(layers.NetFlowFieldSourceTransportPort, 2),
+			spec(layers.NetFlowFieldDestinationTransportPort, 2),
+			spec(layers.NetFlowFieldProtocolIdentifier, 1),
+			spec(layers.NetFlowFieldTCPControlBits, 1),
+			spec(layers.NetFlowFieldOctetDeltaCount, 8),
+			spec(layers.NetFlowFieldPacketDeltaCount, 8),
+		}
+		if e.options.Version == 9 {
+			fields = append(fields,
+				spec(layers.NetFlowFieldFlowStartSysUpTime, 4),
+				spec(layers.NetFlowFieldFlowEndSysUpTime, 4))
+		} else {
+			fields = append(fields,
+				spec(layers.NetFlowFieldFlowStartMilliseconds, 8),
+				spec(layers.NetFlowFieldFlowEndMilliseconds, 8))
+		}
+		fields = append(fields, spec(layers.NetFlowFieldFlowEndReason, 1))
+		templates = append(templates, layers.NetFlowTemplate{ID: t.id, Fields: fields})
+	}
+	return templates
+}
+
+// Add accounts a packet to its flow.  It returns false, ignoring the packet,
+// for packets without IPv4 or IPv6 layer.
+func (e *Exporter) Add(p gopacket.Packet) bool {
+	var length int
+	switch ip := p.NetworkLayer().(type) {
+	case *layers.IPv4:
+		length = int(ip.Length)
+	case *layers.IPv6:
+		length = 40 + int(ip.Length)
+	default:
+		return false
+	}
+	key, _ := flowtable.KeyFromPacket(p)
+	ci := p.Metadata().CaptureInfo
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.start.IsZero() {
+		e.start = ci.Timestamp
+	}
+	f, d := e.table.Update(key, ci, length)
+	if tcp, ok := p.TransportLayer().(*layers.TCP); ok {
+		f.Data.(*flowState).tcpFlags[direction(d)] |= tcpFlags(tcp)
+	}
+	return true
+}
+
+func tcpFlags(tcp *layers.TCP) uint8 {
+	var flags uint8
+	for i, set := range []bool{tcp.FIN, tcp.SYN, tcp.RST, tcp.PSH, tcp.ACK, tcp.URG, tcp.ECE, tcp.CWR} {
+		if set {
+			flags |= 1 << uint(i)
+		}
+	}
+	return flags
+}
+
+// export queues the records of an expired flow.  It is called by the flow
+// table, with e.mu held.
+func (e *Exporter) export(f *flowtable.Flow, reason flowtable.ExpireReason) {
+	end := uint8(endForced)
+	switch reason {
+	case flowtable.ExpireIdle:
+		end = endIdle
+	case flowtable.ExpireActive:
+		end = endActive
+	}
+	s := f.Data.(*flowState)
+	for _, d := range []flowtable.Direction{flowtable.Forward, flowtable.Reverse} {
+		c := f.Counters(d)
+		if c.Packets == 0 {
+			continue
+		}
+		key := f.Key
+		if d == flowtable.Reverse {
+			key = key.Reverse()
+		}
+		i := direction(d)
+		e.pending = append(e.pending, e.record(key, c, s.first[i], s.last[i], s.tcpFlags[i], end))
+	}
+}
+
+// record returns the record of a direction of a flow.
+func (e *Exporter) record(key flowtable.Key, c *flowtable.Counters, first, last time.Time, flags, end uint8) record {
+	r := record{template: TemplateIPv4}
+	if len(key.Network.Src().Raw()) == 16 {
+		r.template = TemplateIPv6
+	}
+	sport, dport := []byte{0, 0}, []byte{0, 0}
+	var proto layers.IPProtocol
+	switch src, dst := key.Transport.Endpoints(); src.EndpointType() {
+	case layers.EndpointTCPPort:
+		proto, sport, dport = layers.IPProtocolTCP, src.Raw(), dst.Raw()
+	case layers.EndpointUDPPort:
+		proto, sport, dport = layers.IPProtocolUDP, src.Raw(), dst.Raw()
+	case layers.EndpointSCTPPort:
+		proto, sport, dport = layers.IPProtocolSCTP, src.Raw(), dst.Raw()
+	case layers.EndpointUDPLitePort:
+		proto, sport, dport = layers.IPProtocolUDPLite, src.Raw(), dst.Raw()
+	case layers.EndpointIPProtocol:
+		proto = layers.IPProtocol(src.Raw()[0])
+	}
+
+	values := [][]byte{
+		key.Network.Src().Raw(),
+		key.Network.Dst().Raw(),
+		sport,
+		dport,
+		{byte(proto)},
+		{flags},
+		uint64Bytes(c.Bytes),
+		uint64Bytes(c.Packets),
+	}
+	if e.options.Version == 9 {
+		values = append(values,
+			uint32Bytes(uint32(first.Sub(e.start)/time.Millisecond)),
+			uint32Bytes(uint32(last.Sub(e.start)/time.Millisecond)))
+	} else {
+		values = append(values,
+			uint64Bytes(uint64(first.UnixNano()/int64(time.Millisecond))),
+			uint64Bytes(uint64(last.UnixNano()/int64(time.Millisecond))))
+	}
+	values = append(values, []byte{end})
+
+	t, _ := e.templates.Template(gopacket.Endpoint{}, e.options.Version, e.options.ObservationDomainID, r.template)
+	r.fields = make([]layers.NetFlowField, len(values))
+	for i, v := range values {
+		r.fields[i] = layers.NetFlowField{Type: t.Fields[i].Type, Value: v}
+	}
+	return r
+}
+
+func uint32Bytes(v uint32) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, v)
+	return b
+}
+
+func uint64Bytes(v uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+	return b
+}
+
+// Expire exports the flows which reached their inactive or active timeout
+// at now, and returns the messages holding their records, if any.
+func (e *Exporter) Expire(now time.Time) []gopacket.SerializableLayer {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.table.Expire(now)
+	return e.flushMessages(now)
+}
+
+// Flush exports all the flows, and returns the messages holding their
+// records, if any.
+func (e *Exporter) Flush(now time.Time) []gopacket.SerializableLayer {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.table.Flush()
+	return e.flushMessages(now)
+}
+
+// flushMessages returns the messages holding the pending records.
+func (e *Exporter) flushMessages(now time.Time) []gopacket.SerializableLayer {
+	var messages []gopacket.SerializableLayer
+	for len(e.pending) != 0 {
+		messages = append(messages, e.message(now))
+	}
+	e.pending = nil
+	return messages
+}
+
+// message returns a message holding as many pending records as fit in
+// MaxMessageSize, removing them from e.pending.
+func (e *Exporter) message(now time.Time) gopacket.SerializableLayer {
+	size := 16
+	if e.options.Version == 9 {
+		size = 20
+	}
+	var sets []layers.NetFlowSet
+	if e.messages%e.options.TemplateRefresh == 0 {
+		templates := e.Templates()
+		set := layers.NetFlowSet{ID: layers.IPFIXTemplateSetID, Templates: templates}
+		if e.options.Version == 9 {
+			set.ID = layers.NetFlowV9TemplateSetID
+		}
+		size += 4
+		for _, t := range templates {
+			size += 4 + 4*len(t.Fields)
+		}
+		sets = append(sets, set)
+	}
+
+	var records []record
+	for _, r := range e.pending {
+		rsize := 0
+		for _, f := range r.fields {
+			rsize += len(f.Value)
+		}
+		if len(sets) == 0 || sets[len(sets)-1].ID != r.template {
+			rsize += 4
+		}
+		if size+rsize > e.options.MaxMessageSize && len(records) != 0 {
+			break
+		}
+		size += rsize
+		if len(sets) == 0 || sets[len(sets)-1].ID != r.template {
+			sets = append(sets, layers.NetFlowSet{ID: r.template})
+		}
+		set := &sets[len(sets)-1]
+		set.Records = append(set.Records, layers.NetFlowRecord{Fields: r.fields})
+		records = append(records, r)
+	}
+	e.pending = e.pending[len(records):]
+
+	e.messages++
+	if e.options.Version == 9 {
+		msg := &layers.NetFlowV9{
+			Version:        9,
+			SysUptime:      uint32(now.Sub(e.start) / time.Millisecond),
+			UnixSecs:       uint32(now.Unix()),
+			SequenceNumber: e.sequence,
+			SourceID:       e.options.ObservationDomainID,
+			FlowSets:       sets,
+			Templates:      e.templates,
+		}
+		e.sequence++
+		return msg
+	}
+	msg := &layers.IPFIX{
+		Version:             10,
+		ExportTime:          uint32(now.Unix()),
+		SequenceNumber:      e.sequence,
+		ObservationDomainID: e.options.ObservationDomainID,
+		Sets:                sets,
+		Templates:           e.templates,
+	}
+	e.sequence += uint32(len(records))
+	return msg
+}
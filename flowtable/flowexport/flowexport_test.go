@@ -0,0 +1,198 @@
+// Copyright 2026 The GoPacket Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package flowexport
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+var (
+	client   = net.IP{10, 0, 0, 1}
+	server   = net.IP{10, 0, 0, 2}
+	client6  = net.ParseIP("2001:db8::1")
+	server6  = net.ParseIP("2001:db8::2")
+	testTime = time.Unix(1500000000, 0)
+)
+
+// testPacket returns a packet of 10 bytes of payload from src to dst, TCP
+// with SYN set if tcp, UDP otherwise, sent at ms milliseconds.
+func testPacket(t *testing.T, src, dst net.IP, sport, dport int, tcp bool, ms int) gopacket.Packet {
+	var ip gopacket.NetworkLayer
+	var l3 gopacket.SerializableLayer
+	if src.To4() != nil {
+		ip4 := &layers.IPv4{Version: 4, TTL: 64, SrcIP: src, DstIP: dst, Protocol: layers.IPProtocolUDP}
+		if tcp {
+			ip4.Protocol = layers.IPProtocolTCP
+		}
+		ip, l3 = ip4, ip4
+	} else {
+		ip6 := &layers.IPv6{Version: 6, HopLimit: 64, SrcIP: src, DstIP: dst, NextHeader: layers.IPProtocolUDP}
+		if tcp {
+			ip6.NextHeader = layers.IPProtocolTCP
+		}
+		ip, l3 = ip6, ip6
+	}
+	var l4 gopacket.SerializableLayer
+	if tcp {
+		t := &layers.TCP{SrcPort: layers.TCPPort(sport), DstPort: layers.TCPPort(dport), SYN: true, Window: 1000}
+		t.SetNetworkLayerForChecksum(ip)
+		l4 = t
+	} else {
+		u := &layers.UDP{SrcPort: layers.UDPPort(sport), DstPort: layers.UDPPort(dport)}
+		u.SetNetworkLayerForChecksum(ip)
+		l4 = u
+	}
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	if err := gopacket.SerializeLayers(buf, opts, l3, l4, gopacket.Payload("0123456789")); err != nil {
+		t.Fatal(err)
+	}
+	first := layers.LayerTypeIPv4
+	if src.To4() == nil {
+		first = layers.LayerTypeIPv6
+	}
+	p := gopacket.NewPacket(buf.Bytes(), first, gopacket.Default)
+	md := p.Metadata()
+	md.Timestamp = testTime.Add(time.Duration(ms) * time.Millisecond)
+	md.Length = len(buf.Bytes())
+	md.CaptureLength = md.Length
+	return p
+}
+
+// decode serializes messages and decodes them as a collector would, with the
+// templates of cache.
+func decode(t *testing.T, cache *layers.NetFlowTemplateCache, messages []gopacket.SerializableLayer, first gopacket.LayerType) []layers.NetFlowRecord {
+	var records []layers.NetFlowRecord
+	for _, msg := range messages {
+		buf := gopacket.NewSerializeBuffer()
+		if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true}, msg); err != nil {
+			t.Fatal(err)
+		}
+		var sets []layers.NetFlowSet
+		var err error
+		switch first {
+		case layers.LayerTypeIPFIX:
+			l := &layers.IPFIX{Templates: cache}
+			err = l.DecodeFromBytes(buf.Bytes(), gopacket.NilDecodeFeedback)
+			sets = l.Sets
+		case layers.LayerTypeNetFlowV9:
+			l := &layers.NetFlowV9{Templates: cache}
+			err = l.DecodeFromBytes(buf.Bytes(), gopacket.NilDecodeFeedback)
+			sets = l.FlowSets
+		}
+		if err != nil {
+			t.Fatalf("decoding error: %v", err)
+		}
+		for _, s := range sets {
+			if s.IsData() && s.Records == nil {
+				t.Errorf("set %d not decoded", s.ID)
+			}
+			records = append(records, s.Records...)
+		}
+	}
+	return records
+}
+
+func TestExporterIPFIX(t *testing.T) {
+	e := NewExporter(Options{ObservationDomainID: 100, InactiveTimeout: time.Second, ActiveTimeout: time.Minute})
+	e.Add(testPacket(t, client, server, 5000, 53, false, 0))
+	e.Add(testPacket(t, server, client, 53, 5000, false, 10))
+	e.Add(testPacket(t, client, server, 5000, 53, false, 20))
+	e.Add(testPacket(t, client6, server6, 40000, 443, true, 30))
+	if msgs := e.Expire(testTime.Add(500 * time.Millisecond)); len(msgs) != 0 {
+		t.Errorf("%d messages before the inactive timeout", len(msgs))
+	}
+
+	cache := layers.NewNetFlowTemplateCache()
+	records := decode(t, cache, e.Expire(testTime.Add(2*time.Second)), layers.LayerTypeIPFIX)
+	if len(records) != 3 {
+		t.Fatalf("got %d records", len(records))
+	}
+	var fwd, rev, tcp *layers.NetFlowRecord
+	for i := range records {
+		r := &records[i]
+		switch {
+		case r.SrcIP().Equal(client):
+			fwd = r
+		case r.SrcIP().Equal(server):
+			rev = r
+		case r.SrcIP().Equal(client6):
+			tcp = r
+		}
+	}
+	if fwd == nil || rev == nil || tcp == nil {
+		t.Fatalf("records: %+v", records)
+	}
+	uint := func(r *layers.NetFlowRecord, f layers.NetFlowFieldType) uint64 {
+		v, ok := r.Uint(f)
+		if !ok {
+			t.Errorf("no %v", f)
+		}
+		return v
+	}
+	if uint(fwd, layers.NetFlowFieldPacketDeltaCount) != 2 || uint(fwd, layers.NetFlowFieldOctetDeltaCount) != 76 ||
+		uint(fwd, layers.NetFlowFieldSourceTransportPort) != 5000 || uint(fwd, layers.NetFlowFieldProtocolIdentifier) != 17 {
+		t.Errorf("forward record: %+v", fwd)
+	}
+	start := uint64(testTime.UnixNano() / int64(time.Millisecond))
+	if uint(fwd, layers.NetFlowFieldFlowStartMilliseconds) != start || uint(fwd, layers.NetFlowFieldFlowEndMilliseconds) != start+20 {
+		t.Errorf("forward record times: %+v", fwd)
+	}
+	if uint(rev, layers.NetFlowFieldPacketDeltaCount) != 1 || uint(rev, layers.NetFlowFieldSourceTransportPort) != 53 ||
+		!rev.DstIP().Equal(client) || uint(rev, layers.NetFlowFieldFlowStartMilliseconds) != start+10 {
+		t.Errorf("reverse record: %+v", rev)
+	}
+	if uint(tcp, layers.NetFlowFieldTCPControlBits) != 0x02 || uint(tcp, layers.NetFlowFieldOctetDeltaCount) != 70 ||
+		uint(tcp, layers.NetFlowFieldFlowEndReason) != endIdle {
+		t.Errorf("TCP record: %+v", tcp)
+	}
+
+	// active timeout
+	for ms := 3000; ms <= 63000; ms += 500 {
+		e.Add(testPacket(t, client, server, 5001, 53, false, ms))
+	}
+	records = decode(t, cache, e.Expire(testTime.Add(63500*time.Millisecond)), layers.LayerTypeIPFIX)
+	if len(records) != 1 || uint(&records[0], layers.NetFlowFieldFlowEndReason) != endActive ||
+		uint(&records[0], layers.NetFlowFieldPacketDeltaCount) != 120 {
+		t.Errorf("active timeout records: %+v", records)
+	}
+	records = decode(t, cache, e.Flush(testTime.Add(64*time.Second)), layers.LayerTypeIPFIX)
+	if len(records) != 1 || uint(&records[0], layers.NetFlowFieldFlowEndReason) != endForced {
+		t.Errorf("flushed records: %+v", records)
+	}
+}
+
+func TestExporterNetFlowV9(t *testing.T) {
+	e := NewExporter(Options{Version: 9, ObservationDomainID: 101, MaxMessageSize: 200})
+	for i := 0; i < 10; i++ {
+		e.Add(testPacket(t, client, server, 6000+i, 53, false, 1000+i))
+	}
+	msgs := e.Flush(testTime.Add(time.Minute))
+	// 20 bytes of header, 100 of templates, and 4 + 39 per record
+	if len(msgs) != 4 {
+		t.Errorf("got %d messages", len(msgs))
+	}
+	if v9, ok := msgs[1].(*layers.NetFlowV9); !ok || v9.SequenceNumber != 1 || len(v9.FlowSets) != 1 {
+		t.Errorf("second message: %+v", msgs[1])
+	}
+	records := decode(t, layers.NewNetFlowTemplateCache(), msgs, layers.LayerTypeNetFlowV9)
+	if len(records) != 10 {
+		t.Fatalf("got %d records", len(records))
+	}
+	for _, r := range records {
+		port, _ := r.Uint(layers.NetFlowFieldSourceTransportPort)
+		start, _ := r.Uint(layers.NetFlowFieldFlowStartSysUpTime)
+		if start != port-6000 {
+			t.Errorf("port %d started at %d", port, start)
+		}
+	}
+}
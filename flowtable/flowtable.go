@@ -0,0 +1,315 @@
+// Copyright 2026 The GoPacket Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+// Package flowtable tracks the flows of any IP protocol (UDP, ICMP, SCTP...)
+// keyed on their network and transport gopacket.Flow, the way
+// reassembly.StreamPool tracks TCP connections.
+//
+// Both directions of a flow share the same Flow: packets whose key is the
+// reverse of the key of the first packet of the flow are counted in its
+// Reverse direction.  Flows expire after an idle timeout without packets,
+// or an active timeout since their first packet, NetFlow style, and hooks
+// are called when flows are created, updated and expired:
+//
+//	table := flowtable.NewTable(flowtable.Options{
+//		IdleTimeout: time.Minute,
+//		Expire: func(f *flowtable.Flow, reason flowtable.ExpireReason) {
+//			fmt.Println(f.Key, f.Forward.Packets, f.Reverse.Packets, reason)
+//		},
+//	})
+//	for packet := range source.Packets() {
+//		table.UpdatePacket(packet)
+//	}
+//	table.Flush()
+//
+// Timeouts are measured with the timestamps of the packets, so that
+// captures read from files expire as they did on the wire; call Expire
+// regularly to expire flows that are not seeing packets anymore.
+package flowtable
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// Key identifies the packets of a direction of a flow.
+type Key struct {
+	Network, Transport gopacket.Flow
+}
+
+// KeyFromPacket returns the key of a packet: its network flow and its
+// transport flow.  Packets of IP protocols without ports, like ICMP, get a
+// transport flow between two layers.EndpointIPProtocol endpoints.  It
+// returns false for packets without network layer.
+func KeyFromPacket(p gopacket.Packet) (Key, bool) {
+	nl := p.NetworkLayer()
+	if nl == nil {
+		return Key{}, false
+	}
+	k := Key{Network: nl.NetworkFlow()}
+	if tl := p.TransportLayer(); tl != nil {
+		k.Transport = tl.TransportFlow()
+		return k, true
+	}
+	var proto layers.IPProtocol
+	switch {
+	case p.Layer(layers.LayerTypeICMPv4) != nil:
+		proto = layers.IPProtocolICMPv4
+	case p.Layer(layers.LayerTypeICMPv6) != nil:
+		proto = layers.IPProtocolICMPv6
+	default:
+		switch ip := nl.(type) {
+		case *layers.IPv4:
+			proto = ip.Protocol
+		case *layers.IPv6:
+			proto = ip.NextHeader
+		}
+	}
+	e := layers.NewIPProtocolEndpoint(proto)
+	k.Transport, _ = gopacket.FlowFromEndpoints(e, e)
+	return k, true
+}
+
+// Reverse returns the key of the other direction.
+func (k Key) Reverse() Key {
+	return Key{k.Network.Reverse(), k.Transport.Reverse()}
+}
+
+func (k Key) String() string {
+	return fmt.Sprintf("%v:%v", k.Network, k.Transport)
+}
+
+// Direction of a packet in a flow.
+type Direction bool
+
+// Directions, relative to the first packet of the flow
+const (
+	Forward Direction = false
+	Reverse Direction = true
+)
+
+func (d Direction) String() string {
+	if d == Forward {
+		return "forward"
+	}
+	return "reverse"
+}
+
+// Counters holds the figures of a direction of a flow.
+type Counters struct {
+	Packets, Bytes uint64
+}
+
+// Flow holds the state of a bidirectional flow.
+type Flow struct {
+	// Key is the key of the first packet, the Forward direction
+	Key Key
+	// First and Last are the timestamps of the first and last packets
+	First, Last      time.Time
+	Forward, Reverse Counters
+	// Data is left to the hooks, to attach their own state to the flow
+	Data interface{}
+}
+
+// Counters returns the counters of direction d.
+func (f *Flow) Counters(d Direction) *Counters {
+	if d == Forward {
+		return &f.Forward
+	}
+	return &f.Reverse
+}
+
+// ExpireReason tells why a flow expired.
+type ExpireReason int
+
+// Reasons for Options.Expire
+const (
+	// ExpireIdle flows saw no packets for Options.IdleTimeout
+	ExpireIdle ExpireReason = iota
+	// ExpireActive flows lasted Options.ActiveTimeout
+	ExpireActive
+	// ExpireFlush flows were removed by Table.Flush
+	ExpireFlush
+)
+
+func (r ExpireReason) String() string {
+	switch r {
+	case ExpireIdle:
+		return "idle"
+	case ExpireActive:
+		return "active"
+	case ExpireFlush:
+		return "flush"
+	}
+	return "unknown"
+}
+
+// Options controls the behavior of a Table.  Hooks are called with the
+// Table locked, they must not call its methods.
+type Options struct {
+	// IdleTimeout expires flows without packets for this long.  If 0,
+	// flows never become idle.
+	IdleTimeout time.Duration
+	// ActiveTimeout expires flows this long after their first packet,
+	// even if they still see packets: the next packets start a new flow.
+	// If 0, flows stay active as long as they see packets.
+	ActiveTimeout time.Duration
+	// New is called when a flow is created, before Update is called for
+	// its first packet.
+	New func(f *Flow, ci gopacket.CaptureInfo)
+	// Update is called for every packet, once its direction is counted.
+	Update func(f *Flow, d Direction, ci gopacket.CaptureInfo)
+	// Expire is called when a flow is removed from the table.
+	Expire func(f *Flow, reason ExpireReason)
+}
+
+// Table tracks flows.  It is safe for concurrent use.
+type Table struct {
+	options Options
+	mu      sync.Mutex
+	flows   map[Key]*Flow
+}
+
+// NewTable creates a new flow table.
+func NewTable(options Options) *Table {
+	return &Table{
+		options: options,
+		flows:   make(map[Key]*Flow),
+	}
+}
+
+// lookup returns the flow of key and the direction of key in it.
+func (t *Table) lookup(key Key) (*Flow, Direction) {
+	if f := t.flows[key]; f != nil {
+		return f, Forward
+	}
+	if f := t.flows[key.Reverse()]; f != nil {
+		return f, Reverse
+	}
+	return nil, Forward
+}
+
+// expired returns whether f expires at ts, and why.
+func (t *Table) expired(f *Flow, ts time.Time) (bool, ExpireReason) {
+	if t.options.ActiveTimeout > 0 && !ts.Before(f.First.Add(t.options.ActiveTimeout)) {
+		return true, ExpireActive
+	}
+	if t.options.IdleTimeout > 0 && !ts.Before(f.Last.Add(t.options.IdleTimeout)) {
+		return true, ExpireIdle
+	}
+	return false, 0
+}
+
+func (t *Table) remove(f *Flow, reason ExpireReason) {
+	delete(t.flows, f.Key)
+	if t.options.Expire != nil {
+		t.options.Expire(f, reason)
+	}
+}
+
+// Update counts a packet with the given key, capture info and length in
+// bytes, creating its flow if needed, and returns the flow and the
+// direction of the packet in it.  A flow that expired by the packet's
+// timestamp is replaced by a new one.
+func (t *Table) Update(key Key, ci gopacket.CaptureInfo, length int) (*Flow, Direction) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	f, d := t.lookup(key)
+	if f != nil {
+		if ok, reason := t.expired(f, ci.Timestamp); ok {
+			t.remove(f, reason)
+			f, d = nil, Forward
+		}
+	}
+	if f == nil {
+		f = &Flow{Key: key, First: ci.Timestamp, Last: ci.Timestamp}
+		t.flows[key] = f
+		if t.options.New != nil {
+			t.options.New(f, ci)
+		}
+	}
+	if ci.Timestamp.After(f.Last) {
+		f.Last = ci.Timestamp
+	}
+	c := f.Counters(d)
+	c.Packets++
+	c.Bytes += uint64(length)
+	if t.options.Update != nil {
+		t.options.Update(f, d, ci)
+	}
+	return f, d
+}
+
+// UpdatePacket calls Update with the key of the packet, its metadata and
+// its length on the wire.  It returns false, without updating the table,
+// for packets without network layer.
+func (t *Table) UpdatePacket(p gopacket.Packet) (*Flow, Direction, bool) {
+	key, ok := KeyFromPacket(p)
+	if !ok {
+		return nil, Forward, false
+	}
+	ci := p.Metadata().CaptureInfo
+	f, d := t.Update(key, ci, ci.Length)
+	return f, d, true
+}
+
+// Lookup returns the flow of key, if any, and the direction of key in it.
+func (t *Table) Lookup(key Key) (*Flow, Direction, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	f, d := t.lookup(key)
+	return f, d, f != nil
+}
+
+// Expire removes the flows expired at now, calling the Expire hook for
+// each of them, and returns their number.
+func (t *Table) Expire(now time.Time) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	n := 0
+	for _, f := range t.flows {
+		if ok, reason := t.expired(f, now); ok {
+			t.remove(f, reason)
+			n++
+		}
+	}
+	return n
+}
+
+// Flush removes all flows, calling the Expire hook for each of them, and
+// returns their number.
+func (t *Table) Flush() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	n := len(t.flows)
+	for _, f := range t.flows {
+		t.remove(f, ExpireFlush)
+	}
+	return n
+}
+
+// Len returns the number of flows in the table.
+func (t *Table) Len() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.flows)
+}
+
+// Range calls fn for every flow of the table, until fn returns false.
+// fn must not call the methods of the table.
+func (t *Table) Range(fn func(f *Flow) bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, f := range t.flows {
+		if !fn(f) {
+			return
+		}
+	}
+}
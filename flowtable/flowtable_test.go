@@ -0,0 +1,105 @@
+// Copyright 2026 The GoPacket Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package flowtable
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+var (
+	hostA = net.IP{10, 0, 0, 1}
+	hostB = net.IP{10, 0, 0, 2}
+)
+
+// testPacket returns a packet from src to dst, UDP between sport and dport,
+// or ICMP echo if sport is 0.
+func testPacket(t *testing.T, src, dst net.IP, sport, dport int, ts time.Time) gopacket.Packet {
+	ip := &layers.IPv4{Version: 4, TTL: 64, SrcIP: src, DstIP: dst, Protocol: layers.IPProtocolUDP}
+	var l4 gopacket.SerializableLayer
+	if sport == 0 {
+		ip.Protocol = layers.IPProtocolICMPv4
+		l4 = &layers.ICMPv4{TypeCode: layers.CreateICMPv4TypeCode(layers.ICMPv4TypeEchoRequest, 0)}
+	} else {
+		udp := &layers.UDP{SrcPort: layers.UDPPort(sport), DstPort: layers.UDPPort(dport)}
+		udp.SetNetworkLayerForChecksum(ip)
+		l4 = udp
+	}
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	if err := gopacket.SerializeLayers(buf, opts, ip, l4, gopacket.Payload("data")); err != nil {
+		t.Fatal(err)
+	}
+	p := gopacket.NewPacket(buf.Bytes(), layers.LayerTypeIPv4, gopacket.Default)
+	md := p.Metadata()
+	md.Timestamp = ts
+	md.CaptureLength = len(buf.Bytes())
+	md.Length = len(buf.Bytes())
+	return p
+}
+
+func TestTable(t *testing.T) {
+	var news, updates int
+	expired := map[ExpireReason]int{}
+	table := NewTable(Options{
+		IdleTimeout:   2 * time.Minute,
+		ActiveTimeout: 10 * time.Minute,
+		New: func(f *Flow, ci gopacket.CaptureInfo) {
+			news++
+			f.Data = "mine"
+		},
+		Update: func(f *Flow, d Direction, ci gopacket.CaptureInfo) {
+			updates++
+		},
+		Expire: func(f *Flow, reason ExpireReason) {
+			expired[reason]++
+		},
+	})
+	ts := time.Unix(1000, 0)
+
+	f, d, ok := table.UpdatePacket(testPacket(t, hostA, hostB, 5353, 53, ts))
+	if !ok || d != Forward || f.Data != "mine" {
+		t.Fatalf("first packet: %v, %v, %v", f, d, ok)
+	}
+	f2, d, _ := table.UpdatePacket(testPacket(t, hostB, hostA, 53, 5353, ts.Add(time.Second)))
+	if f2 != f || d != Reverse {
+		t.Errorf("reply not matched: %v", d)
+	}
+	table.UpdatePacket(testPacket(t, hostA, hostB, 5354, 53, ts))
+	icmp, _, _ := table.UpdatePacket(testPacket(t, hostA, hostB, 0, 0, ts))
+	if icmp.Key.Transport.EndpointType() != layers.EndpointIPProtocol || icmp.Key.Transport.Src().String() != "ICMPv4" {
+		t.Errorf("ICMP key: %v", icmp.Key)
+	}
+	if table.Len() != 3 || news != 3 || updates != 4 {
+		t.Errorf("got %d flows, %d new, %d updates", table.Len(), news, updates)
+	}
+	if f.Forward.Packets != 1 || f.Reverse.Packets != 1 || f.Forward.Bytes != 32 || !f.Last.Equal(ts.Add(time.Second)) {
+		t.Errorf("flow: %+v", f)
+	}
+
+	// keep the first flow alive until its active timeout
+	for i := 1; i < 10; i++ {
+		table.UpdatePacket(testPacket(t, hostA, hostB, 5353, 53, ts.Add(time.Duration(i)*time.Minute)))
+	}
+	if n := table.Expire(ts.Add(9*time.Minute + time.Second)); n != 2 || expired[ExpireIdle] != 2 {
+		t.Errorf("expired %d flows, %v", n, expired)
+	}
+	f3, d, _ := table.UpdatePacket(testPacket(t, hostB, hostA, 53, 5353, ts.Add(10*time.Minute)))
+	if f3 == f || d != Forward || expired[ExpireActive] != 1 {
+		t.Errorf("active timeout: same flow %v, %v, %v", f3 == f, d, expired)
+	}
+	if _, _, ok := table.Lookup(f3.Key.Reverse()); !ok {
+		t.Error("reverse key not found")
+	}
+	if n := table.Flush(); n != 1 || expired[ExpireFlush] != 1 || table.Len() != 0 {
+		t.Errorf("flushed %d flows, %v", n, expired)
+	}
+}
@@ -8,8 +8,6 @@
 package ip4defrag
 
 import (
-	"container/list"
-	"errors"
 	"fmt"
 	"log"
 	"sync"
@@ -39,6 +37,110 @@ const (
 	IPv4MaximumFragmentListLen = 8192  // Back out if we get more than this many fragments
 )
 
+// Policy selects which data is kept when a fragment overlaps data
+// already received for the same datagram. Different IP stacks resolve
+// overlaps differently; an IDS must mimic the stack of the host the
+// datagram is sent to in order to see what that host sees.
+//
+// The policies are the ones of Snort's frag3 preprocessor, described in
+// "Target-Based Fragmentation Reassembly" (Judy Novak, 2005). In the
+// descriptions below, the original fragment is the one whose data is
+// already held, the subsequent fragment is the one being inserted.
+type Policy uint8
+
+// Policy known values.
+const (
+	// PolicyBSDRight favors the subsequent fragment, unless the original
+	// one begins before it and ends at or after its end. Used by HP
+	// JetDirect printers. This is the default.
+	PolicyBSDRight Policy = iota
+	// PolicyBSD favors the original fragment, unless the subsequent one
+	// begins before it. Used by AIX, FreeBSD, HP-UX 10, IRIX, OpenVMS.
+	PolicyBSD
+	// PolicyFirst always favors the original fragment. Used by Mac OS
+	// and HP-UX 11.
+	PolicyFirst
+	// PolicyLast always favors the subsequent fragment. Used by Cisco
+	// IOS.
+	PolicyLast
+	// PolicyLinux favors the original fragment, unless the subsequent
+	// one begins before or at the same offset. Used by Linux and
+	// OpenBSD.
+	PolicyLinux
+	// PolicyWindows favors the original fragment, unless the subsequent
+	// one begins before it and ends after it.
+	PolicyWindows
+	// PolicySolaris favors the original fragment, unless the subsequent
+	// one begins before it and ends at or after its end.
+	PolicySolaris
+)
+
+func (p Policy) String() string {
+	switch p {
+	case PolicyBSDRight:
+		return "bsd-right"
+	case PolicyBSD:
+		return "bsd"
+	case PolicyFirst:
+		return "first"
+	case PolicyLast:
+		return "last"
+	case PolicyLinux:
+		return "linux"
+	case PolicyWindows:
+		return "windows"
+	case PolicySolaris:
+		return "solaris"
+	}
+	return fmt.Sprintf("Policy(%d)", uint8(p))
+}
+
+// replaces reports whether, under policy p, data of a subsequent
+// fragment spanning sub replaces data of an original fragment spanning
+// orig.
+func (p Policy) replaces(orig, sub span) bool {
+	switch p {
+	case PolicyBSD:
+		return sub.start < orig.start
+	case PolicyFirst:
+		return false
+	case PolicyLast:
+		return true
+	case PolicyLinux:
+		return sub.start <= orig.start
+	case PolicyWindows:
+		return sub.start < orig.start && sub.end > orig.end
+	case PolicySolaris:
+		return sub.start < orig.start && sub.end >= orig.end
+	}
+	return !(orig.start < sub.start && orig.end >= sub.end)
+}
+
+// Overlap describes a fragment carrying data that conflicts with data
+// previously received at the same offset of the same datagram.
+type Overlap struct {
+	// Flow and ID identify the datagram.
+	Flow gopacket.Flow
+	ID   uint16
+	// Offset is the offset of the conflicting bytes in the payload of
+	// the datagram.
+	Offset uint16
+	// Original holds the bytes previously received, Subsequent the bytes
+	// carried by the new fragment.
+	Original, Subsequent []byte
+	// Replaced is true if the policy kept Subsequent in place of
+	// Original.
+	Replaced bool
+}
+
+// Stats holds the counters of an IPv4Defragmenter.
+type Stats struct {
+	Fragments   int // fragments handled
+	Reassembled int // datagrams successfully reassembled
+	Overlaps    int // fragments overlapping previously received data
+	Conflicts   int // overlapping fragments carrying different data
+}
+
 // DefragIPv4 takes in an IPv4 packet with a fragment payload.
 //
 // It do not modify the IPv4 layer in place, 'in' remains untouched
@@ -110,14 +212,33 @@ func (d *IPv4Defragmenter) DefragIPv4WithTimestamp(in *layers.IPv4, t time.Time)
 		fl = new(fragmentList)
 		d.ipFlows[ipf] = fl
 	}
-	d.Unlock()
 	// insert, and if final build it
-	out, err2 := fl.insert(in, t)
+	out, overlaps, overlapped := fl.insert(in, t, d.Policy)
+	d.stats.Fragments++
+	if overlapped {
+		d.stats.Overlaps++
+	}
+	if len(overlaps) > 0 {
+		d.stats.Conflicts++
+	}
+	if out != nil {
+		d.stats.Reassembled++
+	}
+	listLen := len(fl.frags)
+	d.Unlock()
+
+	if d.OnOverlap != nil {
+		for i := range overlaps {
+			overlaps[i].Flow = ipf.ip4
+			overlaps[i].ID = ipf.id
+			d.OnOverlap(overlaps[i])
+		}
+	}
 
 	// at last, if we hit the maximum frag list len
 	// without any defrag success, we just drop everything and
 	// raise an error
-	if out == nil && fl.List.Len()+1 > IPv4MaximumFragmentListLen {
+	if out == nil && listLen+1 > IPv4MaximumFragmentListLen {
 		d.flush(ipf)
 		return nil, fmt.Errorf("defrag: Fragment List hits its maximum"+
 			"size(%d), without success. Flushing the list",
@@ -131,7 +252,7 @@ func (d *IPv4Defragmenter) DefragIPv4WithTimestamp(in *layers.IPv4, t time.Time)
 		d.flush(ipf)
 		return out, nil
 	}
-	return nil, err2
+	return nil, nil
 }
 
 // DiscardOlderThan forgets all packets without any activity since
@@ -150,6 +271,13 @@ func (d *IPv4Defragmenter) DiscardOlderThan(t time.Time) int {
 	return nb
 }
 
+// Stats returns a snapshot of the defragmenter counters.
+func (d *IPv4Defragmenter) Stats() Stats {
+	d.RLock()
+	defer d.RUnlock()
+	return d.stats
+}
+
 // flush the fragment list for a particular flow
 func (d *IPv4Defragmenter) flush(ipf ipv4) {
 	d.Lock()
@@ -194,71 +322,97 @@ func (d *IPv4Defragmenter) securityChecks(ip *layers.IPv4) error {
 			"(handcrafted? %d > %d)", fragOffset+ip.Length, IPv4MaximumSize)
 	}
 
+	// don't allow fragments missing part of their payload, which would
+	// leave a hole in the datagram
+	if len(ip.Payload) < int(fragSize) {
+		return fmt.Errorf("defrag: fragment truncated "+
+			"(%d < %d)", len(ip.Payload), fragSize)
+	}
+
 	return nil
 }
 
-// fragmentList holds a container/list used to contains IP
-// packets/fragments.  It stores internal counters to track the
-// maximum total of byte, and the current length it has received.
-// It also stores a flag to know if he has seen the last packet.
+// span is the range of datagram payload bytes carried by a fragment.
+type span struct {
+	start, end int
+}
+
+// fragmentList holds the payload of a datagram being reassembled.
+// For each byte received so far, it records which fragment (index in
+// frags plus one) its current value comes from, so that overlaps can be
+// resolved against the original fragment. It stores internal counters
+// to track the maximum total of byte, and the number of bytes it has
+// received. It also stores a flag to know if he has seen the last
+// packet.
 type fragmentList struct {
-	List          list.List
+	frags         []span
+	data          []byte
+	owner         []uint16
 	Highest       uint16
 	Current       uint16
 	FinalReceived bool
 	LastSeen      time.Time
 }
 
-// insert insert an IPv4 fragment/packet into the Fragment List
-// It use the following strategy : we are inserting fragment based
-// on their offset, latest first. This is sometimes called BSD-Right.
-// See: http://www.sans.org/reading-room/whitepapers/detection/ip-fragment-reassembly-scapy-33969
-func (f *fragmentList) insert(in *layers.IPv4, t time.Time) (*layers.IPv4, error) {
-	// TODO: should keep a copy of *in in the list
-	// or not (ie the packet source is reliable) ? -> depends on Lazy / last packet
-	fragOffset := in.FragOffset * 8
-	if fragOffset >= f.Highest {
-		f.List.PushBack(in)
-	} else {
-		for e := f.List.Front(); e != nil; e = e.Next() {
-			frag, _ := e.Value.(*layers.IPv4)
-			if in.FragOffset == frag.FragOffset {
-				// TODO: what if we receive a fragment
-				// that begins with duplicate data but
-				// *also* has new data? For example:
-				//
-				// AAAA
-				//     BB
-				//     BBCC
-				//         DDDD
-				//
-				// In this situation we completely
-				// ignore CC and the complete packet can
-				// never be reassembled.
-				debug.Printf("defrag: ignoring frag %d as we already have it (duplicate?)\n",
-					fragOffset)
-				return nil, nil
-			}
-			if in.FragOffset < frag.FragOffset {
-				debug.Printf("defrag: inserting frag %d before existing frag %d\n",
-					fragOffset, frag.FragOffset*8)
-				f.List.InsertBefore(in, e)
-				break
+// insert inserts an IPv4 fragment/packet into the Fragment List,
+// resolving overlaps with previously received data according to policy
+// p. It returns the reassembled datagram if it is complete, the
+// overlaps with conflicting data and whether the fragment overlapped
+// previously received data at all.
+func (f *fragmentList) insert(in *layers.IPv4, t time.Time, p Policy) (*layers.IPv4, []Overlap, bool) {
+	s := span{start: int(in.FragOffset) * 8}
+	s.end = s.start + int(in.Length) - int(in.IHL)*4
+	payload := in.Payload[:s.end-s.start]
+	if s.end > len(f.data) {
+		f.data = append(f.data, make([]byte, s.end-len(f.data))...)
+		f.owner = append(f.owner, make([]uint16, s.end-len(f.owner))...)
+	}
+	f.frags = append(f.frags, s)
+	idx := uint16(len(f.frags))
+
+	var overlaps []Overlap
+	var overlapped bool
+	var last uint16 // original fragment of the last overlap
+	for i := s.start; i < s.end; i++ {
+		b := payload[i-s.start]
+		o := f.owner[i]
+		if o == 0 {
+			f.data[i] = b
+			f.owner[i] = idx
+			f.Current++
+			continue
+		}
+		overlapped = true
+		replace := p.replaces(f.frags[o-1], s)
+		if f.data[i] != b {
+			n := len(overlaps) - 1
+			if n < 0 || last != o || int(overlaps[n].Offset)+len(overlaps[n].Original) != i {
+				overlaps = append(overlaps, Overlap{Offset: uint16(i), Replaced: replace})
+				n++
+				last = o
 			}
+			overlaps[n].Original = append(overlaps[n].Original, f.data[i])
+			overlaps[n].Subsequent = append(overlaps[n].Subsequent, b)
+		}
+		if replace {
+			f.data[i] = b
+			f.owner[i] = idx
 		}
 	}
+	if overlapped {
+		debug.Printf("defrag: frag %d-%d overlaps, %d conflicts (policy %s)\n",
+			s.start, s.end, len(overlaps), p)
+	}
 
 	f.LastSeen = t
 
-	fragLength := in.Length - 20
 	// After inserting the Fragment, we update the counters
-	if f.Highest < fragOffset+fragLength {
-		f.Highest = fragOffset + fragLength
+	if int(f.Highest) < s.end {
+		f.Highest = uint16(s.end)
 	}
-	f.Current = f.Current + fragLength
 
 	debug.Printf("defrag: insert ListLen: %d Highest:%d Current:%d\n",
-		f.List.Len(),
+		len(f.frags),
 		f.Highest, f.Current)
 
 	// Final Fragment ?
@@ -267,43 +421,17 @@ func (f *fragmentList) insert(in *layers.IPv4, t time.Time) (*layers.IPv4, error
 	}
 	// Ready to try defrag ?
 	if f.FinalReceived && f.Highest == f.Current {
-		return f.build(in)
+		return f.build(in), overlaps, overlapped
 	}
-	return nil, nil
+	return nil, overlaps, overlapped
 }
 
-// Build builds the final datagram, modifying ip in place.
-// It puts priority to packet in the early position of the list.
-// See Insert for more details.
-func (f *fragmentList) build(in *layers.IPv4) (*layers.IPv4, error) {
-	var final []byte
-	var currentOffset uint16
-
+// build builds the final datagram from the received payload and the
+// header of in.
+func (f *fragmentList) build(in *layers.IPv4) *layers.IPv4 {
 	debug.Printf("defrag: building the datagram \n")
-	for e := f.List.Front(); e != nil; e = e.Next() {
-		frag, _ := e.Value.(*layers.IPv4)
-		if frag.FragOffset*8 == currentOffset {
-			debug.Printf("defrag: building - adding %d\n", frag.FragOffset*8)
-			final = append(final, frag.Payload...)
-			currentOffset = currentOffset + frag.Length - 20
-		} else if frag.FragOffset*8 < currentOffset {
-			// overlapping fragment - let's take only what we need
-			startAt := currentOffset - frag.FragOffset*8
-			debug.Printf("defrag: building - overlapping, starting at %d\n",
-				startAt)
-			if startAt > frag.Length-20 {
-				return nil, errors.New("defrag: building - invalid fragment")
-			}
-			final = append(final, frag.Payload[startAt:]...)
-			currentOffset = currentOffset + frag.FragOffset*8
-		} else {
-			// Houston - we have an hole !
-			debug.Printf("defrag: hole found while building, " +
-				"stopping the defrag process\n")
-			return nil, errors.New("defrag: building - hole found")
-		}
-		debug.Printf("defrag: building - next is %d\n", currentOffset)
-	}
+	final := make([]byte, f.Highest)
+	copy(final, f.data)
 
 	// TODO recompute IP Checksum
 	out := &layers.IPv4{
@@ -324,7 +452,7 @@ func (f *fragmentList) build(in *layers.IPv4) (*layers.IPv4, error) {
 	}
 	out.Payload = final
 
-	return out, nil
+	return out
 }
 
 // ipv4 is a struct to be used as a key.
@@ -343,9 +471,18 @@ func newIPv4(ip *layers.IPv4) ipv4 {
 
 // IPv4Defragmenter is a struct which embedded a map of
 // all fragment/packet.
+//
+// Policy and OnOverlap must be set before the defragmenter is used.
 type IPv4Defragmenter struct {
 	sync.RWMutex
 	ipFlows map[ipv4]*fragmentList
+	stats   Stats
+
+	// Policy selects how overlapping fragments are resolved.
+	Policy Policy
+	// OnOverlap, if not nil, is called for every range of bytes
+	// received twice with different data.
+	OnOverlap func(Overlap)
 }
 
 // NewIPv4Defragmenter returns a new IPv4Defragmenter
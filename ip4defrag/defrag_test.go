@@ -11,6 +11,7 @@ import (
 	"encoding/binary"
 	"fmt"
 	"net"
+	"strings"
 	"testing"
 	"time"
 
@@ -164,6 +165,7 @@ func TestDefragTooSmall(t *testing.T) {
 		Length:     27, // Minimum fragment size -1 + header (20)
 		Flags:      layers.IPv4MoreFragments,
 	}
+	ip1.Payload = make([]byte, 8)
 	if _, err := defrag.DefragIPv4(&ip1); err == nil {
 		t.Fatal("defrag: Minimum fragment size is supposed to be 8")
 	}
@@ -188,6 +190,7 @@ func TestDefragFragmentOffset(t *testing.T) {
 		Length:     512,
 		Flags:      layers.IPv4MoreFragments,
 	}
+	ip1.Payload = make([]byte, 512-20)
 	if _, err := defrag.DefragIPv4(&ip1); err != nil {
 		t.Fatal(err)
 	}
@@ -227,6 +230,7 @@ func TestDefragMaxSize(t *testing.T) {
 		Length:     65535,
 		Flags:      layers.IPv4MoreFragments,
 	}
+	ip1.Payload = make([]byte, 65535-20)
 	if _, err := defrag.DefragIPv4(&ip1); err != nil {
 		t.Fatal(err)
 	}
@@ -259,6 +263,142 @@ func TestDefragIDField(t *testing.T) {
 
 }
 
+// novakFragments is the overlap test pattern of "Target-Based
+// Fragmentation Reassembly" (Novak, 2005): each fragment is filled with
+// its own letter, offsets and lengths are in bytes.
+var novakFragments = []struct {
+	offset, length int
+	fill           byte
+}{
+	{0, 24, 'A'}, {32, 16, 'B'}, {48, 24, 'C'}, {80, 8, 'D'},
+	{104, 16, 'E'}, {120, 24, 'F'}, {144, 16, 'G'}, {160, 16, 'H'},
+	{176, 8, 'I'},
+	{8, 32, 'J'}, {48, 24, 'K'}, {72, 24, 'L'}, {96, 24, 'M'},
+	{128, 8, 'N'}, {152, 8, 'O'}, {160, 8, 'P'}, {176, 16, 'Q'},
+}
+
+func newFragment(id uint16, offset, length int, fill byte, more bool) *layers.IPv4 {
+	ip := &layers.IPv4{
+		Version:    4,
+		IHL:        5,
+		TTL:        15,
+		SrcIP:      net.IPv4(1, 1, 1, 1),
+		DstIP:      net.IPv4(2, 2, 2, 2),
+		Id:         id,
+		FragOffset: uint16(offset / 8),
+		Length:     uint16(20 + length),
+	}
+	if more {
+		ip.Flags = layers.IPv4MoreFragments
+	}
+	ip.Payload = bytes.Repeat([]byte{fill}, length)
+	return ip
+}
+
+func TestDefragPolicies(t *testing.T) {
+	for _, test := range []struct {
+		policy Policy
+		want   string // one letter per 8 bytes
+	}{
+		{PolicyBSD, "AAAJJBCCCLLLMMMFFFGGHHIQ"},
+		{PolicyFirst, "AAAJBBCCCLDLMEEFFFGGHHIQ"},
+		{PolicyLast, "AJJJJBKKKLLLMMMFNFGOPHQQ"},
+		{PolicyBSDRight, "AJJJJBKKKLLLMMMFFFGGPHQQ"},
+		{PolicyLinux, "AAAJJBKKKLLLMMMFFFGGPHQQ"},
+		{PolicyWindows, "AAAJBBCCCLLLMEEFFFGGHHIQ"},
+		{PolicySolaris, "AAAJBBCCCLLLMMMFFFGGHHIQ"},
+	} {
+		defrag := NewIPv4Defragmenter()
+		defrag.Policy = test.policy
+		var conflicts int
+		defrag.OnOverlap = func(o Overlap) {
+			conflicts++
+			if o.ID != 0xcc || len(o.Original) != len(o.Subsequent) || len(o.Original) == 0 {
+				t.Errorf("%s: unexpected overlap %+v", test.policy, o)
+			}
+		}
+		var out *layers.IPv4
+		for i, f := range novakFragments {
+			var err error
+			out, err = defrag.DefragIPv4(newFragment(0xcc, f.offset, f.length, f.fill, i != len(novakFragments)-1))
+			if err != nil {
+				t.Fatalf("%s: %v", test.policy, err)
+			}
+			if out != nil && i != len(novakFragments)-1 {
+				t.Fatalf("%s: datagram reassembled after fragment %c", test.policy, f.fill)
+			}
+		}
+		if out == nil {
+			t.Fatalf("%s: datagram not reassembled", test.policy)
+		}
+		var want []byte
+		for _, c := range []byte(test.want) {
+			want = append(want, bytes.Repeat([]byte{c}, 8)...)
+		}
+		if !bytes.Equal(out.Payload, want) {
+			t.Errorf("%s: got payload\n%s\nwant\n%s", test.policy, out.Payload, want)
+		}
+		stats := defrag.Stats()
+		if stats.Fragments != len(novakFragments) || stats.Reassembled != 1 ||
+			stats.Overlaps != 8 || stats.Conflicts != 8 {
+			t.Errorf("%s: unexpected stats %+v", test.policy, stats)
+		}
+		if conflicts < stats.Conflicts {
+			t.Errorf("%s: got %d overlap events, want at least %d", test.policy, conflicts, stats.Conflicts)
+		}
+	}
+}
+
+func TestDefragOverlapSameData(t *testing.T) {
+	defrag := NewIPv4Defragmenter()
+	defrag.OnOverlap = func(o Overlap) {
+		t.Errorf("unexpected overlap %+v", o)
+	}
+
+	// AAAA
+	//     BB
+	//     BBCC
+	//         DDDD
+	frags := []*layers.IPv4{
+		newFragment(1, 0, 32, 'A', true),
+		newFragment(1, 32, 16, 'B', true),
+		newFragment(1, 32, 32, 'B', true),
+		newFragment(1, 64, 32, 'D', false),
+	}
+	copy(frags[2].Payload[16:], bytes.Repeat([]byte{'C'}, 16))
+	var out *layers.IPv4
+	for _, f := range frags {
+		var err error
+		if out, err = defrag.DefragIPv4(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if out == nil {
+		t.Fatal("datagram not reassembled")
+	}
+	want := strings.Repeat("A", 32) + strings.Repeat("B", 16) + strings.Repeat("C", 16) + strings.Repeat("D", 32)
+	if string(out.Payload) != want {
+		t.Errorf("got payload %s, want %s", out.Payload, want)
+	}
+	if stats := defrag.Stats(); stats.Overlaps != 1 || stats.Conflicts != 0 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestDefragTruncated(t *testing.T) {
+	defrag := NewIPv4Defragmenter()
+
+	frag := newFragment(1, 0, 32, 'A', true)
+	frag.Payload = frag.Payload[:16]
+	if _, err := defrag.DefragIPv4(frag); err == nil {
+		t.Fatal("defrag: truncated fragment accepted")
+	}
+	out, err := defrag.DefragIPv4(newFragment(1, 32, 32, 'B', false))
+	if out != nil || err != nil {
+		t.Fatalf("defrag: datagram reassembled without its first fragment: %v, %v", out, err)
+	}
+}
+
 func gentestDefrag(t *testing.T, defrag *IPv4Defragmenter, buf []byte, expect bool, label string) *layers.IPv4 {
 	p := gopacket.NewPacket(buf, layers.LinkTypeEthernet, gopacket.Default)
 	if p.ErrorLayer() != nil {
@@ -0,0 +1,404 @@
+// Copyright 2026 The GoPacket Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+// Package ip6defrag implements a IPv6 defragmenter
+package ip6defrag
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// Quick and Easy to use debug code to trace
+// how defrag works.
+var debug debugging = false // or flip to true
+type debugging bool
+
+func (d debugging) Printf(format string, args ...interface{}) {
+	if d {
+		log.Printf(format, args...)
+	}
+}
+
+// Constants determining how to handle fragments.
+// Reference RFC 8200, section 4.5
+const (
+	IPv6FragmentHeaderLength   = 8     // Length of the Fragment extension header
+	IPv6MaximumSize            = 65535 // Maximum size of the reassembled payload (2^16)
+	IPv6MaximumFragmentListLen = 8192  // Back out if we get more than this many fragments
+)
+
+// DefragIPv6 takes in an IPv6 packet with a fragment extension header.
+//
+// It do not modify the IPv6 layer in place, 'in' remains untouched
+// It returns a ready-to be used IPv6 layer.
+//
+// If the passed-in IPv6 layer is NOT fragmented, it will
+// immediately return it without modifying the layer.
+//
+// If the IPv6 layer is a fragment and we don't have all
+// fragments, it will return nil and store whatever internal
+// information it needs to eventually defrag the packet.
+//
+// If the IPv6 layer is the last fragment needed to reconstruct
+// the packet, a new IPv6 layer will be returned, and will be set to
+// the entire defragmented packet. Its header chain is the unfragmentable
+// part of the first fragment (hop-by-hop, routing and destination options
+// headers preceding the fragment header) directly followed by the
+// reassembled fragmentable part: the fragment header itself is gone.
+//
+// Atomic fragments (offset 0 without the M flag, RFC 6946) are returned
+// right away, with the fragment header removed, without looking at other
+// fragments of the same flow.
+//
+// Following RFC 5722, a datagram with overlapping fragments is dropped
+// along with every fragment of it received later on; an error is returned
+// for each of them. Exact duplicates of an already received fragment are
+// silently ignored.
+//
+// # It use a map of all the running flows
+//
+// Usage example:
+//
+//	func HandlePacket(in *layers.IPv6) err {
+//	    defragger := ip6defrag.NewIPv6Defragmenter()
+//	    in, err := defragger.DefragIPv6(in)
+//	    if err != nil {
+//	        return err
+//	    } else if in == nil {
+//	        return nil  // packet fragment, we don't have whole packet yet.
+//	    }
+//	    // At this point, we know that 'in' is defragmented.
+//	    ... do stuff to 'in' ...
+//	}
+func (d *IPv6Defragmenter) DefragIPv6(in *layers.IPv6) (*layers.IPv6, error) {
+	return d.DefragIPv6WithTimestamp(in, time.Now())
+}
+
+// DefragIPv6WithTimestamp provides functionality of DefragIPv6 with
+// an additional timestamp parameter which is used for discarding
+// old fragments instead of time.Now()
+//
+// This is useful when operating on pcap files instead of live captured data
+func (d *IPv6Defragmenter) DefragIPv6WithTimestamp(in *layers.IPv6, t time.Time) (*layers.IPv6, error) {
+	frag, err := parseFragment(in)
+	if err != nil {
+		return nil, err
+	}
+	// check if we need to defrag
+	if frag == nil {
+		debug.Printf("defrag: do nothing, do not need anything")
+		return in, nil
+	}
+	if frag.atomic() {
+		debug.Printf("defrag: atomic fragment, id=%d", frag.id)
+		return frag.build(in, frag.data)
+	}
+	// perfom security checks
+	if err := frag.securityChecks(); err != nil {
+		debug.Printf("defrag: alert security check")
+		return nil, err
+	}
+
+	// ok, got a fragment
+	debug.Printf("defrag: got a new fragment id=%d offset=%d more=%v\n",
+		frag.id, frag.offset, frag.more)
+
+	// have we already seen a flow between src/dst with that Id?
+	key := ipv6{ip6: in.NetworkFlow(), id: frag.id}
+	d.Lock()
+	fl, exist := d.ipFlows[key]
+	if !exist {
+		debug.Printf("defrag: unknown flow, creating a new one\n")
+		fl = new(fragmentList)
+		d.ipFlows[key] = fl
+	}
+	d.Unlock()
+	// insert, and if final build it
+	out, err := fl.insert(in, frag, t)
+
+	// at last, if we hit the maximum frag list len
+	// without any defrag success, we just drop everything and
+	// raise an error
+	if out == nil && err == nil && len(fl.frags) > IPv6MaximumFragmentListLen {
+		d.flush(key)
+		return nil, fmt.Errorf("defrag: Fragment List hits its maximum "+
+			"size(%d), without success. Flushing the list",
+			IPv6MaximumFragmentListLen)
+	}
+
+	// if we got a packet, it's a new one, and he is defragmented
+	if out != nil {
+		// when defrag is done for a flow between two ip
+		// clean the list
+		d.flush(key)
+		return out, nil
+	}
+	return nil, err
+}
+
+// DiscardOlderThan forgets all packets without any activity since
+// time t. It returns the number of FragmentList aka number of
+// fragment packets it has discarded.
+func (d *IPv6Defragmenter) DiscardOlderThan(t time.Time) int {
+	var nb int
+	d.Lock()
+	for k, v := range d.ipFlows {
+		if v.LastSeen.Before(t) {
+			nb = nb + 1
+			delete(d.ipFlows, k)
+		}
+	}
+	d.Unlock()
+	return nb
+}
+
+// flush the fragment list for a particular flow
+func (d *IPv6Defragmenter) flush(key ipv6) {
+	d.Lock()
+	delete(d.ipFlows, key)
+	d.Unlock()
+}
+
+// fragment is a fragment header along with the fragmentable data it
+// carries and the location of the header in the packet.
+type fragment struct {
+	nextHeader layers.IPProtocol
+	offset     int
+	more       bool
+	id         uint32
+	data       []byte
+
+	// unfrag is the unfragmentable part of the packet following the
+	// hop-by-hop header (if any) and preceding the fragment header.
+	unfrag []byte
+	// nhPos is the position in unfrag of the Next Header field pointing
+	// to the fragment header, -1 if it lives in the IPv6 or hop-by-hop
+	// header.
+	nhPos int
+}
+
+// parseFragment walks the extension headers of in up to its fragment
+// header. It returns nil if in has no fragment header.
+func parseFragment(in *layers.IPv6) (*fragment, error) {
+	nh := in.NextHeader
+	if in.HopByHop != nil {
+		nh = in.HopByHop.NextHeader
+	}
+	data := in.Payload
+	off, nhPos := 0, -1
+	for {
+		switch nh {
+		case layers.IPProtocolIPv6Fragment:
+			if len(data)-off < IPv6FragmentHeaderLength {
+				return nil, errors.New("defrag: truncated fragment header")
+			}
+			h := data[off : off+IPv6FragmentHeaderLength]
+			return &fragment{
+				nextHeader: layers.IPProtocol(h[0]),
+				offset:     int(binary.BigEndian.Uint16(h[2:4]) &^ 7),
+				more:       h[3]&1 != 0,
+				id:         binary.BigEndian.Uint32(h[4:8]),
+				data:       data[off+IPv6FragmentHeaderLength:],
+				unfrag:     data[:off],
+				nhPos:      nhPos,
+			}, nil
+		case layers.IPProtocolIPv6Routing, layers.IPProtocolIPv6Destination:
+			if len(data)-off < 2 {
+				return nil, errors.New("defrag: truncated extension header")
+			}
+			l := (int(data[off+1]) + 1) * 8
+			if len(data)-off < l {
+				return nil, errors.New("defrag: truncated extension header")
+			}
+			nhPos = off
+			nh = layers.IPProtocol(data[off])
+			off += l
+		default:
+			// reached the upper layer without finding a fragment header
+			return nil, nil
+		}
+	}
+}
+
+// atomic returns true if f is an atomic fragment (RFC 6946)
+func (f *fragment) atomic() bool {
+	return f.offset == 0 && !f.more
+}
+
+// securityChecks performs the needed security checks
+func (f *fragment) securityChecks() error {
+	// every fragment but the last one must be a multiple of 8 octets long
+	if f.more && len(f.data)%8 != 0 {
+		return fmt.Errorf("defrag: fragment length is not a multiple "+
+			"of 8 (handcrafted? %d)", len(f.data))
+	}
+	if f.more && len(f.data) == 0 {
+		return errors.New("defrag: empty non-final fragment (handcrafted?)")
+	}
+	// don't allow fragment that would oversize an IP packet
+	if f.offset+len(f.data) > IPv6MaximumSize {
+		return fmt.Errorf("defrag: fragment will overrun "+
+			"(handcrafted? %d > %d)", f.offset+len(f.data), IPv6MaximumSize)
+	}
+	return nil
+}
+
+// build returns the packet made of the headers of in, with the fragment
+// header removed, followed by payload.
+func (f *fragment) build(in *layers.IPv6, payload []byte) (*layers.IPv6, error) {
+	var hbh []byte
+	if in.HopByHop != nil {
+		hbh = in.HopByHop.Contents
+	}
+	l := len(hbh) + len(f.unfrag) + len(payload)
+	if l > IPv6MaximumSize {
+		return nil, fmt.Errorf("defrag: reassembled packet too big (%d > %d)", l, IPv6MaximumSize)
+	}
+
+	data := make([]byte, 40, 40+l)
+	data[0] = (in.Version << 4) | (in.TrafficClass >> 4)
+	data[1] = (in.TrafficClass << 4) | uint8(in.FlowLabel>>16)
+	binary.BigEndian.PutUint16(data[2:], uint16(in.FlowLabel))
+	binary.BigEndian.PutUint16(data[4:], uint16(l))
+	data[6] = byte(in.NextHeader)
+	data[7] = in.HopLimit
+	copy(data[8:24], in.SrcIP.To16())
+	copy(data[24:40], in.DstIP.To16())
+	data = append(data, hbh...)
+	data = append(data, f.unfrag...)
+	data = append(data, payload...)
+
+	// The header that pointed to the fragment header now points to
+	// whatever the fragment header pointed to.
+	switch {
+	case f.nhPos >= 0:
+		data[40+len(hbh)+f.nhPos] = byte(f.nextHeader)
+	case hbh != nil:
+		data[40] = byte(f.nextHeader)
+	default:
+		data[6] = byte(f.nextHeader)
+	}
+
+	out := &layers.IPv6{}
+	if err := out.DecodeFromBytes(data, gopacket.NilDecodeFeedback); err != nil {
+		return nil, fmt.Errorf("defrag: building - %v", err)
+	}
+	return out, nil
+}
+
+// fragmentList holds the fragments received so far for a datagram,
+// sorted by offset. It stores internal counters to track the
+// maximum total of byte, and the current length it has received.
+// It also stores a flag to know if he has seen the last packet.
+type fragmentList struct {
+	frags         []*fragment
+	first         *layers.IPv6
+	Highest       int
+	Current       int
+	FinalReceived bool
+	Overlapped    bool
+	LastSeen      time.Time
+}
+
+// insert insert an IPv6 fragment into the Fragment List. Overlaps are not
+// resolved: as required by RFC 5722 the whole datagram is dropped instead.
+func (f *fragmentList) insert(in *layers.IPv6, frag *fragment, t time.Time) (*layers.IPv6, error) {
+	f.LastSeen = t
+	if f.Overlapped {
+		debug.Printf("defrag: dropping fragment %d of an overlapping datagram\n", frag.offset)
+		return nil, errors.New("defrag: fragment of a datagram with overlapping fragments")
+	}
+
+	end := frag.offset + len(frag.data)
+	i := sort.Search(len(f.frags), func(i int) bool { return f.frags[i].offset >= frag.offset })
+	if i < len(f.frags) && f.frags[i].offset == frag.offset && len(f.frags[i].data) == len(frag.data) &&
+		f.frags[i].more == frag.more && string(f.frags[i].data) == string(frag.data) {
+		debug.Printf("defrag: ignoring frag %d as we already have it (duplicate)\n", frag.offset)
+		return nil, nil
+	}
+	overlap := i > 0 && f.frags[i-1].offset+len(f.frags[i-1].data) > frag.offset ||
+		i < len(f.frags) && f.frags[i].offset < end ||
+		f.FinalReceived && (end > f.Highest || !frag.more && end != f.Highest)
+	if overlap {
+		debug.Printf("defrag: overlapping frag %d, dropping the datagram\n", frag.offset)
+		f.Overlapped = true
+		f.frags = nil
+		f.first = nil
+		return nil, errors.New("defrag: overlapping fragments (RFC 5722), dropping the datagram")
+	}
+
+	debug.Printf("defrag: inserting frag %d at position %d\n", frag.offset, i)
+	f.frags = append(f.frags, nil)
+	copy(f.frags[i+1:], f.frags[i:])
+	f.frags[i] = frag
+	if frag.offset == 0 {
+		f.first = in
+	}
+
+	// After inserting the Fragment, we update the counters
+	if f.Highest < end {
+		f.Highest = end
+	}
+	f.Current += len(frag.data)
+
+	debug.Printf("defrag: insert ListLen: %d Highest:%d Current:%d\n",
+		len(f.frags), f.Highest, f.Current)
+
+	// Final Fragment ?
+	if !frag.more {
+		f.FinalReceived = true
+	}
+	// Ready to try defrag ?
+	if f.FinalReceived && f.first != nil && f.Highest == f.Current {
+		return f.build()
+	}
+	return nil, nil
+}
+
+// build builds the final datagram from the fragments, using the headers of
+// the first one.
+func (f *fragmentList) build() (*layers.IPv6, error) {
+	debug.Printf("defrag: building the datagram \n")
+	payload := make([]byte, 0, f.Highest)
+	for _, frag := range f.frags {
+		if frag.offset != len(payload) {
+			// Houston - we have an hole !
+			return nil, errors.New("defrag: building - hole found")
+		}
+		payload = append(payload, frag.data...)
+	}
+	return f.frags[0].build(f.first, payload)
+}
+
+// ipv6 is a struct to be used as a key.
+type ipv6 struct {
+	ip6 gopacket.Flow
+	id  uint32
+}
+
+// IPv6Defragmenter is a struct which embedded a map of
+// all fragment/packet.
+type IPv6Defragmenter struct {
+	sync.RWMutex
+	ipFlows map[ipv6]*fragmentList
+}
+
+// NewIPv6Defragmenter returns a new IPv6Defragmenter
+// with an initialized map.
+func NewIPv6Defragmenter() *IPv6Defragmenter {
+	return &IPv6Defragmenter{
+		ipFlows: make(map[ipv6]*fragmentList),
+	}
+}
@@ -0,0 +1,261 @@
+// Copyright 2026 The GoPacket Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package ip6defrag
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+var (
+	testSrcIP = net.ParseIP("2001:db8::1")
+	testDstIP = net.ParseIP("2001:db8::2")
+)
+
+// testDatagram is the fragmentable part used by the tests: an UDP header
+// followed by 3000 bytes of payload.
+func testDatagram() []byte {
+	data := make([]byte, 8+3000)
+	binary.BigEndian.PutUint16(data[0:], 5353)
+	binary.BigEndian.PutUint16(data[2:], 53)
+	binary.BigEndian.PutUint16(data[4:], uint16(len(data)))
+	for i := 8; i < len(data); i++ {
+		data[i] = byte(i)
+	}
+	return data
+}
+
+type testExtensions struct {
+	hopByHop    bool
+	destination bool
+}
+
+// testFragment builds an IPv6 packet holding data[offset:offset+length] in
+// a fragment header, and returns its decoded IPv6 layer.
+func testFragment(t *testing.T, ext testExtensions, id uint32, data []byte, offset, length int) *layers.IPv6 {
+	more := offset+length < len(data)
+	frag := data[offset : offset+length]
+
+	var chain []byte
+	first := layers.IPProtocolIPv6Fragment
+	if ext.destination {
+		// Destination options header with a PadN option
+		chain = append(chain, byte(layers.IPProtocolIPv6Fragment), 0, 1, 4, 0, 0, 0, 0)
+		first = layers.IPProtocolIPv6Destination
+	}
+	if ext.hopByHop {
+		chain = append([]byte{byte(first), 0, 1, 4, 0, 0, 0, 0}, chain...)
+		first = layers.IPProtocolIPv6HopByHop
+	}
+	fh := make([]byte, 8)
+	fh[0] = byte(layers.IPProtocolUDP)
+	binary.BigEndian.PutUint16(fh[2:], uint16(offset))
+	if more {
+		fh[3] |= 1
+	}
+	binary.BigEndian.PutUint32(fh[4:], id)
+	chain = append(chain, fh...)
+	chain = append(chain, frag...)
+
+	pkt := make([]byte, 40, 40+len(chain))
+	pkt[0] = 0x60
+	binary.BigEndian.PutUint16(pkt[4:], uint16(len(chain)))
+	pkt[6] = byte(first)
+	pkt[7] = 64
+	copy(pkt[8:], testSrcIP)
+	copy(pkt[24:], testDstIP)
+	pkt = append(pkt, chain...)
+
+	p := gopacket.NewPacket(pkt, layers.LayerTypeIPv6, gopacket.Default)
+	ip, ok := p.Layer(layers.LayerTypeIPv6).(*layers.IPv6)
+	if !ok {
+		t.Fatal("Failed to decode test fragment:", p.ErrorLayer())
+	}
+	return ip
+}
+
+func checkDatagram(t *testing.T, out *layers.IPv6, data []byte) {
+	if out == nil {
+		t.Fatal("defrag: expected a reassembled packet")
+	}
+	p := gopacket.NewPacket(out.Payload, out.NextLayerType(), gopacket.Default)
+	udp, ok := p.Layer(layers.LayerTypeUDP).(*layers.UDP)
+	if !ok {
+		t.Fatalf("defrag: reassembled packet has no UDP layer: %v", p)
+	}
+	if udp.SrcPort != 5353 || !bytes.Equal(udp.Payload, data[8:]) {
+		t.Errorf("defrag: payload is not correctly defragmented")
+	}
+}
+
+func TestNotFrag(t *testing.T) {
+	ip := &layers.IPv6{
+		Version:    6,
+		NextHeader: layers.IPProtocolUDP,
+		SrcIP:      testSrcIP,
+		DstIP:      testDstIP,
+	}
+	defrag := NewIPv6Defragmenter()
+	out, err := defrag.DefragIPv6(ip)
+	if out != ip || err != nil {
+		t.Errorf("defrag: this packet do not need to be defrag ['%v']", err)
+	}
+}
+
+func TestDefragUnordered(t *testing.T) {
+	data := testDatagram()
+	defrag := NewIPv6Defragmenter()
+	ext := testExtensions{}
+
+	for _, f := range [][2]int{{1232, 1232}, {2464, len(data) - 2464}, {1232, 1232}} {
+		out, err := defrag.DefragIPv6(testFragment(t, ext, 42, data, f[0], f[1]))
+		if out != nil || err != nil {
+			t.Fatalf("defrag: unexpected result %v, %v", out, err)
+		}
+	}
+	out, err := defrag.DefragIPv6(testFragment(t, ext, 42, data, 0, 1232))
+	if err != nil {
+		t.Fatal("defrag:", err)
+	}
+	if out.NextHeader != layers.IPProtocolUDP || out.HopByHop != nil {
+		t.Errorf("defrag: wrong header chain, next header %v", out.NextHeader)
+	}
+	if int(out.Length) != len(data) {
+		t.Errorf("defrag: wrong payload length %d, want %d", out.Length, len(data))
+	}
+	checkDatagram(t, out, data)
+
+	if discarded := defrag.DiscardOlderThan(time.Now()); discarded != 0 {
+		t.Errorf("defrag: discarded more fragments then expected: %d", discarded)
+	}
+}
+
+func TestDefragHeaderChain(t *testing.T) {
+	data := testDatagram()
+	defrag := NewIPv6Defragmenter()
+	ext := testExtensions{hopByHop: true, destination: true}
+
+	defrag.DefragIPv6(testFragment(t, ext, 7, data, 0, 1600))
+	out, err := defrag.DefragIPv6(testFragment(t, ext, 7, data, 1600, len(data)-1600))
+	if err != nil {
+		t.Fatal("defrag:", err)
+	}
+	if out.NextHeader != layers.IPProtocolIPv6HopByHop || out.HopByHop == nil {
+		t.Fatalf("defrag: hop-by-hop header lost")
+	}
+	if out.HopByHop.NextHeader != layers.IPProtocolIPv6Destination {
+		t.Errorf("defrag: hop-by-hop header points to %v", out.HopByHop.NextHeader)
+	}
+	if int(out.Length) != 8+8+len(data) {
+		t.Errorf("defrag: wrong payload length %d", out.Length)
+	}
+
+	p := gopacket.NewPacket(out.Payload, out.NextLayerType(), gopacket.Default)
+	dst, ok := p.Layer(layers.LayerTypeIPv6Destination).(*layers.IPv6Destination)
+	if !ok {
+		t.Fatal("defrag: destination options header lost")
+	}
+	if dst.NextHeader != layers.IPProtocolUDP {
+		t.Errorf("defrag: destination options header points to %v", dst.NextHeader)
+	}
+	if p.Layer(layers.LayerTypeIPv6Fragment) != nil {
+		t.Error("defrag: fragment header still present")
+	}
+	udp, ok := p.Layer(layers.LayerTypeUDP).(*layers.UDP)
+	if !ok || !bytes.Equal(udp.Payload, data[8:]) {
+		t.Errorf("defrag: payload is not correctly defragmented")
+	}
+}
+
+func TestDefragAtomic(t *testing.T) {
+	data := testDatagram()
+	defrag := NewIPv6Defragmenter()
+	ext := testExtensions{}
+
+	// a pending fragment with the same id does not interfere
+	defrag.DefragIPv6(testFragment(t, ext, 1, data, 1600, len(data)-1600))
+	out, err := defrag.DefragIPv6(testFragment(t, ext, 1, data, 0, len(data)))
+	if err != nil {
+		t.Fatal("defrag:", err)
+	}
+	checkDatagram(t, out, data)
+	if n := len(defrag.ipFlows); n != 1 {
+		t.Errorf("defrag: atomic fragment changed the flow table (%d flows)", n)
+	}
+}
+
+func TestDefragOverlap(t *testing.T) {
+	data := testDatagram()
+	defrag := NewIPv6Defragmenter()
+	ext := testExtensions{}
+
+	if _, err := defrag.DefragIPv6(testFragment(t, ext, 9, data, 0, 1600)); err != nil {
+		t.Fatal("defrag:", err)
+	}
+	// overlaps the end of the first fragment
+	if _, err := defrag.DefragIPv6(testFragment(t, ext, 9, data, 1592, 808)); err == nil {
+		t.Error("defrag: overlapping fragment accepted")
+	}
+	// the rest of the datagram must be discarded too
+	for _, f := range [][2]int{{1600, 800}, {2400, len(data) - 2400}, {0, 1600}} {
+		out, err := defrag.DefragIPv6(testFragment(t, ext, 9, data, f[0], f[1]))
+		if out != nil || err == nil {
+			t.Errorf("defrag: fragment %d of an overlapping datagram accepted", f[0])
+		}
+	}
+	if discarded := defrag.DiscardOlderThan(time.Now().Add(time.Second)); discarded != 1 {
+		t.Errorf("defrag: expected 1 discarded flow, got %d", discarded)
+	}
+
+	// a duplicate with different data is an overlap as well
+	defrag.DefragIPv6(testFragment(t, ext, 10, data, 0, 1600))
+	other := append([]byte(nil), data...)
+	other[100] ^= 0xff
+	if _, err := defrag.DefragIPv6(testFragment(t, ext, 10, other, 0, 1600)); err == nil {
+		t.Error("defrag: conflicting duplicate accepted")
+	}
+}
+
+func TestDefragSecurityChecks(t *testing.T) {
+	data := testDatagram()
+	defrag := NewIPv6Defragmenter()
+	ext := testExtensions{}
+
+	if _, err := defrag.DefragIPv6(testFragment(t, ext, 11, data, 0, 1001)); err == nil {
+		t.Error("defrag: accepted a non-final fragment not multiple of 8")
+	}
+
+	big := make([]byte, 65528+1000)
+	if _, err := defrag.DefragIPv6(testFragment(t, ext, 12, big, 65528, 1000)); err == nil {
+		t.Error("defrag: accepted a fragment overrunning the maximum size")
+	}
+}
+
+func TestDefragDiscard(t *testing.T) {
+	data := testDatagram()
+	defrag := NewIPv6Defragmenter()
+	ext := testExtensions{}
+
+	start := time.Unix(1000, 0)
+	defrag.DefragIPv6WithTimestamp(testFragment(t, ext, 13, data, 0, 1600), start)
+	if n := defrag.DiscardOlderThan(start); n != 0 {
+		t.Errorf("defrag: discarded %d flows, want 0", n)
+	}
+	if n := defrag.DiscardOlderThan(start.Add(time.Second)); n != 1 {
+		t.Errorf("defrag: discarded %d flows, want 1", n)
+	}
+	out, err := defrag.DefragIPv6WithTimestamp(testFragment(t, ext, 13, data, 1600, len(data)-1600), start.Add(time.Second))
+	if out != nil || err != nil {
+		t.Errorf("defrag: reassembled a datagram from discarded fragments")
+	}
+}
@@ -0,0 +1,1388 @@
+// Copyright 2026 The GoPacket Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+
+	"github.com/google/gopacket"
+)
+
+// BGPHeaderLength is the length of the header of every BGP message: the
+// marker, the length and the type.
+const BGPHeaderLength = 19
+
+// BGPMaxMessageLength is the maximum length of a BGP message, unless both
+// speakers advertised the extended message capability (RFC 8654), which
+// raises it to 65535 bytes.
+const BGPMaxMessageLength = 4096
+
+// BGPMessageType is the type of a BGP message.
+type BGPMessageType uint8
+
+// BGPMessageType known values.
+const (
+	BGPMessageOpen         BGPMessageType = 1
+	BGPMessageUpdate       BGPMessageType = 2
+	BGPMessageNotification BGPMessageType = 3
+	BGPMessageKeepalive    BGPMessageType = 4
+	BGPMessageRouteRefresh BGPMessageType = 5 // RFC 2918
+)
+
+func (t BGPMessageType) String() string {
+	switch t {
+	case BGPMessageOpen:
+		return "OPEN"
+	case BGPMessageUpdate:
+		return "UPDATE"
+	case BGPMessageNotification:
+		return "NOTIFICATION"
+	case BGPMessageKeepalive:
+		return "KEEPALIVE"
+	case BGPMessageRouteRefresh:
+		return "ROUTE-REFRESH"
+	}
+	return fmt.Sprintf("Unknown(%d)", uint8(t))
+}
+
+// BGPAFI is an address family identifier.
+type BGPAFI uint16
+
+// BGPAFI known values.
+const (
+	BGPAFIIPv4  BGPAFI = 1
+	BGPAFIIPv6  BGPAFI = 2
+	BGPAFIL2VPN BGPAFI = 25
+)
+
+func (a BGPAFI) String() string {
+	switch a {
+	case BGPAFIIPv4:
+		return "IPv4"
+	case BGPAFIIPv6:
+		return "IPv6"
+	case BGPAFIL2VPN:
+		return "L2VPN"
+	}
+	return fmt.Sprintf("Unknown(%d)", uint16(a))
+}
+
+// BGPSAFI is a subsequent address family identifier.
+type BGPSAFI uint8
+
+// BGPSAFI known values.
+const (
+	BGPSAFIUnicast   BGPSAFI = 1
+	BGPSAFIMulticast BGPSAFI = 2
+	BGPSAFIMPLSLabel BGPSAFI = 4 // RFC 8277
+	BGPSAFIEVPN      BGPSAFI = 70
+	BGPSAFIMPLSVPN   BGPSAFI = 128 // RFC 4364 and RFC 4659
+	BGPSAFIFlowSpec  BGPSAFI = 133
+)
+
+func (s BGPSAFI) String() string {
+	switch s {
+	case BGPSAFIUnicast:
+		return "Unicast"
+	case BGPSAFIMulticast:
+		return "Multicast"
+	case BGPSAFIMPLSLabel:
+		return "MPLSLabel"
+	case BGPSAFIEVPN:
+		return "EVPN"
+	case BGPSAFIMPLSVPN:
+		return "MPLSVPN"
+	case BGPSAFIFlowSpec:
+		return "FlowSpec"
+	}
+	return fmt.Sprintf("Unknown(%d)", uint8(s))
+}
+
+// BGPCapabilityCode identifies a capability advertised in an OPEN message.
+type BGPCapabilityCode uint8
+
+// BGPCapabilityCode known values.
+const (
+	BGPCapabilityMultiProtocol        BGPCapabilityCode = 1
+	BGPCapabilityRouteRefresh         BGPCapabilityCode = 2
+	BGPCapabilityExtendedNextHop      BGPCapabilityCode = 5
+	BGPCapabilityExtendedMessage      BGPCapabilityCode = 6
+	BGPCapabilityGracefulRestart      BGPCapabilityCode = 64
+	BGPCapabilityFourOctetAS          BGPCapabilityCode = 65
+	BGPCapabilityAddPath              BGPCapabilityCode = 69
+	BGPCapabilityEnhancedRouteRefresh BGPCapabilityCode = 70
+	BGPCapabilityFQDN                 BGPCapabilityCode = 73
+)
+
+func (c BGPCapabilityCode) String() string {
+	switch c {
+	case BGPCapabilityMultiProtocol:
+		return "MultiProtocol"
+	case BGPCapabilityRouteRefresh:
+		return "RouteRefresh"
+	case BGPCapabilityExtendedNextHop:
+		return "ExtendedNextHop"
+	case BGPCapabilityExtendedMessage:
+		return "ExtendedMessage"
+	case BGPCapabilityGracefulRestart:
+		return "GracefulRestart"
+	case BGPCapabilityFourOctetAS:
+		return "FourOctetAS"
+	case BGPCapabilityAddPath:
+		return "AddPath"
+	case BGPCapabilityEnhancedRouteRefresh:
+		return "EnhancedRouteRefresh"
+	case BGPCapabilityFQDN:
+		return "FQDN"
+	}
+	return fmt.Sprintf("Unknown(%d)", uint8(c))
+}
+
+// BGPCapability is a capability advertised in an OPEN message, as specified
+// in RFC 5492.
+type BGPCapability struct {
+	Code  BGPCapabilityCode
+	Value []byte
+	// Parameter is the index of the optional parameter holding the
+	// capability among those of the message.  Capabilities with the same
+	// index are packed in one parameter.
+	Parameter int
+}
+
+// MultiProtocol returns the address family of a multiprotocol capability.
+func (c *BGPCapability) MultiProtocol() (BGPAFI, BGPSAFI, bool) {
+	if c.Code != BGPCapabilityMultiProtocol || len(c.Value) != 4 {
+		return 0, 0, false
+	}
+	return BGPAFI(binary.BigEndian.Uint16(c.Value)), BGPSAFI(c.Value[3]), true
+}
+
+// FourOctetAS returns the AS number of a 4-octet AS number capability.
+func (c *BGPCapability) FourOctetAS() (uint32, bool) {
+	if c.Code != BGPCapabilityFourOctetAS || len(c.Value) != 4 {
+		return 0, false
+	}
+	return binary.BigEndian.Uint32(c.Value), true
+}
+
+// BGPOptionalParameter is an optional parameter of an OPEN message, other
+// than capabilities.
+type BGPOptionalParameter struct {
+	Type  uint8
+	Value []byte
+	// Index is the position of the parameter among those of the message,
+	// as for BGPCapability.Parameter.
+	Index int
+}
+
+// bgpParameterCapabilities is the type of the optional parameters holding
+// capabilities.
+const bgpParameterCapabilities = 2
+
+// BGPOpen is an OPEN message, as specified in RFC 4271 section 4.2.
+//
+// The capabilities of all the optional parameters carrying some are
+// gathered in Capabilities, with the index of their parameter.
+// Serialization writes the parameters in the order of their indexes,
+// capabilities first among equal indexes, so that decoded messages keep
+// their layout, whether a speaker packs its capabilities in one parameter
+// or gives each its own.  Optional parameters too long for the original
+// encoding use the extended one of RFC 9072.
+type BGPOpen struct {
+	Version uint8
+	// MyAS is AS_TRANS (23456) for speakers whose AS number needs four
+	// octets: see AS.
+	MyAS         uint16
+	HoldTime     uint16
+	Identifier   net.IP
+	Capabilities []BGPCapability
+	Parameters   []BGPOptionalParameter
+}
+
+// Capability returns the first capability of the given code, or nil.
+func (o *BGPOpen) Capability(code BGPCapabilityCode) *BGPCapability {
+	for i := range o.Capabilities {
+		if o.Capabilities[i].Code == code {
+			return &o.Capabilities[i]
+		}
+	}
+	return nil
+}
+
+// AS returns the AS number of the speaker, from its 4-octet AS number
+// capability if it advertised one.
+func (o *BGPOpen) AS() uint32 {
+	if c := o.Capability(BGPCapabilityFourOctetAS); c != nil {
+		if as, ok := c.FourOctetAS(); ok {
+			return as
+		}
+	}
+	return uint32(o.MyAS)
+}
+
+// BGPErrorCode is the error code of a NOTIFICATION message.
+type BGPErrorCode uint8
+
+// BGPErrorCode known values.
+const (
+	BGPErrorMessageHeader    BGPErrorCode = 1
+	BGPErrorOpenMessage      BGPErrorCode = 2
+	BGPErrorUpdateMessage    BGPErrorCode = 3
+	BGPErrorHoldTimerExpired BGPErrorCode = 4
+	BGPErrorFSM              BGPErrorCode = 5
+	BGPErrorCease            BGPErrorCode = 6
+	BGPErrorRouteRefresh     BGPErrorCode = 7 // RFC 7313
+)
+
+func (c BGPErrorCode) String() string {
+	switch c {
+	case BGPErrorMessageHeader:
+		return "Message Header Error"
+	case BGPErrorOpenMessage:
+		return "OPEN Message Error"
+	case BGPErrorUpdateMessage:
+		return "UPDATE Message Error"
+	case BGPErrorHoldTimerExpired:
+		return "Hold Timer Expired"
+	case BGPErrorFSM:
+		return "Finite State Machine Error"
+	case BGPErrorCease:
+		return "Cease"
+	case BGPErrorRouteRefresh:
+		return "ROUTE-REFRESH Message Error"
+	}
+	return fmt.Sprintf("Unknown(%d)", uint8(c))
+}
+
+// BGPNotification is a NOTIFICATION message.
+type BGPNotification struct {
+	Code    BGPErrorCode
+	Subcode uint8
+	Data    []byte
+}
+
+// BGPRouteRefresh is a ROUTE-REFRESH message. Subtype is 1 and 2 for the
+// beginning and the end of an enhanced route refresh (RFC 7313).
+type BGPRouteRefresh struct {
+	AFI     BGPAFI
+	Subtype uint8
+	SAFI    BGPSAFI
+}
+
+// BGPRouteDistinguisher is the route distinguisher of a VPN route, as
+// specified in RFC 4364 section 4.2.
+type BGPRouteDistinguisher [8]byte
+
+func (rd BGPRouteDistinguisher) String() string {
+	switch binary.BigEndian.Uint16(rd[:2]) {
+	case 0:
+		return fmt.Sprintf("%d:%d", binary.BigEndian.Uint16(rd[2:4]), binary.BigEndian.Uint32(rd[4:]))
+	case 1:
+		return fmt.Sprintf("%v:%d", net.IP(rd[2:6]), binary.BigEndian.Uint16(rd[6:]))
+	case 2:
+		return fmt.Sprintf("%d:%d", binary.BigEndian.Uint32(rd[2:6]), binary.BigEndian.Uint16(rd[6:]))
+	}
+	return fmt.Sprintf("%x", rd[:])
+}
+
+// BGPPrefix is a route of the NLRI or the withdrawn routes of an UPDATE
+// message.
+type BGPPrefix struct {
+	// Labels are the MPLS labels of labeled and VPN routes, without their
+	// traffic class and bottom of stack bits. Withdrawn routes usually
+	// carry the compatibility value 0x80000.
+	Labels []uint32
+	// RouteDistinguisher is set for VPN routes.
+	RouteDistinguisher BGPRouteDistinguisher
+	IP                 net.IP
+	// Length is the length of the prefix in bits.
+	Length uint8
+}
+
+func (p BGPPrefix) String() string {
+	s := fmt.Sprintf("%v/%d", p.IP, p.Length)
+	if p.RouteDistinguisher != (BGPRouteDistinguisher{}) {
+		s = p.RouteDistinguisher.String() + ":" + s
+	}
+	return s
+}
+
+// BGPAttributeType is the type code of a path attribute.
+type BGPAttributeType uint8
+
+// BGPAttributeType known values.
+const (
+	BGPAttributeOrigin              BGPAttributeType = 1
+	BGPAttributeASPath              BGPAttributeType = 2
+	BGPAttributeNextHop             BGPAttributeType = 3
+	BGPAttributeMED                 BGPAttributeType = 4
+	BGPAttributeLocalPref           BGPAttributeType = 5
+	BGPAttributeAtomicAggregate     BGPAttributeType = 6
+	BGPAttributeAggregator          BGPAttributeType = 7
+	BGPAttributeCommunities         BGPAttributeType = 8  // RFC 1997
+	BGPAttributeOriginatorID        BGPAttributeType = 9  // RFC 4456
+	BGPAttributeClusterList         BGPAttributeType = 10 // RFC 4456
+	BGPAttributeMPReachNLRI         BGPAttributeType = 14 // RFC 4760
+	BGPAttributeMPUnreachNLRI       BGPAttributeType = 15 // RFC 4760
+	BGPAttributeExtendedCommunities BGPAttributeType = 16 // RFC 4360
+	BGPAttributeAS4Path             BGPAttributeType = 17 // RFC 6793
+	BGPAttributeAS4Aggregator       BGPAttributeType = 18 // RFC 6793
+	BGPAttributeLargeCommunities    BGPAttributeType = 32 // RFC 8092
+)
+
+func (t BGPAttributeType) String() string {
+	switch t {
+	case BGPAttributeOrigin:
+		return "ORIGIN"
+	case BGPAttributeASPath:
+		return "AS_PATH"
+	case BGPAttributeNextHop:
+		return "NEXT_HOP"
+	case BGPAttributeMED:
+		return "MULTI_EXIT_DISC"
+	case BGPAttributeLocalPref:
+		return "LOCAL_PREF"
+	case BGPAttributeAtomicAggregate:
+		return "ATOMIC_AGGREGATE"
+	case BGPAttributeAggregator:
+		return "AGGREGATOR"
+	case BGPAttributeCommunities:
+		return "COMMUNITIES"
+	case BGPAttributeOriginatorID:
+		return "ORIGINATOR_ID"
+	case BGPAttributeClusterList:
+		return "CLUSTER_LIST"
+	case BGPAttributeMPReachNLRI:
+		return "MP_REACH_NLRI"
+	case BGPAttributeMPUnreachNLRI:
+		return "MP_UNREACH_NLRI"
+	case BGPAttributeExtendedCommunities:
+		return "EXTENDED_COMMUNITIES"
+	case BGPAttributeAS4Path:
+		return "AS4_PATH"
+	case BGPAttributeAS4Aggregator:
+		return "AS4_AGGREGATOR"
+	case BGPAttributeLargeCommunities:
+		return "LARGE_COMMUNITY"
+	}
+	return fmt.Sprintf("Unknown(%d)", uint8(t))
+}
+
+// BGPAttributeFlags are the flags of a path attribute.
+type BGPAttributeFlags uint8
+
+// BGPAttributeFlags known values.
+const (
+	BGPAttributeFlagOptional       BGPAttributeFlags = 0x80
+	BGPAttributeFlagTransitive     BGPAttributeFlags = 0x40
+	BGPAttributeFlagPartial        BGPAttributeFlags = 0x20
+	BGPAttributeFlagExtendedLength BGPAttributeFlags = 0x10
+)
+
+// BGPOrigin is the value of an ORIGIN attribute.
+type BGPOrigin uint8
+
+// BGPOrigin known values.
+const (
+	BGPOriginIGP        BGPOrigin = 0
+	BGPOriginEGP        BGPOrigin = 1
+	BGPOriginIncomplete BGPOrigin = 2
+)
+
+func (o BGPOrigin) String() string {
+	switch o {
+	case BGPOriginIGP:
+		return "IGP"
+	case BGPOriginEGP:
+		return "EGP"
+	case BGPOriginIncomplete:
+		return "INCOMPLETE"
+	}
+	return fmt.Sprintf("Unknown(%d)", uint8(o))
+}
+
+// BGPASPathSegmentType is the type of an AS_PATH segment.
+type BGPASPathSegmentType uint8
+
+// BGPASPathSegmentType known values.
+const (
+	BGPASSet            BGPASPathSegmentType = 1
+	BGPASSequence       BGPASPathSegmentType = 2
+	BGPASConfedSequence BGPASPathSegmentType = 3 // RFC 5065
+	BGPASConfedSet      BGPASPathSegmentType = 4 // RFC 5065
+)
+
+// BGPASPathSegment is a segment of an AS_PATH or AS4_PATH attribute.
+type BGPASPathSegment struct {
+	Type BGPASPathSegmentType
+	ASNs []uint32
+}
+
+// BGPAggregator is the value of an AGGREGATOR or AS4_AGGREGATOR attribute.
+type BGPAggregator struct {
+	AS uint32
+	IP net.IP
+}
+
+// BGPCommunity is a community, as specified in RFC 1997.
+type BGPCommunity uint32
+
+// BGPCommunity well-known values.
+const (
+	BGPCommunityNoExport          BGPCommunity = 0xffffff01
+	BGPCommunityNoAdvertise       BGPCommunity = 0xffffff02
+	BGPCommunityNoExportSubconfed BGPCommunity = 0xffffff03
+)
+
+func (c BGPCommunity) String() string {
+	return fmt.Sprintf("%d:%d", uint32(c)>>16, uint16(c))
+}
+
+// BGPLargeCommunity is a large community, as specified in RFC 8092.
+type BGPLargeCommunity struct {
+	GlobalAdministrator uint32
+	LocalData1          uint32
+	LocalData2          uint32
+}
+
+func (c BGPLargeCommunity) String() string {
+	return fmt.Sprintf("%d:%d:%d", c.GlobalAdministrator, c.LocalData1, c.LocalData2)
+}
+
+// BGPMPReachNLRI is the value of an MP_REACH_NLRI attribute. The routes of
+// the IPv4 and IPv6 unicast, multicast, labeled and VPN address families
+// are decoded in NLRI, those of other families are left in RawNLRI.
+type BGPMPReachNLRI struct {
+	AFI  BGPAFI
+	SAFI BGPSAFI
+	// NextHops holds the next hop, followed by the link-local address of
+	// IPv6 next hops giving one. The route distinguishers of VPN next
+	// hops, always zero, are left out.
+	NextHops []net.IP
+	NLRI     []BGPPrefix
+	RawNLRI  []byte
+}
+
+// BGPMPUnreachNLRI is the value of an MP_UNREACH_NLRI attribute, whose
+// routes are decoded like those of BGPMPReachNLRI.
+type BGPMPUnreachNLRI struct {
+	AFI          BGPAFI
+	SAFI         BGPSAFI
+	Withdrawn    []BGPPrefix
+	RawWithdrawn []byte
+}
+
+// BGPPathAttribute is a path attribute of an UPDATE message.
+//
+// Value is the attribute value as sent. The fields following it are filled
+// in according to the type, the others are left to their zero value, and
+// are those serialized for the known types. Flags left to zero are
+// serialized as the usual ones of the type; the extended length flag is
+// added as needed.
+type BGPPathAttribute struct {
+	Flags BGPAttributeFlags
+	Type  BGPAttributeType
+	Value []byte
+
+	Origin BGPOrigin
+	// ASPath is set for AS_PATH and AS4_PATH attributes.
+	ASPath  []BGPASPathSegment
+	NextHop net.IP
+	// MED is the MULTI_EXIT_DISC attribute value.
+	MED       uint32
+	LocalPref uint32
+	// Aggregator is set for AGGREGATOR and AS4_AGGREGATOR attributes.
+	Aggregator          BGPAggregator
+	Communities         []BGPCommunity
+	OriginatorID        net.IP
+	ClusterList         []net.IP
+	MPReachNLRI         *BGPMPReachNLRI
+	MPUnreachNLRI       *BGPMPUnreachNLRI
+	ExtendedCommunities []uint64
+	LargeCommunities    []BGPLargeCommunity
+}
+
+// BGPUpdate is an UPDATE message, as specified in RFC 4271 section 4.3.
+type BGPUpdate struct {
+	// TwoOctetAS is set when the AS numbers of the AS_PATH and AGGREGATOR
+	// attributes are encoded on two octets, between speakers which did not
+	// both advertise the 4-octet AS number capability. AS numbers which do
+	// not fit are serialized as AS_TRANS.
+	TwoOctetAS      bool
+	WithdrawnRoutes []BGPPrefix
+	PathAttributes  []BGPPathAttribute
+	NLRI            []BGPPrefix
+}
+
+// Attribute returns the first path attribute of the given type, or nil.
+func (u *BGPUpdate) Attribute(t BGPAttributeType) *BGPPathAttribute {
+	for i := range u.PathAttributes {
+		if u.PathAttributes[i].Type == t {
+			return &u.PathAttributes[i]
+		}
+	}
+	return nil
+}
+
+// EndOfRIB returns the address family whose initial routing update an
+// End-of-RIB marker (RFC 4724) ends.
+func (u *BGPUpdate) EndOfRIB() (BGPAFI, BGPSAFI, bool) {
+	if len(u.WithdrawnRoutes) != 0 || len(u.NLRI) != 0 {
+		return 0, 0, false
+	}
+	switch len(u.PathAttributes) {
+	case 0:
+		return BGPAFIIPv4, BGPSAFIUnicast, true
+	case 1:
+		if r := u.PathAttributes[0].MPUnreachNLRI; r != nil && len(r.Withdrawn) == 0 && len(r.RawWithdrawn) == 0 {
+			return r.AFI, r.SAFI, true
+		}
+	}
+	return 0, 0, false
+}
+
+// BGPMessage is a BGP message. The field matching Type is set, except for
+// KEEPALIVE messages, which have no body.
+type BGPMessage struct {
+	Length       uint16
+	Type         BGPMessageType
+	Open         *BGPOpen
+	Update       *BGPUpdate
+	Notification *BGPNotification
+	RouteRefresh *BGPRouteRefresh
+}
+
+// BGPASEncoding is the encoding of the AS numbers of the AS_PATH and
+// AGGREGATOR attributes, which depends on the capabilities advertised by the
+// speakers of a session.
+type BGPASEncoding uint8
+
+// BGPASEncoding values.
+const (
+	// BGPASGuess guesses the encoding from each UPDATE message.
+	BGPASGuess BGPASEncoding = iota
+	BGPASTwoOctet
+	BGPASFourOctet
+)
+
+// BGP holds the BGP messages of a TCP segment or of a chunk of reassembled
+// data, as specified in RFC 4271.
+//
+// Only the messages complete in the decoded data are decoded; a message
+// whose end is missing marks the layer as truncated. The
+// reassembly/bgpstream package follows messages over whole sessions.
+type BGP struct {
+	BaseLayer
+	Messages []BGPMessage
+}
+
+// LayerType returns gopacket.LayerTypeBGP.
+func (b *BGP) LayerType() gopacket.LayerType { return LayerTypeBGP }
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode
+func (b *BGP) CanDecode() gopacket.LayerClass { return LayerTypeBGP }
+
+// NextLayerType returns the layer type contained by this DecodingLayer
+func (b *BGP) NextLayerType() gopacket.LayerType { return gopacket.LayerTypeZero }
+
+// Payload returns nil.
+func (b *BGP) Payload() []byte { return nil }
+
+func decodeBGP(data []byte, p gopacket.PacketBuilder) error {
+	b := &BGP{}
+	err := b.DecodeFromBytes(data, p)
+	if err != nil {
+		return err
+	}
+	p.AddLayer(b)
+	p.SetApplicationLayer(b)
+	return nil
+}
+
+// DecodeFromBytes decodes the slice into the BGP struct, guessing the
+// encoding of AS numbers.
+func (b *BGP) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	return b.DecodeWithASEncoding(data, BGPASGuess, df)
+}
+
+// DecodeWithASEncoding decodes the slice into the BGP struct like
+// DecodeFromBytes, with the encoding of AS numbers known from the OPEN
+// messages of the session.
+func (b *BGP) DecodeWithASEncoding(data []byte, enc BGPASEncoding, df gopacket.DecodeFeedback) error {
+	b.Messages = b.Messages[:0]
+
+	rest := data
+	for len(rest) > 0 {
+		if err := checkBGPMarker(rest); err != nil {
+			return err
+		}
+		if len(rest) < BGPHeaderLength {
+			break
+		}
+		length := int(binary.BigEndian.Uint16(rest[16:18]))
+		if length < BGPHeaderLength {
+			return fmt.Errorf("BGP message length %d too short", length)
+		}
+		if len(rest) < length {
+			break
+		}
+		var m BGPMessage
+		if err := m.decodeFromBytes(rest[:length], enc); err != nil {
+			return err
+		}
+		b.Messages = append(b.Messages, m)
+		rest = rest[length:]
+	}
+	if len(rest) > 0 {
+		df.SetTruncated()
+		if len(b.Messages) == 0 {
+			return errors.New("BGP message truncated")
+		}
+	}
+	b.BaseLayer = BaseLayer{Contents: data[:len(data)-len(rest)]}
+	return nil
+}
+
+// checkBGPMarker checks the part of the marker of a message held in data.
+func checkBGPMarker(data []byte) error {
+	for i := 0; i < 16 && i < len(data); i++ {
+		if data[i] != 0xff {
+			return errors.New("invalid BGP message marker")
+		}
+	}
+	return nil
+}
+
+func (m *BGPMessage) decodeFromBytes(data []byte, enc BGPASEncoding) error {
+	m.Length = uint16(len(data))
+	m.Type = BGPMessageType(data[18])
+	body := data[BGPHeaderLength:]
+	switch m.Type {
+	case BGPMessageOpen:
+		m.Open = &BGPOpen{}
+		return m.Open.decodeFromBytes(body)
+	case BGPMessageUpdate:
+		m.Update = &BGPUpdate{}
+		return m.Update.decodeFromBytes(body, enc)
+	case BGPMessageNotification:
+		if len(body) < 2 {
+			return errors.New("BGP NOTIFICATION message too short")
+		}
+		m.Notification = &BGPNotification{Code: BGPErrorCode(body[0]), Subcode: body[1], Data: body[2:]}
+	case BGPMessageKeepalive:
+		if len(body) != 0 {
+			return errors.New("BGP KEEPALIVE message with a body")
+		}
+	case BGPMessageRouteRefresh:
+		if len(body) < 4 {
+			return errors.New("BGP ROUTE-REFRESH message too short")
+		}
+		m.RouteRefresh = &BGPRouteRefresh{
+			AFI:     BGPAFI(binary.BigEndian.Uint16(body)),
+			Subtype: body[2],
+			SAFI:    BGPSAFI(body[3]),
+		}
+	default:
+		return fmt.Errorf("unknown BGP message type %d", m.Type)
+	}
+	return nil
+}
+
+func (o *BGPOpen) decodeFromBytes(data []byte) error {
+	if len(data) < 10 {
+		return errors.New("BGP OPEN message too short")
+	}
+	o.Version = data[0]
+	o.MyAS = binary.BigEndian.Uint16(data[1:3])
+	o.HoldTime = binary.BigEndian.Uint16(data[3:5])
+	o.Identifier = net.IP(data[5:9])
+	length, params := int(data[9]), data[10:]
+	// RFC 9072 extended optional parameters length
+	hdr := 2
+	if length == 255 && len(params) >= 3 && params[0] == 255 {
+		hdr = 3
+		length = int(binary.BigEndian.Uint16(params[1:3]))
+		params = params[3:]
+	}
+	if len(params) != length {
+		return errors.New("BGP OPEN optional parameters length mismatch")
+	}
+	for index := 0; len(params) > 0; index++ {
+		if len(params) < hdr {
+			return errors.New("BGP OPEN optional parameter truncated")
+		}
+		typ, l := params[0], int(params[1])
+		if hdr == 3 {
+			l = int(binary.BigEndian.Uint16(params[1:3]))
+		}
+		if len(params) < hdr+l {
+			return errors.New("BGP OPEN optional parameter truncated")
+		}
+		value := params[hdr : hdr+l]
+		params = params[hdr+l:]
+		if typ != bgpParameterCapabilities {
+			o.Parameters = append(o.Parameters, BGPOptionalParameter{Type: typ, Value: value, Index: index})
+			continue
+		}
+		for len(value) > 0 {
+			if len(value) < 2 || len(value) < 2+int(value[1]) {
+				return errors.New("BGP OPEN capability truncated")
+			}
+			o.Capabilities = append(o.Capabilities, BGPCapability{
+				Code:      BGPCapabilityCode(value[0]),
+				Value:     value[2 : 2+int(value[1])],
+				Parameter: index,
+			})
+			value = value[2+int(value[1]):]
+		}
+	}
+	return nil
+}
+
+func (u *BGPUpdate) decodeFromBytes(data []byte, enc BGPASEncoding) error {
+	if len(data) < 2 {
+		return errors.New("BGP UPDATE message too short")
+	}
+	wl := int(binary.BigEndian.Uint16(data))
+	if len(data) < 2+wl+2 {
+		return errors.New("BGP UPDATE withdrawn routes length too long")
+	}
+	var err error
+	if u.WithdrawnRoutes, err = decodeBGPPrefixes(data[2:2+wl], BGPAFIIPv4, BGPSAFIUnicast); err != nil {
+		return err
+	}
+	data = data[2+wl:]
+	al := int(binary.BigEndian.Uint16(data))
+	if len(data) < 2+al {
+		return errors.New("BGP UPDATE path attributes length too long")
+	}
+	attrs, nlri := data[2:2+al], data[2+al:]
+	for len(attrs) > 0 {
+		if len(attrs) < 3 {
+			return errors.New("BGP path attribute truncated")
+		}
+		a := BGPPathAttribute{Flags: BGPAttributeFlags(attrs[0]), Type: BGPAttributeType(attrs[1])}
+		hdr, l := 3, int(attrs[2])
+		if a.Flags&BGPAttributeFlagExtendedLength != 0 {
+			if len(attrs) < 4 {
+				return errors.New("BGP path attribute truncated")
+			}
+			hdr, l = 4, int(binary.BigEndian.Uint16(attrs[2:4]))
+		}
+		if len(attrs) < hdr+l {
+			return fmt.Errorf("BGP %v attribute truncated", a.Type)
+		}
+		a.Value = attrs[hdr : hdr+l]
+		u.PathAttributes = append(u.PathAttributes, a)
+		attrs = attrs[hdr+l:]
+	}
+
+	u.TwoOctetAS = enc == BGPASTwoOctet || enc == BGPASGuess && u.guessTwoOctetAS()
+	for i := range u.PathAttributes {
+		if err := u.PathAttributes[i].decodeValue(u.TwoOctetAS); err != nil {
+			return err
+		}
+	}
+	u.NLRI, err = decodeBGPPrefixes(nlri, BGPAFIIPv4, BGPSAFIUnicast)
+	return err
+}
+
+// guessTwoOctetAS guesses whether AS numbers are encoded on two octets,
+// from the AS_PATH attribute, or else the length of the AGGREGATOR one.
+func (u *BGPUpdate) guessTwoOctetAS() bool {
+	if a := u.Attribute(BGPAttributeASPath); a != nil && len(a.Value) > 0 {
+		return !validBGPASPath(a.Value, 4) && validBGPASPath(a.Value, 2)
+	}
+	if a := u.Attribute(BGPAttributeAggregator); a != nil {
+		return len(a.Value) == 6
+	}
+	return false
+}
+
+// validBGPASPath returns whether data is made of whole AS_PATH segments with
+// AS numbers of asLen octets.
+func validBGPASPath(data []byte, asLen int) bool {
+	for len(data) > 0 {
+		if len(data) < 2 || data[0] < 1 || data[0] > 4 || len(data) < 2+int(data[1])*asLen {
+			return false
+		}
+		data = data[2+int(data[1])*asLen:]
+	}
+	return true
+}
+
+func (a *BGPPathAttribute) decodeValue(twoOctetAS bool) error {
+	v := a.Value
+	invalid := func() error {
+		return fmt.Errorf("BGP %v attribute length %d invalid", a.Type, len(v))
+	}
+	asLen := 4
+	if twoOctetAS && (a.Type == BGPAttributeASPath || a.Type == BGPAttributeAggregator) {
+		asLen = 2
+	}
+	switch a.Type {
+	case BGPAttributeOrigin:
+		if len(v) != 1 {
+			return invalid()
+		}
+		a.Origin = BGPOrigin(v[0])
+	case BGPAttributeASPath, BGPAttributeAS4Path:
+		if !validBGPASPath(v, asLen) {
+			return fmt.Errorf("invalid BGP %v attribute", a.Type)
+		}
+		for len(v) > 0 {
+			s := BGPASPathSegment{Type: BGPASPathSegmentType(v[0]), ASNs: make([]uint32, v[1])}
+			v = v[2:]
+			for i := range s.ASNs {
+				s.ASNs[i] = bgpASN(v, asLen)
+				v = v[asLen:]
+			}
+			a.ASPath = append(a.ASPath, s)
+		}
+	case BGPAttributeNextHop, BGPAttributeOriginatorID:
+		if len(v) != 4 {
+			return invalid()
+		}
+		if a.Type == BGPAttributeNextHop {
+			a.NextHop = net.IP(v)
+		} else {
+			a.OriginatorID = net.IP(v)
+		}
+	case BGPAttributeMED, BGPAttributeLocalPref:
+		if len(v) != 4 {
+			return invalid()
+		}
+		if a.Type == BGPAttributeMED {
+			a.MED = binary.BigEndian.Uint32(v)
+		} else {
+			a.LocalPref = binary.BigEndian.Uint32(v)
+		}
+	case BGPAttributeAtomicAggregate:
+		if len(v) != 0 {
+			return invalid()
+		}
+	case BGPAttributeAggregator, BGPAttributeAS4Aggregator:
+		if len(v) != asLen+4 {
+			return invalid()
+		}
+		a.Aggregator = BGPAggregator{AS: bgpASN(v, asLen), IP: net.IP(v[asLen:])}
+	case BGPAttributeCommunities:
+		if len(v)%4 != 0 {
+			return invalid()
+		}
+		for ; len(v) > 0; v = v[4:] {
+			a.Communities = append(a.Communities, BGPCommunity(binary.BigEndian.Uint32(v)))
+		}
+	case BGPAttributeClusterList:
+		if len(v)%4 != 0 {
+			return invalid()
+		}
+		for ; len(v) > 0; v = v[4:] {
+			a.ClusterList = append(a.ClusterList, net.IP(v[:4]))
+		}
+	case BGPAttributeMPReachNLRI:
+		if len(v) < 5 || len(v) < 5+int(v[3]) {
+			return invalid()
+		}
+		r := &BGPMPReachNLRI{AFI: BGPAFI(binary.BigEndian.Uint16(v)), SAFI: BGPSAFI(v[2])}
+		nh := v[4 : 4+int(v[3])]
+		// the next hop is followed by a reserved octet
+		nlri := v[5+len(nh):]
+		var err error
+		if r.NextHops, err = decodeBGPNextHops(nh, r.SAFI); err != nil {
+			return err
+		}
+		if bgpPrefixFamily(r.AFI, r.SAFI) {
+			r.NLRI, err = decodeBGPPrefixes(nlri, r.AFI, r.SAFI)
+		} else {
+			r.RawNLRI = nlri
+		}
+		a.MPReachNLRI = r
+		return err
+	case BGPAttributeMPUnreachNLRI:
+		if len(v) < 3 {
+			return invalid()
+		}
+		r := &BGPMPUnreachNLRI{AFI: BGPAFI(binary.BigEndian.Uint16(v)), SAFI: BGPSAFI(v[2])}
+		var err error
+		if bgpPrefixFamily(r.AFI, r.SAFI) {
+			r.Withdrawn, err = decodeBGPPrefixes(v[3:], r.AFI, r.SAFI)
+		} else {
+			r.RawWithdrawn = v[3:]
+		}
+		a.MPUnreachNLRI = r
+		return err
+	case BGPAttributeExtendedCommunities:
+		if len(v)%8 != 0 {
+			return invalid()
+		}
+		for ; len(v) > 0; v = v[8:] {
+			a.ExtendedCommunities = append(a.ExtendedCommunities, binary.BigEndian.Uint64(v))
+		}
+	case BGPAttributeLargeCommunities:
+		if len(v)%12 != 0 {
+			return invalid()
+		}
+		for ; len(v) > 0; v = v[12:] {
+			a.LargeCommunities = append(a.LargeCommunities, BGPLargeCommunity{
+				GlobalAdministrator: binary.BigEndian.Uint32(v),
+				LocalData1:          binary.BigEndian.Uint32(v[4:]),
+				LocalData2:          binary.BigEndian.Uint32(v[8:]),
+			})
+		}
+	}
+	return nil
+}
+
+func bgpASN(data []byte, asLen int) uint32 {
+	if asLen == 2 {
+		return uint32(binary.BigEndian.Uint16(data))
+	}
+	return binary.BigEndian.Uint32(data)
+}
+
+// bgpPrefixFamily returns whether the routes of an address family are
+// decoded as BGPPrefix.
+func bgpPrefixFamily(afi BGPAFI, safi BGPSAFI) bool {
+	if afi != BGPAFIIPv4 && afi != BGPAFIIPv6 {
+		return false
+	}
+	switch safi {
+	case BGPSAFIUnicast, BGPSAFIMulticast, BGPSAFIMPLSLabel, BGPSAFIMPLSVPN:
+		return true
+	}
+	return false
+}
+
+// decodeBGPNextHops decodes the next hop of an MP_REACH_NLRI attribute.
+func decodeBGPNextHops(data []byte, safi BGPSAFI) ([]net.IP, error) {
+	rd := 0
+	if safi == BGPSAFIMPLSVPN {
+		rd = 8
+	}
+	size := net.IPv6len
+	if len(data) == rd+net.IPv4len {
+		size = net.IPv4len
+	}
+	if len(data)%(rd+size) != 0 {
+		return nil, fmt.Errorf("BGP next hop length %d invalid", len(data))
+	}
+	var hops []net.IP
+	for ; len(data) > 0; data = data[rd+size:] {
+		hops = append(hops, net.IP(data[rd:rd+size]))
+	}
+	return hops, nil
+}
+
+// decodeBGPPrefixes decodes the routes of an address family for which
+// bgpPrefixFamily is true.
+func decodeBGPPrefixes(data []byte, afi BGPAFI, safi BGPSAFI) ([]BGPPrefix, error) {
+	addrLen := net.IPv4len
+	if afi == BGPAFIIPv6 {
+		addrLen = net.IPv6len
+	}
+	var prefixes []BGPPrefix
+	for len(data) > 0 {
+		bits := int(data[0])
+		data = data[1:]
+		var p BGPPrefix
+		if safi == BGPSAFIMPLSLabel || safi == BGPSAFIMPLSVPN {
+			for {
+				if bits < 24 || len(data) < 3 {
+					return nil, errors.New("BGP route labels truncated")
+				}
+				label := uint32(data[0])<<16 | uint32(data[1])<<8 | uint32(data[2])
+				data = data[3:]
+				bits -= 24
+				p.Labels = append(p.Labels, label>>4)
+				if label&1 != 0 || label == 0x800000 || label == 0 {
+					break
+				}
+			}
+		}
+		if safi == BGPSAFIMPLSVPN {
+			if bits < 64 || len(data) < 8 {
+				return nil, errors.New("BGP route distinguisher truncated")
+			}
+			copy(p.RouteDistinguisher[:], data)
+			data = data[8:]
+			bits -= 64
+		}
+		n := (bits + 7) / 8
+		if bits > addrLen*8 || len(data) < n {
+			return nil, fmt.Errorf("BGP %v prefix length %d invalid", afi, bits)
+		}
+		p.IP = make(net.IP, addrLen)
+		copy(p.IP, data[:n])
+		p.Length = uint8(bits)
+		prefixes = append(prefixes, p)
+		data = data[n:]
+	}
+	return prefixes, nil
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+func (b *BGP) SerializeTo(buf gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	var data []byte
+	for i := range b.Messages {
+		var err error
+		if data, err = b.Messages[i].appendTo(data, opts); err != nil {
+			return err
+		}
+	}
+	bytes, err := buf.PrependBytes(len(data))
+	if err != nil {
+		return err
+	}
+	copy(bytes, data)
+	return nil
+}
+
+func (m *BGPMessage) appendTo(b []byte, opts gopacket.SerializeOptions) ([]byte, error) {
+	start := len(b)
+	for i := 0; i < 16; i++ {
+		b = append(b, 0xff)
+	}
+	b = append(b, 0, 0, byte(m.Type))
+
+	var err error
+	missing := fmt.Errorf("BGP %v message without its body", m.Type)
+	switch m.Type {
+	case BGPMessageOpen:
+		if m.Open == nil {
+			return nil, missing
+		}
+		b, err = m.Open.appendTo(b)
+	case BGPMessageUpdate:
+		if m.Update == nil {
+			return nil, missing
+		}
+		b, err = m.Update.appendTo(b)
+	case BGPMessageNotification:
+		if m.Notification == nil {
+			return nil, missing
+		}
+		b = append(b, byte(m.Notification.Code), m.Notification.Subcode)
+		b = append(b, m.Notification.Data...)
+	case BGPMessageKeepalive:
+	case BGPMessageRouteRefresh:
+		if m.RouteRefresh == nil {
+			return nil, missing
+		}
+		r := m.RouteRefresh
+		b = append(b, byte(r.AFI>>8), byte(r.AFI), r.Subtype, byte(r.SAFI))
+	default:
+		return nil, fmt.Errorf("unknown BGP message type %d", m.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	length := len(b) - start
+	if length > 0xffff {
+		return nil, fmt.Errorf("BGP %v message too long: %d bytes", m.Type, length)
+	}
+	if opts.FixLengths {
+		m.Length = uint16(length)
+	}
+	binary.BigEndian.PutUint16(b[start+16:], m.Length)
+	return b, nil
+}
+
+func (o *BGPOpen) appendTo(b []byte) ([]byte, error) {
+	id := o.Identifier.To4()
+	if id == nil {
+		return nil, fmt.Errorf("BGP identifier %v is not an IPv4 address", o.Identifier)
+	}
+	b = append(b, o.Version, byte(o.MyAS>>8), byte(o.MyAS), byte(o.HoldTime>>8), byte(o.HoldTime))
+	b = append(b, id...)
+
+	// capabilities are packed in the parameter of their index
+	params := make([]BGPOptionalParameter, 0, len(o.Capabilities)+len(o.Parameters))
+	capParams := map[int]int{}
+	for _, c := range o.Capabilities {
+		if len(c.Value) > 255 {
+			return nil, fmt.Errorf("BGP %v capability too long", c.Code)
+		}
+		i, ok := capParams[c.Parameter]
+		if !ok {
+			i = len(params)
+			capParams[c.Parameter] = i
+			params = append(params, BGPOptionalParameter{Type: bgpParameterCapabilities, Index: c.Parameter})
+		}
+		params[i].Value = append(append(params[i].Value, byte(c.Code), byte(len(c.Value))), c.Value...)
+	}
+	params = append(params, o.Parameters...)
+	sort.SliceStable(params, func(i, j int) bool { return params[i].Index < params[j].Index })
+	length, extended := 0, false
+	for _, p := range params {
+		length += 2 + len(p.Value)
+		extended = extended || len(p.Value) > 255
+	}
+	if extended || length > 255 {
+		length += len(params)
+		if length > 0xffff {
+			return nil, errors.New("BGP OPEN optional parameters too long")
+		}
+		b = append(b, 255, 255, byte(length>>8), byte(length))
+		for _, p := range params {
+			b = append(b, p.Type, byte(len(p.Value)>>8), byte(len(p.Value)))
+			b = append(b, p.Value...)
+		}
+		return b, nil
+	}
+	b = append(b, byte(length))
+	for _, p := range params {
+		b = append(b, p.Type, byte(len(p.Value)))
+		b = append(b, p.Value...)
+	}
+	return b, nil
+}
+
+func (u *BGPUpdate) appendTo(b []byte) ([]byte, error) {
+	var err error
+	start := len(b)
+	b = append(b, 0, 0)
+	for _, p := range u.WithdrawnRoutes {
+		if b, err = appendBGPPrefix(b, p, BGPAFIIPv4, BGPSAFIUnicast); err != nil {
+			return nil, err
+		}
+	}
+	binary.BigEndian.PutUint16(b[start:], uint16(len(b)-start-2))
+
+	start = len(b)
+	b = append(b, 0, 0)
+	for i := range u.PathAttributes {
+		if b, err = u.PathAttributes[i].appendTo(b, u.TwoOctetAS); err != nil {
+			return nil, err
+		}
+	}
+	if len(b)-start-2 > 0xffff {
+		return nil, errors.New("BGP UPDATE path attributes too long")
+	}
+	binary.BigEndian.PutUint16(b[start:], uint16(len(b)-start-2))
+
+	for _, p := range u.NLRI {
+		if b, err = appendBGPPrefix(b, p, BGPAFIIPv4, BGPSAFIUnicast); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// bgpDefaultAttributeFlags returns the usual flags of an attribute type.
+func bgpDefaultAttributeFlags(t BGPAttributeType) BGPAttributeFlags {
+	switch t {
+	case BGPAttributeOrigin, BGPAttributeASPath, BGPAttributeNextHop, BGPAttributeLocalPref, BGPAttributeAtomicAggregate:
+		return BGPAttributeFlagTransitive
+	case BGPAttributeMED, BGPAttributeOriginatorID, BGPAttributeClusterList, BGPAttributeMPReachNLRI, BGPAttributeMPUnreachNLRI:
+		return BGPAttributeFlagOptional
+	}
+	return BGPAttributeFlagOptional | BGPAttributeFlagTransitive
+}
+
+func (a *BGPPathAttribute) appendTo(b []byte, twoOctetAS bool) ([]byte, error) {
+	v, err := a.encodeValue(twoOctetAS)
+	if err != nil {
+		return nil, err
+	}
+	if len(v) > 0xffff {
+		return nil, fmt.Errorf("BGP %v attribute too long", a.Type)
+	}
+	flags := a.Flags
+	if flags == 0 {
+		flags = bgpDefaultAttributeFlags(a.Type)
+	}
+	if len(v) > 255 {
+		flags |= BGPAttributeFlagExtendedLength
+	}
+	b = append(b, byte(flags), byte(a.Type))
+	if flags&BGPAttributeFlagExtendedLength != 0 {
+		b = append(b, byte(len(v)>>8))
+	}
+	b = append(b, byte(len(v)))
+	return append(b, v...), nil
+}
+
+// bgpASTrans is the AS number standing for those which do not fit in two
+// octets (RFC 6793).
+const bgpASTrans = 23456
+
+func appendBGPASN(b []byte, as uint32, asLen int) []byte {
+	if asLen == 2 {
+		if as > 0xffff {
+			as = bgpASTrans
+		}
+		return append(b, byte(as>>8), byte(as))
+	}
+	return append(b, byte(as>>24), byte(as>>16), byte(as>>8), byte(as))
+}
+
+func appendBGPIPv4(b []byte, ip net.IP, what string) ([]byte, error) {
+	ip4 := ip.To4()
+	if ip4 == nil {
+		return nil, fmt.Errorf("BGP %s %v is not an IPv4 address", what, ip)
+	}
+	return append(b, ip4...), nil
+}
+
+// encodeValue returns the value of an attribute: the encoding of its typed
+// fields for the known types, Value for others.
+func (a *BGPPathAttribute) encodeValue(twoOctetAS bool) ([]byte, error) {
+	asLen := 4
+	if twoOctetAS && (a.Type == BGPAttributeASPath || a.Type == BGPAttributeAggregator) {
+		asLen = 2
+	}
+	var v []byte
+	var err error
+	switch a.Type {
+	case BGPAttributeOrigin:
+		v = []byte{byte(a.Origin)}
+	case BGPAttributeASPath, BGPAttributeAS4Path:
+		v = []byte{}
+		for _, s := range a.ASPath {
+			if len(s.ASNs) > 255 {
+				return nil, fmt.Errorf("BGP %v segment too long", a.Type)
+			}
+			v = append(v, byte(s.Type), byte(len(s.ASNs)))
+			for _, as := range s.ASNs {
+				v = appendBGPASN(v, as, asLen)
+			}
+		}
+	case BGPAttributeNextHop:
+		v, err = appendBGPIPv4(nil, a.NextHop, "next hop")
+	case BGPAttributeOriginatorID:
+		v, err = appendBGPIPv4(nil, a.OriginatorID, "originator ID")
+	case BGPAttributeMED:
+		v = appendBGPASN(nil, a.MED, 4)
+	case BGPAttributeLocalPref:
+		v = appendBGPASN(nil, a.LocalPref, 4)
+	case BGPAttributeAtomicAggregate:
+		v = []byte{}
+	case BGPAttributeAggregator, BGPAttributeAS4Aggregator:
+		v, err = appendBGPIPv4(appendBGPASN(nil, a.Aggregator.AS, asLen), a.Aggregator.IP, "aggregator")
+	case BGPAttributeCommunities:
+		v = []byte{}
+		for _, c := range a.Communities {
+			v = appendBGPASN(v, uint32(c), 4)
+		}
+	case BGPAttributeClusterList:
+		v = []byte{}
+		for _, id := range a.ClusterList {
+			if v, err = appendBGPIPv4(v, id, "cluster ID"); err != nil {
+				return nil, err
+			}
+		}
+	case BGPAttributeMPReachNLRI:
+		if a.MPReachNLRI == nil {
+			return nil, errors.New("BGP MP_REACH_NLRI attribute without its value")
+		}
+		v, err = a.MPReachNLRI.encode()
+	case BGPAttributeMPUnreachNLRI:
+		r := a.MPUnreachNLRI
+		if r == nil {
+			return nil, errors.New("BGP MP_UNREACH_NLRI attribute without its value")
+		}
+		v = []byte{byte(r.AFI >> 8), byte(r.AFI), byte(r.SAFI)}
+		for _, p := range r.Withdrawn {
+			if v, err = appendBGPPrefix(v, p, r.AFI, r.SAFI); err != nil {
+				return nil, err
+			}
+		}
+		v = append(v, r.RawWithdrawn...)
+	case BGPAttributeExtendedCommunities:
+		v = make([]byte, 8*len(a.ExtendedCommunities))
+		for i, c := range a.ExtendedCommunities {
+			binary.BigEndian.PutUint64(v[8*i:], c)
+		}
+	case BGPAttributeLargeCommunities:
+		v = []byte{}
+		for _, c := range a.LargeCommunities {
+			v = appendBGPASN(v, c.GlobalAdministrator, 4)
+			v = appendBGPASN(v, c.LocalData1, 4)
+			v = appendBGPASN(v, c.LocalData2, 4)
+		}
+	default:
+		v = a.Value
+	}
+	return v, err
+}
+
+func (r *BGPMPReachNLRI) encode() ([]byte, error) {
+	v := []byte{byte(r.AFI >> 8), byte(r.AFI), byte(r.SAFI), 0}
+	for _, hop := range r.NextHops {
+		if r.SAFI == BGPSAFIMPLSVPN {
+			v = append(v, make([]byte, 8)...)
+		}
+		if ip4 := hop.To4(); ip4 != nil && r.AFI == BGPAFIIPv4 {
+			v = append(v, ip4...)
+		} else if ip6 := hop.To16(); ip6 != nil {
+			v = append(v, ip6...)
+		} else {
+			return nil, fmt.Errorf("invalid BGP next hop %v", hop)
+		}
+	}
+	if len(v)-4 > 255 {
+		return nil, errors.New("BGP next hop too long")
+	}
+	v[3] = byte(len(v) - 4)
+	// reserved octet
+	v = append(v, 0)
+	var err error
+	for _, p := range r.NLRI {
+		if v, err = appendBGPPrefix(v, p, r.AFI, r.SAFI); err != nil {
+			return nil, err
+		}
+	}
+	return append(v, r.RawNLRI...), nil
+}
+
+// appendBGPPrefix appends a route of an address family for which
+// bgpPrefixFamily is true.
+func appendBGPPrefix(b []byte, p BGPPrefix, afi BGPAFI, safi BGPSAFI) ([]byte, error) {
+	ip := p.IP.To16()
+	maxLen := 8 * net.IPv6len
+	if afi == BGPAFIIPv4 {
+		ip = p.IP.To4()
+		maxLen = 8 * net.IPv4len
+	}
+	if ip == nil || int(p.Length) > maxLen {
+		return nil, fmt.Errorf("invalid BGP %v prefix %v", afi, p)
+	}
+	labeled := safi == BGPSAFIMPLSLabel || safi == BGPSAFIMPLSVPN
+	bits := int(p.Length)
+	if labeled {
+		bits += 24 * len(p.Labels)
+	}
+	if safi == BGPSAFIMPLSVPN {
+		bits += 64
+	}
+	if bits > 255 {
+		return nil, fmt.Errorf("BGP prefix %v too long", p)
+	}
+	b = append(b, byte(bits))
+	if labeled {
+		for i, l := range p.Labels {
+			e := l << 4
+			// bottom of stack, unless the withdrawal compatibility value
+			if i == len(p.Labels)-1 && l != 0x80000 {
+				e |= 1
+			}
+			b = append(b, byte(e>>16), byte(e>>8), byte(e))
+		}
+	}
+	if safi == BGPSAFIMPLSVPN {
+		b = append(b, p.RouteDistinguisher[:]...)
+	}
+	return append(b, ip[:(p.Length+7)/8]...), nil
+}
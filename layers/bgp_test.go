@@ -0,0 +1,273 @@
+// Copyright 2026 The GoPacket Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"bytes"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+// A KEEPALIVE, then an UPDATE between 2-octet AS speakers: 192.0.2.0/24 is
+// withdrawn, 198.51.100.0/24 announced with an AS_PATH of 65001 65002.
+var testBGPPacket = []byte{
+	0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+	0x00, 0x13, 0x04,
+	0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+	0x00, 0x3a, 0x02,
+	0x00, 0x04, 0x18, 0xc0, 0x00, 0x02,
+	0x00, 0x1b,
+	0x40, 0x01, 0x01, 0x00,
+	0x40, 0x02, 0x06, 0x02, 0x02, 0xfd, 0xe9, 0xfd, 0xea,
+	0x40, 0x03, 0x04, 0x0a, 0x00, 0x00, 0x01,
+	0x80, 0x04, 0x04, 0x00, 0x00, 0x00, 0x64,
+	0x18, 0xc6, 0x33, 0x64,
+}
+
+func TestBGPDecode(t *testing.T) {
+	p := gopacket.NewPacket(testBGPPacket, LayerTypeBGP, gopacket.Default)
+	b, ok := p.Layer(LayerTypeBGP).(*BGP)
+	if !ok {
+		t.Fatalf("no BGP layer: %v", p)
+	}
+	if len(b.Messages) != 2 || b.Messages[0].Type != BGPMessageKeepalive || b.Messages[1].Type != BGPMessageUpdate {
+		t.Fatalf("messages: %+v", b.Messages)
+	}
+	u := b.Messages[1].Update
+	if !u.TwoOctetAS || len(u.WithdrawnRoutes) != 1 || u.WithdrawnRoutes[0].String() != "192.0.2.0/24" ||
+		len(u.NLRI) != 1 || u.NLRI[0].String() != "198.51.100.0/24" {
+		t.Errorf("routes: %+v", u)
+	}
+	if a := u.Attribute(BGPAttributeASPath); a == nil || !reflect.DeepEqual(a.ASPath, []BGPASPathSegment{{BGPASSequence, []uint32{65001, 65002}}}) {
+		t.Errorf("AS_PATH: %+v", a)
+	}
+	if a := u.Attribute(BGPAttributeMED); a == nil || a.MED != 100 || a.Flags != BGPAttributeFlagOptional {
+		t.Errorf("MED: %+v", a)
+	}
+	if a := u.Attribute(BGPAttributeNextHop); a == nil || !a.NextHop.Equal(net.IP{10, 0, 0, 1}) {
+		t.Errorf("NEXT_HOP: %+v", a)
+	}
+
+	// the encoding of AS numbers known from the session
+	var b4 BGP
+	if err := b4.DecodeWithASEncoding(testBGPPacket, BGPASFourOctet, gopacket.NilDecodeFeedback); err == nil {
+		t.Errorf("2-octet AS_PATH decoded with 4-octet AS numbers: %+v", b4.Messages[1].Update.PathAttributes[1])
+	}
+
+	// a message split over two segments
+	p = gopacket.NewPacket(testBGPPacket[:40], LayerTypeBGP, gopacket.Default)
+	if b, ok := p.Layer(LayerTypeBGP).(*BGP); !ok || len(b.Messages) != 1 || !p.Metadata().Truncated || len(b.Contents) != 19 {
+		t.Errorf("truncated message: %v", p)
+	}
+	for _, bad := range [][]byte{
+		append([]byte{0}, testBGPPacket[1:]...),
+		testBGPPacket[:10],
+		// KEEPALIVE with a body
+		append(append([]byte(nil), testBGPPacket[:16]...), 0, 0x14, 0x04, 0),
+	} {
+		if p := gopacket.NewPacket(bad, LayerTypeBGP, gopacket.Default); p.ErrorLayer() == nil {
+			t.Errorf("%x decoded: %v", bad, p)
+		}
+	}
+}
+
+func TestBGPSerialize(t *testing.T) {
+	rd := BGPRouteDistinguisher{0, 0, 0xfd, 0xe8, 0, 0, 0, 1}
+	messages := []BGPMessage{
+		{Type: BGPMessageOpen, Open: &BGPOpen{
+			Version:    4,
+			MyAS:       23456,
+			HoldTime:   90,
+			Identifier: net.IP{192, 0, 2, 1},
+			Capabilities: []BGPCapability{
+				{Code: BGPCapabilityMultiProtocol, Value: []byte{0, 2, 0, 1}},
+				{Code: BGPCapabilityRouteRefresh, Value: []byte{}},
+				{Code: BGPCapabilityFourOctetAS, Value: []byte{0xfa, 0x56, 0xea, 0x00}},
+			},
+		}},
+		{Type: BGPMessageUpdate, Update: &BGPUpdate{
+			PathAttributes: []BGPPathAttribute{
+				{Type: BGPAttributeOrigin, Origin: BGPOriginIncomplete},
+				{Type: BGPAttributeASPath, ASPath: []BGPASPathSegment{
+					{BGPASSequence, []uint32{4200000000, 65001}},
+					{BGPASSet, []uint32{1, 2}},
+				}},
+				{Type: BGPAttributeCommunities, Communities: []BGPCommunity{65001<<16 | 100, BGPCommunityNoExport}},
+				{Type: BGPAttributeLargeCommunities, LargeCommunities: []BGPLargeCommunity{{4200000000, 1, 2}}},
+				{Type: BGPAttributeAggregator, Aggregator: BGPAggregator{4200000000, net.IP{192, 0, 2, 1}}},
+				{Type: BGPAttributeMPReachNLRI, MPReachNLRI: &BGPMPReachNLRI{
+					AFI:      BGPAFIIPv6,
+					SAFI:     BGPSAFIUnicast,
+					NextHops: []net.IP{net.ParseIP("2001:db8::1"), net.ParseIP("fe80::1")},
+					NLRI:     []BGPPrefix{{IP: net.ParseIP("2001:db8:1::"), Length: 48}},
+				}},
+				{Type: BGPAttributeMPUnreachNLRI, MPUnreachNLRI: &BGPMPUnreachNLRI{
+					AFI:       BGPAFIIPv4,
+					SAFI:      BGPSAFIMPLSVPN,
+					Withdrawn: []BGPPrefix{{Labels: []uint32{0x80000}, RouteDistinguisher: rd, IP: net.IP{10, 1, 0, 0}, Length: 16}},
+				}},
+			},
+		}},
+		{Type: BGPMessageUpdate, Update: &BGPUpdate{
+			PathAttributes: []BGPPathAttribute{
+				{Type: BGPAttributeMPReachNLRI, MPReachNLRI: &BGPMPReachNLRI{
+					AFI:      BGPAFIIPv4,
+					SAFI:     BGPSAFIMPLSVPN,
+					NextHops: []net.IP{{192, 0, 2, 1}},
+					NLRI:     []BGPPrefix{{Labels: []uint32{16001}, RouteDistinguisher: rd, IP: net.IP{10, 2, 3, 0}, Length: 24}},
+				}},
+				{Type: BGPAttributeExtendedCommunities, ExtendedCommunities: []uint64{0x0002fde800000001}},
+			},
+		}},
+		{Type: BGPMessageNotification, Notification: &BGPNotification{Code: BGPErrorCease, Subcode: 2}},
+		{Type: BGPMessageRouteRefresh, RouteRefresh: &BGPRouteRefresh{AFI: BGPAFIIPv6, SAFI: BGPSAFIUnicast}},
+	}
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true}, &BGP{Messages: messages}); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	p := gopacket.NewPacket(data, LayerTypeBGP, gopacket.Default)
+	b, ok := p.Layer(LayerTypeBGP).(*BGP)
+	if !ok || len(b.Messages) != len(messages) {
+		t.Fatalf("decoded %v", p)
+	}
+	for i, m := range b.Messages {
+		if m.Type != messages[i].Type || m.Length != messages[i].Length {
+			t.Errorf("message %d: %v of %d bytes, want %v of %d", i, m.Type, m.Length, messages[i].Type, messages[i].Length)
+		}
+	}
+
+	open := b.Messages[0].Open
+	if open.AS() != 4200000000 || !open.Identifier.Equal(net.IP{192, 0, 2, 1}) || len(open.Capabilities) != 3 {
+		t.Errorf("OPEN: %+v", open)
+	}
+	if afi, safi, ok := open.Capabilities[0].MultiProtocol(); !ok || afi != BGPAFIIPv6 || safi != BGPSAFIUnicast {
+		t.Errorf("multiprotocol capability: %v %v", afi, safi)
+	}
+
+	u := b.Messages[1].Update
+	if u.TwoOctetAS || !reflect.DeepEqual(u.Attribute(BGPAttributeASPath).ASPath, messages[1].Update.PathAttributes[1].ASPath) {
+		t.Errorf("AS_PATH: %+v", u.Attribute(BGPAttributeASPath))
+	}
+	if a := u.Attribute(BGPAttributeCommunities); a.Communities[0].String() != "65001:100" || a.Communities[1] != BGPCommunityNoExport {
+		t.Errorf("communities: %v", a.Communities)
+	}
+	if a := u.Attribute(BGPAttributeLargeCommunities); len(a.LargeCommunities) != 1 || a.LargeCommunities[0].String() != "4200000000:1:2" {
+		t.Errorf("large communities: %v", a.LargeCommunities)
+	}
+	if a := u.Attribute(BGPAttributeAggregator); a.Aggregator.AS != 4200000000 {
+		t.Errorf("aggregator: %+v", a.Aggregator)
+	}
+	reach := u.Attribute(BGPAttributeMPReachNLRI).MPReachNLRI
+	if len(reach.NextHops) != 2 || !reach.NextHops[1].Equal(net.ParseIP("fe80::1")) || len(reach.NLRI) != 1 || reach.NLRI[0].String() != "2001:db8:1::/48" {
+		t.Errorf("MP_REACH_NLRI: %+v", reach)
+	}
+	unreach := u.Attribute(BGPAttributeMPUnreachNLRI).MPUnreachNLRI
+	if len(unreach.Withdrawn) != 1 || unreach.Withdrawn[0].String() != "65000:1:10.1.0.0/16" || unreach.Withdrawn[0].Labels[0] != 0x80000 {
+		t.Errorf("MP_UNREACH_NLRI: %+v", unreach)
+	}
+
+	vpn := b.Messages[2].Update.PathAttributes[0].MPReachNLRI
+	if len(vpn.NextHops) != 1 || !vpn.NextHops[0].Equal(net.IP{192, 0, 2, 1}) || len(vpn.NLRI) != 1 ||
+		!reflect.DeepEqual(vpn.NLRI[0].Labels, []uint32{16001}) || vpn.NLRI[0].String() != "65000:1:10.2.3.0/24" {
+		t.Errorf("VPN MP_REACH_NLRI: %+v", vpn)
+	}
+	if n := b.Messages[3].Notification; n.Code != BGPErrorCease || n.Subcode != 2 {
+		t.Errorf("NOTIFICATION: %+v", n)
+	}
+	if r := b.Messages[4].RouteRefresh; r.AFI != BGPAFIIPv6 || r.SAFI != BGPSAFIUnicast {
+		t.Errorf("ROUTE-REFRESH: %+v", r)
+	}
+
+	// decoded messages serialize back to the same bytes
+	buf = gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{}, b); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), data) {
+		t.Errorf("serialized decoded messages:\n%x\nwant\n%x", buf.Bytes(), data)
+	}
+
+	// 2-octet AS numbers
+	two := &BGPUpdate{TwoOctetAS: true, PathAttributes: []BGPPathAttribute{
+		{Type: BGPAttributeASPath, ASPath: []BGPASPathSegment{{BGPASSequence, []uint32{4200000000}}}},
+		{Type: BGPAttributeAS4Path, ASPath: []BGPASPathSegment{{BGPASSequence, []uint32{4200000000}}}},
+	}}
+	buf = gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true},
+		&BGP{Messages: []BGPMessage{{Type: BGPMessageUpdate, Update: two}}}); err != nil {
+		t.Fatal(err)
+	}
+	var dec BGP
+	if err := dec.DecodeWithASEncoding(buf.Bytes(), BGPASTwoOctet, gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal(err)
+	}
+	attrs := dec.Messages[0].Update.PathAttributes
+	if attrs[0].ASPath[0].ASNs[0] != 23456 || attrs[1].ASPath[0].ASNs[0] != 4200000000 {
+		t.Errorf("2-octet AS_PATH %+v, AS4_PATH %+v", attrs[0].ASPath, attrs[1].ASPath)
+	}
+}
+
+// An OPEN of AS 65001 packing its multiprotocol, route refresh and 4-octet
+// AS number capabilities in one optional parameter, followed by a
+// deprecated authentication parameter and a graceful restart capability in
+// its own parameter.
+var testBGPOpenPacked = []byte{
+	0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+	0x00, 0x36, 0x01,
+	0x04, 0xfd, 0xe9, 0x00, 0xb4, 0x0a, 0x00, 0x00, 0x01,
+	0x19,
+	0x02, 0x0e, 0x01, 0x04, 0x00, 0x01, 0x00, 0x01, 0x02, 0x00, 0x41, 0x04, 0x00, 0x00, 0xfd, 0xe9,
+	0x01, 0x01, 0x00,
+	0x02, 0x04, 0x40, 0x02, 0x00, 0x78,
+}
+
+func TestBGPOpenPacked(t *testing.T) {
+	p := gopacket.NewPacket(testBGPOpenPacked, LayerTypeBGP, gopacket.Default)
+	b, ok := p.Layer(LayerTypeBGP).(*BGP)
+	if !ok || len(b.Messages) != 1 || b.Messages[0].Open == nil {
+		t.Fatalf("no OPEN: %v", p)
+	}
+	open := b.Messages[0].Open
+	var params []int
+	for _, c := range open.Capabilities {
+		params = append(params, c.Parameter)
+	}
+	if !reflect.DeepEqual(params, []int{0, 0, 0, 2}) || len(open.Parameters) != 1 || open.Parameters[0].Index != 1 || open.AS() != 65001 {
+		t.Errorf("OPEN: %+v", open)
+	}
+
+	for _, opts := range []gopacket.SerializeOptions{{}, {FixLengths: true}} {
+		buf := gopacket.NewSerializeBuffer()
+		if err := gopacket.SerializeLayers(buf, opts, b); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf.Bytes(), testBGPOpenPacked) {
+			t.Errorf("serialized with %+v:\n%x\nwant\n%x", opts, buf.Bytes(), testBGPOpenPacked)
+		}
+	}
+}
+
+func TestBGPEndOfRIB(t *testing.T) {
+	u := &BGPUpdate{}
+	if afi, safi, ok := u.EndOfRIB(); !ok || afi != BGPAFIIPv4 || safi != BGPSAFIUnicast {
+		t.Errorf("IPv4 End-of-RIB: %v %v %v", afi, safi, ok)
+	}
+	u.PathAttributes = []BGPPathAttribute{{Type: BGPAttributeMPUnreachNLRI, MPUnreachNLRI: &BGPMPUnreachNLRI{AFI: BGPAFIIPv6, SAFI: BGPSAFIUnicast}}}
+	if afi, _, ok := u.EndOfRIB(); !ok || afi != BGPAFIIPv6 {
+		t.Errorf("IPv6 End-of-RIB: %v %v", afi, ok)
+	}
+	u.NLRI = []BGPPrefix{{IP: net.IP{10, 0, 0, 0}, Length: 8}}
+	if _, _, ok := u.EndOfRIB(); ok {
+		t.Error("End-of-RIB with routes")
+	}
+}
@@ -1270,6 +1270,27 @@ func TestSCTPChunkBadLength(t *testing.T) {
 	gopacket.NewPacket(data, LinkTypeEthernet, gopacket.Default)
 }
 
+// TestSCTPDataShort tests Data chunks shorter than their header
+func TestSCTPDataShort(t *testing.T) {
+	header := []byte{0x9c, 0x40, 0x0f, 0x1c, 0, 0, 0, 1, 0, 0, 0, 0}
+	for _, test := range []struct {
+		chunk     []byte
+		truncated bool
+	}{
+		{[]byte{0, 3, 0, 60, 0, 0, 0, 1}, true},
+		{[]byte{0, 3}, true},
+		// a length shorter than the Data chunk header
+		{[]byte{0, 3, 0, 5, 0, 0, 0, 1, 0, 0, 0, 1, 0, 0, 0, 0}, false},
+	} {
+		p := gopacket.NewPacket(append(append([]byte(nil), header...), test.chunk...), LayerTypeSCTP, gopacket.Default)
+		if p.ErrorLayer() == nil || p.Layer(LayerTypeSCTPData) != nil || p.Metadata().Truncated != test.truncated {
+			t.Errorf("%x: %v", test.chunk, p)
+		} else if err := p.ErrorLayer().Error(); strings.Contains(err.Error(), "runtime error") {
+			t.Errorf("%x: %v", test.chunk, err)
+		}
+	}
+}
+
 // TestSTP
 func TestSTP(t *testing.T) {
 	testSTPpacket := []byte{
@@ -0,0 +1,615 @@
+// Copyright 2026 The GoPacket Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/google/gopacket"
+)
+
+const diameterHeaderLength = 20
+
+// DiameterCommandCode is the command code of a Diameter message, shared by
+// its requests and answers.
+type DiameterCommandCode uint32
+
+// DiameterCommandCode known values, of the base protocol and of RFC 4006.
+const (
+	DiameterCommandCapabilitiesExchange DiameterCommandCode = 257
+	DiameterCommandReAuth               DiameterCommandCode = 258
+	DiameterCommandAccounting           DiameterCommandCode = 271
+	DiameterCommandCreditControl        DiameterCommandCode = 272
+	DiameterCommandAbortSession         DiameterCommandCode = 274
+	DiameterCommandSessionTermination   DiameterCommandCode = 275
+	DiameterCommandDeviceWatchdog       DiameterCommandCode = 280
+	DiameterCommandDisconnectPeer       DiameterCommandCode = 282
+)
+
+func (c DiameterCommandCode) String() string {
+	switch c {
+	case DiameterCommandCapabilitiesExchange:
+		return "Capabilities-Exchange"
+	case DiameterCommandReAuth:
+		return "Re-Auth"
+	case DiameterCommandAccounting:
+		return "Accounting"
+	case DiameterCommandCreditControl:
+		return "Credit-Control"
+	case DiameterCommandAbortSession:
+		return "Abort-Session"
+	case DiameterCommandSessionTermination:
+		return "Session-Termination"
+	case DiameterCommandDeviceWatchdog:
+		return "Device-Watchdog"
+	case DiameterCommandDisconnectPeer:
+		return "Disconnect-Peer"
+	}
+	return fmt.Sprintf("Unknown(%d)", uint32(c))
+}
+
+// DiameterFlags are the command flags of a Diameter message.
+type DiameterFlags uint8
+
+// DiameterFlags known values.
+const (
+	DiameterFlagRequest       DiameterFlags = 0x80
+	DiameterFlagProxiable     DiameterFlags = 0x40
+	DiameterFlagError         DiameterFlags = 0x20
+	DiameterFlagRetransmitted DiameterFlags = 0x10
+)
+
+// DiameterApplicationID identifies the application of a Diameter message.
+type DiameterApplicationID uint32
+
+// DiameterApplicationID known values.
+const (
+	DiameterApplicationCommon        DiameterApplicationID = 0
+	DiameterApplicationNASREQ        DiameterApplicationID = 1
+	DiameterApplicationAccounting    DiameterApplicationID = 3
+	DiameterApplicationCreditControl DiameterApplicationID = 4
+	DiameterApplicationEAP           DiameterApplicationID = 5
+	DiameterApplicationRelay         DiameterApplicationID = 0xffffffff
+)
+
+func (a DiameterApplicationID) String() string {
+	switch a {
+	case DiameterApplicationCommon:
+		return "Common"
+	case DiameterApplicationNASREQ:
+		return "NASREQ"
+	case DiameterApplicationAccounting:
+		return "Accounting"
+	case DiameterApplicationCreditControl:
+		return "CreditControl"
+	case DiameterApplicationEAP:
+		return "EAP"
+	case DiameterApplicationRelay:
+		return "Relay"
+	}
+	return fmt.Sprintf("Unknown(%d)", uint32(a))
+}
+
+// DiameterDataType is the data format of the data of an AVP.
+type DiameterDataType uint8
+
+// DiameterDataType values.
+const (
+	// DiameterDataOctetString is any sequence of bytes, and the type of
+	// unknown AVPs.
+	DiameterDataOctetString DiameterDataType = iota
+	DiameterDataInteger32
+	DiameterDataInteger64
+	DiameterDataUnsigned32
+	DiameterDataUnsigned64
+	DiameterDataFloat32
+	DiameterDataFloat64
+	DiameterDataGrouped
+	DiameterDataAddress
+	DiameterDataTime
+	DiameterDataUTF8String
+	DiameterDataIdentity
+	DiameterDataURI
+	DiameterDataEnumerated
+)
+
+// DiameterAVPCode is the code of an AVP. The known values are those of the
+// IETF, whose AVPs have no vendor ID.
+type DiameterAVPCode uint32
+
+// DiameterAVPCode known values, of the base protocol and of RFC 4006.
+const (
+	DiameterAVPUserName                      DiameterAVPCode = 1
+	DiameterAVPClass                         DiameterAVPCode = 25
+	DiameterAVPSessionTimeout                DiameterAVPCode = 27
+	DiameterAVPProxyState                    DiameterAVPCode = 33
+	DiameterAVPAcctSessionID                 DiameterAVPCode = 44
+	DiameterAVPAcctMultiSessionID            DiameterAVPCode = 50
+	DiameterAVPEventTimestamp                DiameterAVPCode = 55
+	DiameterAVPAcctInterimInterval           DiameterAVPCode = 85
+	DiameterAVPHostIPAddress                 DiameterAVPCode = 257
+	DiameterAVPAuthApplicationID             DiameterAVPCode = 258
+	DiameterAVPAcctApplicationID             DiameterAVPCode = 259
+	DiameterAVPVendorSpecificApplicationID   DiameterAVPCode = 260
+	DiameterAVPRedirectHostUsage             DiameterAVPCode = 261
+	DiameterAVPRedirectMaxCacheTime          DiameterAVPCode = 262
+	DiameterAVPSessionID                     DiameterAVPCode = 263
+	DiameterAVPOriginHost                    DiameterAVPCode = 264
+	DiameterAVPSupportedVendorID             DiameterAVPCode = 265
+	DiameterAVPVendorID                      DiameterAVPCode = 266
+	DiameterAVPFirmwareRevision              DiameterAVPCode = 267
+	DiameterAVPResultCode                    DiameterAVPCode = 268
+	DiameterAVPProductName                   DiameterAVPCode = 269
+	DiameterAVPSessionBinding                DiameterAVPCode = 270
+	DiameterAVPSessionServerFailover         DiameterAVPCode = 271
+	DiameterAVPMultiRoundTimeOut             DiameterAVPCode = 272
+	DiameterAVPDisconnectCause               DiameterAVPCode = 273
+	DiameterAVPAuthRequestType               DiameterAVPCode = 274
+	DiameterAVPAuthGracePeriod               DiameterAVPCode = 276
+	DiameterAVPAuthSessionState              DiameterAVPCode = 277
+	DiameterAVPOriginStateID                 DiameterAVPCode = 278
+	DiameterAVPFailedAVP                     DiameterAVPCode = 279
+	DiameterAVPProxyHost                     DiameterAVPCode = 280
+	DiameterAVPErrorMessage                  DiameterAVPCode = 281
+	DiameterAVPRouteRecord                   DiameterAVPCode = 282
+	DiameterAVPDestinationRealm              DiameterAVPCode = 283
+	DiameterAVPProxyInfo                     DiameterAVPCode = 284
+	DiameterAVPReAuthRequestType             DiameterAVPCode = 285
+	DiameterAVPAccountingSubSessionID        DiameterAVPCode = 287
+	DiameterAVPAuthorizationLifetime         DiameterAVPCode = 291
+	DiameterAVPRedirectHost                  DiameterAVPCode = 292
+	DiameterAVPDestinationHost               DiameterAVPCode = 293
+	DiameterAVPErrorReportingHost            DiameterAVPCode = 294
+	DiameterAVPTerminationCause              DiameterAVPCode = 295
+	DiameterAVPOriginRealm                   DiameterAVPCode = 296
+	DiameterAVPExperimentalResult            DiameterAVPCode = 297
+	DiameterAVPExperimentalResultCode        DiameterAVPCode = 298
+	DiameterAVPInbandSecurityID              DiameterAVPCode = 299
+	DiameterAVPCCInputOctets                 DiameterAVPCode = 412
+	DiameterAVPCCOutputOctets                DiameterAVPCode = 414
+	DiameterAVPCCRequestNumber               DiameterAVPCode = 415
+	DiameterAVPCCRequestType                 DiameterAVPCode = 416
+	DiameterAVPCCTime                        DiameterAVPCode = 420
+	DiameterAVPCCTotalOctets                 DiameterAVPCode = 421
+	DiameterAVPGrantedServiceUnit            DiameterAVPCode = 431
+	DiameterAVPRatingGroup                   DiameterAVPCode = 432
+	DiameterAVPRequestedServiceUnit          DiameterAVPCode = 437
+	DiameterAVPServiceIdentifier             DiameterAVPCode = 439
+	DiameterAVPSubscriptionID                DiameterAVPCode = 443
+	DiameterAVPSubscriptionIDData            DiameterAVPCode = 444
+	DiameterAVPUsedServiceUnit               DiameterAVPCode = 446
+	DiameterAVPSubscriptionIDType            DiameterAVPCode = 450
+	DiameterAVPMultipleServicesIndicator     DiameterAVPCode = 455
+	DiameterAVPMultipleServicesCreditControl DiameterAVPCode = 456
+	DiameterAVPServiceContextID              DiameterAVPCode = 461
+	DiameterAVPAccountingRecordType          DiameterAVPCode = 480
+	DiameterAVPAccountingRealtimeRequired    DiameterAVPCode = 483
+	DiameterAVPAccountingRecordNumber        DiameterAVPCode = 485
+)
+
+// diameterAVPs holds the name and data format of the known AVPs.
+var diameterAVPs = map[DiameterAVPCode]struct {
+	name     string
+	dataType DiameterDataType
+}{
+	DiameterAVPUserName:                      {"User-Name", DiameterDataUTF8String},
+	DiameterAVPClass:                         {"Class", DiameterDataOctetString},
+	DiameterAVPSessionTimeout:                {"Session-Timeout", DiameterDataUnsigned32},
+	DiameterAVPProxyState:                    {"Proxy-State", DiameterDataOctetString},
+	DiameterAVPAcctSessionID:                 {"Acct-Session-Id", DiameterDataOctetString},
+	DiameterAVPAcctMultiSessionID:            {"Acct-Multi-Session-Id", DiameterDataUTF8String},
+	DiameterAVPEventTimestamp:                {"Event-Timestamp", DiameterDataTime},
+	DiameterAVPAcctInterimInterval:           {"Acct-Interim-Interval", DiameterDataUnsigned32},
+	DiameterAVPHostIPAddress:                 {"Host-IP-Address", DiameterDataAddress},
+	DiameterAVPAuthApplicationID:             {"Auth-Application-Id", DiameterDataUnsigned32},
+	DiameterAVPAcctApplicationID:             {"Acct-Application-Id", DiameterDataUnsigned32},
+	DiameterAVPVendorSpecificApplicationID:   {"Vendor-Specific-Application-Id", DiameterDataGrouped},
+	DiameterAVPRedirectHostUsage:             {"Redirect-Host-Usage", DiameterDataEnumerated},
+	DiameterAVPRedirectMaxCacheTime:          {"Redirect-Max-Cache-Time", DiameterDataUnsigned32},
+	DiameterAVPSessionID:                     {"Session-Id", DiameterDataUTF8String},
+	DiameterAVPOriginHost:                    {"Origin-Host", DiameterDataIdentity},
+	DiameterAVPSupportedVendorID:             {"Supported-Vendor-Id", DiameterDataUnsigned32},
+	DiameterAVPVendorID:                      {"Vendor-Id", DiameterDataUnsigned32},
+	DiameterAVPFirmwareRevision:              {"Firmware-Revision", DiameterDataUnsigned32},
+	DiameterAVPResultCode:                    {"Result-Code", DiameterDataUnsigned32},
+	DiameterAVPProductName:                   {"Product-Name", DiameterDataUTF8String},
+	DiameterAVPSessionBinding:                {"Session-Binding", DiameterDataUnsigned32},
+	DiameterAVPSessionServerFailover:         {"Session-Server-Failover", DiameterDataEnumerated},
+	DiameterAVPMultiRoundTimeOut:             {"Multi-Round-Time-Out", DiameterDataUnsigned32},
+	DiameterAVPDisconnectCause:               {"Disconnect-Cause", DiameterDataEnumerated},
+	DiameterAVPAuthRequestType:               {"Auth-Request-Type", DiameterDataEnumerated},
+	DiameterAVPAuthGracePeriod:               {"Auth-Grace-Period", DiameterDataUnsigned32},
+	DiameterAVPAuthSessionState:              {"Auth-Session-State", DiameterDataEnumerated},
+	DiameterAVPOriginStateID:                 {"Origin-State-Id", DiameterDataUnsigned32},
+	DiameterAVPFailedAVP:                     {"Failed-AVP", DiameterDataGrouped},
+	DiameterAVPProxyHost:                     {"Proxy-Host", DiameterDataIdentity},
+	DiameterAVPErrorMessage:                  {"Error-Message", DiameterDataUTF8String},
+	DiameterAVPRouteRecord:                   {"Route-Record", DiameterDataIdentity},
+	DiameterAVPDestinationRealm:              {"Destination-Realm", DiameterDataIdentity},
+	DiameterAVPProxyInfo:                     {"Proxy-Info", DiameterDataGrouped},
+	DiameterAVPReAuthRequestType:             {"Re-Auth-Request-Type", DiameterDataEnumerated},
+	DiameterAVPAccountingSubSessionID:        {"Accounting-Sub-Session-Id", DiameterDataUnsigned64},
+	DiameterAVPAuthorizationLifetime:         {"Authorization-Lifetime", DiameterDataUnsigned32},
+	DiameterAVPRedirectHost:                  {"Redirect-Host", DiameterDataURI},
+	DiameterAVPDestinationHost:               {"Destination-Host", DiameterDataIdentity},
+	DiameterAVPErrorReportingHost:            {"Error-Reporting-Host", DiameterDataIdentity},
+	DiameterAVPTerminationCause:              {"Termination-Cause", DiameterDataEnumerated},
+	DiameterAVPOriginRealm:                   {"Origin-Realm", DiameterDataIdentity},
+	DiameterAVPExperimentalResult:            {"Experimental-Result", DiameterDataGrouped},
+	DiameterAVPExperimentalResultCode:        {"Experimental-Result-Code", DiameterDataUnsigned32},
+	DiameterAVPInbandSecurityID:              {"Inband-Security-Id", DiameterDataUnsigned32},
+	DiameterAVPCCInputOctets:                 {"CC-Input-Octets", DiameterDataUnsigned64},
+	DiameterAVPCCOutputOctets:                {"CC-Output-Octets", DiameterDataUnsigned64},
+	DiameterAVPCCRequestNumber:               {"CC-Request-Number", DiameterDataUnsigned32},
+	DiameterAVPCCRequestType:                 {"CC-Request-Type", DiameterDataEnumerated},
+	DiameterAVPCCTime:                        {"CC-Time", DiameterDataUnsigned32},
+	DiameterAVPCCTotalOctets:                 {"CC-Total-Octets", DiameterDataUnsigned64},
+	DiameterAVPGrantedServiceUnit:            {"Granted-Service-Unit", DiameterDataGrouped},
+	DiameterAVPRatingGroup:                   {"Rating-Group", DiameterDataUnsigned32},
+	DiameterAVPRequestedServiceUnit:          {"Requested-Service-Unit", DiameterDataGrouped},
+	DiameterAVPServiceIdentifier:             {"Service-Identifier", DiameterDataUnsigned32},
+	DiameterAVPSubscriptionID:                {"Subscription-Id", DiameterDataGrouped},
+	DiameterAVPSubscriptionIDData:            {"Subscription-Id-Data", DiameterDataUTF8String},
+	DiameterAVPUsedServiceUnit:               {"Used-Service-Unit", DiameterDataGrouped},
+	DiameterAVPSubscriptionIDType:            {"Subscription-Id-Type", DiameterDataEnumerated},
+	DiameterAVPMultipleServicesIndicator:     {"Multiple-Services-Indicator", DiameterDataEnumerated},
+	DiameterAVPMultipleServicesCreditControl: {"Multiple-Services-Credit-Control", DiameterDataGrouped},
+	DiameterAVPServiceContextID:              {"Service-Context-Id", DiameterDataUTF8String},
+	DiameterAVPAccountingRecordType:          {"Accounting-Record-Type", DiameterDataEnumerated},
+	DiameterAVPAccountingRealtimeRequired:    {"Accounting-Realtime-Required", DiameterDataEnumerated},
+	DiameterAVPAccountingRecordNumber:        {"Accounting-Record-Number", DiameterDataUnsigned32},
+}
+
+func (c DiameterAVPCode) String() string {
+	if a, ok := diameterAVPs[c]; ok {
+		return a.name
+	}
+	return fmt.Sprintf("Unknown(%d)", uint32(c))
+}
+
+// DataType returns the data format of the AVPs of the code without a
+// vendor ID.
+func (c DiameterAVPCode) DataType() DiameterDataType {
+	return diameterAVPs[c].dataType
+}
+
+type diameterVendorAVP struct {
+	vendorID uint32
+	code     DiameterAVPCode
+}
+
+// diameterGroupedAVPs holds the vendor specific AVPs registered as grouped.
+var diameterGroupedAVPs = map[diameterVendorAVP]bool{}
+
+// RegisterDiameterGroupedAVP makes the AVPs of a vendor with the given code
+// decoded as grouped AVPs, such as those of the 3GPP (vendor 10415).
+func RegisterDiameterGroupedAVP(vendorID uint32, code DiameterAVPCode) {
+	diameterGroupedAVPs[diameterVendorAVP{vendorID, code}] = true
+}
+
+// DiameterAVPFlags are the flags of an AVP.
+type DiameterAVPFlags uint8
+
+// DiameterAVPFlags known values.
+const (
+	DiameterAVPFlagVendor    DiameterAVPFlags = 0x80
+	DiameterAVPFlagMandatory DiameterAVPFlags = 0x40
+	DiameterAVPFlagProtected DiameterAVPFlags = 0x20
+)
+
+// DiameterAVP is an attribute-value pair of a Diameter message.
+//
+// The AVPs of grouped AVPs, known or registered with
+// RegisterDiameterGroupedAVP, are decoded into AVPs, and serialized instead
+// of Data when there are some. The vendor flag is serialized when VendorID
+// is set.
+type DiameterAVP struct {
+	Code DiameterAVPCode
+	// Length is the length of the AVP header and data, without padding.
+	Length   uint32
+	Flags    DiameterAVPFlags
+	VendorID uint32
+	Data     []byte
+	AVPs     []DiameterAVP
+}
+
+// Grouped returns whether the AVP is a grouped AVP.
+func (a *DiameterAVP) Grouped() bool {
+	if a.Flags&DiameterAVPFlagVendor != 0 && a.VendorID != 0 {
+		return diameterGroupedAVPs[diameterVendorAVP{a.VendorID, a.Code}]
+	}
+	return a.Code.DataType() == DiameterDataGrouped
+}
+
+// AVP returns the first AVP of a grouped AVP with the given code and no
+// vendor ID, or nil.
+func (a *DiameterAVP) AVP(code DiameterAVPCode) *DiameterAVP {
+	return findDiameterAVP(a.AVPs, code)
+}
+
+func findDiameterAVP(avps []DiameterAVP, code DiameterAVPCode) *DiameterAVP {
+	for i := range avps {
+		if avps[i].Code == code && avps[i].VendorID == 0 {
+			return &avps[i]
+		}
+	}
+	return nil
+}
+
+// Uint32 returns the data of an Unsigned32 or Enumerated AVP.
+func (a *DiameterAVP) Uint32() (uint32, error) {
+	if len(a.Data) != 4 {
+		return 0, fmt.Errorf("Diameter AVP %d data of %d bytes is not 32 bits", a.Code, len(a.Data))
+	}
+	return binary.BigEndian.Uint32(a.Data), nil
+}
+
+// Uint64 returns the data of an Unsigned64 AVP.
+func (a *DiameterAVP) Uint64() (uint64, error) {
+	if len(a.Data) != 8 {
+		return 0, fmt.Errorf("Diameter AVP %d data of %d bytes is not 64 bits", a.Code, len(a.Data))
+	}
+	return binary.BigEndian.Uint64(a.Data), nil
+}
+
+// Int32 returns the data of an Integer32 AVP.
+func (a *DiameterAVP) Int32() (int32, error) {
+	v, err := a.Uint32()
+	return int32(v), err
+}
+
+// Int64 returns the data of an Integer64 AVP.
+func (a *DiameterAVP) Int64() (int64, error) {
+	v, err := a.Uint64()
+	return int64(v), err
+}
+
+// Address returns the data of an IPv4 or IPv6 Address AVP.
+func (a *DiameterAVP) Address() (net.IP, error) {
+	if len(a.Data) == 2+net.IPv4len && binary.BigEndian.Uint16(a.Data) == 1 ||
+		len(a.Data) == 2+net.IPv6len && binary.BigEndian.Uint16(a.Data) == 2 {
+		return net.IP(a.Data[2:]), nil
+	}
+	return nil, fmt.Errorf("Diameter AVP %d data is not an IP address", a.Code)
+}
+
+// Time returns the data of a Time AVP, a NTP timestamp.
+func (a *DiameterAVP) Time() (time.Time, error) {
+	if len(a.Data) != 4 {
+		return time.Time{}, fmt.Errorf("Diameter AVP %d data of %d bytes is not a time", a.Code, len(a.Data))
+	}
+	s := int64(binary.BigEndian.Uint32(a.Data))
+	if s < 0x80000000 {
+		// after February 2036, in the next NTP era
+		s += 1 << 32
+	}
+	return time.Unix(s-2208988800, 0).UTC(), nil
+}
+
+// DiameterMessage is a Diameter message, as specified in RFC 6733 section 3.
+type DiameterMessage struct {
+	Version       uint8
+	Length        uint32
+	Flags         DiameterFlags
+	CommandCode   DiameterCommandCode
+	ApplicationID DiameterApplicationID
+	HopByHopID    uint32
+	EndToEndID    uint32
+	AVPs          []DiameterAVP
+}
+
+// Request returns whether the message is a request rather than an answer.
+func (m *DiameterMessage) Request() bool {
+	return m.Flags&DiameterFlagRequest != 0
+}
+
+// AVP returns the first AVP of the message with the given code and no vendor
+// ID, or nil.
+func (m *DiameterMessage) AVP(code DiameterAVPCode) *DiameterAVP {
+	return findDiameterAVP(m.AVPs, code)
+}
+
+// Diameter holds the Diameter messages of a TCP segment or of a SCTP data
+// chunk, as specified in RFC 6733.
+//
+// Only the messages complete in the decoded data are decoded; a message
+// whose end is missing marks the layer as truncated.
+type Diameter struct {
+	BaseLayer
+	Messages []DiameterMessage
+}
+
+// LayerType returns gopacket.LayerTypeDiameter.
+func (d *Diameter) LayerType() gopacket.LayerType { return LayerTypeDiameter }
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode
+func (d *Diameter) CanDecode() gopacket.LayerClass { return LayerTypeDiameter }
+
+// NextLayerType returns the layer type contained by this DecodingLayer
+func (d *Diameter) NextLayerType() gopacket.LayerType { return gopacket.LayerTypeZero }
+
+// Payload returns nil.
+func (d *Diameter) Payload() []byte { return nil }
+
+func decodeDiameter(data []byte, p gopacket.PacketBuilder) error {
+	d := &Diameter{}
+	err := d.DecodeFromBytes(data, p)
+	if err != nil {
+		return err
+	}
+	p.AddLayer(d)
+	p.SetApplicationLayer(d)
+	return nil
+}
+
+// DecodeFromBytes decodes the slice into the Diameter struct.
+func (d *Diameter) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	d.Messages = d.Messages[:0]
+
+	rest := data
+	for len(rest) >= diameterHeaderLength {
+		if rest[0] != 1 {
+			return fmt.Errorf("unknown Diameter version %d", rest[0])
+		}
+		length := int(binary.BigEndian.Uint32(rest) & 0xffffff)
+		if length < diameterHeaderLength || length%4 != 0 {
+			return fmt.Errorf("invalid Diameter message length %d", length)
+		}
+		if len(rest) < length {
+			break
+		}
+		var m DiameterMessage
+		if err := m.decodeFromBytes(rest[:length]); err != nil {
+			return err
+		}
+		d.Messages = append(d.Messages, m)
+		rest = rest[length:]
+	}
+	if len(rest) > 0 {
+		df.SetTruncated()
+		if len(d.Messages) == 0 {
+			return errors.New("Diameter message truncated")
+		}
+	}
+	d.BaseLayer = BaseLayer{Contents: data[:len(data)-len(rest)]}
+	return nil
+}
+
+func (m *DiameterMessage) decodeFromBytes(data []byte) error {
+	m.Version = data[0]
+	m.Length = uint32(len(data))
+	m.Flags = DiameterFlags(data[4])
+	m.CommandCode = DiameterCommandCode(binary.BigEndian.Uint32(data[4:8]) & 0xffffff)
+	m.ApplicationID = DiameterApplicationID(binary.BigEndian.Uint32(data[8:12]))
+	m.HopByHopID = binary.BigEndian.Uint32(data[12:16])
+	m.EndToEndID = binary.BigEndian.Uint32(data[16:20])
+	var err error
+	m.AVPs, err = decodeDiameterAVPs(data[diameterHeaderLength:])
+	return err
+}
+
+func decodeDiameterAVPs(data []byte) ([]DiameterAVP, error) {
+	var avps []DiameterAVP
+	for len(data) > 0 {
+		if len(data) < 8 {
+			return nil, errors.New("Diameter AVP header truncated")
+		}
+		a := DiameterAVP{
+			Code:   DiameterAVPCode(binary.BigEndian.Uint32(data[0:4])),
+			Flags:  DiameterAVPFlags(data[4]),
+			Length: binary.BigEndian.Uint32(data[4:8]) & 0xffffff,
+		}
+		header := 8
+		if a.Flags&DiameterAVPFlagVendor != 0 {
+			header = 12
+		}
+		if int(a.Length) < header || int(a.Length) > len(data) {
+			return nil, fmt.Errorf("invalid Diameter AVP %d length %d", a.Code, a.Length)
+		}
+		if header == 12 {
+			a.VendorID = binary.BigEndian.Uint32(data[8:12])
+		}
+		a.Data = data[header:a.Length]
+		if a.Grouped() {
+			var err error
+			if a.AVPs, err = decodeDiameterAVPs(a.Data); err != nil {
+				return nil, fmt.Errorf("Diameter AVP %v: %v", a.Code, err)
+			}
+		}
+		avps = append(avps, a)
+
+		// the padding of the last AVP may be missing
+		next := (int(a.Length) + 3) &^ 3
+		if next > len(data) {
+			next = len(data)
+		}
+		data = data[next:]
+	}
+	return avps, nil
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+func (d *Diameter) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	var data []byte
+	for i := range d.Messages {
+		var err error
+		if data, err = d.Messages[i].appendTo(data, opts); err != nil {
+			return err
+		}
+	}
+	bytes, err := b.PrependBytes(len(data))
+	if err != nil {
+		return err
+	}
+	copy(bytes, data)
+	return nil
+}
+
+func (m *DiameterMessage) appendTo(b []byte, opts gopacket.SerializeOptions) ([]byte, error) {
+	start := len(b)
+	b = append(b, make([]byte, diameterHeaderLength)...)
+	for i := range m.AVPs {
+		var err error
+		if b, err = m.AVPs[i].appendTo(b, opts); err != nil {
+			return nil, err
+		}
+	}
+	if len(b)-start > 0xffffff {
+		return nil, fmt.Errorf("Diameter message too long: %d bytes", len(b)-start)
+	}
+	if opts.FixLengths {
+		m.Length = uint32(len(b) - start)
+	}
+	h := b[start:]
+	binary.BigEndian.PutUint32(h[0:4], uint32(m.Version)<<24|m.Length&0xffffff)
+	binary.BigEndian.PutUint32(h[4:8], uint32(m.Flags)<<24|uint32(m.CommandCode)&0xffffff)
+	binary.BigEndian.PutUint32(h[8:12], uint32(m.ApplicationID))
+	binary.BigEndian.PutUint32(h[12:16], m.HopByHopID)
+	binary.BigEndian.PutUint32(h[16:20], m.EndToEndID)
+	return b, nil
+}
+
+func (a *DiameterAVP) appendTo(b []byte, opts gopacket.SerializeOptions) ([]byte, error) {
+	start := len(b)
+	flags := a.Flags
+	if a.VendorID != 0 {
+		flags |= DiameterAVPFlagVendor
+	}
+	b = append(b, make([]byte, 8)...)
+	if flags&DiameterAVPFlagVendor != 0 {
+		b = append(b, byte(a.VendorID>>24), byte(a.VendorID>>16), byte(a.VendorID>>8), byte(a.VendorID))
+	}
+	if len(a.AVPs) > 0 {
+		for i := range a.AVPs {
+			var err error
+			if b, err = a.AVPs[i].appendTo(b, opts); err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		b = append(b, a.Data...)
+	}
+	length := len(b) - start
+	if length > 0xffffff {
+		return nil, fmt.Errorf("Diameter AVP %d too long: %d bytes", a.Code, length)
+	}
+	if opts.FixLengths {
+		a.Length = uint32(length)
+	}
+	binary.BigEndian.PutUint32(b[start:], uint32(a.Code))
+	binary.BigEndian.PutUint32(b[start+4:], uint32(flags)<<24|a.Length&0xffffff)
+	for (len(b)-start)%4 != 0 {
+		b = append(b, 0)
+	}
+	return b, nil
+}
@@ -0,0 +1,186 @@
+// Copyright 2026 The GoPacket Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+)
+
+// A Device-Watchdog-Request from a.b of the realm b.
+var testDiameterDWR = mustDecodeHex("0100002c" + "80000118" + "00000000" + "00000001" + "00000002" +
+	"000001084000000b612e6200" + "000001284000000962000000")
+
+func TestDiameterDecode(t *testing.T) {
+	// a segment with a whole message, and the start of another one
+	data := append(append([]byte(nil), testDiameterDWR...), testDiameterDWR[:30]...)
+	p := gopacket.NewPacket(data, LayerTypeDiameter, gopacket.Default)
+	d, ok := p.Layer(LayerTypeDiameter).(*Diameter)
+	if !ok || p.ApplicationLayer() != d || !p.Metadata().Truncated {
+		t.Fatalf("packet: %v", p)
+	}
+	if len(d.Messages) != 1 || len(d.Contents) != len(testDiameterDWR) {
+		t.Fatalf("%d messages in %d bytes", len(d.Messages), len(d.Contents))
+	}
+	m := d.Messages[0]
+	if m.Version != 1 || m.Length != 44 || !m.Request() || m.CommandCode != DiameterCommandDeviceWatchdog ||
+		m.ApplicationID != DiameterApplicationCommon || m.HopByHopID != 1 || m.EndToEndID != 2 || len(m.AVPs) != 2 {
+		t.Errorf("message: %+v", m)
+	}
+	if a := m.AVP(DiameterAVPOriginHost); a == nil || string(a.Data) != "a.b" || a.Length != 11 || a.Flags != DiameterAVPFlagMandatory {
+		t.Errorf("Origin-Host: %+v", a)
+	}
+	if a := m.AVP(DiameterAVPOriginRealm); a == nil || string(a.Data) != "b" || a.Code.DataType() != DiameterDataIdentity {
+		t.Errorf("Origin-Realm: %+v", a)
+	}
+
+	for _, data := range [][]byte{
+		testDiameterDWR[:19],
+		append([]byte{2}, testDiameterDWR[1:]...),
+		// AVP length beyond the message
+		append(append([]byte(nil), testDiameterDWR[:27]...), append([]byte{0x20}, testDiameterDWR[28:]...)...),
+	} {
+		if p := gopacket.NewPacket(data, LayerTypeDiameter, gopacket.Default); p.ErrorLayer() == nil {
+			t.Errorf("%x: no error", data)
+		}
+	}
+}
+
+func TestDiameterSerialize(t *testing.T) {
+	RegisterDiameterGroupedAVP(10415, 628) // 3GPP Supported-Features
+	timestamp := make([]byte, 4)
+	binary.BigEndian.PutUint32(timestamp, uint32(time.Date(2040, 1, 1, 0, 0, 0, 0, time.UTC).Unix()+2208988800))
+	u32 := func(v uint32) []byte {
+		b := make([]byte, 4)
+		binary.BigEndian.PutUint32(b, v)
+		return b
+	}
+	cer := DiameterMessage{
+		Version:     1,
+		Flags:       DiameterFlagRequest,
+		CommandCode: DiameterCommandCapabilitiesExchange,
+		HopByHopID:  0x1234,
+		EndToEndID:  0x5678,
+		AVPs: []DiameterAVP{
+			{Code: DiameterAVPOriginHost, Flags: DiameterAVPFlagMandatory, Data: []byte("pcrf.example.com")},
+			{Code: DiameterAVPHostIPAddress, Flags: DiameterAVPFlagMandatory, Data: append([]byte{0, 2}, net.ParseIP("2001:db8::1")...)},
+			{Code: DiameterAVPEventTimestamp, Data: timestamp},
+			{Code: DiameterAVPVendorSpecificApplicationID, Flags: DiameterAVPFlagMandatory, AVPs: []DiameterAVP{
+				{Code: DiameterAVPVendorID, Flags: DiameterAVPFlagMandatory, Data: u32(10415)},
+				{Code: DiameterAVPAuthApplicationID, Flags: DiameterAVPFlagMandatory, Data: u32(16777238)},
+			}},
+			{Code: 628, VendorID: 10415, AVPs: []DiameterAVP{
+				{Code: DiameterAVPVendorID, Data: u32(10415)},
+				{Code: 629, VendorID: 10415, Data: u32(1)},
+			}},
+		},
+	}
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true}, &Diameter{Messages: []DiameterMessage{cer}}); err != nil {
+		t.Fatal(err)
+	}
+	data := append([]byte(nil), buf.Bytes()...)
+	if len(data)%4 != 0 {
+		t.Fatalf("message of %d bytes", len(data))
+	}
+
+	// over TCP
+	tcp := &TCP{SrcPort: 40000, DstPort: 3868}
+	buf = gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true}, tcp, gopacket.Payload(data)); err != nil {
+		t.Fatal(err)
+	}
+	p := gopacket.NewPacket(buf.Bytes(), LayerTypeTCP, gopacket.DecodeOptions{DecodeStreamsAsDatagrams: true})
+	d, ok := p.Layer(LayerTypeDiameter).(*Diameter)
+	if !ok || len(d.Messages) != 1 {
+		t.Fatalf("packet: %v", p)
+	}
+	m := d.Messages[0]
+	if int(m.Length) != len(data) || m.CommandCode != DiameterCommandCapabilitiesExchange || len(m.AVPs) != 5 {
+		t.Fatalf("message: %+v", m)
+	}
+	if ip, err := m.AVP(DiameterAVPHostIPAddress).Address(); err != nil || !ip.Equal(net.ParseIP("2001:db8::1")) {
+		t.Errorf("Host-IP-Address: %v, %v", ip, err)
+	}
+	if ts, err := m.AVP(DiameterAVPEventTimestamp).Time(); err != nil || !ts.Equal(time.Date(2040, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Event-Timestamp: %v, %v", ts, err)
+	}
+	app := m.AVP(DiameterAVPVendorSpecificApplicationID)
+	if id, err := app.AVP(DiameterAVPAuthApplicationID).Uint32(); len(app.AVPs) != 2 || err != nil || id != 16777238 {
+		t.Errorf("Vendor-Specific-Application-Id: %+v", app)
+	}
+	if f := m.AVPs[4]; f.Flags != DiameterAVPFlagVendor || f.VendorID != 10415 || len(f.AVPs) != 2 || f.AVPs[1].VendorID != 10415 {
+		t.Errorf("Supported-Features: %+v", f)
+	}
+
+	// over SCTP, in a whole user message with the Diameter payload protocol
+	for _, chunk := range []SCTPData{
+		{SCTPChunk: SCTPChunk{Type: SCTPChunkTypeData}, BeginFragment: true, EndFragment: true, PayloadProtocol: SCTPPayloadDiameter},
+		{SCTPChunk: SCTPChunk{Type: SCTPChunkTypeData}, BeginFragment: true, PayloadProtocol: SCTPPayloadDiameter},
+	} {
+		buf = gopacket.NewSerializeBuffer()
+		if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{}, &SCTP{SrcPort: 3868, DstPort: 3868}, &chunk, gopacket.Payload(data)); err != nil {
+			t.Fatal(err)
+		}
+		p = gopacket.NewPacket(buf.Bytes(), LayerTypeSCTP, gopacket.Default)
+		d, ok := p.Layer(LayerTypeDiameter).(*Diameter)
+		if whole := chunk.EndFragment; ok != whole || whole && len(d.Messages) != 1 {
+			t.Errorf("SCTP packet: %v", p)
+		}
+	}
+
+	// a decoded message serializes to the same bytes
+	buf = gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{}, d); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), data) {
+		t.Errorf("reserialized as %x, want %x", buf.Bytes(), data)
+	}
+}
+
+func TestDiameterSCTPBundled(t *testing.T) {
+	data := func(ppid SCTPPayloadProtocol, tsn byte) []byte {
+		c := []byte{0, 3, 0, byte(16 + len(testDiameterDWR)), 0, 0, 0, tsn, 0, 0, 0, tsn, 0, 0, 0, byte(ppid)}
+		return append(c, testDiameterDWR...)
+	}
+	sack := []byte{3, 0, 0, 16, 0, 0, 0, 1, 0, 0, 0x10, 0, 0, 0, 0, 0}
+	packet := func(port SCTPPort, chunks ...[]byte) []byte {
+		b := []byte{0x9c, 0x40, byte(port >> 8), byte(port), 0, 0, 0, 1, 0, 0, 0, 0}
+		for _, c := range chunks {
+			b = append(b, c...)
+		}
+		return b
+	}
+	for _, test := range []struct {
+		name     string
+		packet   []byte
+		diameter bool
+	}{
+		{"two Data chunks", packet(5000, data(SCTPPayloadDiameter, 1), data(SCTPPayloadDiameter, 2)), true},
+		{"Sack then Data without payload protocol", packet(3868, sack, data(0, 1)), true},
+		{"Data without payload protocol on another port", packet(5000, data(0, 1), data(0, 2)), false},
+	} {
+		p := gopacket.NewPacket(test.packet, LayerTypeSCTP, gopacket.Default)
+		if p.ErrorLayer() != nil {
+			t.Errorf("%s: %v", test.name, p.ErrorLayer().Error())
+			continue
+		}
+		d, ok := p.Layer(LayerTypeDiameter).(*Diameter)
+		if ok != test.diameter || ok && (len(d.Messages) != 1 || !bytes.Equal(d.Contents, testDiameterDWR)) {
+			t.Errorf("%s: %v", test.name, p)
+		}
+		if !test.diameter && !bytes.Equal(p.ApplicationLayer().Payload(), testDiameterDWR) {
+			t.Errorf("%s: payload %x", test.name, p.ApplicationLayer().Payload())
+		}
+	}
+}
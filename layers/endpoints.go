@@ -45,6 +45,11 @@ var (
 	EndpointPPP = gopacket.RegisterEndpointType(9, gopacket.EndpointTypeMetadata{Name: "PPP", Formatter: func([]byte) string {
 		return "point"
 	}})
+	// EndpointIPProtocol is the transport endpoint of IP protocols without
+	// ports, like ICMP: both endpoints of its flows are the protocol.
+	EndpointIPProtocol = gopacket.RegisterEndpointType(10, gopacket.EndpointTypeMetadata{Name: "IPProtocol", Formatter: func(b []byte) string {
+		return IPProtocol(b[0]).String()
+	}})
 )
 
 // NewIPEndpoint creates a new IP (v4 or v6) endpoint from a net.IP address.
@@ -63,6 +68,11 @@ func NewIPEndpoint(a net.IP) gopacket.Endpoint {
 	return gopacket.InvalidEndpoint
 }
 
+// NewIPProtocolEndpoint returns an endpoint based on an IP protocol number.
+func NewIPProtocolEndpoint(p IPProtocol) gopacket.Endpoint {
+	return gopacket.NewEndpoint(EndpointIPProtocol, []byte{byte(p)})
+}
+
 // NewMACEndpoint returns a new MAC address endpoint.
 func NewMACEndpoint(a net.HardwareAddr) gopacket.Endpoint {
 	return gopacket.NewEndpoint(EndpointMAC, []byte(a))
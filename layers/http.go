@@ -0,0 +1,388 @@
+// Copyright 2026 The GoPacket Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/google/gopacket"
+)
+
+// HTTPHeader is a header field of an HTTP message.
+type HTTPHeader struct {
+	Name  string
+	Value string
+}
+
+// HTTP is an HTTP/1.0 or HTTP/1.1 request or response, as specified in
+// RFC 7230.
+//
+// Contents holds the start line and the header fields, up to the empty line
+// ending them. Payload holds the message body found in the decoded data,
+// with the chunked transfer coding removed. Messages whose body is not
+// complete in the data are decoded with the part of the body present, and
+// reported as truncated; the reassembly/httpstream package decodes messages
+// spanning several TCP segments.
+//
+// As nothing tells how long the body of a response without a Content-Length
+// or a chunked Transfer-Encoding is, all the data following its header is
+// taken as its body. That is wrong for responses to HEAD requests, which
+// have no body; the stream parser knows about them.
+//
+// HTTP is not registered on any TCP port by default, as most segments do not
+// start with a message. It can be with:
+//
+//	layers.RegisterTCPPortLayerType(80, layers.LayerTypeHTTP)
+type HTTP struct {
+	BaseLayer
+
+	IsResponse bool
+	// Version is the protocol version of the start line, such as
+	// "HTTP/1.1".
+	Version string
+
+	// Request
+	Method     string
+	RequestURI string
+
+	// Response
+	StatusCode int
+	Reason     string
+
+	// Headers are the header fields in the order of the message. Field
+	// values folded over several lines are unfolded.
+	Headers []HTTPHeader
+	// Trailers are the trailer fields following a chunked body.
+	Trailers []HTTPHeader
+}
+
+var errHTTPHeaderIncomplete = errors.New("HTTP header incomplete")
+
+// decodeHTTP decodes the byte slice into an HTTP type. It also
+// setups the application Layer in PacketBuilder.
+func decodeHTTP(data []byte, p gopacket.PacketBuilder) error {
+	h := &HTTP{}
+	err := h.DecodeFromBytes(data, p)
+	if err != nil {
+		return err
+	}
+	p.AddLayer(h)
+	p.SetApplicationLayer(h)
+	return nil
+}
+
+// LayerType returns gopacket.LayerTypeHTTP.
+func (h *HTTP) LayerType() gopacket.LayerType { return LayerTypeHTTP }
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode
+func (h *HTTP) CanDecode() gopacket.LayerClass { return LayerTypeHTTP }
+
+// NextLayerType returns the layer type contained by this DecodingLayer
+func (h *HTTP) NextLayerType() gopacket.LayerType { return gopacket.LayerTypePayload }
+
+// Payload returns the message body.
+func (h *HTTP) Payload() []byte { return h.BaseLayer.Payload }
+
+// DecodeFromBytes decodes the slice into the HTTP struct.
+func (h *HTTP) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	// empty lines may precede a message
+	start := 0
+	for start < len(data) && (data[start] == '\r' || data[start] == '\n') {
+		start++
+	}
+	headLen := HTTPHeaderLength(data[start:])
+	if headLen < 0 {
+		df.SetTruncated()
+		return errHTTPHeaderIncomplete
+	}
+	if err := h.decodeHead(data[start : start+headLen]); err != nil {
+		return err
+	}
+	h.Contents = data[:start+headLen]
+	h.BaseLayer.Payload = nil
+	body := data[start+headLen:]
+
+	switch {
+	case !h.HasBody(""):
+	case h.Chunked():
+		var truncated bool
+		h.BaseLayer.Payload, h.Trailers, truncated = decodeHTTPChunked(body)
+		if truncated {
+			df.SetTruncated()
+		}
+	default:
+		length, ok := h.ContentLength()
+		if !ok {
+			if h.IsResponse {
+				// delimited by the end of the connection
+				h.BaseLayer.Payload = body
+			}
+			break
+		}
+		if int64(len(body)) < length {
+			df.SetTruncated()
+			length = int64(len(body))
+		}
+		h.BaseLayer.Payload = body[:length]
+	}
+	return nil
+}
+
+// HTTPHeaderLength returns the length of the start line and header fields of
+// the HTTP message data starts with, including the empty line ending them,
+// or -1 if it is not complete. Lines may end with a bare LF.
+func HTTPHeaderLength(data []byte) int {
+	for i := 0; ; {
+		eol := bytes.IndexByte(data[i:], '\n')
+		if eol < 0 {
+			return -1
+		}
+		// i is the start of the next line, which may be the empty one
+		i += eol + 1
+		switch {
+		case i < len(data) && data[i] == '\n':
+			return i + 1
+		case i+1 < len(data) && data[i] == '\r' && data[i+1] == '\n':
+			return i + 2
+		}
+	}
+}
+
+// decodeHead decodes the start line and header fields.
+func (h *HTTP) decodeHead(head []byte) error {
+	h.IsResponse = false
+	h.Version, h.Method, h.RequestURI, h.Reason = "", "", "", ""
+	h.StatusCode = 0
+	h.Headers = h.Headers[:0]
+	h.Trailers = h.Trailers[:0]
+
+	lines := strings.Split(strings.TrimRight(string(head), "\r\n"), "\n")
+	for i := range lines {
+		lines[i] = strings.TrimSuffix(lines[i], "\r")
+	}
+	if err := h.decodeStartLine(lines[0]); err != nil {
+		return err
+	}
+	headers, err := appendHTTPHeaders(h.Headers, lines[1:])
+	h.Headers = headers
+	return err
+}
+
+func (h *HTTP) decodeStartLine(line string) error {
+	if strings.HasPrefix(line, "HTTP/") {
+		// HTTP/1.1 200 OK
+		parts := strings.SplitN(line, " ", 3)
+		if len(parts) < 2 || !validHTTPVersion(parts[0]) || len(parts[1]) != 3 {
+			return fmt.Errorf("invalid HTTP status line %q", line)
+		}
+		code, err := strconv.Atoi(parts[1])
+		if err != nil || code < 100 {
+			return fmt.Errorf("invalid HTTP status code %q", parts[1])
+		}
+		h.IsResponse = true
+		h.Version = parts[0]
+		h.StatusCode = code
+		if len(parts) == 3 {
+			h.Reason = parts[2]
+		}
+		return nil
+	}
+
+	// GET /index.html HTTP/1.1
+	parts := strings.Split(line, " ")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || !validHTTPVersion(parts[2]) {
+		return fmt.Errorf("invalid HTTP request line %q", line)
+	}
+	for _, c := range parts[0] {
+		if c <= ' ' || c >= 0x7f || strings.ContainsRune("()<>@,;:\\\"/[]?={}", c) {
+			return fmt.Errorf("invalid HTTP method %q", parts[0])
+		}
+	}
+	h.Method = parts[0]
+	h.RequestURI = parts[1]
+	h.Version = parts[2]
+	return nil
+}
+
+func validHTTPVersion(v string) bool {
+	return len(v) == 8 && strings.HasPrefix(v, "HTTP/1.") && v[7] >= '0' && v[7] <= '9'
+}
+
+// appendHTTPHeaders appends the header fields of lines to headers.
+func appendHTTPHeaders(headers []HTTPHeader, lines []string) ([]HTTPHeader, error) {
+	for _, line := range lines {
+		if line == "" {
+			continue
+		}
+		if line[0] == ' ' || line[0] == '\t' {
+			// obsolete line folding
+			if len(headers) == 0 {
+				return headers, fmt.Errorf("invalid HTTP header line %q", line)
+			}
+			last := &headers[len(headers)-1]
+			last.Value += " " + strings.TrimSpace(line)
+			continue
+		}
+		colon := strings.IndexByte(line, ':')
+		if colon <= 0 || strings.ContainsAny(line[:colon], " \t") {
+			return headers, fmt.Errorf("invalid HTTP header line %q", line)
+		}
+		headers = append(headers, HTTPHeader{
+			Name:  line[:colon],
+			Value: strings.TrimSpace(line[colon+1:]),
+		})
+	}
+	return headers, nil
+}
+
+// Header returns the value of the first header field called name, compared
+// case-insensitively, or "" if there is none.
+func (h *HTTP) Header(name string) string {
+	for _, f := range h.Headers {
+		if strings.EqualFold(f.Name, name) {
+			return f.Value
+		}
+	}
+	return ""
+}
+
+// HeaderValues returns the values of all the header fields called name.
+func (h *HTTP) HeaderValues(name string) []string {
+	var values []string
+	for _, f := range h.Headers {
+		if strings.EqualFold(f.Name, name) {
+			values = append(values, f.Value)
+		}
+	}
+	return values
+}
+
+// ContentLength returns the value of the Content-Length header field, and
+// whether there is a valid one. Repeated fields and list values are allowed,
+// as long as they all agree.
+func (h *HTTP) ContentLength() (int64, bool) {
+	var length int64 = -1
+	for _, field := range h.HeaderValues("Content-Length") {
+		for _, v := range strings.Split(field, ",") {
+			n, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+			if err != nil || n < 0 || (length >= 0 && n != length) {
+				return 0, false
+			}
+			length = n
+		}
+	}
+	if length < 0 {
+		return 0, false
+	}
+	return length, true
+}
+
+// Chunked returns whether the last transfer coding of the message is
+// chunked.
+func (h *HTTP) Chunked() bool {
+	codings := h.HeaderValues("Transfer-Encoding")
+	if len(codings) == 0 {
+		return false
+	}
+	list := strings.Split(codings[len(codings)-1], ",")
+	return strings.EqualFold(strings.TrimSpace(list[len(list)-1]), "chunked")
+}
+
+// HasBody returns whether the message has a body, going by RFC 7230 section
+// 3.3.3. For responses, method is the method of the request, if known:
+// responses to HEAD requests, and successful responses to CONNECT, have no
+// body.
+func (h *HTTP) HasBody(method string) bool {
+	if !h.IsResponse {
+		if h.Chunked() {
+			return true
+		}
+		n, ok := h.ContentLength()
+		return ok && n > 0
+	}
+	switch {
+	case h.StatusCode < 200, h.StatusCode == 204, h.StatusCode == 304:
+		return false
+	case method == "HEAD":
+		return false
+	case method == "CONNECT" && h.StatusCode < 300:
+		return false
+	}
+	return true
+}
+
+// ParseHTTPChunkSize parses the size of a chunk of the chunked transfer
+// coding from its line, without the line ending. Chunk extensions are
+// ignored.
+func ParseHTTPChunkSize(line []byte) (int64, error) {
+	if i := bytes.IndexByte(line, ';'); i >= 0 {
+		line = line[:i]
+	}
+	n, err := strconv.ParseInt(string(bytes.TrimSpace(line)), 16, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid HTTP chunk size %q", line)
+	}
+	return n, nil
+}
+
+// ParseHTTPHeaders parses the lines of a trailer section, without the
+// empty line ending it.
+func ParseHTTPHeaders(data []byte) ([]HTTPHeader, error) {
+	lines := strings.Split(strings.TrimRight(string(data), "\r\n"), "\n")
+	for i := range lines {
+		lines[i] = strings.TrimSuffix(lines[i], "\r")
+	}
+	return appendHTTPHeaders(nil, lines)
+}
+
+// decodeHTTPChunked removes the chunked transfer coding from data, returning
+// the body, its trailer fields, and whether data ended before the last
+// chunk.
+func decodeHTTPChunked(data []byte) (body []byte, trailers []HTTPHeader, truncated bool) {
+	body = []byte{}
+	for {
+		eol := bytes.IndexByte(data, '\n')
+		if eol < 0 {
+			return body, nil, true
+		}
+		size, err := ParseHTTPChunkSize(bytes.TrimSuffix(data[:eol], []byte("\r")))
+		if err != nil {
+			return body, nil, true
+		}
+		data = data[eol+1:]
+		if size == 0 {
+			break
+		}
+		if int64(len(data)) < size {
+			return append(body, data...), nil, true
+		}
+		body = append(body, data[:size]...)
+		data = data[size:]
+		// CRLF ending the chunk data
+		if bytes.HasPrefix(data, []byte("\r\n")) {
+			data = data[2:]
+		} else if bytes.HasPrefix(data, []byte("\n")) {
+			data = data[1:]
+		} else {
+			return body, nil, true
+		}
+	}
+	// trailer section, ended by an empty line
+	if bytes.HasPrefix(data, []byte("\r\n")) || bytes.HasPrefix(data, []byte("\n")) {
+		return body, nil, false
+	}
+	end := HTTPHeaderLength(data)
+	if end < 0 {
+		return body, nil, true
+	}
+	trailers, err := ParseHTTPHeaders(data[:end])
+	return body, trailers, err != nil
+}
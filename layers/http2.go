@@ -0,0 +1,392 @@
+// Copyright 2026 The GoPacket Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/google/gopacket"
+)
+
+// HTTP2Preface is the connection preface an HTTP/2 client starts with.
+const HTTP2Preface = "PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n"
+
+// HTTP2FrameHeaderLength is the length of the header of every HTTP/2 frame.
+const HTTP2FrameHeaderLength = 9
+
+// HTTP2FrameType is the type of an HTTP/2 frame.
+type HTTP2FrameType uint8
+
+// HTTP2FrameType known values, from RFC 7540 section 6.
+const (
+	HTTP2FrameData         HTTP2FrameType = 0x0
+	HTTP2FrameHeaders      HTTP2FrameType = 0x1
+	HTTP2FramePriority     HTTP2FrameType = 0x2
+	HTTP2FrameRSTStream    HTTP2FrameType = 0x3
+	HTTP2FrameSettings     HTTP2FrameType = 0x4
+	HTTP2FramePushPromise  HTTP2FrameType = 0x5
+	HTTP2FramePing         HTTP2FrameType = 0x6
+	HTTP2FrameGoAway       HTTP2FrameType = 0x7
+	HTTP2FrameWindowUpdate HTTP2FrameType = 0x8
+	HTTP2FrameContinuation HTTP2FrameType = 0x9
+)
+
+func (t HTTP2FrameType) String() string {
+	switch t {
+	case HTTP2FrameData:
+		return "DATA"
+	case HTTP2FrameHeaders:
+		return "HEADERS"
+	case HTTP2FramePriority:
+		return "PRIORITY"
+	case HTTP2FrameRSTStream:
+		return "RST_STREAM"
+	case HTTP2FrameSettings:
+		return "SETTINGS"
+	case HTTP2FramePushPromise:
+		return "PUSH_PROMISE"
+	case HTTP2FramePing:
+		return "PING"
+	case HTTP2FrameGoAway:
+		return "GOAWAY"
+	case HTTP2FrameWindowUpdate:
+		return "WINDOW_UPDATE"
+	case HTTP2FrameContinuation:
+		return "CONTINUATION"
+	}
+	return fmt.Sprintf("UNKNOWN(%d)", uint8(t))
+}
+
+// HTTP2Flags are the flags of an HTTP/2 frame, whose meaning depends on its
+// type.
+type HTTP2Flags uint8
+
+// HTTP2Flags known values.
+const (
+	// HTTP2FlagEndStream is set on DATA and HEADERS frames.
+	HTTP2FlagEndStream HTTP2Flags = 0x1
+	// HTTP2FlagAck is set on SETTINGS and PING frames.
+	HTTP2FlagAck HTTP2Flags = 0x1
+	// HTTP2FlagEndHeaders is set on HEADERS, PUSH_PROMISE and
+	// CONTINUATION frames.
+	HTTP2FlagEndHeaders HTTP2Flags = 0x4
+	// HTTP2FlagPadded is set on DATA, HEADERS and PUSH_PROMISE frames.
+	HTTP2FlagPadded HTTP2Flags = 0x8
+	// HTTP2FlagPriority is set on HEADERS frames.
+	HTTP2FlagPriority HTTP2Flags = 0x20
+)
+
+// HTTP2ErrorCode is the error code of RST_STREAM and GOAWAY frames.
+type HTTP2ErrorCode uint32
+
+// HTTP2ErrorCode known values, from RFC 7540 section 7.
+const (
+	HTTP2NoError            HTTP2ErrorCode = 0x0
+	HTTP2ProtocolError      HTTP2ErrorCode = 0x1
+	HTTP2InternalError      HTTP2ErrorCode = 0x2
+	HTTP2FlowControlError   HTTP2ErrorCode = 0x3
+	HTTP2SettingsTimeout    HTTP2ErrorCode = 0x4
+	HTTP2StreamClosed       HTTP2ErrorCode = 0x5
+	HTTP2FrameSizeError     HTTP2ErrorCode = 0x6
+	HTTP2RefusedStream      HTTP2ErrorCode = 0x7
+	HTTP2Cancel             HTTP2ErrorCode = 0x8
+	HTTP2CompressionError   HTTP2ErrorCode = 0x9
+	HTTP2ConnectError       HTTP2ErrorCode = 0xa
+	HTTP2EnhanceYourCalm    HTTP2ErrorCode = 0xb
+	HTTP2InadequateSecurity HTTP2ErrorCode = 0xc
+	HTTP2HTTP11Required     HTTP2ErrorCode = 0xd
+)
+
+var http2ErrorCodeNames = map[HTTP2ErrorCode]string{
+	HTTP2NoError:            "NO_ERROR",
+	HTTP2ProtocolError:      "PROTOCOL_ERROR",
+	HTTP2InternalError:      "INTERNAL_ERROR",
+	HTTP2FlowControlError:   "FLOW_CONTROL_ERROR",
+	HTTP2SettingsTimeout:    "SETTINGS_TIMEOUT",
+	HTTP2StreamClosed:       "STREAM_CLOSED",
+	HTTP2FrameSizeError:     "FRAME_SIZE_ERROR",
+	HTTP2RefusedStream:      "REFUSED_STREAM",
+	HTTP2Cancel:             "CANCEL",
+	HTTP2CompressionError:   "COMPRESSION_ERROR",
+	HTTP2ConnectError:       "CONNECT_ERROR",
+	HTTP2EnhanceYourCalm:    "ENHANCE_YOUR_CALM",
+	HTTP2InadequateSecurity: "INADEQUATE_SECURITY",
+	HTTP2HTTP11Required:     "HTTP_1_1_REQUIRED",
+}
+
+func (c HTTP2ErrorCode) String() string {
+	if name, ok := http2ErrorCodeNames[c]; ok {
+		return name
+	}
+	return fmt.Sprintf("UNKNOWN(%d)", uint32(c))
+}
+
+// HTTP2SettingID identifies a setting of a SETTINGS frame.
+type HTTP2SettingID uint16
+
+// HTTP2SettingID known values, from RFC 7540 section 6.5.2.
+const (
+	HTTP2SettingHeaderTableSize      HTTP2SettingID = 0x1
+	HTTP2SettingEnablePush           HTTP2SettingID = 0x2
+	HTTP2SettingMaxConcurrentStreams HTTP2SettingID = 0x3
+	HTTP2SettingInitialWindowSize    HTTP2SettingID = 0x4
+	HTTP2SettingMaxFrameSize         HTTP2SettingID = 0x5
+	HTTP2SettingMaxHeaderListSize    HTTP2SettingID = 0x6
+)
+
+func (id HTTP2SettingID) String() string {
+	switch id {
+	case HTTP2SettingHeaderTableSize:
+		return "HEADER_TABLE_SIZE"
+	case HTTP2SettingEnablePush:
+		return "ENABLE_PUSH"
+	case HTTP2SettingMaxConcurrentStreams:
+		return "MAX_CONCURRENT_STREAMS"
+	case HTTP2SettingInitialWindowSize:
+		return "INITIAL_WINDOW_SIZE"
+	case HTTP2SettingMaxFrameSize:
+		return "MAX_FRAME_SIZE"
+	case HTTP2SettingMaxHeaderListSize:
+		return "MAX_HEADER_LIST_SIZE"
+	}
+	return fmt.Sprintf("UNKNOWN(%d)", uint16(id))
+}
+
+// HTTP2Setting is a parameter of a SETTINGS frame.
+type HTTP2Setting struct {
+	ID    HTTP2SettingID
+	Value uint32
+}
+
+// HTTP2Priority is the priority of a stream, given in HEADERS and PRIORITY
+// frames.
+type HTTP2Priority struct {
+	Exclusive        bool
+	StreamDependency uint32
+	// Weight is the weight minus one, as sent.
+	Weight uint8
+}
+
+// HTTP2Frame is an HTTP/2 frame, as specified in RFC 7540 section 4.1.
+//
+// The fields following the frame header are filled in according to its
+// type; the others are left to their zero value. Frames of unknown types
+// only have their Payload.
+type HTTP2Frame struct {
+	Length   uint32
+	Type     HTTP2FrameType
+	Flags    HTTP2Flags
+	StreamID uint32
+	// Payload is the whole frame payload, padding included.
+	Payload []byte
+
+	// Data is the data of a DATA frame, without padding.
+	Data []byte
+	// HeaderBlockFragment is the part of a header block carried by
+	// HEADERS, PUSH_PROMISE and CONTINUATION frames, without padding.
+	// Header blocks are compressed with HPACK, whose state is kept per
+	// connection: see the reassembly/http2stream package for decoded
+	// headers.
+	HeaderBlockFragment []byte
+	// Priority is set for PRIORITY frames, and HEADERS frames with the
+	// HTTP2FlagPriority flag.
+	Priority HTTP2Priority
+	// PromisedStreamID is the stream reserved by a PUSH_PROMISE frame.
+	PromisedStreamID uint32
+	// ErrorCode is set for RST_STREAM and GOAWAY frames.
+	ErrorCode HTTP2ErrorCode
+	// LastStreamID and DebugData are set for GOAWAY frames.
+	LastStreamID uint32
+	DebugData    []byte
+	// Settings are the parameters of a SETTINGS frame.
+	Settings []HTTP2Setting
+	// OpaqueData is the data of a PING frame.
+	OpaqueData [8]byte
+	// WindowSizeIncrement is set for WINDOW_UPDATE frames.
+	WindowSizeIncrement uint32
+}
+
+// Has returns whether all of flags are set on the frame.
+func (f *HTTP2Frame) Has(flags HTTP2Flags) bool {
+	return f.Flags&flags == flags
+}
+
+// HTTP2 holds the HTTP/2 frames of a TCP segment or of a chunk of
+// reassembled data, as specified in RFC 7540.
+//
+// Only the frames complete in the decoded data are decoded; a frame whose
+// end is missing marks the layer as truncated. The reassembly/http2stream
+// package follows frames over whole connections.
+//
+// HTTP2 is not registered on any TCP port by default: most connections are
+// protected by TLS, and cleartext ones start as HTTP/1.1.
+type HTTP2 struct {
+	BaseLayer
+
+	// Preface is set if the data starts with the client connection
+	// preface, HTTP2Preface.
+	Preface bool
+	Frames  []HTTP2Frame
+}
+
+// LayerType returns gopacket.LayerTypeHTTP2.
+func (h *HTTP2) LayerType() gopacket.LayerType { return LayerTypeHTTP2 }
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode
+func (h *HTTP2) CanDecode() gopacket.LayerClass { return LayerTypeHTTP2 }
+
+// NextLayerType returns the layer type contained by this DecodingLayer
+func (h *HTTP2) NextLayerType() gopacket.LayerType { return gopacket.LayerTypeZero }
+
+// Payload returns nil, since the data of HTTP/2 streams is in the frames.
+func (h *HTTP2) Payload() []byte { return nil }
+
+func decodeHTTP2(data []byte, p gopacket.PacketBuilder) error {
+	h := &HTTP2{}
+	err := h.DecodeFromBytes(data, p)
+	if err != nil {
+		return err
+	}
+	p.AddLayer(h)
+	p.SetApplicationLayer(h)
+	return nil
+}
+
+// DecodeFromBytes decodes the slice into the HTTP2 struct.
+func (h *HTTP2) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	h.Preface = false
+	h.Frames = h.Frames[:0]
+
+	rest := data
+	if len(rest) >= len(HTTP2Preface) && string(rest[:len(HTTP2Preface)]) == HTTP2Preface {
+		h.Preface = true
+		rest = rest[len(HTTP2Preface):]
+	}
+	for len(rest) >= HTTP2FrameHeaderLength {
+		length := int(rest[0])<<16 | int(rest[1])<<8 | int(rest[2])
+		if len(rest) < HTTP2FrameHeaderLength+length {
+			break
+		}
+		var f HTTP2Frame
+		if err := f.decodeFromBytes(rest[:HTTP2FrameHeaderLength+length]); err != nil {
+			return err
+		}
+		h.Frames = append(h.Frames, f)
+		rest = rest[HTTP2FrameHeaderLength+length:]
+	}
+	if len(rest) > 0 {
+		df.SetTruncated()
+		if !h.Preface && len(h.Frames) == 0 {
+			return errors.New("HTTP/2 frame truncated")
+		}
+	}
+	h.BaseLayer = BaseLayer{Contents: data[:len(data)-len(rest)]}
+	return nil
+}
+
+// decodeFromBytes decodes a whole frame.
+func (f *HTTP2Frame) decodeFromBytes(data []byte) error {
+	f.Length = uint32(data[0])<<16 | uint32(data[1])<<8 | uint32(data[2])
+	f.Type = HTTP2FrameType(data[3])
+	f.Flags = HTTP2Flags(data[4])
+	f.StreamID = binary.BigEndian.Uint32(data[5:9]) & 0x7fffffff
+	f.Payload = data[HTTP2FrameHeaderLength:]
+	p := f.Payload
+
+	// DATA, HEADERS and PUSH_PROMISE frames may be padded
+	padded := func() ([]byte, error) {
+		if !f.Has(HTTP2FlagPadded) {
+			return p, nil
+		}
+		if len(p) < 1 || int(p[0]) > len(p)-1 {
+			return nil, fmt.Errorf("HTTP/2 %s frame padding too long", f.Type)
+		}
+		return p[1 : len(p)-int(p[0])], nil
+	}
+	short := func() error {
+		return fmt.Errorf("HTTP/2 %s frame too short: %d bytes", f.Type, len(p))
+	}
+
+	var err error
+	switch f.Type {
+	case HTTP2FrameData:
+		f.Data, err = padded()
+	case HTTP2FrameHeaders:
+		if p, err = padded(); err != nil {
+			return err
+		}
+		if f.Has(HTTP2FlagPriority) {
+			if len(p) < 5 {
+				return short()
+			}
+			f.Priority = decodeHTTP2Priority(p)
+			p = p[5:]
+		}
+		f.HeaderBlockFragment = p
+	case HTTP2FramePriority:
+		if len(p) != 5 {
+			return short()
+		}
+		f.Priority = decodeHTTP2Priority(p)
+	case HTTP2FrameRSTStream:
+		if len(p) != 4 {
+			return short()
+		}
+		f.ErrorCode = HTTP2ErrorCode(binary.BigEndian.Uint32(p))
+	case HTTP2FrameSettings:
+		if len(p)%6 != 0 {
+			return fmt.Errorf("HTTP/2 SETTINGS frame length %d not a multiple of 6", len(p))
+		}
+		f.Settings = make([]HTTP2Setting, 0, len(p)/6)
+		for ; len(p) > 0; p = p[6:] {
+			f.Settings = append(f.Settings, HTTP2Setting{
+				ID:    HTTP2SettingID(binary.BigEndian.Uint16(p)),
+				Value: binary.BigEndian.Uint32(p[2:]),
+			})
+		}
+	case HTTP2FramePushPromise:
+		if p, err = padded(); err != nil {
+			return err
+		}
+		if len(p) < 4 {
+			return short()
+		}
+		f.PromisedStreamID = binary.BigEndian.Uint32(p) & 0x7fffffff
+		f.HeaderBlockFragment = p[4:]
+	case HTTP2FramePing:
+		if len(p) != 8 {
+			return short()
+		}
+		copy(f.OpaqueData[:], p)
+	case HTTP2FrameGoAway:
+		if len(p) < 8 {
+			return short()
+		}
+		f.LastStreamID = binary.BigEndian.Uint32(p) & 0x7fffffff
+		f.ErrorCode = HTTP2ErrorCode(binary.BigEndian.Uint32(p[4:]))
+		f.DebugData = p[8:]
+	case HTTP2FrameWindowUpdate:
+		if len(p) != 4 {
+			return short()
+		}
+		f.WindowSizeIncrement = binary.BigEndian.Uint32(p) & 0x7fffffff
+	case HTTP2FrameContinuation:
+		f.HeaderBlockFragment = p
+	}
+	return err
+}
+
+func decodeHTTP2Priority(p []byte) HTTP2Priority {
+	dep := binary.BigEndian.Uint32(p)
+	return HTTP2Priority{
+		Exclusive:        dep&0x80000000 != 0,
+		StreamDependency: dep & 0x7fffffff,
+		Weight:           p[4],
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2026 The GoPacket Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+func TestHTTP2Frames(t *testing.T) {
+	data := append([]byte(HTTP2Preface),
+		// SETTINGS: HEADER_TABLE_SIZE 4096, ENABLE_PUSH 0
+		0, 0, 12, 0x4, 0, 0, 0, 0, 0,
+		0, 1, 0, 0, 0x10, 0, 0, 2, 0, 0, 0, 0,
+		// HEADERS, padded, with priority: exclusive on stream 3, weight 15
+		0, 0, 10, 0x1, 0x2d, 0, 0, 0, 5,
+		2, 0x80, 0, 0, 3, 15, 0x82, 0x84, 0, 0,
+		// DATA, padded
+		0, 0, 5, 0x0, 0x9, 0, 0, 0, 5,
+		1, 'a', 'b', 'c', 0,
+		// GOAWAY: last stream 5, ENHANCE_YOUR_CALM, debug data
+		0, 0, 10, 0x7, 0, 0, 0, 0, 0,
+		0, 0, 0, 5, 0, 0, 0, 0xb, 'h', 'i',
+		// the start of a PING frame
+		0, 0, 8, 0x6, 0, 0, 0, 0, 0, 1, 2,
+	)
+	p := gopacket.NewPacket(data, LayerTypeHTTP2, gopacket.Default)
+	h, ok := p.Layer(LayerTypeHTTP2).(*HTTP2)
+	if !ok {
+		t.Fatalf("no HTTP2 layer: %v", p)
+	}
+	if !h.Preface || len(h.Frames) != 4 || !p.Metadata().Truncated || len(h.Contents) != len(data)-11 {
+		t.Fatalf("preface %v, %d frames, truncated %v", h.Preface, len(h.Frames), p.Metadata().Truncated)
+	}
+
+	settings := h.Frames[0]
+	if want := []HTTP2Setting{{HTTP2SettingHeaderTableSize, 4096}, {HTTP2SettingEnablePush, 0}}; settings.Type != HTTP2FrameSettings ||
+		!reflect.DeepEqual(settings.Settings, want) {
+		t.Errorf("SETTINGS frame: %+v", settings)
+	}
+	headers := h.Frames[1]
+	if headers.StreamID != 5 || !headers.Has(HTTP2FlagEndHeaders|HTTP2FlagEndStream) || headers.Has(HTTP2FlagEndHeaders|0x2) ||
+		headers.Priority != (HTTP2Priority{Exclusive: true, StreamDependency: 3, Weight: 15}) ||
+		!reflect.DeepEqual(headers.HeaderBlockFragment, []byte{0x82, 0x84}) {
+		t.Errorf("HEADERS frame: %+v", headers)
+	}
+	if data := h.Frames[2]; string(data.Data) != "abc" || len(data.Payload) != 5 {
+		t.Errorf("DATA frame: %+v", data)
+	}
+	goaway := h.Frames[3]
+	if goaway.LastStreamID != 5 || goaway.ErrorCode != HTTP2EnhanceYourCalm || string(goaway.DebugData) != "hi" ||
+		goaway.ErrorCode.String() != "ENHANCE_YOUR_CALM" {
+		t.Errorf("GOAWAY frame: %+v", goaway)
+	}
+}
+
+func TestHTTP2Invalid(t *testing.T) {
+	for _, bad := range [][]byte{
+		// padding longer than the payload
+		{0, 0, 2, 0x0, 0x8, 0, 0, 0, 1, 5, 0},
+		// SETTINGS length not a multiple of 6
+		{0, 0, 4, 0x4, 0, 0, 0, 0, 0, 0, 1, 0, 0},
+		// WINDOW_UPDATE too short
+		{0, 0, 2, 0x8, 0, 0, 0, 0, 1, 0, 1},
+		// a frame header alone
+		{0, 0, 2, 0x8, 0},
+	} {
+		if p := gopacket.NewPacket(bad, LayerTypeHTTP2, gopacket.Default); p.ErrorLayer() == nil {
+			t.Errorf("%x decoded: %v", bad, p)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright 2026 The GoPacket Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+func TestHTTPRequest(t *testing.T) {
+	data := []byte("\r\nPOST /upload?x=1 HTTP/1.1\r\nHost: example.com\r\nX-Folded: a\r\n  b\r\ncontent-length: 5\r\n\r\nhelloGET / HTTP/1.1\r\n\r\n")
+	var h HTTP
+	var body gopacket.Payload
+	parser := gopacket.NewDecodingLayerParser(LayerTypeHTTP, &h, &body)
+	var decoded []gopacket.LayerType
+	if err := parser.DecodeLayers(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if h.IsResponse || h.Method != "POST" || h.RequestURI != "/upload?x=1" || h.Version != "HTTP/1.1" {
+		t.Errorf("request line: %+v", h)
+	}
+	want := []HTTPHeader{{"Host", "example.com"}, {"X-Folded", "a b"}, {"content-length", "5"}}
+	if !reflect.DeepEqual(h.Headers, want) {
+		t.Errorf("headers: got %+v, want %+v", h.Headers, want)
+	}
+	if h.Header("Content-Length") != "5" || h.Header("Accept") != "" {
+		t.Errorf("Header lookups")
+	}
+	if string(body) != "hello" || len(h.Contents) != len(data)-len("helloGET / HTTP/1.1\r\n\r\n") {
+		t.Errorf("body %q, contents %q", h.Payload(), h.Contents)
+	}
+
+	// the layer is reused
+	if err := parser.DecodeLayers([]byte("GET / HTTP/1.0\n\n"), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if h.Method != "GET" || h.Version != "HTTP/1.0" || len(h.Headers) != 0 || len(h.Payload()) != 0 {
+		t.Errorf("bare LF request: %+v", h)
+	}
+}
+
+func TestHTTPResponse(t *testing.T) {
+	data := []byte("HTTP/1.1 200 OK\r\nTransfer-Encoding: gzip, chunked\r\n\r\n4;ext=1\r\nabcd\r\n2\r\nef\r\n0\r\nExpires: never\r\n\r\n")
+	p := gopacket.NewPacket(data, LayerTypeHTTP, gopacket.Default)
+	h, ok := p.Layer(LayerTypeHTTP).(*HTTP)
+	if !ok {
+		t.Fatalf("no HTTP layer: %v", p)
+	}
+	if p.ApplicationLayer() != h || p.Metadata().Truncated {
+		t.Errorf("application layer %v, truncated %v", p.ApplicationLayer(), p.Metadata().Truncated)
+	}
+	if !h.IsResponse || h.StatusCode != 200 || h.Reason != "OK" || !h.Chunked() {
+		t.Errorf("status line: %+v", h)
+	}
+	if string(h.Payload()) != "abcdef" || !reflect.DeepEqual(h.Trailers, []HTTPHeader{{"Expires", "never"}}) {
+		t.Errorf("body %q, trailers %+v", h.Payload(), h.Trailers)
+	}
+
+	// no length: the body runs to the end of the data
+	p = gopacket.NewPacket([]byte("HTTP/1.0 404 Not Found\r\nServer: x\r\n\r\n<html>"), LayerTypeHTTP, gopacket.Default)
+	if h, ok := p.Layer(LayerTypeHTTP).(*HTTP); !ok || string(h.Payload()) != "<html>" || h.StatusCode != 404 {
+		t.Errorf("close-delimited response: %v", p)
+	}
+	// no body at all
+	p = gopacket.NewPacket([]byte("HTTP/1.1 304 Not Modified\r\n\r\n"), LayerTypeHTTP, gopacket.Default)
+	if h, ok := p.Layer(LayerTypeHTTP).(*HTTP); !ok || h.HasBody("GET") || len(h.Payload()) != 0 {
+		t.Errorf("304 response: %v", p)
+	}
+}
+
+func TestHTTPTruncated(t *testing.T) {
+	p := gopacket.NewPacket([]byte("HTTP/1.1 200 OK\r\nContent-Length: 100\r\n\r\npartial"), LayerTypeHTTP, gopacket.Default)
+	if h, ok := p.Layer(LayerTypeHTTP).(*HTTP); !ok || string(h.Payload()) != "partial" || !p.Metadata().Truncated {
+		t.Errorf("truncated body: %v", p)
+	}
+	p = gopacket.NewPacket([]byte("HTTP/1.1 200 OK\r\nTransfer-Encoding: chunked\r\n\r\n10\r\nabc"), LayerTypeHTTP, gopacket.Default)
+	if h, ok := p.Layer(LayerTypeHTTP).(*HTTP); !ok || string(h.Payload()) != "abc" || !p.Metadata().Truncated {
+		t.Errorf("truncated chunk: %v", p)
+	}
+	p = gopacket.NewPacket([]byte("GET / HTTP/1.1\r\nHost: exa"), LayerTypeHTTP, gopacket.Default)
+	if p.ErrorLayer() == nil || !p.Metadata().Truncated {
+		t.Errorf("incomplete header: %v", p)
+	}
+	for _, bad := range []string{
+		"GET /\r\n\r\n",
+		"GET / HTTP/2.0\r\n\r\n",
+		"HTTP/1.1 2000 OK\r\n\r\n",
+		"GET / HTTP/1.1\r\nNo Colon\r\n\r\n",
+	} {
+		if p := gopacket.NewPacket([]byte(bad), LayerTypeHTTP, gopacket.Default); p.ErrorLayer() == nil {
+			t.Errorf("%q decoded: %v", bad, p)
+		}
+	}
+}
+
+func TestHTTPContentLength(t *testing.T) {
+	for _, test := range []struct {
+		headers []HTTPHeader
+		length  int64
+		ok      bool
+	}{
+		{nil, 0, false},
+		{[]HTTPHeader{{"Content-Length", "5"}}, 5, true},
+		{[]HTTPHeader{{"Content-Length", "5, 5"}, {"content-length", "5"}}, 5, true},
+		{[]HTTPHeader{{"Content-Length", "5, 6"}}, 0, false},
+		{[]HTTPHeader{{"Content-Length", "5"}, {"Content-Length", "6"}}, 0, false},
+		{[]HTTPHeader{{"Content-Length", "5"}, {"Content-Length", ""}}, 0, false},
+		{[]HTTPHeader{{"Content-Length", "-1"}}, 0, false},
+	} {
+		h := HTTP{Headers: test.headers}
+		if length, ok := h.ContentLength(); length != test.length || ok != test.ok {
+			t.Errorf("%+v: got %d, %v", test.headers, length, ok)
+		}
+	}
+}
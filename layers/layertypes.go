@@ -146,6 +146,17 @@ var (
 	LayerTypeRMCP                         = gopacket.RegisterLayerType(142, gopacket.LayerTypeMetadata{Name: "RMCP", Decoder: gopacket.DecodeFunc(decodeRMCP)})
 	LayerTypeASF                          = gopacket.RegisterLayerType(143, gopacket.LayerTypeMetadata{Name: "ASF", Decoder: gopacket.DecodeFunc(decodeASF)})
 	LayerTypeASFPresencePong              = gopacket.RegisterLayerType(144, gopacket.LayerTypeMetadata{Name: "ASFPresencePong", Decoder: gopacket.DecodeFunc(decodeASFPresencePong)})
+	LayerTypeNetFlowV5                    = gopacket.RegisterLayerType(145, gopacket.LayerTypeMetadata{Name: "NetFlowV5", Decoder: gopacket.DecodeFunc(decodeNetFlow)})
+	LayerTypeNetFlowV9                    = gopacket.RegisterLayerType(146, gopacket.LayerTypeMetadata{Name: "NetFlowV9", Decoder: gopacket.DecodeFunc(decodeNetFlow)})
+	LayerTypeIPFIX                        = gopacket.RegisterLayerType(147, gopacket.LayerTypeMetadata{Name: "IPFIX", Decoder: gopacket.DecodeFunc(decodeNetFlow)})
+	LayerTypeHTTP                         = gopacket.RegisterLayerType(148, gopacket.LayerTypeMetadata{Name: "HTTP", Decoder: gopacket.DecodeFunc(decodeHTTP)})
+	LayerTypeSSH                          = gopacket.RegisterLayerType(149, gopacket.LayerTypeMetadata{Name: "SSH", Decoder: gopacket.DecodePayload})
+	LayerTypeSMB                          = gopacket.RegisterLayerType(150, gopacket.LayerTypeMetadata{Name: "SMB", Decoder: gopacket.DecodePayload})
+	LayerTypeHTTP2                        = gopacket.RegisterLayerType(151, gopacket.LayerTypeMetadata{Name: "HTTP2", Decoder: gopacket.DecodeFunc(decodeHTTP2)})
+	LayerTypeQUIC                         = gopacket.RegisterLayerType(152, gopacket.LayerTypeMetadata{Name: "QUIC", Decoder: gopacket.DecodeFunc(decodeQUIC)})
+	LayerTypeBGP                          = gopacket.RegisterLayerType(153, gopacket.LayerTypeMetadata{Name: "BGP", Decoder: gopacket.DecodeFunc(decodeBGP)})
+	LayerTypeRADIUS                       = gopacket.RegisterLayerType(154, gopacket.LayerTypeMetadata{Name: "RADIUS", Decoder: gopacket.DecodeFunc(decodeRADIUS)})
+	LayerTypeDiameter                     = gopacket.RegisterLayerType(155, gopacket.LayerTypeMetadata{Name: "Diameter", Decoder: gopacket.DecodeFunc(decodeDiameter)})
 )
 
 var (
@@ -0,0 +1,1017 @@
+// Copyright 2026 The GoPacket Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/google/gopacket"
+)
+
+// This file decodes the flow records exported by routers: NetFlow v5, whose
+// records have a fixed layout, and NetFlow v9 (RFC 3954) and IPFIX
+// (RFC 7011), whose data records are laid out by templates sent in earlier
+// packets.
+//
+// Exporters commonly send all three versions to the same collector port, so
+// the decoders of LayerTypeNetFlowV5, LayerTypeNetFlowV9 and LayerTypeIPFIX
+// all look at the version field and decode whichever version they get.
+//
+// Data sets are decoded with the templates sent earlier in the same packet.
+// Templates are only remembered across packets in a NetFlowTemplateCache the
+// caller provides, keyed on the exporter (the source of the network layer
+// carrying the packet), the version, the source ID or observation domain, and
+// the template ID.  Data sets whose template is not known are kept undecoded
+// in the Data of their NetFlowSet.
+
+// NetFlowFieldType is the type of a field of a NetFlow v9 or IPFIX record:
+// an information element ID from the IANA IPFIX registry, which NetFlow v9
+// field types are a subset of.
+type NetFlowFieldType uint16
+
+// Common NetFlow v9 and IPFIX field types.
+const (
+	NetFlowFieldOctetDeltaCount                  NetFlowFieldType = 1
+	NetFlowFieldPacketDeltaCount                 NetFlowFieldType = 2
+	NetFlowFieldDeltaFlowCount                   NetFlowFieldType = 3
+	NetFlowFieldProtocolIdentifier               NetFlowFieldType = 4
+	NetFlowFieldIPClassOfService                 NetFlowFieldType = 5
+	NetFlowFieldTCPControlBits                   NetFlowFieldType = 6
+	NetFlowFieldSourceTransportPort              NetFlowFieldType = 7
+	NetFlowFieldSourceIPv4Address                NetFlowFieldType = 8
+	NetFlowFieldSourceIPv4PrefixLength           NetFlowFieldType = 9
+	NetFlowFieldIngressInterface                 NetFlowFieldType = 10
+	NetFlowFieldDestinationTransportPort         NetFlowFieldType = 11
+	NetFlowFieldDestinationIPv4Address           NetFlowFieldType = 12
+	NetFlowFieldDestinationIPv4PrefixLength      NetFlowFieldType = 13
+	NetFlowFieldEgressInterface                  NetFlowFieldType = 14
+	NetFlowFieldIPNextHopIPv4Address             NetFlowFieldType = 15
+	NetFlowFieldBGPSourceASNumber                NetFlowFieldType = 16
+	NetFlowFieldBGPDestinationASNumber           NetFlowFieldType = 17
+	NetFlowFieldBGPNextHopIPv4Address            NetFlowFieldType = 18
+	NetFlowFieldFlowEndSysUpTime                 NetFlowFieldType = 21
+	NetFlowFieldFlowStartSysUpTime               NetFlowFieldType = 22
+	NetFlowFieldSourceIPv6Address                NetFlowFieldType = 27
+	NetFlowFieldDestinationIPv6Address           NetFlowFieldType = 28
+	NetFlowFieldSourceIPv6PrefixLength           NetFlowFieldType = 29
+	NetFlowFieldDestinationIPv6PrefixLength      NetFlowFieldType = 30
+	NetFlowFieldFlowLabelIPv6                    NetFlowFieldType = 31
+	NetFlowFieldICMPTypeCodeIPv4                 NetFlowFieldType = 32
+	NetFlowFieldSamplingInterval                 NetFlowFieldType = 34
+	NetFlowFieldSamplingAlgorithm                NetFlowFieldType = 35
+	NetFlowFieldSourceMacAddress                 NetFlowFieldType = 56
+	NetFlowFieldPostDestinationMacAddress        NetFlowFieldType = 57
+	NetFlowFieldVlanID                           NetFlowFieldType = 58
+	NetFlowFieldIPVersion                        NetFlowFieldType = 60
+	NetFlowFieldFlowDirection                    NetFlowFieldType = 61
+	NetFlowFieldIPNextHopIPv6Address             NetFlowFieldType = 62
+	NetFlowFieldDestinationMacAddress            NetFlowFieldType = 80
+	NetFlowFieldOctetTotalCount                  NetFlowFieldType = 85
+	NetFlowFieldPacketTotalCount                 NetFlowFieldType = 86
+	NetFlowFieldApplicationID                    NetFlowFieldType = 95
+	NetFlowFieldApplicationName                  NetFlowFieldType = 96
+	NetFlowFieldFlowEndReason                    NetFlowFieldType = 136
+	NetFlowFieldICMPTypeCodeIPv6                 NetFlowFieldType = 139
+	NetFlowFieldFlowID                           NetFlowFieldType = 148
+	NetFlowFieldObservationDomainID              NetFlowFieldType = 149
+	NetFlowFieldFlowStartSeconds                 NetFlowFieldType = 150
+	NetFlowFieldFlowEndSeconds                   NetFlowFieldType = 151
+	NetFlowFieldFlowStartMilliseconds            NetFlowFieldType = 152
+	NetFlowFieldFlowEndMilliseconds              NetFlowFieldType = 153
+	NetFlowFieldSystemInitTimeMilliseconds       NetFlowFieldType = 160
+	NetFlowFieldPostNATSourceIPv4Address         NetFlowFieldType = 225
+	NetFlowFieldPostNATDestinationIPv4Address    NetFlowFieldType = 226
+	NetFlowFieldPostNAPTSourceTransportPort      NetFlowFieldType = 227
+	NetFlowFieldPostNAPTDestinationTransportPort NetFlowFieldType = 228
+	NetFlowFieldSamplingPacketInterval           NetFlowFieldType = 305
+)
+
+var netFlowFieldTypeNames = map[NetFlowFieldType]string{
+	NetFlowFieldOctetDeltaCount:                  "octetDeltaCount",
+	NetFlowFieldPacketDeltaCount:                 "packetDeltaCount",
+	NetFlowFieldDeltaFlowCount:                   "deltaFlowCount",
+	NetFlowFieldProtocolIdentifier:               "protocolIdentifier",
+	NetFlowFieldIPClassOfService:                 "ipClassOfService",
+	NetFlowFieldTCPControlBits:                   "tcpControlBits",
+	NetFlowFieldSourceTransportPort:              "sourceTransportPort",
+	NetFlowFieldSourceIPv4Address:                "sourceIPv4Address",
+	NetFlowFieldSourceIPv4PrefixLength:           "sourceIPv4PrefixLength",
+	NetFlowFieldIngressInterface:                 "ingressInterface",
+	NetFlowFieldDestinationTransportPort:         "destinationTransportPort",
+	NetFlowFieldDestinationIPv4Address:           "destinationIPv4Address",
+	NetFlowFieldDestinationIPv4PrefixLength:      "destinationIPv4PrefixLength",
+	NetFlowFieldEgressInterface:                  "egressInterface",
+	NetFlowFieldIPNextHopIPv4Address:             "ipNextHopIPv4Address",
+	NetFlowFieldBGPSourceASNumber:                "bgpSourceAsNumber",
+	NetFlowFieldBGPDestinationASNumber:           "bgpDestinationAsNumber",
+	NetFlowFieldBGPNextHopIPv4Address:            "bgpNextHopIPv4Address",
+	NetFlowFieldFlowEndSysUpTime:                 "flowEndSysUpTime",
+	NetFlowFieldFlowStartSysUpTime:               "flowStartSysUpTime",
+	NetFlowFieldSourceIPv6Address:                "sourceIPv6Address",
+	NetFlowFieldDestinationIPv6Address:           "destinationIPv6Address",
+	NetFlowFieldSourceIPv6PrefixLength:           "sourceIPv6PrefixLength",
+	NetFlowFieldDestinationIPv6PrefixLength:      "destinationIPv6PrefixLength",
+	NetFlowFieldFlowLabelIPv6:                    "flowLabelIPv6",
+	NetFlowFieldICMPTypeCodeIPv4:                 "icmpTypeCodeIPv4",
+	NetFlowFieldSamplingInterval:                 "samplingInterval",
+	NetFlowFieldSamplingAlgorithm:                "samplingAlgorithm",
+	NetFlowFieldSourceMacAddress:                 "sourceMacAddress",
+	NetFlowFieldPostDestinationMacAddress:        "postDestinationMacAddress",
+	NetFlowFieldVlanID:                           "vlanId",
+	NetFlowFieldIPVersion:                        "ipVersion",
+	NetFlowFieldFlowDirection:                    "flowDirection",
+	NetFlowFieldIPNextHopIPv6Address:             "ipNextHopIPv6Address",
+	NetFlowFieldDestinationMacAddress:            "destinationMacAddress",
+	NetFlowFieldOctetTotalCount:                  "octetTotalCount",
+	NetFlowFieldPacketTotalCount:                 "packetTotalCount",
+	NetFlowFieldApplicationID:                    "applicationId",
+	NetFlowFieldApplicationName:                  "applicationName",
+	NetFlowFieldFlowEndReason:                    "flowEndReason",
+	NetFlowFieldICMPTypeCodeIPv6:                 "icmpTypeCodeIPv6",
+	NetFlowFieldFlowID:                           "flowId",
+	NetFlowFieldObservationDomainID:              "observationDomainId",
+	NetFlowFieldFlowStartSeconds:                 "flowStartSeconds",
+	NetFlowFieldFlowEndSeconds:                   "flowEndSeconds",
+	NetFlowFieldFlowStartMilliseconds:            "flowStartMilliseconds",
+	NetFlowFieldFlowEndMilliseconds:              "flowEndMilliseconds",
+	NetFlowFieldSystemInitTimeMilliseconds:       "systemInitTimeMilliseconds",
+	NetFlowFieldPostNATSourceIPv4Address:         "postNATSourceIPv4Address",
+	NetFlowFieldPostNATDestinationIPv4Address:    "postNATDestinationIPv4Address",
+	NetFlowFieldPostNAPTSourceTransportPort:      "postNAPTSourceTransportPort",
+	NetFlowFieldPostNAPTDestinationTransportPort: "postNAPTDestinationTransportPort",
+	NetFlowFieldSamplingPacketInterval:           "samplingPacketInterval",
+}
+
+func (t NetFlowFieldType) String() string {
+	if name, ok := netFlowFieldTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("Unknown(%d)", uint16(t))
+}
+
+// Set IDs of template sets.  Data sets have IDs from 256 up, the ID of the
+// template describing their records.
+const (
+	NetFlowV9TemplateSetID        uint16 = 0
+	NetFlowV9OptionsTemplateSetID uint16 = 1
+	IPFIXTemplateSetID            uint16 = 2
+	IPFIXOptionsTemplateSetID     uint16 = 3
+)
+
+// NetFlowVariableLength is the length of the IPFIX fields whose length is
+// given in each record.
+const NetFlowVariableLength = 0xffff
+
+// NetFlowFieldSpec describes a field of a template.
+type NetFlowFieldSpec struct {
+	Type NetFlowFieldType
+	// Length is the length of the field, or NetFlowVariableLength
+	Length uint16
+	// EnterpriseNumber is the private enterprise number of IPFIX
+	// enterprise-specific fields, 0 for IANA fields
+	EnterpriseNumber uint32
+}
+
+// NetFlowTemplate is a NetFlow v9 or IPFIX template or options template.
+type NetFlowTemplate struct {
+	ID uint16
+	// ScopeFieldCount is the number of scope fields of options templates,
+	// which are the first of Fields.  NetFlow v9 scope fields have their own
+	// types: 1 for system, 2 for interface, 3 for line card, 4 for cache and
+	// 5 for template.
+	ScopeFieldCount int
+	// Fields is empty for IPFIX template withdrawals
+	Fields []NetFlowFieldSpec
+}
+
+// minRecordLength returns the length of the shortest record of the
+// template, counting one byte for variable length fields.
+func (t *NetFlowTemplate) minRecordLength() int {
+	n := 0
+	for _, f := range t.Fields {
+		if f.Length == NetFlowVariableLength {
+			n++
+		} else {
+			n += int(f.Length)
+		}
+	}
+	return n
+}
+
+// NetFlowField is a field of a NetFlow v9 or IPFIX data record.
+type NetFlowField struct {
+	Type             NetFlowFieldType
+	EnterpriseNumber uint32
+	Value            []byte
+}
+
+// Uint returns the value of an unsigned field, which exporters may encode
+// in fewer bytes than its type's size.
+func (f NetFlowField) Uint() uint64 {
+	var v uint64
+	for _, b := range f.Value {
+		v = v<<8 | uint64(b)
+	}
+	return v
+}
+
+// IP returns the value of an IPv4 or IPv6 address field, nil for fields of
+// other lengths.
+func (f NetFlowField) IP() net.IP {
+	if len(f.Value) != net.IPv4len && len(f.Value) != net.IPv6len {
+		return nil
+	}
+	return net.IP(f.Value)
+}
+
+// HardwareAddr returns the value of a MAC address field, nil for fields of
+// other lengths.
+func (f NetFlowField) HardwareAddr() net.HardwareAddr {
+	if len(f.Value) != 6 {
+		return nil
+	}
+	return net.HardwareAddr(f.Value)
+}
+
+// NetFlowRecord is a NetFlow v9 or IPFIX data record, decoded with its
+// template.
+type NetFlowRecord struct {
+	// ScopeFieldCount is the number of scope fields of options records,
+	// which are the first of Fields.
+	ScopeFieldCount int
+	Fields          []NetFlowField
+}
+
+// Field returns the first IANA field of type t of the record.
+func (r *NetFlowRecord) Field(t NetFlowFieldType) (NetFlowField, bool) {
+	for i := r.ScopeFieldCount; i < len(r.Fields); i++ {
+		if f := r.Fields[i]; f.Type == t && f.EnterpriseNumber == 0 {
+			return f, true
+		}
+	}
+	return NetFlowField{}, false
+}
+
+// Uint returns the value of the unsigned field t of the record.
+func (r *NetFlowRecord) Uint(t NetFlowFieldType) (uint64, bool) {
+	f, ok := r.Field(t)
+	return f.Uint(), ok
+}
+
+// IP returns the value of the address field t of the record.
+func (r *NetFlowRecord) IP(t NetFlowFieldType) (net.IP, bool) {
+	f, ok := r.Field(t)
+	if !ok || f.IP() == nil {
+		return nil, false
+	}
+	return f.IP(), true
+}
+
+// SrcIP returns the IPv4 or IPv6 source address of the flow, nil if it has
+// none.
+func (r *NetFlowRecord) SrcIP() net.IP {
+	if ip, ok := r.IP(NetFlowFieldSourceIPv4Address); ok {
+		return ip
+	}
+	ip, _ := r.IP(NetFlowFieldSourceIPv6Address)
+	return ip
+}
+
+// DstIP returns the IPv4 or IPv6 destination address of the flow, nil if it
+// has none.
+func (r *NetFlowRecord) DstIP() net.IP {
+	if ip, ok := r.IP(NetFlowFieldDestinationIPv4Address); ok {
+		return ip
+	}
+	ip, _ := r.IP(NetFlowFieldDestinationIPv6Address)
+	return ip
+}
+
+// NetFlowSet is a NetFlow v9 FlowSet or an IPFIX Set.
+type NetFlowSet struct {
+	ID     uint16
+	Length uint16
+	// Templates holds the templates of template and options template sets.
+	Templates []NetFlowTemplate
+	// Records holds the records of data sets whose template is known.
+	Records []NetFlowRecord
+	// Data holds the contents of the set after its header.
+	Data []byte
+}
+
+// IsData returns whether the set holds data records.
+func (s *NetFlowSet) IsData() bool { return s.ID >= 256 }
+
+type netFlowTemplateKey struct {
+	exporter gopacket.Endpoint
+	version  uint16
+	domain   uint32
+	id       uint16
+}
+
+// NetFlowTemplateCache holds the NetFlow v9 and IPFIX templates received from
+// exporters, to decode the data records of later packets.  It is safe for
+// concurrent use.
+type NetFlowTemplateCache struct {
+	mu        sync.RWMutex
+	templates map[netFlowTemplateKey]*NetFlowTemplate
+}
+
+// NewNetFlowTemplateCache returns an empty template cache.
+func NewNetFlowTemplateCache() *NetFlowTemplateCache {
+	return &NetFlowTemplateCache{templates: make(map[netFlowTemplateKey]*NetFlowTemplate)}
+}
+
+// DefaultNetFlowTemplateCache is the cache of the NetFlowV9 and IPFIX layers
+// which do not set their own, as when decoded by gopacket.NewPacket.  It is
+// nil, so templates are not remembered across packets unless it is set.  A
+// cache grows with the number of exporters and templates seen, and exporters
+// are easily spoofed: only set it when the sources of NetFlow packets are
+// trusted.
+var DefaultNetFlowTemplateCache *NetFlowTemplateCache
+
+// Template returns the template id of an exporter, for version 9 or 10
+// (IPFIX) and a source ID or observation domain.
+func (c *NetFlowTemplateCache) Template(exporter gopacket.Endpoint, version uint16, domain uint32, id uint16) (*NetFlowTemplate, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	t, ok := c.templates[netFlowTemplateKey{exporter, version, domain, id}]
+	return t, ok
+}
+
+// Len returns the number of templates in the cache.
+func (c *NetFlowTemplateCache) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.templates)
+}
+
+// update adds the templates of a set, or removes them for withdrawals.  An
+// IPFIX withdrawal of the set ID withdraws all the templates of the domain.
+func (c *NetFlowTemplateCache) update(exporter gopacket.Endpoint, version uint16, domain uint32, set *NetFlowSet) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := range set.Templates {
+		t := &set.Templates[i]
+		key := netFlowTemplateKey{exporter, version, domain, t.ID}
+		switch {
+		case len(t.Fields) != 0:
+			c.templates[key] = t
+		case t.ID == set.ID:
+			for k := range c.templates {
+				if k.exporter == exporter && k.version == version && k.domain == domain {
+					delete(c.templates, k)
+				}
+			}
+		default:
+			delete(c.templates, key)
+		}
+	}
+}
+
+// decodeNetFlowSets decodes the sets of a NetFlow v9 or IPFIX packet, learning
+// templates in cache, if not nil, and decoding data records with them and the
+// templates of earlier sets of the packet.
+func decodeNetFlowSets(data []byte, exporter gopacket.Endpoint, version uint16, domain uint32, cache *NetFlowTemplateCache, df gopacket.DecodeFeedback) ([]NetFlowSet, error) {
+	var sets []NetFlowSet
+	template := func(id uint16) (*NetFlowTemplate, bool) {
+		// the last definition or withdrawal of the packet wins
+		for i := len(sets) - 1; i >= 0; i-- {
+			for j := len(sets[i].Templates) - 1; j >= 0; j-- {
+				t := &sets[i].Templates[j]
+				switch {
+				case t.ID == id && len(t.Fields) != 0:
+					return t, true
+				case t.ID == id, len(t.Fields) == 0 && t.ID == sets[i].ID:
+					return nil, false
+				}
+			}
+		}
+		if cache == nil {
+			return nil, false
+		}
+		return cache.Template(exporter, version, domain, id)
+	}
+	for len(data) >= 4 {
+		set := NetFlowSet{
+			ID:     binary.BigEndian.Uint16(data[0:2]),
+			Length: binary.BigEndian.Uint16(data[2:4]),
+		}
+		if set.Length < 4 {
+			return nil, fmt.Errorf("invalid set length %d", set.Length)
+		}
+		if int(set.Length) > len(data) {
+			df.SetTruncated()
+			return nil, fmt.Errorf("set length %d too long for %d bytes", set.Length, len(data))
+		}
+		set.Data = data[4:set.Length]
+		data = data[set.Length:]
+
+		var err error
+		switch {
+		case set.ID == NetFlowV9TemplateSetID && version == 9:
+			set.Templates, err = decodeNetFlowV9Templates(set.Data, false)
+		case set.ID == NetFlowV9OptionsTemplateSetID && version == 9:
+			set.Templates, err = decodeNetFlowV9Templates(set.Data, true)
+		case set.ID == IPFIXTemplateSetID && version == 10:
+			set.Templates, err = decodeIPFIXTemplates(set.Data, false)
+		case set.ID == IPFIXOptionsTemplateSetID && version == 10:
+			set.Templates, err = decodeIPFIXTemplates(set.Data, true)
+		case set.IsData():
+			if t, ok := template(set.ID); ok {
+				set.Records, err = decodeNetFlowRecords(set.Data, t)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(set.Templates) != 0 && cache != nil {
+			cache.update(exporter, version, domain, &set)
+		}
+		sets = append(sets, set)
+	}
+	return sets, nil
+}
+
+// decodeNetFlowV9Templates decodes the templates of a NetFlow v9 template or
+// options template FlowSet, up to its padding.
+func decodeNetFlowV9Templates(data []byte, options bool) ([]NetFlowTemplate, error) {
+	var templates []NetFlowTemplate
+	for {
+		var t NetFlowTemplate
+		var scope, fields int
+		if options {
+			if len(data) < 6 {
+				break
+			}
+			t.ID = binary.BigEndian.Uint16(data[0:2])
+			scope = int(binary.BigEndian.Uint16(data[2:4])) / 4
+			fields = scope + int(binary.BigEndian.Uint16(data[4:6]))/4
+			data = data[6:]
+		} else {
+			if len(data) < 4 {
+				break
+			}
+			t.ID = binary.BigEndian.Uint16(data[0:2])
+			fields = int(binary.BigEndian.Uint16(data[2:4]))
+			data = data[4:]
+		}
+		if t.ID < 256 || fields == 0 {
+			// padding
+			break
+		}
+		if len(data) < 4*fields {
+			return nil, fmt.Errorf("NetFlow v9 template %d too short for %d fields", t.ID, fields)
+		}
+		t.ScopeFieldCount = scope
+		t.Fields = make([]NetFlowFieldSpec, fields)
+		for i := range t.Fields {
+			t.Fields[i].Type = NetFlowFieldType(binary.BigEndian.Uint16(data[0:2]))
+			t.Fields[i].Length = binary.BigEndian.Uint16(data[2:4])
+			data = data[4:]
+		}
+		templates = append(templates, t)
+	}
+	return templates, nil
+}
+
+// decodeIPFIXTemplates decodes the templates and withdrawals of an IPFIX
+// template or options template set, up to its padding.
+func decodeIPFIXTemplates(data []byte, options bool) ([]NetFlowTemplate, error) {
+	var templates []NetFlowTemplate
+	for len(data) >= 4 {
+		t := NetFlowTemplate{ID: binary.BigEndian.Uint16(data[0:2])}
+		fields := int(binary.BigEndian.Uint16(data[2:4]))
+		if t.ID < 256 && !(fields == 0 && t.ID >= IPFIXTemplateSetID) {
+			// padding
+			break
+		}
+		data = data[4:]
+		if options && fields != 0 {
+			if len(data) < 2 {
+				return nil, fmt.Errorf("IPFIX options template %d too short", t.ID)
+			}
+			t.ScopeFieldCount = int(binary.BigEndian.Uint16(data[0:2]))
+			data = data[2:]
+			if t.ScopeFieldCount == 0 || t.ScopeFieldCount > fields {
+				return nil, fmt.Errorf("IPFIX options template %d has %d scope fields", t.ID, t.ScopeFieldCount)
+			}
+		}
+		t.Fields = make([]NetFlowFieldSpec, fields)
+		for i := range t.Fields {
+			if len(data) < 4 {
+				return nil, fmt.Errorf("IPFIX template %d too short for %d fields", t.ID, fields)
+			}
+			f := &t.Fields[i]
+			f.Type = NetFlowFieldType(binary.BigEndian.Uint16(data[0:2]) & 0x7fff)
+			f.Length = binary.BigEndian.Uint16(data[2:4])
+			enterprise := data[0]&0x80 != 0
+			data = data[4:]
+			if enterprise {
+				if len(data) < 4 {
+					return nil, fmt.Errorf("IPFIX template %d too short for %d fields", t.ID, fields)
+				}
+				f.EnterpriseNumber = binary.BigEndian.Uint32(data[0:4])
+				data = data[4:]
+			}
+		}
+		templates = append(templates, t)
+	}
+	return templates, nil
+}
+
+// decodeNetFlowRecords decodes the records of a data set, up to its padding.
+func decodeNetFlowRecords(data []byte, t *NetFlowTemplate) ([]NetFlowRecord, error) {
+	min := t.minRecordLength()
+	if min == 0 {
+		return nil, nil
+	}
+	var records []NetFlowRecord
+	for len(data) >= min {
+		r := NetFlowRecord{
+			ScopeFieldCount: t.ScopeFieldCount,
+			Fields:          make([]NetFlowField, len(t.Fields)),
+		}
+		for i, spec := range t.Fields {
+			length := int(spec.Length)
+			if spec.Length == NetFlowVariableLength {
+				if len(data) < 1 {
+					return nil, fmt.Errorf("record of template %d too short", t.ID)
+				}
+				length, data = int(data[0]), data[1:]
+				if length == 255 {
+					if len(data) < 2 {
+						return nil, fmt.Errorf("record of template %d too short", t.ID)
+					}
+					length, data = int(binary.BigEndian.Uint16(data[0:2])), data[2:]
+				}
+			}
+			if len(data) < length {
+				return nil, fmt.Errorf("record of template %d too short", t.ID)
+			}
+			r.Fields[i] = NetFlowField{
+				Type:             spec.Type,
+				EnterpriseNumber: spec.EnterpriseNumber,
+				Value:            data[:length],
+			}
+			data = data[length:]
+		}
+		records = append(records, r)
+	}
+	return records, nil
+}
+
+// Add adds a template of an exporter to the cache, for version 9 or 10 (IPFIX)
+// and a source ID or observation domain, replacing any template with the
+// same ID.
+func (c *NetFlowTemplateCache) Add(exporter gopacket.Endpoint, version uint16, domain uint32, t NetFlowTemplate) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.templates[netFlowTemplateKey{exporter, version, domain, t.ID}] = &t
+}
+
+// netFlowExporter returns the source of the network layer of the packet being
+// built, the exporter templates are attached to.
+func netFlowExporter(p gopacket.PacketBuilder) gopacket.Endpoint {
+	if pkt, ok := p.(interface {
+		NetworkLayer() gopacket.NetworkLayer
+	}); ok {
+		if nl := pkt.NetworkLayer(); nl != nil {
+			return nl.NetworkFlow().Src()
+		}
+	}
+	return gopacket.Endpoint{}
+}
+
+func decodeNetFlow(data []byte, p gopacket.PacketBuilder) error {
+	if len(data) < 2 {
+		p.SetTruncated()
+		return errors.New("NetFlow packet too short")
+	}
+	switch version := binary.BigEndian.Uint16(data[0:2]); version {
+	case 5:
+		return decodingLayerDecoder(&NetFlowV5{}, data, p)
+	case 9:
+		return decodingLayerDecoder(&NetFlowV9{Exporter: netFlowExporter(p)}, data, p)
+	case 10:
+		return decodingLayerDecoder(&IPFIX{Exporter: netFlowExporter(p)}, data, p)
+	default:
+		return fmt.Errorf("unsupported NetFlow version %d", version)
+	}
+}
+
+// NetFlowV5 is a NetFlow version 5 export packet.
+type NetFlowV5 struct {
+	BaseLayer
+	Version uint16
+	Count   uint16
+	// SysUptime is the time since the exporter booted, in milliseconds
+	SysUptime        uint32
+	UnixSecs         uint32
+	UnixNsecs        uint32
+	FlowSequence     uint32
+	EngineType       uint8
+	EngineID         uint8
+	SamplingMode     uint8  // 2 bits
+	SamplingInterval uint16 // 14 bits
+	Records          []NetFlowV5Record
+}
+
+// NetFlowV5Record is a flow record of a NetFlow v5 packet.
+type NetFlowV5Record struct {
+	SrcAddr, DstAddr, NextHop net.IP
+	// Input and Output are SNMP interface indexes
+	Input, Output   uint16
+	Packets, Octets uint32
+	// First and Last are the SysUptime of the first and last packets
+	First, Last      uint32
+	SrcPort, DstPort uint16
+	TCPFlags         uint8
+	Protocol         IPProtocol
+	TOS              uint8
+	SrcAS, DstAS     uint16
+	SrcMask, DstMask uint8
+}
+
+// LayerType returns LayerTypeNetFlowV5.
+func (n *NetFlowV5) LayerType() gopacket.LayerType { return LayerTypeNetFlowV5 }
+
+// CanDecode returns LayerTypeNetFlowV5.
+func (n *NetFlowV5) CanDecode() gopacket.LayerClass { return LayerTypeNetFlowV5 }
+
+// NextLayerType returns gopacket.LayerTypeZero.
+func (n *NetFlowV5) NextLayerType() gopacket.LayerType { return gopacket.LayerTypeZero }
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (n *NetFlowV5) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 24 {
+		df.SetTruncated()
+		return errors.New("NetFlow v5 packet too short")
+	}
+	n.Version = binary.BigEndian.Uint16(data[0:2])
+	if n.Version != 5 {
+		return fmt.Errorf("invalid NetFlow v5 version %d", n.Version)
+	}
+	n.Count = binary.BigEndian.Uint16(data[2:4])
+	n.SysUptime = binary.BigEndian.Uint32(data[4:8])
+	n.UnixSecs = binary.BigEndian.Uint32(data[8:12])
+	n.UnixNsecs = binary.BigEndian.Uint32(data[12:16])
+	n.FlowSequence = binary.BigEndian.Uint32(data[16:20])
+	n.EngineType = data[20]
+	n.EngineID = data[21]
+	sampling := binary.BigEndian.Uint16(data[22:24])
+	n.SamplingMode = uint8(sampling >> 14)
+	n.SamplingInterval = sampling & 0x3fff
+
+	length := 24 + 48*int(n.Count)
+	if len(data) < length {
+		df.SetTruncated()
+		return fmt.Errorf("NetFlow v5 packet too short for %d records", n.Count)
+	}
+	n.Records = n.Records[:0]
+	for r := data[24:length]; len(r) != 0; r = r[48:] {
+		n.Records = append(n.Records, NetFlowV5Record{
+			SrcAddr:  net.IP(r[0:4]),
+			DstAddr:  net.IP(r[4:8]),
+			NextHop:  net.IP(r[8:12]),
+			Input:    binary.BigEndian.Uint16(r[12:14]),
+			Output:   binary.BigEndian.Uint16(r[14:16]),
+			Packets:  binary.BigEndian.Uint32(r[16:20]),
+			Octets:   binary.BigEndian.Uint32(r[20:24]),
+			First:    binary.BigEndian.Uint32(r[24:28]),
+			Last:     binary.BigEndian.Uint32(r[28:32]),
+			SrcPort:  binary.BigEndian.Uint16(r[32:34]),
+			DstPort:  binary.BigEndian.Uint16(r[34:36]),
+			TCPFlags: r[37],
+			Protocol: IPProtocol(r[38]),
+			TOS:      r[39],
+			SrcAS:    binary.BigEndian.Uint16(r[40:42]),
+			DstAS:    binary.BigEndian.Uint16(r[42:44]),
+			SrcMask:  r[44],
+			DstMask:  r[45],
+		})
+	}
+	n.BaseLayer = BaseLayer{Contents: data[:length], Payload: data[length:]}
+	return nil
+}
+
+// NetFlowV9 is a NetFlow version 9 export packet, as defined in RFC 3954.
+type NetFlowV9 struct {
+	BaseLayer
+	Version uint16
+	// Count is the number of template and data records of the packet
+	Count uint16
+	// SysUptime is the time since the exporter booted, in milliseconds
+	SysUptime      uint32
+	UnixSecs       uint32
+	SequenceNumber uint32
+	SourceID       uint32
+	FlowSets       []NetFlowSet
+
+	// Exporter and Templates are not decoded, but select the templates
+	// used to decode data records and updated by template records.  When
+	// decoding a packet, Exporter is the source of its network layer;
+	// Templates defaults to DefaultNetFlowTemplateCache.  Without a cache,
+	// only the templates of the packet itself are used.
+	Exporter  gopacket.Endpoint
+	Templates *NetFlowTemplateCache
+}
+
+// LayerType returns LayerTypeNetFlowV9.
+func (n *NetFlowV9) LayerType() gopacket.LayerType { return LayerTypeNetFlowV9 }
+
+// CanDecode returns LayerTypeNetFlowV9.
+func (n *NetFlowV9) CanDecode() gopacket.LayerClass { return LayerTypeNetFlowV9 }
+
+// NextLayerType returns gopacket.LayerTypeZero.
+func (n *NetFlowV9) NextLayerType() gopacket.LayerType { return gopacket.LayerTypeZero }
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (n *NetFlowV9) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 20 {
+		df.SetTruncated()
+		return errors.New("NetFlow v9 packet too short")
+	}
+	n.Version = binary.BigEndian.Uint16(data[0:2])
+	if n.Version != 9 {
+		return fmt.Errorf("invalid NetFlow v9 version %d", n.Version)
+	}
+	n.Count = binary.BigEndian.Uint16(data[2:4])
+	n.SysUptime = binary.BigEndian.Uint32(data[4:8])
+	n.UnixSecs = binary.BigEndian.Uint32(data[8:12])
+	n.SequenceNumber = binary.BigEndian.Uint32(data[12:16])
+	n.SourceID = binary.BigEndian.Uint32(data[16:20])
+
+	cache := n.Templates
+	if cache == nil {
+		cache = DefaultNetFlowTemplateCache
+	}
+	var err error
+	n.FlowSets, err = decodeNetFlowSets(data[20:], n.Exporter, 9, n.SourceID, cache, df)
+	if err != nil {
+		return err
+	}
+	n.BaseLayer = BaseLayer{Contents: data}
+	return nil
+}
+
+// IPFIX is an IPFIX message, as defined in RFC 7011.
+type IPFIX struct {
+	BaseLayer
+	Version uint16
+	Length  uint16
+	// ExportTime is in seconds since the epoch
+	ExportTime          uint32
+	SequenceNumber      uint32
+	ObservationDomainID uint32
+	Sets                []NetFlowSet
+
+	// Exporter and Templates are not decoded, but select the templates
+	// used to decode data records and updated by template records.  When
+	// decoding a packet, Exporter is the source of its network layer;
+	// Templates defaults to DefaultNetFlowTemplateCache.  Without a cache,
+	// only the templates of the packet itself are used.
+	Exporter  gopacket.Endpoint
+	Templates *NetFlowTemplateCache
+}
+
+// LayerType returns LayerTypeIPFIX.
+func (i *IPFIX) LayerType() gopacket.LayerType { return LayerTypeIPFIX }
+
+// CanDecode returns LayerTypeIPFIX.
+func (i *IPFIX) CanDecode() gopacket.LayerClass { return LayerTypeIPFIX }
+
+// NextLayerType returns gopacket.LayerTypeZero.
+func (i *IPFIX) NextLayerType() gopacket.LayerType { return gopacket.LayerTypeZero }
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (i *IPFIX) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 16 {
+		df.SetTruncated()
+		return errors.New("IPFIX message too short")
+	}
+	i.Version = binary.BigEndian.Uint16(data[0:2])
+	if i.Version != 10 {
+		return fmt.Errorf("invalid IPFIX version %d", i.Version)
+	}
+	i.Length = binary.BigEndian.Uint16(data[2:4])
+	i.ExportTime = binary.BigEndian.Uint32(data[4:8])
+	i.SequenceNumber = binary.BigEndian.Uint32(data[8:12])
+	i.ObservationDomainID = binary.BigEndian.Uint32(data[12:16])
+	if i.Length < 16 {
+		return fmt.Errorf("invalid IPFIX message length %d", i.Length)
+	}
+	if int(i.Length) > len(data) {
+		df.SetTruncated()
+		return fmt.Errorf("IPFIX message length %d too long for %d bytes", i.Length, len(data))
+	}
+
+	cache := i.Templates
+	if cache == nil {
+		cache = DefaultNetFlowTemplateCache
+	}
+	var err error
+	i.Sets, err = decodeNetFlowSets(data[16:i.Length], i.Exporter, 10, i.ObservationDomainID, cache, df)
+	if err != nil {
+		return err
+	}
+	i.BaseLayer = BaseLayer{Contents: data[:i.Length], Payload: data[i.Length:]}
+	return nil
+}
+
+// appendNetFlowTemplates appends the templates of a template or options
+// template set.
+func appendNetFlowTemplates(b []byte, set *NetFlowSet, version uint16) ([]byte, error) {
+	options := set.ID == NetFlowV9OptionsTemplateSetID || set.ID == IPFIXOptionsTemplateSetID
+	for _, t := range set.Templates {
+		var h [6]byte
+		binary.BigEndian.PutUint16(h[0:2], t.ID)
+		switch {
+		case version == 9 && len(t.Fields) == 0:
+			return nil, fmt.Errorf("NetFlow v9 template %d has no fields", t.ID)
+		case version == 9 && options:
+			binary.BigEndian.PutUint16(h[2:4], uint16(4*t.ScopeFieldCount))
+			binary.BigEndian.PutUint16(h[4:6], uint16(4*(len(t.Fields)-t.ScopeFieldCount)))
+			b = append(b, h[:6]...)
+		case options && len(t.Fields) != 0:
+			binary.BigEndian.PutUint16(h[2:4], uint16(len(t.Fields)))
+			binary.BigEndian.PutUint16(h[4:6], uint16(t.ScopeFieldCount))
+			b = append(b, h[:6]...)
+		default:
+			binary.BigEndian.PutUint16(h[2:4], uint16(len(t.Fields)))
+			b = append(b, h[:4]...)
+		}
+		for _, f := range t.Fields {
+			var fh [8]byte
+			binary.BigEndian.PutUint16(fh[0:2], uint16(f.Type))
+			binary.BigEndian.PutUint16(fh[2:4], f.Length)
+			if f.EnterpriseNumber == 0 {
+				b = append(b, fh[:4]...)
+				continue
+			}
+			if version == 9 {
+				return nil, fmt.Errorf("NetFlow v9 template %d has an enterprise field", t.ID)
+			}
+			fh[0] |= 0x80
+			binary.BigEndian.PutUint32(fh[4:8], f.EnterpriseNumber)
+			b = append(b, fh[:]...)
+		}
+	}
+	return b, nil
+}
+
+// appendNetFlowRecords appends data records encoded with their template.
+func appendNetFlowRecords(b []byte, records []NetFlowRecord, t *NetFlowTemplate) ([]byte, error) {
+	for _, r := range records {
+		if len(r.Fields) != len(t.Fields) {
+			return nil, fmt.Errorf("record with %d fields for template %d of %d fields", len(r.Fields), t.ID, len(t.Fields))
+		}
+		for i, spec := range t.Fields {
+			v := r.Fields[i].Value
+			switch {
+			case spec.Length != NetFlowVariableLength:
+				if len(v) != int(spec.Length) {
+					return nil, fmt.Errorf("field %v of %d bytes for template %d of %d bytes", spec.Type, len(v), t.ID, spec.Length)
+				}
+			case len(v) < 255:
+				b = append(b, byte(len(v)))
+			case len(v) <= 0xffff:
+				b = append(b, 255, byte(len(v)>>8), byte(len(v)))
+			default:
+				return nil, fmt.Errorf("field %v too long: %d bytes", spec.Type, len(v))
+			}
+			b = append(b, v...)
+		}
+	}
+	return b, nil
+}
+
+// serializeNetFlowSets prepends the sets of a NetFlow v9 or IPFIX packet to
+// b, and returns the number of records they hold.  Data records are encoded
+// with the templates of the packet, or else of the cache, if not nil.
+func serializeNetFlowSets(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions, sets []NetFlowSet, exporter gopacket.Endpoint, version uint16, domain uint32, cache *NetFlowTemplateCache) (int, error) {
+	template := func(id uint16) (*NetFlowTemplate, bool) {
+		for i := range sets {
+			for j := range sets[i].Templates {
+				if t := &sets[i].Templates[j]; t.ID == id && len(t.Fields) != 0 {
+					return t, true
+				}
+			}
+		}
+		if cache == nil {
+			return nil, false
+		}
+		return cache.Template(exporter, version, domain, id)
+	}
+	var data []byte
+	count := 0
+	for i := range sets {
+		set := &sets[i]
+		start := len(data)
+		data = append(data, 0, 0, 0, 0)
+		var err error
+		switch {
+		case len(set.Templates) != 0:
+			data, err = appendNetFlowTemplates(data, set, version)
+			count += len(set.Templates)
+		case len(set.Records) != 0:
+			t, ok := template(set.ID)
+			if !ok {
+				return 0, fmt.Errorf("no template %d for data set", set.ID)
+			}
+			data, err = appendNetFlowRecords(data, set.Records, t)
+			count += len(set.Records)
+		default:
+			data = append(data, set.Data...)
+		}
+		if err != nil {
+			return 0, err
+		}
+		if opts.FixLengths {
+			if len(data)-start > 0xffff {
+				return 0, fmt.Errorf("set %d too long: %d bytes", set.ID, len(data)-start)
+			}
+			set.Length = uint16(len(data) - start)
+		}
+		binary.BigEndian.PutUint16(data[start:], set.ID)
+		binary.BigEndian.PutUint16(data[start+2:], set.Length)
+	}
+	bytes, err := b.PrependBytes(len(data))
+	if err != nil {
+		return 0, err
+	}
+	copy(bytes, data)
+	return count, nil
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.  Data
+// records are encoded with the templates of the packet, or else of
+// Templates.  With FixLengths, Count and the lengths of the FlowSets are
+// set from their contents.
+func (n *NetFlowV9) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	cache := n.Templates
+	if cache == nil {
+		cache = DefaultNetFlowTemplateCache
+	}
+	count, err := serializeNetFlowSets(b, opts, n.FlowSets, n.Exporter, 9, n.SourceID, cache)
+	if err != nil {
+		return err
+	}
+	if opts.FixLengths {
+		n.Version = 9
+		n.Count = uint16(count)
+	}
+	bytes, err := b.PrependBytes(20)
+	if err != nil {
+		return err
+	}
+	binary.BigEndian.PutUint16(bytes[0:2], n.Version)
+	binary.BigEndian.PutUint16(bytes[2:4], n.Count)
+	binary.BigEndian.PutUint32(bytes[4:8], n.SysUptime)
+	binary.BigEndian.PutUint32(bytes[8:12], n.UnixSecs)
+	binary.BigEndian.PutUint32(bytes[12:16], n.SequenceNumber)
+	binary.BigEndian.PutUint32(bytes[16:20], n.SourceID)
+	return nil
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.  Data
+// records are encoded with the templates of the message, or else of
+// Templates.  With FixLengths, the lengths of the message and of its sets
+// are set from their contents.
+func (i *IPFIX) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	cache := i.Templates
+	if cache == nil {
+		cache = DefaultNetFlowTemplateCache
+	}
+	if _, err := serializeNetFlowSets(b, opts, i.Sets, i.Exporter, 10, i.ObservationDomainID, cache); err != nil {
+		return err
+	}
+	if opts.FixLengths {
+		length := 16 + len(b.Bytes())
+		if length > 0xffff {
+			return fmt.Errorf("IPFIX message too long: %d bytes", length)
+		}
+		i.Version = 10
+		i.Length = uint16(length)
+	}
+	bytes, err := b.PrependBytes(16)
+	if err != nil {
+		return err
+	}
+	binary.BigEndian.PutUint16(bytes[0:2], i.Version)
+	binary.BigEndian.PutUint16(bytes[2:4], i.Length)
+	binary.BigEndian.PutUint32(bytes[4:8], i.ExportTime)
+	binary.BigEndian.PutUint32(bytes[8:12], i.SequenceNumber)
+	binary.BigEndian.PutUint32(bytes[12:16], i.ObservationDomainID)
+	return nil
+}
@@ -0,0 +1,310 @@
+// Copyright 2026 The GoPacket Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+// netFlowBytes concatenates big endian fields: uint8, uint16, uint32, []byte
+// and strings.
+func netFlowBytes(fields ...interface{}) []byte {
+	var b bytes.Buffer
+	for _, f := range fields {
+		switch f := f.(type) {
+		case string:
+			b.WriteString(f)
+		default:
+			binary.Write(&b, binary.BigEndian, f)
+		}
+	}
+	return b.Bytes()
+}
+
+// netFlowSet returns a set with the given ID and contents.
+func netFlowSet(id uint16, contents ...interface{}) []byte {
+	data := netFlowBytes(contents...)
+	return netFlowBytes(id, uint16(4+len(data)), data)
+}
+
+// netFlowUDPPacket decodes payload sent from src to the given UDP port.
+func netFlowUDPPacket(t *testing.T, src net.IP, port UDPPort, payload []byte) gopacket.Packet {
+	ip := &IPv4{Version: 4, TTL: 64, Protocol: IPProtocolUDP, SrcIP: src, DstIP: net.IP{192, 0, 2, 1}}
+	udp := &UDP{SrcPort: 50000, DstPort: port}
+	udp.SetNetworkLayerForChecksum(ip)
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	if err := gopacket.SerializeLayers(buf, opts, ip, udp, gopacket.Payload(payload)); err != nil {
+		t.Fatal(err)
+	}
+	p := gopacket.NewPacket(buf.Bytes(), LayerTypeIPv4, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatalf("decoding error: %v", p.ErrorLayer().Error())
+	}
+	return p
+}
+
+func TestNetFlowV5(t *testing.T) {
+	data := netFlowBytes(
+		uint16(5), uint16(1), uint32(123456), uint32(1500000000), uint32(42), uint32(7),
+		uint8(1), uint8(2), uint16(0x4000|100),
+		[]byte{10, 0, 0, 1}, []byte{10, 0, 0, 2}, []byte{10, 0, 0, 254},
+		uint16(3), uint16(4), uint32(10), uint32(1500), uint32(1000), uint32(2000),
+		uint16(1234), uint16(80), uint8(0), uint8(0x1b), uint8(6), uint8(0),
+		uint16(65001), uint16(65002), uint8(24), uint8(16), uint16(0),
+	)
+	p := netFlowUDPPacket(t, net.IP{198, 51, 100, 5}, 2055, data)
+	n, ok := p.Layer(LayerTypeNetFlowV5).(*NetFlowV5)
+	if !ok {
+		t.Fatalf("no NetFlow v5 layer in %v", p)
+	}
+	if n.Count != 1 || n.SysUptime != 123456 || n.FlowSequence != 7 || n.EngineID != 2 ||
+		n.SamplingMode != 1 || n.SamplingInterval != 100 || len(n.Records) != 1 {
+		t.Fatalf("header: %+v", n)
+	}
+	r := n.Records[0]
+	if !r.SrcAddr.Equal(net.IP{10, 0, 0, 1}) || !r.NextHop.Equal(net.IP{10, 0, 0, 254}) || r.Octets != 1500 ||
+		r.DstPort != 80 || r.TCPFlags != 0x1b || r.Protocol != IPProtocolTCP || r.DstAS != 65002 || r.DstMask != 16 {
+		t.Errorf("record: %+v", r)
+	}
+
+	if err := n.DecodeFromBytes(data[:24+47], gopacket.NilDecodeFeedback); err == nil {
+		t.Error("truncated record decoded")
+	}
+}
+
+func TestNetFlowV9(t *testing.T) {
+	header := func(seq uint32) []byte {
+		return netFlowBytes(uint16(9), uint16(2), uint32(5000), uint32(1500000000), seq, uint32(33))
+	}
+	template := netFlowSet(NetFlowV9TemplateSetID,
+		uint16(256), uint16(4),
+		uint16(NetFlowFieldSourceIPv4Address), uint16(4),
+		uint16(NetFlowFieldDestinationIPv4Address), uint16(4),
+		uint16(NetFlowFieldOctetDeltaCount), uint16(4),
+		uint16(NetFlowFieldProtocolIdentifier), uint16(1),
+	)
+	options := netFlowSet(NetFlowV9OptionsTemplateSetID,
+		uint16(257), uint16(4), uint16(8),
+		uint16(1), uint16(4), // system scope
+		uint16(NetFlowFieldSamplingInterval), uint16(4),
+		uint16(NetFlowFieldSamplingAlgorithm), uint16(1),
+		uint16(0), // padding
+	)
+	exporter := net.IP{198, 51, 100, 9}
+	DefaultNetFlowTemplateCache = NewNetFlowTemplateCache()
+	defer func() { DefaultNetFlowTemplateCache = nil }()
+
+	// templates and records in the same packet
+	p := netFlowUDPPacket(t, exporter, 2055, netFlowBytes(header(1), template, options,
+		netFlowSet(257, []byte{192, 0, 2, 9}, uint32(100), uint8(2), []byte{0, 0, 0}),
+	))
+	n, ok := p.Layer(LayerTypeNetFlowV9).(*NetFlowV9)
+	if !ok {
+		t.Fatalf("no NetFlow v9 layer in %v", p)
+	}
+	if n.SourceID != 33 || len(n.FlowSets) != 3 || len(n.FlowSets[0].Templates) != 1 || len(n.FlowSets[1].Templates) != 1 {
+		t.Fatalf("packet: %+v", n)
+	}
+	if opt := n.FlowSets[1].Templates[0]; opt.ScopeFieldCount != 1 || len(opt.Fields) != 3 || opt.Fields[2].Length != 1 {
+		t.Errorf("options template: %+v", opt)
+	}
+	if recs := n.FlowSets[2].Records; len(recs) != 1 {
+		t.Errorf("options records: %+v", recs)
+	} else if v, ok := recs[0].Uint(NetFlowFieldSamplingInterval); !ok || v != 100 {
+		t.Errorf("sampling interval: %d, %v", v, ok)
+	} else if v, ok := recs[0].Uint(1); ok {
+		t.Errorf("scope field returned as field type 1: %d", v)
+	}
+
+	// records of a later packet, with padding
+	data := netFlowBytes(header(2), netFlowSet(256,
+		[]byte{10, 1, 1, 1}, []byte{10, 2, 2, 2}, uint32(1000), uint8(17),
+		[]byte{10, 1, 1, 2}, []byte{10, 2, 2, 3}, uint32(2000), uint8(6),
+		[]byte{0, 0},
+	))
+	p = netFlowUDPPacket(t, exporter, 2055, data)
+	n = p.Layer(LayerTypeNetFlowV9).(*NetFlowV9)
+	recs := n.FlowSets[0].Records
+	if len(recs) != 2 {
+		t.Fatalf("got %d records", len(recs))
+	}
+	if src, dst := recs[1].SrcIP(), recs[1].DstIP(); !src.Equal(net.IP{10, 1, 1, 2}) || !dst.Equal(net.IP{10, 2, 2, 3}) {
+		t.Errorf("addresses: %v, %v", src, dst)
+	}
+	if v, _ := recs[1].Uint(NetFlowFieldOctetDeltaCount); v != 2000 {
+		t.Errorf("octets: %d", v)
+	}
+	if v, _ := recs[0].Uint(NetFlowFieldProtocolIdentifier); IPProtocol(v) != IPProtocolUDP {
+		t.Errorf("protocol: %d", v)
+	}
+
+	// another exporter has not sent its templates
+	p = netFlowUDPPacket(t, net.IP{198, 51, 100, 10}, 2055, data)
+	n = p.Layer(LayerTypeNetFlowV9).(*NetFlowV9)
+	if s := n.FlowSets[0]; s.Records != nil || len(s.Data) != 28 {
+		t.Errorf("records decoded without template: %+v", s)
+	}
+
+	// templates are not remembered without a cache
+	DefaultNetFlowTemplateCache = nil
+	p = netFlowUDPPacket(t, net.IP{198, 51, 100, 11}, 2055, netFlowBytes(header(1), template))
+	p = netFlowUDPPacket(t, net.IP{198, 51, 100, 11}, 2055, data)
+	n = p.Layer(LayerTypeNetFlowV9).(*NetFlowV9)
+	if s := n.FlowSets[0]; s.Records != nil {
+		t.Errorf("records decoded without a cache: %+v", s)
+	}
+}
+
+func TestIPFIX(t *testing.T) {
+	message := func(sets ...interface{}) []byte {
+		data := netFlowBytes(sets...)
+		return netFlowBytes(uint16(10), uint16(16+len(data)), uint32(1500000000), uint32(0), uint32(7), data)
+	}
+	cache := NewNetFlowTemplateCache()
+	exporter := NewIPEndpoint(net.IP{198, 51, 100, 20})
+	decode := func(data []byte) *IPFIX {
+		i := &IPFIX{Exporter: exporter, Templates: cache}
+		if err := i.DecodeFromBytes(data, gopacket.NilDecodeFeedback); err != nil {
+			t.Fatal(err)
+		}
+		return i
+	}
+
+	long := string(bytes.Repeat([]byte{'a'}, 300))
+	i := decode(message(
+		netFlowSet(IPFIXTemplateSetID,
+			uint16(300), uint16(3),
+			uint16(NetFlowFieldSourceIPv6Address), uint16(16),
+			uint16(0x8000|1), uint16(4), uint32(9),
+			uint16(NetFlowFieldApplicationName), uint16(NetFlowVariableLength),
+		),
+		netFlowSet(IPFIXOptionsTemplateSetID,
+			uint16(301), uint16(2), uint16(1),
+			uint16(NetFlowFieldObservationDomainID), uint16(4),
+			uint16(NetFlowFieldSamplingPacketInterval), uint16(2),
+		),
+		netFlowSet(300,
+			net.ParseIP("2001:db8::1").To16(), uint32(5), uint8(4), "http",
+			net.ParseIP("2001:db8::2").To16(), uint32(6), uint8(255), uint16(300), long,
+		),
+		netFlowSet(301, uint32(7), uint16(1000)),
+	))
+	if i.ObservationDomainID != 7 || len(i.Sets) != 4 || cache.Len() != 2 {
+		t.Fatalf("message: %+v, %d templates", i, cache.Len())
+	}
+	tmpl, ok := cache.Template(exporter, 10, 7, 300)
+	if !ok || tmpl.Fields[1].Type != 1 || tmpl.Fields[1].EnterpriseNumber != 9 {
+		t.Errorf("template: %+v", tmpl)
+	}
+	recs := i.Sets[2].Records
+	if len(recs) != 2 {
+		t.Fatalf("got %d records", len(recs))
+	}
+	if !recs[0].SrcIP().Equal(net.ParseIP("2001:db8::1")) {
+		t.Errorf("source: %v", recs[0].SrcIP())
+	}
+	if f, _ := recs[0].Field(NetFlowFieldApplicationName); string(f.Value) != "http" {
+		t.Errorf("short variable length field: %q", f.Value)
+	}
+	if f, _ := recs[1].Field(NetFlowFieldApplicationName); string(f.Value) != long {
+		t.Errorf("long variable length field: %d bytes", len(f.Value))
+	}
+	if _, ok := recs[0].Field(1); ok {
+		t.Error("enterprise field returned as IANA field")
+	} else if f := recs[1].Fields[1]; f.EnterpriseNumber != 9 || f.Uint() != 6 {
+		t.Errorf("enterprise field: %+v", f)
+	}
+	if v, ok := i.Sets[3].Records[0].Uint(NetFlowFieldSamplingPacketInterval); !ok || v != 1000 {
+		t.Errorf("options record: %d, %v", v, ok)
+	}
+
+	// withdrawals
+	decode(message(netFlowSet(IPFIXTemplateSetID, uint16(300), uint16(0))))
+	if _, ok := cache.Template(exporter, 10, 7, 300); ok || cache.Len() != 1 {
+		t.Errorf("template not withdrawn, %d templates", cache.Len())
+	}
+	decode(message(netFlowSet(IPFIXOptionsTemplateSetID, uint16(IPFIXOptionsTemplateSetID), uint16(0))))
+	if cache.Len() != 0 {
+		t.Errorf("%d templates after withdrawing all", cache.Len())
+	}
+
+	// decoded through the IPFIX port
+	p := netFlowUDPPacket(t, net.IP{198, 51, 100, 21}, 4739, message(
+		netFlowSet(IPFIXTemplateSetID, uint16(256), uint16(1), uint16(NetFlowFieldPacketDeltaCount), uint16(8)),
+		netFlowSet(256, uint64(12345)),
+	))
+	if i, ok := p.Layer(LayerTypeIPFIX).(*IPFIX); !ok {
+		t.Errorf("no IPFIX layer in %v", p)
+	} else if v, _ := i.Sets[1].Records[0].Uint(NetFlowFieldPacketDeltaCount); v != 12345 {
+		t.Errorf("packets: %d", v)
+	}
+
+	if err := (&IPFIX{}).DecodeFromBytes(message()[:15], gopacket.NilDecodeFeedback); err == nil {
+		t.Error("truncated message decoded")
+	}
+}
+
+func TestIPFIXSerialize(t *testing.T) {
+	cache := NewNetFlowTemplateCache()
+	template := NetFlowTemplate{ID: 400, Fields: []NetFlowFieldSpec{
+		{Type: NetFlowFieldSourceIPv4Address, Length: 4},
+		{Type: 1, Length: 2, EnterpriseNumber: 9},
+		{Type: NetFlowFieldApplicationName, Length: NetFlowVariableLength},
+	}}
+	long := bytes.Repeat([]byte{'b'}, 256)
+	record := func(name []byte) NetFlowRecord {
+		return NetFlowRecord{Fields: []NetFlowField{
+			{Type: NetFlowFieldSourceIPv4Address, Value: []byte{10, 0, 0, 1}},
+			{Type: 1, EnterpriseNumber: 9, Value: []byte{0, 42}},
+			{Type: NetFlowFieldApplicationName, Value: name},
+		}}
+	}
+	msg := &IPFIX{
+		ExportTime:          1500000000,
+		SequenceNumber:      3,
+		ObservationDomainID: 8,
+		Sets: []NetFlowSet{
+			{ID: IPFIXTemplateSetID, Templates: []NetFlowTemplate{template}},
+			{ID: 400, Records: []NetFlowRecord{record([]byte("dns")), record(long)}},
+		},
+	}
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true}, msg); err != nil {
+		t.Fatal(err)
+	}
+	if int(msg.Length) != len(buf.Bytes()) || msg.Sets[1].Length != 4+2*6+(1+3)+(3+256) {
+		t.Errorf("lengths: message %d of %d bytes, set %d", msg.Length, len(buf.Bytes()), msg.Sets[1].Length)
+	}
+
+	i := &IPFIX{Templates: cache}
+	if err := i.DecodeFromBytes(buf.Bytes(), gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := cache.Template(gopacket.Endpoint{}, 10, 8, 400); !ok || !reflect.DeepEqual(*got, template) {
+		t.Errorf("template: got %+v, want %+v", got, template)
+	}
+	if recs := i.Sets[1].Records; len(recs) != 2 || !reflect.DeepEqual(recs[1], record(long)) {
+		t.Errorf("records: %+v", recs)
+	}
+
+	// records of a message without templates are encoded with the cache
+	msg = &IPFIX{ObservationDomainID: 8, Templates: cache, Sets: []NetFlowSet{{ID: 400, Records: []NetFlowRecord{record(nil)}}}}
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true}, msg); err != nil {
+		t.Fatal(err)
+	}
+	msg.Templates = NewNetFlowTemplateCache()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true}, msg); err == nil {
+		t.Error("records serialized without template")
+	}
+}
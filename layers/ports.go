@@ -61,6 +61,7 @@ func (a TCPPort) LayerType() gopacket.LayerType {
 
 var tcpPortLayerType = [65536]gopacket.LayerType{
 	53:   LayerTypeDNS,
+	179:  LayerTypeBGP,
 	443:  LayerTypeTLS,       // https
 	502:  LayerTypeModbusTCP, // modbustcp
 	636:  LayerTypeTLS,       // ldaps
@@ -70,6 +71,7 @@ var tcpPortLayerType = [65536]gopacket.LayerType{
 	993:  LayerTypeTLS,       // imaps
 	994:  LayerTypeTLS,       // ircs
 	995:  LayerTypeTLS,       // pop3s
+	3868: LayerTypeDiameter,  // diameter
 	5061: LayerTypeTLS,       // ips
 }
 
@@ -116,6 +118,12 @@ var udpPortLayerType = [65536]gopacket.LayerType{
 	3784: LayerTypeBFD,
 	2152: LayerTypeGTPv1U,
 	623:  LayerTypeRMCP,
+	2055: LayerTypeNetFlowV9,
+	4739: LayerTypeIPFIX,
+	443:  LayerTypeQUIC,
+	1812: LayerTypeRADIUS,
+	1813: LayerTypeRADIUS,
+	3799: LayerTypeRADIUS, // radius-dynauth
 }
 
 // RegisterUDPPortLayerType creates a new mapping between a UDPPort
@@ -144,6 +152,28 @@ func (a SCTPPort) String() string {
 	return strconv.Itoa(int(a))
 }
 
+// LayerType returns a LayerType that would be able to decode the
+// application payload of Data chunks which do not give their payload
+// protocol. It uses some well-known ports such as 3868 for Diameter.
+//
+// Returns gopacket.LayerTypePayload for unknown/unsupported port numbers.
+func (a SCTPPort) LayerType() gopacket.LayerType {
+	if lt, ok := sctpPortLayerType[a]; ok {
+		return lt
+	}
+	return gopacket.LayerTypePayload
+}
+
+var sctpPortLayerType = map[SCTPPort]gopacket.LayerType{
+	3868: LayerTypeDiameter,
+}
+
+// RegisterSCTPPortLayerType creates a new mapping between a SCTPPort
+// and an underlaying LayerType.
+func RegisterSCTPPortLayerType(port SCTPPort, layerType gopacket.LayerType) {
+	sctpPortLayerType[port] = layerType
+}
+
 // String returns the port as "number(name)" if there's a well-known port name,
 // or just "number" if there isn't.  Well-known names are stored in
 // UDPLitePortNames.
@@ -0,0 +1,650 @@
+// Copyright 2026 The GoPacket Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/google/gopacket"
+)
+
+// QUICVersion is the version of a QUIC long header packet.
+type QUICVersion uint32
+
+// QUICVersion known values.
+const (
+	// QUICVersionNegotiation is the version of Version Negotiation packets.
+	QUICVersionNegotiation QUICVersion = 0
+	QUICVersion1           QUICVersion = 1          // RFC 9000
+	QUICVersion2           QUICVersion = 0x6b3343cf // RFC 9369
+	QUICVersionDraft29     QUICVersion = 0xff00001d
+)
+
+func (v QUICVersion) String() string {
+	switch v {
+	case QUICVersionNegotiation:
+		return "Negotiation"
+	case QUICVersion1:
+		return "1"
+	case QUICVersion2:
+		return "2"
+	}
+	if v&0xffffff00 == 0xff000000 {
+		return fmt.Sprintf("draft-%d", uint8(v))
+	}
+	return fmt.Sprintf("Unknown(%#08x)", uint32(v))
+}
+
+// QUICPacketType is the type of a QUIC packet.
+type QUICPacketType uint8
+
+// QUICPacketType known values. The first four are long header packets whose
+// type is given by the header, with a version dependent encoding.
+const (
+	QUICPacketInitial QUICPacketType = iota
+	QUICPacket0RTT
+	QUICPacketHandshake
+	QUICPacketRetry
+	QUICPacketVersionNegotiation
+	// QUICPacket1RTT is the only short header packet.
+	QUICPacket1RTT
+)
+
+func (t QUICPacketType) String() string {
+	switch t {
+	case QUICPacketInitial:
+		return "Initial"
+	case QUICPacket0RTT:
+		return "0-RTT"
+	case QUICPacketHandshake:
+		return "Handshake"
+	case QUICPacketRetry:
+		return "Retry"
+	case QUICPacketVersionNegotiation:
+		return "VersionNegotiation"
+	case QUICPacket1RTT:
+		return "1-RTT"
+	}
+	return fmt.Sprintf("Unknown(%d)", uint8(t))
+}
+
+// QUICFrameType is the type of a QUIC frame.
+type QUICFrameType uint64
+
+// QUICFrameType values of the frames allowed in Initial and Handshake
+// packets, from RFC 9000 section 12.4.
+const (
+	QUICFramePadding         QUICFrameType = 0x00
+	QUICFramePing            QUICFrameType = 0x01
+	QUICFrameACK             QUICFrameType = 0x02
+	QUICFrameACKECN          QUICFrameType = 0x03
+	QUICFrameCrypto          QUICFrameType = 0x06
+	QUICFrameConnectionClose QUICFrameType = 0x1c
+)
+
+func (t QUICFrameType) String() string {
+	switch t {
+	case QUICFramePadding:
+		return "PADDING"
+	case QUICFramePing:
+		return "PING"
+	case QUICFrameACK:
+		return "ACK"
+	case QUICFrameACKECN:
+		return "ACK_ECN"
+	case QUICFrameCrypto:
+		return "CRYPTO"
+	case QUICFrameConnectionClose:
+		return "CONNECTION_CLOSE"
+	}
+	return fmt.Sprintf("UNKNOWN(%#x)", uint64(t))
+}
+
+// QUICACKRange is a range of packet numbers acknowledged by an ACK frame.
+type QUICACKRange struct {
+	Smallest, Largest uint64
+}
+
+// QUICFrame is a frame of a decrypted Initial packet.
+//
+// The fields following Type are filled in according to it; the others are
+// left to their zero value.
+type QUICFrame struct {
+	Type QUICFrameType
+	// Length is the number of bytes of a run of PADDING frames, which is
+	// reported as a single frame.
+	Length int
+	// ACKDelay and ACKRanges are set for ACK frames, and ECNCounts for ACK
+	// frames of type QUICFrameACKECN: ECT(0), ECT(1) and ECN-CE, in that
+	// order. The ranges are in decreasing order, the first one ends with the
+	// largest acknowledged packet.
+	ACKDelay  uint64
+	ACKRanges []QUICACKRange
+	ECNCounts [3]uint64
+	// Offset and Data are set for CRYPTO frames.
+	Offset uint64
+	Data   []byte
+	// ErrorCode, FrameType and ReasonPhrase are set for CONNECTION_CLOSE
+	// frames.
+	ErrorCode    uint64
+	FrameType    uint64
+	ReasonPhrase string
+}
+
+// QUICPacket is a QUIC packet, as specified in RFC 9000 section 17.
+//
+// Only the parts of the header which are not protected are decoded from the
+// packet as sent. Initial packets are protected with keys derived from
+// public data, and can be decrypted with DecryptInitial.
+//
+// Versions other than the known ones are assumed to share the version 1
+// packet layout.
+type QUICPacket struct {
+	Type QUICPacketType
+	// Version, DestinationConnectionID and SourceConnectionID are set for
+	// long header packets. A 1-RTT packet does not carry the length of its
+	// destination connection ID: it starts Protected.
+	Version                 QUICVersion
+	DestinationConnectionID []byte
+	SourceConnectionID      []byte
+	// SupportedVersions are the versions listed by a Version Negotiation
+	// packet.
+	SupportedVersions []QUICVersion
+	// Token is the token of an Initial or Retry packet.
+	Token []byte
+	// RetryIntegrityTag is the tag ending a Retry packet.
+	RetryIntegrityTag []byte
+	// SpinBit is the latency spin bit of a 1-RTT packet.
+	SpinBit bool
+	// Contents is the whole packet.
+	Contents []byte
+	// Protected is the part of an Initial, 0-RTT, Handshake or 1-RTT packet
+	// following the header fields which are not protected: the packet
+	// number and the encrypted payload.
+	Protected []byte
+
+	// Decrypted is set once the packet has been decrypted. PacketNumber is
+	// then the packet number as sent, without its most significant bytes,
+	// Plaintext is the decrypted payload and Frames are its frames.
+	Decrypted    bool
+	PacketNumber uint64
+	Plaintext    []byte
+	Frames       []QUICFrame
+}
+
+// QUIC holds the QUIC packets of a UDP datagram: several long header
+// packets may be coalesced, possibly followed by a 1-RTT packet, which
+// extends to the end of the datagram.
+//
+// Client Initial packets are decrypted while decoding, to give access to the
+// TLS ClientHello, in Handshake. Server Initial packets are protected with
+// keys derived from the Destination Connection ID of the first Initial
+// packet of the client: callers following connections decrypt them with
+// QUICPacket.DecryptInitial. A ClientHello too large for a single datagram
+// is reassembled across datagrams with a QUICCryptoStream.
+type QUIC struct {
+	BaseLayer
+	Packets []QUICPacket
+	// Handshake holds the TLS handshake messages carried whole by the
+	// CRYPTO frames of the decrypted Initial packets of the datagram.
+	Handshake []TLSHandshakeMessage
+}
+
+// LayerType returns gopacket.LayerTypeQUIC.
+func (q *QUIC) LayerType() gopacket.LayerType { return LayerTypeQUIC }
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode
+func (q *QUIC) CanDecode() gopacket.LayerClass { return LayerTypeQUIC }
+
+// NextLayerType returns the layer type contained by this DecodingLayer
+func (q *QUIC) NextLayerType() gopacket.LayerType { return gopacket.LayerTypeZero }
+
+// Payload returns nil, since the payload of QUIC packets is encrypted.
+func (q *QUIC) Payload() []byte { return nil }
+
+// ClientHello returns the ClientHello of Handshake, or nil.
+func (q *QUIC) ClientHello() *TLSClientHello {
+	for _, m := range q.Handshake {
+		if m.ClientHello != nil {
+			return m.ClientHello
+		}
+	}
+	return nil
+}
+
+func decodeQUIC(data []byte, p gopacket.PacketBuilder) error {
+	q := &QUIC{}
+	err := q.DecodeFromBytes(data, p)
+	if err != nil {
+		return err
+	}
+	p.AddLayer(q)
+	p.SetApplicationLayer(q)
+	return nil
+}
+
+var errQUICTruncated = errors.New("QUIC packet truncated")
+
+// DecodeFromBytes decodes the slice into the QUIC struct.
+func (q *QUIC) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	q.Packets = q.Packets[:0]
+	q.Handshake = nil
+
+	rest := data
+	for len(rest) > 0 {
+		// Datagrams may be padded after their last packet, with bytes
+		// which are not a packet header.
+		if len(q.Packets) > 0 && rest[0]&0x40 == 0 {
+			break
+		}
+		var p QUICPacket
+		n, err := p.decodeFromBytes(rest)
+		if err == errQUICTruncated {
+			df.SetTruncated()
+		}
+		if err != nil {
+			if len(q.Packets) == 0 {
+				return err
+			}
+			break
+		}
+		q.Packets = append(q.Packets, p)
+		rest = rest[n:]
+	}
+	q.BaseLayer = BaseLayer{Contents: data}
+
+	var cs QUICCryptoStream
+	for i := range q.Packets {
+		p := &q.Packets[i]
+		if p.Type != QUICPacketInitial {
+			continue
+		}
+		// Server packets fail authentication with the client keys.
+		p.DecryptInitial(p.DestinationConnectionID, false)
+		for j := range p.Frames {
+			msgs, _ := cs.Add(&p.Frames[j])
+			q.Handshake = append(q.Handshake, msgs...)
+		}
+	}
+	return nil
+}
+
+// quicLongPacketTypes maps the type bits of long headers to packet types.
+var quicLongPacketTypes = [2][4]QUICPacketType{
+	{QUICPacketInitial, QUICPacket0RTT, QUICPacketHandshake, QUICPacketRetry},
+	// RFC 9369 section 3.2
+	{QUICPacketRetry, QUICPacketInitial, QUICPacket0RTT, QUICPacketHandshake},
+}
+
+// decodeFromBytes decodes the packet starting data, and returns its length.
+func (p *QUICPacket) decodeFromBytes(data []byte) (int, error) {
+	first := data[0]
+	if first&0x80 == 0 {
+		if first&0x40 == 0 {
+			return 0, errors.New("QUIC short header with fixed bit unset")
+		}
+		p.Type = QUICPacket1RTT
+		p.SpinBit = first&0x20 != 0
+		p.Contents = data
+		p.Protected = data[1:]
+		return len(data), nil
+	}
+
+	if len(data) < 7 {
+		return 0, errQUICTruncated
+	}
+	p.Version = QUICVersion(binary.BigEndian.Uint32(data[1:5]))
+	off := 5
+	for _, cid := range []*[]byte{&p.DestinationConnectionID, &p.SourceConnectionID} {
+		if off >= len(data) {
+			return 0, errQUICTruncated
+		}
+		l := int(data[off])
+		if l > 20 && p.Version != QUICVersionNegotiation {
+			return 0, fmt.Errorf("QUIC connection ID length %d too long", l)
+		}
+		off++
+		if off+l > len(data) {
+			return 0, errQUICTruncated
+		}
+		*cid = data[off : off+l]
+		off += l
+	}
+
+	if p.Version == QUICVersionNegotiation {
+		p.Type = QUICPacketVersionNegotiation
+		versions := data[off:]
+		if len(versions)%4 != 0 {
+			return 0, fmt.Errorf("QUIC Version Negotiation versions length %d not a multiple of 4", len(versions))
+		}
+		for ; len(versions) > 0; versions = versions[4:] {
+			p.SupportedVersions = append(p.SupportedVersions, QUICVersion(binary.BigEndian.Uint32(versions)))
+		}
+		p.Contents = data
+		return len(data), nil
+	}
+	if first&0x40 == 0 {
+		return 0, errors.New("QUIC long header with fixed bit unset")
+	}
+	v2 := 0
+	if p.Version == QUICVersion2 {
+		v2 = 1
+	}
+	p.Type = quicLongPacketTypes[v2][first>>4&0x3]
+
+	switch p.Type {
+	case QUICPacketRetry:
+		if len(data)-off < 16 {
+			return 0, errQUICTruncated
+		}
+		p.Token = data[off : len(data)-16]
+		p.RetryIntegrityTag = data[len(data)-16:]
+		p.Contents = data
+		return len(data), nil
+	case QUICPacketInitial:
+		l, n := quicVarint(data[off:])
+		if n == 0 || uint64(len(data)-off-n) < l {
+			return 0, errQUICTruncated
+		}
+		off += n
+		p.Token = data[off : off+int(l)]
+		off += int(l)
+	}
+	l, n := quicVarint(data[off:])
+	if n == 0 || uint64(len(data)-off-n) < l {
+		return 0, errQUICTruncated
+	}
+	off += n
+	p.Contents = data[:off+int(l)]
+	p.Protected = data[off : off+int(l)]
+	return off + int(l), nil
+}
+
+// DecryptInitial removes the protection of an Initial packet, with the keys
+// derived from the Destination Connection ID of the first Initial packet
+// sent by the client, and decodes its frames. fromServer selects the keys
+// of the server packets.
+func (p *QUICPacket) DecryptInitial(dcid []byte, fromServer bool) error {
+	p.Decrypted = false
+	p.PacketNumber = 0
+	p.Plaintext = nil
+	p.Frames = nil
+	if p.Type != QUICPacketInitial {
+		return fmt.Errorf("QUIC %s packet is not an Initial packet", p.Type)
+	}
+	key, iv, hp, err := quicInitialKeys(p.Version, dcid, fromServer)
+	if err != nil {
+		return err
+	}
+	// The header protection mask is computed from a sample of the payload
+	// assuming a 4 bytes packet number (RFC 9001 section 5.4.2).
+	if len(p.Protected) < 4+16 {
+		return errors.New("QUIC Initial packet too short to remove header protection")
+	}
+	hpCipher, _ := aes.NewCipher(hp)
+	var mask [16]byte
+	hpCipher.Encrypt(mask[:], p.Protected[4:20])
+
+	// the header is unprotected in a copy, which is the associated data
+	hdrLen := len(p.Contents) - len(p.Protected)
+	header := append([]byte(nil), p.Contents[:hdrLen+4]...)
+	header[0] ^= mask[0] & 0x0f
+	pnLen := int(header[0]&0x3) + 1
+	header = header[:hdrLen+pnLen]
+	var pn uint64
+	for i := 0; i < pnLen; i++ {
+		header[hdrLen+i] ^= mask[1+i]
+		pn = pn<<8 | uint64(header[hdrLen+i])
+	}
+
+	block, _ := aes.NewCipher(key)
+	aead, _ := cipher.NewGCM(block)
+	nonce := iv
+	for i := 0; i < 8; i++ {
+		nonce[len(nonce)-1-i] ^= byte(pn >> (8 * uint(i)))
+	}
+	plaintext, err := aead.Open(nil, nonce, p.Protected[pnLen:], header)
+	if err != nil {
+		return errors.New("QUIC Initial packet authentication failed")
+	}
+	p.Decrypted = true
+	p.PacketNumber = pn
+	p.Plaintext = plaintext
+	p.Frames, err = decodeQUICFrames(plaintext)
+	return err
+}
+
+// quicInitialSalt is the salt and the prefix of the labels used to derive
+// Initial keys for a version.
+type quicInitialSalt struct {
+	salt   []byte
+	prefix string
+}
+
+var quicInitialSalts = map[QUICVersion]quicInitialSalt{
+	QUICVersion1: {[]byte{
+		0x38, 0x76, 0x2c, 0xf7, 0xf5, 0x59, 0x34, 0xb3, 0x4d, 0x17,
+		0x9a, 0xe6, 0xa4, 0xc8, 0x0c, 0xad, 0xcc, 0xbb, 0x7f, 0x0a,
+	}, "quic "},
+	QUICVersion2: {[]byte{
+		0x0d, 0xed, 0xe3, 0xde, 0xf7, 0x00, 0xa6, 0xdb, 0x81, 0x93,
+		0x81, 0xbe, 0x6e, 0x26, 0x9d, 0xcb, 0xf9, 0xbd, 0x2e, 0xd9,
+	}, "quicv2 "},
+	QUICVersionDraft29: {[]byte{
+		0xaf, 0xbf, 0xec, 0x28, 0x99, 0x93, 0xd2, 0x4c, 0x9e, 0x97,
+		0x86, 0xf1, 0x9c, 0x61, 0x11, 0xe0, 0x43, 0x90, 0xa8, 0x99,
+	}, "quic "},
+}
+
+// quicInitialKeys derives the AEAD key and IV and the header protection key
+// of the Initial packets of one side of a connection, as specified in RFC
+// 9001 section 5.2.
+func quicInitialKeys(v QUICVersion, dcid []byte, server bool) (key, iv, hp []byte, err error) {
+	s, ok := quicInitialSalts[v]
+	if !ok {
+		return nil, nil, nil, fmt.Errorf("no QUIC Initial keys for version %v", v)
+	}
+	mac := hmac.New(sha256.New, s.salt)
+	mac.Write(dcid)
+	label := "client in"
+	if server {
+		label = "server in"
+	}
+	secret := hkdfExpandLabel(mac.Sum(nil), label, sha256.Size)
+	key = hkdfExpandLabel(secret, s.prefix+"key", 16)
+	iv = hkdfExpandLabel(secret, s.prefix+"iv", 12)
+	hp = hkdfExpandLabel(secret, s.prefix+"hp", 16)
+	return key, iv, hp, nil
+}
+
+// hkdfExpandLabel is the TLS 1.3 HKDF-Expand-Label function with SHA-256
+// and an empty context.
+func hkdfExpandLabel(secret []byte, label string, length int) []byte {
+	info := []byte{byte(length >> 8), byte(length), byte(len("tls13 ") + len(label))}
+	info = append(info, "tls13 "...)
+	info = append(info, label...)
+	info = append(info, 0)
+
+	var out, t []byte
+	for i := byte(1); len(out) < length; i++ {
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(t)
+		mac.Write(info)
+		mac.Write([]byte{i})
+		t = mac.Sum(nil)
+		out = append(out, t...)
+	}
+	return out[:length]
+}
+
+// quicVarint decodes a variable-length integer, and returns its value and
+// length, or a zero length if data is too short.
+func quicVarint(data []byte) (uint64, int) {
+	if len(data) == 0 {
+		return 0, 0
+	}
+	l := 1 << (data[0] >> 6)
+	if len(data) < l {
+		return 0, 0
+	}
+	v := uint64(data[0] & 0x3f)
+	for _, b := range data[1:l] {
+		v = v<<8 | uint64(b)
+	}
+	return v, l
+}
+
+// quicReader walks the fields of QUIC frames; the first error sticks.
+type quicReader struct {
+	data []byte
+	err  error
+}
+
+func (r *quicReader) varint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := quicVarint(r.data)
+	if n == 0 {
+		r.err = errors.New("QUIC frame truncated")
+	}
+	r.data = r.data[n:]
+	return v
+}
+
+func (r *quicReader) bytes(n uint64) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n > uint64(len(r.data)) {
+		r.err = errors.New("QUIC frame truncated")
+		return nil
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+// decodeQUICFrames decodes the frames of an Initial or Handshake packet
+// payload.
+func decodeQUICFrames(data []byte) ([]QUICFrame, error) {
+	var frames []QUICFrame
+	r := quicReader{data: data}
+	for len(r.data) > 0 {
+		f := QUICFrame{Type: QUICFrameType(r.varint())}
+		switch f.Type {
+		case QUICFramePadding:
+			f.Length = 1
+			for f.Length-1 < len(r.data) && r.data[f.Length-1] == 0 {
+				f.Length++
+			}
+			r.data = r.data[f.Length-1:]
+		case QUICFramePing:
+		case QUICFrameACK, QUICFrameACKECN:
+			largest := r.varint()
+			f.ACKDelay = r.varint()
+			count := r.varint()
+			first := r.varint()
+			if first > largest {
+				return frames, errors.New("QUIC ACK frame first range too long")
+			}
+			f.ACKRanges = []QUICACKRange{{largest - first, largest}}
+			for i := uint64(0); i < count && r.err == nil; i++ {
+				smallest := f.ACKRanges[len(f.ACKRanges)-1].Smallest
+				gap, length := r.varint(), r.varint()
+				if smallest < gap+2 || smallest-gap-2 < length {
+					return frames, errors.New("QUIC ACK frame range below zero")
+				}
+				largest = smallest - gap - 2
+				f.ACKRanges = append(f.ACKRanges, QUICACKRange{largest - length, largest})
+			}
+			if f.Type == QUICFrameACKECN {
+				for i := range f.ECNCounts {
+					f.ECNCounts[i] = r.varint()
+				}
+			}
+		case QUICFrameCrypto:
+			f.Offset = r.varint()
+			f.Data = r.bytes(r.varint())
+		case QUICFrameConnectionClose:
+			f.ErrorCode = r.varint()
+			f.FrameType = r.varint()
+			f.ReasonPhrase = string(r.bytes(r.varint()))
+		default:
+			if r.err == nil {
+				return frames, fmt.Errorf("QUIC frame %v not allowed in Initial and Handshake packets", f.Type)
+			}
+		}
+		if r.err != nil {
+			return frames, r.err
+		}
+		frames = append(frames, f)
+	}
+	return frames, nil
+}
+
+// quicMaxPendingCrypto is the number of CRYPTO frames a QUICCryptoStream
+// keeps while waiting for the data preceding them.
+const quicMaxPendingCrypto = 64
+
+// QUICCryptoStream reassembles the CRYPTO frames sent by one side of a
+// connection in one packet number space into TLS handshake messages.
+type QUICCryptoStream struct {
+	offset  uint64
+	pending map[uint64][]byte
+	hs      TLSHandshakeReassembler
+}
+
+// Add feeds a frame, CRYPTO frames being accepted in any order, and returns
+// the handshake messages it completes. The data of frames received ahead of
+// the data preceding them is kept until then.
+func (s *QUICCryptoStream) Add(f *QUICFrame) ([]TLSHandshakeMessage, error) {
+	if f.Type != QUICFrameCrypto || f.Offset+uint64(len(f.Data)) <= s.offset {
+		return nil, nil
+	}
+	if f.Offset > s.offset {
+		if s.pending == nil {
+			s.pending = make(map[uint64][]byte)
+		}
+		if old, ok := s.pending[f.Offset]; !ok || len(old) < len(f.Data) {
+			if len(s.pending) >= quicMaxPendingCrypto {
+				return nil, errors.New("too many QUIC CRYPTO frames pending")
+			}
+			s.pending[f.Offset] = f.Data
+		}
+		return nil, nil
+	}
+
+	var msgs []TLSHandshakeMessage
+	data := f.Data[s.offset-f.Offset:]
+	for data != nil {
+		m, err := s.hs.Add(data)
+		if err != nil {
+			return msgs, err
+		}
+		msgs = append(msgs, m...)
+		s.offset += uint64(len(data))
+		data = nil
+		for off, d := range s.pending {
+			if off > s.offset {
+				continue
+			}
+			delete(s.pending, off)
+			if off+uint64(len(d)) > s.offset {
+				data = d[s.offset-off:]
+				break
+			}
+		}
+	}
+	return msgs, nil
+}
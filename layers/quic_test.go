@@ -0,0 +1,202 @@
+// Copyright 2026 The GoPacket Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/hex"
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+func mustDecodeHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// The keys of RFC 9001 appendix A.1.
+func TestQUICInitialKeys(t *testing.T) {
+	dcid := mustDecodeHex("8394c8f03e515708")
+	for _, want := range []struct {
+		server      bool
+		key, iv, hp string
+	}{
+		{false, "1f369613dd76d5467730efcbe3b1a22d", "fa044b2f42a3fd3b46fb255c", "9f50449e04a0e810283a1e9933adedd2"},
+		{true, "cf3a5331653c364c88f0f379b6067e37", "0ac1493ca1905853b0bba03e", "c206b8d9b9f0f37644430b490eeaa314"},
+	} {
+		key, iv, hp, err := quicInitialKeys(QUICVersion1, dcid, want.server)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hex.EncodeToString(key) != want.key || hex.EncodeToString(iv) != want.iv || hex.EncodeToString(hp) != want.hp {
+			t.Errorf("server %v: key %x, iv %x, hp %x", want.server, key, iv, hp)
+		}
+	}
+	if _, _, _, err := quicInitialKeys(0x1a2a3a4a, dcid, false); err == nil {
+		t.Error("keys for an unknown version")
+	}
+}
+
+// quicTestClientHello returns a ClientHello handshake message with the SNI
+// and ALPN extensions.
+func quicTestClientHello() []byte {
+	ext := func(typ byte, body ...byte) []byte {
+		return append([]byte{0, typ, 0, byte(len(body))}, body...)
+	}
+	sni := ext(0, append([]byte{0, 14, 0, 0, 11}, "example.com"...)...)
+	alpn := ext(16, 0, 3, 2, 'h', '3')
+	body := []byte{3, 3}
+	body = append(body, make([]byte, 32)...)
+	body = append(body, 0, 0, 2, 0x13, 0x01, 1, 0, 0, byte(len(sni)+len(alpn)))
+	body = append(body, sni...)
+	body = append(body, alpn...)
+	return append([]byte{1, 0, 0, byte(len(body))}, body...)
+}
+
+// quicTestInitial returns a version 1 client Initial packet with a 2 bytes
+// packet number.
+func quicTestInitial(dcid, scid []byte, pn uint16, payload []byte) []byte {
+	key, iv, hp, _ := quicInitialKeys(QUICVersion1, dcid, false)
+	hdr := append([]byte{0xc1, 0, 0, 0, 1, byte(len(dcid))}, dcid...)
+	hdr = append(hdr, byte(len(scid)))
+	hdr = append(hdr, scid...)
+	length := 2 + len(payload) + 16
+	hdr = append(hdr, 0, 0x40|byte(length>>8), byte(length), byte(pn>>8), byte(pn))
+
+	block, _ := aes.NewCipher(key)
+	aead, _ := cipher.NewGCM(block)
+	iv[10] ^= byte(pn >> 8)
+	iv[11] ^= byte(pn)
+	pkt := aead.Seal(append([]byte(nil), hdr...), iv, payload, hdr)
+
+	pnOff := len(hdr) - 2
+	var mask [16]byte
+	block, _ = aes.NewCipher(hp)
+	block.Encrypt(mask[:], pkt[pnOff+4:pnOff+20])
+	pkt[0] ^= mask[0] & 0x0f
+	pkt[pnOff] ^= mask[1]
+	pkt[pnOff+1] ^= mask[2]
+	return pkt
+}
+
+func TestQUICInitial(t *testing.T) {
+	dcid, scid := mustDecodeHex("8394c8f03e515708"), []byte{1, 2, 3, 4}
+	hello := quicTestClientHello()
+	// CRYPTO frames out of order, an ACK frame with two ranges, padding
+	payload := append([]byte{0x06, 0x0a, 0x40, byte(len(hello) - 10)}, hello[10:]...)
+	payload = append(payload, 0x06, 0x00, 0x0a)
+	payload = append(payload, hello[:10]...)
+	payload = append(payload, 0x02, 0x0a, 0x00, 0x01, 0x01, 0x02, 0x03)
+	payload = append(payload, make([]byte, 1000)...)
+	data := quicTestInitial(dcid, scid, 2, payload)
+	// a coalesced Handshake packet, and padding
+	data = append(data, 0xe0, 0, 0, 0, 1, 1, 0x55, 0, 4, 1, 2, 3, 4, 0, 0, 0)
+
+	udp := &UDP{SrcPort: 50000, DstPort: 443}
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true}, udp, gopacket.Payload(data)); err != nil {
+		t.Fatal(err)
+	}
+	p := gopacket.NewPacket(buf.Bytes(), LayerTypeUDP, gopacket.Default)
+	q, ok := p.Layer(LayerTypeQUIC).(*QUIC)
+	if !ok {
+		t.Fatalf("no QUIC layer: %v", p)
+	}
+	if p.ApplicationLayer() != q || len(q.Packets) != 2 {
+		t.Fatalf("application layer %v, %d packets", p.ApplicationLayer(), len(q.Packets))
+	}
+
+	initial := q.Packets[0]
+	if initial.Type != QUICPacketInitial || initial.Version != QUICVersion1 || !bytes.Equal(initial.DestinationConnectionID, dcid) ||
+		!bytes.Equal(initial.SourceConnectionID, scid) || len(initial.Token) != 0 {
+		t.Errorf("Initial header: %+v", initial)
+	}
+	if !initial.Decrypted || initial.PacketNumber != 2 || len(initial.Frames) != 4 {
+		t.Fatalf("Initial packet decrypted %v, number %d, %d frames", initial.Decrypted, initial.PacketNumber, len(initial.Frames))
+	}
+	if ack := initial.Frames[2]; ack.Type != QUICFrameACK || ack.ACKDelay != 0 ||
+		len(ack.ACKRanges) != 2 || ack.ACKRanges[0] != (QUICACKRange{9, 10}) || ack.ACKRanges[1] != (QUICACKRange{2, 5}) {
+		t.Errorf("ACK frame: %+v", ack)
+	}
+	if pad := initial.Frames[3]; pad.Type != QUICFramePadding || pad.Length != 1000 {
+		t.Errorf("PADDING frame: %+v", pad)
+	}
+	ch := q.ClientHello()
+	if ch == nil || ch.ServerName != "example.com" || len(ch.ALPN) != 1 || ch.ALPN[0] != "h3" {
+		t.Errorf("ClientHello: %+v", ch)
+	}
+
+	if hs := q.Packets[1]; hs.Type != QUICPacketHandshake || hs.Decrypted || !bytes.Equal(hs.Protected, []byte{1, 2, 3, 4}) {
+		t.Errorf("Handshake packet: %+v", hs)
+	}
+
+	// the server keys do not authenticate a client packet
+	if err := initial.DecryptInitial(dcid, true); err == nil || initial.Decrypted {
+		t.Errorf("decrypted with the server keys: %v", err)
+	}
+}
+
+func TestQUICHeaders(t *testing.T) {
+	// Version Negotiation
+	p := gopacket.NewPacket([]byte{0x80, 0, 0, 0, 0, 1, 0xaa, 2, 0xbb, 0xcc, 0, 0, 0, 1, 0x6b, 0x33, 0x43, 0xcf}, LayerTypeQUIC, gopacket.Default)
+	if q, ok := p.Layer(LayerTypeQUIC).(*QUIC); !ok || len(q.Packets) != 1 || q.Packets[0].Type != QUICPacketVersionNegotiation ||
+		len(q.Packets[0].SupportedVersions) != 2 || q.Packets[0].SupportedVersions[1] != QUICVersion2 {
+		t.Errorf("Version Negotiation: %v", p)
+	}
+
+	// version 2 Retry
+	retry := append([]byte{0xc0, 0x6b, 0x33, 0x43, 0xcf, 0, 1, 0xaa, 't', 'o', 'k'}, make([]byte, 16)...)
+	p = gopacket.NewPacket(retry, LayerTypeQUIC, gopacket.Default)
+	if q, ok := p.Layer(LayerTypeQUIC).(*QUIC); !ok || q.Packets[0].Type != QUICPacketRetry || string(q.Packets[0].Token) != "tok" ||
+		len(q.Packets[0].RetryIntegrityTag) != 16 {
+		t.Errorf("Retry: %v", p)
+	}
+
+	// 1-RTT
+	p = gopacket.NewPacket([]byte{0x61, 1, 2, 3, 4}, LayerTypeQUIC, gopacket.Default)
+	if q, ok := p.Layer(LayerTypeQUIC).(*QUIC); !ok || q.Packets[0].Type != QUICPacket1RTT || !q.Packets[0].SpinBit ||
+		len(q.Packets[0].Protected) != 4 {
+		t.Errorf("1-RTT: %v", p)
+	}
+
+	// a Handshake packet longer than the datagram
+	p = gopacket.NewPacket([]byte{0xe0, 0, 0, 0, 1, 0, 0, 0x10, 1, 2}, LayerTypeQUIC, gopacket.Default)
+	if p.ErrorLayer() == nil || !p.Metadata().Truncated {
+		t.Errorf("truncated packet: %v", p)
+	}
+	if p := gopacket.NewPacket([]byte{0x01, 2, 3}, LayerTypeQUIC, gopacket.Default); p.ErrorLayer() == nil {
+		t.Errorf("fixed bit unset: %v", p)
+	}
+}
+
+func TestQUICCryptoStream(t *testing.T) {
+	hello := quicTestClientHello()
+	var s QUICCryptoStream
+	for i, f := range []QUICFrame{
+		{Type: QUICFrameCrypto, Offset: 20, Data: hello[20:]},
+		{Type: QUICFramePing},
+		{Type: QUICFrameCrypto, Offset: 0, Data: hello[:10]},
+		{Type: QUICFrameCrypto, Offset: 5, Data: hello[5:25]},
+	} {
+		msgs, err := s.Add(&f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := i == 3; (len(msgs) == 1) != want {
+			t.Fatalf("frame %d: %d messages", i, len(msgs))
+		}
+		if len(msgs) == 1 && msgs[0].ClientHello.ServerName != "example.com" {
+			t.Errorf("ClientHello: %+v", msgs[0].ClientHello)
+		}
+	}
+}
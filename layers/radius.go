@@ -0,0 +1,600 @@
+// Copyright 2026 The GoPacket Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/google/gopacket"
+)
+
+const (
+	radiusHeaderLength = 20
+	// radiusMaxLength is the maximum length of a RADIUS packet, of RFC 2865
+	// section 3.
+	radiusMaxLength = 4096
+)
+
+// RADIUSCode is the code of a RADIUS packet.
+type RADIUSCode uint8
+
+// RADIUSCode known values.
+const (
+	RADIUSCodeAccessRequest      RADIUSCode = 1
+	RADIUSCodeAccessAccept       RADIUSCode = 2
+	RADIUSCodeAccessReject       RADIUSCode = 3
+	RADIUSCodeAccountingRequest  RADIUSCode = 4 // RFC 2866
+	RADIUSCodeAccountingResponse RADIUSCode = 5 // RFC 2866
+	RADIUSCodeAccessChallenge    RADIUSCode = 11
+	RADIUSCodeStatusServer       RADIUSCode = 12 // RFC 5997
+	RADIUSCodeStatusClient       RADIUSCode = 13
+	RADIUSCodeDisconnectRequest  RADIUSCode = 40 // RFC 5176
+	RADIUSCodeDisconnectACK      RADIUSCode = 41
+	RADIUSCodeDisconnectNAK      RADIUSCode = 42
+	RADIUSCodeCoARequest         RADIUSCode = 43
+	RADIUSCodeCoAACK             RADIUSCode = 44
+	RADIUSCodeCoANAK             RADIUSCode = 45
+)
+
+func (c RADIUSCode) String() string {
+	switch c {
+	case RADIUSCodeAccessRequest:
+		return "Access-Request"
+	case RADIUSCodeAccessAccept:
+		return "Access-Accept"
+	case RADIUSCodeAccessReject:
+		return "Access-Reject"
+	case RADIUSCodeAccountingRequest:
+		return "Accounting-Request"
+	case RADIUSCodeAccountingResponse:
+		return "Accounting-Response"
+	case RADIUSCodeAccessChallenge:
+		return "Access-Challenge"
+	case RADIUSCodeStatusServer:
+		return "Status-Server"
+	case RADIUSCodeStatusClient:
+		return "Status-Client"
+	case RADIUSCodeDisconnectRequest:
+		return "Disconnect-Request"
+	case RADIUSCodeDisconnectACK:
+		return "Disconnect-ACK"
+	case RADIUSCodeDisconnectNAK:
+		return "Disconnect-NAK"
+	case RADIUSCodeCoARequest:
+		return "CoA-Request"
+	case RADIUSCodeCoAACK:
+		return "CoA-ACK"
+	case RADIUSCodeCoANAK:
+		return "CoA-NAK"
+	}
+	return fmt.Sprintf("Unknown(%d)", uint8(c))
+}
+
+// Response returns whether packets of the code answer a request, their
+// authenticator then being computed from the one of the request.
+func (c RADIUSCode) Response() bool {
+	switch c {
+	case RADIUSCodeAccessAccept, RADIUSCodeAccessReject, RADIUSCodeAccessChallenge, RADIUSCodeAccountingResponse,
+		RADIUSCodeDisconnectACK, RADIUSCodeDisconnectNAK, RADIUSCodeCoAACK, RADIUSCodeCoANAK:
+		return true
+	}
+	return false
+}
+
+// RADIUSDataType is the data type of the value of an attribute, as named in
+// RFC 8044.
+type RADIUSDataType uint8
+
+// RADIUSDataType values.
+const (
+	// RADIUSDataString is any sequence of bytes, and the type of unknown
+	// attributes.
+	RADIUSDataString RADIUSDataType = iota
+	RADIUSDataText
+	RADIUSDataInteger
+	RADIUSDataTime
+	RADIUSDataIPv4Address
+	RADIUSDataIPv6Address
+	RADIUSDataIPv6Prefix
+	RADIUSDataVSA
+)
+
+// RADIUSAttributeType is the type of a RADIUS attribute.
+type RADIUSAttributeType uint8
+
+// RADIUSAttributeType known values, of RFC 2865, RFC 2866, RFC 2868,
+// RFC 2869, RFC 3162, RFC 4372 and RFC 5176.
+const (
+	RADIUSAttributeUserName               RADIUSAttributeType = 1
+	RADIUSAttributeUserPassword           RADIUSAttributeType = 2
+	RADIUSAttributeCHAPPassword           RADIUSAttributeType = 3
+	RADIUSAttributeNASIPAddress           RADIUSAttributeType = 4
+	RADIUSAttributeNASPort                RADIUSAttributeType = 5
+	RADIUSAttributeServiceType            RADIUSAttributeType = 6
+	RADIUSAttributeFramedProtocol         RADIUSAttributeType = 7
+	RADIUSAttributeFramedIPAddress        RADIUSAttributeType = 8
+	RADIUSAttributeFramedIPNetmask        RADIUSAttributeType = 9
+	RADIUSAttributeFramedRouting          RADIUSAttributeType = 10
+	RADIUSAttributeFilterID               RADIUSAttributeType = 11
+	RADIUSAttributeFramedMTU              RADIUSAttributeType = 12
+	RADIUSAttributeFramedCompression      RADIUSAttributeType = 13
+	RADIUSAttributeLoginIPHost            RADIUSAttributeType = 14
+	RADIUSAttributeLoginService           RADIUSAttributeType = 15
+	RADIUSAttributeLoginTCPPort           RADIUSAttributeType = 16
+	RADIUSAttributeReplyMessage           RADIUSAttributeType = 18
+	RADIUSAttributeCallbackNumber         RADIUSAttributeType = 19
+	RADIUSAttributeCallbackID             RADIUSAttributeType = 20
+	RADIUSAttributeFramedRoute            RADIUSAttributeType = 22
+	RADIUSAttributeFramedIPXNetwork       RADIUSAttributeType = 23
+	RADIUSAttributeState                  RADIUSAttributeType = 24
+	RADIUSAttributeClass                  RADIUSAttributeType = 25
+	RADIUSAttributeVendorSpecific         RADIUSAttributeType = 26
+	RADIUSAttributeSessionTimeout         RADIUSAttributeType = 27
+	RADIUSAttributeIdleTimeout            RADIUSAttributeType = 28
+	RADIUSAttributeTerminationAction      RADIUSAttributeType = 29
+	RADIUSAttributeCalledStationID        RADIUSAttributeType = 30
+	RADIUSAttributeCallingStationID       RADIUSAttributeType = 31
+	RADIUSAttributeNASIdentifier          RADIUSAttributeType = 32
+	RADIUSAttributeProxyState             RADIUSAttributeType = 33
+	RADIUSAttributeAcctStatusType         RADIUSAttributeType = 40
+	RADIUSAttributeAcctDelayTime          RADIUSAttributeType = 41
+	RADIUSAttributeAcctInputOctets        RADIUSAttributeType = 42
+	RADIUSAttributeAcctOutputOctets       RADIUSAttributeType = 43
+	RADIUSAttributeAcctSessionID          RADIUSAttributeType = 44
+	RADIUSAttributeAcctAuthentic          RADIUSAttributeType = 45
+	RADIUSAttributeAcctSessionTime        RADIUSAttributeType = 46
+	RADIUSAttributeAcctInputPackets       RADIUSAttributeType = 47
+	RADIUSAttributeAcctOutputPackets      RADIUSAttributeType = 48
+	RADIUSAttributeAcctTerminateCause     RADIUSAttributeType = 49
+	RADIUSAttributeAcctMultiSessionID     RADIUSAttributeType = 50
+	RADIUSAttributeAcctLinkCount          RADIUSAttributeType = 51
+	RADIUSAttributeAcctInputGigawords     RADIUSAttributeType = 52
+	RADIUSAttributeAcctOutputGigawords    RADIUSAttributeType = 53
+	RADIUSAttributeEventTimestamp         RADIUSAttributeType = 55
+	RADIUSAttributeCHAPChallenge          RADIUSAttributeType = 60
+	RADIUSAttributeNASPortType            RADIUSAttributeType = 61
+	RADIUSAttributePortLimit              RADIUSAttributeType = 62
+	RADIUSAttributeTunnelType             RADIUSAttributeType = 64
+	RADIUSAttributeTunnelMediumType       RADIUSAttributeType = 65
+	RADIUSAttributeConnectInfo            RADIUSAttributeType = 77
+	RADIUSAttributeEAPMessage             RADIUSAttributeType = 79
+	RADIUSAttributeMessageAuthenticator   RADIUSAttributeType = 80
+	RADIUSAttributeTunnelPrivateGroupID   RADIUSAttributeType = 81
+	RADIUSAttributeAcctInterimInterval    RADIUSAttributeType = 85
+	RADIUSAttributeNASPortID              RADIUSAttributeType = 87
+	RADIUSAttributeFramedPool             RADIUSAttributeType = 88
+	RADIUSAttributeChargeableUserIdentity RADIUSAttributeType = 89
+	RADIUSAttributeNASIPv6Address         RADIUSAttributeType = 95
+	RADIUSAttributeFramedInterfaceID      RADIUSAttributeType = 96
+	RADIUSAttributeFramedIPv6Prefix       RADIUSAttributeType = 97
+	RADIUSAttributeLoginIPv6Host          RADIUSAttributeType = 98
+	RADIUSAttributeFramedIPv6Route        RADIUSAttributeType = 99
+	RADIUSAttributeFramedIPv6Pool         RADIUSAttributeType = 100
+	RADIUSAttributeErrorCause             RADIUSAttributeType = 101
+)
+
+// radiusAttributes holds the name and data type of the known attributes.
+// Tagged attributes, such as Tunnel-Type, are strings since their first
+// byte may be a tag.
+var radiusAttributes = map[RADIUSAttributeType]struct {
+	name     string
+	dataType RADIUSDataType
+}{
+	RADIUSAttributeUserName:               {"User-Name", RADIUSDataText},
+	RADIUSAttributeUserPassword:           {"User-Password", RADIUSDataString},
+	RADIUSAttributeCHAPPassword:           {"CHAP-Password", RADIUSDataString},
+	RADIUSAttributeNASIPAddress:           {"NAS-IP-Address", RADIUSDataIPv4Address},
+	RADIUSAttributeNASPort:                {"NAS-Port", RADIUSDataInteger},
+	RADIUSAttributeServiceType:            {"Service-Type", RADIUSDataInteger},
+	RADIUSAttributeFramedProtocol:         {"Framed-Protocol", RADIUSDataInteger},
+	RADIUSAttributeFramedIPAddress:        {"Framed-IP-Address", RADIUSDataIPv4Address},
+	RADIUSAttributeFramedIPNetmask:        {"Framed-IP-Netmask", RADIUSDataIPv4Address},
+	RADIUSAttributeFramedRouting:          {"Framed-Routing", RADIUSDataInteger},
+	RADIUSAttributeFilterID:               {"Filter-Id", RADIUSDataText},
+	RADIUSAttributeFramedMTU:              {"Framed-MTU", RADIUSDataInteger},
+	RADIUSAttributeFramedCompression:      {"Framed-Compression", RADIUSDataInteger},
+	RADIUSAttributeLoginIPHost:            {"Login-IP-Host", RADIUSDataIPv4Address},
+	RADIUSAttributeLoginService:           {"Login-Service", RADIUSDataInteger},
+	RADIUSAttributeLoginTCPPort:           {"Login-TCP-Port", RADIUSDataInteger},
+	RADIUSAttributeReplyMessage:           {"Reply-Message", RADIUSDataText},
+	RADIUSAttributeCallbackNumber:         {"Callback-Number", RADIUSDataText},
+	RADIUSAttributeCallbackID:             {"Callback-Id", RADIUSDataText},
+	RADIUSAttributeFramedRoute:            {"Framed-Route", RADIUSDataText},
+	RADIUSAttributeFramedIPXNetwork:       {"Framed-IPX-Network", RADIUSDataIPv4Address},
+	RADIUSAttributeState:                  {"State", RADIUSDataString},
+	RADIUSAttributeClass:                  {"Class", RADIUSDataString},
+	RADIUSAttributeVendorSpecific:         {"Vendor-Specific", RADIUSDataVSA},
+	RADIUSAttributeSessionTimeout:         {"Session-Timeout", RADIUSDataInteger},
+	RADIUSAttributeIdleTimeout:            {"Idle-Timeout", RADIUSDataInteger},
+	RADIUSAttributeTerminationAction:      {"Termination-Action", RADIUSDataInteger},
+	RADIUSAttributeCalledStationID:        {"Called-Station-Id", RADIUSDataText},
+	RADIUSAttributeCallingStationID:       {"Calling-Station-Id", RADIUSDataText},
+	RADIUSAttributeNASIdentifier:          {"NAS-Identifier", RADIUSDataText},
+	RADIUSAttributeProxyState:             {"Proxy-State", RADIUSDataString},
+	RADIUSAttributeAcctStatusType:         {"Acct-Status-Type", RADIUSDataInteger},
+	RADIUSAttributeAcctDelayTime:          {"Acct-Delay-Time", RADIUSDataInteger},
+	RADIUSAttributeAcctInputOctets:        {"Acct-Input-Octets", RADIUSDataInteger},
+	RADIUSAttributeAcctOutputOctets:       {"Acct-Output-Octets", RADIUSDataInteger},
+	RADIUSAttributeAcctSessionID:          {"Acct-Session-Id", RADIUSDataText},
+	RADIUSAttributeAcctAuthentic:          {"Acct-Authentic", RADIUSDataInteger},
+	RADIUSAttributeAcctSessionTime:        {"Acct-Session-Time", RADIUSDataInteger},
+	RADIUSAttributeAcctInputPackets:       {"Acct-Input-Packets", RADIUSDataInteger},
+	RADIUSAttributeAcctOutputPackets:      {"Acct-Output-Packets", RADIUSDataInteger},
+	RADIUSAttributeAcctTerminateCause:     {"Acct-Terminate-Cause", RADIUSDataInteger},
+	RADIUSAttributeAcctMultiSessionID:     {"Acct-Multi-Session-Id", RADIUSDataText},
+	RADIUSAttributeAcctLinkCount:          {"Acct-Link-Count", RADIUSDataInteger},
+	RADIUSAttributeAcctInputGigawords:     {"Acct-Input-Gigawords", RADIUSDataInteger},
+	RADIUSAttributeAcctOutputGigawords:    {"Acct-Output-Gigawords", RADIUSDataInteger},
+	RADIUSAttributeEventTimestamp:         {"Event-Timestamp", RADIUSDataTime},
+	RADIUSAttributeCHAPChallenge:          {"CHAP-Challenge", RADIUSDataString},
+	RADIUSAttributeNASPortType:            {"NAS-Port-Type", RADIUSDataInteger},
+	RADIUSAttributePortLimit:              {"Port-Limit", RADIUSDataInteger},
+	RADIUSAttributeTunnelType:             {"Tunnel-Type", RADIUSDataString},
+	RADIUSAttributeTunnelMediumType:       {"Tunnel-Medium-Type", RADIUSDataString},
+	RADIUSAttributeConnectInfo:            {"Connect-Info", RADIUSDataText},
+	RADIUSAttributeEAPMessage:             {"EAP-Message", RADIUSDataString},
+	RADIUSAttributeMessageAuthenticator:   {"Message-Authenticator", RADIUSDataString},
+	RADIUSAttributeTunnelPrivateGroupID:   {"Tunnel-Private-Group-ID", RADIUSDataString},
+	RADIUSAttributeAcctInterimInterval:    {"Acct-Interim-Interval", RADIUSDataInteger},
+	RADIUSAttributeNASPortID:              {"NAS-Port-Id", RADIUSDataText},
+	RADIUSAttributeFramedPool:             {"Framed-Pool", RADIUSDataText},
+	RADIUSAttributeChargeableUserIdentity: {"Chargeable-User-Identity", RADIUSDataString},
+	RADIUSAttributeNASIPv6Address:         {"NAS-IPv6-Address", RADIUSDataIPv6Address},
+	RADIUSAttributeFramedInterfaceID:      {"Framed-Interface-Id", RADIUSDataString},
+	RADIUSAttributeFramedIPv6Prefix:       {"Framed-IPv6-Prefix", RADIUSDataIPv6Prefix},
+	RADIUSAttributeLoginIPv6Host:          {"Login-IPv6-Host", RADIUSDataIPv6Address},
+	RADIUSAttributeFramedIPv6Route:        {"Framed-IPv6-Route", RADIUSDataText},
+	RADIUSAttributeFramedIPv6Pool:         {"Framed-IPv6-Pool", RADIUSDataText},
+	RADIUSAttributeErrorCause:             {"Error-Cause", RADIUSDataInteger},
+}
+
+func (t RADIUSAttributeType) String() string {
+	if a, ok := radiusAttributes[t]; ok {
+		return a.name
+	}
+	return fmt.Sprintf("Unknown(%d)", uint8(t))
+}
+
+// DataType returns the data type of the values of attributes of the type.
+func (t RADIUSAttributeType) DataType() RADIUSDataType {
+	return radiusAttributes[t].dataType
+}
+
+// RADIUSVendorAttribute is an attribute of a Vendor-Specific attribute, in
+// the format recommended by RFC 2865 section 5.26.
+type RADIUSVendorAttribute struct {
+	Type  uint8
+	Value []byte
+}
+
+// RADIUSAttribute is an attribute of a RADIUS packet.
+//
+// VendorID is set for Vendor-Specific attributes, and VendorAttributes when
+// their value follows the recommended format. Value is serialized as is; a
+// Vendor-Specific attribute without a Value is serialized from VendorID and
+// VendorAttributes.
+type RADIUSAttribute struct {
+	Type   RADIUSAttributeType
+	Length uint8
+	Value  []byte
+
+	VendorID         uint32
+	VendorAttributes []RADIUSVendorAttribute
+}
+
+// Integer returns the value of an integer attribute.
+func (a *RADIUSAttribute) Integer() (uint32, error) {
+	if len(a.Value) != 4 {
+		return 0, fmt.Errorf("RADIUS %v attribute of %d bytes is not an integer", a.Type, len(a.Value))
+	}
+	return binary.BigEndian.Uint32(a.Value), nil
+}
+
+// Time returns the value of a time attribute.
+func (a *RADIUSAttribute) Time() (time.Time, error) {
+	if len(a.Value) != 4 {
+		return time.Time{}, fmt.Errorf("RADIUS %v attribute of %d bytes is not a time", a.Type, len(a.Value))
+	}
+	return time.Unix(int64(binary.BigEndian.Uint32(a.Value)), 0).UTC(), nil
+}
+
+// IP returns the value of an IPv4 or IPv6 address attribute.
+func (a *RADIUSAttribute) IP() (net.IP, error) {
+	if len(a.Value) != net.IPv4len && len(a.Value) != net.IPv6len {
+		return nil, fmt.Errorf("RADIUS %v attribute of %d bytes is not an address", a.Type, len(a.Value))
+	}
+	return net.IP(a.Value), nil
+}
+
+// IPv6Prefix returns the value of an IPv6 prefix attribute, as specified in
+// RFC 3162 section 2.3.
+func (a *RADIUSAttribute) IPv6Prefix() (*net.IPNet, error) {
+	if len(a.Value) < 2 || len(a.Value) > 18 || int(a.Value[1]) > 128 || (int(a.Value[1])+7)/8 > len(a.Value)-2 {
+		return nil, fmt.Errorf("RADIUS %v attribute is not a valid IPv6 prefix", a.Type)
+	}
+	ip := make(net.IP, net.IPv6len)
+	copy(ip, a.Value[2:])
+	mask := net.CIDRMask(int(a.Value[1]), 128)
+	return &net.IPNet{IP: ip.Mask(mask), Mask: mask}, nil
+}
+
+func (a *RADIUSAttribute) decodeVendorSpecific() {
+	if len(a.Value) < 4 {
+		return
+	}
+	a.VendorID = binary.BigEndian.Uint32(a.Value)
+	for rest := a.Value[4:]; len(rest) > 0; {
+		if len(rest) < 2 || rest[1] < 2 || int(rest[1]) > len(rest) {
+			// not the recommended format
+			a.VendorAttributes = nil
+			return
+		}
+		a.VendorAttributes = append(a.VendorAttributes, RADIUSVendorAttribute{Type: rest[0], Value: rest[2:rest[1]]})
+		rest = rest[rest[1]:]
+	}
+}
+
+// RADIUS is a RADIUS packet, as specified in RFC 2865 and RFC 2866.
+type RADIUS struct {
+	BaseLayer
+	Code          RADIUSCode
+	Identifier    uint8
+	Length        uint16
+	Authenticator [16]byte
+	Attributes    []RADIUSAttribute
+}
+
+// LayerType returns gopacket.LayerTypeRADIUS.
+func (r *RADIUS) LayerType() gopacket.LayerType { return LayerTypeRADIUS }
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode
+func (r *RADIUS) CanDecode() gopacket.LayerClass { return LayerTypeRADIUS }
+
+// NextLayerType returns the layer type contained by this DecodingLayer
+func (r *RADIUS) NextLayerType() gopacket.LayerType { return gopacket.LayerTypeZero }
+
+// Payload returns nil.
+func (r *RADIUS) Payload() []byte { return nil }
+
+// Attribute returns the first attribute of the given type, or nil.
+func (r *RADIUS) Attribute(t RADIUSAttributeType) *RADIUSAttribute {
+	for i := range r.Attributes {
+		if r.Attributes[i].Type == t {
+			return &r.Attributes[i]
+		}
+	}
+	return nil
+}
+
+// EAPMessage returns the EAP packet carried by the EAP-Message attributes,
+// which are concatenated as specified in RFC 3579 section 3.1, or nil.
+func (r *RADIUS) EAPMessage() []byte {
+	var eap []byte
+	for i := range r.Attributes {
+		if r.Attributes[i].Type == RADIUSAttributeEAPMessage {
+			eap = append(eap, r.Attributes[i].Value...)
+		}
+	}
+	return eap
+}
+
+func decodeRADIUS(data []byte, p gopacket.PacketBuilder) error {
+	r := &RADIUS{}
+	err := r.DecodeFromBytes(data, p)
+	if err != nil {
+		return err
+	}
+	p.AddLayer(r)
+	p.SetApplicationLayer(r)
+	return nil
+}
+
+// DecodeFromBytes decodes the slice into the RADIUS struct. Bytes following
+// the length of the packet are ignored, as padding.
+func (r *RADIUS) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < radiusHeaderLength {
+		df.SetTruncated()
+		return errors.New("RADIUS packet too short")
+	}
+	length := int(binary.BigEndian.Uint16(data[2:4]))
+	if length < radiusHeaderLength || length > radiusMaxLength {
+		return fmt.Errorf("invalid RADIUS packet length %d", length)
+	}
+	if len(data) < length {
+		df.SetTruncated()
+		return fmt.Errorf("RADIUS packet length %d exceeds the %d bytes of data", length, len(data))
+	}
+	r.Code = RADIUSCode(data[0])
+	r.Identifier = data[1]
+	r.Length = uint16(length)
+	copy(r.Authenticator[:], data[4:radiusHeaderLength])
+
+	r.Attributes = r.Attributes[:0]
+	for attrs := data[radiusHeaderLength:length]; len(attrs) > 0; {
+		if len(attrs) < 2 || attrs[1] < 2 || int(attrs[1]) > len(attrs) {
+			return errors.New("invalid RADIUS attribute length")
+		}
+		a := RADIUSAttribute{
+			Type:   RADIUSAttributeType(attrs[0]),
+			Length: attrs[1],
+			Value:  attrs[2:attrs[1]],
+		}
+		if a.Type == RADIUSAttributeVendorSpecific {
+			a.decodeVendorSpecific()
+		}
+		r.Attributes = append(r.Attributes, a)
+		attrs = attrs[attrs[1]:]
+	}
+	r.BaseLayer = BaseLayer{Contents: data[:length]}
+	return nil
+}
+
+// VerifyAuthenticator checks the authenticator of a response, given the
+// authenticator of its request, or the one of an Accounting-Request, CoA-Request
+// or Disconnect-Request, against the shared secret. The authenticator of
+// other requests is random.
+func (r *RADIUS) VerifyAuthenticator(secret, requestAuthenticator []byte) error {
+	var auth []byte
+	switch {
+	case r.Code.Response():
+		if len(requestAuthenticator) != 16 {
+			return fmt.Errorf("RADIUS %v authenticator needs the one of the request", r.Code)
+		}
+		auth = requestAuthenticator
+	case r.Code == RADIUSCodeAccountingRequest || r.Code == RADIUSCodeCoARequest || r.Code == RADIUSCodeDisconnectRequest:
+		auth = make([]byte, 16)
+	default:
+		return fmt.Errorf("RADIUS %v authenticator is random", r.Code)
+	}
+	if len(r.Contents) < radiusHeaderLength {
+		return errors.New("RADIUS packet was not decoded")
+	}
+	h := md5.New()
+	h.Write(r.Contents[:4])
+	h.Write(auth)
+	h.Write(r.Contents[radiusHeaderLength:])
+	h.Write(secret)
+	if !hmac.Equal(h.Sum(nil), r.Authenticator[:]) {
+		return errors.New("RADIUS authenticator does not match")
+	}
+	return nil
+}
+
+// VerifyMessageAuthenticator checks the Message-Authenticator attribute of
+// the packet, as specified in RFC 3579 section 3.2 and RFC 5176,
+// against the shared secret. The authenticator of the request is needed for
+// responses, and ignored otherwise.
+func (r *RADIUS) VerifyMessageAuthenticator(secret, requestAuthenticator []byte) error {
+	sum, field, err := radiusMessageAuthenticator(r.Contents, secret, requestAuthenticator)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal(sum, field) {
+		return errors.New("RADIUS Message-Authenticator does not match")
+	}
+	return nil
+}
+
+// SignRADIUSMessageAuthenticator sets the value of the Message-Authenticator
+// attribute of a serialized RADIUS packet, computed with the shared secret
+// and, for responses, the authenticator of the request. The attribute must
+// be in the packet, with a 16 bytes value.
+func SignRADIUSMessageAuthenticator(packet, secret, requestAuthenticator []byte) error {
+	sum, field, err := radiusMessageAuthenticator(packet, secret, requestAuthenticator)
+	if err != nil {
+		return err
+	}
+	copy(field, sum)
+	return nil
+}
+
+// radiusMessageAuthenticator returns the Message-Authenticator of packet,
+// and the value of its attribute within packet.
+func radiusMessageAuthenticator(packet, secret, requestAuthenticator []byte) (sum, field []byte, err error) {
+	if len(packet) < radiusHeaderLength {
+		return nil, nil, errors.New("RADIUS packet too short")
+	}
+	length := int(binary.BigEndian.Uint16(packet[2:4]))
+	if length < radiusHeaderLength || length > len(packet) {
+		return nil, nil, fmt.Errorf("invalid RADIUS packet length %d", length)
+	}
+	packet = packet[:length]
+	off := radiusHeaderLength
+	for ; off < length; off += int(packet[off+1]) {
+		if length-off < 2 || packet[off+1] < 2 || off+int(packet[off+1]) > length {
+			return nil, nil, errors.New("invalid RADIUS attribute length")
+		}
+		if RADIUSAttributeType(packet[off]) == RADIUSAttributeMessageAuthenticator {
+			break
+		}
+	}
+	if off == length {
+		return nil, nil, errors.New("RADIUS packet without Message-Authenticator")
+	}
+	if packet[off+1] != 18 {
+		return nil, nil, errors.New("invalid RADIUS Message-Authenticator length")
+	}
+	field = packet[off+2 : off+18]
+
+	var zero [16]byte
+	auth := packet[4:radiusHeaderLength]
+	switch code := RADIUSCode(packet[0]); {
+	case code.Response():
+		if len(requestAuthenticator) != 16 {
+			return nil, nil, fmt.Errorf("RADIUS %v Message-Authenticator needs the authenticator of the request", code)
+		}
+		auth = requestAuthenticator
+	case code == RADIUSCodeAccountingRequest || code == RADIUSCodeCoARequest || code == RADIUSCodeDisconnectRequest:
+		auth = zero[:]
+	}
+	mac := hmac.New(md5.New, secret)
+	mac.Write(packet[:4])
+	mac.Write(auth)
+	mac.Write(packet[radiusHeaderLength : off+2])
+	mac.Write(zero[:])
+	mac.Write(packet[off+18:])
+	return mac.Sum(nil), field, nil
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+func (r *RADIUS) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	data := make([]byte, radiusHeaderLength, 256)
+	for i := range r.Attributes {
+		var err error
+		if data, err = r.Attributes[i].appendTo(data, opts); err != nil {
+			return err
+		}
+	}
+	if len(data) > radiusMaxLength {
+		return fmt.Errorf("RADIUS packet too long: %d bytes", len(data))
+	}
+	if opts.FixLengths {
+		r.Length = uint16(len(data))
+	}
+	data[0] = byte(r.Code)
+	data[1] = r.Identifier
+	binary.BigEndian.PutUint16(data[2:4], r.Length)
+	copy(data[4:radiusHeaderLength], r.Authenticator[:])
+
+	bytes, err := b.PrependBytes(len(data))
+	if err != nil {
+		return err
+	}
+	copy(bytes, data)
+	return nil
+}
+
+func (a *RADIUSAttribute) appendTo(b []byte, opts gopacket.SerializeOptions) ([]byte, error) {
+	value := a.Value
+	if value == nil && a.Type == RADIUSAttributeVendorSpecific {
+		value = make([]byte, 4)
+		binary.BigEndian.PutUint32(value, a.VendorID)
+		for _, va := range a.VendorAttributes {
+			if len(va.Value) > 253 {
+				return nil, fmt.Errorf("RADIUS vendor %d attribute %d too long: %d bytes", a.VendorID, va.Type, len(va.Value))
+			}
+			value = append(value, va.Type, byte(len(va.Value)+2))
+			value = append(value, va.Value...)
+		}
+	}
+	if len(value) > 253 {
+		return nil, fmt.Errorf("RADIUS %v attribute too long: %d bytes", a.Type, len(value))
+	}
+	if opts.FixLengths {
+		a.Length = uint8(len(value) + 2)
+	}
+	b = append(b, byte(a.Type), a.Length)
+	return append(b, value...), nil
+}
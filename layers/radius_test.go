@@ -0,0 +1,182 @@
+// Copyright 2026 The GoPacket Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"bytes"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+// The Access-Request and Access-Accept of RFC 2865 section 7.1, with the
+// shared secret "xyzzy5461".
+var (
+	testRADIUSAccessRequest = mustDecodeHex("010000380f403f9473978057bd83d5cb98f4227a01066e656d6f02120dbe708d93d413ce3196e43f782a0aee0406c0a80110050600000003")
+	testRADIUSAccessAccept  = mustDecodeHex("0200002686fe220e7624ba2a1005f6bf9b55e0b20606000000010f06000000000e06c0a80103")
+)
+
+func decodeTestRADIUS(t *testing.T, data []byte, port UDPPort) *RADIUS {
+	buf := gopacket.NewSerializeBuffer()
+	udp := &UDP{SrcPort: 50000, DstPort: port}
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true}, udp, gopacket.Payload(data)); err != nil {
+		t.Fatal(err)
+	}
+	p := gopacket.NewPacket(buf.Bytes(), LayerTypeUDP, gopacket.Default)
+	r, ok := p.Layer(LayerTypeRADIUS).(*RADIUS)
+	if !ok || p.ApplicationLayer() != r {
+		t.Fatalf("no RADIUS layer: %v", p)
+	}
+	return r
+}
+
+func TestRADIUSDecode(t *testing.T) {
+	req := decodeTestRADIUS(t, testRADIUSAccessRequest, 1812)
+	if req.Code != RADIUSCodeAccessRequest || req.Identifier != 0 || req.Length != 56 || len(req.Attributes) != 4 {
+		t.Fatalf("Access-Request: %+v", req)
+	}
+	if a := req.Attribute(RADIUSAttributeUserName); a == nil || string(a.Value) != "nemo" || a.Type.DataType() != RADIUSDataText {
+		t.Errorf("User-Name: %+v", a)
+	}
+	if ip, err := req.Attribute(RADIUSAttributeNASIPAddress).IP(); err != nil || !ip.Equal(net.IP{192, 168, 1, 16}) {
+		t.Errorf("NAS-IP-Address: %v, %v", ip, err)
+	}
+	if port, err := req.Attribute(RADIUSAttributeNASPort).Integer(); err != nil || port != 3 {
+		t.Errorf("NAS-Port: %v, %v", port, err)
+	}
+	if err := req.VerifyAuthenticator([]byte("xyzzy5461"), nil); err == nil {
+		t.Error("verified the random authenticator of an Access-Request")
+	}
+
+	resp := decodeTestRADIUS(t, testRADIUSAccessAccept, 1812)
+	if resp.Code != RADIUSCodeAccessAccept || len(resp.Attributes) != 3 {
+		t.Fatalf("Access-Accept: %+v", resp)
+	}
+	if ip, err := resp.Attribute(RADIUSAttributeLoginIPHost).IP(); err != nil || !ip.Equal(net.IP{192, 168, 1, 3}) {
+		t.Errorf("Login-IP-Host: %v, %v", ip, err)
+	}
+	if err := resp.VerifyAuthenticator([]byte("xyzzy5461"), req.Authenticator[:]); err != nil {
+		t.Error(err)
+	}
+	if err := resp.VerifyAuthenticator([]byte("xyzzy5462"), req.Authenticator[:]); err == nil {
+		t.Error("verified the authenticator with the wrong secret")
+	}
+	if err := resp.VerifyMessageAuthenticator([]byte("xyzzy5461"), req.Authenticator[:]); err == nil {
+		t.Error("verified a missing Message-Authenticator")
+	}
+
+	// padding following the packet is ignored
+	padded := append(append([]byte(nil), testRADIUSAccessAccept...), 0, 0)
+	if r := decodeTestRADIUS(t, padded, 1813); len(r.Contents) != len(testRADIUSAccessAccept) || len(r.Attributes) != 3 {
+		t.Errorf("padded packet: %+v", r)
+	}
+}
+
+func TestRADIUSInvalid(t *testing.T) {
+	for _, data := range [][]byte{
+		testRADIUSAccessAccept[:19],
+		// length beyond the data
+		append([]byte{2, 0, 0, 0x27}, testRADIUSAccessAccept[4:]...),
+		// attribute length beyond the packet
+		append(append([]byte(nil), testRADIUSAccessAccept[:32]...), 0x0e, 0x07, 0xc0, 0xa8, 0x01, 0x03),
+		// attribute length of 1
+		append(append([]byte{2, 0, 0, 0x16}, testRADIUSAccessAccept[4:20]...), 0x01, 0x01),
+	} {
+		if p := gopacket.NewPacket(data, LayerTypeRADIUS, gopacket.Default); p.ErrorLayer() == nil {
+			t.Errorf("%x: no error", data)
+		}
+	}
+}
+
+func TestRADIUSSerialize(t *testing.T) {
+	secret := []byte("s3cr3t")
+	eap := bytes.Repeat([]byte{0x02}, 300)
+	req := &RADIUS{
+		Code:          RADIUSCodeAccessRequest,
+		Identifier:    7,
+		Authenticator: [16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+		Attributes: []RADIUSAttribute{
+			{Type: RADIUSAttributeUserName, Value: []byte("alice")},
+			{Type: RADIUSAttributeEAPMessage, Value: eap[:253]},
+			{Type: RADIUSAttributeEAPMessage, Value: eap[253:]},
+			{Type: RADIUSAttributeFramedIPv6Prefix, Value: []byte{0, 48, 0x20, 0x01, 0x0d, 0xb8, 0, 1}},
+			// Microsoft MS-MPPE-Send-Key and MS-MPPE-Recv-Key
+			{Type: RADIUSAttributeVendorSpecific, VendorID: 311, VendorAttributes: []RADIUSVendorAttribute{
+				{Type: 16, Value: []byte{0xaa, 0xbb}},
+				{Type: 17, Value: []byte{0xcc}},
+			}},
+			{Type: RADIUSAttributeMessageAuthenticator, Value: make([]byte, 16)},
+		},
+	}
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true}, req); err != nil {
+		t.Fatal(err)
+	}
+	if err := SignRADIUSMessageAuthenticator(buf.Bytes(), secret, nil); err != nil {
+		t.Fatal(err)
+	}
+	data := append([]byte(nil), buf.Bytes()...)
+
+	r := decodeTestRADIUS(t, data, 1812)
+	if err := r.VerifyMessageAuthenticator(secret, nil); err != nil {
+		t.Error(err)
+	}
+	if err := r.VerifyMessageAuthenticator([]byte("secret"), nil); err == nil {
+		t.Error("verified the Message-Authenticator with the wrong secret")
+	}
+	if int(r.Length) != len(data) || len(r.Attributes) != 6 || !bytes.Equal(r.EAPMessage(), eap) {
+		t.Fatalf("Access-Request: %+v", r)
+	}
+	if prefix, err := r.Attribute(RADIUSAttributeFramedIPv6Prefix).IPv6Prefix(); err != nil || prefix.String() != "2001:db8:1::/48" {
+		t.Errorf("Framed-IPv6-Prefix: %v, %v", prefix, err)
+	}
+	if vsa := r.Attribute(RADIUSAttributeVendorSpecific); vsa.VendorID != 311 || !reflect.DeepEqual(vsa.VendorAttributes, req.Attributes[4].VendorAttributes) {
+		t.Errorf("Vendor-Specific: %+v", vsa)
+	}
+
+	// a decoded packet serializes to the same bytes
+	buf = gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{}, r); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), data) {
+		t.Errorf("reserialized as %x, want %x", buf.Bytes(), data)
+	}
+
+	// responses are signed with the authenticator of the request
+	resp := &RADIUS{
+		Code:       RADIUSCodeAccessChallenge,
+		Identifier: 7,
+		Attributes: []RADIUSAttribute{
+			{Type: RADIUSAttributeState, Value: []byte{1}},
+			{Type: RADIUSAttributeMessageAuthenticator, Value: make([]byte, 16)},
+		},
+	}
+	buf = gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true}, resp); err != nil {
+		t.Fatal(err)
+	}
+	if err := SignRADIUSMessageAuthenticator(buf.Bytes(), secret, nil); err == nil {
+		t.Error("signed a response without the authenticator of its request")
+	}
+	if err := SignRADIUSMessageAuthenticator(buf.Bytes(), secret, r.Authenticator[:]); err != nil {
+		t.Fatal(err)
+	}
+	r = decodeTestRADIUS(t, buf.Bytes(), 1812)
+	if err := r.VerifyMessageAuthenticator(secret, req.Authenticator[:]); err != nil {
+		t.Error(err)
+	}
+	if err := r.VerifyMessageAuthenticator(secret, make([]byte, 16)); err == nil {
+		t.Error("verified the Message-Authenticator with the wrong request")
+	}
+
+	if err := (&RADIUS{Attributes: []RADIUSAttribute{{Type: RADIUSAttributeClass, Value: eap}}}).SerializeTo(gopacket.NewSerializeBuffer(), gopacket.SerializeOptions{}); err == nil {
+		t.Error("serialized an attribute of 300 bytes")
+	}
+}
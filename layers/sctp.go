@@ -35,11 +35,41 @@ func decodeSCTP(data []byte, p gopacket.PacketBuilder) error {
 	if err != nil {
 		return err
 	}
+	if lt := sctp.portLayerType(); lt != gopacket.LayerTypePayload {
+		p = &sctpPacketBuilder{PacketBuilder: p, portLayerType: lt}
+	}
 	return p.NextDecoder(sctpChunkTypePrefixDecoder)
 }
 
 var sctpChunkTypePrefixDecoder = gopacket.DecodeFunc(decodeWithSCTPChunkTypePrefix)
 
+// portLayerType returns the layer type of the well-known port of s, as for
+// UDP.
+func (s *SCTP) portLayerType() gopacket.LayerType {
+	if lt := s.DstPort.LayerType(); lt != gopacket.LayerTypePayload {
+		return lt
+	}
+	return s.SrcPort.LayerType()
+}
+
+// sctpPacketBuilder passes the layer type of the ports of a packet to the
+// decoders of its chunks, for Data chunks which leave their payload
+// protocol unspecified.
+type sctpPacketBuilder struct {
+	gopacket.PacketBuilder
+	portLayerType gopacket.LayerType
+}
+
+// NextDecoder passes b on to the decoder of the next chunk.
+func (b *sctpPacketBuilder) NextDecoder(next gopacket.Decoder) error {
+	if next == nil {
+		return b.PacketBuilder.NextDecoder(next)
+	}
+	return b.PacketBuilder.NextDecoder(gopacket.DecodeFunc(func(data []byte, _ gopacket.PacketBuilder) error {
+		return next.Decode(data, b)
+	}))
+}
+
 // TransportFlow returns a flow based on the source and destination SCTP port.
 func (s *SCTP) TransportFlow() gopacket.Flow {
 	return gopacket.NewFlow(EndpointSCTPPort, s.sPort, s.dPort)
@@ -243,6 +273,7 @@ const (
 	SCTPPayloadDDPSegment                     = 16
 	SCTPPayloadDDPStream                      = 17
 	SCTPPayloadS1AP                           = 18
+	SCTPPayloadDiameter                       = 46
 )
 
 func (p SCTPPayloadProtocol) String() string {
@@ -285,15 +316,54 @@ func (p SCTPPayloadProtocol) String() string {
 		return "DDPStream"
 	case SCTPPayloadS1AP:
 		return "S1AP"
+	case SCTPPayloadDiameter:
+		return "Diameter"
 	}
 	return fmt.Sprintf("Unknown(%d)", p)
 }
 
+var sctpPayloadProtocolLayerType = map[SCTPPayloadProtocol]gopacket.LayerType{
+	SCTPPayloadDiameter: LayerTypeDiameter,
+}
+
+// LayerType returns a LayerType that would be able to decode the user data
+// of a Data chunk with the payload protocol.
+//
+// Returns gopacket.LayerTypePayload for unknown/unsupported payload
+// protocols.
+func (p SCTPPayloadProtocol) LayerType() gopacket.LayerType {
+	if lt, ok := sctpPayloadProtocolLayerType[p]; ok {
+		return lt
+	}
+	return gopacket.LayerTypePayload
+}
+
+// RegisterSCTPPayloadProtocolLayerType creates a new mapping between a
+// SCTPPayloadProtocol and an underlaying LayerType.
+func RegisterSCTPPayloadProtocolLayerType(p SCTPPayloadProtocol, layerType gopacket.LayerType) {
+	sctpPayloadProtocolLayerType[p] = layerType
+}
+
 func decodeSCTPData(data []byte, p gopacket.PacketBuilder) error {
+	if len(data) < 16 {
+		p.SetTruncated()
+		return errors.New("SCTP data chunk truncated")
+	}
+	if binary.BigEndian.Uint16(data[2:4]) < 16 {
+		return errors.New("invalid SCTP data chunk length")
+	}
 	chunk, err := decodeSCTPChunk(data)
 	if err != nil {
 		return err
 	}
+	// The user data ends at Length, before the padding and the chunks
+	// bundled after this one.
+	if int(chunk.Length) <= len(data) {
+		chunk.Payload = data[16:chunk.Length]
+	} else {
+		p.SetTruncated()
+		chunk.Payload = data[16:]
+	}
 	sc := &SCTPData{
 		SCTPChunk:       chunk,
 		Unordered:       data[1]&0x4 != 0,
@@ -306,7 +376,16 @@ func decodeSCTPData(data []byte, p gopacket.PacketBuilder) error {
 	}
 	// Length is the length in bytes of the data, INCLUDING the 16-byte header.
 	p.AddLayer(sc)
-	return p.NextDecoder(gopacket.LayerTypePayload)
+	if !sc.BeginFragment || !sc.EndFragment {
+		// only a part of the user message
+		return p.NextDecoder(gopacket.LayerTypePayload)
+	}
+	next := sc.PayloadProtocol.LayerType()
+	if b, ok := p.(*sctpPacketBuilder); ok && sc.PayloadProtocol == SCTPProtocolReserved {
+		// unspecified by the application, the ports tell
+		next = b.portLayerType
+	}
+	return p.NextDecoder(next)
 }
 
 // SerializeTo is for gopacket.SerializableLayer.
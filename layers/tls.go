@@ -9,6 +9,7 @@ package layers
 import (
 	"encoding/binary"
 	"errors"
+	"fmt"
 
 	"github.com/google/gopacket"
 )
@@ -89,6 +90,12 @@ type TLS struct {
 	Handshake        []TLSHandshakeRecord
 	AppData          []TLSAppDataRecord
 	Alert            []TLSAlertRecord
+
+	// RecordTypes holds the content type of every record in wire order,
+	// telling how the records above are interleaved. When serializing a TLS
+	// layer with no RecordTypes, records are written type by type in the
+	// order of the fields above.
+	RecordTypes []TLSType
 }
 
 // TLSRecordHeader contains all the information that each TLS Record types should have
@@ -116,6 +123,17 @@ func decodeTLS(data []byte, p gopacket.PacketBuilder) error {
 
 // DecodeFromBytes decodes the slice into the TLS struct.
 func (t *TLS) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	// Handshake messages may span several records of the same data
+	var hs TLSHandshakeReassembler
+	return t.DecodeWithReassembler(data, &hs, df)
+}
+
+// DecodeWithReassembler decodes the slice into the TLS struct like
+// DecodeFromBytes, rebuilding handshake messages through hs. Keeping one
+// reassembler per direction of a connection lets messages span the records
+// of several calls, and handshake records following a ChangeCipherSpec of an
+// earlier call be reported as encrypted.
+func (t *TLS) DecodeWithReassembler(data []byte, hs *TLSHandshakeReassembler, df gopacket.DecodeFeedback) error {
 	t.BaseLayer.Contents = data
 	t.BaseLayer.Payload = nil
 
@@ -123,11 +141,12 @@ func (t *TLS) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
 	t.Handshake = t.Handshake[:0]
 	t.AppData = t.AppData[:0]
 	t.Alert = t.Alert[:0]
+	t.RecordTypes = t.RecordTypes[:0]
 
-	return t.decodeTLSRecords(data, df)
+	return t.decodeTLSRecords(data, hs, df)
 }
 
-func (t *TLS) decodeTLSRecords(data []byte, df gopacket.DecodeFeedback) error {
+func (t *TLS) decodeTLSRecords(data []byte, hs *TLSHandshakeReassembler, df gopacket.DecodeFeedback) error {
 	if len(data) < 5 {
 		df.SetTruncated()
 		return errors.New("TLS record too short")
@@ -163,6 +182,7 @@ func (t *TLS) decodeTLSRecords(data []byte, df gopacket.DecodeFeedback) error {
 			return e
 		}
 		t.ChangeCipherSpec = append(t.ChangeCipherSpec, r)
+		hs.ChangeCipherSpec()
 	case TLSAlert:
 		var r TLSAlertRecord
 		e := r.decodeFromBytes(h, data[hl:tl], df)
@@ -172,7 +192,7 @@ func (t *TLS) decodeTLSRecords(data []byte, df gopacket.DecodeFeedback) error {
 		t.Alert = append(t.Alert, r)
 	case TLSHandshake:
 		var r TLSHandshakeRecord
-		e := r.decodeFromBytes(h, data[hl:tl], df)
+		e := r.decodeFromBytes(h, data[hl:tl], hs, df)
 		if e != nil {
 			return e
 		}
@@ -186,10 +206,12 @@ func (t *TLS) decodeTLSRecords(data []byte, df gopacket.DecodeFeedback) error {
 		t.AppData = append(t.AppData, r)
 	}
 
+	t.RecordTypes = append(t.RecordTypes, h.ContentType)
+
 	if len(data) == tl {
 		return nil
 	}
-	return t.decodeTLSRecords(data[tl:len(data)], df)
+	return t.decodeTLSRecords(data[tl:len(data)], hs, df)
 }
 
 // CanDecode implements gopacket.DecodingLayer.
@@ -206,3 +228,91 @@ func (t *TLS) NextLayerType() gopacket.LayerType {
 func (t *TLS) Payload() []byte {
 	return nil
 }
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+func (t *TLS) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	order := t.RecordTypes
+	if len(order) == 0 {
+		order = t.defaultRecordTypes()
+	}
+	var nccs, nhs, nad, nal int
+	for _, ct := range order {
+		switch ct {
+		case TLSChangeCipherSpec:
+			nccs++
+		case TLSHandshake:
+			nhs++
+		case TLSApplicationData:
+			nad++
+		case TLSAlert:
+			nal++
+		default:
+			return fmt.Errorf("Unknown TLS record type %d", ct)
+		}
+	}
+	if nccs != len(t.ChangeCipherSpec) || nhs != len(t.Handshake) || nad != len(t.AppData) || nal != len(t.Alert) {
+		return errors.New("TLS RecordTypes does not match the records")
+	}
+
+	// Records are prepended, so they are written last to first
+	for i := len(order) - 1; i >= 0; i-- {
+		var err error
+		switch order[i] {
+		case TLSChangeCipherSpec:
+			nccs--
+			err = t.ChangeCipherSpec[nccs].SerializeTo(b, opts)
+		case TLSHandshake:
+			nhs--
+			err = t.Handshake[nhs].SerializeTo(b, opts)
+		case TLSApplicationData:
+			nad--
+			err = t.AppData[nad].SerializeTo(b, opts)
+		case TLSAlert:
+			nal--
+			err = t.Alert[nal].SerializeTo(b, opts)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *TLS) defaultRecordTypes() []TLSType {
+	var order []TLSType
+	for range t.ChangeCipherSpec {
+		order = append(order, TLSChangeCipherSpec)
+	}
+	for range t.Handshake {
+		order = append(order, TLSHandshake)
+	}
+	for range t.AppData {
+		order = append(order, TLSApplicationData)
+	}
+	for range t.Alert {
+		order = append(order, TLSAlert)
+	}
+	return order
+}
+
+// serializeTo prepends the record header and a body of bodyLen bytes to b,
+// returning the body for the caller to fill in.
+func (h *TLSRecordHeader) serializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions, ct TLSType, bodyLen int) ([]byte, error) {
+	if bodyLen > 0xffff {
+		return nil, fmt.Errorf("TLS record body too long: %d", bodyLen)
+	}
+	bytes, err := b.PrependBytes(5 + bodyLen)
+	if err != nil {
+		return nil, err
+	}
+	if opts.FixLengths {
+		h.ContentType = ct
+		h.Length = uint16(bodyLen)
+	}
+	bytes[0] = uint8(h.ContentType)
+	binary.BigEndian.PutUint16(bytes[1:], uint16(h.Version))
+	binary.BigEndian.PutUint16(bytes[3:], h.Length)
+	return bytes[5:], nil
+}
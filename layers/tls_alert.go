@@ -94,6 +94,31 @@ func (t *TLSAlertRecord) decodeFromBytes(h TLSRecordHeader, data []byte, df gopa
 	return nil
 }
 
+// LayerType returns LayerTypeTLS, so that a single record can be serialized
+// on its own with gopacket.SerializeLayers.
+func (t *TLSAlertRecord) LayerType() gopacket.LayerType { return LayerTypeTLS }
+
+// SerializeTo writes the serialized form of this record into the
+// SerializationBuffer, implementing gopacket.SerializableLayer. Encrypted
+// alerts are written from EncryptedMsg.
+func (t *TLSAlertRecord) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	if t.EncryptedMsg != nil {
+		bytes, err := t.TLSRecordHeader.serializeTo(b, opts, TLSAlert, len(t.EncryptedMsg))
+		if err != nil {
+			return err
+		}
+		copy(bytes, t.EncryptedMsg)
+		return nil
+	}
+	bytes, err := t.TLSRecordHeader.serializeTo(b, opts, TLSAlert, 2)
+	if err != nil {
+		return err
+	}
+	bytes[0] = uint8(t.Level)
+	bytes[1] = uint8(t.Description)
+	return nil
+}
+
 // Strings shows the TLS alert level nicely formatted
 func (al TLSAlertLevel) String() string {
 	switch al {
@@ -32,3 +32,18 @@ func (t *TLSAppDataRecord) decodeFromBytes(h TLSRecordHeader, data []byte, df go
 	t.Payload = data
 	return nil
 }
+
+// LayerType returns LayerTypeTLS, so that a single record can be serialized
+// on its own with gopacket.SerializeLayers.
+func (t *TLSAppDataRecord) LayerType() gopacket.LayerType { return LayerTypeTLS }
+
+// SerializeTo writes the serialized form of this record into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+func (t *TLSAppDataRecord) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	bytes, err := t.TLSRecordHeader.serializeTo(b, opts, TLSApplicationData, len(t.Payload))
+	if err != nil {
+		return err
+	}
+	copy(bytes, t.Payload)
+	return nil
+}
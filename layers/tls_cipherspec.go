@@ -53,6 +53,21 @@ func (t *TLSChangeCipherSpecRecord) decodeFromBytes(h TLSRecordHeader, data []by
 	return nil
 }
 
+// LayerType returns LayerTypeTLS, so that a single record can be serialized
+// on its own with gopacket.SerializeLayers.
+func (t *TLSChangeCipherSpecRecord) LayerType() gopacket.LayerType { return LayerTypeTLS }
+
+// SerializeTo writes the serialized form of this record into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+func (t *TLSChangeCipherSpecRecord) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	bytes, err := t.TLSRecordHeader.serializeTo(b, opts, TLSChangeCipherSpec, 1)
+	if err != nil {
+		return err
+	}
+	bytes[0] = uint8(t.Message)
+	return nil
+}
+
 // String shows the message value nicely formatted
 func (ccs TLSchangeCipherSpec) String() string {
 	switch ccs {
@@ -0,0 +1,417 @@
+// Copyright 2018 The GoPacket Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// TLSExtensionType is the type of a hello extension
+type TLSExtensionType uint16
+
+// TLSExtensionType known values, see the IANA "TLS ExtensionType Values"
+// registry.
+const (
+	TLSExtServerName                 TLSExtensionType = 0
+	TLSExtMaxFragmentLength          TLSExtensionType = 1
+	TLSExtStatusRequest              TLSExtensionType = 5
+	TLSExtSupportedGroups            TLSExtensionType = 10
+	TLSExtECPointFormats             TLSExtensionType = 11
+	TLSExtSignatureAlgorithms        TLSExtensionType = 13
+	TLSExtUseSRTP                    TLSExtensionType = 14
+	TLSExtHeartbeat                  TLSExtensionType = 15
+	TLSExtALPN                       TLSExtensionType = 16
+	TLSExtStatusRequestV2            TLSExtensionType = 17
+	TLSExtSignedCertificateTimestamp TLSExtensionType = 18
+	TLSExtPadding                    TLSExtensionType = 21
+	TLSExtEncryptThenMAC             TLSExtensionType = 22
+	TLSExtExtendedMasterSecret       TLSExtensionType = 23
+	TLSExtCompressCertificate        TLSExtensionType = 27
+	TLSExtRecordSizeLimit            TLSExtensionType = 28
+	TLSExtDelegatedCredentials       TLSExtensionType = 34
+	TLSExtSessionTicket              TLSExtensionType = 35
+	TLSExtPreSharedKey               TLSExtensionType = 41
+	TLSExtEarlyData                  TLSExtensionType = 42
+	TLSExtSupportedVersions          TLSExtensionType = 43
+	TLSExtCookie                     TLSExtensionType = 44
+	TLSExtPSKKeyExchangeModes        TLSExtensionType = 45
+	TLSExtCertificateAuthorities     TLSExtensionType = 47
+	TLSExtPostHandshakeAuth          TLSExtensionType = 49
+	TLSExtSignatureAlgorithmsCert    TLSExtensionType = 50
+	TLSExtKeyShare                   TLSExtensionType = 51
+	TLSExtQUICTransportParameters    TLSExtensionType = 57
+	TLSExtNextProtocolNegotiation    TLSExtensionType = 13172
+	TLSExtApplicationSettings        TLSExtensionType = 17513
+	TLSExtEncryptedClientHello       TLSExtensionType = 65037
+	TLSExtRenegotiationInfo          TLSExtensionType = 65281
+)
+
+// String shows the extension type nicely formatted
+func (et TLSExtensionType) String() string {
+	switch et {
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint16(et))
+	case TLSExtServerName:
+		return "server_name"
+	case TLSExtMaxFragmentLength:
+		return "max_fragment_length"
+	case TLSExtStatusRequest:
+		return "status_request"
+	case TLSExtSupportedGroups:
+		return "supported_groups"
+	case TLSExtECPointFormats:
+		return "ec_point_formats"
+	case TLSExtSignatureAlgorithms:
+		return "signature_algorithms"
+	case TLSExtUseSRTP:
+		return "use_srtp"
+	case TLSExtHeartbeat:
+		return "heartbeat"
+	case TLSExtALPN:
+		return "application_layer_protocol_negotiation"
+	case TLSExtStatusRequestV2:
+		return "status_request_v2"
+	case TLSExtSignedCertificateTimestamp:
+		return "signed_certificate_timestamp"
+	case TLSExtPadding:
+		return "padding"
+	case TLSExtEncryptThenMAC:
+		return "encrypt_then_mac"
+	case TLSExtExtendedMasterSecret:
+		return "extended_master_secret"
+	case TLSExtCompressCertificate:
+		return "compress_certificate"
+	case TLSExtRecordSizeLimit:
+		return "record_size_limit"
+	case TLSExtDelegatedCredentials:
+		return "delegated_credentials"
+	case TLSExtSessionTicket:
+		return "session_ticket"
+	case TLSExtPreSharedKey:
+		return "pre_shared_key"
+	case TLSExtEarlyData:
+		return "early_data"
+	case TLSExtSupportedVersions:
+		return "supported_versions"
+	case TLSExtCookie:
+		return "cookie"
+	case TLSExtPSKKeyExchangeModes:
+		return "psk_key_exchange_modes"
+	case TLSExtCertificateAuthorities:
+		return "certificate_authorities"
+	case TLSExtPostHandshakeAuth:
+		return "post_handshake_auth"
+	case TLSExtSignatureAlgorithmsCert:
+		return "signature_algorithms_cert"
+	case TLSExtKeyShare:
+		return "key_share"
+	case TLSExtQUICTransportParameters:
+		return "quic_transport_parameters"
+	case TLSExtNextProtocolNegotiation:
+		return "next_protocol_negotiation"
+	case TLSExtApplicationSettings:
+		return "application_settings"
+	case TLSExtEncryptedClientHello:
+		return "encrypted_client_hello"
+	case TLSExtRenegotiationInfo:
+		return "renegotiation_info"
+	}
+}
+
+// TLSNamedGroup is a named elliptic curve or finite field group, as used by
+// the supported_groups and key_share extensions.
+type TLSNamedGroup uint16
+
+// TLSNamedGroup known values
+const (
+	TLSGroupSecp256r1      TLSNamedGroup = 23
+	TLSGroupSecp384r1      TLSNamedGroup = 24
+	TLSGroupSecp521r1      TLSNamedGroup = 25
+	TLSGroupX25519         TLSNamedGroup = 29
+	TLSGroupX448           TLSNamedGroup = 30
+	TLSGroupFFDHE2048      TLSNamedGroup = 256
+	TLSGroupFFDHE3072      TLSNamedGroup = 257
+	TLSGroupFFDHE4096      TLSNamedGroup = 258
+	TLSGroupFFDHE6144      TLSNamedGroup = 259
+	TLSGroupFFDHE8192      TLSNamedGroup = 260
+	TLSGroupX25519MLKEM768 TLSNamedGroup = 4588
+)
+
+// String shows the named group nicely formatted
+func (g TLSNamedGroup) String() string {
+	switch g {
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint16(g))
+	case TLSGroupSecp256r1:
+		return "secp256r1"
+	case TLSGroupSecp384r1:
+		return "secp384r1"
+	case TLSGroupSecp521r1:
+		return "secp521r1"
+	case TLSGroupX25519:
+		return "x25519"
+	case TLSGroupX448:
+		return "x448"
+	case TLSGroupFFDHE2048:
+		return "ffdhe2048"
+	case TLSGroupFFDHE3072:
+		return "ffdhe3072"
+	case TLSGroupFFDHE4096:
+		return "ffdhe4096"
+	case TLSGroupFFDHE6144:
+		return "ffdhe6144"
+	case TLSGroupFFDHE8192:
+		return "ffdhe8192"
+	case TLSGroupX25519MLKEM768:
+		return "X25519MLKEM768"
+	}
+}
+
+// TLSSignatureScheme is a signature algorithm as used by the
+// signature_algorithms extension and by signed handshake messages.
+type TLSSignatureScheme uint16
+
+// TLSSignatureScheme known values
+const (
+	TLSSigRSAPKCS1SHA1         TLSSignatureScheme = 0x0201
+	TLSSigECDSASHA1            TLSSignatureScheme = 0x0203
+	TLSSigRSAPKCS1SHA256       TLSSignatureScheme = 0x0401
+	TLSSigECDSASecp256r1SHA256 TLSSignatureScheme = 0x0403
+	TLSSigRSAPKCS1SHA384       TLSSignatureScheme = 0x0501
+	TLSSigECDSASecp384r1SHA384 TLSSignatureScheme = 0x0503
+	TLSSigRSAPKCS1SHA512       TLSSignatureScheme = 0x0601
+	TLSSigECDSASecp521r1SHA512 TLSSignatureScheme = 0x0603
+	TLSSigRSAPSSRSAESHA256     TLSSignatureScheme = 0x0804
+	TLSSigRSAPSSRSAESHA384     TLSSignatureScheme = 0x0805
+	TLSSigRSAPSSRSAESHA512     TLSSignatureScheme = 0x0806
+	TLSSigEd25519              TLSSignatureScheme = 0x0807
+	TLSSigEd448                TLSSignatureScheme = 0x0808
+	TLSSigRSAPSSPSSSHA256      TLSSignatureScheme = 0x0809
+	TLSSigRSAPSSPSSSHA384      TLSSignatureScheme = 0x080a
+	TLSSigRSAPSSPSSSHA512      TLSSignatureScheme = 0x080b
+)
+
+// String shows the signature scheme nicely formatted
+func (s TLSSignatureScheme) String() string {
+	switch s {
+	default:
+		return fmt.Sprintf("0x%04x", uint16(s))
+	case TLSSigRSAPKCS1SHA1:
+		return "rsa_pkcs1_sha1"
+	case TLSSigECDSASHA1:
+		return "ecdsa_sha1"
+	case TLSSigRSAPKCS1SHA256:
+		return "rsa_pkcs1_sha256"
+	case TLSSigECDSASecp256r1SHA256:
+		return "ecdsa_secp256r1_sha256"
+	case TLSSigRSAPKCS1SHA384:
+		return "rsa_pkcs1_sha384"
+	case TLSSigECDSASecp384r1SHA384:
+		return "ecdsa_secp384r1_sha384"
+	case TLSSigRSAPKCS1SHA512:
+		return "rsa_pkcs1_sha512"
+	case TLSSigECDSASecp521r1SHA512:
+		return "ecdsa_secp521r1_sha512"
+	case TLSSigRSAPSSRSAESHA256:
+		return "rsa_pss_rsae_sha256"
+	case TLSSigRSAPSSRSAESHA384:
+		return "rsa_pss_rsae_sha384"
+	case TLSSigRSAPSSRSAESHA512:
+		return "rsa_pss_rsae_sha512"
+	case TLSSigEd25519:
+		return "ed25519"
+	case TLSSigEd448:
+		return "ed448"
+	case TLSSigRSAPSSPSSSHA256:
+		return "rsa_pss_pss_sha256"
+	case TLSSigRSAPSSPSSSHA384:
+		return "rsa_pss_pss_sha384"
+	case TLSSigRSAPSSPSSSHA512:
+		return "rsa_pss_pss_sha512"
+	}
+}
+
+// TLSExtension is a raw hello extension, as found on the wire.
+type TLSExtension struct {
+	Type TLSExtensionType
+	Data []byte
+}
+
+// TLSKeyShareEntry is an entry of the key_share extension. A
+// HelloRetryRequest only carries the selected group, with no KeyExchange.
+type TLSKeyShareEntry struct {
+	Group       TLSNamedGroup
+	KeyExchange []byte
+}
+
+// TLSHelloExtensions holds the extensions of a ClientHello or ServerHello.
+// Extensions lists every extension in wire order, the other fields hold the
+// decoded content of the well-known ones. Fields of extensions that only
+// carry a single value in a ServerHello (supported_versions, ALPN,
+// key_share) are still slices, with a single element.
+type TLSHelloExtensions struct {
+	Extensions []TLSExtension
+
+	ServerName           string
+	ALPN                 []string
+	SupportedVersions    []TLSVersion
+	SupportedGroups      []TLSNamedGroup
+	ECPointFormats       []uint8
+	SignatureAlgorithms  []TLSSignatureScheme
+	KeyShares            []TLSKeyShareEntry
+	PSKKeyExchangeModes  []uint8
+	SessionTicket        []byte
+	RenegotiationInfo    []byte
+	StatusRequest        bool
+	ExtendedMasterSecret bool
+	SecureRenegotiation  bool
+}
+
+// HasExtension returns true if an extension of type et was sent.
+func (e *TLSHelloExtensions) HasExtension(et TLSExtensionType) bool {
+	for _, ext := range e.Extensions {
+		if ext.Type == et {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeFromBytes decodes an extensions block. rest holds whatever follows
+// the block in the hello message and must be empty.
+func (e *TLSHelloExtensions) decodeFromBytes(data, rest []byte, ht TLSHandshakeType) error {
+	if len(rest) != 0 {
+		return fmt.Errorf("TLS %s extensions length mismatch", ht)
+	}
+	r := tlsReader{data: data}
+	for !r.empty() {
+		ext := TLSExtension{Type: TLSExtensionType(r.uint16())}
+		ext.Data = r.vec16()
+		if r.err != nil {
+			return r.err
+		}
+		e.Extensions = append(e.Extensions, ext)
+		if err := e.decodeExtension(ext, ht); err != nil {
+			return fmt.Errorf("TLS %s extension %s: %v", ht, ext.Type, err)
+		}
+	}
+	return nil
+}
+
+func (e *TLSHelloExtensions) decodeExtension(ext TLSExtension, ht TLSHandshakeType) error {
+	r := tlsReader{data: ext.Data}
+	client := ht == TLSHandshakeClientHello
+	switch ext.Type {
+	case TLSExtServerName:
+		// A server acknowledges SNI with an empty extension
+		if len(ext.Data) == 0 {
+			return nil
+		}
+		names := tlsReader{data: r.vec16()}
+		for !names.empty() && names.err == nil {
+			nameType := names.uint8()
+			name := names.vec16()
+			if nameType == 0 && e.ServerName == "" {
+				e.ServerName = string(name)
+			}
+		}
+		if names.err != nil {
+			return names.err
+		}
+	case TLSExtALPN:
+		protos := tlsReader{data: r.vec16()}
+		for !protos.empty() && protos.err == nil {
+			e.ALPN = append(e.ALPN, string(protos.vec8()))
+		}
+		if protos.err != nil {
+			return protos.err
+		}
+	case TLSExtSupportedVersions:
+		if client {
+			versions := r.vec8()
+			if len(versions)%2 != 0 {
+				return errors.New("odd length")
+			}
+			for i := 0; i < len(versions); i += 2 {
+				e.SupportedVersions = append(e.SupportedVersions, TLSVersion(binary.BigEndian.Uint16(versions[i:])))
+			}
+		} else {
+			e.SupportedVersions = []TLSVersion{TLSVersion(r.uint16())}
+		}
+	case TLSExtSupportedGroups:
+		groups := r.vec16()
+		if len(groups)%2 != 0 {
+			return errors.New("odd length")
+		}
+		for i := 0; i < len(groups); i += 2 {
+			e.SupportedGroups = append(e.SupportedGroups, TLSNamedGroup(binary.BigEndian.Uint16(groups[i:])))
+		}
+	case TLSExtECPointFormats:
+		e.ECPointFormats = r.vec8()
+	case TLSExtSignatureAlgorithms:
+		var err error
+		if e.SignatureAlgorithms, err = decodeTLSSignatureSchemes(r.vec16()); err != nil {
+			return err
+		}
+	case TLSExtKeyShare:
+		switch {
+		case client:
+			shares := tlsReader{data: r.vec16()}
+			for !shares.empty() && shares.err == nil {
+				ks := TLSKeyShareEntry{Group: TLSNamedGroup(shares.uint16())}
+				ks.KeyExchange = shares.vec16()
+				e.KeyShares = append(e.KeyShares, ks)
+			}
+			if shares.err != nil {
+				return shares.err
+			}
+		case len(ext.Data) == 2:
+			// HelloRetryRequest
+			e.KeyShares = []TLSKeyShareEntry{{Group: TLSNamedGroup(r.uint16())}}
+		default:
+			ks := TLSKeyShareEntry{Group: TLSNamedGroup(r.uint16())}
+			ks.KeyExchange = r.vec16()
+			e.KeyShares = []TLSKeyShareEntry{ks}
+		}
+	case TLSExtPSKKeyExchangeModes:
+		e.PSKKeyExchangeModes = r.vec8()
+	case TLSExtSessionTicket:
+		e.SessionTicket = ext.Data
+		return nil
+	case TLSExtRenegotiationInfo:
+		e.SecureRenegotiation = true
+		e.RenegotiationInfo = r.vec8()
+	case TLSExtStatusRequest:
+		e.StatusRequest = true
+		return nil
+	case TLSExtExtendedMasterSecret:
+		e.ExtendedMasterSecret = true
+		return nil
+	default:
+		return nil
+	}
+	if r.err != nil {
+		return r.err
+	}
+	if !r.empty() {
+		return errors.New("trailing data")
+	}
+	return nil
+}
+
+func decodeTLSSignatureSchemes(data []byte) ([]TLSSignatureScheme, error) {
+	if len(data)%2 != 0 {
+		return nil, errors.New("odd signature algorithms length")
+	}
+	schemes := make([]TLSSignatureScheme, len(data)/2)
+	for i := range schemes {
+		schemes[i] = TLSSignatureScheme(binary.BigEndian.Uint16(data[2*i:]))
+	}
+	return schemes, nil
+}
@@ -7,22 +7,694 @@
 package layers
 
 import (
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
 	"github.com/google/gopacket"
 )
 
-// TLSHandshakeRecord defines the structure of a Handshare Record
+// TLSHandshakeType defines the type of a handshake message
+type TLSHandshakeType uint8
+
+// TLSHandshakeType known values, see RFC 5246 and RFC 8446.
+const (
+	TLSHandshakeHelloRequest        TLSHandshakeType = 0
+	TLSHandshakeClientHello         TLSHandshakeType = 1
+	TLSHandshakeServerHello         TLSHandshakeType = 2
+	TLSHandshakeHelloVerifyRequest  TLSHandshakeType = 3
+	TLSHandshakeNewSessionTicket    TLSHandshakeType = 4
+	TLSHandshakeEndOfEarlyData      TLSHandshakeType = 5
+	TLSHandshakeEncryptedExtensions TLSHandshakeType = 8
+	TLSHandshakeCertificate         TLSHandshakeType = 11
+	TLSHandshakeServerKeyExchange   TLSHandshakeType = 12
+	TLSHandshakeCertificateRequest  TLSHandshakeType = 13
+	TLSHandshakeServerHelloDone     TLSHandshakeType = 14
+	TLSHandshakeCertificateVerify   TLSHandshakeType = 15
+	TLSHandshakeClientKeyExchange   TLSHandshakeType = 16
+	TLSHandshakeFinished            TLSHandshakeType = 20
+	TLSHandshakeCertificateStatus   TLSHandshakeType = 22
+	TLSHandshakeKeyUpdate           TLSHandshakeType = 24
+	TLSHandshakeMessageHash         TLSHandshakeType = 254
+)
+
+// String shows the handshake type nicely formatted
+func (ht TLSHandshakeType) String() string {
+	switch ht {
+	default:
+		return fmt.Sprintf("Unknown(%d)", ht)
+	case TLSHandshakeHelloRequest:
+		return "HelloRequest"
+	case TLSHandshakeClientHello:
+		return "ClientHello"
+	case TLSHandshakeServerHello:
+		return "ServerHello"
+	case TLSHandshakeHelloVerifyRequest:
+		return "HelloVerifyRequest"
+	case TLSHandshakeNewSessionTicket:
+		return "NewSessionTicket"
+	case TLSHandshakeEndOfEarlyData:
+		return "EndOfEarlyData"
+	case TLSHandshakeEncryptedExtensions:
+		return "EncryptedExtensions"
+	case TLSHandshakeCertificate:
+		return "Certificate"
+	case TLSHandshakeServerKeyExchange:
+		return "ServerKeyExchange"
+	case TLSHandshakeCertificateRequest:
+		return "CertificateRequest"
+	case TLSHandshakeServerHelloDone:
+		return "ServerHelloDone"
+	case TLSHandshakeCertificateVerify:
+		return "CertificateVerify"
+	case TLSHandshakeClientKeyExchange:
+		return "ClientKeyExchange"
+	case TLSHandshakeFinished:
+		return "Finished"
+	case TLSHandshakeCertificateStatus:
+		return "CertificateStatus"
+	case TLSHandshakeKeyUpdate:
+		return "KeyUpdate"
+	case TLSHandshakeMessageHash:
+		return "MessageHash"
+	}
+}
+
+// known reports whether ht is a handshake type this package understands.
+// It is used to tell plaintext handshake messages from encrypted ones.
+func (ht TLSHandshakeType) known() bool {
+	return ht.String()[0] != 'U'
+}
+
+// TLSCipherSuite is the 16 bits identifier of a cipher suite
+type TLSCipherSuite uint16
+
+// String shows the cipher suite identifier in hexadecimal
+func (cs TLSCipherSuite) String() string {
+	return fmt.Sprintf("0x%04X", uint16(cs))
+}
+
+// TLSCompressionMethod is the identifier of a record compression method
+type TLSCompressionMethod uint8
+
+// TLSCompressionMethod known values
+const (
+	TLSCompressionNull    TLSCompressionMethod = 0
+	TLSCompressionDeflate TLSCompressionMethod = 1
+)
+
+//  TLS Handshake Message
+//  0  1  2  3  4  5  6  7  8
+//  +--+--+--+--+--+--+--+--+
+//  |     Handshake Type    |
+//  +--+--+--+--+--+--+--+--+
+//  |                       |
+//  +--      Length       --+
+//  |       (24 bits)       |
+//  +--                   --+
+//  |                       |
+//  +--+--+--+--+--+--+--+--+
+//  |     Message Body      |
+//  +--+--+--+--+--+--+--+--+
+
+// TLSHandshakeMessage is a single handshake message. Body always holds the
+// raw message body; the typed field matching Type is filled in when the
+// message type is understood, all others are nil.
+type TLSHandshakeMessage struct {
+	Type   TLSHandshakeType
+	Length uint32
+	Body   []byte
+
+	ClientHello        *TLSClientHello
+	ServerHello        *TLSServerHello
+	NewSessionTicket   *TLSNewSessionTicket
+	Certificate        *TLSCertificate
+	ServerKeyExchange  *TLSServerKeyExchange
+	CertificateRequest *TLSCertificateRequest
+	CertificateVerify  *TLSCertificateVerify
+	ClientKeyExchange  *TLSClientKeyExchange
+	Finished           *TLSFinished
+	CertificateStatus  *TLSCertificateStatus
+}
+
+// TLSClientHello is the ClientHello handshake message
+type TLSClientHello struct {
+	Version            TLSVersion
+	Random             []byte
+	SessionID          []byte
+	CipherSuites       []TLSCipherSuite
+	CompressionMethods []TLSCompressionMethod
+	TLSHelloExtensions
+}
+
+// TLSServerHello is the ServerHello handshake message. In TLS 1.3 a
+// HelloRetryRequest is sent as a ServerHello with a special Random value,
+// which is reported in HelloRetryRequest.
+type TLSServerHello struct {
+	Version           TLSVersion
+	Random            []byte
+	SessionID         []byte
+	CipherSuite       TLSCipherSuite
+	CompressionMethod TLSCompressionMethod
+	HelloRetryRequest bool
+	TLSHelloExtensions
+}
+
+// TLSNewSessionTicket is the TLS 1.2 NewSessionTicket handshake message
+// (RFC 5077)
+type TLSNewSessionTicket struct {
+	LifetimeHint uint32
+	Ticket       []byte
+}
+
+// TLSCertificate is the Certificate handshake message. Certificates holds
+// the DER encoded certificate chain as sent by the peer, RequestContext is
+// only used by TLS 1.3.
+type TLSCertificate struct {
+	RequestContext []byte
+	Certificates   [][]byte
+}
+
+// X509 parses the certificate chain using crypto/x509.
+func (c *TLSCertificate) X509() ([]*x509.Certificate, error) {
+	certs := make([]*x509.Certificate, 0, len(c.Certificates))
+	for _, der := range c.Certificates {
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return certs, err
+		}
+		certs = append(certs, cert)
+	}
+	return certs, nil
+}
+
+// TLSECCurveType is the ECParameters curve type of a ServerKeyExchange
+type TLSECCurveType uint8
+
+// TLSECCurveType known values
+const (
+	TLSECCurveExplicitPrime TLSECCurveType = 1
+	TLSECCurveExplicitChar2 TLSECCurveType = 2
+	TLSECCurveNamedCurve    TLSECCurveType = 3
+)
+
+// TLSServerKeyExchange is the ServerKeyExchange handshake message. The
+// message layout depends on the negotiated key exchange, which is not known
+// from the message alone: messages starting with a named curve are decoded as
+// ECDHE parameters, anything else as DHE parameters. Params always holds the
+// raw key exchange parameters.
+type TLSServerKeyExchange struct {
+	Params []byte
+
+	// ECDHE parameters
+	CurveType  TLSECCurveType
+	NamedCurve TLSNamedGroup
+	PublicKey  []byte
+
+	// DHE parameters
+	DHP  []byte
+	DHG  []byte
+	DHYs []byte
+
+	// SignatureAlgorithm is only present from TLS 1.2 on
+	SignatureAlgorithm TLSSignatureScheme
+	Signature          []byte
+}
+
+// TLSCertificateRequest is the TLS 1.2 CertificateRequest handshake message
+type TLSCertificateRequest struct {
+	CertificateTypes       []uint8
+	SignatureAlgorithms    []TLSSignatureScheme
+	CertificateAuthorities [][]byte
+}
+
+// TLSCertificateVerify is the CertificateVerify handshake message
+type TLSCertificateVerify struct {
+	SignatureAlgorithm TLSSignatureScheme
+	Signature          []byte
+}
+
+// TLSClientKeyExchange is the ClientKeyExchange handshake message. Its
+// content depends on the key exchange method, so it is kept opaque.
+type TLSClientKeyExchange struct {
+	Data []byte
+}
+
+// TLSFinished is the Finished handshake message
+type TLSFinished struct {
+	VerifyData []byte
+}
+
+// TLSCertificateStatus is the CertificateStatus handshake message (RFC 6066)
+type TLSCertificateStatus struct {
+	StatusType uint8
+	Response   []byte
+}
+
+// TLSHandshakeRecord defines the structure of a Handshare Record. Messages
+// holds the handshake messages completed by this record; a message that
+// started in a previous record of the same TLS layer is reported in the
+// record that contains its last byte.
+//
+// Handshake records sent after a ChangeCipherSpec, or whose content does not
+// start with a known handshake message type, are reported as Encrypted with
+// the record body in EncryptedMsg. A known message with a malformed body is a
+// decoding error.
+//
+// Fragment holds the body of a record that does not consist of whole
+// messages: it continues a message of a previous record, or ends with the
+// start of a message continued in the next one.
 type TLSHandshakeRecord struct {
 	TLSRecordHeader
+
+	Messages     []TLSHandshakeMessage
+	Encrypted    bool
+	EncryptedMsg []byte
+	Fragment     []byte
 }
 
-// DecodeFromBytes decodes the slice into the TLS struct.
-func (t *TLSHandshakeRecord) decodeFromBytes(h TLSRecordHeader, data []byte, df gopacket.DecodeFeedback) error {
+// decodeFromBytes decodes the slice into the TLS struct. Messages
+// fragmented over several records are rebuilt through hs.
+func (t *TLSHandshakeRecord) decodeFromBytes(h TLSRecordHeader, data []byte, hs *TLSHandshakeReassembler, df gopacket.DecodeFeedback) error {
 	// TLS Record Header
 	t.ContentType = h.ContentType
 	t.Version = h.Version
 	t.Length = h.Length
 
-	// TODO
+	continued := hs.Pending() > 0
+	msgs, err := hs.Add(data)
+	if err == errTLSHandshakeEncrypted {
+		t.Encrypted = true
+		t.EncryptedMsg = data
+		return nil
+	} else if err != nil {
+		return err
+	}
+	t.Messages = msgs
+	if continued || hs.Pending() > 0 {
+		t.Fragment = data
+	}
+	return nil
+}
+
+// LayerType returns LayerTypeTLS, so that a single record can be serialized
+// on its own with gopacket.SerializeLayers.
+func (t *TLSHandshakeRecord) LayerType() gopacket.LayerType { return LayerTypeTLS }
+
+// SerializeTo writes the serialized form of this record into the
+// SerializationBuffer, implementing gopacket.SerializableLayer. Messages are
+// written from their Body, the typed message fields are ignored. Encrypted
+// records are written from EncryptedMsg, and records holding a Fragment from
+// it, so that a message spanning several records keeps its boundaries.
+func (t *TLSHandshakeRecord) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	if t.Encrypted || t.Fragment != nil {
+		body := t.EncryptedMsg
+		if !t.Encrypted {
+			body = t.Fragment
+		}
+		bytes, err := t.TLSRecordHeader.serializeTo(b, opts, TLSHandshake, len(body))
+		if err != nil {
+			return err
+		}
+		copy(bytes, body)
+		return nil
+	}
 
+	l := 0
+	for i := range t.Messages {
+		if len(t.Messages[i].Body) > 0xffffff {
+			return fmt.Errorf("TLS %s message too long", t.Messages[i].Type)
+		}
+		l += 4 + len(t.Messages[i].Body)
+	}
+	bytes, err := t.TLSRecordHeader.serializeTo(b, opts, TLSHandshake, l)
+	if err != nil {
+		return err
+	}
+	for i := range t.Messages {
+		m := &t.Messages[i]
+		if opts.FixLengths {
+			m.Length = uint32(len(m.Body))
+		}
+		bytes[0] = uint8(m.Type)
+		bytes[1] = uint8(m.Length >> 16)
+		bytes[2] = uint8(m.Length >> 8)
+		bytes[3] = uint8(m.Length)
+		copy(bytes[4:], m.Body)
+		bytes = bytes[4+len(m.Body):]
+	}
 	return nil
 }
+
+// TLSHandshakeReassembler rebuilds handshake messages that are fragmented
+// over several handshake records. Feed it the bodies of consecutive handshake
+// records of one direction of a connection; complete messages are returned
+// as soon as their last fragment has been added. The TLS layer uses one
+// internally for records decoded from the same data, callers following a
+// whole connection keep one per direction.
+type TLSHandshakeReassembler struct {
+	buf       []byte
+	encrypted bool
+}
+
+// Reset drops any buffered fragment and clears the encrypted state.
+func (r *TLSHandshakeReassembler) Reset() {
+	r.buf = nil
+	r.encrypted = false
+}
+
+// ChangeCipherSpec tells the reassembler that the peer switched to encrypted
+// records: every following handshake record is rejected as encrypted.
+func (r *TLSHandshakeReassembler) ChangeCipherSpec() {
+	r.buf = nil
+	r.encrypted = true
+}
+
+// Pending returns the number of buffered bytes of an incomplete message.
+func (r *TLSHandshakeReassembler) Pending() int {
+	return len(r.buf)
+}
+
+var errTLSHandshakeEncrypted = errors.New("TLS handshake record is encrypted")
+
+// Add feeds the body of a handshake record. It returns the messages
+// completed by this record. The reassembler state is left untouched when an
+// error is returned: either the record does not hold plaintext handshake
+// messages, or one of its messages is malformed.
+func (r *TLSHandshakeReassembler) Add(data []byte) ([]TLSHandshakeMessage, error) {
+	if r.encrypted {
+		return nil, errTLSHandshakeEncrypted
+	}
+	buf := data
+	if len(r.buf) > 0 {
+		buf = make([]byte, 0, len(r.buf)+len(data))
+		buf = append(buf, r.buf...)
+		buf = append(buf, data...)
+	}
+
+	var msgs []TLSHandshakeMessage
+	for len(buf) >= 4 {
+		ht := TLSHandshakeType(buf[0])
+		if !ht.known() {
+			return nil, errTLSHandshakeEncrypted
+		}
+		l := uint32(buf[1])<<16 | uint32(buf[2])<<8 | uint32(buf[3])
+		if int(l) > len(buf)-4 {
+			break
+		}
+		m := TLSHandshakeMessage{Type: ht, Length: l, Body: buf[4 : 4+l]}
+		if err := m.decodeBody(); err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, m)
+		buf = buf[4+l:]
+	}
+	// Whatever is left is the beginning of a message continued in the next
+	// record. It is copied since data belongs to the caller.
+	r.buf = nil
+	if len(buf) > 0 {
+		r.buf = append(r.buf, buf...)
+	}
+	return msgs, nil
+}
+
+// decodeBody decodes m.Body according to m.Type.
+func (m *TLSHandshakeMessage) decodeBody() error {
+	var err error
+	switch m.Type {
+	case TLSHandshakeClientHello:
+		m.ClientHello = &TLSClientHello{}
+		err = m.ClientHello.decodeFromBytes(m.Body)
+	case TLSHandshakeServerHello:
+		m.ServerHello = &TLSServerHello{}
+		err = m.ServerHello.decodeFromBytes(m.Body)
+	case TLSHandshakeNewSessionTicket:
+		m.NewSessionTicket = &TLSNewSessionTicket{}
+		err = m.NewSessionTicket.decodeFromBytes(m.Body)
+	case TLSHandshakeCertificate:
+		m.Certificate = &TLSCertificate{}
+		err = m.Certificate.decodeFromBytes(m.Body)
+	case TLSHandshakeServerKeyExchange:
+		m.ServerKeyExchange = &TLSServerKeyExchange{}
+		err = m.ServerKeyExchange.decodeFromBytes(m.Body)
+	case TLSHandshakeCertificateRequest:
+		m.CertificateRequest = &TLSCertificateRequest{}
+		err = m.CertificateRequest.decodeFromBytes(m.Body)
+	case TLSHandshakeCertificateVerify:
+		m.CertificateVerify = &TLSCertificateVerify{}
+		err = m.CertificateVerify.decodeFromBytes(m.Body)
+	case TLSHandshakeClientKeyExchange:
+		m.ClientKeyExchange = &TLSClientKeyExchange{Data: m.Body}
+	case TLSHandshakeFinished:
+		m.Finished = &TLSFinished{VerifyData: m.Body}
+	case TLSHandshakeCertificateStatus:
+		m.CertificateStatus = &TLSCertificateStatus{}
+		err = m.CertificateStatus.decodeFromBytes(m.Body)
+	case TLSHandshakeHelloRequest, TLSHandshakeServerHelloDone, TLSHandshakeEndOfEarlyData:
+		if len(m.Body) != 0 {
+			err = fmt.Errorf("TLS %s message with non-empty body", m.Type)
+		}
+	}
+	return err
+}
+
+// tlsReader is a small helper to walk the length-prefixed vectors used all
+// over the TLS handshake.
+type tlsReader struct {
+	data []byte
+	err  error
+}
+
+var errTLSHandshakeTruncated = errors.New("TLS handshake message truncated")
+
+func (r *tlsReader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n > len(r.data) {
+		r.err = errTLSHandshakeTruncated
+		return nil
+	}
+	b := r.data[:n:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *tlsReader) uint8() uint8 {
+	b := r.bytes(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+func (r *tlsReader) uint16() uint16 {
+	b := r.bytes(2)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint16(b)
+}
+
+func (r *tlsReader) uint24() uint32 {
+	b := r.bytes(3)
+	if b == nil {
+		return 0
+	}
+	return uint32(b[0])<<16 | uint32(b[1])<<8 | uint32(b[2])
+}
+
+func (r *tlsReader) uint32() uint32 {
+	b := r.bytes(4)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint32(b)
+}
+
+func (r *tlsReader) vec8() []byte  { return r.bytes(int(r.uint8())) }
+func (r *tlsReader) vec16() []byte { return r.bytes(int(r.uint16())) }
+func (r *tlsReader) vec24() []byte { return r.bytes(int(r.uint24())) }
+
+func (r *tlsReader) empty() bool { return len(r.data) == 0 }
+
+// tlsHelloRetryRequestRandom is the special ServerHello.Random value of a
+// TLS 1.3 HelloRetryRequest (RFC 8446, section 4.1.3).
+var tlsHelloRetryRequestRandom = [32]byte{
+	0xcf, 0x21, 0xad, 0x74, 0xe5, 0x9a, 0x61, 0x11, 0xbe, 0x1d, 0x8c, 0x02, 0x1e, 0x65, 0xb8, 0x91,
+	0xc2, 0xa2, 0x11, 0x16, 0x7a, 0xbb, 0x8c, 0x5e, 0x07, 0x9e, 0x09, 0xe2, 0xc8, 0xa8, 0x33, 0x9c,
+}
+
+func (h *TLSClientHello) decodeFromBytes(data []byte) error {
+	r := tlsReader{data: data}
+	h.Version = TLSVersion(r.uint16())
+	h.Random = r.bytes(32)
+	h.SessionID = r.vec8()
+	suites := r.vec16()
+	compression := r.vec8()
+	if r.err != nil {
+		return r.err
+	}
+	if len(suites)%2 != 0 {
+		return errors.New("TLS ClientHello cipher suites length is odd")
+	}
+	h.CipherSuites = make([]TLSCipherSuite, len(suites)/2)
+	for i := range h.CipherSuites {
+		h.CipherSuites[i] = TLSCipherSuite(binary.BigEndian.Uint16(suites[2*i:]))
+	}
+	h.CompressionMethods = make([]TLSCompressionMethod, len(compression))
+	for i, c := range compression {
+		h.CompressionMethods[i] = TLSCompressionMethod(c)
+	}
+	// Extensions are optional (SSL 3.0 / TLS 1.0 clients may omit them)
+	if r.empty() {
+		return nil
+	}
+	exts := r.vec16()
+	if r.err != nil {
+		return r.err
+	}
+	return h.TLSHelloExtensions.decodeFromBytes(exts, r.data, TLSHandshakeClientHello)
+}
+
+func (h *TLSServerHello) decodeFromBytes(data []byte) error {
+	r := tlsReader{data: data}
+	h.Version = TLSVersion(r.uint16())
+	h.Random = r.bytes(32)
+	h.SessionID = r.vec8()
+	h.CipherSuite = TLSCipherSuite(r.uint16())
+	h.CompressionMethod = TLSCompressionMethod(r.uint8())
+	if r.err != nil {
+		return r.err
+	}
+	h.HelloRetryRequest = string(h.Random) == string(tlsHelloRetryRequestRandom[:])
+	if r.empty() {
+		return nil
+	}
+	exts := r.vec16()
+	if r.err != nil {
+		return r.err
+	}
+	return h.TLSHelloExtensions.decodeFromBytes(exts, r.data, TLSHandshakeServerHello)
+}
+
+func (t *TLSNewSessionTicket) decodeFromBytes(data []byte) error {
+	r := tlsReader{data: data}
+	t.LifetimeHint = r.uint32()
+	t.Ticket = r.vec16()
+	return r.err
+}
+
+func (c *TLSCertificate) decodeFromBytes(data []byte) error {
+	// TLS 1.2 and earlier: certificate_list<0..2^24-1>
+	r := tlsReader{data: data}
+	list := r.vec24()
+	if r.err == nil && r.empty() {
+		lr := tlsReader{data: list}
+		var certs [][]byte
+		for !lr.empty() && lr.err == nil {
+			certs = append(certs, lr.vec24())
+		}
+		if lr.err == nil {
+			c.RequestContext = nil
+			c.Certificates = certs
+			return nil
+		}
+	}
+
+	// TLS 1.3: certificate_request_context<0..2^8-1> followed by
+	// CertificateEntry certificate_list<0..2^24-1>
+	r = tlsReader{data: data}
+	c.RequestContext = r.vec8()
+	lr := tlsReader{data: r.vec24()}
+	if r.err != nil {
+		return r.err
+	}
+	if !r.empty() {
+		return errors.New("TLS Certificate message has trailing data")
+	}
+	c.Certificates = nil
+	for !lr.empty() && lr.err == nil {
+		c.Certificates = append(c.Certificates, lr.vec24())
+		lr.vec16() // per-certificate extensions
+	}
+	return lr.err
+}
+
+func (s *TLSServerKeyExchange) decodeFromBytes(data []byte) error {
+	r := tlsReader{data: data}
+	if len(data) > 0 && TLSECCurveType(data[0]) == TLSECCurveNamedCurve {
+		s.CurveType = TLSECCurveType(r.uint8())
+		s.NamedCurve = TLSNamedGroup(r.uint16())
+		s.PublicKey = r.vec8()
+	} else {
+		s.DHP = r.vec16()
+		s.DHG = r.vec16()
+		s.DHYs = r.vec16()
+	}
+	if r.err != nil {
+		return r.err
+	}
+	s.Params = data[:len(data)-len(r.data)]
+
+	// The signature is preceded by its algorithm from TLS 1.2 on. Both
+	// layouts are told apart by the signature length.
+	switch {
+	case r.empty():
+		// anonymous key exchange, no signature
+	case len(r.data) >= 2 && int(binary.BigEndian.Uint16(r.data)) == len(r.data)-2:
+		s.Signature = r.vec16()
+	default:
+		s.SignatureAlgorithm = TLSSignatureScheme(r.uint16())
+		s.Signature = r.vec16()
+		if r.err == nil && !r.empty() {
+			return errors.New("TLS ServerKeyExchange message has trailing data")
+		}
+	}
+	return r.err
+}
+
+func (c *TLSCertificateRequest) decodeFromBytes(data []byte) error {
+	r := tlsReader{data: data}
+	c.CertificateTypes = r.vec8()
+	if r.err != nil {
+		return r.err
+	}
+	// supported_signature_algorithms only exist from TLS 1.2 on; without
+	// them, the remaining data is exactly the CA list.
+	if len(r.data) < 2 || int(binary.BigEndian.Uint16(r.data)) != len(r.data)-2 {
+		var err error
+		c.SignatureAlgorithms, err = decodeTLSSignatureSchemes(r.vec16())
+		if err != nil {
+			return err
+		}
+	}
+	cas := tlsReader{data: r.vec16()}
+	for !cas.empty() && cas.err == nil {
+		c.CertificateAuthorities = append(c.CertificateAuthorities, cas.vec16())
+	}
+	if r.err != nil {
+		return r.err
+	}
+	return cas.err
+}
+
+func (c *TLSCertificateVerify) decodeFromBytes(data []byte) error {
+	r := tlsReader{data: data}
+	if len(data) >= 2 && int(binary.BigEndian.Uint16(data)) == len(data)-2 {
+		// TLS 1.0 and 1.1 have no signature algorithm
+		c.Signature = r.vec16()
+		return r.err
+	}
+	c.SignatureAlgorithm = TLSSignatureScheme(r.uint16())
+	c.Signature = r.vec16()
+	return r.err
+}
+
+func (c *TLSCertificateStatus) decodeFromBytes(data []byte) error {
+	r := tlsReader{data: data}
+	c.StatusType = r.uint8()
+	c.Response = r.vec24()
+	return r.err
+}
@@ -1,6 +1,10 @@
 package layers
 
 import (
+	"bytes"
+	"crypto/tls"
+	"encoding/binary"
+	"net"
 	"reflect"
 	"testing"
 
@@ -38,15 +42,50 @@ var testClientHelloDecoded = &TLS{
 	ChangeCipherSpec: nil,
 	Handshake: []TLSHandshakeRecord{
 		{
-			TLSRecordHeader{
+			TLSRecordHeader: TLSRecordHeader{
 				ContentType: 22,
 				Version:     0x0301,
 				Length:      209,
 			},
+			Messages: []TLSHandshakeMessage{
+				{
+					Type:   TLSHandshakeClientHello,
+					Length: 205,
+					Body:   testClientHello[63:],
+					ClientHello: &TLSClientHello{
+						Version:   0x0301,
+						Random:    testClientHello[65:97],
+						SessionID: testClientHello[98:98],
+						CipherSuites: []TLSCipherSuite{
+							0xc014, 0xc00a, 0x0039, 0x0038, 0x0088, 0x0087, 0xc00f, 0xc005, 0x0035,
+							0x0084, 0xc013, 0xc009, 0x0033, 0x0032, 0x009a, 0x0099, 0x0045, 0x0044,
+							0xc00e, 0xc004, 0x002f, 0x0096, 0x0041, 0xc011, 0xc007, 0xc00c, 0xc002,
+							0x0005, 0x0004, 0xc012, 0xc008, 0x0016, 0x0013, 0xc00d, 0xc003, 0x000a,
+							0x0015, 0x0012, 0x0009, 0x0014, 0x0011, 0x0008, 0x0006, 0x0003, 0x00ff,
+						},
+						CompressionMethods: []TLSCompressionMethod{TLSCompressionDeflate, TLSCompressionNull},
+						TLSHelloExtensions: TLSHelloExtensions{
+							Extensions: []TLSExtension{
+								{Type: TLSExtECPointFormats, Data: testClientHello[199:203]},
+								{Type: TLSExtSupportedGroups, Data: testClientHello[207:259]},
+								{Type: TLSExtSessionTicket, Data: testClientHello[263:263]},
+								{Type: TLSExtHeartbeat, Data: testClientHello[267:268]},
+							},
+							SupportedGroups: []TLSNamedGroup{
+								14, 13, 25, 11, 12, 24, 9, 10, 22, 23, 8, 6, 7,
+								20, 21, 4, 5, 18, 19, 1, 2, 3, 15, 16, 17,
+							},
+							ECPointFormats: testClientHello[200:203],
+							SessionTicket:  testClientHello[263:263],
+						},
+					},
+				},
+			},
 		},
 	},
-	AppData: nil,
-	Alert:   nil,
+	AppData:     nil,
+	Alert:       nil,
+	RecordTypes: []TLSType{TLSHandshake},
 }
 
 // Packet 6 - Server Hello, Certificate, Server Hello Done
@@ -112,22 +151,33 @@ var testClientKeyExchangeDecoded = &TLS{
 	},
 	Handshake: []TLSHandshakeRecord{
 		{
-			TLSRecordHeader{
+			TLSRecordHeader: TLSRecordHeader{
 				ContentType: 22,
 				Version:     0x0301,
 				Length:      70,
 			},
+			Messages: []TLSHandshakeMessage{
+				{
+					Type:              TLSHandshakeClientKeyExchange,
+					Length:            66,
+					Body:              testClientKeyExchange[9:75],
+					ClientKeyExchange: &TLSClientKeyExchange{Data: testClientKeyExchange[9:75]},
+				},
+			},
 		},
 		{
-			TLSRecordHeader{
+			TLSRecordHeader: TLSRecordHeader{
 				ContentType: 22,
 				Version:     0x0301,
 				Length:      48,
 			},
+			Encrypted:    true,
+			EncryptedMsg: testClientKeyExchange[86:],
 		},
 	},
-	AppData: nil,
-	Alert:   nil,
+	AppData:     nil,
+	Alert:       nil,
+	RecordTypes: []TLSType{TLSHandshake, TLSChangeCipherSpec, TLSHandshake},
 }
 
 // Packet 9 - New Session Ticket, Change Cipher Spec, Encryption Handshake Message
@@ -182,7 +232,8 @@ var testDoubleAppDataDecoded = &TLS{
 			testDoubleAppData[42 : 42+32],
 		},
 	},
-	Alert: nil,
+	Alert:       nil,
+	RecordTypes: []TLSType{TLSApplicationData, TLSApplicationData},
 }
 
 var testAlertEncrypted = []byte{
@@ -210,6 +261,7 @@ var testAlertEncryptedDecoded = &TLS{
 			testAlertEncrypted[5:],
 		},
 	},
+	RecordTypes: []TLSType{TLSAlert},
 }
 
 // Malformed TLS records
@@ -310,6 +362,15 @@ func TestParseTLSTooShort(t *testing.T) {
 	}
 }
 
+func TestParseTLSMalformedHandshake(t *testing.T) {
+	// a ClientHello whose body ends in the middle of its random
+	data := []byte{0x16, 0x03, 0x01, 0x00, 0x08, 0x01, 0x00, 0x00, 0x04, 0x03, 0x03, 0x01, 0x02}
+	p := gopacket.NewPacket(data, LayerTypeTLS, testTLSDecodeOptions)
+	if p.ErrorLayer() == nil {
+		t.Errorf("No Decoding Error when parsing a malformed ClientHello: %v", p)
+	}
+}
+
 func TestParseTLSLengthMismatch(t *testing.T) {
 	var testLengthMismatch = make([]byte, len(testDoubleAppData))
 	copy(testLengthMismatch, testDoubleAppData)
@@ -337,3 +398,269 @@ func TestParseTLSAlertEncrypted(t *testing.T) {
 		t.Error("No TLS layer type found in packet")
 	}
 }
+
+func TestParseTLSServerHelloCertificate(t *testing.T) {
+	p := gopacket.NewPacket(testServerHello, LayerTypeTLS, testTLSDecodeOptions)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	got := p.Layer(LayerTypeTLS).(*TLS)
+	if len(got.Handshake) != 3 {
+		t.Fatalf("Expected 3 handshake records, got %d", len(got.Handshake))
+	}
+	var types []TLSHandshakeType
+	for _, r := range got.Handshake {
+		if r.Encrypted {
+			t.Errorf("Handshake record wrongly reported as encrypted")
+		}
+		for _, m := range r.Messages {
+			types = append(types, m.Type)
+		}
+	}
+	want := []TLSHandshakeType{TLSHandshakeServerHello, TLSHandshakeCertificate, TLSHandshakeServerHelloDone}
+	if !reflect.DeepEqual(types, want) {
+		t.Fatalf("Handshake message types mismatch, got %v want %v", types, want)
+	}
+
+	sh := got.Handshake[0].Messages[0].ServerHello
+	if sh.Version != 0x0301 || sh.CipherSuite != 0x002f || sh.CompressionMethod != TLSCompressionDeflate {
+		t.Errorf("ServerHello decoded wrongly: %+v", sh)
+	}
+	if !sh.SecureRenegotiation || !sh.HasExtension(TLSExtSessionTicket) || sh.HelloRetryRequest {
+		t.Errorf("ServerHello extensions decoded wrongly: %+v", sh.TLSHelloExtensions)
+	}
+
+	certs, err := got.Handshake[1].Messages[0].Certificate.X509()
+	if err != nil {
+		t.Fatal("Failed to parse certificate:", err)
+	}
+	if len(certs) != 1 || certs[0].Subject.CommonName != "SSLeay demo server" {
+		t.Errorf("Unexpected certificate chain: %v", certs)
+	}
+}
+
+func TestParseTLSNewSessionTicket(t *testing.T) {
+	var got TLS
+	if err := got.DecodeFromBytes(testNewSessionTicket, gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal("Failed to decode packet:", err)
+	}
+	if len(got.Handshake) != 2 || len(got.ChangeCipherSpec) != 1 {
+		t.Fatalf("Unexpected records: %+v", got)
+	}
+	m := got.Handshake[0].Messages
+	if len(m) != 1 || m[0].NewSessionTicket == nil {
+		t.Fatalf("Missing NewSessionTicket: %+v", got.Handshake[0])
+	}
+	if m[0].NewSessionTicket.LifetimeHint != 7200 || len(m[0].NewSessionTicket.Ticket) != 160 {
+		t.Errorf("NewSessionTicket decoded wrongly: %+v", m[0].NewSessionTicket)
+	}
+	if !got.Handshake[1].Encrypted {
+		t.Error("Finished after ChangeCipherSpec should be reported as encrypted")
+	}
+}
+
+// testTLSGenerateClientHello returns the records of a ClientHello sent by
+// crypto/tls.
+func testTLSGenerateClientHello(t *testing.T, config *tls.Config) []byte {
+	client, server := net.Pipe()
+	defer server.Close()
+	go func() {
+		tls.Client(client, config).Handshake()
+		client.Close()
+	}()
+
+	var buf []byte
+	hdr := make([]byte, 5)
+	for {
+		if _, err := server.Read(hdr); err != nil {
+			t.Fatal("Failed to read ClientHello:", err)
+		}
+		body := make([]byte, binary.BigEndian.Uint16(hdr[3:]))
+		for n := 0; n < len(body); {
+			m, err := server.Read(body[n:])
+			if err != nil {
+				t.Fatal("Failed to read ClientHello:", err)
+			}
+			n += m
+		}
+		buf = append(buf, hdr...)
+		buf = append(buf, body...)
+		var h TLSHandshakeReassembler
+		if msgs, _ := h.Add(buf[5:]); len(msgs) > 0 {
+			return buf
+		}
+	}
+}
+
+func TestParseTLS13ClientHello(t *testing.T) {
+	data := testTLSGenerateClientHello(t, &tls.Config{
+		ServerName: "www.example.com",
+		NextProtos: []string{"h2", "http/1.1"},
+		MinVersion: tls.VersionTLS12,
+	})
+
+	var got TLS
+	if err := got.DecodeFromBytes(data, gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal("Failed to decode ClientHello:", err)
+	}
+	if len(got.Handshake) != 1 || len(got.Handshake[0].Messages) != 1 {
+		t.Fatalf("Unexpected records: %+v", got)
+	}
+	ch := got.Handshake[0].Messages[0].ClientHello
+	if ch == nil {
+		t.Fatal("Missing ClientHello")
+	}
+	if ch.ServerName != "www.example.com" {
+		t.Errorf("Wrong SNI %q", ch.ServerName)
+	}
+	if !reflect.DeepEqual(ch.ALPN, []string{"h2", "http/1.1"}) {
+		t.Errorf("Wrong ALPN %q", ch.ALPN)
+	}
+	if len(ch.SupportedVersions) == 0 || ch.SupportedVersions[0] != 0x0304 {
+		t.Errorf("Wrong supported versions %v", ch.SupportedVersions)
+	}
+	if len(ch.KeyShares) == 0 || len(ch.KeyShares[0].KeyExchange) == 0 {
+		t.Errorf("Missing key shares %v", ch.KeyShares)
+	}
+	if len(ch.SignatureAlgorithms) == 0 || len(ch.SupportedGroups) == 0 {
+		t.Errorf("Missing signature algorithms or groups: %+v", ch.TLSHelloExtensions)
+	}
+}
+
+func TestParseTLSFragmentedHandshake(t *testing.T) {
+	// Split the ClientHello message of packet 4 over three records
+	msg := testClientHello[59:]
+	var data []byte
+	for _, frag := range [][]byte{msg[:3], msg[3:100], msg[100:]} {
+		data = append(data, 0x16, 0x03, 0x01, 0, 0)
+		binary.BigEndian.PutUint16(data[len(data)-2:], uint16(len(frag)))
+		data = append(data, frag...)
+	}
+
+	var got TLS
+	if err := got.DecodeFromBytes(data, gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal("Failed to decode fragmented handshake:", err)
+	}
+	if len(got.Handshake) != 3 {
+		t.Fatalf("Expected 3 handshake records, got %d", len(got.Handshake))
+	}
+	for i, r := range got.Handshake[:2] {
+		if r.Encrypted || len(r.Messages) != 0 {
+			t.Errorf("Record %d: unexpected content %+v", i, r)
+		}
+	}
+	m := got.Handshake[2].Messages
+	if len(m) != 1 || !bytes.Equal(m[0].Body, testClientHello[63:]) {
+		t.Fatalf("ClientHello not reassembled: %+v", got.Handshake[2])
+	}
+	want := testClientHelloDecoded.Handshake[0].Messages[0].ClientHello
+	if m[0].ClientHello.ServerName != want.ServerName || !reflect.DeepEqual(m[0].ClientHello.CipherSuites, want.CipherSuites) {
+		t.Errorf("ClientHello decoded wrongly: %+v", m[0].ClientHello)
+	}
+}
+
+func TestTLSSerializeRoundTrip(t *testing.T) {
+	// the ClientHello of packet 4 split over two records
+	msg := testClientHello[59:]
+	var fragmented []byte
+	for _, frag := range [][]byte{msg[:100], msg[100:]} {
+		fragmented = append(fragmented, 0x16, 0x03, 0x01, 0, 0)
+		binary.BigEndian.PutUint16(fragmented[len(fragmented)-2:], uint16(len(frag)))
+		fragmented = append(fragmented, frag...)
+	}
+
+	for _, data := range [][]byte{
+		testClientHello[54:],
+		testServerHello,
+		testClientKeyExchange,
+		testNewSessionTicket,
+		testDoubleAppData,
+		testAlertEncrypted,
+		fragmented,
+	} {
+		var tls TLS
+		if err := tls.DecodeFromBytes(data, gopacket.NilDecodeFeedback); err != nil {
+			t.Fatal("Failed to decode TLS:", err)
+		}
+		for _, opts := range []gopacket.SerializeOptions{{}, {FixLengths: true}} {
+			buf := gopacket.NewSerializeBuffer()
+			if err := gopacket.SerializeLayers(buf, opts, &tls); err != nil {
+				t.Fatal("Failed to serialize TLS:", err)
+			}
+			if !bytes.Equal(buf.Bytes(), data) {
+				t.Errorf("Serialization with %+v does not match the decoded data:\ngot:\n%x\nwant:\n%x", opts, buf.Bytes(), data)
+			}
+		}
+	}
+}
+
+func TestTLSSerializeRecords(t *testing.T) {
+	hs := TLSHandshakeRecord{
+		TLSRecordHeader: TLSRecordHeader{Version: 0x0303},
+		Messages: []TLSHandshakeMessage{
+			{Type: TLSHandshakeClientKeyExchange, Body: []byte{0x03, 0x01, 0x02, 0x03}},
+		},
+	}
+	ccs := TLSChangeCipherSpecRecord{
+		TLSRecordHeader: TLSRecordHeader{Version: 0x0303},
+		Message:         TLSChangecipherspecMessage,
+	}
+	fin := TLSHandshakeRecord{
+		TLSRecordHeader: TLSRecordHeader{Version: 0x0303},
+		Encrypted:       true,
+		EncryptedMsg:    []byte{0xde, 0xad, 0xbe, 0xef, 0x00, 0x11},
+	}
+	eth := &Ethernet{
+		SrcMAC:       []byte{0, 1, 2, 3, 4, 5},
+		DstMAC:       []byte{0, 1, 2, 3, 4, 6},
+		EthernetType: EthernetTypeIPv4,
+	}
+	ip := &IPv4{
+		Version:  4,
+		TTL:      64,
+		Protocol: IPProtocolTCP,
+		SrcIP:    []byte{192, 0, 2, 1},
+		DstIP:    []byte{192, 0, 2, 2},
+	}
+	tcp := &TCP{SrcPort: 40000, DstPort: 443, PSH: true, ACK: true}
+
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true}
+	if err := gopacket.SerializeLayers(buf, opts, eth, ip, tcp, &hs, &ccs, &fin); err != nil {
+		t.Fatal("Failed to serialize:", err)
+	}
+	if hs.Length != 8 || hs.Messages[0].Length != 4 || ccs.Length != 1 || fin.Length != 6 {
+		t.Errorf("Lengths not fixed: %d %d %d %d", hs.Length, hs.Messages[0].Length, ccs.Length, fin.Length)
+	}
+
+	p := gopacket.NewPacket(buf.Bytes(), LinkTypeEthernet, testTLSDecodeOptions)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	got, ok := p.Layer(LayerTypeTLS).(*TLS)
+	if !ok {
+		t.Fatal("No TLS layer type found in packet")
+	}
+	want := []TLSType{TLSHandshake, TLSChangeCipherSpec, TLSHandshake}
+	if !reflect.DeepEqual(got.RecordTypes, want) {
+		t.Errorf("Wrong record order %v, want %v", got.RecordTypes, want)
+	}
+	if len(got.Handshake) != 2 || got.Handshake[0].Messages[0].Type != TLSHandshakeClientKeyExchange || !got.Handshake[1].Encrypted {
+		t.Errorf("Handshake records decoded wrongly: %+v", got.Handshake)
+	}
+
+	// Re-serializing the whole layer keeps the record order
+	buf2 := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf2, opts, got); err != nil {
+		t.Fatal("Failed to serialize:", err)
+	}
+	payload := p.Layer(LayerTypeTCP).LayerPayload()
+	if !bytes.Equal(buf2.Bytes(), payload) {
+		t.Errorf("TLS layer serialization mismatch:\ngot:\n%x\nwant:\n%x", buf2.Bytes(), payload)
+	}
+
+	got.RecordTypes = got.RecordTypes[:2]
+	if err := got.SerializeTo(gopacket.NewSerializeBuffer(), opts); err == nil {
+		t.Error("Expected error on RecordTypes mismatch")
+	}
+}
@@ -0,0 +1,760 @@
+// Copyright 2026 The GoPacket Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package pcapfilter
+
+import (
+	"fmt"
+
+	"golang.org/x/net/bpf"
+)
+
+// A filter expression is parsed into a tree of boolean nodes whose
+// leaves are single BPF comparisons, each with the instructions loading
+// the compared value. Code generation turns the tree into a sequence of
+// forward jumps, then a small optimizer removes redundant loads and
+// threads jumps whose outcome is already known, like the libpcap
+// optimizer does for the common cases.
+type node interface{}
+
+// leaf loads a value in A (and possibly X) and tests it.
+type leaf struct {
+	load []bpf.Instruction
+	test bpf.JumpTest // JumpEqual, JumpGreaterThan, JumpGreaterOrEqual or JumpBitsSet
+	val  uint32
+	x    bool // compare A with X rather than val
+}
+
+type andNode struct{ l, r node }
+type orNode struct{ l, r node }
+type notNode struct{ n node }
+type constNode bool
+
+func and(ns ...node) node {
+	var out node
+	for _, n := range ns {
+		if n == nil {
+			continue
+		}
+		if out == nil {
+			out = n
+		} else {
+			out = andNode{out, n}
+		}
+	}
+	if out == nil {
+		return constNode(true)
+	}
+	return out
+}
+
+func or(ns ...node) node {
+	var out node
+	for _, n := range ns {
+		if n == nil {
+			continue
+		}
+		if out == nil {
+			out = n
+		} else {
+			out = orNode{out, n}
+		}
+	}
+	if out == nil {
+		return constNode(false)
+	}
+	return out
+}
+
+func not(n node) node { return notNode{n} }
+
+// cmp returns a leaf testing the value loaded by load.
+func cmp(test bpf.JumpTest, val uint32, load ...bpf.Instruction) node {
+	return leaf{load: load, test: test, val: val}
+}
+
+func eq(val uint32, load ...bpf.Instruction) node {
+	return cmp(bpf.JumpEqual, val, load...)
+}
+
+// insn is an instruction of the program being generated. Jumps refer
+// to their targets by pointer so that instructions can be inserted and
+// removed freely; other instructions fall through to the next one.
+type insn struct {
+	ins bpf.Instruction // for non-jump instructions
+
+	cond   bool // conditional jump: test, val, x, jt, jf
+	test   bpf.JumpTest
+	val    uint32
+	x      bool
+	jt, jf *insn
+
+	ja *insn // unconditional jump target
+
+	removed bool
+}
+
+func (i *insn) isJump() bool { return i.cond || i.ja != nil }
+
+func (i *insn) isRet() bool {
+	switch i.ins.(type) {
+	case bpf.RetA, bpf.RetConstant:
+		return true
+	}
+	return false
+}
+
+// codegen turns a node tree into instructions. Labels are resolved to
+// the instruction following them once the whole tree is emitted.
+type codegen struct {
+	prog []*insn
+	// fixups records jumps to labels placed after the jump.
+	labels map[int][]**insn
+	next   int
+}
+
+func (g *codegen) newLabel() int {
+	g.next++
+	return g.next
+}
+
+func (g *codegen) place(l int) {
+	// the instruction that will be emitted next is the target
+	at := &insn{}
+	g.prog = append(g.prog, at)
+	for _, ref := range g.labels[l] {
+		*ref = at
+	}
+	delete(g.labels, l)
+}
+
+func (g *codegen) ref(l int, p **insn) {
+	g.labels[l] = append(g.labels[l], p)
+}
+
+func (g *codegen) emit(ins bpf.Instruction) {
+	g.prog = append(g.prog, &insn{ins: ins})
+}
+
+func (g *codegen) gen(n node, t, f int) {
+	switch n := n.(type) {
+	case leaf:
+		for _, ins := range n.load {
+			g.emit(ins)
+		}
+		j := &insn{cond: true, test: n.test, val: n.val, x: n.x}
+		g.prog = append(g.prog, j)
+		g.ref(t, &j.jt)
+		g.ref(f, &j.jf)
+	case andNode:
+		m := g.newLabel()
+		g.gen(n.l, m, f)
+		g.place(m)
+		g.gen(n.r, t, f)
+	case orNode:
+		m := g.newLabel()
+		g.gen(n.l, t, m)
+		g.place(m)
+		g.gen(n.r, t, f)
+	case notNode:
+		g.gen(n.n, f, t)
+	case constNode:
+		j := &insn{}
+		g.prog = append(g.prog, j)
+		if n {
+			g.ref(t, &j.ja)
+		} else {
+			g.ref(f, &j.ja)
+		}
+	default:
+		panic(fmt.Sprintf("pcapfilter: unknown node %T", n))
+	}
+}
+
+// generate compiles n into an optimized BPF program returning snaplen
+// for accepted packets and 0 for rejected ones.
+func generate(n node, snaplen uint32) ([]bpf.Instruction, error) {
+	g := &codegen{labels: make(map[int][]**insn)}
+	accept, reject := g.newLabel(), g.newLabel()
+	g.gen(n, accept, reject)
+	g.place(accept)
+	g.emit(bpf.RetConstant{Val: snaplen})
+	g.place(reject)
+	g.emit(bpf.RetConstant{Val: 0})
+
+	// placeholders inserted by place behave as a jump to the next
+	// instruction, drop them
+	for _, i := range g.prog {
+		if i.ins == nil && !i.isJump() {
+			i.removed = true
+		}
+	}
+	prog := compact(g.prog)
+	prog = threadJumps(prog)
+	prog = removeRedundantLoads(prog)
+	return layout(prog)
+}
+
+// compact drops removed instructions and unconditional jumps to the
+// next instruction, redirecting jumps to the instruction that follows
+// them, then drops unreachable instructions.
+func compact(prog []*insn) []*insn {
+	for {
+		for k, i := range prog {
+			if i.ja != nil && !i.removed && resolvePtr(prog, i.ja) == resolve(prog, k+1) {
+				i.removed = true
+			}
+		}
+		// redirect jumps before dropping anything, removed
+		// instructions must still be found in prog
+		for _, i := range prog {
+			if i.removed {
+				continue
+			}
+			if i.cond {
+				i.jt = resolvePtr(prog, i.jt)
+				i.jf = resolvePtr(prog, i.jf)
+				if i.jt == i.jf {
+					i.cond, i.ja, i.jt, i.jf = false, i.jt, nil, nil
+				}
+			} else if i.ja != nil {
+				i.ja = resolvePtr(prog, i.ja)
+			}
+		}
+		out := make([]*insn, 0, len(prog))
+		for _, i := range prog {
+			if !i.removed {
+				out = append(out, i)
+			}
+		}
+		out = reachable(out)
+		if len(out) == len(prog) {
+			return out
+		}
+		prog = out
+	}
+}
+
+// resolve returns the first instruction at or after index k which is
+// not removed, following unconditional jumps.
+func resolve(prog []*insn, k int) *insn {
+	for k < len(prog) && prog[k].removed {
+		k++
+	}
+	if k == len(prog) {
+		return nil
+	}
+	if j := prog[k]; j.ja != nil && j.ja != j {
+		return resolvePtr(prog, j.ja)
+	}
+	return prog[k]
+}
+
+func resolvePtr(prog []*insn, i *insn) *insn {
+	for k, p := range prog {
+		if p == i {
+			return resolve(prog, k)
+		}
+	}
+	return i
+}
+
+func reachable(prog []*insn) []*insn {
+	index := indexOf(prog)
+	seen := make([]bool, len(prog))
+	var visit func(k int)
+	visit = func(k int) {
+		for k < len(prog) && !seen[k] {
+			seen[k] = true
+			i := prog[k]
+			switch {
+			case i.cond:
+				visit(index[i.jt])
+				k = index[i.jf]
+			case i.ja != nil:
+				k = index[i.ja]
+			case i.isRet():
+				return
+			default:
+				k++
+			}
+		}
+	}
+	visit(0)
+	out := prog[:0:0]
+	for k, i := range prog {
+		if seen[k] {
+			out = append(out, i)
+		}
+	}
+	return out
+}
+
+func indexOf(prog []*insn) map[*insn]int {
+	index := make(map[*insn]int, len(prog))
+	for k, i := range prog {
+		index[i] = k
+	}
+	return index
+}
+
+// regState describes what is known of the A and X registers: the key of
+// the computation that produced them, or "" if unknown.
+type regState struct {
+	a, x string
+}
+
+func (s regState) meet(o regState) regState {
+	if s.a != o.a {
+		s.a = ""
+	}
+	if s.x != o.x {
+		s.x = ""
+	}
+	return s
+}
+
+// transfer returns the state after executing ins in state s.
+func transfer(ins bpf.Instruction, s regState) regState {
+	switch ins := ins.(type) {
+	case bpf.LoadAbsolute:
+		s.a = fmt.Sprintf("abs %d %d", ins.Off, ins.Size)
+	case bpf.LoadIndirect:
+		s.a = ""
+		if s.x != "" {
+			s.a = fmt.Sprintf("ind (%s) %d %d", s.x, ins.Off, ins.Size)
+		}
+	case bpf.LoadExtension:
+		s.a = fmt.Sprintf("ext %d", ins.Num)
+	case bpf.LoadConstant:
+		if ins.Dst == bpf.RegA {
+			s.a = fmt.Sprintf("const %d", ins.Val)
+		} else {
+			s.x = fmt.Sprintf("const %d", ins.Val)
+		}
+	case bpf.LoadMemShift:
+		s.x = fmt.Sprintf("msh %d", ins.Off)
+	case bpf.LoadScratch:
+		if ins.Dst == bpf.RegA {
+			s.a = ""
+		} else {
+			s.x = ""
+		}
+	case bpf.ALUOpConstant:
+		if s.a != "" {
+			s.a = fmt.Sprintf("(%s) op %d %d", s.a, ins.Op, ins.Val)
+		}
+	case bpf.ALUOpX:
+		if s.a != "" && s.x != "" {
+			s.a = fmt.Sprintf("(%s) op %d (%s)", s.a, ins.Op, s.x)
+		} else {
+			s.a = ""
+		}
+	case bpf.NegateA:
+		if s.a != "" {
+			s.a = fmt.Sprintf("neg (%s)", s.a)
+		}
+	case bpf.TAX:
+		s.x = s.a
+	case bpf.TXA:
+		s.a = s.x
+	}
+	return s
+}
+
+// states computes the register state on entry of each instruction.
+func states(prog []*insn) []regState {
+	index := indexOf(prog)
+	in := make([]regState, len(prog))
+	reached := make([]bool, len(prog))
+	reached[0] = true
+	flow := func(k int, s regState) {
+		if !reached[k] {
+			in[k], reached[k] = s, true
+		} else {
+			in[k] = in[k].meet(s)
+		}
+	}
+	// jumps only go forward, so a single pass sees every predecessor
+	// of an instruction before the instruction itself
+	for k, i := range prog {
+		if !reached[k] {
+			continue
+		}
+		switch {
+		case i.cond:
+			flow(index[i.jt], in[k])
+			flow(index[i.jf], in[k])
+		case i.ja != nil:
+			flow(index[i.ja], in[k])
+		case i.isRet():
+		default:
+			flow(k+1, transfer(i.ins, in[k]))
+		}
+	}
+	return in
+}
+
+// isLoad reports whether ins only sets a register from the packet or a
+// constant, so that it can be dropped when the register already holds
+// that value.
+func isLoad(ins bpf.Instruction) bool {
+	switch ins.(type) {
+	case bpf.LoadAbsolute, bpf.LoadIndirect, bpf.LoadExtension, bpf.LoadConstant, bpf.LoadMemShift:
+		return true
+	}
+	return false
+}
+
+func setsX(ins bpf.Instruction) bool {
+	switch ins := ins.(type) {
+	case bpf.LoadMemShift:
+		return true
+	case bpf.LoadConstant:
+		return ins.Dst == bpf.RegX
+	}
+	return false
+}
+
+func removeRedundantLoads(prog []*insn) []*insn {
+	in := states(prog)
+	for k, i := range prog {
+		if i.ins == nil || !isLoad(i.ins) {
+			continue
+		}
+		out := transfer(i.ins, in[k])
+		if out.a != in[k].a || out.x != in[k].x {
+			continue
+		}
+		if setsX(i.ins) {
+			i.removed = in[k].x != ""
+		} else {
+			i.removed = in[k].a != ""
+		}
+	}
+	return compact(prog)
+}
+
+// fact is the known outcome of a test of the value identified by key.
+// Keys identify values computed from the packet only, so a fact holds
+// wherever it is known, whatever the registers hold.
+type fact struct {
+	key   string
+	test  bpf.JumpTest
+	val   uint32
+	taken bool
+}
+
+// decide returns the outcome of test against val on the value of key,
+// if it is implied by facts.
+func decide(facts []fact, key string, test bpf.JumpTest, val uint32) (bool, bool) {
+	for _, f := range facts {
+		if f.key != key {
+			continue
+		}
+		if res, ok := implies(f.test, f.val, f.taken, test, val); ok {
+			return res, true
+		}
+	}
+	return false, false
+}
+
+func intersect(a, b []fact) []fact {
+	var out []fact
+	for _, f := range a {
+		for _, g := range b {
+			if f == g {
+				out = append(out, f)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// computeFacts returns the facts known on entry of each instruction,
+// those established by the tests on every path leading to it.
+func computeFacts(prog []*insn, in []regState) [][]fact {
+	index := indexOf(prog)
+	facts := make([][]fact, len(prog))
+	reached := make([]bool, len(prog))
+	reached[0] = true
+	flow := func(k int, fs []fact) {
+		if !reached[k] {
+			facts[k], reached[k] = fs, true
+		} else {
+			facts[k] = intersect(facts[k], fs)
+		}
+	}
+	for k, i := range prog {
+		if !reached[k] {
+			continue
+		}
+		switch {
+		case i.cond:
+			ft, ff := facts[k], facts[k]
+			if in[k].a != "" && !i.x {
+				ft = append(ft[:len(ft):len(ft)], fact{in[k].a, i.test, i.val, true})
+				ff = append(ff[:len(ff):len(ff)], fact{in[k].a, i.test, i.val, false})
+			}
+			flow(index[i.jt], ft)
+			flow(index[i.jf], ff)
+		case i.ja != nil:
+			flow(index[i.ja], facts[k])
+		case i.isRet():
+		default:
+			flow(k+1, facts[k])
+		}
+	}
+	return facts
+}
+
+// threadJumps retargets conditional jumps leading to tests whose
+// outcome is already known, e.g. a jump taken because the ethertype is
+// IPv6 never needs to test for IPv4. It repeats until no jump changes.
+func threadJumps(prog []*insn) []*insn {
+	for changed := true; changed; {
+		changed = false
+		in := states(prog)
+		facts := computeFacts(prog, in)
+		index := indexOf(prog)
+		for k, i := range prog {
+			if !i.cond {
+				continue
+			}
+			for _, taken := range []bool{true, false} {
+				fs := facts[k]
+				if in[k].a != "" && !i.x {
+					fs = append(fs[:len(fs):len(fs)], fact{in[k].a, i.test, i.val, taken})
+				}
+				target := &i.jf
+				if taken {
+					target = &i.jt
+				}
+				if t := follow(prog, index, *target, in[k], fs); t != *target {
+					*target, changed = t, true
+				}
+			}
+		}
+		prog = compact(prog)
+	}
+	return prog
+}
+
+// follow walks from t, reached with registers s and facts fs, through
+// loads and tests whose outcome is known. It returns the furthest
+// instruction reached that can be jumped to directly: one where the
+// registers hold what they hold at t, or which does not read them.
+func follow(prog []*insn, index map[*insn]int, t *insn, s regState, fs []fact) *insn {
+	best, start := t, s
+	for {
+		switch {
+		case t.ja != nil:
+			t = t.ja
+		case t.cond && !t.x && s.a != "":
+			res, ok := decide(fs, s.a, t.test, t.val)
+			if !ok {
+				return best
+			}
+			fs = append(fs[:len(fs):len(fs)], fact{s.a, t.test, t.val, res})
+			if res {
+				t = t.jt
+			} else {
+				t = t.jf
+			}
+		case t.ins != nil && pure(t.ins):
+			s = transfer(t.ins, s)
+			t = prog[index[t]+1]
+			continue
+		default:
+			return best
+		}
+		needA, needX := reads(prog, index[t])
+		if (s.a == start.a || !needA) && (s.x == start.x || !needX) {
+			best = t
+		}
+	}
+}
+
+// pure reports whether ins only computes registers from the packet and
+// the registers, without side effects.
+func pure(ins bpf.Instruction) bool {
+	switch ins.(type) {
+	case bpf.LoadAbsolute, bpf.LoadIndirect, bpf.LoadExtension, bpf.LoadConstant,
+		bpf.LoadMemShift, bpf.ALUOpConstant, bpf.ALUOpX, bpf.NegateA, bpf.TAX, bpf.TXA:
+		return true
+	}
+	return false
+}
+
+// reads reports whether the code starting at k may read A or X before
+// setting them.
+func reads(prog []*insn, k int) (needA, needX bool) {
+	setA, setX := false, false
+	for ; k < len(prog); k++ {
+		i := prog[k]
+		if i.isJump() {
+			return !setA, !setX
+		}
+		switch ins := i.ins.(type) {
+		case bpf.RetConstant:
+			return false, false
+		case bpf.LoadAbsolute, bpf.LoadExtension:
+			setA = true
+		case bpf.LoadConstant:
+			if ins.Dst == bpf.RegA {
+				setA = true
+			} else {
+				setX = true
+			}
+		case bpf.LoadScratch:
+			if ins.Dst == bpf.RegA {
+				setA = true
+			} else {
+				setX = true
+			}
+		case bpf.LoadIndirect:
+			if !setX {
+				needX = true
+			}
+			setA = true
+		case bpf.LoadMemShift:
+			setX = true
+		case bpf.TXA:
+			if !setX {
+				needX = true
+			}
+			setA = true
+		default:
+			// ALU operations, TAX, stores and RetA read A, ALUOpX
+			// also reads X
+			return !setA, !setX
+		}
+		if (setA || needA) && (setX || needX) {
+			return needA, needX
+		}
+	}
+	return !setA, !setX
+}
+
+// implies returns the outcome of test2 against val2 knowing the outcome
+// of test1 against val1 on the same value, if it can be deduced.
+func implies(test1 bpf.JumpTest, val1 uint32, taken bool, test2 bpf.JumpTest, val2 uint32) (bool, bool) {
+	// lo and hi bound the value when known
+	lo, hi := uint32(0), ^uint32(0)
+	switch test1 {
+	case bpf.JumpEqual:
+		if taken {
+			lo, hi = val1, val1
+		} else if test2 == bpf.JumpEqual && val2 == val1 {
+			return false, true
+		}
+	case bpf.JumpGreaterThan:
+		if taken {
+			if val1 == ^uint32(0) {
+				return false, false
+			}
+			lo = val1 + 1
+		} else {
+			hi = val1
+		}
+	case bpf.JumpGreaterOrEqual:
+		if taken {
+			lo = val1
+		} else {
+			if val1 == 0 {
+				return false, false
+			}
+			hi = val1 - 1
+		}
+	case bpf.JumpBitsSet:
+		if test2 == bpf.JumpBitsSet && val2 == val1 {
+			return taken, true
+		}
+	}
+	switch test2 {
+	case bpf.JumpEqual:
+		if lo == hi {
+			return lo == val2, true
+		}
+		if val2 < lo || val2 > hi {
+			return false, true
+		}
+	case bpf.JumpGreaterThan:
+		if lo > val2 {
+			return true, true
+		}
+		if hi <= val2 {
+			return false, true
+		}
+	case bpf.JumpGreaterOrEqual:
+		if lo >= val2 {
+			return true, true
+		}
+		if hi < val2 {
+			return false, true
+		}
+	case bpf.JumpBitsSet:
+		if lo == hi {
+			return lo&val2 != 0, true
+		}
+	}
+	return false, false
+}
+
+// layout converts the instructions to BPF, inserting unconditional
+// jumps where a conditional jump is too long for its 8-bit offset.
+func layout(prog []*insn) ([]bpf.Instruction, error) {
+	for {
+		index := indexOf(prog)
+		far := -1
+		var target **insn
+		for k, i := range prog {
+			if !i.cond {
+				continue
+			}
+			if index[i.jt]-k-1 > 255 {
+				far, target = k, &i.jt
+				break
+			}
+			if index[i.jf]-k-1 > 255 {
+				far, target = k, &i.jf
+				break
+			}
+		}
+		if far < 0 {
+			break
+		}
+		j := &insn{ja: *target}
+		*target = j
+		prog = append(prog[:far+1], append([]*insn{j}, prog[far+1:]...)...)
+	}
+
+	index := indexOf(prog)
+	out := make([]bpf.Instruction, len(prog))
+	for k, i := range prog {
+		switch {
+		case i.cond:
+			skipTrue, skipFalse := uint8(index[i.jt]-k-1), uint8(index[i.jf]-k-1)
+			if i.x {
+				out[k] = bpf.JumpIfX{Cond: i.test, SkipTrue: skipTrue, SkipFalse: skipFalse}
+			} else {
+				out[k] = bpf.JumpIf{Cond: i.test, Val: i.val, SkipTrue: skipTrue, SkipFalse: skipFalse}
+			}
+		case i.ja != nil:
+			out[k] = bpf.Jump{Skip: uint32(index[i.ja] - k - 1)}
+		default:
+			out[k] = i.ins
+		}
+	}
+	if len(out) > maxInstructions {
+		return nil, fmt.Errorf("pcapfilter: program too long (%d instructions)", len(out))
+	}
+	return out, nil
+}
+
+// maxInstructions is the maximum length of a BPF program (BPF_MAXINSNS).
+const maxInstructions = 4096
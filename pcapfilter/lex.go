@@ -0,0 +1,115 @@
+// Copyright 2026 The GoPacket Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package pcapfilter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokWord
+	tokOp
+)
+
+type token struct {
+	kind tokenKind
+	s    string
+	pos  int
+	// escaped is set for words written with a leading backslash, which
+	// are never keywords ("ip proto \tcp").
+	escaped bool
+}
+
+func (t token) String() string {
+	if t.kind == tokEOF {
+		return "end of expression"
+	}
+	return strconv.Quote(t.s)
+}
+
+func isWordStart(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_'
+}
+
+// isWordChar reports whether c may continue a word. Words include the
+// punctuation of addresses and names ("10.0.0.1", "fe80::1",
+// "00:11:22:33:44:55", "tcp-syn", "1-1023"); colons are not allowed
+// inside brackets where they separate offset and size.
+func isWordChar(c byte, inBrackets bool) bool {
+	return isWordStart(c) || c == '.' || c == '-' || c == ':' && !inBrackets
+}
+
+var twoCharOps = []string{"&&", "||", "<<", ">>", "<=", ">=", "==", "!="}
+
+// lex splits a filter expression in tokens.
+func lex(expr string) ([]token, error) {
+	var toks []token
+	brackets := 0
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case isWordStart(c) || c == '\\' || c == ':' && i+1 < len(expr) && expr[i+1] == ':' && brackets == 0:
+			start := i
+			escaped := c == '\\'
+			if escaped {
+				i++
+			}
+			j := i
+			for j < len(expr) && isWordChar(expr[j], brackets > 0) {
+				j++
+			}
+			// words never end with a dash, so that "len-1" is
+			// not silently accepted as a name
+			word := strings.TrimRight(expr[i:j], "-")
+			if word == "" {
+				return nil, fmt.Errorf("pcapfilter: unexpected character %q at offset %d", c, start)
+			}
+			i += len(word)
+			toks = append(toks, token{kind: tokWord, s: word, pos: start, escaped: escaped})
+		default:
+			op := ""
+			for _, o := range twoCharOps {
+				if strings.HasPrefix(expr[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				if !strings.ContainsRune("()[]:!&|^+-*/%<>=", rune(c)) {
+					return nil, fmt.Errorf("pcapfilter: unexpected character %q at offset %d", c, i)
+				}
+				op = expr[i : i+1]
+			}
+			switch op {
+			case "[":
+				brackets++
+			case "]":
+				brackets--
+			}
+			toks = append(toks, token{kind: tokOp, s: op, pos: i})
+			i += len(op)
+		}
+	}
+	return append(toks, token{kind: tokEOF, pos: len(expr)}), nil
+}
+
+// parseNumber parses a decimal, hexadecimal (0x) or octal (leading 0)
+// unsigned 32-bit number.
+func parseNumber(s string) (uint32, bool) {
+	v, err := strconv.ParseUint(s, 0, 32)
+	if err != nil {
+		return 0, false
+	}
+	return uint32(v), true
+}
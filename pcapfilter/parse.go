@@ -0,0 +1,786 @@
+// Copyright 2026 The GoPacket Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package pcapfilter
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"golang.org/x/net/bpf"
+)
+
+// syntaxError is a parse error with the position of the token it was
+// found at, so that the most relevant of two alternative parses can be
+// reported.
+type syntaxError struct {
+	pos int
+	msg string
+}
+
+func (e *syntaxError) Error() string {
+	return fmt.Sprintf("pcapfilter: syntax error at offset %d: %s", e.pos, e.msg)
+}
+
+var (
+	protoKeywords = map[string]bool{
+		"ether": true, "link": true, "ip": true, "ip6": true, "arp": true,
+		"rarp": true, "tcp": true, "udp": true, "sctp": true, "icmp": true,
+		"icmp6": true, "igmp": true,
+	}
+	typeKeywords = map[string]bool{
+		"host": true, "net": true, "port": true, "portrange": true,
+	}
+)
+
+// qualifiers are the proto, dir and type keywords of a primitive.
+type qualifiers struct {
+	proto string
+	dir   direction
+	typ   string
+}
+
+type parser struct {
+	toks []token
+	pos  int
+	link linkInfo
+	// last holds the qualifiers of the last primitive with an id, which
+	// bare ids inherit: "host a or b" is "host a or host b".
+	last    qualifiers
+	hasLast bool
+}
+
+func (p *parser) peek() token { return p.toks[p.pos] }
+
+func (p *parser) peekAt(n int) token {
+	if p.pos+n >= len(p.toks) {
+		return p.toks[len(p.toks)-1]
+	}
+	return p.toks[p.pos+n]
+}
+
+func (p *parser) next() token {
+	t := p.toks[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+// is reports whether t is one of the given operators or unescaped
+// keywords.
+func (t token) is(s ...string) bool {
+	if t.kind == tokEOF || t.escaped {
+		return false
+	}
+	for _, x := range s {
+		if t.s == x {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return &syntaxError{pos: p.peek().pos, msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) expect(op string) error {
+	if t := p.peek(); t.kind != tokOp || t.s != op {
+		return p.errorf("expected %q, got %s", op, t)
+	}
+	p.pos++
+	return nil
+}
+
+// parseExpr parses a sequence of primitives joined by "and" and "or",
+// which have the same precedence and associate to the left.
+func (p *parser) parseExpr() (node, error) {
+	n, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		var isAnd bool
+		switch {
+		case t.is("and", "&&"):
+			isAnd = true
+		case t.is("or", "||"):
+		default:
+			return n, nil
+		}
+		p.pos++
+		r, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if isAnd {
+			n = andNode{n, r}
+		} else {
+			n = orNode{n, r}
+		}
+	}
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.peek().is("not", "!") {
+		p.pos++
+		n, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return not(n), nil
+	}
+	return p.parsePrimary()
+}
+
+// parsePrimary parses a relation between arithmetic expressions, a
+// parenthesized expression or a primitive. Relations and parenthesized
+// expressions both may start with "(", so a relation is tried first.
+func (p *parser) parsePrimary() (node, error) {
+	start := p.pos
+	n, relErr := p.parseRelation()
+	if relErr == nil {
+		return n, nil
+	}
+	p.pos = start
+	var err error
+	if p.peek().is("(") {
+		p.pos++
+		if n, err = p.parseExpr(); err == nil {
+			err = p.expect(")")
+		}
+	} else {
+		n, err = p.parsePrimitive()
+	}
+	if err == nil {
+		return n, nil
+	}
+	// report the error of the parse that went the furthest
+	if re, ok := relErr.(*syntaxError); ok {
+		if e, ok := err.(*syntaxError); ok && re.pos > e.pos {
+			return nil, relErr
+		}
+	}
+	return nil, err
+}
+
+func (p *parser) parsePrimitive() (node, error) {
+	t := p.peek()
+	if t.kind != tokWord {
+		return nil, p.errorf("unexpected %s", t)
+	}
+	switch {
+	case t.is("less", "greater"):
+		p.pos++
+		v, err := p.parseNumber()
+		if err != nil {
+			return nil, err
+		}
+		return lengthTest(t.s == "greater", v), nil
+	case t.is("vlan"):
+		p.pos++
+		id := -1
+		if v, ok := parseNumber(p.peek().s); ok && p.peek().kind == tokWord {
+			if v > 0x0fff {
+				return nil, p.errorf("VLAN identifier %d out of range", v)
+			}
+			p.pos++
+			id = int(v)
+		}
+		n, err := p.link.vlan(id)
+		if err != nil {
+			return nil, err
+		}
+		// the headers that follow are 4 bytes further
+		p.link.linkType += 4
+		p.link.nl += 4
+		return n, nil
+	case t.is("broadcast"):
+		p.pos++
+		return p.link.etherBroadcast()
+	case t.is("multicast"):
+		p.pos++
+		return p.link.etherMulticast()
+	case t.is("proto"):
+		p.pos++
+		return p.parseProtoValue("")
+	case t.is("gateway"):
+		return nil, p.errorf("gateway is not supported")
+	}
+
+	var q qualifiers
+	explicit, hasDir := false, false
+	if t := p.peek(); t.kind == tokWord && !t.escaped && protoKeywords[t.s] {
+		p.pos++
+		q.proto, explicit = t.s, true
+		if q.proto == "link" {
+			q.proto = "ether"
+		}
+		switch t := p.peek(); {
+		case t.is("proto"):
+			p.pos++
+			return p.parseProtoValue(q.proto)
+		case t.is("broadcast"):
+			p.pos++
+			if q.proto != "ether" {
+				return nil, p.errorf("%s broadcast is not supported", q.proto)
+			}
+			return p.link.etherBroadcast()
+		case t.is("multicast"):
+			p.pos++
+			switch q.proto {
+			case "ether":
+				return p.link.etherMulticast()
+			case "ip":
+				return p.link.ipMulticast(), nil
+			case "ip6":
+				return p.link.ip6Multicast(), nil
+			}
+			return nil, p.errorf("%s multicast is not supported", q.proto)
+		}
+	}
+	if t := p.peek(); t.is("src", "dst") {
+		p.pos++
+		explicit, hasDir = true, true
+		q.dir = dirSrc
+		if t.s == "dst" {
+			q.dir = dirDst
+		}
+		// "src or dst" and "src and dst" qualify a single primitive
+		if j := p.peek(); j.is("or", "and") && p.peekAt(1).is("src", "dst") && p.peekAt(1).s != t.s {
+			p.pos += 2
+			q.dir = dirSrcOrDst
+			if j.s == "and" {
+				q.dir = dirSrcAndDst
+			}
+		}
+	}
+	if t := p.peek(); t.kind == tokWord && !t.escaped && typeKeywords[t.s] {
+		p.pos++
+		q.typ, explicit = t.s, true
+	}
+
+	if !explicit {
+		q = qualifiers{typ: "host"}
+		if p.hasLast {
+			q = p.last
+		}
+	} else if q.typ == "" {
+		if !hasDir {
+			// a protocol alone, e.g. "tcp"
+			if n, ok := p.link.proto(q.proto); ok {
+				return n, nil
+			}
+			return nil, p.errorf("'%s' needs an address", q.proto)
+		}
+		q.typ = "host"
+	}
+
+	id := p.peek()
+	if id.kind != tokWord || id.is("and", "or", "not") {
+		return nil, p.errorf("expected %s, got %s", q.typ, id)
+	}
+	p.pos++
+	p.last, p.hasLast = q, true
+	return p.primitive(q, id)
+}
+
+// primitive builds a host, net, port or portrange primitive.
+func (p *parser) primitive(q qualifiers, id token) (node, error) {
+	bad := func(format string, args ...interface{}) error {
+		return &syntaxError{pos: id.pos, msg: fmt.Sprintf(format, args...)}
+	}
+	switch q.typ {
+	case "host":
+		if ip := net.ParseIP(id.s); ip != nil {
+			if ip4 := ip.To4(); ip4 != nil && !strings.Contains(id.s, ":") {
+				return p.link.net4(q.proto, q.dir, ipUint32(ip4), 0xffffffff)
+			}
+			return p.link.net6(q.proto, q.dir, ip, 128)
+		}
+		if mac, err := net.ParseMAC(id.s); err == nil && len(mac) == 6 {
+			if q.proto != "" && q.proto != "ether" {
+				return nil, bad("'%s' is not valid with an Ethernet address", q.proto)
+			}
+			return p.link.etherHost(q.dir, mac)
+		}
+		return nil, bad("unknown host %q (host names are not supported)", id.s)
+	case "net":
+		if p.peek().is("/") && p.peekAt(1).kind == tokWord {
+			p.pos++
+			id.s += "/" + p.next().s
+		}
+		if strings.Contains(id.s, ":") {
+			_, ipnet, err := net.ParseCIDR(id.s)
+			if err != nil {
+				if ip := net.ParseIP(id.s); ip != nil {
+					return p.link.net6(q.proto, q.dir, ip, 128)
+				}
+				return nil, bad("invalid IPv6 network %q", id.s)
+			}
+			bits, _ := ipnet.Mask.Size()
+			return p.link.net6(q.proto, q.dir, ipnet.IP, bits)
+		}
+		addr, mask, err := parseNet4(id.s)
+		if err != nil {
+			return nil, bad("%v", err)
+		}
+		if p.peek().is("mask") {
+			if strings.Contains(id.s, "/") {
+				return nil, p.errorf("mask used with a prefix length")
+			}
+			p.pos++
+			m := p.next()
+			ip := net.ParseIP(m.s).To4()
+			if ip == nil {
+				return nil, &syntaxError{pos: m.pos, msg: fmt.Sprintf("invalid mask %q", m.s)}
+			}
+			mask = ipUint32(ip)
+		}
+		if addr&^mask != 0 {
+			return nil, bad("non-network bits set in %q", id.s)
+		}
+		return p.link.net4(q.proto, q.dir, addr, mask)
+	case "port":
+		port, ok := parsePort(id.s)
+		if !ok {
+			return nil, bad("unknown port %q", id.s)
+		}
+		return p.link.port(q.proto, q.dir, port, port)
+	case "portrange":
+		for i := 0; i < len(id.s); i++ {
+			if id.s[i] != '-' {
+				continue
+			}
+			lo, ok1 := parsePort(id.s[:i])
+			hi, ok2 := parsePort(id.s[i+1:])
+			if ok1 && ok2 {
+				if lo > hi {
+					lo, hi = hi, lo
+				}
+				return p.link.port(q.proto, q.dir, lo, hi)
+			}
+		}
+		return nil, bad("invalid port range %q", id.s)
+	}
+	return nil, bad("unknown qualifier %q", q.typ)
+}
+
+// parseProtoValue parses the value of "ether proto", "ip proto",
+// "ip6 proto" and "proto".
+func (p *parser) parseProtoValue(qual string) (node, error) {
+	t := p.next()
+	if t.kind != tokWord {
+		return nil, &syntaxError{pos: t.pos, msg: fmt.Sprintf("expected protocol, got %s", t)}
+	}
+	names := ipProtoNames
+	if qual == "ether" {
+		names = etherProtoNames
+	}
+	v, ok := parseNumber(t.s)
+	if !ok {
+		if v, ok = names[t.s]; !ok {
+			return nil, &syntaxError{pos: t.pos, msg: fmt.Sprintf("unknown protocol %q", t.s)}
+		}
+	}
+	switch qual {
+	case "ether":
+		if v > 0xffff {
+			return nil, &syntaxError{pos: t.pos, msg: fmt.Sprintf("ethertype %d out of range", v)}
+		}
+		return p.link.ethertype(v), nil
+	case "ip":
+		return p.link.ipProto(v), nil
+	case "ip6":
+		return p.link.ip6Proto(v), nil
+	case "":
+		return or(p.link.ipProto(v), p.link.ip6Proto(v)), nil
+	}
+	return nil, &syntaxError{pos: t.pos, msg: fmt.Sprintf("%s proto is not supported", qual)}
+}
+
+func (p *parser) parseNumber() (uint32, error) {
+	t := p.peek()
+	v, ok := parseNumber(t.s)
+	if t.kind != tokWord || !ok {
+		return 0, p.errorf("expected number, got %s", t)
+	}
+	p.pos++
+	return v, nil
+}
+
+func parsePort(s string) (uint32, bool) {
+	v, ok := parseNumber(s)
+	if !ok {
+		v, ok = serviceNames[s]
+	}
+	return v, ok && v <= 0xffff
+}
+
+// parseNet4 parses an IPv4 network: "10.1.2.0/24", or an address with
+// trailing bytes omitted ("10", "10.1", "10.1.2") whose mask covers the
+// bytes given.
+func parseNet4(s string) (addr, mask uint32, err error) {
+	if i := strings.IndexByte(s, '/'); i >= 0 {
+		ip, ipnet, err := net.ParseCIDR(s)
+		if err != nil || ip.To4() == nil {
+			return 0, 0, fmt.Errorf("invalid network %q", s)
+		}
+		return ipUint32(ip), ipUint32(net.IP(ipnet.Mask)), nil
+	}
+	parts := strings.Split(s, ".")
+	if len(parts) > 4 {
+		return 0, 0, fmt.Errorf("invalid network %q", s)
+	}
+	for i, part := range parts {
+		v, ok := parseNumber(part)
+		if !ok || v > 255 || strings.HasPrefix(part, "0x") {
+			return 0, 0, fmt.Errorf("invalid network %q", s)
+		}
+		addr |= v << uint(24-8*i)
+		mask |= 0xff << uint(24-8*i)
+	}
+	return addr, mask, nil
+}
+
+func ipUint32(ip net.IP) uint32 {
+	ip = ip.To4()
+	return uint32(ip[0])<<24 | uint32(ip[1])<<16 | uint32(ip[2])<<8 | uint32(ip[3])
+}
+
+// Arithmetic expressions.
+type (
+	arith interface{}
+	// num is a constant
+	num uint32
+	// pktLen is the length of the packet
+	pktLen struct{}
+	// pktLoad is proto[idx:size]
+	pktLoad struct {
+		proto string
+		idx   arith
+		size  int
+		link  linkInfo
+	}
+	binop struct {
+		op   bpf.ALUOp
+		l, r arith
+	}
+	negate struct{ a arith }
+)
+
+var (
+	relops = map[string]bool{">": true, "<": true, ">=": true, "<=": true, "=": true, "==": true, "!=": true}
+	// binary operators by increasing precedence
+	arithLevels = [][]string{{"|"}, {"^"}, {"&"}, {"<<", ">>"}, {"+", "-"}, {"*", "/", "%"}}
+	aluOps      = map[string]bpf.ALUOp{
+		"|": bpf.ALUOpOr, "^": bpf.ALUOpXor, "&": bpf.ALUOpAnd,
+		"<<": bpf.ALUOpShiftLeft, ">>": bpf.ALUOpShiftRight,
+		"+": bpf.ALUOpAdd, "-": bpf.ALUOpSub,
+		"*": bpf.ALUOpMul, "/": bpf.ALUOpDiv, "%": bpf.ALUOpMod,
+	}
+)
+
+func (p *parser) parseRelation() (node, error) {
+	l, err := p.parseArith(0)
+	if err != nil {
+		return nil, err
+	}
+	op := p.peek()
+	if op.kind != tokOp || !relops[op.s] {
+		return nil, p.errorf("expected comparison, got %s", op)
+	}
+	p.pos++
+	r, err := p.parseArith(0)
+	if err != nil {
+		return nil, err
+	}
+	return relation(l, op.s, r)
+}
+
+func (p *parser) parseArith(level int) (arith, error) {
+	if level == len(arithLevels) {
+		return p.parseArithUnary()
+	}
+	l, err := p.parseArith(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if t.kind != tokOp || !t.is(arithLevels[level]...) {
+			return l, nil
+		}
+		p.pos++
+		r, err := p.parseArith(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		if l, err = fold(binop{aluOps[t.s], l, r}); err != nil {
+			return nil, &syntaxError{pos: t.pos, msg: err.Error()}
+		}
+	}
+}
+
+func (p *parser) parseArithUnary() (arith, error) {
+	t := p.peek()
+	switch {
+	case t.kind == tokOp && t.s == "-":
+		p.pos++
+		a, err := p.parseArithUnary()
+		if err != nil {
+			return nil, err
+		}
+		if n, ok := a.(num); ok {
+			return -n, nil
+		}
+		return negate{a}, nil
+	case t.kind == tokOp && t.s == "(":
+		p.pos++
+		a, err := p.parseArith(0)
+		if err != nil {
+			return nil, err
+		}
+		return a, p.expect(")")
+	case t.kind != tokWord:
+		return nil, p.errorf("unexpected %s", t)
+	}
+	if v, ok := parseNumber(t.s); ok {
+		p.pos++
+		return num(v), nil
+	}
+	if t.is("len") {
+		p.pos++
+		return pktLen{}, nil
+	}
+	if v, ok := namedConstants[t.s]; ok && !t.escaped {
+		p.pos++
+		return num(v), nil
+	}
+	if !protoKeywords[t.s] || t.escaped || !p.peekAt(1).is("[") {
+		return nil, p.errorf("unexpected %s in expression", t)
+	}
+	p.pos += 2
+	ld := pktLoad{proto: t.s, size: 1, link: p.link}
+	if ld.proto == "link" {
+		ld.proto = "ether"
+	}
+	var err error
+	if ld.idx, err = p.parseArith(0); err != nil {
+		return nil, err
+	}
+	if p.peek().is(":") {
+		p.pos++
+		size, err := p.parseNumber()
+		if err != nil {
+			return nil, err
+		}
+		if size != 1 && size != 2 && size != 4 {
+			return nil, p.errorf("data size must be 1, 2 or 4")
+		}
+		ld.size = int(size)
+	}
+	return ld, p.expect("]")
+}
+
+// fold evaluates operations on constants.
+func fold(b binop) (arith, error) {
+	r, ok := b.r.(num)
+	if ok && r == 0 && (b.op == bpf.ALUOpDiv || b.op == bpf.ALUOpMod) {
+		return nil, fmt.Errorf("division by zero")
+	}
+	l, ok2 := b.l.(num)
+	if !ok || !ok2 {
+		return b, nil
+	}
+	switch b.op {
+	case bpf.ALUOpOr:
+		return l | r, nil
+	case bpf.ALUOpXor:
+		return l ^ r, nil
+	case bpf.ALUOpAnd:
+		return l & r, nil
+	case bpf.ALUOpShiftLeft:
+		return l << r, nil
+	case bpf.ALUOpShiftRight:
+		return l >> r, nil
+	case bpf.ALUOpAdd:
+		return l + r, nil
+	case bpf.ALUOpSub:
+		return l - r, nil
+	case bpf.ALUOpMul:
+		return l * r, nil
+	case bpf.ALUOpDiv:
+		return l / r, nil
+	}
+	return l % r, nil
+}
+
+// relation builds the test "l op r", guarded by the checks that the
+// protocols whose headers are loaded are present.
+func relation(l arith, op string, r arith) (node, error) {
+	var checks []node
+	seen := make(map[string]bool)
+	var collect func(a arith)
+	collect = func(a arith) {
+		switch a := a.(type) {
+		case pktLoad:
+			if !seen[a.proto] {
+				seen[a.proto] = true
+				if c := a.link.loadCheck(a.proto); c != nil {
+					checks = append(checks, c)
+				}
+			}
+			collect(a.idx)
+		case binop:
+			collect(a.l)
+			collect(a.r)
+		case negate:
+			collect(a.a)
+		}
+	}
+	collect(l)
+	collect(r)
+
+	var test bpf.JumpTest
+	negated := false
+	switch op {
+	case "=", "==":
+		test = bpf.JumpEqual
+	case "!=":
+		test, negated = bpf.JumpEqual, true
+	case ">":
+		test = bpf.JumpGreaterThan
+	case ">=":
+		test = bpf.JumpGreaterOrEqual
+	case "<":
+		test, negated = bpf.JumpGreaterOrEqual, true
+	case "<=":
+		test, negated = bpf.JumpGreaterThan, true
+	}
+
+	var n node
+	if rv, ok := r.(num); ok {
+		load, err := genArith(l, 0)
+		if err != nil {
+			return nil, err
+		}
+		n = leaf{load: load, test: test, val: uint32(rv)}
+	} else {
+		load, err := genArith(r, 0)
+		if err != nil {
+			return nil, err
+		}
+		load = append(load, bpf.StoreScratch{Src: bpf.RegA, N: 0})
+		ll, err := genArith(l, 1)
+		if err != nil {
+			return nil, err
+		}
+		load = append(append(load, ll...), bpf.LoadScratch{Dst: bpf.RegX, N: 0})
+		n = leaf{load: load, test: test, x: true}
+	}
+	if negated {
+		n = not(n)
+	}
+	return and(append(checks, n)...), nil
+}
+
+// loadCheck returns the test that must pass before loading from the
+// header of proto, or nil if there is none.
+func (l linkInfo) loadCheck(proto string) node {
+	switch proto {
+	case "ip", "ip6", "arp", "rarp":
+		n, _ := l.proto(proto)
+		return n
+	case "tcp", "udp", "sctp", "icmp", "igmp":
+		// only IPv4 transport headers can be addressed
+		return and(l.ipProto(ipProtoNumbers[proto]), l.notFragment())
+	case "icmp6":
+		return and(l.ip6(), eq(ipProtoICMPv6, ldb(l.nl+6)))
+	}
+	return nil
+}
+
+var ipProtoNumbers = map[string]uint32{
+	"tcp": ipProtoTCP, "udp": ipProtoUDP, "sctp": ipProtoSCTP,
+	"icmp": ipProtoICMP, "igmp": ipProtoIGMP,
+}
+
+// genArith returns the instructions computing a in A, using scratch
+// memory from slot s.
+func genArith(a arith, s int) ([]bpf.Instruction, error) {
+	if s >= 16 {
+		return nil, fmt.Errorf("pcapfilter: expression too complex")
+	}
+	switch a := a.(type) {
+	case num:
+		return []bpf.Instruction{bpf.LoadConstant{Dst: bpf.RegA, Val: uint32(a)}}, nil
+	case pktLen:
+		return []bpf.Instruction{bpf.LoadExtension{Num: bpf.ExtLen}}, nil
+	case negate:
+		code, err := genArith(a.a, s)
+		return append(code, bpf.NegateA{}), err
+	case binop:
+		if r, ok := a.r.(num); ok {
+			code, err := genArith(a.l, s)
+			return append(code, bpf.ALUOpConstant{Op: a.op, Val: uint32(r)}), err
+		}
+		code, err := genArith(a.r, s)
+		if err != nil {
+			return nil, err
+		}
+		code = append(code, bpf.StoreScratch{Src: bpf.RegA, N: s})
+		l, err := genArith(a.l, s+1)
+		if err != nil {
+			return nil, err
+		}
+		code = append(code, l...)
+		return append(code, bpf.LoadScratch{Dst: bpf.RegX, N: s}, bpf.ALUOpX{Op: a.op}), nil
+	case pktLoad:
+		return a.gen(s)
+	}
+	return nil, fmt.Errorf("pcapfilter: unknown expression %T", a)
+}
+
+func (a pktLoad) gen(s int) ([]bpf.Instruction, error) {
+	l := a.link
+	var base uint32
+	transport := false
+	switch a.proto {
+	case "ether":
+	case "ip", "ip6", "arp", "rarp":
+		base = l.nl
+	case "icmp6":
+		base = l.nl + 40
+	default:
+		transport = true
+	}
+	if idx, ok := a.idx.(num); ok {
+		if transport {
+			return []bpf.Instruction{
+				bpf.LoadMemShift{Off: l.nl},
+				bpf.LoadIndirect{Off: l.nl + uint32(idx), Size: a.size},
+			}, nil
+		}
+		return []bpf.Instruction{bpf.LoadAbsolute{Off: base + uint32(idx), Size: a.size}}, nil
+	}
+	code, err := genArith(a.idx, s)
+	if err != nil {
+		return nil, err
+	}
+	if transport {
+		code = append(code,
+			bpf.StoreScratch{Src: bpf.RegA, N: s},
+			bpf.LoadMemShift{Off: l.nl},
+			bpf.LoadScratch{Dst: bpf.RegA, N: s},
+			bpf.ALUOpX{Op: bpf.ALUOpAdd})
+		base = l.nl
+	}
+	return append(code, bpf.TAX{}, bpf.LoadIndirect{Off: base, Size: a.size}), nil
+}
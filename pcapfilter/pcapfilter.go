@@ -0,0 +1,97 @@
+// Copyright 2026 The GoPacket Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+// Package pcapfilter compiles pcap-filter expressions, the filter
+// syntax of tcpdump, into BPF programs without libpcap.
+//
+// The programs can be attached to live captures that accept
+// golang.org/x/net/bpf instructions, such as afpacket.TPacket.SetBPF and
+// pcapgo.EthernetHandle.SetBPF, so that statically linked binaries can
+// filter in the kernel:
+//
+//	filter, err := pcapfilter.CompileRaw(layers.LinkTypeEthernet, 65535, "tcp port 80")
+//	if err != nil {
+//	    return err
+//	}
+//	if err := handle.SetBPF(filter); err != nil {
+//	    return err
+//	}
+//
+// Source applies filters to packets read from files with pcapgo, as
+// libpcap does for offline captures.
+//
+// Supported syntax
+//
+// The following primitives are supported, with the proto (ether, ip,
+// ip6, arp, rarp, tcp, udp, sctp, icmp, icmp6, igmp), dir (src, dst,
+// src or dst, src and dst) and type (host, net, port, portrange)
+// qualifiers of pcap-filter(7):
+//
+//	host, net (with /len, "mask" or a truncated address), port, portrange
+//	ether host, ether broadcast, ether multicast, broadcast, multicast
+//	ip multicast, ip6 multicast
+//	ip, ip6, arp, rarp, tcp, udp, sctp, icmp, icmp6, igmp
+//	ether proto, ip proto, ip6 proto, proto
+//	vlan [id], less, greater
+//	proto[expr:size] and len in arithmetic relations (+ - * / % & | ^ << >>)
+//
+// Primitives are combined with and, or and not (&&, || and !) and
+// parentheses; and and or have the same precedence, as in libpcap. Ids
+// without qualifiers inherit those of the previous primitive, so that
+// "tcp port 80 or 443" means "tcp port 80 or tcp port 443".
+//
+// Host names are not resolved, and only well-known service and protocol
+// names are recognized. As with libpcap, "vlan" moves the offsets used
+// by the primitives that follow it by the size of the tag, and
+// transport headers can be addressed in arithmetic expressions for
+// IPv4 only.
+//
+// Supported link types are Ethernet, Linux cooked capture and raw IP.
+package pcapfilter
+
+import (
+	"fmt"
+
+	"github.com/google/gopacket/layers"
+	"golang.org/x/net/bpf"
+)
+
+// Compile compiles the filter expr for packets of linkType. Accepted
+// packets are truncated to snaplen bytes. An empty expression accepts
+// every packet.
+func Compile(linkType layers.LinkType, snaplen int, expr string) ([]bpf.Instruction, error) {
+	if snaplen <= 0 {
+		return nil, fmt.Errorf("pcapfilter: invalid snaplen %d", snaplen)
+	}
+	link, err := newLinkInfo(linkType)
+	if err != nil {
+		return nil, err
+	}
+	toks, err := lex(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks, link: link}
+	var n node = constNode(true)
+	if p.peek().kind != tokEOF {
+		if n, err = p.parseExpr(); err != nil {
+			return nil, err
+		}
+		if t := p.peek(); t.kind != tokEOF {
+			return nil, p.errorf("unexpected %s", t)
+		}
+	}
+	return generate(n, uint32(snaplen))
+}
+
+// CompileRaw is like Compile, returning the assembled program.
+func CompileRaw(linkType layers.LinkType, snaplen int, expr string) ([]bpf.RawInstruction, error) {
+	prog, err := Compile(linkType, snaplen, expr)
+	if err != nil {
+		return nil, err
+	}
+	return bpf.Assemble(prog)
+}
@@ -0,0 +1,600 @@
+// Copyright 2026 The GoPacket Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package pcapfilter
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+	"golang.org/x/net/bpf"
+)
+
+// testPacket is a packet of the corpus the compiled filters are run on.
+type testPacket struct {
+	name string
+	data []byte
+}
+
+var (
+	macA = net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}
+	macB = net.HardwareAddr{0x66, 0x77, 0x88, 0x99, 0xaa, 0xbb}
+	macF = net.HardwareAddr{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+)
+
+func serialize(t testing.TB, ls ...gopacket.SerializableLayer) []byte {
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	if err := gopacket.SerializeLayers(buf, opts, ls...); err != nil {
+		t.Fatal(err)
+	}
+	return append([]byte(nil), buf.Bytes()...)
+}
+
+func eth(t layers.EthernetType) *layers.Ethernet {
+	return &layers.Ethernet{SrcMAC: macA, DstMAC: macB, EthernetType: t}
+}
+
+func ip4(src, dst string, proto layers.IPProtocol) *layers.IPv4 {
+	return &layers.IPv4{Version: 4, IHL: 5, TTL: 64, Protocol: proto,
+		SrcIP: net.ParseIP(src).To4(), DstIP: net.ParseIP(dst).To4()}
+}
+
+func ip6(src, dst string, next layers.IPProtocol) *layers.IPv6 {
+	return &layers.IPv6{Version: 6, HopLimit: 64, NextHeader: next,
+		SrcIP: net.ParseIP(src), DstIP: net.ParseIP(dst)}
+}
+
+func tcp(src, dst layers.TCPPort, syn bool) *layers.TCP {
+	return &layers.TCP{SrcPort: src, DstPort: dst, SYN: syn, ACK: !syn, Window: 1024}
+}
+
+func udp(src, dst layers.UDPPort) *layers.UDP {
+	return &layers.UDP{SrcPort: src, DstPort: dst}
+}
+
+// corpus returns crafted packets covering the primitives, followed by
+// the packets of the Ethernet captures of the repository.
+func corpus(t testing.TB) []testPacket {
+	withTransport := func(ip gopacket.NetworkLayer, l gopacket.SerializableLayer) gopacket.SerializableLayer {
+		switch tl := l.(type) {
+		case *layers.TCP:
+			tl.SetNetworkLayerForChecksum(ip)
+		case *layers.UDP:
+			tl.SetNetworkLayerForChecksum(ip)
+		case *layers.ICMPv6:
+			tl.SetNetworkLayerForChecksum(ip)
+		}
+		return l
+	}
+	var pkts []testPacket
+	add := func(name string, data []byte) {
+		pkts = append(pkts, testPacket{name, data})
+	}
+
+	ip := ip4("10.0.0.1", "1.2.3.4", layers.IPProtocolTCP)
+	add("tcp4 syn to 1.2.3.4:80", serialize(t, eth(layers.EthernetTypeIPv4), ip, withTransport(ip, tcp(1234, 80, true)), gopacket.Payload("GET")))
+	ip = ip4("1.2.3.4", "10.0.0.1", layers.IPProtocolTCP)
+	add("tcp4 ack from 1.2.3.4:80", serialize(t, eth(layers.EthernetTypeIPv4), ip, withTransport(ip, tcp(80, 1234, false))))
+	ip = ip4("192.168.1.10", "10.0.0.2", layers.IPProtocolTCP)
+	add("tcp4 443", serialize(t, eth(layers.EthernetTypeIPv4), ip, withTransport(ip, tcp(50000, 443, true))))
+	ip = ip4("192.168.1.10", "10.0.0.2", layers.IPProtocolTCP)
+	ip.IHL = 6
+	ip.Options = []layers.IPv4Option{{OptionType: 1}, {OptionType: 1}, {OptionType: 1}, {OptionType: 0}}
+	add("tcp4 options port 22", serialize(t, eth(layers.EthernetTypeIPv4), ip, withTransport(ip, tcp(22, 60000, false))))
+	ip = ip4("10.0.0.1", "8.8.8.8", layers.IPProtocolUDP)
+	add("udp4 dns query", serialize(t, eth(layers.EthernetTypeIPv4), ip, withTransport(ip, udp(5353, 53)), gopacket.Payload("query")))
+	ip = ip4("192.168.1.1", "10.0.0.2", layers.IPProtocolUDP)
+	add("udp4 dns answer", serialize(t, eth(layers.EthernetTypeIPv4), ip, withTransport(ip, udp(53, 40000))))
+	ip = ip4("10.0.0.1", "224.0.0.251", layers.IPProtocolUDP)
+	mc := eth(layers.EthernetTypeIPv4)
+	mc.DstMAC = net.HardwareAddr{0x01, 0x00, 0x5e, 0x00, 0x00, 0xfb}
+	add("udp4 multicast", serialize(t, mc, ip, withTransport(ip, udp(5353, 5353))))
+	ip = ip4("10.0.0.1", "1.2.3.4", layers.IPProtocolTCP)
+	ip.FragOffset = 100
+	add("tcp4 fragment", serialize(t, eth(layers.EthernetTypeIPv4), ip, gopacket.Payload{0x00, 0x50, 0x00, 0x50, 0, 0, 0, 0}))
+	ip = ip4("10.0.0.1", "1.2.3.4", layers.IPProtocolICMPv4)
+	add("icmp4 echo", serialize(t, eth(layers.EthernetTypeIPv4), ip,
+		&layers.ICMPv4{TypeCode: layers.CreateICMPv4TypeCode(layers.ICMPv4TypeEchoRequest, 0), Id: 1, Seq: 1}))
+	ip = ip4("1.2.3.4", "10.0.0.1", layers.IPProtocolICMPv4)
+	add("icmp4 reply", serialize(t, eth(layers.EthernetTypeIPv4), ip,
+		&layers.ICMPv4{TypeCode: layers.CreateICMPv4TypeCode(layers.ICMPv4TypeEchoReply, 0), Id: 1, Seq: 1}))
+
+	ip6p := ip6("2001:db8::1", "2001:db8:1::2", layers.IPProtocolTCP)
+	add("tcp6 port 80", serialize(t, eth(layers.EthernetTypeIPv6), ip6p, withTransport(ip6p, tcp(1234, 80, true))))
+	ip6p = ip6("fe80::1", "2001:db8::53", layers.IPProtocolUDP)
+	add("udp6 dns", serialize(t, eth(layers.EthernetTypeIPv6), ip6p, withTransport(ip6p, udp(40000, 53))))
+	ip6p = ip6("::1", "::1", layers.IPProtocolICMPv6)
+	add("icmp6 echo", serialize(t, eth(layers.EthernetTypeIPv6), ip6p,
+		withTransport(ip6p, &layers.ICMPv6{TypeCode: layers.CreateICMPv6TypeCode(layers.ICMPv6TypeEchoRequest, 0)}),
+		&layers.ICMPv6Echo{Identifier: 1, SeqNumber: 1}))
+	ip6p = ip6("2001:db8::1", "ff02::fb", layers.IPProtocolIPv6Fragment)
+	add("tcp6 fragment header", serialize(t, eth(layers.EthernetTypeIPv6), ip6p,
+		gopacket.Payload{6, 0, 0, 0, 0, 0, 0, 1, 0x04, 0xd2, 0x00, 0x50, 0, 0, 0, 0}))
+
+	arpReq := &layers.ARP{AddrType: layers.LinkTypeEthernet, Protocol: layers.EthernetTypeIPv4,
+		HwAddressSize: 6, ProtAddressSize: 4, Operation: layers.ARPRequest,
+		SourceHwAddress: macA, SourceProtAddress: net.IP{1, 2, 3, 4},
+		DstHwAddress: make([]byte, 6), DstProtAddress: net.IP{10, 0, 0, 1}}
+	bcast := eth(layers.EthernetTypeARP)
+	bcast.DstMAC = macF
+	add("arp request", serialize(t, bcast, arpReq))
+	rarp := eth(layers.EthernetType(0x8035))
+	add("rarp", serialize(t, rarp, arpReq))
+
+	ip = ip4("10.0.0.1", "1.2.3.4", layers.IPProtocolTCP)
+	add("vlan 100 tcp4 80", serialize(t, eth(layers.EthernetTypeDot1Q),
+		&layers.Dot1Q{VLANIdentifier: 100, Type: layers.EthernetTypeIPv4}, ip, withTransport(ip, tcp(1234, 80, true))))
+	ip = ip4("10.0.0.1", "8.8.8.8", layers.IPProtocolUDP)
+	add("vlan 200 udp4 53", serialize(t, eth(layers.EthernetTypeDot1Q),
+		&layers.Dot1Q{VLANIdentifier: 200, Type: layers.EthernetTypeIPv4}, ip, withTransport(ip, udp(5353, 53))))
+	add("truncated", []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 0x08, 0x00, 0x45})
+
+	for _, file := range []string{"test_ethernet.pcap", "test_dns.pcap", "test_tls.pcap"} {
+		f, err := os.Open("../pcap/" + file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err := pcapgo.NewReader(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; ; i++ {
+			data, _, err := r.ReadPacketData()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			add(fmt.Sprintf("%s#%d", file, i), data)
+		}
+		f.Close()
+	}
+	return pkts
+}
+
+// parseListing parses the output of "tcpdump -d".
+func parseListing(t *testing.T, listing string) []bpf.Instruction {
+	var prog []bpf.Instruction
+	for _, line := range strings.Split(strings.TrimSpace(listing), "\n") {
+		f := strings.Fields(line)
+		idx, _ := strconv.Atoi(strings.Trim(f[0], "()"))
+		num := func(s string) uint32 {
+			v, err := strconv.ParseUint(strings.TrimPrefix(s, "#"), 0, 32)
+			if err != nil {
+				t.Fatalf("bad number %q in %q", s, line)
+			}
+			return uint32(v)
+		}
+		offset := func(s string) uint32 { return num(strings.Trim(s, "[]")) }
+		skip := func(s string) uint8 { return uint8(num(s) - uint32(idx) - 1) }
+		size := map[string]int{"ldb": 1, "ldh": 2, "ld": 4}
+		switch op := f[1]; op {
+		case "ld", "ldh", "ldb":
+			switch {
+			case f[2] == "#pktlen":
+				prog = append(prog, bpf.LoadExtension{Num: bpf.ExtLen})
+			case f[2] == "[x":
+				prog = append(prog, bpf.LoadIndirect{Off: offset(f[4]), Size: size[op]})
+			default:
+				prog = append(prog, bpf.LoadAbsolute{Off: offset(f[2]), Size: size[op]})
+			}
+		case "ldxb":
+			prog = append(prog, bpf.LoadMemShift{Off: num(strings.TrimSuffix(strings.TrimPrefix(f[2], "4*(["), "]&0xf)"))})
+		case "and":
+			prog = append(prog, bpf.ALUOpConstant{Op: bpf.ALUOpAnd, Val: num(f[2])})
+		case "jeq", "jgt", "jge", "jset":
+			cond := map[string]bpf.JumpTest{"jeq": bpf.JumpEqual, "jgt": bpf.JumpGreaterThan,
+				"jge": bpf.JumpGreaterOrEqual, "jset": bpf.JumpBitsSet}[op]
+			prog = append(prog, bpf.JumpIf{Cond: cond, Val: num(f[2]), SkipTrue: skip(f[4]), SkipFalse: skip(f[6])})
+		case "ret":
+			prog = append(prog, bpf.RetConstant{Val: num(f[2])})
+		default:
+			t.Fatalf("unknown instruction in %q", line)
+		}
+	}
+	return prog
+}
+
+// Programs generated by libpcap for Ethernet with a 262144 bytes
+// snaplen, as printed by tcpdump -d.
+var referencePrograms = []struct {
+	expr, listing string
+}{
+	{"ip", `
+(000) ldh      [12]
+(001) jeq      #0x800           jt 2	jf 3
+(002) ret      #262144
+(003) ret      #0`},
+	{"arp", `
+(000) ldh      [12]
+(001) jeq      #0x806           jt 2	jf 3
+(002) ret      #262144
+(003) ret      #0`},
+	{"tcp", `
+(000) ldh      [12]
+(001) jeq      #0x86dd          jt 2	jf 7
+(002) ldb      [20]
+(003) jeq      #0x6             jt 10	jf 4
+(004) jeq      #0x2c            jt 5	jf 11
+(005) ldb      [54]
+(006) jeq      #0x6             jt 10	jf 11
+(007) jeq      #0x800           jt 8	jf 11
+(008) ldb      [23]
+(009) jeq      #0x6             jt 10	jf 11
+(010) ret      #262144
+(011) ret      #0`},
+	{"icmp", `
+(000) ldh      [12]
+(001) jeq      #0x800           jt 2	jf 5
+(002) ldb      [23]
+(003) jeq      #0x1             jt 4	jf 5
+(004) ret      #262144
+(005) ret      #0`},
+	{"port 80", `
+(000) ldh      [12]
+(001) jeq      #0x86dd          jt 2	jf 10
+(002) ldb      [20]
+(003) jeq      #0x84            jt 6	jf 4
+(004) jeq      #0x6             jt 6	jf 5
+(005) jeq      #0x11            jt 6	jf 23
+(006) ldh      [54]
+(007) jeq      #0x50            jt 22	jf 8
+(008) ldh      [56]
+(009) jeq      #0x50            jt 22	jf 23
+(010) jeq      #0x800           jt 11	jf 23
+(011) ldb      [23]
+(012) jeq      #0x84            jt 15	jf 13
+(013) jeq      #0x6             jt 15	jf 14
+(014) jeq      #0x11            jt 15	jf 23
+(015) ldh      [20]
+(016) jset     #0x1fff          jt 23	jf 17
+(017) ldxb     4*([14]&0xf)
+(018) ldh      [x + 14]
+(019) jeq      #0x50            jt 22	jf 20
+(020) ldh      [x + 16]
+(021) jeq      #0x50            jt 22	jf 23
+(022) ret      #262144
+(023) ret      #0`},
+	{"tcp port 80", `
+(000) ldh      [12]
+(001) jeq      #0x86dd          jt 2	jf 8
+(002) ldb      [20]
+(003) jeq      #0x6             jt 4	jf 19
+(004) ldh      [54]
+(005) jeq      #0x50            jt 18	jf 6
+(006) ldh      [56]
+(007) jeq      #0x50            jt 18	jf 19
+(008) jeq      #0x800           jt 9	jf 19
+(009) ldb      [23]
+(010) jeq      #0x6             jt 11	jf 19
+(011) ldh      [20]
+(012) jset     #0x1fff          jt 19	jf 13
+(013) ldxb     4*([14]&0xf)
+(014) ldh      [x + 14]
+(015) jeq      #0x50            jt 18	jf 16
+(016) ldh      [x + 16]
+(017) jeq      #0x50            jt 18	jf 19
+(018) ret      #262144
+(019) ret      #0`},
+	{"udp dst port 53", `
+(000) ldh      [12]
+(001) jeq      #0x86dd          jt 2	jf 6
+(002) ldb      [20]
+(003) jeq      #0x11            jt 4	jf 15
+(004) ldh      [56]
+(005) jeq      #0x35            jt 14	jf 15
+(006) jeq      #0x800           jt 7	jf 15
+(007) ldb      [23]
+(008) jeq      #0x11            jt 9	jf 15
+(009) ldh      [20]
+(010) jset     #0x1fff          jt 15	jf 11
+(011) ldxb     4*([14]&0xf)
+(012) ldh      [x + 16]
+(013) jeq      #0x35            jt 14	jf 15
+(014) ret      #262144
+(015) ret      #0`},
+	{"host 1.2.3.4", `
+(000) ldh      [12]
+(001) jeq      #0x800           jt 2	jf 6
+(002) ld       [26]
+(003) jeq      #0x1020304       jt 12	jf 4
+(004) ld       [30]
+(005) jeq      #0x1020304       jt 12	jf 13
+(006) jeq      #0x806           jt 8	jf 7
+(007) jeq      #0x8035          jt 8	jf 13
+(008) ld       [28]
+(009) jeq      #0x1020304       jt 12	jf 10
+(010) ld       [38]
+(011) jeq      #0x1020304       jt 12	jf 13
+(012) ret      #262144
+(013) ret      #0`},
+	{"net 192.168.0.0/16", `
+(000) ldh      [12]
+(001) jeq      #0x800           jt 2	jf 8
+(002) ld       [26]
+(003) and      #0xffff0000
+(004) jeq      #0xc0a80000      jt 16	jf 5
+(005) ld       [30]
+(006) and      #0xffff0000
+(007) jeq      #0xc0a80000      jt 16	jf 17
+(008) jeq      #0x806           jt 10	jf 9
+(009) jeq      #0x8035          jt 10	jf 17
+(010) ld       [28]
+(011) and      #0xffff0000
+(012) jeq      #0xc0a80000      jt 16	jf 13
+(013) ld       [38]
+(014) and      #0xffff0000
+(015) jeq      #0xc0a80000      jt 16	jf 17
+(016) ret      #262144
+(017) ret      #0`},
+	{"ether host 00:11:22:33:44:55", `
+(000) ld       [8]
+(001) jeq      #0x22334455      jt 2	jf 4
+(002) ldh      [6]
+(003) jeq      #0x11            jt 8	jf 4
+(004) ld       [2]
+(005) jeq      #0x22334455      jt 6	jf 9
+(006) ldh      [0]
+(007) jeq      #0x11            jt 8	jf 9
+(008) ret      #262144
+(009) ret      #0`},
+	{"tcp[tcpflags] & tcp-syn != 0", `
+(000) ldh      [12]
+(001) jeq      #0x800           jt 2	jf 10
+(002) ldb      [23]
+(003) jeq      #0x6             jt 4	jf 10
+(004) ldh      [20]
+(005) jset     #0x1fff          jt 10	jf 6
+(006) ldxb     4*([14]&0xf)
+(007) ldb      [x + 27]
+(008) jset     #0x2             jt 9	jf 10
+(009) ret      #262144
+(010) ret      #0`},
+	{"vlan 100", `
+(000) ldh      [12]
+(001) jeq      #0x8100          jt 4	jf 2
+(002) jeq      #0x88a8          jt 4	jf 3
+(003) jeq      #0x9100          jt 4	jf 8
+(004) ldh      [14]
+(005) and      #0xfff
+(006) jeq      #0x64            jt 7	jf 8
+(007) ret      #262144
+(008) ret      #0`},
+	{"less 100", `
+(000) ld       #pktlen
+(001) jgt      #0x64            jt 2	jf 3
+(002) ret      #0
+(003) ret      #262144`},
+}
+
+func run(t *testing.T, prog []bpf.Instruction, data []byte) bool {
+	vm, err := bpf.NewVM(prog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := vm.Run(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n != 0
+}
+
+func TestCompileMatchesReference(t *testing.T) {
+	pkts := corpus(t)
+	for _, ref := range referencePrograms {
+		want := parseListing(t, ref.listing)
+		got, err := Compile(layers.LinkTypeEthernet, 262144, ref.expr)
+		if err != nil {
+			t.Errorf("%q: %v", ref.expr, err)
+			continue
+		}
+		if len(got) > len(want)+4 {
+			t.Errorf("%q: program of %d instructions, libpcap has %d", ref.expr, len(got), len(want))
+		}
+		matched := 0
+		for _, p := range pkts {
+			w, g := run(t, want, p.data), run(t, got, p.data)
+			if w != g {
+				t.Errorf("%q: %s: got %v, want %v", ref.expr, p.name, g, w)
+			}
+			if w {
+				matched++
+			}
+		}
+		if matched == 0 || matched == len(pkts) {
+			t.Errorf("%q: the corpus does not exercise the filter (%d matches)", ref.expr, matched)
+		}
+	}
+}
+
+func TestCompile(t *testing.T) {
+	pkts := corpus(t)
+	for _, test := range []struct {
+		expr  string
+		match []string // names of the crafted packets matched
+	}{
+		{"src host 10.0.0.1", []string{"tcp4 syn to 1.2.3.4:80", "udp4 dns query", "udp4 multicast", "tcp4 fragment", "icmp4 echo"}},
+		{"host 1.2.3.4 and (port 80 or port 443)", []string{"tcp4 syn to 1.2.3.4:80", "tcp4 ack from 1.2.3.4:80"}},
+		{"tcp port 80 or 443", []string{"tcp4 syn to 1.2.3.4:80", "tcp4 ack from 1.2.3.4:80", "tcp4 443", "tcp6 port 80"}},
+		{"tcp port http or https", []string{"tcp4 syn to 1.2.3.4:80", "tcp4 ack from 1.2.3.4:80", "tcp4 443", "tcp6 port 80"}},
+		{"port 22", []string{"tcp4 options port 22"}},
+		{"src port 53", []string{"udp4 dns answer"}},
+		{"src or dst port domain", []string{"udp4 dns query", "udp4 dns answer", "udp6 dns"}},
+		{"portrange 50-443 and ip6", []string{"tcp6 port 80", "udp6 dns"}},
+		{"udp", []string{"udp4 dns query", "udp4 dns answer", "udp4 multicast", "udp6 dns"}},
+		{"ip proto \\udp or ip proto 1", []string{"udp4 dns query", "udp4 dns answer", "udp4 multicast", "icmp4 echo", "icmp4 reply"}},
+		{"ip6 proto tcp", []string{"tcp6 port 80", "tcp6 fragment header"}},
+		{"icmp6", []string{"icmp6 echo"}},
+		{"icmp[icmptype] == icmp-echo", []string{"icmp4 echo"}},
+		{"icmp[icmptype] != icmp-echo", []string{"icmp4 reply"}},
+		{"ip6 net 2001:db8::/32", []string{"tcp6 port 80", "udp6 dns", "tcp6 fragment header"}},
+		{"dst net 2001:db8:1::/48", []string{"tcp6 port 80"}},
+		{"ip6 host ::1", []string{"icmp6 echo"}},
+		{"net 192.168", []string{"tcp4 443", "tcp4 options port 22", "udp4 dns answer"}},
+		{"src net 192.168.0.0 mask 255.255.0.0", []string{"tcp4 443", "tcp4 options port 22", "udp4 dns answer"}},
+		{"arp host 10.0.0.1", []string{"arp request"}},
+		{"rarp", []string{"rarp"}},
+		{"ether broadcast", []string{"arp request"}},
+		{"ether dst ff:ff:ff:ff:ff:ff", []string{"arp request"}},
+		{"ip multicast", []string{"udp4 multicast"}},
+		{"ip6 multicast", []string{"tcp6 fragment header"}},
+		{"ether proto 0x8035", []string{"rarp"}},
+		{"vlan 100 and tcp port 80", []string{"vlan 100 tcp4 80"}},
+		{"vlan and udp port 53", []string{"vlan 200 udp4 53"}},
+		{"vlan 200 and host 8.8.8.8", []string{"vlan 200 udp4 53"}},
+		{"tcp[tcpflags] & (tcp-syn|tcp-ack) == tcp-syn", []string{"tcp4 syn to 1.2.3.4:80", "tcp4 443"}},
+		{"tcp[13] & 0x10 != 0 && tcp[0:2] > 79", []string{"tcp4 ack from 1.2.3.4:80"}},
+		{"ip[(ip[0] & 0xf) * 4 - 4] = 1", []string{"tcp4 syn to 1.2.3.4:80", "tcp4 options port 22", "tcp4 fragment", "icmp4 echo"}},
+		{"ip and tcp[2:2] == tcp[0:2] * 0 + 80 and not vlan", []string{"tcp4 syn to 1.2.3.4:80"}},
+		{"udp[8:4] = 0x71756572", []string{"udp4 dns query"}},
+		{"ip[2:2] + 14 > len", []string{}},
+		{"greater 100 and icmp", []string{}},
+		{"less 60 and icmp", []string{"icmp4 echo", "icmp4 reply"}},
+		{"ip and not tcp and not udp", []string{"icmp4 echo", "icmp4 reply"}},
+		{"! arp && ! ip && ! ip6 && ! rarp", []string{"vlan 100 tcp4 80", "vlan 200 udp4 53"}},
+		{"tcp or udp and port 80", []string{"tcp4 syn to 1.2.3.4:80", "tcp4 ack from 1.2.3.4:80", "tcp6 port 80"}},
+	} {
+		prog, err := Compile(layers.LinkTypeEthernet, 65535, test.expr)
+		if err != nil {
+			t.Errorf("%q: %v", test.expr, err)
+			continue
+		}
+		want := make(map[string]bool)
+		for _, name := range test.match {
+			want[name] = true
+		}
+		for _, p := range pkts {
+			if strings.Contains(p.name, ".pcap#") {
+				continue
+			}
+			if got := run(t, prog, p.data); got != want[p.name] {
+				t.Errorf("%q: %s: got %v, want %v", test.expr, p.name, got, want[p.name])
+			}
+		}
+	}
+}
+
+func TestCompileRawLink(t *testing.T) {
+	ip := ip4("10.0.0.1", "1.2.3.4", layers.IPProtocolTCP)
+	tl := tcp(1234, 80, true)
+	tl.SetNetworkLayerForChecksum(ip)
+	v4 := serialize(t, ip, tl)
+	ip6p := ip6("2001:db8::1", "2001:db8::2", layers.IPProtocolUDP)
+	ul := udp(1234, 53)
+	ul.SetNetworkLayerForChecksum(ip6p)
+	v6 := serialize(t, ip6p, ul)
+
+	for _, test := range []struct {
+		expr   string
+		v4, v6 bool
+	}{
+		{"ip", true, false},
+		{"ip6", false, true},
+		{"tcp dst port 80", true, false},
+		{"port 53", false, true},
+		{"host 1.2.3.4", true, false},
+		{"arp", false, false},
+		{"ip[9] = 6", true, false},
+	} {
+		prog, err := Compile(layers.LinkTypeRaw, 65535, test.expr)
+		if err != nil {
+			t.Errorf("%q: %v", test.expr, err)
+			continue
+		}
+		if got := run(t, prog, v4); got != test.v4 {
+			t.Errorf("%q: IPv4 packet: got %v, want %v", test.expr, got, test.v4)
+		}
+		if got := run(t, prog, v6); got != test.v6 {
+			t.Errorf("%q: IPv6 packet: got %v, want %v", test.expr, got, test.v6)
+		}
+	}
+	if _, err := Compile(layers.LinkTypeRaw, 65535, "ether host 00:11:22:33:44:55"); err == nil {
+		t.Error("ether host accepted on a raw link")
+	}
+}
+
+func TestCompileLongJumps(t *testing.T) {
+	// each port adds a dozen instructions, more than a conditional jump
+	// can skip
+	var ports []string
+	for p := 1000; p < 1100; p++ {
+		ports = append(ports, fmt.Sprintf("port %d", p))
+	}
+	expr := "tcp and (" + strings.Join(ports, " or ") + ") and not vlan"
+	prog, err := Compile(layers.LinkTypeEthernet, 65535, expr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prog) < 512 {
+		t.Fatalf("program of %d instructions is too short to test long jumps", len(prog))
+	}
+	ip := ip4("10.0.0.1", "1.2.3.4", layers.IPProtocolTCP)
+	for _, port := range []layers.TCPPort{999, 1000, 1050, 1099, 1100} {
+		tl := tcp(40000, port, true)
+		tl.SetNetworkLayerForChecksum(ip)
+		want := port >= 1000 && port < 1100
+		if got := run(t, prog, serialize(t, eth(layers.EthernetTypeIPv4), ip, tl)); got != want {
+			t.Errorf("port %d: got %v, want %v", port, got, want)
+		}
+	}
+	if _, err := bpf.Assemble(prog); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCompileEmpty(t *testing.T) {
+	prog, err := CompileRaw(layers.LinkTypeEthernet, 1500, " ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (bpf.RetConstant{Val: 1500}); len(prog) != 1 || prog[0].Disassemble() != want {
+		t.Errorf("got %v, want %v", prog, want)
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	for _, expr := range []string{
+		"host",
+		"host foo.example.com",
+		"tcp and",
+		"port 70000",
+		"portrange 1-x",
+		"(tcp",
+		"tcp)",
+		"tcp 80",
+		"ip host ::1",
+		"ip6 host 1.2.3.4",
+		"net 10.0.0.1/8",
+		"tcp[13] & 2 !=",
+		"tcp[1:3] = 0",
+		"ip[0] / 0 = 1",
+		"len > ip",
+		"ether",
+		"vlan 5000",
+		"udp port $",
+		"ip broadcast",
+		"gateway 1.2.3.4",
+	} {
+		if _, err := Compile(layers.LinkTypeEthernet, 65535, expr); err == nil {
+			t.Errorf("%q: no error", expr)
+		}
+	}
+	if _, err := Compile(layers.LinkTypeEthernet, 0, "ip"); err == nil {
+		t.Error("no error for a null snaplen")
+	}
+	if _, err := Compile(layers.LinkTypeFDDI, 65535, "ip"); err == nil {
+		t.Error("no error for an unsupported link type")
+	}
+}
@@ -0,0 +1,338 @@
+// Copyright 2026 The GoPacket Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package pcapfilter
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+
+	"github.com/google/gopacket/layers"
+	"golang.org/x/net/bpf"
+)
+
+// linkInfo describes where the headers tested by a filter are found in
+// packets of a link type. The offsets move when "vlan" is used.
+type linkInfo struct {
+	linkType int    // offset of the ethertype, -1 if the link has none
+	nl       uint32 // offset of the network layer header
+	ether    bool   // Ethernet addresses are available
+}
+
+func newLinkInfo(lt layers.LinkType) (linkInfo, error) {
+	switch lt {
+	case layers.LinkTypeEthernet:
+		return linkInfo{linkType: 12, nl: 14, ether: true}, nil
+	case layers.LinkTypeLinuxSLL:
+		return linkInfo{linkType: 14, nl: 16}, nil
+	case layers.LinkTypeRaw, layers.LinkTypeIPv4, layers.LinkTypeIPv6:
+		return linkInfo{linkType: -1}, nil
+	}
+	return linkInfo{}, fmt.Errorf("pcapfilter: unsupported link type %v", lt)
+}
+
+const (
+	etherTypeIPv4 = 0x0800
+	etherTypeARP  = 0x0806
+	etherTypeRARP = 0x8035
+	etherTypeIPv6 = 0x86dd
+
+	ipProtoICMP     = 1
+	ipProtoIGMP     = 2
+	ipProtoTCP      = 6
+	ipProtoUDP      = 17
+	ipProtoFragment = 44
+	ipProtoICMPv6   = 58
+	ipProtoSCTP     = 132
+)
+
+func ldb(off uint32) bpf.Instruction { return bpf.LoadAbsolute{Off: off, Size: 1} }
+func ldh(off uint32) bpf.Instruction { return bpf.LoadAbsolute{Off: off, Size: 2} }
+func ld(off uint32) bpf.Instruction  { return bpf.LoadAbsolute{Off: off, Size: 4} }
+
+// ethertype tests the network layer protocol. Links without an
+// ethertype only carry IP, whose version is found in the first nibble.
+func (l linkInfo) ethertype(t uint32) node {
+	if l.linkType < 0 {
+		switch t {
+		case etherTypeIPv4:
+			return eq(0x40, ldb(0), bpf.ALUOpConstant{Op: bpf.ALUOpAnd, Val: 0xf0})
+		case etherTypeIPv6:
+			return eq(0x60, ldb(0), bpf.ALUOpConstant{Op: bpf.ALUOpAnd, Val: 0xf0})
+		}
+		return constNode(false)
+	}
+	return eq(t, ldh(uint32(l.linkType)))
+}
+
+func (l linkInfo) ip() node  { return l.ethertype(etherTypeIPv4) }
+func (l linkInfo) ip6() node { return l.ethertype(etherTypeIPv6) }
+
+// ipProto tests the protocol of an IPv4 packet.
+func (l linkInfo) ipProto(p uint32) node {
+	return and(l.ip(), eq(p, ldb(l.nl+9)))
+}
+
+// ip6Proto tests the next header of an IPv6 packet, looking past a
+// fragment header if there is one.
+func (l linkInfo) ip6Proto(p uint32) node {
+	return and(l.ip6(), or(
+		eq(p, ldb(l.nl+6)),
+		and(eq(ipProtoFragment, ldb(l.nl+6)), eq(p, ldb(l.nl+40)))))
+}
+
+// notFragment is true for IPv4 packets with a null fragment offset,
+// the only ones carrying transport headers.
+func (l linkInfo) notFragment() node {
+	return not(cmp(bpf.JumpBitsSet, 0x1fff, ldh(l.nl+6)))
+}
+
+// transport tests for a transport protocol over IPv4 or IPv6.
+func (l linkInfo) transport(p uint32) node {
+	return or(l.ipProto(p), l.ip6Proto(p))
+}
+
+// proto returns the node testing for a protocol keyword.
+func (l linkInfo) proto(name string) (node, bool) {
+	switch name {
+	case "ip":
+		return l.ip(), true
+	case "ip6":
+		return l.ip6(), true
+	case "arp":
+		return l.ethertype(etherTypeARP), true
+	case "rarp":
+		return l.ethertype(etherTypeRARP), true
+	case "tcp":
+		return l.transport(ipProtoTCP), true
+	case "udp":
+		return l.transport(ipProtoUDP), true
+	case "sctp":
+		return l.transport(ipProtoSCTP), true
+	case "icmp":
+		return l.ipProto(ipProtoICMP), true
+	case "igmp":
+		return l.ipProto(ipProtoIGMP), true
+	case "icmp6":
+		return l.ip6Proto(ipProtoICMPv6), true
+	}
+	return nil, false
+}
+
+// direction is the dir qualifier of a primitive.
+type direction int
+
+const (
+	dirSrcOrDst direction = iota
+	dirSrc
+	dirDst
+	dirSrcAndDst
+)
+
+func (d direction) apply(src, dst node) node {
+	switch d {
+	case dirSrc:
+		return src
+	case dirDst:
+		return dst
+	case dirSrcAndDst:
+		return and(src, dst)
+	}
+	return or(src, dst)
+}
+
+// net4 tests IPv4 addresses of IP, ARP and RARP packets against addr
+// under mask.
+func (l linkInfo) net4(proto string, d direction, addr, mask uint32) (node, error) {
+	test := func(off uint32) node {
+		load := []bpf.Instruction{ld(off)}
+		if mask != 0xffffffff {
+			load = append(load, bpf.ALUOpConstant{Op: bpf.ALUOpAnd, Val: mask})
+		}
+		return leaf{load: load, test: bpf.JumpEqual, val: addr & mask}
+	}
+	arp := func(types ...node) node {
+		return and(or(types...), d.apply(test(l.nl+14), test(l.nl+24)))
+	}
+	ip := and(l.ip(), d.apply(test(l.nl+12), test(l.nl+16)))
+	switch proto {
+	case "":
+		// ARP and RARP share their address offsets, test them once
+		return or(ip, arp(l.ethertype(etherTypeARP), l.ethertype(etherTypeRARP))), nil
+	case "ip":
+		return ip, nil
+	case "arp":
+		return arp(l.ethertype(etherTypeARP)), nil
+	case "rarp":
+		return arp(l.ethertype(etherTypeRARP)), nil
+	}
+	return nil, fmt.Errorf("pcapfilter: '%s' is not valid with an IPv4 address", proto)
+}
+
+// net6 tests IPv6 addresses against the first bits of addr.
+func (l linkInfo) net6(proto string, d direction, addr net.IP, bits int) (node, error) {
+	if proto != "" && proto != "ip6" {
+		return nil, fmt.Errorf("pcapfilter: '%s' is not valid with an IPv6 address", proto)
+	}
+	mask := net.CIDRMask(bits, 128)
+	test := func(off uint32) node {
+		var words []node
+		for i := 0; i < 4; i++ {
+			m := binary.BigEndian.Uint32(mask[4*i:])
+			if m == 0 {
+				continue
+			}
+			load := []bpf.Instruction{ld(off + uint32(4*i))}
+			if m != 0xffffffff {
+				load = append(load, bpf.ALUOpConstant{Op: bpf.ALUOpAnd, Val: m})
+			}
+			words = append(words, leaf{load: load, test: bpf.JumpEqual, val: binary.BigEndian.Uint32(addr[4*i:]) & m})
+		}
+		return and(words...)
+	}
+	return and(l.ip6(), d.apply(test(l.nl+8), test(l.nl+24))), nil
+}
+
+// etherHost tests the Ethernet addresses.
+func (l linkInfo) etherHost(d direction, mac net.HardwareAddr) (node, error) {
+	if !l.ether {
+		return nil, fmt.Errorf("pcapfilter: ethernet addresses are not supported on this link type")
+	}
+	hi, lo := uint32(binary.BigEndian.Uint16(mac)), binary.BigEndian.Uint32(mac[2:])
+	src := and(eq(lo, ld(8)), eq(hi, ldh(6)))
+	dst := and(eq(lo, ld(2)), eq(hi, ldh(0)))
+	return d.apply(src, dst), nil
+}
+
+// port tests the ports of TCP, UDP and SCTP packets against the range
+// [lo, hi].
+func (l linkInfo) port(proto string, d direction, lo, hi uint32) (node, error) {
+	var protos []uint32
+	switch proto {
+	case "":
+		protos = []uint32{ipProtoTCP, ipProtoUDP, ipProtoSCTP}
+	case "tcp":
+		protos = []uint32{ipProtoTCP}
+	case "udp":
+		protos = []uint32{ipProtoUDP}
+	case "sctp":
+		protos = []uint32{ipProtoSCTP}
+	default:
+		return nil, fmt.Errorf("pcapfilter: '%s' is not valid with port", proto)
+	}
+	oneOf := func(off uint32) node {
+		var ns []node
+		for _, p := range protos {
+			ns = append(ns, eq(p, ldb(off)))
+		}
+		return or(ns...)
+	}
+	test := func(load ...bpf.Instruction) node {
+		if lo == hi {
+			return eq(lo, load...)
+		}
+		return and(cmp(bpf.JumpGreaterOrEqual, lo, load...), not(cmp(bpf.JumpGreaterThan, hi, load...)))
+	}
+	ip := and(l.ip(), oneOf(l.nl+9), l.notFragment(), d.apply(
+		test(bpf.LoadMemShift{Off: l.nl}, bpf.LoadIndirect{Off: l.nl, Size: 2}),
+		test(bpf.LoadMemShift{Off: l.nl}, bpf.LoadIndirect{Off: l.nl + 2, Size: 2})))
+	ip6 := and(l.ip6(), oneOf(l.nl+6), d.apply(test(ldh(l.nl+40)), test(ldh(l.nl+42))))
+	return or(ip, ip6), nil
+}
+
+// vlan tests for an 802.1Q or 802.1ad tag, with the given VLAN
+// identifier if id is not negative.
+func (l linkInfo) vlan(id int) (node, error) {
+	if !l.ether {
+		return nil, fmt.Errorf("pcapfilter: vlan is not supported on this link type")
+	}
+	off := uint32(l.linkType)
+	tag := or(eq(0x8100, ldh(off)), eq(0x88a8, ldh(off)), eq(0x9100, ldh(off)))
+	if id < 0 {
+		return tag, nil
+	}
+	return and(tag, eq(uint32(id), ldh(off+2), bpf.ALUOpConstant{Op: bpf.ALUOpAnd, Val: 0x0fff})), nil
+}
+
+func (l linkInfo) etherBroadcast() (node, error) {
+	if !l.ether {
+		return nil, fmt.Errorf("pcapfilter: ethernet addresses are not supported on this link type")
+	}
+	return and(eq(0xffffffff, ld(2)), eq(0xffff, ldh(0))), nil
+}
+
+func (l linkInfo) etherMulticast() (node, error) {
+	if !l.ether {
+		return nil, fmt.Errorf("pcapfilter: ethernet addresses are not supported on this link type")
+	}
+	return cmp(bpf.JumpBitsSet, 1, ldb(0)), nil
+}
+
+func (l linkInfo) ipMulticast() node {
+	return and(l.ip(), cmp(bpf.JumpGreaterOrEqual, 224, ldb(l.nl+16)))
+}
+
+func (l linkInfo) ip6Multicast() node {
+	return and(l.ip6(), eq(0xff, ldb(l.nl+24)))
+}
+
+// lengthTest implements "less" and "greater".
+func lengthTest(greater bool, n uint32) node {
+	length := bpf.LoadExtension{Num: bpf.ExtLen}
+	if greater {
+		return cmp(bpf.JumpGreaterOrEqual, n, length)
+	}
+	return not(cmp(bpf.JumpGreaterThan, n, length))
+}
+
+// Names accepted in place of numbers, as found in /etc/services,
+// /etc/protocols and the pcap-filter manual page.
+var (
+	serviceNames = map[string]uint32{
+		"ftp-data": 20, "ftp": 21, "ssh": 22, "telnet": 23, "smtp": 25,
+		"domain": 53, "bootps": 67, "bootpc": 68, "tftp": 69, "http": 80,
+		"www": 80, "kerberos": 88, "pop3": 110, "sunrpc": 111, "ntp": 123,
+		"netbios-ns": 137, "netbios-dgm": 138, "netbios-ssn": 139,
+		"imap": 143, "snmp": 161, "snmp-trap": 162, "bgp": 179,
+		"ldap": 389, "https": 443, "microsoft-ds": 445, "syslog": 514,
+		"ldaps": 636, "imaps": 993, "pop3s": 995, "openvpn": 1194,
+		"radius": 1812, "radius-acct": 1813, "mysql": 3306, "rdp": 3389,
+		"sip": 5060, "postgresql": 5432, "http-alt": 8080,
+	}
+	ipProtoNames = map[string]uint32{
+		"icmp": ipProtoICMP, "igmp": ipProtoIGMP, "tcp": ipProtoTCP,
+		"udp": ipProtoUDP, "gre": 47, "esp": 50, "ah": 51,
+		"icmp6": ipProtoICMPv6, "ospf": 89, "pim": 103, "vrrp": 112,
+		"sctp": ipProtoSCTP,
+	}
+	etherProtoNames = map[string]uint32{
+		"ip": etherTypeIPv4, "ip6": etherTypeIPv6, "arp": etherTypeARP,
+		"rarp": etherTypeRARP, "atalk": 0x809b, "aarp": 0x80f3,
+		"decnet": 0x6003, "lat": 0x6004, "sca": 0x6007, "moprc": 0x6002,
+		"mopdl": 0x6001, "ipx": 0x8137, "netbeui": 0xf0f0,
+	}
+	// constants usable in arithmetic expressions
+	namedConstants = map[string]uint32{
+		"icmptype": 0, "icmpcode": 1,
+		"icmp-echoreply": 0, "icmp-unreach": 3, "icmp-sourcequench": 4,
+		"icmp-redirect": 5, "icmp-echo": 8, "icmp-routeradvert": 9,
+		"icmp-routersolicit": 10, "icmp-timxceed": 11, "icmp-paramprob": 12,
+		"icmp-tstamp": 13, "icmp-tstampreply": 14, "icmp-ireq": 15,
+		"icmp-ireqreply": 16, "icmp-maskreq": 17, "icmp-maskreply": 18,
+		"icmp6type": 0, "icmp6code": 1,
+		"icmp6-destinationunreach": 1, "icmp6-packettoobig": 2,
+		"icmp6-timeexceeded": 3, "icmp6-parameterproblem": 4,
+		"icmp6-echo": 128, "icmp6-echoreply": 129,
+		"icmp6-routersolicit": 133, "icmp6-routeradvert": 134,
+		"icmp6-neighborsolicit": 135, "icmp6-neighboradvert": 136,
+		"icmp6-redirect": 137,
+
+		"tcpflags": 13,
+		"tcp-fin":  0x01, "tcp-syn": 0x02, "tcp-rst": 0x04, "tcp-push": 0x08,
+		"tcp-ack": 0x10, "tcp-urg": 0x20, "tcp-ece": 0x40, "tcp-cwr": 0x80,
+	}
+)
@@ -0,0 +1,213 @@
+// Copyright 2026 The GoPacket Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package pcapfilter
+
+import (
+	"errors"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+	"golang.org/x/net/bpf"
+)
+
+// maxSnaplen is the snapshot length filters are compiled with for
+// Source, which only distinguishes accepted packets from rejected ones.
+const maxSnaplen = 262144
+
+// Source is a gopacket.PacketDataSource returning the packets of an
+// underlying source accepted by a BPF program, like libpcap does for
+// offline captures with pcap_setfilter. It allows filtering files read
+// with pcapgo without cgo:
+//
+//	r, err := pcapgo.NewReader(f)
+//	...
+//	src, err := pcapfilter.NewSourceFilter(r, "tcp port 80")
+//	...
+//	packets := gopacket.NewPacketSource(src, src.LinkType())
+//
+// Programs run in the golang.org/x/net/bpf virtual machine on the
+// captured bytes, so the packet length seen by the program ("len") is
+// the capture length, not the length on the wire.
+//
+// The link type of the packets is taken from the underlying source
+// when it reports one (pcapgo.Reader, pcapgo.NgReader and
+// pcapgo.SnoopReader do). For pcapgo.NgReader, the link type of the
+// interface each packet was captured on is used.
+type Source struct {
+	src      gopacket.PacketDataSource
+	linkType layers.LinkType
+	known    bool
+	ng       *pcapgo.NgReader
+	// compile returns the program for another link type than linkType,
+	// nil if the programs are link type specific
+	compile func(layers.LinkType) ([]bpf.Instruction, error)
+	vms     map[layers.LinkType]*bpf.VM
+	// unbound is the program given to NewSource for a pcapng file whose
+	// first interface was not read yet
+	unbound *bpf.VM
+	// errs holds the errors of compiling the filter per link type
+	errs map[layers.LinkType]error
+}
+
+// NewSource returns a Source running filter on the packets of src.
+// filter must have been compiled for the link type of src; packets of
+// pcapng interfaces with other link types are rejected. If src does
+// not report a link type, filter is run on every packet.
+func NewSource(src gopacket.PacketDataSource, filter []bpf.Instruction) (*Source, error) {
+	vm, err := bpf.NewVM(filter)
+	if err != nil {
+		return nil, err
+	}
+	s := newSource(src)
+	if s.ng != nil && s.ng.NInterfaces() == 0 {
+		s.unbound = vm
+	} else {
+		s.vms[s.linkType] = vm
+	}
+	return s, nil
+}
+
+// NewSourceFilter returns a Source keeping the packets of src matching
+// the pcap-filter expression expr. expr is compiled for every link type
+// found in src; it is an error if src does not report its link type.
+func NewSourceFilter(src gopacket.PacketDataSource, expr string) (*Source, error) {
+	s := newSource(src)
+	if !s.known {
+		return nil, errors.New("pcapfilter: link type of source is unknown")
+	}
+	s.compile = func(linkType layers.LinkType) ([]bpf.Instruction, error) {
+		return Compile(linkType, maxSnaplen, expr)
+	}
+	// report syntax errors and an unsupported link type now rather than
+	// on the first packet
+	linkType := s.linkType
+	if s.ng != nil && s.ng.NInterfaces() == 0 {
+		linkType = layers.LinkTypeEthernet
+	}
+	if _, err := s.vm(linkType); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func newSource(src gopacket.PacketDataSource) *Source {
+	s := &Source{
+		src:  src,
+		vms:  make(map[layers.LinkType]*bpf.VM),
+		errs: make(map[layers.LinkType]error),
+	}
+	switch r := src.(type) {
+	case *pcapgo.NgReader:
+		// with NgReaderOptions.WantMixedLinkType, interfaces are only
+		// read along with the packets
+		s.ng, s.known = r, true
+		if intf, err := r.Interface(0); err == nil {
+			s.linkType = intf.LinkType
+		}
+	case interface{ LinkType() layers.LinkType }:
+		s.linkType, s.known = r.LinkType(), true
+	case interface {
+		LinkType() (*layers.LinkType, error)
+	}:
+		if lt, err := r.LinkType(); err == nil {
+			s.linkType, s.known = *lt, true
+		}
+	}
+	return s
+}
+
+// LinkType returns the link type of the underlying source, or of the
+// first interface of a pcapng file if it has been read.
+func (s *Source) LinkType() layers.LinkType {
+	return s.linkType
+}
+
+// vm returns the virtual machine filtering packets of linkType, nil if
+// they are all rejected.
+func (s *Source) vm(linkType layers.LinkType) (*bpf.VM, error) {
+	if vm, ok := s.vms[linkType]; ok || s.compile == nil {
+		return vm, nil
+	}
+	if err, ok := s.errs[linkType]; ok {
+		return nil, err
+	}
+	prog, err := s.compile(linkType)
+	if err != nil {
+		s.errs[linkType] = err
+		return nil, err
+	}
+	vm, err := bpf.NewVM(prog)
+	if err != nil {
+		s.errs[linkType] = err
+		return nil, err
+	}
+	s.vms[linkType] = vm
+	return vm, nil
+}
+
+// accept runs the filter for the packet data captured as described by
+// ci.
+func (s *Source) accept(data []byte, ci gopacket.CaptureInfo) (bool, error) {
+	linkType := s.linkType
+	if s.ng != nil {
+		if s.unbound != nil {
+			// the interface of the first packet is the first one
+			intf, err := s.ng.Interface(0)
+			if err != nil {
+				return false, err
+			}
+			s.linkType = intf.LinkType
+			s.vms[s.linkType], s.unbound = s.unbound, nil
+		}
+		intf, err := s.ng.Interface(ci.InterfaceIndex)
+		if err != nil {
+			return false, err
+		}
+		linkType = intf.LinkType
+	}
+	vm, err := s.vm(linkType)
+	if vm == nil {
+		return false, err
+	}
+	n, err := vm.Run(data)
+	return n > 0, err
+}
+
+// ReadPacketData returns the next packet accepted by the filter.
+func (s *Source) ReadPacketData() (data []byte, ci gopacket.CaptureInfo, err error) {
+	for {
+		data, ci, err = s.src.ReadPacketData()
+		if err != nil {
+			return
+		}
+		var ok bool
+		if ok, err = s.accept(data, ci); ok || err != nil {
+			return
+		}
+	}
+}
+
+// ZeroCopyReadPacketData is like ReadPacketData, without copying the
+// packet data if the underlying source implements
+// gopacket.ZeroCopyPacketDataSource. The returned data is only valid
+// until the next call.
+func (s *Source) ZeroCopyReadPacketData() (data []byte, ci gopacket.CaptureInfo, err error) {
+	zc, ok := s.src.(gopacket.ZeroCopyPacketDataSource)
+	if !ok {
+		return s.ReadPacketData()
+	}
+	for {
+		data, ci, err = zc.ZeroCopyReadPacketData()
+		if err != nil {
+			return
+		}
+		if ok, err = s.accept(data, ci); ok || err != nil {
+			return
+		}
+	}
+}
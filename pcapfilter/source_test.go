@@ -0,0 +1,153 @@
+// Copyright 2026 The GoPacket Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package pcapfilter
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+)
+
+// readAll returns the packets of src until io.EOF.
+func readAll(t *testing.T, src gopacket.PacketDataSource) [][]byte {
+	var out [][]byte
+	for {
+		data, _, err := src.ReadPacketData()
+		if err == io.EOF {
+			return out
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, data)
+	}
+}
+
+// openPcap returns a reader for the packets of the pcap file name.
+func openPcap(t *testing.T, name string) *pcapgo.Reader {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := pcapgo.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestSourceFilter(t *testing.T) {
+	for _, expr := range []string{"tcp", "udp port 53", "tcp[tcpflags] & tcp-syn != 0", ""} {
+		all := readAll(t, openPcap(t, "../pcap/test_ethernet.pcap"))
+		var want int
+		for _, data := range all {
+			p := gopacket.NewPacket(data, layers.LinkTypeEthernet, gopacket.Default)
+			tcp, _ := p.Layer(layers.LayerTypeTCP).(*layers.TCP)
+			udp, _ := p.Layer(layers.LayerTypeUDP).(*layers.UDP)
+			var match bool
+			switch expr {
+			case "tcp":
+				match = tcp != nil
+			case "udp port 53":
+				match = udp != nil && (udp.SrcPort == 53 || udp.DstPort == 53)
+			case "tcp[tcpflags] & tcp-syn != 0":
+				match = tcp != nil && tcp.SYN
+			case "":
+				match = true
+			}
+			if match {
+				want++
+			}
+		}
+
+		src, err := NewSourceFilter(openPcap(t, "../pcap/test_ethernet.pcap"), expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if src.LinkType() != layers.LinkTypeEthernet {
+			t.Errorf("link type %v", src.LinkType())
+		}
+		if got := len(readAll(t, src)); got != want {
+			t.Errorf("%q: got %d packets, want %d", expr, got, want)
+		}
+	}
+}
+
+func TestSourceNgInterfaces(t *testing.T) {
+	ip := ip4("10.0.0.1", "8.8.8.8", layers.IPProtocolUDP)
+	l4 := udp(5353, 53)
+	l4.SetNetworkLayerForChecksum(ip)
+	udp4 := serialize(t, eth(layers.EthernetTypeIPv4), ip, l4)
+	var buf bytes.Buffer
+	w, err := pcapgo.NewNgWriter(&buf, layers.LinkTypeEthernet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := w.AddInterface(pcapgo.NgInterface{LinkType: layers.LinkTypeRaw})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, data := range [][]byte{udp4, udp4[14:]} {
+		ci := gopacket.CaptureInfo{CaptureLength: len(data), Length: len(data)}
+		if i == 1 {
+			ci.InterfaceIndex = raw
+		}
+		if err := w.WritePacket(ci, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	open := func() *pcapgo.NgReader {
+		r, err := pcapgo.NewNgReader(bytes.NewReader(buf.Bytes()), pcapgo.NgReaderOptions{WantMixedLinkType: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+
+	// the expression is compiled for both link types
+	src, err := NewSourceFilter(open(), "udp port 53")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(readAll(t, src)); got != 2 {
+		t.Errorf("filter: got %d packets, want 2", got)
+	}
+
+	// a program only applies to the link type it was compiled for
+	prog, err := Compile(layers.LinkTypeEthernet, 65535, "udp port 53")
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err = NewSource(open(), prog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readAll(t, src); len(got) != 1 || len(got[0]) != len(udp4) {
+		t.Errorf("program: got %d packets, want the Ethernet one", len(got))
+	}
+}
+
+func TestSourceErrors(t *testing.T) {
+	if _, err := NewSourceFilter(openPcap(t, "../pcap/test_ethernet.pcap"), "tcp port"); err == nil {
+		t.Error("invalid expression: no error")
+	}
+	if _, err := NewSourceFilter(openPcap(t, "../pcap/test_loopback.pcap"), "tcp"); err == nil {
+		t.Error("unsupported link type: no error")
+	}
+	if _, err := NewSourceFilter(gopacket.PacketDataSource(nil), "tcp"); err == nil {
+		t.Error("unknown link type: no error")
+	}
+}
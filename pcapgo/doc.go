@@ -20,7 +20,11 @@ Basic Usage pcapng
 Pcapng files can be read and written. Reading supports both big and little endian files, packet blocks,
 simple packet blocks, enhanced packets blocks, interface blocks, and interface statistics blocks. All
 the options also by Wireshark are supported. The default reader options match libpcap behaviour. Have
-a look at NgReaderOptions for more advanced usage. Both ReadPacketData and ZeroCopyReadPacketData is
+a look at NgReaderOptions for more advanced usage. Name resolution, decryption secrets, custom, and
+systemd journal export blocks are passed to the callbacks in NgReaderOptions. Packet options (comments,
+flags, hashes, drop count, and packet id) are read by ReadPacketDataWithOptions and written by
+WritePacketWithOptions. Several pcap, pcapng, and snoop inputs can be merged into one pcapng file with
+NgMerge. Both ReadPacketData and ZeroCopyReadPacketData is
 supported (which means PacketDataSource and ZeroCopyPacketDataSource is supported).
 
 		f, err := os.Open("somefile.pcapng")
@@ -37,7 +41,8 @@ supported (which means PacketDataSource and ZeroCopyPacketDataSource is supporte
 		data, ci, err := r.ReadPacketData()
 		...
 
-Write supports only little endian, enhanced packets blocks, interface blocks, and interface statistics
+Write supports only little endian, enhanced packets blocks, interface blocks, interface statistics
+blocks, name resolution blocks, decryption secrets blocks, custom blocks, and systemd journal export
 blocks. The same options as with writing are supported. Interface timestamp resolution is fixed to
 10^-9s to match time.Time. Any other values are ignored. Upon creating a writer, a section, and an
 interface block is automatically written. Additional interfaces can be added at any time. Since
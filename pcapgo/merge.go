@@ -0,0 +1,157 @@
+// Copyright 2026 The GoPacket Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package pcapgo
+
+import (
+	"container/heap"
+	"fmt"
+	"io"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// mergeInput is an input of NgMerge with its next packet.
+type mergeInput struct {
+	src   gopacket.PacketDataSource
+	index int // position in the inputs, to keep the order of packets with equal timestamps
+
+	// ng is set for pcapng inputs, whose interfaces are mapped to
+	// interfaces of the output
+	ng     *NgReader
+	ifaces map[[2]int]int // section and interface in the input -> output interface
+	// iface is the output interface of other inputs, -1 before the first packet
+	iface int
+	intf  NgInterface
+
+	data []byte
+	ci   gopacket.CaptureInfo
+	opts NgPacketOptions
+}
+
+// next reads the next packet of the input, adding its interface to w if needed.
+func (in *mergeInput) next(w *NgWriter) (err error) {
+	if in.ng == nil {
+		in.data, in.ci, err = in.src.ReadPacketData()
+		if err != nil {
+			return err
+		}
+		if in.iface < 0 {
+			if in.iface, err = w.AddInterface(in.intf); err != nil {
+				return err
+			}
+		}
+		in.ci.InterfaceIndex = in.iface
+		in.opts = NgEmptyPacketOptions
+		return nil
+	}
+
+	in.data, in.ci, err = in.ng.ReadPacketDataWithOptions(&in.opts)
+	if err != nil {
+		return err
+	}
+	key := [2]int{in.ng.section, in.ci.InterfaceIndex}
+	id, ok := in.ifaces[key]
+	if !ok {
+		intf, err := in.ng.Interface(in.ci.InterfaceIndex)
+		if err != nil {
+			return err
+		}
+		// timestamps are already adjusted by the reader and written with nanosecond resolution
+		intf.TimestampOffset = 0
+		intf.Statistics = NgInterfaceStatistics{}
+		if id, err = w.AddInterface(intf); err != nil {
+			return err
+		}
+		in.ifaces[key] = id
+	}
+	in.ci.InterfaceIndex = id
+	in.ci.AncillaryData = nil
+	return nil
+}
+
+// mergeHeap orders inputs by the timestamp of their next packet.
+type mergeHeap []*mergeInput
+
+func (h mergeHeap) Len() int { return len(h) }
+func (h mergeHeap) Less(i, j int) bool {
+	if h[i].ci.Timestamp.Equal(h[j].ci.Timestamp) {
+		return h[i].index < h[j].index
+	}
+	return h[i].ci.Timestamp.Before(h[j].ci.Timestamp)
+}
+func (h mergeHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *mergeHeap) Push(x interface{}) { *h = append(*h, x.(*mergeInput)) }
+func (h *mergeHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// NgMerge writes the packets of all inputs as a single pcapng section to w, ordered by timestamp. Packets with equal timestamps are written in the order of the inputs.
+// Inputs are read in lockstep, so that only one packet per input is held in memory. Each input must be sorted by timestamp.
+//
+// Inputs can be a *NgReader, a *SnoopReader, or any other PacketDataSource with a LinkType() layers.LinkType method, like *Reader.
+// Each interface of each section of a pcapng input becomes an interface of the output, keeping its description and link type; the packet options are kept as well.
+// NgReaders should be created with WantMixedLinkType, otherwise packets of interfaces with another link type than the first one are skipped by the reader.
+// Every other input becomes one interface of the output, with the link type and snap length of the input.
+//
+// Interfaces are added to the output when their first packet is read. Interface statistics, and blocks other than interfaces and packets are not copied.
+func NgMerge(w io.Writer, options NgWriterOptions, inputs ...gopacket.PacketDataSource) error {
+	out, err := newNgWriterSection(w, options)
+	if err != nil {
+		return err
+	}
+
+	h := make(mergeHeap, 0, len(inputs))
+	for i, src := range inputs {
+		in := &mergeInput{src: src, index: i, iface: -1}
+		in.intf = DefaultNgInterface
+		in.intf.Name = fmt.Sprintf("intf%d", i)
+		switch r := src.(type) {
+		case *NgReader:
+			in.ng = r
+			in.ifaces = make(map[[2]int]int)
+		case *Reader:
+			in.intf.LinkType = r.LinkType()
+			in.intf.SnapLength = r.Snaplen()
+		case *SnoopReader:
+			linkType, err := r.LinkType()
+			if err != nil {
+				return err
+			}
+			in.intf.LinkType = *linkType
+		case interface{ LinkType() layers.LinkType }:
+			in.intf.LinkType = r.LinkType()
+		default:
+			return fmt.Errorf("Can't determine the link type of input %d (%T)", i, src)
+		}
+		if err := in.next(out); err == io.EOF {
+			continue
+		} else if err != nil {
+			return fmt.Errorf("Reading input %d: %v", i, err)
+		}
+		h = append(h, in)
+	}
+	heap.Init(&h)
+
+	for len(h) > 0 {
+		in := h[0]
+		if err := out.WritePacketWithOptions(in.ci, in.data, in.opts); err != nil {
+			return err
+		}
+		if err := in.next(out); err == io.EOF {
+			heap.Pop(&h)
+			continue
+		} else if err != nil {
+			return fmt.Errorf("Reading input %d: %v", in.index, err)
+		}
+		heap.Fix(&h, 0)
+	}
+	return out.Flush()
+}
@@ -0,0 +1,134 @@
+// Copyright 2026 The GoPacket Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package pcapgo
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+func mergeTestCI(sec int, iface int, data []byte) gopacket.CaptureInfo {
+	return gopacket.CaptureInfo{
+		Timestamp:      time.Unix(int64(sec), 0).UTC(),
+		Length:         len(data),
+		CaptureLength:  len(data),
+		InterfaceIndex: iface,
+	}
+}
+
+func TestNgMerge(t *testing.T) {
+	// a pcap file with packets at 1s and 4s
+	pcapBuf := &bytes.Buffer{}
+	pw := NewWriter(pcapBuf)
+	if err := pw.WriteFileHeader(1500, layers.LinkTypeRaw); err != nil {
+		t.Fatal(err)
+	}
+	for _, sec := range []int{1, 4} {
+		data := []byte{0x45, byte(sec)}
+		if err := pw.WritePacket(mergeTestCI(sec, 0, data), data); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a pcapng file with two sections, the first one with two interfaces
+	ngBuf := &bytes.Buffer{}
+	nw, err := NewNgWriterInterface(ngBuf, NgInterface{Name: "eth0", LinkType: layers.LinkTypeEthernet}, DefaultNgWriterOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := nw.AddInterface(NgInterface{Name: "lo", LinkType: layers.LinkTypeNull, Description: "loopback"}); err != nil {
+		t.Fatal(err)
+	}
+	opts := NgEmptyPacketOptions
+	opts.Comments = []string{"annotated"}
+	for _, p := range []struct{ sec, iface int }{{0, 0}, {2, 1}, {4, 0}} {
+		data := []byte{byte(p.sec), byte(p.iface)}
+		if err := nw.WritePacketWithOptions(mergeTestCI(p.sec, p.iface, data), data, opts); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := nw.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	nw, err = NewNgWriterInterface(ngBuf, NgInterface{Name: "wlan0", LinkType: layers.LinkTypeIEEE80211Radio}, DefaultNgWriterOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := []byte{5, 0}
+	if err := nw.WritePacket(mergeTestCI(5, 0, data), data); err != nil {
+		t.Fatal(err)
+	}
+	if err := nw.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	pr, err := NewReader(bytes.NewReader(pcapBuf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	nr, err := NewNgReader(bytes.NewReader(ngBuf.Bytes()), NgReaderOptions{WantMixedLinkType: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := &bytes.Buffer{}
+	if err := NgMerge(out, DefaultNgWriterOptions, nr, pr); err != nil {
+		t.Fatal("Merge failed:", err)
+	}
+
+	r, err := NewNgReader(bytes.NewReader(out.Bytes()), NgReaderOptions{WantMixedLinkType: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		sec      int
+		data     []byte
+		name     string
+		linkType layers.LinkType
+		comment  bool
+	}{
+		{0, []byte{0, 0}, "eth0", layers.LinkTypeEthernet, true},
+		{1, []byte{0x45, 1}, "intf1", layers.LinkTypeRaw, false},
+		{2, []byte{2, 1}, "lo", layers.LinkTypeNull, true},
+		{4, []byte{4, 0}, "eth0", layers.LinkTypeEthernet, true},
+		{4, []byte{0x45, 4}, "intf1", layers.LinkTypeRaw, false},
+		{5, []byte{5, 0}, "wlan0", layers.LinkTypeIEEE80211Radio, false},
+	}
+	for i, w := range want {
+		var opts NgPacketOptions
+		data, ci, err := r.ReadPacketDataWithOptions(&opts)
+		if err != nil {
+			t.Fatalf("Packet %d: %v", i, err)
+		}
+		intf, err := r.Interface(ci.InterfaceIndex)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ci.Timestamp.Unix() != int64(w.sec) || !bytes.Equal(data, w.data) {
+			t.Errorf("Packet %d: got %v at %v, want %v at %ds", i, data, ci.Timestamp, w.data, w.sec)
+		}
+		if intf.Name != w.name || intf.LinkType != w.linkType {
+			t.Errorf("Packet %d: got interface %s/%v, want %s/%v", i, intf.Name, intf.LinkType, w.name, w.linkType)
+		}
+		if (len(opts.Comments) == 1) != w.comment {
+			t.Errorf("Packet %d: got comments %q", i, opts.Comments)
+		}
+		if w.name == "lo" && intf.Description != "loopback" {
+			t.Errorf("Packet %d: description %q not kept", i, intf.Description)
+		}
+	}
+	if _, _, err := r.ReadPacketData(); err != io.EOF {
+		t.Errorf("Expected EOF, got %v", err)
+	}
+	if r.NInterfaces() != 4 {
+		t.Errorf("Got %d interfaces, want 4", r.NInterfaces())
+	}
+}
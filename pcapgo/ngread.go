@@ -8,10 +8,12 @@ package pcapgo
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"time"
 
 	"github.com/google/gopacket"
@@ -33,6 +35,14 @@ type NgReaderOptions struct {
 	SectionEndCallback func([]NgInterface, NgSectionInfo)
 	// StatisticsCallback is called when a interface statistics block is read. The interface id and the read statistics are provided.
 	StatisticsCallback func(int, NgInterfaceStatistics)
+	// NameResolutionCallback is called when a name resolution block is read.
+	NameResolutionCallback func(NgNameResolution)
+	// DecryptionSecretsCallback is called when a decryption secrets block is read.
+	DecryptionSecretsCallback func(NgDecryptionSecrets)
+	// CustomBlockCallback is called when a custom block is read.
+	CustomBlockCallback func(NgCustomBlock)
+	// SystemdJournalCallback is called when a systemd journal export block is read. The journal entry in the Journal Export Format is provided.
+	SystemdJournalCallback func([]byte)
 }
 
 // DefaultNgReaderOptions provides sane defaults for a pcapng reader.
@@ -55,6 +65,7 @@ type NgReader struct {
 	firstSectionFound bool
 	activeSection     bool
 	bigEndian         bool
+	section           int // number of sections read so far
 }
 
 // NewNgReader initializes a new writer, reads the first section header, and if necessary according to the options the first interface.
@@ -256,6 +267,7 @@ OPTIONS:
 	}
 	r.activeSection = true
 	r.sectionInfo = section
+	r.section++
 
 	if !r.options.WantMixedLinkType {
 		// If we don't want mixed link type, we need the first interface to fill Reader.LinkType()
@@ -315,7 +327,7 @@ func (r *NgReader) firstInterface() error {
 		case ngBlockTypePacket, ngBlockTypeEnhancedPacket, ngBlockTypeSimplePacket, ngBlockTypeInterfaceStatistics:
 			return errors.New("A section must have an interface before a packet block")
 		}
-		if _, err := r.r.Discard(int(r.currentBlock.length)); err != nil {
+		if err := r.readOtherBlock(); err != nil {
 			return err
 		}
 	}
@@ -437,9 +449,196 @@ OPTIONS:
 	return nil
 }
 
+// readOtherBlock handles blocks not describing interfaces or packets. Name resolution, decryption secrets, custom and systemd journal export blocks are passed to their callback, if any; all other blocks are skipped.
+func (r *NgReader) readOtherBlock() error {
+	var callback func([]byte) error
+	switch r.currentBlock.typ {
+	case ngBlockTypeNameResolution:
+		if r.options.NameResolutionCallback != nil {
+			callback = r.readNameResolution
+		}
+	case ngBlockTypeDecryptionSecrets:
+		if r.options.DecryptionSecretsCallback != nil {
+			callback = r.readDecryptionSecrets
+		}
+	case ngBlockTypeCustom, ngBlockTypeCustomNoCopy:
+		if r.options.CustomBlockCallback != nil {
+			callback = r.readCustomBlock
+		}
+	case ngBlockTypeSystemdJournal:
+		if r.options.SystemdJournalCallback != nil {
+			callback = r.readSystemdJournal
+		}
+	}
+	if callback == nil {
+		_, err := r.r.Discard(int(r.currentBlock.length))
+		return err
+	}
+	if r.currentBlock.length < 4 || r.currentBlock.length&3 != 0 {
+		return fmt.Errorf("Invalid length %d for block type %#x", r.currentBlock.length+8, uint32(r.currentBlock.typ))
+	}
+	// these blocks are rare and small enough to be read at once
+	body := make([]byte, r.currentBlock.length)
+	if err := r.readBytes(body); err != nil {
+		return err
+	}
+	r.currentBlock.length = 0
+	// drop the trailing block length
+	return callback(body[:len(body)-4])
+}
+
+// parseOptions calls fn for each option in buf until the end of options.
+func (r *NgReader) parseOptions(buf []byte, fn func(code ngOptionCode, value []byte)) error {
+	for len(buf) >= 4 {
+		code := ngOptionCode(r.getUint16(buf[:2]))
+		length := int(r.getUint16(buf[2:4]))
+		if code == ngOptionCodeEndOfOptions {
+			return nil
+		}
+		buf = buf[4:]
+		if length > len(buf) {
+			return errors.New("Option exceeds block length")
+		}
+		fn(code, buf[:length])
+		length += (4 - length&3) & 3
+		if length > len(buf) {
+			length = len(buf)
+		}
+		buf = buf[length:]
+	}
+	return nil
+}
+
+// readNameResolution parses the records and options of a name resolution block.
+func (r *NgReader) readNameResolution(body []byte) error {
+	var nrb NgNameResolution
+	for {
+		if len(body) < 4 {
+			return errors.New("Name resolution block is missing end of records")
+		}
+		code := ngNameRecordCode(r.getUint16(body[:2]))
+		length := int(r.getUint16(body[2:4]))
+		body = body[4:]
+		if code == ngNameRecordEnd {
+			break
+		}
+		if length > len(body) {
+			return errors.New("Name resolution record exceeds block length")
+		}
+		value := body[:length]
+		padded := length + (4-length&3)&3
+		if padded > len(body) {
+			padded = len(body)
+		}
+		body = body[padded:]
+
+		var addrLen int
+		switch code {
+		case ngNameRecordIPv4:
+			addrLen = 4
+		case ngNameRecordIPv6:
+			addrLen = 16
+		case ngNameRecordEUI48:
+			addrLen = 6
+		case ngNameRecordEUI64:
+			addrLen = 8
+		default:
+			// unknown record type
+			continue
+		}
+		if len(value) < addrLen {
+			return fmt.Errorf("Name resolution record of type %d too short", code)
+		}
+		record := NgNameResolutionRecord{Address: append([]byte(nil), value[:addrLen]...)}
+		switch code {
+		case ngNameRecordIPv4, ngNameRecordIPv6:
+			record.Address = net.IP(record.Address)
+		default:
+			record.Address = net.HardwareAddr(record.Address)
+		}
+		for _, name := range bytes.Split(value[addrLen:], []byte{0}) {
+			if len(name) > 0 {
+				record.Names = append(record.Names, string(name))
+			}
+		}
+		nrb.Records = append(nrb.Records, record)
+	}
+	err := r.parseOptions(body, func(code ngOptionCode, value []byte) {
+		switch code {
+		case ngOptionCodeComment:
+			nrb.Comment = string(value)
+		case ngOptionCodeNameResolutionDNSName:
+			nrb.DNSName = string(value)
+		case ngOptionCodeNameResolutionDNSIPv4Address:
+			if len(value) == 4 {
+				nrb.DNSIPv4Address = net.IP(append([]byte(nil), value...))
+			}
+		case ngOptionCodeNameResolutionDNSIPv6Address:
+			if len(value) == 16 {
+				nrb.DNSIPv6Address = net.IP(append([]byte(nil), value...))
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+	r.options.NameResolutionCallback(nrb)
+	return nil
+}
+
+// readDecryptionSecrets parses a decryption secrets block.
+func (r *NgReader) readDecryptionSecrets(body []byte) error {
+	if len(body) < 8 {
+		return errors.New("Decryption secrets block too short")
+	}
+	dsb := NgDecryptionSecrets{Type: NgSecretsType(r.getUint32(body[:4]))}
+	length := int(r.getUint32(body[4:8]))
+	body = body[8:]
+	if length > len(body) {
+		return errors.New("Decryption secrets exceed block length")
+	}
+	dsb.Data = body[:length]
+	length += (4 - length&3) & 3
+	if length > len(body) {
+		length = len(body)
+	}
+	err := r.parseOptions(body[length:], func(code ngOptionCode, value []byte) {
+		if code == ngOptionCodeComment {
+			dsb.Comment = string(value)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	r.options.DecryptionSecretsCallback(dsb)
+	return nil
+}
+
+// readCustomBlock parses a custom block.
+func (r *NgReader) readCustomBlock(body []byte) error {
+	if len(body) < 4 {
+		return errors.New("Custom block too short")
+	}
+	r.options.CustomBlockCallback(NgCustomBlock{
+		Copyable: r.currentBlock.typ == ngBlockTypeCustom,
+		PEN:      r.getUint32(body[:4]),
+		Data:     body[4:],
+	})
+	return nil
+}
+
+// readSystemdJournal parses a systemd journal export block, removing the padding of the entry.
+func (r *NgReader) readSystemdJournal(body []byte) error {
+	for i := 0; i < 3 && len(body) > 0 && body[len(body)-1] == 0; i++ {
+		body = body[:len(body)-1]
+	}
+	r.options.SystemdJournalCallback(body)
+	return nil
+}
+
 // readPacketHeader looks for a packet (enhanced, simple, or packet) and parses the header.
 // If an interface descriptor, an interface statistics block, or a section header is encountered, those are handled accordingly.
-// All other block types are skipped. New block types must be added here.
+// All other block types are passed to readOtherBlock. New block types must be added here.
 func (r *NgReader) readPacketHeader() error {
 RESTART:
 FIND_PACKET:
@@ -503,7 +702,7 @@ FIND_PACKET:
 			r.ci.Length = int(r.getUint32(r.buf[16:20]))
 			break FIND_PACKET
 		default:
-			if _, err := r.r.Discard(int(r.currentBlock.length)); err != nil {
+			if err := r.readOtherBlock(); err != nil {
 				return err
 			}
 		}
@@ -544,6 +743,80 @@ func (r *NgReader) ReadPacketData() (data []byte, ci gopacket.CaptureInfo, err e
 	return
 }
 
+// ReadPacketDataWithOptions is like ReadPacketData, additionally filling opts with the options of the packet block.
+// Options are only parsed by this method, since doing this for every packet is expensive.
+func (r *NgReader) ReadPacketDataWithOptions(opts *NgPacketOptions) (data []byte, ci gopacket.CaptureInfo, err error) {
+	if err = r.readPacketHeader(); err != nil {
+		return
+	}
+	ci = r.ci
+	if r.options.WantMixedLinkType {
+		ci.AncillaryData = make([]interface{}, 1)
+		ci.AncillaryData[0] = r.ancil[0]
+	}
+	data = make([]byte, r.ci.CaptureLength)
+	if err = r.readBytes(data); err != nil {
+		return
+	}
+	err = r.readPacketOptions(opts)
+	return
+}
+
+// readPacketOptions parses the options following the packet data of the current block into opts.
+func (r *NgReader) readPacketOptions(opts *NgPacketOptions) error {
+	*opts = NgEmptyPacketOptions
+	if r.currentBlock.typ == ngBlockTypeSimplePacket {
+		// simple packet blocks have no options, and the packet data can
+		// be longer than the snap length
+		_, err := r.r.Discard(int(r.currentBlock.length) - r.ci.CaptureLength)
+		return err
+	}
+	length := uint32(r.ci.CaptureLength)
+	length += (4 - length&3) & 3
+	if r.currentBlock.length < length+4 {
+		return errors.New("Packet data exceeds block length")
+	}
+	if _, err := r.r.Discard(int(length) - r.ci.CaptureLength); err != nil {
+		return err
+	}
+	r.currentBlock.length -= length
+
+OPTIONS:
+	for {
+		if err := r.readOption(); err != nil {
+			return err
+		}
+		value := r.currentOption.value
+		switch r.currentOption.code {
+		case ngOptionCodeEndOfOptions:
+			break OPTIONS
+		case ngOptionCodeComment:
+			opts.Comments = append(opts.Comments, string(value))
+		case ngOptionCodePacketFlags:
+			if len(value) == 4 {
+				opts.Flags = ngPacketFlagsFromUint32(r.getUint32(value))
+			}
+		case ngOptionCodePacketHash:
+			if len(value) > 0 {
+				opts.Hashes = append(opts.Hashes, NgPacketHash{
+					Algorithm: NgHashAlgorithm(value[0]),
+					Value:     append([]byte(nil), value[1:]...),
+				})
+			}
+		case ngOptionCodePacketDropCount:
+			if len(value) == 8 {
+				opts.DropCount = r.getUint64(value)
+			}
+		case ngOptionCodePacketID:
+			if len(value) == 8 {
+				opts.PacketID = r.getUint64(value)
+			}
+		}
+	}
+	_, err := r.r.Discard(int(r.currentBlock.length))
+	return err
+}
+
 // ZeroCopyReadPacketData returns the next packet available from this data source.
 // If WantMixedLinkType is true, ci.AncillaryData[0] contains the link type.
 // Warning: Like data, ci.AncillaryData is also reused and overwritten on the next call to ZeroCopyReadPacketData.
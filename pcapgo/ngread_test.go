@@ -1842,6 +1842,44 @@ func TestNgFileReadLEZero(t *testing.T) {
 	}
 }
 
+func TestNgReadSimplePacketOptions(t *testing.T) {
+	file := []byte{
+		0x0A, 0x0D, 0x0D, 0x0A, 28, 0, 0, 0, // Section Header
+		0x4D, 0x3C, 0x2B, 0x1A, 1, 0, 0, 0,
+		0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+		28, 0, 0, 0,
+		1, 0, 0, 0, 20, 0, 0, 0, // Interface Description, snap length 4
+		1, 0, 0, 0, 4, 0, 0, 0,
+		20, 0, 0, 0,
+		3, 0, 0, 0, 20, 0, 0, 0, // Simple Packet, truncated to the snap length
+		10, 0, 0, 0, 1, 2, 3, 4,
+		20, 0, 0, 0,
+		3, 0, 0, 0, 28, 0, 0, 0, // Simple Packet, longer than the snap length
+		10, 0, 0, 0, 1, 0, 8, 0, 9, 9, 9, 9, 9, 9, 0, 0,
+		28, 0, 0, 0,
+	}
+	r, err := NewNgReader(bytes.NewReader(file), DefaultNgReaderOptions)
+	if err != nil {
+		t.Fatal("Couldn't read file", err)
+	}
+	for i, want := range [][]byte{{1, 2, 3, 4}, {1, 0, 8, 0}} {
+		var opts NgPacketOptions
+		data, ci, err := r.ReadPacketDataWithOptions(&opts)
+		if err != nil {
+			t.Fatal("Couldn't read packet", err)
+		}
+		if !bytes.Equal(data, want) || ci.Length != 10 || ci.CaptureLength != 4 {
+			t.Errorf("Packet %d mismatch: %x, %+v", i, data, ci)
+		}
+		if !reflect.DeepEqual(opts, NgEmptyPacketOptions) {
+			t.Errorf("Packet %d options: %+v", i, opts)
+		}
+	}
+	if _, _, err := r.ReadPacketData(); err != io.EOF {
+		t.Errorf("Expected EOF, got %v", err)
+	}
+}
+
 type endlessNgPacketReader struct {
 	packet []byte
 }
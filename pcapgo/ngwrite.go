@@ -11,6 +11,7 @@ import (
 	"encoding/binary"
 	"fmt"
 	"io"
+	"net"
 	"runtime"
 	"time"
 
@@ -64,6 +65,19 @@ func NewNgWriter(w io.Writer, linkType layers.LinkType) (*NgWriter, error) {
 //
 // Written files are in little endian format. Interface timestamp resolution is fixed to 9 (to match time.Time).
 func NewNgWriterInterface(w io.Writer, intf NgInterface, options NgWriterOptions) (*NgWriter, error) {
+	ret, err := newNgWriterSection(w, options)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := ret.AddInterface(intf); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// newNgWriterSection initializes and returns a new writer, writing only the section header.
+func newNgWriterSection(w io.Writer, options NgWriterOptions) (*NgWriter, error) {
 	ret := &NgWriter{
 		w:       bufio.NewWriter(w),
 		options: options,
@@ -71,10 +85,6 @@ func NewNgWriterInterface(w io.Writer, intf NgInterface, options NgWriterOptions
 	if err := ret.writeSectionHeader(); err != nil {
 		return nil, err
 	}
-
-	if _, err := ret.AddInterface(intf); err != nil {
-		return nil, err
-	}
 	return ret, nil
 }
 
@@ -354,6 +364,11 @@ func (w *NgWriter) WriteInterfaceStats(intf int, stats NgInterfaceStatistics) er
 
 // WritePacket writes out packet with the given data and capture info. The given InterfaceIndex must already be added to the file. InterfaceIndex 0 is automatically added by the NewWriter* methods.
 func (w *NgWriter) WritePacket(ci gopacket.CaptureInfo, data []byte) error {
+	return w.WritePacketWithOptions(ci, data, NgEmptyPacketOptions)
+}
+
+// WritePacketWithOptions is like WritePacket, additionally writing the given packet options. Empty values are not written.
+func (w *NgWriter) WritePacketWithOptions(ci gopacket.CaptureInfo, data []byte, opts NgPacketOptions) error {
 	if ci.InterfaceIndex >= int(w.intf) || ci.InterfaceIndex < 0 {
 		return fmt.Errorf("Can't send statistics for non existent interface %d; have only %d interfaces", ci.InterfaceIndex, w.intf)
 	}
@@ -364,9 +379,26 @@ func (w *NgWriter) WritePacket(ci gopacket.CaptureInfo, data []byte) error {
 		return fmt.Errorf("invalid capture info %+v:  capture length > length", ci)
 	}
 
+	var options []ngOption
+	for _, comment := range opts.Comments {
+		options = append(options, ngOption{code: ngOptionCodeComment, raw: comment})
+	}
+	if opts.Flags != (NgPacketFlags{}) {
+		options = append(options, ngOption{code: ngOptionCodePacketFlags, raw: opts.Flags.toUint32()})
+	}
+	for _, hash := range opts.Hashes {
+		options = append(options, ngOption{code: ngOptionCodePacketHash, raw: append([]byte{byte(hash.Algorithm)}, hash.Value...)})
+	}
+	if opts.DropCount != NgNoValue64 {
+		options = append(options, ngOption{code: ngOptionCodePacketDropCount, raw: opts.DropCount})
+	}
+	if opts.PacketID != NgNoValue64 {
+		options = append(options, ngOption{code: ngOptionCodePacketID, raw: opts.PacketID})
+	}
+
 	length := uint32(len(data)) + 32
 	padding := (4 - length&3) & 3
-	length += padding
+	length += padding + prepareNgOptions(options)
 
 	ts := ci.Timestamp.UnixNano()
 
@@ -387,10 +419,147 @@ func (w *NgWriter) WritePacket(ci gopacket.CaptureInfo, data []byte) error {
 	}
 
 	binary.LittleEndian.PutUint32(w.buf[:4], 0)
-	_, err := w.w.Write(w.buf[4-padding : 8]) // padding + length
+	if _, err := w.w.Write(w.buf[:padding]); err != nil {
+		return err
+	}
+
+	if err := w.writeOptions(options); err != nil {
+		return err
+	}
+
+	binary.LittleEndian.PutUint32(w.buf[:4], length)
+	_, err := w.w.Write(w.buf[:4])
+	return err
+}
+
+// writeBlock writes a block of the given type, consisting of body padded to 32 bits and options.
+func (w *NgWriter) writeBlock(typ ngBlockType, body []byte, options []ngOption) error {
+	padding := uint32((4 - len(body)&3) & 3)
+	length := uint32(len(body)) + padding + prepareNgOptions(options) +
+		8 + // header
+		4 // trailer
+
+	binary.LittleEndian.PutUint32(w.buf[:4], uint32(typ))
+	binary.LittleEndian.PutUint32(w.buf[4:8], length)
+	if _, err := w.w.Write(w.buf[:8]); err != nil {
+		return err
+	}
+	if _, err := w.w.Write(body); err != nil {
+		return err
+	}
+	binary.LittleEndian.PutUint32(w.buf[:4], 0)
+	if _, err := w.w.Write(w.buf[:padding]); err != nil {
+		return err
+	}
+
+	if err := w.writeOptions(options); err != nil {
+		return err
+	}
+
+	binary.LittleEndian.PutUint32(w.buf[0:4], length)
+	_, err := w.w.Write(w.buf[:4])
 	return err
 }
 
+// WriteNameResolution writes a name resolution block with the given records. Empty values are not written.
+func (w *NgWriter) WriteNameResolution(nrb NgNameResolution) error {
+	var body []byte
+	var record [4]byte
+	for _, rec := range nrb.Records {
+		var code ngNameRecordCode
+		address := rec.Address
+		switch ip := net.IP(rec.Address).To4(); {
+		case ip != nil:
+			// IPv4 addresses in their 16 byte form are written as IPv4 records
+			code = ngNameRecordIPv4
+			address = ip
+		case len(rec.Address) == 16:
+			code = ngNameRecordIPv6
+		case len(rec.Address) == 6:
+			code = ngNameRecordEUI48
+		case len(rec.Address) == 8:
+			code = ngNameRecordEUI64
+		default:
+			return fmt.Errorf("Invalid name resolution address %x", rec.Address)
+		}
+		if len(rec.Names) == 0 {
+			return fmt.Errorf("No names for name resolution address %x", rec.Address)
+		}
+		value := append([]byte(nil), address...)
+		for _, name := range rec.Names {
+			value = append(append(value, name...), 0)
+		}
+		if len(value) > 0xffff {
+			return fmt.Errorf("Too many names for name resolution address %x", rec.Address)
+		}
+		binary.LittleEndian.PutUint16(record[0:2], uint16(code))
+		binary.LittleEndian.PutUint16(record[2:4], uint16(len(value)))
+		body = append(append(body, record[:]...), value...)
+		for len(body)&3 != 0 {
+			body = append(body, 0)
+		}
+	}
+	body = append(body, 0, 0, 0, 0) // end of records
+
+	var scratch [4]ngOption
+	i := 0
+	if nrb.Comment != "" {
+		scratch[i].code = ngOptionCodeComment
+		scratch[i].raw = nrb.Comment
+		i++
+	}
+	if nrb.DNSName != "" {
+		scratch[i].code = ngOptionCodeNameResolutionDNSName
+		scratch[i].raw = nrb.DNSName
+		i++
+	}
+	if ip := nrb.DNSIPv4Address.To4(); ip != nil {
+		scratch[i].code = ngOptionCodeNameResolutionDNSIPv4Address
+		scratch[i].raw = []byte(ip)
+		i++
+	}
+	if ip := nrb.DNSIPv6Address.To16(); ip != nil {
+		scratch[i].code = ngOptionCodeNameResolutionDNSIPv6Address
+		scratch[i].raw = []byte(ip)
+		i++
+	}
+	return w.writeBlock(ngBlockTypeNameResolution, body, scratch[:i])
+}
+
+// WriteDecryptionSecrets writes a decryption secrets block. Secrets must be written before the packets they decrypt.
+func (w *NgWriter) WriteDecryptionSecrets(dsb NgDecryptionSecrets) error {
+	body := make([]byte, 8, 8+len(dsb.Data))
+	binary.LittleEndian.PutUint32(body[0:4], uint32(dsb.Type))
+	binary.LittleEndian.PutUint32(body[4:8], uint32(len(dsb.Data)))
+	body = append(body, dsb.Data...)
+
+	var scratch [1]ngOption
+	i := 0
+	if dsb.Comment != "" {
+		scratch[i].code = ngOptionCodeComment
+		scratch[i].raw = dsb.Comment
+		i++
+	}
+	return w.writeBlock(ngBlockTypeDecryptionSecrets, body, scratch[:i])
+}
+
+// WriteCustomBlock writes a custom block.
+func (w *NgWriter) WriteCustomBlock(block NgCustomBlock) error {
+	body := make([]byte, 4, 4+len(block.Data))
+	binary.LittleEndian.PutUint32(body[0:4], block.PEN)
+	body = append(body, block.Data...)
+	typ := ngBlockTypeCustomNoCopy
+	if block.Copyable {
+		typ = ngBlockTypeCustom
+	}
+	return w.writeBlock(typ, body, nil)
+}
+
+// WriteSystemdJournal writes a systemd journal export block holding the given journal entry in the Journal Export Format.
+func (w *NgWriter) WriteSystemdJournal(entry []byte) error {
+	return w.writeBlock(ngBlockTypeSystemdJournal, entry, nil)
+}
+
 // Flush writes out buffered data to the storage media. Must be called before closing the underlying file.
 func (w *NgWriter) Flush() error {
 	return w.w.Flush()
@@ -8,6 +8,9 @@ package pcapgo
 
 import (
 	"bytes"
+	"io"
+	"net"
+	"reflect"
 	"testing"
 	"time"
 
@@ -214,6 +217,155 @@ func TestNgWriteComplex(t *testing.T) {
 	ngRunFileReadTest(test, "", false, t)
 }
 
+func TestNgWriteOtherBlocks(t *testing.T) {
+	nrb := NgNameResolution{
+		Records: []NgNameResolutionRecord{
+			{Address: net.IP{10, 0, 0, 1}, Names: []string{"a.example", "alias.example"}},
+			{Address: net.ParseIP("2001:db8::1"), Names: []string{"v6.example"}},
+			{Address: net.HardwareAddr{0, 1, 2, 3, 4, 5}, Names: []string{"nic"}},
+			{Address: net.IPv4(10, 0, 0, 2), Names: []string{"mapped.example"}},
+		},
+		Comment:        "resolved",
+		DNSName:        "ns.example",
+		DNSIPv4Address: net.IP{10, 0, 0, 53},
+	}
+	dsb := NgDecryptionSecrets{
+		Type:    NgSecretsTLSKeyLog,
+		Data:    []byte("CLIENT_RANDOM 00 11\n"),
+		Comment: "keys",
+	}
+	custom := []NgCustomBlock{
+		{Copyable: true, PEN: 32473, Data: []byte{1, 2, 3, 4}},
+		{PEN: 32473, Data: []byte{5, 6, 7, 8, 9, 10, 11, 12}},
+	}
+	journal := []byte("__REALTIME_TIMESTAMP=1\nMESSAGE=hello\n\n")
+
+	buffer := &bytes.Buffer{}
+	w, err := NewNgWriter(buffer, layers.LinkTypeEthernet)
+	if err != nil {
+		t.Fatal("Opening file failed with: ", err)
+	}
+	if err := w.WriteNameResolution(nrb); err != nil {
+		t.Fatal("Couldn't write name resolution", err)
+	}
+	if err := w.WriteDecryptionSecrets(dsb); err != nil {
+		t.Fatal("Couldn't write decryption secrets", err)
+	}
+	for _, c := range custom {
+		if err := w.WriteCustomBlock(c); err != nil {
+			t.Fatal("Couldn't write custom block", err)
+		}
+	}
+	if err := w.WriteSystemdJournal(journal); err != nil {
+		t.Fatal("Couldn't write journal entry", err)
+	}
+	ci := gopacket.CaptureInfo{
+		Timestamp:     time.Unix(0, 0).UTC(),
+		Length:        len(ngPacketSource[0]),
+		CaptureLength: len(ngPacketSource[0]),
+	}
+	if err := w.WritePacket(ci, ngPacketSource[0]); err != nil {
+		t.Fatal("Couldn't write packet", err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal("Couldn't flush buffer", err)
+	}
+
+	var gotNRB []NgNameResolution
+	var gotDSB []NgDecryptionSecrets
+	var gotCustom []NgCustomBlock
+	var gotJournal [][]byte
+	r, err := NewNgReader(bytes.NewReader(buffer.Bytes()), NgReaderOptions{
+		NameResolutionCallback:    func(n NgNameResolution) { gotNRB = append(gotNRB, n) },
+		DecryptionSecretsCallback: func(d NgDecryptionSecrets) { gotDSB = append(gotDSB, d) },
+		CustomBlockCallback:       func(c NgCustomBlock) { gotCustom = append(gotCustom, c) },
+		SystemdJournalCallback:    func(j []byte) { gotJournal = append(gotJournal, j) },
+	})
+	if err != nil {
+		t.Fatal("Couldn't read file", err)
+	}
+	data, _, err := r.ReadPacketData()
+	if err != nil {
+		t.Fatal("Couldn't read packet", err)
+	}
+	if !bytes.Equal(data, ngPacketSource[0]) {
+		t.Error("Packet data mismatch")
+	}
+
+	// IPv4 addresses are read back in their 4 byte form
+	nrb.Records[3].Address = net.IP{10, 0, 0, 2}
+	if !reflect.DeepEqual(gotNRB, []NgNameResolution{nrb}) {
+		t.Errorf("Name resolution mismatch: got %+v, want %+v", gotNRB, nrb)
+	}
+	if !reflect.DeepEqual(gotDSB, []NgDecryptionSecrets{dsb}) {
+		t.Errorf("Decryption secrets mismatch: got %+v, want %+v", gotDSB, dsb)
+	}
+	if !reflect.DeepEqual(gotCustom, custom) {
+		t.Errorf("Custom blocks mismatch: got %+v, want %+v", gotCustom, custom)
+	}
+	if len(gotJournal) != 1 || !bytes.Equal(gotJournal[0], journal) {
+		t.Errorf("Journal entry mismatch: got %q, want %q", gotJournal, journal)
+	}
+}
+
+func TestNgWritePacketOptions(t *testing.T) {
+	withOptions := NgEmptyPacketOptions
+	withOptions.Comments = []string{"first", "second comment"}
+	withOptions.Flags = NgPacketFlags{
+		Direction:     NgPacketDirectionOutbound,
+		ReceptionType: NgReceptionTypeMulticast,
+		FCSLength:     4,
+		LinkErrors:    NgLinkErrorCRC | NgLinkErrorSymbol,
+	}
+	withOptions.Hashes = []NgPacketHash{{Algorithm: NgHashCRC32, Value: []byte{1, 2, 3, 4}}}
+	withOptions.DropCount = 0
+	withOptions.PacketID = 0x0102030405060708
+	onlyID := NgEmptyPacketOptions
+	onlyID.PacketID = 1
+	packets := []NgPacketOptions{withOptions, NgEmptyPacketOptions, onlyID}
+
+	buffer := &bytes.Buffer{}
+	w, err := NewNgWriter(buffer, layers.LinkTypeEthernet)
+	if err != nil {
+		t.Fatal("Opening file failed with: ", err)
+	}
+	for i, opts := range packets {
+		data := ngPacketSource[i]
+		ci := gopacket.CaptureInfo{
+			Timestamp:     time.Unix(int64(i), 0).UTC(),
+			Length:        len(data),
+			CaptureLength: len(data),
+		}
+		if err := w.WritePacketWithOptions(ci, data, opts); err != nil {
+			t.Fatal("Couldn't write packet", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal("Couldn't flush buffer", err)
+	}
+
+	r, err := NewNgReader(bytes.NewReader(buffer.Bytes()), DefaultNgReaderOptions)
+	if err != nil {
+		t.Fatal("Couldn't read file", err)
+	}
+	for i, want := range packets {
+		var opts NgPacketOptions
+		data, ci, err := r.ReadPacketDataWithOptions(&opts)
+		if err != nil {
+			t.Fatal("Couldn't read packet", err)
+		}
+		if !bytes.Equal(data, ngPacketSource[i]) || ci.Timestamp.Unix() != int64(i) {
+			t.Errorf("Packet %d mismatch", i)
+		}
+		if !reflect.DeepEqual(opts, want) {
+			t.Errorf("Packet %d options mismatch: got %+v, want %+v", i, opts, want)
+		}
+	}
+	if _, _, err := r.ReadPacketData(); err != io.EOF {
+		t.Errorf("Expected EOF, got %v", err)
+	}
+}
+
 type ngDevNull struct{}
 
 func (w *ngDevNull) Write(p []byte) (n int, err error) {
@@ -9,6 +9,7 @@ package pcapgo
 import (
 	"errors"
 	"math"
+	"net"
 	"time"
 
 	"github.com/google/gopacket"
@@ -37,6 +38,11 @@ const (
 	ngBlockTypeSimplePacket        ngBlockType = 3          // Simple packet block
 	ngBlockTypeInterfaceStatistics ngBlockType = 5          // Interface statistics block
 	ngBlockTypeEnhancedPacket      ngBlockType = 6          // Enhanced packet block
+	ngBlockTypeNameResolution      ngBlockType = 4          // Name resolution block
+	ngBlockTypeSystemdJournal      ngBlockType = 9          // Systemd journal export block
+	ngBlockTypeDecryptionSecrets   ngBlockType = 0x0A       // Decryption secrets block
+	ngBlockTypeCustom              ngBlockType = 0x00000BAD // Custom block which can be copied
+	ngBlockTypeCustomNoCopy        ngBlockType = 0x40000BAD // Custom block which must not be copied
 	ngBlockTypeSectionHeader       ngBlockType = 0x0A0D0D0A // Section header block (same in both endians)
 )
 
@@ -76,6 +82,29 @@ const (
 	ngOptionCodeInterfaceStatisticsDelivered                                 // Packets delivered to user
 )
 
+const (
+	ngOptionCodePacketFlags     ngOptionCode = iota + 2 // link layer information (direction, reception type, FCS length, errors)
+	ngOptionCodePacketHash                              // hash of the packet
+	ngOptionCodePacketDropCount                         // packets lost between this packet and the preceding one
+	ngOptionCodePacketID                                // unique identifier of the packet
+)
+
+const (
+	ngOptionCodeNameResolutionDNSName        ngOptionCode = iota + 2 // name of the DNS server
+	ngOptionCodeNameResolutionDNSIPv4Address                         // IPv4 address of the DNS server
+	ngOptionCodeNameResolutionDNSIPv6Address                         // IPv6 address of the DNS server
+)
+
+type ngNameRecordCode uint16
+
+const (
+	ngNameRecordEnd   ngNameRecordCode = iota // end of records
+	ngNameRecordIPv4                          // IPv4 address and names
+	ngNameRecordIPv6                          // IPv6 address and names
+	ngNameRecordEUI48                         // EUI-48 address and names
+	ngNameRecordEUI64                         // EUI-64 address and names
+)
+
 // ngOption is a pcapng option
 type ngOption struct {
 	code   ngOptionCode
@@ -185,3 +214,161 @@ type NgSectionInfo struct {
 	// Comment can be an arbitrary comment. This value might be empty if this option is missing.
 	Comment string
 }
+
+// NgPacketDirection is the direction of a packet stored in NgPacketFlags.
+type NgPacketDirection uint8
+
+// Packet directions.
+const (
+	NgPacketDirectionUnknown  NgPacketDirection = 0
+	NgPacketDirectionInbound  NgPacketDirection = 1
+	NgPacketDirectionOutbound NgPacketDirection = 2
+)
+
+// NgReceptionType is the way a packet was received stored in NgPacketFlags.
+type NgReceptionType uint8
+
+// Packet reception types.
+const (
+	NgReceptionTypeUnspecified NgReceptionType = 0
+	NgReceptionTypeUnicast     NgReceptionType = 1
+	NgReceptionTypeMulticast   NgReceptionType = 2
+	NgReceptionTypeBroadcast   NgReceptionType = 3
+	NgReceptionTypePromiscuous NgReceptionType = 4
+)
+
+// NgLinkErrors are the link layer errors of a packet stored in NgPacketFlags.
+type NgLinkErrors uint16
+
+// Link layer errors.
+const (
+	NgLinkErrorCRC             NgLinkErrors = 1 << 8
+	NgLinkErrorTooLong         NgLinkErrors = 1 << 9
+	NgLinkErrorTooShort        NgLinkErrors = 1 << 10
+	NgLinkErrorInterFrameGap   NgLinkErrors = 1 << 11
+	NgLinkErrorUnalignedFrame  NgLinkErrors = 1 << 12
+	NgLinkErrorStartFrameDelim NgLinkErrors = 1 << 13
+	NgLinkErrorPreamble        NgLinkErrors = 1 << 14
+	NgLinkErrorSymbol          NgLinkErrors = 1 << 15
+)
+
+// NgPacketFlags holds the link layer information of a packet (the epb_flags option). The zero value means nothing is known, and is not written.
+type NgPacketFlags struct {
+	// Direction is the direction of the packet.
+	Direction NgPacketDirection
+	// ReceptionType is the way the packet was received.
+	ReceptionType NgReceptionType
+	// FCSLength is the length in bytes of the Frame Check Sequence at the end of the packet, 0 if unknown.
+	FCSLength uint8
+	// LinkErrors are the link layer errors of the packet.
+	LinkErrors NgLinkErrors
+}
+
+func (f NgPacketFlags) toUint32() uint32 {
+	return uint32(f.Direction&0x3) | uint32(f.ReceptionType&0x7)<<2 | uint32(f.FCSLength&0xf)<<5 | uint32(f.LinkErrors)<<16
+}
+
+func ngPacketFlagsFromUint32(v uint32) NgPacketFlags {
+	return NgPacketFlags{
+		Direction:     NgPacketDirection(v & 0x3),
+		ReceptionType: NgReceptionType(v >> 2 & 0x7),
+		FCSLength:     uint8(v >> 5 & 0xf),
+		LinkErrors:    NgLinkErrors(v >> 16),
+	}
+}
+
+// NgHashAlgorithm is the algorithm of a NgPacketHash.
+type NgHashAlgorithm uint8
+
+// Hash algorithms.
+const (
+	NgHashTwosComplement NgHashAlgorithm = 0
+	NgHashXOR            NgHashAlgorithm = 1
+	NgHashCRC32          NgHashAlgorithm = 2
+	NgHashMD5            NgHashAlgorithm = 3
+	NgHashSHA1           NgHashAlgorithm = 4
+	NgHashToeplitz       NgHashAlgorithm = 5
+)
+
+// NgPacketHash is a hash of the packet data (the epb_hash option).
+type NgPacketHash struct {
+	// Algorithm is the hash algorithm.
+	Algorithm NgHashAlgorithm
+	// Value is the hash value.
+	Value []byte
+}
+
+// NgPacketOptions holds the options of a packet block.
+type NgPacketOptions struct {
+	// Comments are arbitrary comments. This value might be empty if this option is missing.
+	Comments []string
+	// Flags holds the link layer information of the packet. This value might be zero if this option is missing.
+	Flags NgPacketFlags
+	// Hashes are hashes of the packet data. This value might be empty if this option is missing.
+	Hashes []NgPacketHash
+	// DropCount is the number of packets lost between this packet and the preceding one. This value might be NgNoValue64 if this option is missing.
+	DropCount uint64
+	// PacketID identifies the packet, e.g. to match copies of the packet captured on several interfaces. This value might be NgNoValue64 if this option is missing.
+	PacketID uint64
+}
+
+// NgEmptyPacketOptions are packet options with all values missing, to be used as a base for writing packets with only some options.
+var NgEmptyPacketOptions = NgPacketOptions{
+	DropCount: NgNoValue64,
+	PacketID:  NgNoValue64,
+}
+
+// NgNameResolutionRecord maps an address to names. Address is an IPv4 or IPv6 address (as a net.IP of length 4 or 16), or a 6 or 8 bytes EUI-48 or EUI-64 hardware address (as a net.HardwareAddr).
+type NgNameResolutionRecord struct {
+	// Address is the resolved address.
+	Address []byte
+	// Names are the names of Address.
+	Names []string
+}
+
+// NgNameResolution holds the contents of a pcapng name resolution block.
+type NgNameResolution struct {
+	// Records are the name resolution records of the block.
+	Records []NgNameResolutionRecord
+	// Comment can be an arbitrary comment. This value might be empty if this option is missing.
+	Comment string
+	// DNSName is the name of the DNS server used for resolution. This value might be empty if this option is missing.
+	DNSName string
+	// DNSIPv4Address is the IPv4 address of the DNS server. This value might be nil if this option is missing.
+	DNSIPv4Address net.IP
+	// DNSIPv6Address is the IPv6 address of the DNS server. This value might be nil if this option is missing.
+	DNSIPv6Address net.IP
+}
+
+// NgSecretsType is the format of the secrets of a pcapng decryption secrets block.
+type NgSecretsType uint32
+
+// Known secrets types.
+const (
+	NgSecretsTLSKeyLog       NgSecretsType = 0x544c534b // TLS key log in NSS key log format
+	NgSecretsSSHKeyLog       NgSecretsType = 0x5353484b // SSH key log
+	NgSecretsWireGuardKeyLog NgSecretsType = 0x57474b4c // WireGuard key log
+	NgSecretsZigBeeNWKKey    NgSecretsType = 0x5a4e574b // ZigBee NWK key and PAN ID
+	NgSecretsZigBeeAPSKey    NgSecretsType = 0x5a415053 // ZigBee APS key
+	NgSecretsOPCUAKeyLog     NgSecretsType = 0x55414b4c // OPC UA key log
+)
+
+// NgDecryptionSecrets holds the contents of a pcapng decryption secrets block, e.g. TLS session keys used to decrypt the packets following it.
+type NgDecryptionSecrets struct {
+	// Type is the format of Data.
+	Type NgSecretsType
+	// Data holds the secrets.
+	Data []byte
+	// Comment can be an arbitrary comment. This value might be empty if this option is missing.
+	Comment string
+}
+
+// NgCustomBlock holds the contents of a pcapng custom block.
+type NgCustomBlock struct {
+	// Copyable is true if the block may be copied to other files, even if its contents are not understood.
+	Copyable bool
+	// PEN is the IANA Private Enterprise Number of the organization defining the block.
+	PEN uint32
+	// Data holds the custom data and options of the block, which are opaque. Data is padded to 32 bits when written, and read back with the padding.
+	Data []byte
+}
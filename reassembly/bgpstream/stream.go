@@ -0,0 +1,163 @@
+// Copyright 2026 The GoPacket Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+// Package bgpstream provides an implementation for reassembly.Stream which
+// cuts the reassembled data of a BGP session into messages.
+//
+// layers.BGP only decodes messages that are complete within the data it is
+// given, and UPDATE messages of full routing tables routinely span several
+// TCP segments.  A Stream buffers each direction until its messages are
+// complete, and passes each of them to a Handler:
+//
+//	type handler struct{}
+//	func (h *handler) Message(m *layers.BGPMessage, dir reassembly.TCPFlowDirection, ci gopacket.CaptureInfo) {
+//		if m.Update != nil {
+//			fmt.Println(len(m.Update.NLRI), "routes announced at", ci.Timestamp)
+//		}
+//	}
+//	func (h *handler) Desync(dir reassembly.TCPFlowDirection, ci gopacket.CaptureInfo, err error) {}
+//
+//	type streamFactory struct{}
+//	func (f *streamFactory) New(net, transport gopacket.Flow, tcp *layers.TCP, ac reassembly.AssemblerContext) reassembly.Stream {
+//		return bgpstream.NewStream(&handler{})
+//	}
+//
+// The OPEN messages of both speakers tell how AS numbers are encoded in the
+// UPDATE messages of the session; until both were seen, the encoding is
+// guessed from each message.  When data is missing, or does not look like
+// BGP messages, the direction is resynchronized on the next marker.
+package bgpstream
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/reassembly"
+	"github.com/google/gopacket/reassembly/internal/framing"
+)
+
+// Handler receives what a Stream decodes.
+type Handler interface {
+	// Message is called with each complete message, and the CaptureInfo of
+	// the segment holding its last byte.  The message is not reused by
+	// the Stream.
+	Message(m *layers.BGPMessage, dir reassembly.TCPFlowDirection, ci gopacket.CaptureInfo)
+	// Desync is called when data of a direction was lost or could not be
+	// decoded as BGP messages, with the CaptureInfo of the first data
+	// concerned.  Messages are then looked for again.
+	Desync(dir reassembly.TCPFlowDirection, ci gopacket.CaptureInfo, err error)
+}
+
+var errNotBGP = errors.New("bgpstream: data is not a BGP message")
+
+type direction struct {
+	messages framing.Buffer
+	// open is the OPEN message of the direction
+	open *layers.BGPOpen
+}
+
+// Stream implements reassembly.Stream, decoding the BGP messages of both
+// directions of a session.  It is not safe for concurrent use, which the
+// reassembly package does not need.
+type Stream struct {
+	handler Handler
+	dirs    [2]direction
+}
+
+// NewStream creates a stream passing the messages it decodes to h.
+func NewStream(h Handler) *Stream {
+	s := &Stream{handler: h}
+	for i, dir := range []reassembly.TCPFlowDirection{reassembly.TCPDirClientToServer, reassembly.TCPDirServerToClient} {
+		d, dir := &s.dirs[i], dir
+		d.messages = framing.Buffer{
+			Name:         "bgpstream",
+			HeaderLength: layers.BGPHeaderLength,
+			ValidHeader:  validHeader,
+			Invalid:      errNotBGP,
+			OnUnit: func(msg []byte, ci gopacket.CaptureInfo) {
+				s.decode(d, dir, msg, ci)
+			},
+			OnDesync: func(ci gopacket.CaptureInfo, err error) {
+				s.handler.Desync(dir, ci, err)
+			},
+		}
+	}
+	return s
+}
+
+func (s *Stream) direction(dir reassembly.TCPFlowDirection) *direction {
+	if dir == reassembly.TCPDirClientToServer {
+		return &s.dirs[0]
+	}
+	return &s.dirs[1]
+}
+
+// asEncoding returns the encoding of AS numbers of the session.
+func (s *Stream) asEncoding() layers.BGPASEncoding {
+	if s.dirs[0].open == nil || s.dirs[1].open == nil {
+		return layers.BGPASGuess
+	}
+	for _, d := range s.dirs {
+		if d.open.Capability(layers.BGPCapabilityFourOctetAS) == nil {
+			return layers.BGPASTwoOctet
+		}
+	}
+	return layers.BGPASFourOctet
+}
+
+// Accept implements reassembly.Stream's Accept function, accepting every
+// packet.
+func (s *Stream) Accept(tcp *layers.TCP, ci gopacket.CaptureInfo, dir reassembly.TCPFlowDirection, nextSeq reassembly.Sequence, start *bool, ac reassembly.AssemblerContext) reassembly.PacketDecision {
+	return reassembly.KeepDecision
+}
+
+// validHeader returns whether data starts with a plausible message header,
+// and the length of the message.
+func validHeader(data []byte) (int, bool) {
+	for _, b := range data[:16] {
+		if b != 0xff {
+			return 0, false
+		}
+	}
+	length := int(binary.BigEndian.Uint16(data[16:18]))
+	t := layers.BGPMessageType(data[18])
+	if length < layers.BGPHeaderLength || t < layers.BGPMessageOpen || t > layers.BGPMessageRouteRefresh {
+		return 0, false
+	}
+	return length, true
+}
+
+// ReassembledSG implements reassembly.Stream's ReassembledSG function,
+// buffering the data of its direction and decoding the messages it
+// completes.
+func (s *Stream) ReassembledSG(sg reassembly.ScatterGather, flushing bool, ac reassembly.AssemblerContext) {
+	dir, _, _, _ := sg.Info()
+	s.direction(dir).messages.Reassembled(sg)
+}
+
+// decode decodes a complete message, and passes it to the handler.
+func (s *Stream) decode(d *direction, dir reassembly.TCPFlowDirection, msg []byte, ci gopacket.CaptureInfo) {
+	bgp := &layers.BGP{}
+	if err := bgp.DecodeWithASEncoding(msg, s.asEncoding(), gopacket.NilDecodeFeedback); err != nil {
+		d.messages.Desync(ci, err)
+		return
+	}
+	m := &bgp.Messages[0]
+	if m.Open != nil {
+		d.open = m.Open
+	}
+	s.handler.Message(m, dir, ci)
+}
+
+// ReassemblyComplete implements reassembly.Stream's ReassemblyComplete
+// function.  Incomplete messages left in the buffers are dropped.
+func (s *Stream) ReassemblyComplete(ac reassembly.AssemblerContext) bool {
+	s.dirs[0].messages.Reset()
+	s.dirs[1].messages.Reset()
+	return true
+}
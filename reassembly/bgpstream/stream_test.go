@@ -0,0 +1,115 @@
+// Copyright 2026 The GoPacket Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package bgpstream
+
+import (
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/reassembly"
+	"github.com/google/gopacket/reassembly/internal/streamtest"
+)
+
+type testHandler struct {
+	messages map[reassembly.TCPFlowDirection][]*layers.BGPMessage
+	times    map[reassembly.TCPFlowDirection][]time.Time
+	desyncs  map[reassembly.TCPFlowDirection]int
+}
+
+func (h *testHandler) Message(m *layers.BGPMessage, dir reassembly.TCPFlowDirection, ci gopacket.CaptureInfo) {
+	h.messages[dir] = append(h.messages[dir], m)
+	h.times[dir] = append(h.times[dir], ci.Timestamp)
+}
+
+func (h *testHandler) Desync(dir reassembly.TCPFlowDirection, ci gopacket.CaptureInfo, err error) {
+	h.desyncs[dir]++
+}
+
+func serialize(t *testing.T, messages ...layers.BGPMessage) string {
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true}, &layers.BGP{Messages: messages}); err != nil {
+		t.Fatal(err)
+	}
+	return string(buf.Bytes())
+}
+
+func TestStream(t *testing.T) {
+	h := &testHandler{
+		messages: map[reassembly.TCPFlowDirection][]*layers.BGPMessage{},
+		times:    map[reassembly.TCPFlowDirection][]time.Time{},
+		desyncs:  map[reassembly.TCPFlowDirection]int{},
+	}
+	a := reassembly.NewAssembler(reassembly.NewStreamPool(streamtest.Factory(func() reassembly.Stream { return NewStream(h) })))
+
+	open := func(id byte) layers.BGPMessage {
+		return layers.BGPMessage{Type: layers.BGPMessageOpen, Open: &layers.BGPOpen{
+			Version:      4,
+			MyAS:         65000 + uint16(id),
+			HoldTime:     180,
+			Identifier:   net.IP{10, 0, 0, id},
+			Capabilities: []layers.BGPCapability{{Code: layers.BGPCapabilityRouteRefresh, Value: []byte{}}},
+		}}
+	}
+	keepalive := layers.BGPMessage{Type: layers.BGPMessageKeepalive}
+	// an AS_PATH which also makes sense with 4-octet AS numbers, one of
+	// 0x02010200
+	path := []layers.BGPASPathSegment{{Type: layers.BGPASSequence, ASNs: []uint32{513}}, {Type: layers.BGPASSequence, ASNs: []uint32{}}}
+	update := serialize(t, layers.BGPMessage{Type: layers.BGPMessageUpdate, Update: &layers.BGPUpdate{
+		TwoOctetAS: true,
+		PathAttributes: []layers.BGPPathAttribute{
+			{Type: layers.BGPAttributeOrigin},
+			{Type: layers.BGPAttributeASPath, ASPath: path},
+			{Type: layers.BGPAttributeNextHop, NextHop: net.IP{10, 0, 0, 2}},
+		},
+		NLRI: []layers.BGPPrefix{{IP: net.IP{198, 51, 100, 0}, Length: 24}},
+	}})
+
+	streamtest.Assemble(a, 179, time.Unix(1000, 0), streamtest.Session([]streamtest.Packet{
+		{C2S: true, Payload: serialize(t, open(1))},
+		{Payload: serialize(t, open(2), keepalive)},
+		{C2S: true, Payload: serialize(t, keepalive)},
+		// 5: an UPDATE spread over three segments
+		{Payload: update[:10]},
+		{Payload: update[10:30]},
+		{Payload: update[30:]},
+		// 8: the end of a lost message, then a KEEPALIVE
+		{C2S: true, Payload: "\x01\x02\x03\x04" + serialize(t, keepalive), Lost: 100},
+	}))
+	a.FlushAll()
+
+	c2s, s2c := reassembly.TCPDirClientToServer, reassembly.TCPDirServerToClient
+	types := func(dir reassembly.TCPFlowDirection) (ts []layers.BGPMessageType) {
+		for _, m := range h.messages[dir] {
+			ts = append(ts, m.Type)
+		}
+		return ts
+	}
+	want := []layers.BGPMessageType{layers.BGPMessageOpen, layers.BGPMessageKeepalive, layers.BGPMessageKeepalive}
+	if got := types(c2s); !reflect.DeepEqual(got, want) || h.desyncs[c2s] != 1 {
+		t.Errorf("client messages %v, %d desyncs", got, h.desyncs[c2s])
+	}
+	want = []layers.BGPMessageType{layers.BGPMessageOpen, layers.BGPMessageKeepalive, layers.BGPMessageUpdate}
+	if got := types(s2c); !reflect.DeepEqual(got, want) || h.desyncs[s2c] != 0 {
+		t.Fatalf("server messages %v, %d desyncs", got, h.desyncs[s2c])
+	}
+	if ts := h.times[s2c][2]; !ts.Equal(time.Unix(1007, 0)) {
+		t.Errorf("UPDATE completed at %v", ts)
+	}
+
+	// neither speaker advertised the 4-octet AS number capability
+	u := h.messages[s2c][2].Update
+	if !u.TwoOctetAS || !reflect.DeepEqual(u.Attribute(layers.BGPAttributeASPath).ASPath, path) || len(u.NLRI) != 1 {
+		t.Errorf("UPDATE: %+v", u)
+	}
+	if as := h.messages[c2s][0].Open.AS(); as != 65001 {
+		t.Errorf("client AS %d", as)
+	}
+}
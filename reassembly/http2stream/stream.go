@@ -0,0 +1,458 @@
+// Copyright 2026 The GoPacket Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+// Package http2stream provides an implementation for reassembly.Stream which
+// decodes the frames of cleartext HTTP/2 connections, and their headers.
+//
+// Header blocks are compressed with HPACK, whose dynamic tables live as long
+// as the connection: they can only be decoded by following each direction
+// from its start.  A Stream cuts the reassembled data of both directions
+// into frames decoded as layers.HTTP2Frame, keeps an HPACK decoder per
+// direction, and passes the frames and the header blocks of HEADERS and
+// PUSH_PROMISE frames, continuations included, to a Handler:
+//
+//	type handler struct{}
+//	func (h *handler) Frame(f *layers.HTTP2Frame, dir reassembly.TCPFlowDirection, ci gopacket.CaptureInfo) {}
+//	func (h *handler) Headers(hs *http2stream.Headers) {
+//		fmt.Println(hs.StreamID, hs.Get(":method"), hs.Get(":path"), hs.Get(":status"))
+//	}
+//	func (h *handler) Error(dir reassembly.TCPFlowDirection, err error) {}
+//
+// Connections are recognized from the client connection preface, for
+// clients with prior knowledge of HTTP/2, or from an HTTP/1.1 request
+// upgraded with "Upgrade: h2c" and its 101 Switching Protocols response.
+// The headers of an upgraded request are those of stream 1.
+package http2stream
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/http2/hpack"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/reassembly"
+)
+
+// Headers is a decoded header block.
+type Headers struct {
+	Direction   reassembly.TCPFlowDirection
+	CaptureInfo gopacket.CaptureInfo
+	StreamID    uint32
+	// PromisedStreamID is the stream reserved by a PUSH_PROMISE frame, 0
+	// for HEADERS frames.
+	PromisedStreamID uint32
+	// EndStream is set when the HEADERS frame ends its stream.
+	EndStream bool
+	// Upgrade is set for the headers of an HTTP/1.1 request upgraded to
+	// HTTP/2, which are given pseudo-header fields like the others.
+	Upgrade bool
+	Fields  []hpack.HeaderField
+}
+
+// Get returns the value of the first field called name, or "".  Names of
+// HTTP/2 fields are lowercase.
+func (h *Headers) Get(name string) string {
+	for _, f := range h.Fields {
+		if f.Name == name {
+			return f.Value
+		}
+	}
+	return ""
+}
+
+// Handler receives what a Stream decodes, in the order of each direction.
+type Handler interface {
+	// Frame is called with each frame, and the CaptureInfo of the
+	// segment holding its last byte.  The frame is not reused by the
+	// Stream.
+	Frame(f *layers.HTTP2Frame, dir reassembly.TCPFlowDirection, ci gopacket.CaptureInfo)
+	// Headers is called with each complete header block, after the
+	// Frame call of its last frame.
+	Headers(h *Headers)
+	// Error is called when a direction can no longer be followed, because
+	// data was lost or is invalid.  The rest of its data is ignored.
+	Error(dir reassembly.TCPFlowDirection, err error)
+}
+
+// maxUpgradeHeaderSize is the longest HTTP/1.1 header parsed before an
+// upgrade.
+const maxUpgradeHeaderSize = 64 * 1024
+
+// Bounds of SETTINGS_MAX_FRAME_SIZE, from RFC 7540 section 6.5.2.
+const (
+	defaultMaxFrameSize = 1 << 14
+	maxMaxFrameSize     = 1<<24 - 1
+)
+
+// defaultMaxHeaderBlockSize is the longest header block buffered, unless
+// the peer advertises a larger SETTINGS_MAX_HEADER_LIST_SIZE.  Longer blocks
+// are an error, which keeps endless CONTINUATION frames from using up
+// memory.
+const defaultMaxHeaderBlockSize = 64 * 1024
+
+type state uint8
+
+const (
+	stateStart state = iota
+	// the client sent an upgrade request, the server has yet to answer
+	stateUpgrading
+	// the client waits for the 101 response to send the preface
+	statePreface
+	stateFrames
+	stateDone
+)
+
+type direction struct {
+	dir   reassembly.TCPFlowDirection
+	state state
+	buf   []byte
+	hpack *hpack.Decoder
+	// skip is the length of a body following an upgrade request
+	skip int64
+	// maxFrameSize is the largest frame payload the peer accepts
+	maxFrameSize int
+	// maxHeaderBlockSize is the longest header block buffered
+	maxHeaderBlockSize int
+
+	// the header block being received
+	block     []byte
+	blockHead *Headers
+}
+
+// Stream implements reassembly.Stream, decoding the HTTP/2 frames of both
+// directions of a connection.
+type Stream struct {
+	handler Handler
+	dirs    [2]direction
+}
+
+// NewStream creates a stream passing what it decodes to h.
+func NewStream(h Handler) *Stream {
+	s := &Stream{handler: h}
+	s.dirs[0].dir = reassembly.TCPDirClientToServer
+	s.dirs[1].dir = reassembly.TCPDirServerToClient
+	for i := range s.dirs {
+		s.dirs[i].hpack = hpack.NewDecoder(4096, nil)
+		s.dirs[i].maxFrameSize = defaultMaxFrameSize
+		s.dirs[i].maxHeaderBlockSize = defaultMaxHeaderBlockSize
+	}
+	return s
+}
+
+// Accept implements reassembly.Stream's Accept function, accepting every
+// packet.
+func (s *Stream) Accept(tcp *layers.TCP, ci gopacket.CaptureInfo, dir reassembly.TCPFlowDirection, nextSeq reassembly.Sequence, start *bool, ac reassembly.AssemblerContext) reassembly.PacketDecision {
+	return reassembly.KeepDecision
+}
+
+// ReassembledSG implements reassembly.Stream's ReassembledSG function,
+// decoding the frames completed by the data of its direction.
+func (s *Stream) ReassembledSG(sg reassembly.ScatterGather, flushing bool, ac reassembly.AssemblerContext) {
+	dir, start, _, skip := sg.Info()
+	d, peer := &s.dirs[0], &s.dirs[1]
+	if dir == reassembly.TCPDirServerToClient {
+		d, peer = peer, d
+	}
+	if d.state == stateDone {
+		return
+	}
+	if !start && skip != 0 {
+		if skip < 0 {
+			s.fail(d, errors.New("http2stream: start of stream missing"))
+		} else {
+			s.fail(d, fmt.Errorf("http2stream: %d bytes missing", skip))
+		}
+		return
+	}
+	length, _ := sg.Lengths()
+	if length == 0 {
+		return
+	}
+
+	base := len(d.buf)
+	d.buf = append(d.buf, sg.Fetch(length)...)
+	off := 0
+	ci := func(end int) gopacket.CaptureInfo {
+		// end is the offset in d.buf following the last byte
+		if end-1 < base {
+			return sg.CaptureInfo(0)
+		}
+		return sg.CaptureInfo(end - 1 - base)
+	}
+	for d.state != stateDone {
+		n := s.parse(d, peer, d.buf[off:], func(end int) gopacket.CaptureInfo { return ci(off + end) })
+		if n == 0 {
+			break
+		}
+		off += n
+	}
+	if d.state == stateDone {
+		d.buf = nil
+		return
+	}
+	d.buf = append(d.buf[:0], d.buf[off:]...)
+}
+
+// ReassemblyComplete implements reassembly.Stream's ReassemblyComplete
+// function.
+func (s *Stream) ReassemblyComplete(ac reassembly.AssemblerContext) bool {
+	return true
+}
+
+func (s *Stream) fail(d *direction, err error) {
+	d.state = stateDone
+	d.buf = nil
+	d.block = nil
+	d.blockHead = nil
+	s.handler.Error(d.dir, err)
+}
+
+var errNotHTTP2 = errors.New("http2stream: not an HTTP/2 connection")
+
+// parse parses the start of data, returning how much of it was consumed, 0
+// if more data is needed.  ci returns the CaptureInfo of the byte before an
+// offset of data.
+func (s *Stream) parse(d, peer *direction, data []byte, ci func(int) gopacket.CaptureInfo) int {
+	client := d.dir == reassembly.TCPDirClientToServer
+	switch d.state {
+	case stateStart, statePreface:
+		if len(data) < len(layers.HTTP2Preface) && strings.HasPrefix(layers.HTTP2Preface, string(data)) {
+			return 0
+		}
+		if client && strings.HasPrefix(string(data), layers.HTTP2Preface) {
+			d.state = stateFrames
+			return len(layers.HTTP2Preface)
+		}
+		if d.state == statePreface {
+			s.fail(d, errNotHTTP2)
+			return 0
+		}
+		if !client {
+			if strings.HasPrefix(string(data), "HTTP/") || strings.HasPrefix("HTTP/", string(data)) {
+				return s.upgradeResponse(d, peer, data)
+			}
+			// the SETTINGS frame of the server connection preface
+			d.state = stateFrames
+			return s.parse(d, peer, data, ci)
+		}
+		return s.upgradeRequest(d, data, ci)
+
+	case stateUpgrading:
+		if d.skip > 0 {
+			n := int64(len(data))
+			if n > d.skip {
+				n = d.skip
+			}
+			d.skip -= n
+			return int(n)
+		}
+		// the client sends its preface once the upgrade is accepted
+		d.state = statePreface
+		return s.parse(d, peer, data, ci)
+
+	case stateFrames:
+		if len(data) < layers.HTTP2FrameHeaderLength {
+			return 0
+		}
+		length := int(data[0])<<16 | int(data[1])<<8 | int(data[2])
+		if length > d.maxFrameSize {
+			s.fail(d, fmt.Errorf("http2stream: %d bytes frame, larger than SETTINGS_MAX_FRAME_SIZE %d", length, d.maxFrameSize))
+			return 0
+		}
+		n := layers.HTTP2FrameHeaderLength + length
+		if len(data) < n {
+			return 0
+		}
+		// frames are copied out of the buffer, since the handler may
+		// keep them
+		var h layers.HTTP2
+		if err := h.DecodeFromBytes(append([]byte(nil), data[:n]...), gopacket.NilDecodeFeedback); err != nil {
+			s.fail(d, err)
+			return 0
+		} else if len(h.Frames) != 1 {
+			s.fail(d, errNotHTTP2)
+			return 0
+		}
+		s.frame(d, peer, &h.Frames[0], ci(n))
+		return n
+	}
+	return 0
+}
+
+// upgradeRequest parses the HTTP/1.1 request a client may start with.
+func (s *Stream) upgradeRequest(d *direction, data []byte, ci func(int) gopacket.CaptureInfo) int {
+	n := layers.HTTPHeaderLength(data)
+	if n < 0 {
+		if len(data) > maxUpgradeHeaderSize {
+			s.fail(d, errNotHTTP2)
+		}
+		return 0
+	}
+	var req layers.HTTP
+	if err := req.DecodeFromBytes(data[:n], gopacket.NilDecodeFeedback); err != nil || req.IsResponse {
+		s.fail(d, errNotHTTP2)
+		return 0
+	}
+	upgrade := false
+	for _, v := range strings.Split(req.Header("Upgrade"), ",") {
+		if strings.TrimSpace(v) == "h2c" {
+			upgrade = true
+		}
+	}
+	settings, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(req.Header("HTTP2-Settings"), "="))
+	if !upgrade || err != nil {
+		s.fail(d, errNotHTTP2)
+		return 0
+	}
+
+	// HTTP2-Settings holds the payload of a SETTINGS frame of the client
+	frame := append([]byte{byte(len(settings) >> 16), byte(len(settings) >> 8), byte(len(settings)),
+		byte(layers.HTTP2FrameSettings), 0, 0, 0, 0, 0}, settings...)
+	var h layers.HTTP2
+	if err := h.DecodeFromBytes(frame, gopacket.NilDecodeFeedback); err != nil {
+		s.fail(d, err)
+		return 0
+	}
+	s.settings(&s.dirs[1], &h.Frames[0])
+
+	authority := req.Header("Host")
+	if u := req.RequestURI; strings.HasPrefix(u, "http://") {
+		// absolute form
+		u = u[len("http://"):]
+		if i := strings.IndexByte(u, '/'); i >= 0 {
+			authority, req.RequestURI = u[:i], u[i:]
+		} else {
+			authority, req.RequestURI = u, "/"
+		}
+	}
+	fields := []hpack.HeaderField{
+		{Name: ":method", Value: req.Method},
+		{Name: ":scheme", Value: "http"},
+		{Name: ":authority", Value: authority},
+		{Name: ":path", Value: req.RequestURI},
+	}
+	for _, f := range req.Headers {
+		name := strings.ToLower(f.Name)
+		switch name {
+		case "host", "connection", "upgrade", "http2-settings", "keep-alive", "proxy-connection", "transfer-encoding":
+			// connection-specific, not carried over HTTP/2
+			continue
+		}
+		fields = append(fields, hpack.HeaderField{Name: name, Value: f.Value})
+	}
+	length, _ := req.ContentLength()
+	s.handler.Headers(&Headers{
+		Direction:   d.dir,
+		CaptureInfo: ci(n),
+		StreamID:    1,
+		EndStream:   length == 0,
+		Upgrade:     true,
+		Fields:      fields,
+	})
+	d.state = stateUpgrading
+	d.skip = length
+	return n
+}
+
+// upgradeResponse parses the HTTP/1.1 response to an upgrade request.
+func (s *Stream) upgradeResponse(d, peer *direction, data []byte) int {
+	n := layers.HTTPHeaderLength(data)
+	if n < 0 {
+		if len(data) > maxUpgradeHeaderSize {
+			s.fail(d, errNotHTTP2)
+		}
+		return 0
+	}
+	var resp layers.HTTP
+	if err := resp.DecodeFromBytes(data[:n], gopacket.NilDecodeFeedback); err != nil || resp.StatusCode != 101 {
+		// the upgrade was declined: the connection goes on in HTTP/1.1
+		s.fail(d, errNotHTTP2)
+		if peer.state != stateDone {
+			s.fail(peer, errNotHTTP2)
+		}
+		return 0
+	}
+	d.state = stateFrames
+	return n
+}
+
+// frame handles a frame of d.
+func (s *Stream) frame(d, peer *direction, f *layers.HTTP2Frame, ci gopacket.CaptureInfo) {
+	s.handler.Frame(f, d.dir, ci)
+
+	if d.blockHead != nil && f.Type != layers.HTTP2FrameContinuation {
+		s.fail(d, fmt.Errorf("http2stream: %s frame in a header block", f.Type))
+		return
+	}
+	switch f.Type {
+	case layers.HTTP2FrameSettings:
+		if !f.Has(layers.HTTP2FlagAck) {
+			s.settings(peer, f)
+		}
+		return
+	case layers.HTTP2FrameHeaders, layers.HTTP2FramePushPromise:
+		d.blockHead = &Headers{
+			Direction:        d.dir,
+			StreamID:         f.StreamID,
+			PromisedStreamID: f.PromisedStreamID,
+			EndStream:        f.Type == layers.HTTP2FrameHeaders && f.Has(layers.HTTP2FlagEndStream),
+		}
+		d.block = append(d.block[:0], f.HeaderBlockFragment...)
+	case layers.HTTP2FrameContinuation:
+		if d.blockHead == nil || d.blockHead.StreamID != f.StreamID {
+			s.fail(d, errors.New("http2stream: unexpected CONTINUATION frame"))
+			return
+		}
+		if len(d.block)+len(f.HeaderBlockFragment) > d.maxHeaderBlockSize {
+			s.fail(d, fmt.Errorf("http2stream: header block longer than %d bytes", d.maxHeaderBlockSize))
+			return
+		}
+		d.block = append(d.block, f.HeaderBlockFragment...)
+	default:
+		return
+	}
+	if !f.Has(layers.HTTP2FlagEndHeaders) {
+		return
+	}
+
+	h := d.blockHead
+	d.blockHead = nil
+	fields, err := d.hpack.DecodeFull(d.block)
+	if err != nil {
+		// the dynamic table is out of sync for good
+		s.fail(d, err)
+		return
+	}
+	h.CaptureInfo = ci
+	h.Fields = fields
+	s.handler.Headers(h)
+}
+
+// settings applies the SETTINGS frame a peer of d sent, which tells how
+// large the HPACK dynamic table, the frames and the header blocks of d may
+// be.
+func (s *Stream) settings(d *direction, f *layers.HTTP2Frame) {
+	for _, setting := range f.Settings {
+		switch setting.ID {
+		case layers.HTTP2SettingHeaderTableSize:
+			d.hpack.SetAllowedMaxDynamicTableSize(setting.Value)
+		case layers.HTTP2SettingMaxFrameSize:
+			if setting.Value >= defaultMaxFrameSize && setting.Value <= maxMaxFrameSize {
+				d.maxFrameSize = int(setting.Value)
+			}
+		case layers.HTTP2SettingMaxHeaderListSize:
+			// the limit is advisory: blocks up to the default are
+			// still accepted
+			if setting.Value > defaultMaxHeaderBlockSize && setting.Value <= maxMaxFrameSize {
+				d.maxHeaderBlockSize = int(setting.Value)
+			}
+		}
+	}
+}
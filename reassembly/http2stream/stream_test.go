@@ -0,0 +1,214 @@
+// Copyright 2026 The GoPacket Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package http2stream
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/reassembly"
+	"github.com/google/gopacket/reassembly/internal/streamtest"
+)
+
+type testHandler struct {
+	frames  map[reassembly.TCPFlowDirection][]layers.HTTP2FrameType
+	data    []*layers.HTTP2Frame
+	headers []*Headers
+	errors  map[reassembly.TCPFlowDirection]error
+}
+
+func (h *testHandler) Frame(f *layers.HTTP2Frame, dir reassembly.TCPFlowDirection, ci gopacket.CaptureInfo) {
+	h.frames[dir] = append(h.frames[dir], f.Type)
+	if f.Type == layers.HTTP2FrameData {
+		h.data = append(h.data, f)
+	}
+}
+
+func (h *testHandler) Headers(hs *Headers) {
+	h.headers = append(h.headers, hs)
+}
+
+func (h *testHandler) Error(dir reassembly.TCPFlowDirection, err error) {
+	h.errors[dir] = err
+}
+
+// run passes the packets of a connection between 1.2.3.4:1234 and
+// 5.6.7.8:80, after the SYNs of both sides, to a stream.
+func run(packets []streamtest.Packet) *testHandler {
+	h := &testHandler{
+		frames: map[reassembly.TCPFlowDirection][]layers.HTTP2FrameType{},
+		errors: map[reassembly.TCPFlowDirection]error{},
+	}
+	a := reassembly.NewAssembler(reassembly.NewStreamPool(streamtest.Factory(func() reassembly.Stream { return NewStream(h) })))
+	streamtest.Assemble(a, 80, time.Unix(1000, 0), streamtest.Session(packets))
+	a.FlushAll()
+	return h
+}
+
+// frame returns a frame with a payload given in hex.
+func frame(t layers.HTTP2FrameType, flags layers.HTTP2Flags, stream uint32, payload string) string {
+	p, err := hex.DecodeString(strings.Replace(payload, " ", "", -1))
+	if err != nil {
+		panic(err)
+	}
+	return string([]byte{byte(len(p) >> 16), byte(len(p) >> 8), byte(len(p)), byte(t), byte(flags),
+		byte(stream >> 24), byte(stream >> 16), byte(stream >> 8), byte(stream)}) + string(p)
+}
+
+func TestStream(t *testing.T) {
+	endHeaders := layers.HTTP2FlagEndHeaders
+	// the header blocks of the requests of RFC 7541 appendix C.4, with
+	// Huffman coding and a growing dynamic table
+	req1 := frame(layers.HTTP2FrameHeaders, endHeaders|layers.HTTP2FlagEndStream, 1, "8286 8441 8cf1 e3c2 e5f2 3a6b a0ab 90f4 ff")
+	req2 := frame(layers.HTTP2FrameHeaders, endHeaders|layers.HTTP2FlagEndStream, 3, "8286 84be 5886 a8eb 1064 9cbf")
+	req3 := frame(layers.HTTP2FrameHeaders, layers.HTTP2FlagEndStream, 5, "8287 85bf 4088 25a8 49e9 5ba9 7d7f")
+	req3c := frame(layers.HTTP2FrameContinuation, endHeaders, 5, "8925 a849 e95b b8e8 b4bf")
+	settings := frame(layers.HTTP2FrameSettings, 0, 0, "0001 0000 1000 0004 0001 0000")
+	// :status 200, then a 5 bytes padded DATA frame
+	resp := frame(layers.HTTP2FrameHeaders, endHeaders, 1, "88") +
+		frame(layers.HTTP2FrameData, layers.HTTP2FlagEndStream|layers.HTTP2FlagPadded, 1, "02 68656c6c6f 0000")
+
+	client := layers.HTTP2Preface + settings + req1
+	h := run([]streamtest.Packet{
+		{C2S: true, Payload: client[:10]},
+		{C2S: true, Payload: client[10:]},
+		{Payload: settings + resp[:12]},
+		{Payload: resp[12:]},
+		{C2S: true, Payload: req2 + req3},
+		{C2S: true, Payload: req3c + frame(layers.HTTP2FrameRSTStream, 0, 5, "00000008")},
+	})
+
+	if len(h.errors) != 0 {
+		t.Errorf("errors: %v", h.errors)
+	}
+	c2s, s2c := reassembly.TCPDirClientToServer, reassembly.TCPDirServerToClient
+	wantFrames := map[reassembly.TCPFlowDirection][]layers.HTTP2FrameType{
+		c2s: {layers.HTTP2FrameSettings, layers.HTTP2FrameHeaders, layers.HTTP2FrameHeaders, layers.HTTP2FrameHeaders,
+			layers.HTTP2FrameContinuation, layers.HTTP2FrameRSTStream},
+		s2c: {layers.HTTP2FrameSettings, layers.HTTP2FrameHeaders, layers.HTTP2FrameData},
+	}
+	for dir, want := range wantFrames {
+		if got := h.frames[dir]; len(got) != len(want) {
+			t.Errorf("%v frames: got %v, want %v", dir, got, want)
+		} else {
+			for i := range want {
+				if got[i] != want[i] {
+					t.Errorf("%v frame %d: got %v, want %v", dir, i, got[i], want[i])
+				}
+			}
+		}
+	}
+
+	if len(h.headers) != 4 {
+		t.Fatalf("got %d header blocks", len(h.headers))
+	}
+	for i, want := range []struct {
+		dir      reassembly.TCPFlowDirection
+		stream   uint32
+		field    string
+		value    string
+		captured int64
+	}{
+		{c2s, 1, ":authority", "www.example.com", 1003},
+		{s2c, 1, ":status", "200", 1004},
+		{c2s, 3, "cache-control", "no-cache", 1006},
+		{c2s, 5, "custom-key", "custom-value", 1007},
+	} {
+		hs := h.headers[i]
+		if hs.Direction != want.dir || hs.StreamID != want.stream || hs.Get(want.field) != want.value ||
+			hs.CaptureInfo.Timestamp.Unix() != want.captured {
+			t.Errorf("header block %d: %+v", i, hs)
+		}
+	}
+	if hs := h.headers[3]; hs.Get(":path") != "/index.html" || hs.Get(":authority") != "www.example.com" || !hs.EndStream {
+		t.Errorf("dynamic table entries: %+v", hs)
+	}
+}
+
+func TestStreamUpgrade(t *testing.T) {
+	h := run([]streamtest.Packet{
+		{C2S: true, Payload: "GET /index.html HTTP/1.1\r\nHost: example.com\r\nConnection: Upgrade, HTTP2-Settings\r\n" +
+			"Upgrade: h2c\r\nHTTP2-Settings: AAMAAABkAARAAAAAAAIAAAAA\r\nAccept: */*\r\n\r\n"},
+		{Payload: "HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: h2c\r\n\r\n" +
+			frame(layers.HTTP2FrameSettings, 0, 0, "") + frame(layers.HTTP2FrameHeaders, layers.HTTP2FlagEndHeaders, 1, "88")},
+		{C2S: true, Payload: layers.HTTP2Preface + frame(layers.HTTP2FrameSettings, 0, 0, "") + frame(layers.HTTP2FrameSettings, layers.HTTP2FlagAck, 0, "")},
+	})
+	if len(h.errors) != 0 {
+		t.Errorf("errors: %v", h.errors)
+	}
+	if len(h.headers) != 2 {
+		t.Fatalf("got %d header blocks", len(h.headers))
+	}
+	req := h.headers[0]
+	if !req.Upgrade || req.StreamID != 1 || !req.EndStream || req.Get(":method") != "GET" || req.Get(":path") != "/index.html" ||
+		req.Get(":authority") != "example.com" || req.Get("accept") != "*/*" || req.Get("upgrade") != "" {
+		t.Errorf("upgraded request: %+v", req)
+	}
+	if resp := h.headers[1]; resp.StreamID != 1 || resp.Get(":status") != "200" {
+		t.Errorf("response: %+v", resp)
+	}
+	if len(h.frames[reassembly.TCPDirClientToServer]) != 2 {
+		t.Errorf("client frames: %v", h.frames[reassembly.TCPDirClientToServer])
+	}
+
+	// declined upgrade
+	h = run([]streamtest.Packet{
+		{C2S: true, Payload: "GET / HTTP/1.1\r\nHost: example.com\r\nUpgrade: h2c\r\nHTTP2-Settings: \r\n\r\n"},
+		{Payload: "HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n"},
+		{C2S: true, Payload: "GET /again HTTP/1.1\r\nHost: example.com\r\n\r\n"},
+	})
+	if len(h.errors) != 2 || len(h.headers) != 1 {
+		t.Errorf("declined upgrade: errors %v, %d header blocks", h.errors, len(h.headers))
+	}
+}
+
+func TestStreamMaxFrameSize(t *testing.T) {
+	// the client accepts frames of up to 32768 bytes, the server keeps
+	// the default of 16384
+	big := frame(layers.HTTP2FrameData, 0, 1, strings.Repeat("61", 20000))
+	h := run([]streamtest.Packet{
+		{C2S: true, Payload: layers.HTTP2Preface + frame(layers.HTTP2FrameSettings, 0, 0, "0005 00008000")},
+		{Payload: frame(layers.HTTP2FrameSettings, 0, 0, "") + big[:10000]},
+		{Payload: big[10000:] + frame(layers.HTTP2FrameData, 0, 1, "62")[:5]},
+		{Payload: frame(layers.HTTP2FrameData, 0, 1, "62")[5:]},
+		{C2S: true, Payload: frame(layers.HTTP2FrameData, 0, 1, strings.Repeat("63", 16385))[:100]},
+	})
+	c2s, s2c := reassembly.TCPDirClientToServer, reassembly.TCPDirServerToClient
+	if h.errors[c2s] == nil || h.errors[s2c] != nil {
+		t.Errorf("errors: %v", h.errors)
+	}
+	// frames passed to the handler stay valid once the buffer moved on
+	if len(h.data) != 2 || string(h.data[0].Data) != strings.Repeat("a", 20000) || string(h.data[1].Data) != "b" {
+		t.Errorf("DATA frames: %v", h.data)
+	}
+}
+
+func TestStreamContinuationFlood(t *testing.T) {
+	// CONTINUATION frames without END_HEADERS, past the default limit
+	// of the header block
+	continuation := frame(layers.HTTP2FrameContinuation, 0, 1, strings.Repeat("00", 16384))
+	packets := []streamtest.Packet{{C2S: true, Payload: layers.HTTP2Preface + frame(layers.HTTP2FrameHeaders, 0, 1, "82")}}
+	for i := 0; i < 5; i++ {
+		packets = append(packets, streamtest.Packet{C2S: true, Payload: continuation})
+	}
+	h := run(packets)
+	c2s := reassembly.TCPDirClientToServer
+	if h.errors[c2s] == nil || len(h.frames[c2s]) != 5 {
+		t.Errorf("errors %v, frames %v", h.errors, h.frames[c2s])
+	}
+
+	// a larger SETTINGS_MAX_HEADER_LIST_SIZE of the server raises it
+	packets = append([]streamtest.Packet{{Payload: frame(layers.HTTP2FrameSettings, 0, 0, "0006 00020000")}}, packets...)
+	h = run(packets)
+	if h.errors[c2s] != nil || len(h.frames[c2s]) != 6 {
+		t.Errorf("errors %v, frames %v", h.errors, h.frames[c2s])
+	}
+}
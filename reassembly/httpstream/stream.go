@@ -0,0 +1,529 @@
+// Copyright 2026 The GoPacket Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+// Package httpstream provides an implementation for reassembly.Stream which
+// parses the HTTP/1.0 and HTTP/1.1 messages of a connection, and pairs
+// requests with their responses.
+//
+// A Stream decodes the messages of each direction as layers.HTTP, following
+// their framing over any number of TCP segments: Content-Length and chunked
+// bodies, responses delimited by the end of the connection, responses to
+// HEAD requests without a body, and pipelined requests.  Informational
+// responses such as 100 Continue are attached to the request they answer.
+// Each request and its response make a Transaction, passed to a Handler
+// once complete:
+//
+//	type handler struct{}
+//	func (h *handler) Transaction(t *httpstream.Transaction) {
+//		if t.Request != nil && t.Response != nil {
+//			fmt.Println(t.Request.Method, t.Request.RequestURI, t.Response.StatusCode,
+//				t.ResponseEnd.Sub(t.RequestStart))
+//		}
+//	}
+//
+//	type streamFactory struct{}
+//	func (f *streamFactory) New(net, transport gopacket.Flow, tcp *layers.TCP, ac reassembly.AssemblerContext) reassembly.Stream {
+//		return httpstream.NewStream(&handler{}, httpstream.DefaultOptions)
+//	}
+//
+// After a 101 Switching Protocols response, or a successful response to a
+// CONNECT request, the connection no longer carries HTTP/1.x messages and
+// the rest of its data is ignored.
+package httpstream
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/reassembly"
+)
+
+// Options provides options for a Stream.
+type Options struct {
+	// MaxHeaderSize is the longest start line and header section parsed;
+	// a direction sending a longer one is considered not to be HTTP until
+	// it seems to start a new message.
+	MaxHeaderSize int
+	// MaxBodySize is the number of bytes of each body kept in the Payload
+	// of messages.  Longer bodies are still followed, and their whole
+	// length is given in Transaction.
+	MaxBodySize int
+}
+
+// DefaultOptions provides default options for a Stream.
+var DefaultOptions = Options{
+	MaxHeaderSize: 64 * 1024,
+	MaxBodySize:   1024 * 1024,
+}
+
+// maxLineSize is the longest chunk size line parsed.
+const maxLineSize = 1024
+
+// Transaction is a request and its response.
+type Transaction struct {
+	// Request is nil for responses to requests sent before the start of
+	// the capture, or lost.
+	Request *layers.HTTP
+	// Interim are the informational responses to the request, such as
+	// 100 Continue, sent before Response.
+	Interim []*layers.HTTP
+	// Response is nil if the connection ended without one.
+	Response *layers.HTTP
+
+	// The timestamps of the segments holding the first and the last byte
+	// of the request and of the response.
+	RequestStart, RequestEnd   time.Time
+	ResponseStart, ResponseEnd time.Time
+
+	// RequestBodyLength and ResponseBodyLength are the lengths of the
+	// bodies, without the chunked transfer coding.  They may be longer
+	// than the Payload of the messages, see Options.MaxBodySize.
+	RequestBodyLength, ResponseBodyLength int64
+
+	// Err tells why the request or the response is incomplete, if they
+	// are: because data was lost, or the connection ended.
+	Err error
+
+	requestDone, responseDone bool
+}
+
+// Handler receives the transactions of a Stream.
+type Handler interface {
+	// Transaction is called with each transaction once its request and
+	// response are complete, or cannot be, in the order of the requests.
+	Transaction(t *Transaction)
+}
+
+type state uint8
+
+const (
+	stateIdle state = iota
+	stateHead
+	stateBody
+	stateChunkSize
+	stateChunkData
+	stateChunkEnd
+	stateTrailer
+	stateUntilClose
+	stateLost
+	stateTunnel
+)
+
+var (
+	errClosed  = errors.New("httpstream: connection closed in a message")
+	errInvalid = errors.New("httpstream: invalid message framing")
+)
+
+// parser follows the messages of a direction.
+type parser struct {
+	response bool
+	state    state
+	buf      []byte
+
+	msg        *layers.HTTP
+	txn        *Transaction
+	start      time.Time
+	last       time.Time
+	body       []byte
+	bodyLength int64
+	// remaining is the length of the rest of a body or chunk
+	remaining int64
+}
+
+// Stream implements reassembly.Stream, parsing the HTTP messages of both
+// directions of a connection.  The client is the side the reassembly
+// package sees as the client: the sender of the first SYN.
+type Stream struct {
+	options Options
+	handler Handler
+	// requests, then responses
+	dirs    [2]parser
+	pending []*Transaction
+}
+
+// NewStream creates a stream passing transactions to h.
+func NewStream(h Handler, options Options) *Stream {
+	s := &Stream{options: options, handler: h}
+	s.dirs[1].response = true
+	return s
+}
+
+// Accept implements reassembly.Stream's Accept function, accepting every
+// packet.
+func (s *Stream) Accept(tcp *layers.TCP, ci gopacket.CaptureInfo, dir reassembly.TCPFlowDirection, nextSeq reassembly.Sequence, start *bool, ac reassembly.AssemblerContext) reassembly.PacketDecision {
+	return reassembly.KeepDecision
+}
+
+// ReassembledSG implements reassembly.Stream's ReassembledSG function,
+// parsing the data of its direction.
+func (s *Stream) ReassembledSG(sg reassembly.ScatterGather, flushing bool, ac reassembly.AssemblerContext) {
+	dir, start, end, skip := sg.Info()
+	p := &s.dirs[0]
+	if dir == reassembly.TCPDirServerToClient {
+		p = &s.dirs[1]
+	}
+	if !start && skip != 0 {
+		s.gap(p, skip)
+	}
+	length, _ := sg.Lengths()
+	if length > 0 {
+		data := sg.Fetch(length)
+		for pos := 0; pos < len(data); {
+			pos += s.parse(p, data[pos:], func(i int) time.Time {
+				return sg.CaptureInfo(pos + i).Timestamp
+			})
+		}
+	}
+	if end {
+		s.end(p)
+	}
+}
+
+// ReassemblyComplete implements reassembly.Stream's ReassemblyComplete
+// function, passing on the transactions left.
+func (s *Stream) ReassemblyComplete(ac reassembly.AssemblerContext) bool {
+	s.end(&s.dirs[0])
+	s.end(&s.dirs[1])
+	for _, t := range s.pending {
+		s.handler.Transaction(t)
+	}
+	s.pending = nil
+	return true
+}
+
+// parse parses the start of data, returning how much of it was consumed.
+// timestamp returns the timestamp of the segment of a byte of data.
+func (s *Stream) parse(p *parser, data []byte, timestamp func(int) time.Time) int {
+	switch p.state {
+	case stateTunnel:
+		return len(data)
+
+	case stateLost:
+		i := resync(data, p.response)
+		if i < 0 {
+			return len(data)
+		}
+		p.state = stateIdle
+		return i
+
+	case stateIdle:
+		// empty lines may precede a message
+		i := 0
+		for i < len(data) && (data[i] == '\r' || data[i] == '\n') {
+			i++
+		}
+		if i < len(data) {
+			p.state = stateHead
+			p.start = timestamp(i)
+		}
+		return i
+
+	case stateHead:
+		from := len(p.buf) - 3
+		if from < 0 {
+			from = 0
+		}
+		old := len(p.buf)
+		p.buf = append(p.buf, data...)
+		n := layers.HTTPHeaderLength(p.buf[from:])
+		if n < 0 {
+			if len(p.buf) > s.options.MaxHeaderSize {
+				s.lose(p, errInvalid)
+			}
+			return len(data)
+		}
+		n += from
+		head := append([]byte(nil), p.buf[:n]...)
+		p.buf = p.buf[:0]
+		s.head(p, head, timestamp(n-old-1))
+		return n - old
+
+	case stateBody, stateChunkData, stateUntilClose:
+		n := len(data)
+		if p.state != stateUntilClose && int64(n) > p.remaining {
+			n = int(p.remaining)
+		}
+		p.addBody(data[:n], s.options.MaxBodySize)
+		p.last = timestamp(n - 1)
+		p.remaining -= int64(n)
+		switch {
+		case p.state == stateBody && p.remaining == 0:
+			s.done(p)
+		case p.state == stateChunkData && p.remaining == 0:
+			p.state = stateChunkEnd
+		}
+		return n
+
+	case stateChunkSize, stateChunkEnd:
+		line, n := p.line(data)
+		if line == nil {
+			if len(p.buf) > maxLineSize {
+				s.lose(p, errInvalid)
+			}
+			return n
+		}
+		if p.state == stateChunkEnd {
+			if len(line) != 0 {
+				s.lose(p, errInvalid)
+				return n
+			}
+			p.state = stateChunkSize
+			return n
+		}
+		size, err := layers.ParseHTTPChunkSize(line)
+		if err != nil {
+			s.lose(p, err)
+			return n
+		}
+		p.last = timestamp(n - 1)
+		if size == 0 {
+			p.state = stateTrailer
+		} else {
+			p.state = stateChunkData
+			p.remaining = size
+		}
+		return n
+
+	case stateTrailer:
+		old := len(p.buf)
+		p.buf = append(p.buf, data...)
+		var end int
+		switch {
+		case p.buf[0] == '\n':
+			end = 1
+		case p.buf[0] == '\r' && len(p.buf) == 1:
+			return len(data)
+		case p.buf[0] == '\r' && p.buf[1] == '\n':
+			end = 2
+		default:
+			end = layers.HTTPHeaderLength(p.buf)
+			if end < 0 {
+				if len(p.buf) > s.options.MaxHeaderSize {
+					s.lose(p, errInvalid)
+				}
+				return len(data)
+			}
+			trailers, err := layers.ParseHTTPHeaders(p.buf[:end])
+			if err != nil {
+				s.lose(p, err)
+				return len(data)
+			}
+			p.msg.Trailers = trailers
+		}
+		p.buf = p.buf[:0]
+		p.last = timestamp(end - old - 1)
+		s.done(p)
+		return end - old
+	}
+	panic("httpstream: unknown parser state")
+}
+
+// line returns the next line of data, prefixed with the part buffered
+// before and without its line ending, and how much of data it takes.  It
+// returns a nil line, buffering data, if the line is not complete.
+func (p *parser) line(data []byte) ([]byte, int) {
+	eol := bytes.IndexByte(data, '\n')
+	if eol < 0 {
+		p.buf = append(p.buf, data...)
+		return nil, len(data)
+	}
+	line := append(p.buf, data[:eol]...)
+	p.buf = p.buf[:0]
+	return bytes.TrimSuffix(line, []byte("\r")), eol + 1
+}
+
+func (p *parser) addBody(data []byte, max int) {
+	p.bodyLength += int64(len(data))
+	if room := max - len(p.body); room < len(data) {
+		if room <= 0 {
+			return
+		}
+		data = data[:room]
+	}
+	p.body = append(p.body, data...)
+}
+
+// resync returns the index of the first line of data which looks like a
+// start line, or -1.
+func resync(data []byte, response bool) int {
+	for i := 0; i < len(data); {
+		rest := data[i:]
+		if response {
+			if bytes.HasPrefix(rest, []byte("HTTP/1.")) {
+				return i
+			}
+		} else {
+			for _, m := range methods {
+				if bytes.HasPrefix(rest, []byte(m)) && len(rest) > len(m) && rest[len(m)] == ' ' {
+					return i
+				}
+			}
+		}
+		eol := bytes.IndexByte(rest, '\n')
+		if eol < 0 {
+			break
+		}
+		i += eol + 1
+	}
+	return -1
+}
+
+var methods = []string{"GET", "POST", "HEAD", "PUT", "DELETE", "OPTIONS", "PATCH", "CONNECT", "TRACE"}
+
+// head handles the header of a message, ending at the given time.
+func (s *Stream) head(p *parser, head []byte, end time.Time) {
+	msg := &layers.HTTP{}
+	if err := msg.DecodeFromBytes(head, gopacket.NilDecodeFeedback); err != nil {
+		s.lose(p, err)
+		return
+	}
+	msg.Contents = head
+	p.msg = msg
+	p.last = end
+	p.body = nil
+	p.bodyLength = 0
+
+	var method string
+	if !p.response {
+		p.txn = &Transaction{Request: msg, RequestStart: p.start}
+		s.pending = append(s.pending, p.txn)
+	} else {
+		p.txn = nil
+		for _, t := range s.pending {
+			if t.Response == nil {
+				p.txn = t
+				break
+			}
+		}
+		if p.txn == nil {
+			p.txn = &Transaction{requestDone: true}
+			s.pending = append(s.pending, p.txn)
+		}
+		if p.txn.Request != nil {
+			method = p.txn.Request.Method
+		}
+		if msg.StatusCode < 200 && msg.StatusCode != 101 {
+			p.txn.Interim = append(p.txn.Interim, msg)
+			p.msg = nil
+			p.state = stateIdle
+			return
+		}
+		p.txn.Response = msg
+		p.txn.ResponseStart = p.start
+	}
+
+	length, hasLength := msg.ContentLength()
+	switch {
+	case !msg.HasBody(method), hasLength && length == 0:
+		s.done(p)
+	case msg.Chunked():
+		p.state = stateChunkSize
+	case hasLength:
+		p.state = stateBody
+		p.remaining = length
+	case p.response:
+		p.state = stateUntilClose
+	default:
+		s.done(p)
+	}
+}
+
+// done ends the current message of p.
+func (s *Stream) done(p *parser) {
+	msg, t := p.msg, p.txn
+	msg.BaseLayer.Payload = p.body
+	if !p.response {
+		t.RequestEnd = p.last
+		t.RequestBodyLength = p.bodyLength
+		t.requestDone = true
+	} else {
+		t.ResponseEnd = p.last
+		t.ResponseBodyLength = p.bodyLength
+		t.responseDone = true
+	}
+	p.msg, p.txn, p.body = nil, nil, nil
+	p.state = stateIdle
+
+	if p.response {
+		method := ""
+		if t.Request != nil {
+			method = t.Request.Method
+		}
+		if msg.StatusCode == 101 || (method == "CONNECT" && msg.StatusCode < 300) {
+			s.dirs[0].state = stateTunnel
+			s.dirs[1].state = stateTunnel
+		}
+	}
+	s.flush()
+}
+
+// flush passes on the transactions complete at the front of the queue.
+func (s *Stream) flush() {
+	for len(s.pending) > 0 && s.pending[0].requestDone && s.pending[0].responseDone {
+		s.handler.Transaction(s.pending[0])
+		s.pending[0] = nil
+		s.pending = s.pending[1:]
+	}
+}
+
+// lose gives up on the current message of p, which is left looking for the
+// start of the next one.
+func (s *Stream) lose(p *parser, err error) {
+	p.buf = p.buf[:0]
+	p.state = stateLost
+	if p.msg == nil {
+		return
+	}
+	p.txn.Err = err
+	s.done(p)
+	p.state = stateLost
+}
+
+// gap handles skip bytes missing before the next data of p.
+func (s *Stream) gap(p *parser, skip int) {
+	var err error
+	if skip < 0 {
+		err = errors.New("httpstream: start of stream missing")
+	} else {
+		err = fmt.Errorf("httpstream: %d bytes missing", skip)
+	}
+	switch p.state {
+	case stateTunnel:
+	case stateBody, stateChunkData:
+		if skip >= 0 && int64(skip) < p.remaining {
+			// the message goes on after the missing part of its body
+			p.txn.Err = err
+			p.bodyLength += int64(skip)
+			p.remaining -= int64(skip)
+			return
+		}
+		s.lose(p, err)
+	case stateUntilClose:
+		p.txn.Err = err
+		if skip > 0 {
+			p.bodyLength += int64(skip)
+		}
+	default:
+		s.lose(p, err)
+	}
+}
+
+// end handles the end of the data of p.
+func (s *Stream) end(p *parser) {
+	switch p.state {
+	case stateUntilClose:
+		s.done(p)
+	case stateHead, stateBody, stateChunkSize, stateChunkData, stateChunkEnd, stateTrailer:
+		s.lose(p, errClosed)
+	}
+	if p.state != stateTunnel {
+		p.state = stateLost
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright 2026 The GoPacket Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package httpstream
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/gopacket/reassembly"
+	"github.com/google/gopacket/reassembly/internal/streamtest"
+)
+
+type testHandler struct {
+	transactions []*Transaction
+}
+
+func (h *testHandler) Transaction(t *Transaction) {
+	h.transactions = append(h.transactions, t)
+}
+
+func run(packets []streamtest.Packet, options Options) []*Transaction {
+	h := &testHandler{}
+	a := reassembly.NewAssembler(reassembly.NewStreamPool(streamtest.Factory(func() reassembly.Stream {
+		return NewStream(h, options)
+	})))
+	streamtest.Assemble(a, 80, time.Unix(1000, 0), streamtest.Session(packets))
+	a.FlushAll()
+	return h.transactions
+}
+
+func at(s int) time.Time {
+	return time.Unix(1000+int64(s), 0)
+}
+
+func TestStream(t *testing.T) {
+	txns := run([]streamtest.Packet{
+		// 2: pipelined requests
+		{C2S: true, Payload: "GET /a HTTP/1.1\r\nHost: x\r\n\r\nHEAD /b HTTP/1.1\r\nHost: x\r\n\r\n"},
+		{Payload: "HTTP/1.1 200 OK\r\nContent-Length: 3\r\n\r\nab"},
+		{Payload: "c"},
+		// 5: no body after HEAD, whatever the Content-Length
+		{Payload: "HTTP/1.1 200 OK\r\nContent-Length: 100\r\n\r\n"},
+		// 6: 100-continue
+		{C2S: true, Payload: "POST /c HTTP/1.1\r\nExpect: 100-continue\r\nContent-Length: 5\r\n\r\n"},
+		{Payload: "HTTP/1.1 100 Continue\r\n\r\n"},
+		{C2S: true, Payload: "hel"},
+		{C2S: true, Payload: "lo"},
+		// 10: chunked response with a trailer
+		{Payload: "HTTP/1.1 201 Created\r\nTransfer-Encoding: chunked\r\n\r\n3\r\nabc\r\n"},
+		{Payload: "2\r\nde\r\n0\r\nX-Trailer: 1\r\n\r\n"},
+		// 12: response delimited by the end of the connection
+		{C2S: true, Payload: "GET /d HTTP/1.0\r\n\r\n"},
+		{Payload: "HTTP/1.0 200 OK\r\n\r\nuntil"},
+		{Payload: " close", FIN: true},
+	}, DefaultOptions)
+
+	if len(txns) != 4 {
+		t.Fatalf("got %d transactions", len(txns))
+	}
+	for i, want := range []struct {
+		method, uri string
+		status      int
+		body        string
+		times       [4]int
+	}{
+		{"GET", "/a", 200, "abc", [4]int{2, 2, 3, 4}},
+		{"HEAD", "/b", 200, "", [4]int{2, 2, 5, 5}},
+		{"POST", "/c", 201, "abcde", [4]int{6, 9, 10, 11}},
+		{"GET", "/d", 200, "until close", [4]int{12, 12, 13, 14}},
+	} {
+		txn := txns[i]
+		if txn.Err != nil || txn.Request == nil || txn.Response == nil {
+			t.Errorf("transaction %d: %+v", i, txn)
+			continue
+		}
+		if txn.Request.Method != want.method || txn.Request.RequestURI != want.uri || txn.Response.StatusCode != want.status {
+			t.Errorf("transaction %d: %s %s %d", i, txn.Request.Method, txn.Request.RequestURI, txn.Response.StatusCode)
+		}
+		if string(txn.Response.Payload()) != want.body || txn.ResponseBodyLength != int64(len(want.body)) {
+			t.Errorf("transaction %d: response body %q, %d bytes", i, txn.Response.Payload(), txn.ResponseBodyLength)
+		}
+		times := [4]time.Time{txn.RequestStart, txn.RequestEnd, txn.ResponseStart, txn.ResponseEnd}
+		for j := range times {
+			if !times[j].Equal(at(want.times[j])) {
+				t.Errorf("transaction %d: times %v, want %v", i, times, want.times)
+				break
+			}
+		}
+	}
+	if post := txns[2]; len(post.Interim) != 1 || post.Interim[0].StatusCode != 100 || string(post.Request.Payload()) != "hello" {
+		t.Errorf("100-continue transaction: %+v", post)
+	} else if len(post.Response.Trailers) != 1 || post.Response.Trailers[0].Name != "X-Trailer" {
+		t.Errorf("trailers: %+v", post.Response.Trailers)
+	}
+}
+
+func TestStreamGaps(t *testing.T) {
+	txns := run([]streamtest.Packet{
+		{C2S: true, Payload: "POST /a HTTP/1.1\r\nContent-Length: 10\r\n\r\n01"},
+		// missing body bytes do not lose track of the messages
+		{C2S: true, Payload: "89GET /b HTTP/1.1\r\n\r\n", Lost: 6},
+		{Payload: "HTTP/1.1 204 No Content\r\n\r\nHTTP/1.1 404 Not Found\r\nContent-Length: 4\r\n\r\nno"},
+		// lost in a body: wait for the next response
+		{C2S: true, Payload: "GET /c HTTP/1.1\r\n\r\n"},
+		{Payload: "x\r\nHTTP/1.1 304 Not Modified\r\n\r\n", Lost: 10},
+		// the end of the connection is missing
+		{C2S: true, Payload: "GET /d HTTP/1.1\r\n\r\n"},
+		{Payload: "HTTP/1.1 200 OK\r\nContent-Length: 10\r\n\r\n0123"},
+	}, Options{MaxHeaderSize: 1024, MaxBodySize: 4})
+
+	if len(txns) != 4 {
+		t.Fatalf("got %d transactions", len(txns))
+	}
+	if txn := txns[0]; txn.Err == nil || txn.RequestBodyLength != 10 || string(txn.Request.Payload()) != "0189" ||
+		txn.Response == nil || txn.Response.StatusCode != 204 {
+		t.Errorf("first transaction: %+v", txn)
+	}
+	if txn := txns[1]; txn.Err == nil || txn.Response == nil || txn.Response.StatusCode != 404 {
+		t.Errorf("second transaction: %+v", txn)
+	}
+	if txn := txns[2]; txn.Err != nil || txn.Response == nil || txn.Response.StatusCode != 304 {
+		t.Errorf("third transaction: %+v", txn)
+	}
+	if txn := txns[3]; txn.Err == nil || txn.Request.RequestURI != "/d" || txn.Response == nil || txn.ResponseBodyLength != 4 {
+		t.Errorf("fourth transaction: %+v", txn)
+	}
+}
+
+func TestStreamUpgrade(t *testing.T) {
+	txns := run([]streamtest.Packet{
+		{C2S: true, Payload: "GET /ws HTTP/1.1\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n"},
+		{Payload: "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\n\r\n\x81\x05hello"},
+		{C2S: true, Payload: "GET /not-http HTTP/1.1\r\n\r\n"},
+	}, DefaultOptions)
+	if len(txns) != 1 || txns[0].Response == nil || txns[0].Response.StatusCode != 101 || txns[0].Err != nil {
+		t.Errorf("transactions: %+v", txns)
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright 2026 The GoPacket Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+// Package framing cuts the reassembled data of a direction of a connection
+// into units whose header tells their length, like TLS records or BGP
+// messages, for the reassembly.Stream implementations of the reassembly
+// packages.
+package framing
+
+import (
+	"fmt"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/reassembly"
+)
+
+// Buffer buffers the data of a direction until its units are complete.
+// When data is missing, or does not start with a valid header, the
+// direction is resynchronized on the next valid header.
+type Buffer struct {
+	// Name prefixes the errors passed to OnDesync.
+	Name string
+	// HeaderLength is the length of the headers of units.
+	HeaderLength int
+	// ValidHeader returns whether data, HeaderLength bytes or more,
+	// starts with a plausible header, and the length of the unit.
+	ValidHeader func(data []byte) (int, bool)
+	// Invalid is the error passed to OnDesync for data which does not
+	// start with a valid header.
+	Invalid error
+	// OnUnit is called with each complete unit, and the CaptureInfo of
+	// the segment holding its last byte.  The unit is copied out of the
+	// buffer, so it may be kept.
+	OnUnit func(unit []byte, ci gopacket.CaptureInfo)
+	// OnDesync is called when data was lost or is invalid, with the
+	// CaptureInfo of the first data concerned.  It is not called again
+	// until a valid header is found.
+	OnDesync func(ci gopacket.CaptureInfo, err error)
+
+	buf []byte
+	// lost is set while looking for a header after a desync
+	lost bool
+}
+
+// Reassembled buffers the data of sg, and passes the units it completes to
+// OnUnit.
+func (b *Buffer) Reassembled(sg reassembly.ScatterGather) {
+	_, start, _, skip := sg.Info()
+	length, _ := sg.Lengths()
+	if !start && skip != 0 {
+		var err error
+		if skip < 0 {
+			err = fmt.Errorf("%s: start of stream missing", b.Name)
+		} else {
+			err = fmt.Errorf("%s: %d bytes missing", b.Name, skip)
+		}
+		b.buf = b.buf[:0]
+		b.Desync(sg.CaptureInfo(0), err)
+	}
+	if length == 0 {
+		return
+	}
+
+	// data of sg starts at base in b.buf
+	base := len(b.buf)
+	b.buf = append(b.buf, sg.Fetch(length)...)
+	off := 0
+	for len(b.buf)-off >= b.HeaderLength {
+		n, ok := b.ValidHeader(b.buf[off:])
+		if b.lost {
+			if !ok {
+				off++
+				continue
+			}
+			b.lost = false
+		} else if !ok {
+			b.Desync(sg.CaptureInfo(max(off-base, 0)), b.Invalid)
+			off++
+			continue
+		}
+		if len(b.buf)-off < n {
+			break
+		}
+		unit := append([]byte(nil), b.buf[off:off+n]...)
+		off += n
+		b.OnUnit(unit, sg.CaptureInfo(max(off-1-base, 0)))
+	}
+	if b.lost && len(b.buf)-off >= b.HeaderLength {
+		// keep what may be the start of the next header
+		off = len(b.buf) - (b.HeaderLength - 1)
+	}
+	b.buf = append(b.buf[:0], b.buf[off:]...)
+}
+
+// Desync makes b look for the next valid header, calling OnDesync unless it
+// already is.  It is called for units which fail to decode.
+func (b *Buffer) Desync(ci gopacket.CaptureInfo, err error) {
+	if b.lost {
+		return
+	}
+	b.lost = true
+	b.OnDesync(ci, err)
+}
+
+// Reset drops the buffered data.
+func (b *Buffer) Reset() {
+	b.buf = nil
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,94 @@
+// Copyright 2026 The GoPacket Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+// Package streamtest passes hand-written packets of a TCP connection to a
+// reassembly.Assembler, for the tests of the reassembly.Stream
+// implementations of the reassembly packages.
+package streamtest
+
+import (
+	"net"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/reassembly"
+)
+
+// Factory is a reassembly.StreamFactory returning the streams of a
+// function.
+type Factory func() reassembly.Stream
+
+// New returns f().
+func (f Factory) New(netFlow, tcpFlow gopacket.Flow, tcp *layers.TCP, ac reassembly.AssemblerContext) reassembly.Stream {
+	return f()
+}
+
+// Context is the AssemblerContext of the packets.
+type Context gopacket.CaptureInfo
+
+// GetCaptureInfo returns c as a CaptureInfo.
+func (c *Context) GetCaptureInfo() gopacket.CaptureInfo {
+	return gopacket.CaptureInfo(*c)
+}
+
+// Packet is a packet of a connection.
+type Packet struct {
+	// C2S is set for packets from the client to the server.
+	C2S      bool
+	Seq      uint32
+	SYN, FIN bool
+	Payload  string
+	// Lost is the number of bytes lost before the packet.
+	Lost uint32
+}
+
+// Session returns packets after the SYNs of both sides, with sequence
+// numbers following each other from 100 for the client and 500 for the
+// server, past the bytes lost before packets.
+func Session(packets []Packet) []Packet {
+	seq := map[bool]uint32{true: 100, false: 500}
+	packets = append([]Packet{{C2S: true, SYN: true}, {C2S: false, SYN: true}}, packets...)
+	for i := range packets {
+		p := &packets[i]
+		seq[p.C2S] += p.Lost
+		p.Seq = seq[p.C2S]
+		seq[p.C2S] += uint32(len(p.Payload))
+		if p.SYN || p.FIN {
+			seq[p.C2S]++
+		}
+	}
+	return packets
+}
+
+// Assemble passes the packets of a connection between 1.2.3.4:1234 and
+// 5.6.7.8:port to a, with timestamps one second apart from ts.  Packets
+// following lost bytes are flushed right away.
+func Assemble(a *reassembly.Assembler, port int, ts time.Time, packets []Packet) {
+	client, server := net.IP{1, 2, 3, 4}, net.IP{5, 6, 7, 8}
+	for i, p := range packets {
+		src, dst, sport, dport := client, server, 1234, port
+		if !p.C2S {
+			src, dst, sport, dport = server, client, port, 1234
+		}
+		netFlow, _ := gopacket.FlowFromEndpoints(layers.NewIPEndpoint(src), layers.NewIPEndpoint(dst))
+		tcp := &layers.TCP{
+			SrcPort:   layers.TCPPort(sport),
+			DstPort:   layers.TCPPort(dport),
+			Seq:       p.Seq,
+			SYN:       p.SYN,
+			FIN:       p.FIN,
+			BaseLayer: layers.BaseLayer{Payload: []byte(p.Payload)},
+		}
+		tcp.SetInternalPortsForTesting()
+		ctx := Context(gopacket.CaptureInfo{Timestamp: ts.Add(time.Duration(i) * time.Second)})
+		a.AssembleWithContext(netFlow, tcp, &ctx)
+		if p.Lost > 0 {
+			// stop waiting for the lost bytes
+			a.FlushWithOptions(reassembly.FlushOptions{T: ctx.Timestamp.Add(time.Second)})
+		}
+	}
+}
@@ -9,9 +9,12 @@ package reassembly
 import (
 	"flag"
 	"log"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/google/gopacket"
 	"github.com/google/gopacket/layers"
 )
 
@@ -23,6 +26,9 @@ var memLog = flag.Bool("assembly_memuse_log", defaultDebug, "If true, the github
 // pageCache is a concurrency-unsafe store of page objects we use to avoid
 // memory allocation as much as we can.
 type pageCache struct {
+	// buffered is the byte counter of the StreamPool, if any, updated as
+	// pages are used and replaced
+	buffered     *int64
 	free         []*page
 	pcSize       int
 	size, used   int
@@ -33,21 +39,25 @@ type pageCache struct {
 
 const initialAllocSize = 1024
 
-func newPageCache() *pageCache {
+// newPageCache creates a page cache, accounting the bytes of used pages in
+// buffered if not nil.
+func newPageCache(buffered *int64) *pageCache {
 	pc := &pageCache{
-		free:   make([]*page, 0, initialAllocSize),
-		pcSize: initialAllocSize,
+		buffered: buffered,
+		free:     make([]*page, 0, initialAllocSize),
+		pcSize:   initialAllocSize,
 	}
 	pc.grow()
 	return pc
 }
 
 // grow exponentially increases the size of our page cache as much as necessary.
+// Pages are allocated one by one, so that pages dropped by tryShrink can be
+// garbage collected once traffic spikes are over.
 func (c *pageCache) grow() {
-	pages := make([]page, c.pcSize)
 	c.size += c.pcSize
-	for i := range pages {
-		c.free = append(c.free, &pages[i])
+	for i := 0; i < c.pcSize; i++ {
+		c.free = append(c.free, new(page))
 	}
 	if *memLog {
 		log.Println("PageCache: created", c.pcSize, "new pages, size:", c.size, "cap:", cap(c.free), "len:", len(c.free))
@@ -59,10 +69,11 @@ func (c *pageCache) grow() {
 	c.pcSize *= 2
 }
 
-// Remove references to unused pages to let GC collect them
+// Remove references to unused pages to let GC collect them, keeping as many
+// free pages as used ones.
 // Note: memory used by c.free itself it not collected.
 func (c *pageCache) tryShrink() {
-	var min = c.pcSize / 2
+	var min = c.used
 	if min < initialAllocSize {
 		min = initialAllocSize
 	}
@@ -93,6 +104,9 @@ func (c *pageCache) next(ts time.Time) (p *page) {
 	p.seen = ts
 	p.bytes = p.buf[:0]
 	c.used++
+	if c.buffered != nil {
+		atomic.AddInt64(c.buffered, pageBytes)
+	}
 	if *memLog {
 		log.Printf("allocator returns %s\n", p)
 	}
@@ -108,12 +122,20 @@ func (c *pageCache) next(ts time.Time) (p *page) {
 // replace replaces a page into the pageCache.
 func (c *pageCache) replace(p *page) {
 	c.used--
+	if c.buffered != nil {
+		atomic.AddInt64(c.buffered, -pageBytes)
+	}
 	if *memLog {
 		log.Printf("replacing %s\n", p)
 	}
 	p.prev = nil
 	p.next = nil
 	c.free = append(c.free, p)
+	c.ops++
+	if c.ops > c.nextShrink {
+		c.ops = 0
+		c.tryShrink()
+	}
 }
 
 /*
@@ -135,7 +157,17 @@ func (c *pageCache) replace(p *page) {
 // Like the Assembler, StreamPool attempts to minimize allocation.  Unlike the
 // Assembler, though, it does have to do some locking to make sure that the
 // connection objects it stores are accessible to multiple Assemblers.
+//
+// A StreamPool created with NewStreamPoolWithOptions also limits the
+// memory and the number of connections of its Assemblers, evicting
+// connections chosen by its EvictionPolicy.
 type StreamPool struct {
+	// accessed atomically, first to be 64-bit aligned
+	bufferedBytes      int64
+	evictedBytes       int64
+	evictedConnections int64
+
+	options            StreamPoolOptions
 	conns              map[key]*connection
 	users              int
 	mu                 sync.RWMutex
@@ -144,6 +176,19 @@ type StreamPool struct {
 	all                [][]connection
 	nextAlloc          int
 	newConnectionCount int64
+
+	// evictMu protects the list of connections in assembly order, from
+	// lruHead to lruTail, kept when evicting the least recently seen
+	// connections, and the candidates to evict chosen by the last scan
+	// of the connections.  It is never held while locking a connection.
+	evictMu          sync.Mutex
+	lruHead, lruTail *connection
+	candidates       [2][]evictionCandidate
+}
+
+type evictionCandidate struct {
+	conn *connection
+	key  key
 }
 
 func (p *StreamPool) grow() {
@@ -178,6 +223,9 @@ func (p *StreamPool) remove(conn *connection) {
 	p.mu.Lock()
 	if _, ok := p.conns[conn.key]; ok {
 		delete(p.conns, conn.key)
+		p.evictMu.Lock()
+		p.lruRemove(conn)
+		p.evictMu.Unlock()
 		p.free = append(p.free, conn)
 	}
 	p.mu.Unlock()
@@ -186,7 +234,15 @@ func (p *StreamPool) remove(conn *connection) {
 // NewStreamPool creates a new connection pool.  Streams will
 // be created as necessary using the passed-in StreamFactory.
 func NewStreamPool(factory StreamFactory) *StreamPool {
+	return NewStreamPoolWithOptions(factory, StreamPoolOptions{})
+}
+
+// NewStreamPoolWithOptions creates a new connection pool with the given
+// limits.  Streams will be created as necessary using the passed-in
+// StreamFactory.
+func NewStreamPoolWithOptions(factory StreamFactory, options StreamPoolOptions) *StreamPool {
 	return &StreamPool{
+		options:   options,
 		conns:     make(map[key]*connection, initialAllocSize),
 		free:      make([]*connection, 0, initialAllocSize),
 		factory:   factory,
@@ -194,6 +250,257 @@ func NewStreamPool(factory StreamFactory) *StreamPool {
 	}
 }
 
+// StreamPoolOptions limits the resources used by the connections of a
+// StreamPool, shared by all its Assemblers.
+type StreamPoolOptions struct {
+	// MaxBufferedBytes is an upper limit on the memory used to buffer
+	// out-of-order data, accounted in pages of about 2KB.  Once this
+	// limit is exceeded, the out-of-order data of connections chosen by
+	// Eviction is flushed to their Streams, skipping the missing data,
+	// until the memory used is below the limit.  If <= 0, this is ignored.
+	MaxBufferedBytes int
+	// MaxConnections is an upper limit on the number of connections.  A
+	// new connection beyond this limit evicts the connection chosen by
+	// Eviction: its data is flushed and it is closed.  If <= 0, this is
+	// ignored.
+	MaxConnections int
+	// Eviction chooses the connections to evict.  If nil,
+	// EvictLeastRecentlySeen is used.
+	Eviction EvictionPolicy
+}
+
+// ConnectionInfo describes a connection considered for eviction.
+type ConnectionInfo struct {
+	NetFlow, TCPFlow gopacket.Flow
+	// LastSeen is the timestamp of the last packet of the connection
+	LastSeen time.Time
+	// BufferedBytes is the memory used by the out-of-order data of the
+	// connection
+	BufferedBytes int
+}
+
+// EvictionPolicy orders connections for eviction: it returns true if
+// connection a should be evicted before connection b.
+//
+// Connections are not kept in the order of a policy, since the fields of
+// ConnectionInfo change with every packet.  Instead, a scan of all the
+// connections of the StreamPool chooses the next few to evict, about one in
+// sixteen, so that eviction costs O(log N) amortized.  The connections
+// evicted are the first in the order of the policy at the time of the scan.
+type EvictionPolicy func(a, b ConnectionInfo) bool
+
+var (
+	// EvictLeastRecentlySeen evicts the connection whose last packet is
+	// the oldest.  It is nil, the default EvictionPolicy: the StreamPool
+	// keeps its connections in the order their packets are assembled in,
+	// and evicts the first one in constant time.  Out-of-order data is
+	// flushed from the connections with the oldest LastSeen, chosen as
+	// with other policies.
+	EvictLeastRecentlySeen EvictionPolicy
+	// EvictLargestBuffer evicts the connection buffering the most
+	// out-of-order data, the least recently seen one among equals.
+	EvictLargestBuffer EvictionPolicy = func(a, b ConnectionInfo) bool {
+		if a.BufferedBytes != b.BufferedBytes {
+			return a.BufferedBytes > b.BufferedBytes
+		}
+		return a.LastSeen.Before(b.LastSeen)
+	}
+)
+
+// StreamPoolStats provides some figures for a StreamPool.
+type StreamPoolStats struct {
+	// Connections is the number of connections currently tracked
+	Connections int
+	// BufferedBytes is the memory currently used by out-of-order data
+	BufferedBytes int
+	// EvictedConnections is the number of connections closed because of
+	// MaxConnections
+	EvictedConnections int
+	// EvictedBytes is the number of out-of-order bytes flushed because of
+	// MaxBufferedBytes
+	EvictedBytes int
+}
+
+// Stats returns the current figures of the pool.
+func (p *StreamPool) Stats() StreamPoolStats {
+	return StreamPoolStats{
+		Connections:        p.GetRemainingConnectionCount(),
+		BufferedBytes:      int(atomic.LoadInt64(&p.bufferedBytes)),
+		EvictedConnections: int(atomic.LoadInt64(&p.evictedConnections)),
+		EvictedBytes:       int(atomic.LoadInt64(&p.evictedBytes)),
+	}
+}
+
+// overBudget reports whether the memory used by out-of-order data exceeds
+// MaxBufferedBytes.
+func (p *StreamPool) overBudget() bool {
+	return p.options.MaxBufferedBytes > 0 && atomic.LoadInt64(&p.bufferedBytes) > int64(p.options.MaxBufferedBytes)
+}
+
+// full reports whether a new connection with key k would exceed
+// MaxConnections.
+func (p *StreamPool) full(k key) bool {
+	if p.options.MaxConnections <= 0 {
+		return false
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if conn, _, _ := p.getHalf(k); conn != nil {
+		return false
+	}
+	return len(p.conns) >= p.options.MaxConnections
+}
+
+// evictionCandidate returns the connection to evict first according to
+// the pool's EvictionPolicy, among those with out-of-order data if
+// withPages is set, and the key it had when chosen.  It returns nil if
+// there is none.
+func (p *StreamPool) evictionCandidate(withPages bool) (*connection, key) {
+	p.evictMu.Lock()
+	if !withPages && p.options.Eviction == nil && p.lruHead != nil {
+		conn := p.lruHead
+		p.evictMu.Unlock()
+		return conn, conn.key
+	}
+	i := 0
+	if withPages {
+		i = 1
+	}
+	if n := len(p.candidates[i]); n != 0 {
+		c := p.candidates[i][0]
+		p.candidates[i] = p.candidates[i][1:]
+		p.evictMu.Unlock()
+		return c.conn, c.key
+	}
+	p.evictMu.Unlock()
+
+	candidates := p.scanEvictionCandidates(withPages)
+	if len(candidates) == 0 {
+		return nil, key{}
+	}
+	p.evictMu.Lock()
+	p.candidates[i] = candidates[1:]
+	p.evictMu.Unlock()
+	return candidates[0].conn, candidates[0].key
+}
+
+// scanEvictionCandidates returns the first connections to evict in the
+// order of the pool's EvictionPolicy, about one in sixteen, among those
+// with out-of-order data if withPages is set.
+func (p *StreamPool) scanEvictionCandidates(withPages bool) []evictionCandidate {
+	less := p.options.Eviction
+	if less == nil {
+		less = func(a, b ConnectionInfo) bool {
+			return a.LastSeen.Before(b.LastSeen)
+		}
+	}
+	p.mu.RLock()
+	candidates := make([]evictionCandidate, 0, len(p.conns))
+	for k, conn := range p.conns {
+		candidates = append(candidates, evictionCandidate{conn, k})
+	}
+	p.mu.RUnlock()
+
+	infos := make([]ConnectionInfo, 0, len(candidates))
+	n := 0
+	for _, c := range candidates {
+		conn := c.conn
+		conn.mu.Lock()
+		ci := ConnectionInfo{
+			NetFlow:       c.key[0],
+			TCPFlow:       c.key[1],
+			LastSeen:      conn.lastSeen(),
+			BufferedBytes: (conn.c2s.pages + conn.s2c.pages) * pageBytes,
+		}
+		pending := conn.c2s.first != nil || conn.s2c.first != nil
+		conn.mu.Unlock()
+		if withPages && !pending {
+			continue
+		}
+		candidates[n] = c
+		infos = append(infos, ci)
+		n++
+	}
+	if n == 0 {
+		return nil
+	}
+	candidates = candidates[:n]
+	sort.Sort(&evictionOrder{candidates, infos, less})
+	return candidates[:n/16+1]
+}
+
+// evictionOrder sorts candidates along with their infos.
+type evictionOrder struct {
+	candidates []evictionCandidate
+	infos      []ConnectionInfo
+	less       EvictionPolicy
+}
+
+func (o *evictionOrder) Len() int           { return len(o.candidates) }
+func (o *evictionOrder) Less(i, j int) bool { return o.less(o.infos[i], o.infos[j]) }
+func (o *evictionOrder) Swap(i, j int) {
+	o.candidates[i], o.candidates[j] = o.candidates[j], o.candidates[i]
+	o.infos[i], o.infos[j] = o.infos[j], o.infos[i]
+}
+
+// has reports whether conn is still the pool's connection with key k.
+func (p *StreamPool) has(conn *connection, k key) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.conns[k] == conn
+}
+
+// touch moves conn to the end of the list in assembly order, if the pool
+// keeps one.
+func (p *StreamPool) touch(conn *connection) {
+	if !p.keepsLRU() {
+		return
+	}
+	p.evictMu.Lock()
+	if conn.inLRU {
+		p.lruRemove(conn)
+		p.lruPush(conn)
+	}
+	p.evictMu.Unlock()
+}
+
+// keepsLRU reports whether the pool keeps its connections in assembly
+// order, to evict the least recently seen one.
+func (p *StreamPool) keepsLRU() bool {
+	return p.options.MaxConnections > 0 && p.options.Eviction == nil
+}
+
+// lruPush appends conn to the list in assembly order.
+func (p *StreamPool) lruPush(conn *connection) {
+	conn.lruPrev, conn.lruNext = p.lruTail, nil
+	if p.lruTail != nil {
+		p.lruTail.lruNext = conn
+	} else {
+		p.lruHead = conn
+	}
+	p.lruTail = conn
+	conn.inLRU = true
+}
+
+// lruRemove removes conn from the list in assembly order, if it is in it.
+func (p *StreamPool) lruRemove(conn *connection) {
+	if !conn.inLRU {
+		return
+	}
+	if conn.lruPrev != nil {
+		conn.lruPrev.lruNext = conn.lruNext
+	} else {
+		p.lruHead = conn.lruNext
+	}
+	if conn.lruNext != nil {
+		conn.lruNext.lruPrev = conn.lruPrev
+	} else {
+		p.lruTail = conn.lruPrev
+	}
+	conn.lruPrev, conn.lruNext = nil, nil
+	conn.inLRU = false
+}
+
 func (p *StreamPool) connections() []*connection {
 	p.mu.RLock()
 	conns := make([]*connection, 0, len(p.conns))
@@ -260,5 +567,10 @@ func (p *StreamPool) getConnection(k key, end bool, ts time.Time, tcp *layers.TC
 		return conn2, half2, rev2
 	}
 	p.conns[k] = conn
+	if p.keepsLRU() {
+		p.evictMu.Lock()
+		p.lruPush(conn)
+		p.evictMu.Unlock()
+	}
 	return conn, half, rev
 }
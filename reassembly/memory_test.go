@@ -0,0 +1,154 @@
+// Copyright 2026 The GoPacket Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package reassembly
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestStreamPoolMaxBufferedBytes(t *testing.T) {
+	client, server := net.IP{10, 0, 0, 1}, net.IP{10, 0, 0, 2}
+	for _, test := range []struct {
+		name    string
+		policy  EvictionPolicy
+		evicted int // connection whose out-of-order data is flushed
+	}{
+		{"least recently seen", EvictLeastRecentlySeen, 0},
+		{"largest buffer", EvictLargestBuffer, 1},
+	} {
+		f := &testShardedFactory{data: map[string][]byte{}, complete: map[string]int{}}
+		pool := NewStreamPoolWithOptions(f, StreamPoolOptions{
+			MaxBufferedBytes: 3 * pageBytes,
+			Eviction:         test.policy,
+		})
+		a := NewAssembler(pool)
+		ts := time.Unix(1000, 0)
+		assemble := func(port int, seq uint32, syn bool, payload string) {
+			ts = ts.Add(time.Second)
+			a.AssembleWithContext(shardedTestPacket(client, server, port, 80, seq, syn, false, payload, ts))
+		}
+		for i := 0; i < 3; i++ {
+			assemble(10000+i, 100, true, "")
+		}
+		// one page for connection 0, two for connection 1, then one for
+		// connection 2 exceeds the budget
+		assemble(10000, 200, false, "zero")
+		assemble(10001, 200, false, "one")
+		assemble(10001, 300, false, "two")
+		assemble(10002, 200, false, "three")
+
+		st := pool.Stats()
+		want := map[int]string{0: "zero", 1: "onetwo"}
+		if st.EvictedBytes != len(want[test.evicted]) {
+			t.Errorf("%s: evicted %d bytes, want %d", test.name, st.EvictedBytes, len(want[test.evicted]))
+		}
+		if st.BufferedBytes > 3*pageBytes {
+			t.Errorf("%s: %d bytes buffered, over budget", test.name, st.BufferedBytes)
+		}
+		if st.Connections != 3 || st.EvictedConnections != 0 {
+			t.Errorf("%s: stats %+v, want 3 connections and none evicted", test.name, st)
+		}
+		for i := 0; i < 3; i++ {
+			k := fmt.Sprintf("10.0.0.1->10.0.0.2:%d->80%s", 10000+i, TCPDirClientToServer)
+			if got := string(f.data[k]); got != want[test.evicted] && i == test.evicted || got != "" && i != test.evicted {
+				t.Errorf("%s: connection %d got %q", test.name, i, got)
+			}
+		}
+	}
+}
+
+func TestStreamPoolMaxConnections(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		policy EvictionPolicy
+	}{
+		{"least recently seen", EvictLeastRecentlySeen},
+		{"largest buffer", EvictLargestBuffer},
+	} {
+		f := &testShardedFactory{data: map[string][]byte{}, complete: map[string]int{}}
+		pool := NewStreamPoolWithOptions(f, StreamPoolOptions{MaxConnections: 2, Eviction: test.policy})
+		a := NewAssembler(pool)
+		client, server := net.IP{10, 0, 0, 1}, net.IP{10, 0, 0, 2}
+		ts := time.Unix(1000, 0)
+		for i, port := range []int{10000, 10001, 10000, 10002} {
+			a.AssembleWithContext(shardedTestPacket(client, server, port, 80, uint32(100+i), i != 2, false, "", ts.Add(time.Duration(i)*time.Second)))
+		}
+		// connection 1 is the least recently seen when connection 2 is
+		// created, and none buffers data
+		st := pool.Stats()
+		if st.Connections != 2 || st.EvictedConnections != 1 {
+			t.Errorf("%s: stats %+v, want 2 connections and 1 evicted", test.name, st)
+		}
+		if f.complete["10.0.0.1->10.0.0.2:10001->80"] != 1 {
+			t.Errorf("%s: evicted connection not completed: %v", test.name, f.complete)
+		}
+		if len(f.complete) != 1 {
+			t.Errorf("%s: completed connections: %v", test.name, f.complete)
+		}
+		if closed := a.FlushAll(); closed != 2 {
+			t.Errorf("%s: closed %d connections, want 2", test.name, closed)
+		}
+	}
+}
+
+// BenchmarkStreamPoolEviction measures the creation of a connection in a
+// full pool, which evicts one.
+func BenchmarkStreamPoolEviction(b *testing.B) {
+	server := net.IP{10, 0, 0, 2}
+	for _, policy := range []struct {
+		name   string
+		policy EvictionPolicy
+	}{
+		{"lru", EvictLeastRecentlySeen},
+		{"largest", EvictLargestBuffer},
+	} {
+		for _, n := range []int{100, 1000, 10000} {
+			b.Run(fmt.Sprint(policy.name, "/", n), func(b *testing.B) {
+				f := &testShardedFactory{data: map[string][]byte{}, complete: map[string]int{}}
+				a := NewAssembler(NewStreamPoolWithOptions(f, StreamPoolOptions{MaxConnections: n, Eviction: policy.policy}))
+				ts := time.Unix(1000, 0)
+				syn := func(i int) {
+					client := net.IP{10, 1, byte(i >> 16), byte(i >> 8)}
+					ts = ts.Add(time.Millisecond)
+					a.AssembleWithContext(shardedTestPacket(client, server, 10000+i&0xff, 80, 100, true, false, "", ts))
+				}
+				for i := 0; i < n; i++ {
+					syn(i)
+				}
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					syn(n + i)
+				}
+			})
+		}
+	}
+}
+
+func TestPageCacheShrink(t *testing.T) {
+	var buffered int64
+	c := newPageCache(&buffered)
+	pages := make([]*page, 5000)
+	for i := range pages {
+		pages[i] = c.next(time.Time{})
+	}
+	if buffered != 5000*pageBytes {
+		t.Errorf("%d bytes buffered, want %d", buffered, 5000*pageBytes)
+	}
+	for _, p := range pages {
+		c.replace(p)
+	}
+	c.tryShrink()
+	if buffered != 0 || c.used != 0 {
+		t.Errorf("%d bytes buffered and %d pages used after replacing all pages", buffered, c.used)
+	}
+	if c.size != initialAllocSize || len(c.free) != initialAllocSize {
+		t.Errorf("page cache not shrunk: size %d, %d free", c.size, len(c.free))
+	}
+}
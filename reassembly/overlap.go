@@ -0,0 +1,175 @@
+// Copyright 2026 The GoPacket Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package reassembly
+
+import (
+	"bytes"
+
+	"github.com/google/gopacket"
+)
+
+// OverlapPolicy tells which data is kept when a segment overlaps data
+// queued for a half connection, since hosts differ in their handling of
+// overlapping TCP segments.  Choosing the policy of the destination host
+// keeps an evader from making the assembled stream differ from what the
+// host sees.
+//
+// Policies only apply to queued out-of-order data: data already passed to
+// the Stream is always kept.
+type OverlapPolicy int
+
+const (
+	// OverlapLast favors the new segment, the default.
+	OverlapLast OverlapPolicy = iota
+	// OverlapFirst favors the queued data.
+	OverlapFirst
+	// OverlapBSD favors the queued data, unless the new segment starts
+	// before it.
+	OverlapBSD
+	// OverlapLinux favors the queued data, unless the new segment starts
+	// before it, or starts with it and ends after it.
+	OverlapLinux
+	// OverlapWindows favors the queued data, unless the new segment
+	// starts before it, like OverlapBSD.
+	OverlapWindows
+	// OverlapSolaris favors the new segment if it ends after the queued
+	// data, or starts before it and ends with it.
+	OverlapSolaris
+	// OverlapHPUX is the policy of HP-UX 11, like OverlapSolaris.
+	OverlapHPUX
+)
+
+func (p OverlapPolicy) String() string {
+	switch p {
+	case OverlapLast:
+		return "last"
+	case OverlapFirst:
+		return "first"
+	case OverlapBSD:
+		return "bsd"
+	case OverlapLinux:
+		return "linux"
+	case OverlapWindows:
+		return "windows"
+	case OverlapSolaris:
+		return "solaris"
+	case OverlapHPUX:
+		return "hpux"
+	}
+	return "unknown"
+}
+
+// newWins tells whether a new segment is kept over queued data, given the
+// comparisons of their starts and ends (<0: new is before, 0: same, >0:
+// new is after).
+func (p OverlapPolicy) newWins(start, end int) bool {
+	switch p {
+	case OverlapFirst:
+		return false
+	case OverlapBSD, OverlapWindows:
+		return start < 0
+	case OverlapLinux:
+		return start < 0 || start == 0 && end > 0
+	case OverlapSolaris, OverlapHPUX:
+		return end > 0 || start < 0 && end == 0
+	}
+	return true
+}
+
+// InconsistentRetransmission describes overlapping data that differs
+// from the data queued for the same sequence numbers, a sign of evasion
+// attempts or of broken stacks.
+type InconsistentRetransmission struct {
+	Direction TCPFlowDirection
+	// Seq is the sequence number of the first overlapping byte
+	Seq Sequence
+	// Kept and Discarded are the overlapping bytes respectively kept and
+	// discarded by the OverlapPolicy.  They are only valid during the
+	// call to InconsistentRetransmission.
+	Kept, Discarded []byte
+	// Policy is the policy of the half connection
+	Policy OverlapPolicy
+}
+
+// OverlapStream is implemented by Streams that want to know about
+// inconsistent retransmissions, which are otherwise only visible through
+// the overlap figures of TCPAssemblyStats.
+type OverlapStream interface {
+	Stream
+	// InconsistentRetransmission is called when a segment overlaps queued
+	// data with different bytes, before the data is passed to
+	// ReassembledSG.  ac is the context of the new segment.
+	InconsistentRetransmission(r InconsistentRetransmission, ac AssemblerContext)
+}
+
+// TargetPolicy returns a function choosing the OverlapPolicy of the hosts
+// in policies by their address, and def for the other ones, for use as
+// AssemblerOptions.TargetPolicy.
+func TargetPolicy(policies map[gopacket.Endpoint]OverlapPolicy, def OverlapPolicy) func(dst gopacket.Endpoint) OverlapPolicy {
+	return func(dst gopacket.Endpoint) OverlapPolicy {
+		if p, ok := policies[dst]; ok {
+			return p
+		}
+		return def
+	}
+}
+
+func sign(i int) int {
+	switch {
+	case i < 0:
+		return -1
+	case i > 0:
+		return 1
+	}
+	return 0
+}
+
+// resolveOverlap applies the policy of half to the part of the new bytes,
+// starting at start, overlapping the queued page cur: if the queued data
+// wins, it is copied over the new bytes, so that it replaces cur's data
+// when the new bytes are queued.  Bytes are copied to a buffer of the
+// Assembler before being modified, unless owned is set.
+func (a *Assembler) resolveOverlap(half *halfconnection, cur *page, start Sequence, data []byte, owned bool, ac AssemblerContext) ([]byte, bool) {
+	newOff, oldOff := 0, 0
+	if d := start.Difference(cur.seq); d > 0 {
+		newOff = d
+	} else {
+		oldOff = -d
+	}
+	n := min(len(data)-newOff, len(cur.bytes)-oldOff)
+	if n <= 0 {
+		return data, owned
+	}
+	newer, older := data[newOff:newOff+n], cur.bytes[oldOff:oldOff+n]
+	if bytes.Equal(newer, older) {
+		return data, owned
+	}
+	curEnd := cur.seq.Add(len(cur.bytes))
+	keepNew := half.policy.newWins(sign(-start.Difference(cur.seq)), sign(-start.Add(len(data)).Difference(curEnd)))
+	if s, ok := half.stream.(OverlapStream); ok {
+		r := InconsistentRetransmission{
+			Direction: half.dir,
+			Seq:       start.Add(newOff),
+			Kept:      older,
+			Discarded: newer,
+			Policy:    half.policy,
+		}
+		if keepNew {
+			r.Kept, r.Discarded = newer, older
+		}
+		s.InconsistentRetransmission(r, ac)
+	}
+	if keepNew {
+		return data, owned
+	}
+	if !owned {
+		a.overlapBuf = append(a.overlapBuf[:0], data...)
+		data, owned = a.overlapBuf, true
+	}
+	copy(data[newOff:newOff+n], older)
+	return data, owned
+}
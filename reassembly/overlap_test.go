@@ -0,0 +1,150 @@
+// Copyright 2026 The GoPacket Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package reassembly
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+/* For overlap tests: collects client data and inconsistent retransmissions */
+type testOverlapStream struct {
+	data   []byte
+	events []InconsistentRetransmission
+}
+
+func (s *testOverlapStream) New(netFlow, tcpFlow gopacket.Flow, tcp *layers.TCP, ac AssemblerContext) Stream {
+	return s
+}
+
+func (s *testOverlapStream) Accept(tcp *layers.TCP, ci gopacket.CaptureInfo, dir TCPFlowDirection, nextSeq Sequence, start *bool, ac AssemblerContext) PacketDecision {
+	return KeepDecision
+}
+
+func (s *testOverlapStream) ReassembledSG(sg ScatterGather, flushing bool, ac AssemblerContext) {
+	l, _ := sg.Lengths()
+	s.data = append(s.data, sg.Fetch(l)...)
+}
+
+func (s *testOverlapStream) ReassemblyComplete(ac AssemblerContext) bool {
+	return true
+}
+
+func (s *testOverlapStream) InconsistentRetransmission(r InconsistentRetransmission, ac AssemblerContext) {
+	r.Kept = append([]byte(nil), r.Kept...)
+	r.Discarded = append([]byte(nil), r.Discarded...)
+	s.events = append(s.events, r)
+}
+
+func TestOverlapPolicies(t *testing.T) {
+	client, server := net.IP{10, 0, 0, 1}, net.IP{10, 0, 0, 2}
+	// the queued data is always "AAAA" at 110, then the gap up to the
+	// queued segments is filled
+	segments := []struct {
+		name string
+		seq  uint32
+		data string
+		// data assembled when the new segment or the queued data wins
+		newer, older string
+	}{
+		{"starts before, ends before", 108, "bbBB", "0123456bbBBAA", "0123456bbAAAA"},
+		{"starts with, ends after", 110, "BBBBBB", "012345678BBBBBB", "012345678AAAABB"},
+		{"starts after, ends after", 112, "BBBB", "012345678AABBBB", "012345678AAAABB"},
+	}
+	policies := []struct {
+		policy OverlapPolicy
+		wins   [3]bool // new segment wins, per segment
+	}{
+		{OverlapLast, [3]bool{true, true, true}},
+		{OverlapFirst, [3]bool{false, false, false}},
+		{OverlapBSD, [3]bool{true, false, false}},
+		{OverlapLinux, [3]bool{true, true, false}},
+		{OverlapWindows, [3]bool{true, false, false}},
+		{OverlapSolaris, [3]bool{false, true, true}},
+		{OverlapHPUX, [3]bool{false, true, true}},
+	}
+	for _, p := range policies {
+		for i, seg := range segments {
+			s := &testOverlapStream{}
+			a := NewAssembler(NewStreamPool(s))
+			a.TargetPolicy = TargetPolicy(map[gopacket.Endpoint]OverlapPolicy{
+				layers.NewIPEndpoint(server): p.policy,
+			}, OverlapLast)
+			ts := time.Unix(1000, 0)
+			for _, pkt := range []struct {
+				seq  uint32
+				syn  bool
+				data string
+			}{
+				{100, true, ""},
+				{110, false, "AAAA"},
+				{seg.seq, false, seg.data},
+				{101, false, "0123456789"[:min(int(seg.seq), 110)-101]},
+			} {
+				ts = ts.Add(time.Millisecond)
+				a.AssembleWithContext(shardedTestPacket(client, server, 1234, 80, pkt.seq, pkt.syn, false, pkt.data, ts))
+			}
+			a.FlushAll()
+
+			want := seg.older
+			if p.wins[i] {
+				want = seg.newer
+			}
+			if got := string(s.data); got != want {
+				t.Errorf("%v, %s: got %q, want %q", p.policy, seg.name, got, want)
+			}
+			if len(s.events) != 1 {
+				t.Errorf("%v, %s: got %d events, want 1", p.policy, seg.name, len(s.events))
+				continue
+			}
+			ev := s.events[0]
+			if ev.Policy != p.policy || ev.Direction != TCPDirClientToServer {
+				t.Errorf("%v, %s: event %+v", p.policy, seg.name, ev)
+			}
+			kept, discarded := string(ev.Kept), string(ev.Discarded)
+			if p.wins[i] {
+				kept, discarded = discarded, kept
+			}
+			if kept[0] != 'A' || discarded[0] != 'B' || len(kept) != len(discarded) {
+				t.Errorf("%v, %s: kept %q, discarded %q", p.policy, seg.name, ev.Kept, ev.Discarded)
+			}
+		}
+	}
+}
+
+func TestOverlapConsistent(t *testing.T) {
+	s := &testOverlapStream{}
+	a := NewAssembler(NewStreamPool(s))
+	a.TargetPolicy = func(gopacket.Endpoint) OverlapPolicy { return OverlapFirst }
+	client, server := net.IP{10, 0, 0, 1}, net.IP{10, 0, 0, 2}
+	ts := time.Unix(1000, 0)
+	for _, pkt := range []struct {
+		seq  uint32
+		syn  bool
+		data string
+	}{
+		{100, true, ""},
+		{106, false, "56789"},
+		{104, false, "3456"},
+		{101, false, "01"},
+		{102, false, "12345"},
+	} {
+		ts = ts.Add(time.Millisecond)
+		a.AssembleWithContext(shardedTestPacket(client, server, 1234, 80, pkt.seq, pkt.syn, false, pkt.data, ts))
+	}
+	a.FlushAll()
+	if got := string(s.data); got != "0123456789" {
+		t.Errorf("got %q", got)
+	}
+	if len(s.events) != 0 {
+		t.Errorf("got events for consistent data: %+v", s.events)
+	}
+}
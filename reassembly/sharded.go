@@ -0,0 +1,238 @@
+// Copyright 2026 The GoPacket Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package reassembly
+
+import (
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// ShardedAssemblerOptions controls the behavior of a ShardedAssembler.
+type ShardedAssemblerOptions struct {
+	// Shards is the number of Assemblers, each running in its own
+	// goroutine.  If <= 0, runtime.NumCPU() is used.
+	Shards int
+	// QueueSize is the number of packets each shard queues before
+	// AssembleWithContext blocks.  If <= 0, packets are passed to shards
+	// unbuffered.
+	QueueSize int
+	// AssemblerOptions are the options of each shard's Assembler.  Limits
+	// apply to each shard separately.
+	AssemblerOptions
+	// StreamPoolOptions are the options of each shard's StreamPool.
+	// Limits apply to each shard separately.
+	StreamPoolOptions
+}
+
+// DefaultShardedAssemblerOptions provides default options for a sharded
+// assembler, used by NewShardedAssembler.
+var DefaultShardedAssemblerOptions = ShardedAssemblerOptions{
+	QueueSize:        1024,
+	AssemblerOptions: DefaultAssemblerOptions,
+}
+
+// ShardStats provides some figures for a shard of a ShardedAssembler, or
+// for all of them.
+type ShardStats struct {
+	// Packets is the number of packets assembled
+	Packets int
+	// Connections is the number of connections currently tracked
+	Connections int
+	// BufferedPages is the number of pages holding out-of-order data
+	BufferedPages int
+}
+
+func (s *ShardStats) add(o ShardStats) {
+	s.Packets += o.Packets
+	s.Connections += o.Connections
+	s.BufferedPages += o.BufferedPages
+}
+
+// shardRequest is either a packet to assemble or a function to run on
+// the shard's assembler.
+type shardRequest struct {
+	netFlow gopacket.Flow
+	tcp     *layers.TCP
+	ac      AssemblerContext
+	fn      func(*assemblerShard)
+}
+
+type assemblerShard struct {
+	a       *Assembler
+	pool    *StreamPool
+	queue   chan shardRequest
+	packets int
+}
+
+func (sh *assemblerShard) run(wg *sync.WaitGroup) {
+	defer wg.Done()
+	for req := range sh.queue {
+		if req.fn != nil {
+			req.fn(sh)
+			continue
+		}
+		sh.a.AssembleWithContext(req.netFlow, req.tcp, req.ac)
+		sh.packets++
+	}
+}
+
+// ShardedAssembler spreads TCP reassembly over several Assemblers running
+// concurrently.  Connections are assigned to shards by a symmetric hash
+// of their network and transport flows, so that both directions of a
+// connection are handled by the same Assembler, each having its own
+// StreamPool.
+//
+// Packets are handed to the shards through bounded queues: after
+// AssembleWithContext returns, the packet is still used by the shard's
+// goroutine.  The TCP layer, its payload and the AssemblerContext must
+// not be modified or reused afterwards, which rules out
+// DecodingLayerParser with reused layers and ZeroCopyReadPacketData.
+//
+// The StreamFactory and the Streams are called from the shards'
+// goroutines, concurrently for different connections; each Stream is
+// only called by one goroutine.
+//
+// Flushes are queued like packets, so that they apply to all packets
+// passed before them, and wait for every shard to complete.
+//
+// A ShardedAssembler is safe for concurrent use, but packets of a
+// connection must be passed by a single goroutine to keep their order.
+// Close must be called to stop the shards' goroutines.
+type ShardedAssembler struct {
+	shards []*assemblerShard
+	wg     sync.WaitGroup
+}
+
+// NewShardedAssembler creates a new sharded assembler, creating Streams
+// with factory, and starts its shards.
+func NewShardedAssembler(factory StreamFactory, options ShardedAssemblerOptions) *ShardedAssembler {
+	n := options.Shards
+	if n <= 0 {
+		n = runtime.NumCPU()
+	}
+	queueSize := options.QueueSize
+	if queueSize < 0 {
+		queueSize = 0
+	}
+	s := &ShardedAssembler{shards: make([]*assemblerShard, n)}
+	for i := range s.shards {
+		pool := NewStreamPoolWithOptions(factory, options.StreamPoolOptions)
+		a := NewAssembler(pool)
+		a.AssemblerOptions = options.AssemblerOptions
+		sh := &assemblerShard{
+			a:     a,
+			pool:  pool,
+			queue: make(chan shardRequest, queueSize),
+		}
+		s.shards[i] = sh
+		s.wg.Add(1)
+		go sh.run(&s.wg)
+	}
+	return s
+}
+
+// shard returns the shard handling the connection with the given flows.
+func (s *ShardedAssembler) shard(netFlow, tcpFlow gopacket.Flow) *assemblerShard {
+	// FastHash is symmetric, so is the combination
+	h := netFlow.FastHash() ^ tcpFlow.FastHash()*0x9e3779b97f4a7c15
+	return s.shards[h%uint64(len(s.shards))]
+}
+
+// Assemble calls AssembleWithContext with the current timestamp, useful for
+// packets being read directly off the wire.
+func (s *ShardedAssembler) Assemble(netFlow gopacket.Flow, t *layers.TCP) {
+	ctx := assemblerSimpleContext(gopacket.CaptureInfo{Timestamp: time.Now()})
+	s.AssembleWithContext(netFlow, t, &ctx)
+}
+
+// AssembleWithContext queues the given TCP packet to the shard handling
+// its connection, blocking while that shard's queue is full.  See
+// Assembler.AssembleWithContext.
+func (s *ShardedAssembler) AssembleWithContext(netFlow gopacket.Flow, t *layers.TCP, ac AssemblerContext) {
+	s.shard(netFlow, t.TransportFlow()).queue <- shardRequest{netFlow: netFlow, tcp: t, ac: ac}
+}
+
+// each runs fn on every shard, after the packets already queued, and
+// waits for all of them.
+func (s *ShardedAssembler) each(fn func(i int, sh *assemblerShard)) {
+	var wg sync.WaitGroup
+	wg.Add(len(s.shards))
+	for i, sh := range s.shards {
+		i := i
+		sh.queue <- shardRequest{fn: func(sh *assemblerShard) {
+			fn(i, sh)
+			wg.Done()
+		}}
+	}
+	wg.Wait()
+}
+
+// FlushWithOptions calls Assembler.FlushWithOptions on every shard once
+// the packets queued before the call are assembled.  It returns the
+// total number of connections flushed and closed.
+func (s *ShardedAssembler) FlushWithOptions(opt FlushOptions) (flushed, closed int) {
+	var mu sync.Mutex
+	s.each(func(_ int, sh *assemblerShard) {
+		f, c := sh.a.FlushWithOptions(opt)
+		mu.Lock()
+		flushed += f
+		closed += c
+		mu.Unlock()
+	})
+	return
+}
+
+// FlushCloseOlderThan flushes and closes streams older than given time
+// on every shard.
+func (s *ShardedAssembler) FlushCloseOlderThan(t time.Time) (flushed, closed int) {
+	return s.FlushWithOptions(FlushOptions{T: t, TC: t})
+}
+
+// FlushAll flushes all remaining data into all remaining connections of
+// every shard and closes those connections. It returns the total number
+// of connections flushed/closed by the call.
+func (s *ShardedAssembler) FlushAll() (closed int) {
+	var mu sync.Mutex
+	s.each(func(_ int, sh *assemblerShard) {
+		c := sh.a.FlushAll()
+		mu.Lock()
+		closed += c
+		mu.Unlock()
+	})
+	return
+}
+
+// Stats returns the statistics of all shards combined, and of each
+// shard, once the packets queued before the call are assembled.
+func (s *ShardedAssembler) Stats() (total ShardStats, shards []ShardStats) {
+	shards = make([]ShardStats, len(s.shards))
+	s.each(func(i int, sh *assemblerShard) {
+		shards[i] = ShardStats{
+			Packets:       sh.packets,
+			Connections:   sh.pool.GetRemainingConnectionCount(),
+			BufferedPages: sh.a.pc.used,
+		}
+	})
+	for _, st := range shards {
+		total.add(st)
+	}
+	return
+}
+
+// Close stops the shards once the packets already queued are assembled.
+// Remaining connections are not flushed, call FlushAll before if needed.
+// The ShardedAssembler must not be used after Close.
+func (s *ShardedAssembler) Close() {
+	for _, sh := range s.shards {
+		close(sh.queue)
+	}
+	s.wg.Wait()
+}
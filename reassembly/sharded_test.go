@@ -0,0 +1,148 @@
+// Copyright 2026 The GoPacket Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package reassembly
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+/* For sharded tests: collects bytes per connection, safe for concurrency */
+type testShardedFactory struct {
+	mu       sync.Mutex
+	data     map[string][]byte
+	complete map[string]int
+}
+
+type testShardedStream struct {
+	f   *testShardedFactory
+	key string
+}
+
+func (f *testShardedFactory) New(netFlow, tcpFlow gopacket.Flow, tcp *layers.TCP, ac AssemblerContext) Stream {
+	return &testShardedStream{f: f, key: fmt.Sprintf("%v:%v", netFlow, tcpFlow)}
+}
+
+func (s *testShardedStream) Accept(tcp *layers.TCP, ci gopacket.CaptureInfo, dir TCPFlowDirection, nextSeq Sequence, start *bool, ac AssemblerContext) PacketDecision {
+	return KeepDecision
+}
+
+func (s *testShardedStream) ReassembledSG(sg ScatterGather, flushing bool, ac AssemblerContext) {
+	dir, _, _, _ := sg.Info()
+	l, _ := sg.Lengths()
+	s.f.mu.Lock()
+	k := s.key + dir.String()
+	s.f.data[k] = append(s.f.data[k], sg.Fetch(l)...)
+	s.f.mu.Unlock()
+}
+
+func (s *testShardedStream) ReassemblyComplete(ac AssemblerContext) bool {
+	s.f.mu.Lock()
+	s.f.complete[s.key]++
+	s.f.mu.Unlock()
+	return true
+}
+
+func shardedTestPacket(src, dst net.IP, sport, dport int, seq uint32, syn, fin bool, payload string, ts time.Time) (gopacket.Flow, *layers.TCP, AssemblerContext) {
+	netFlow, _ := gopacket.FlowFromEndpoints(layers.NewIPEndpoint(src), layers.NewIPEndpoint(dst))
+	tcp := &layers.TCP{
+		SrcPort: layers.TCPPort(sport),
+		DstPort: layers.TCPPort(dport),
+		Seq:     seq,
+		SYN:     syn,
+		FIN:     fin,
+		BaseLayer: layers.BaseLayer{
+			Payload: []byte(payload),
+		},
+	}
+	tcp.SetInternalPortsForTesting()
+	ac := assemblerSimpleContext(gopacket.CaptureInfo{Timestamp: ts})
+	return netFlow, tcp, &ac
+}
+
+func TestShardedAssembler(t *testing.T) {
+	f := &testShardedFactory{data: map[string][]byte{}, complete: map[string]int{}}
+	opts := DefaultShardedAssemblerOptions
+	opts.Shards = 4
+	opts.QueueSize = 8
+	s := NewShardedAssembler(f, opts)
+	defer s.Close()
+
+	client, server := net.IP{10, 0, 0, 1}, net.IP{10, 0, 0, 2}
+	const conns = 50
+	ts := time.Unix(1000, 0)
+	packets := 0
+	assemble := func(src, dst net.IP, sport, dport int, seq uint32, syn, fin bool, payload string) {
+		s.AssembleWithContext(shardedTestPacket(src, dst, sport, dport, seq, syn, fin, payload, ts))
+		packets++
+	}
+	// interleave connections, both directions
+	for i := 0; i < conns; i++ {
+		assemble(client, server, 10000+i, 80, 100, true, false, "")
+		assemble(server, client, 80, 10000+i, 500, true, false, "")
+	}
+	for i := 0; i < conns; i++ {
+		assemble(client, server, 10000+i, 80, 101, false, false, fmt.Sprintf("request %d", i))
+		assemble(server, client, 80, 10000+i, 501, false, false, fmt.Sprintf("response %d", i))
+	}
+	// out of order data on the first connection, flushed below
+	assemble(client, server, 10000, 80, 200, false, false, "late")
+
+	total, shards := s.Stats()
+	if total.Packets != packets || total.Connections != conns {
+		t.Errorf("stats: got %+v, want %d packets and %d connections", total, packets, conns)
+	}
+	if total.BufferedPages != 1 {
+		t.Errorf("stats: got %d buffered pages, want 1", total.BufferedPages)
+	}
+	if len(shards) != 4 {
+		t.Fatalf("got %d shards", len(shards))
+	}
+	used := 0
+	for _, sh := range shards {
+		if sh.Connections > 0 {
+			used++
+		}
+	}
+	if used < 2 {
+		t.Errorf("connections not spread over shards: %+v", shards)
+	}
+
+	flushed, _ := s.FlushWithOptions(FlushOptions{T: ts.Add(time.Second)})
+	if flushed != 1 {
+		t.Errorf("flushed %d connections, want 1", flushed)
+	}
+	if closed := s.FlushAll(); closed != conns {
+		t.Errorf("closed %d connections, want %d", closed, conns)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i := 0; i < conns; i++ {
+		c2s := fmt.Sprintf("10.0.0.1->10.0.0.2:%d->80", 10000+i)
+		want := fmt.Sprintf("request %d", i)
+		if i == 0 {
+			want += "late"
+		}
+		if got := string(f.data[c2s+TCPDirClientToServer.String()]); got != want {
+			t.Errorf("connection %d client->server: got %q, want %q", i, got, want)
+		}
+		want = fmt.Sprintf("response %d", i)
+		if got := string(f.data[c2s+TCPDirServerToClient.String()]); got != want {
+			t.Errorf("connection %d server->client: got %q, want %q", i, got, want)
+		}
+		if f.complete[c2s] != 1 {
+			t.Errorf("connection %d completed %d times", i, f.complete[c2s])
+		}
+	}
+}
@@ -0,0 +1,424 @@
+// Copyright 2026 The GoPacket Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package reassembly
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+/*
+ * Analyze TCP packets (RTT, retransmissions, windows), like Wireshark's
+ * tcp.analysis
+ */
+
+// TCPAnalysisFlags tells what TCPAnalyzer found out about a packet.  The
+// flags follow Wireshark's tcp.analysis flags.
+type TCPAnalysisFlags uint32
+
+// Flags set by TCPAnalyzer.Analyze
+const (
+	// TCPAnalysisRetransmission is a segment whose data was already seen
+	TCPAnalysisRetransmission TCPAnalysisFlags = 1 << iota
+	// TCPAnalysisFastRetransmission is a retransmission following two or
+	// more duplicate ACKs
+	TCPAnalysisFastRetransmission
+	// TCPAnalysisSpuriousRetransmission is a retransmission of data
+	// already acknowledged
+	TCPAnalysisSpuriousRetransmission
+	// TCPAnalysisOutOfOrder is a segment older than the last one, seen
+	// shortly after it
+	TCPAnalysisOutOfOrder
+	// TCPAnalysisLostSegment is a segment after data that was not seen
+	TCPAnalysisLostSegment
+	// TCPAnalysisACKedUnseen acknowledges data that was not seen
+	TCPAnalysisACKedUnseen
+	// TCPAnalysisDuplicateACK repeats the last acknowledgement
+	TCPAnalysisDuplicateACK
+	// TCPAnalysisZeroWindow advertises a zero receive window
+	TCPAnalysisZeroWindow
+	// TCPAnalysisZeroWindowProbe is a one byte segment sent while the
+	// peer's receive window is zero
+	TCPAnalysisZeroWindowProbe
+	// TCPAnalysisZeroWindowProbeACK acknowledges a zero window probe
+	TCPAnalysisZeroWindowProbeACK
+	// TCPAnalysisWindowFull fills the peer's receive window
+	TCPAnalysisWindowFull
+	// TCPAnalysisWindowUpdate only changes the receive window
+	TCPAnalysisWindowUpdate
+	// TCPAnalysisKeepAlive is a keep-alive, sent one byte before the next
+	// sequence number
+	TCPAnalysisKeepAlive
+	// TCPAnalysisKeepAliveACK acknowledges a keep-alive
+	TCPAnalysisKeepAliveACK
+)
+
+var tcpAnalysisFlagNames = []string{
+	"Retransmission",
+	"FastRetransmission",
+	"SpuriousRetransmission",
+	"OutOfOrder",
+	"LostSegment",
+	"ACKedUnseen",
+	"DuplicateACK",
+	"ZeroWindow",
+	"ZeroWindowProbe",
+	"ZeroWindowProbeACK",
+	"WindowFull",
+	"WindowUpdate",
+	"KeepAlive",
+	"KeepAliveACK",
+}
+
+func (f TCPAnalysisFlags) String() string {
+	var names []string
+	for i, name := range tcpAnalysisFlagNames {
+		if f&(1<<uint(i)) != 0 {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, "|")
+}
+
+// TCPAnalysisResult holds the analysis of a packet.
+type TCPAnalysisResult struct {
+	Flags TCPAnalysisFlags
+	// ACKRTT is the time since the segment acknowledged by the packet was
+	// seen, 0 if the packet does not acknowledge a new segment, or the
+	// segment was retransmitted
+	ACKRTT time.Duration
+	// DuplicateACKs is the number of the duplicate ACK, for packets with
+	// TCPAnalysisDuplicateACK
+	DuplicateACKs int
+}
+
+// TCPAnalysisCounters holds the figures of a direction of a connection.
+type TCPAnalysisCounters struct {
+	Packets int
+	// Bytes is the payload size
+	Bytes                   int
+	Retransmissions         int
+	FastRetransmissions     int
+	SpuriousRetransmissions int
+	OutOfOrder              int
+	LostSegments            int
+	ACKedUnseen             int
+	DuplicateACKs           int
+	ZeroWindows             int
+	ZeroWindowProbes        int
+	WindowFull              int
+	WindowUpdates           int
+	KeepAlives              int
+	// ACKRTTs is the number of RTT samples taken for the segments sent in
+	// this direction, ACKRTTMin, ACKRTTMax and ACKRTTSum sum them up
+	ACKRTTs                         int
+	ACKRTTMin, ACKRTTMax, ACKRTTSum time.Duration
+}
+
+func (c *TCPAnalysisCounters) add(r TCPAnalysisResult, payload int) {
+	c.Packets++
+	c.Bytes += payload
+	for _, f := range []struct {
+		flag    TCPAnalysisFlags
+		counter *int
+	}{
+		{TCPAnalysisRetransmission, &c.Retransmissions},
+		{TCPAnalysisFastRetransmission, &c.FastRetransmissions},
+		{TCPAnalysisSpuriousRetransmission, &c.SpuriousRetransmissions},
+		{TCPAnalysisOutOfOrder, &c.OutOfOrder},
+		{TCPAnalysisLostSegment, &c.LostSegments},
+		{TCPAnalysisACKedUnseen, &c.ACKedUnseen},
+		{TCPAnalysisDuplicateACK, &c.DuplicateACKs},
+		{TCPAnalysisZeroWindow, &c.ZeroWindows},
+		{TCPAnalysisZeroWindowProbe, &c.ZeroWindowProbes},
+		{TCPAnalysisWindowFull, &c.WindowFull},
+		{TCPAnalysisWindowUpdate, &c.WindowUpdates},
+		{TCPAnalysisKeepAlive, &c.KeepAlives},
+	} {
+		if r.Flags&f.flag != 0 {
+			*f.counter++
+		}
+	}
+}
+
+func (c *TCPAnalysisCounters) addRTT(rtt time.Duration) {
+	if c.ACKRTTs == 0 || rtt < c.ACKRTTMin {
+		c.ACKRTTMin = rtt
+	}
+	if rtt > c.ACKRTTMax {
+		c.ACKRTTMax = rtt
+	}
+	c.ACKRTTSum += rtt
+	c.ACKRTTs++
+}
+
+// TCPAnalysisStats holds the figures of a connection.
+type TCPAnalysisStats struct {
+	// HandshakeRTT is the time from the SYN to the ACK of the SYN/ACK,
+	// 0 if the handshake was not seen
+	HandshakeRTT time.Duration
+	// SYNToSYNACK and SYNACKToACK split HandshakeRTT at the SYN/ACK,
+	// measuring the server and client sides of the capture point
+	SYNToSYNACK, SYNACKToACK       time.Duration
+	ClientToServer, ServerToClient TCPAnalysisCounters
+}
+
+// TCPAnalyzerOptions holds options for TCPAnalyzer
+type TCPAnalyzerOptions struct {
+	// OutOfOrderThreshold is the delay after the segment with the highest
+	// sequence number within which an older segment is out of order rather
+	// than retransmitted.  If 0, 3ms is used.
+	OutOfOrderThreshold time.Duration
+	// FastRetransmissionThreshold is the delay after the last duplicate
+	// ACK within which a retransmission is a fast retransmission.  If 0,
+	// 20ms is used.
+	FastRetransmissionThreshold time.Duration
+	// MaxUnackedSegments is the number of segments per direction
+	// remembered to measure their ACK RTT.  If 0, 1024 is used.
+	MaxUnackedSegments int
+}
+
+// tcpAnalysisSegment is a segment waiting for its acknowledgement
+type tcpAnalysisSegment struct {
+	end           Sequence
+	seen          time.Time
+	retransmitted bool
+}
+
+type tcpAnalysisHalf struct {
+	seen        bool
+	nextSeq     Sequence // highest sequence number seen + 1
+	nextSeqTime time.Time
+	ackSeen     bool
+	lastACK     Sequence
+	lastACKTime time.Time
+	windowSeen  bool
+	window      int64 // scaled
+	synSeen     bool
+	scale       int
+	dupACKs     int
+	lastFlags   TCPAnalysisFlags
+	unacked     []tcpAnalysisSegment
+	counters    TCPAnalysisCounters
+}
+
+// TCPAnalyzer analyzes the packets of a connection, finding out
+// retransmissions, lost segments, duplicate ACKs, window issues and
+// keep-alives, and measuring handshake and ACK round trip times, as
+// Wireshark does.  Times are measured at the capture point.
+//
+// Usage:
+// Create a TCPAnalyzer per connection in the StreamFactory, and call
+// Analyze from Stream's Accept for every packet, in the order they were
+// captured.
+type TCPAnalyzer struct {
+	halves  [2]tcpAnalysisHalf
+	options TCPAnalyzerOptions
+	// handshake
+	client                   TCPFlowDirection
+	syn, synACK              time.Time
+	established              bool
+	synToSYNACK, synACKToACK time.Duration
+}
+
+// NewTCPAnalyzer creates a new TCPAnalyzer
+func NewTCPAnalyzer(options TCPAnalyzerOptions) *TCPAnalyzer {
+	if options.OutOfOrderThreshold == 0 {
+		options.OutOfOrderThreshold = 3 * time.Millisecond
+	}
+	if options.FastRetransmissionThreshold == 0 {
+		options.FastRetransmissionThreshold = 20 * time.Millisecond
+	}
+	if options.MaxUnackedSegments == 0 {
+		options.MaxUnackedSegments = 1024
+	}
+	return &TCPAnalyzer{options: options}
+}
+
+func (t *TCPAnalyzer) getHalf(dir TCPFlowDirection) *tcpAnalysisHalf {
+	if dir == TCPDirClientToServer {
+		return &t.halves[0]
+	}
+	return &t.halves[1]
+}
+
+// Stats returns the figures of the connection so far.
+func (t *TCPAnalyzer) Stats() TCPAnalysisStats {
+	s := TCPAnalysisStats{
+		SYNToSYNACK:    t.synToSYNACK,
+		SYNACKToACK:    t.synACKToACK,
+		ClientToServer: t.halves[0].counters,
+		ServerToClient: t.halves[1].counters,
+	}
+	if t.established {
+		s.HandshakeRTT = t.synToSYNACK + t.synACKToACK
+	}
+	return s
+}
+
+// handshake measures the handshake RTT.
+func (t *TCPAnalyzer) handshake(tcp *layers.TCP, ts time.Time, dir TCPFlowDirection) {
+	switch {
+	case tcp.SYN && !tcp.ACK:
+		t.client, t.syn = dir, ts
+		t.synACK = time.Time{}
+	case tcp.SYN && tcp.ACK:
+		if !t.syn.IsZero() && dir != t.client && t.synACK.IsZero() {
+			t.synACK = ts
+			t.synToSYNACK = ts.Sub(t.syn)
+		}
+	case tcp.ACK:
+		if !t.synACK.IsZero() && dir == t.client && !t.established {
+			t.synACKToACK = ts.Sub(t.synACK)
+			t.established = true
+		}
+	}
+}
+
+// acknowledge forgets the segments acknowledged by ack, returning the
+// RTT of the last one.
+func (h *tcpAnalysisHalf) acknowledge(ack Sequence, ts time.Time) time.Duration {
+	n := 0
+	var last *tcpAnalysisSegment
+	for i := range h.unacked {
+		if ack.Difference(h.unacked[i].end) > 0 {
+			break
+		}
+		last = &h.unacked[i]
+		n++
+	}
+	if last == nil {
+		return 0
+	}
+	var rtt time.Duration
+	if !last.retransmitted {
+		rtt = ts.Sub(last.seen)
+		h.counters.addRTT(rtt)
+	}
+	h.unacked = append(h.unacked[:0], h.unacked[n:]...)
+	return rtt
+}
+
+// Analyze analyzes a packet sent in direction dir, updating the figures of
+// the connection.
+func (t *TCPAnalyzer) Analyze(tcp *layers.TCP, ci gopacket.CaptureInfo, dir TCPFlowDirection) TCPAnalysisResult {
+	var r TCPAnalysisResult
+	fwd, rev := t.getHalf(dir), t.getHalf(dir.Reverse())
+	ts := ci.Timestamp
+	t.handshake(tcp, ts, dir)
+
+	seq, ack := Sequence(tcp.Seq), Sequence(tcp.Ack)
+	payload := len(tcp.Payload)
+	// SYN and FIN use a sequence number
+	length := payload
+	if tcp.SYN {
+		length++
+	}
+	if tcp.FIN {
+		length++
+	}
+	end := seq.Add(length)
+	control := tcp.SYN || tcp.FIN || tcp.RST
+
+	if tcp.SYN {
+		fwd.synSeen = true
+		fwd.scale = -1
+		for _, o := range tcp.Options {
+			if o.OptionType == layers.TCPOptionKindWindowScale && len(o.OptionData) == 1 {
+				fwd.scale = min(int(o.OptionData[0]), 14)
+			}
+		}
+	}
+	window := int64(tcp.Window)
+	if !tcp.SYN && fwd.synSeen && rev.synSeen && fwd.scale >= 0 && rev.scale >= 0 {
+		window <<= uint(fwd.scale)
+	}
+
+	if fwd.seen {
+		if payload == 1 && seq == fwd.nextSeq && rev.windowSeen && rev.window == 0 {
+			r.Flags |= TCPAnalysisZeroWindowProbe
+		}
+		if tcp.Window == 0 && !control {
+			r.Flags |= TCPAnalysisZeroWindow
+		}
+		if fwd.nextSeq.Difference(seq) > 0 && !tcp.RST {
+			r.Flags |= TCPAnalysisLostSegment
+		}
+		if payload <= 1 && !control && seq.Add(1) == fwd.nextSeq {
+			r.Flags |= TCPAnalysisKeepAlive
+		}
+		sameACK := fwd.ackSeen && tcp.ACK && ack == fwd.lastACK
+		if payload == 0 && !control && sameACK && seq == fwd.nextSeq && fwd.windowSeen && window != 0 && window != fwd.window {
+			r.Flags |= TCPAnalysisWindowUpdate
+		}
+		if payload > 0 && !control && rev.windowSeen && rev.ackSeen && end == rev.lastACK.Add(int(rev.window)) {
+			r.Flags |= TCPAnalysisWindowFull
+		}
+		if payload == 0 && !control && sameACK && seq == fwd.nextSeq && window == fwd.window {
+			switch {
+			case rev.lastFlags&TCPAnalysisKeepAlive != 0:
+				r.Flags |= TCPAnalysisKeepAliveACK
+			case window == 0 && rev.lastFlags&TCPAnalysisZeroWindowProbe != 0:
+				r.Flags |= TCPAnalysisZeroWindowProbeACK
+			default:
+				r.Flags |= TCPAnalysisDuplicateACK
+				fwd.dupACKs++
+				r.DuplicateACKs = fwd.dupACKs
+			}
+		}
+		if length > 0 && r.Flags&TCPAnalysisKeepAlive == 0 && seq.Difference(fwd.nextSeq) > 0 {
+			switch {
+			case rev.dupACKs >= 2 && rev.lastACK == seq && ts.Sub(rev.lastACKTime) < t.options.FastRetransmissionThreshold:
+				r.Flags |= TCPAnalysisFastRetransmission
+			case ts.Sub(fwd.nextSeqTime) < t.options.OutOfOrderThreshold && end != fwd.nextSeq:
+				r.Flags |= TCPAnalysisOutOfOrder
+			case rev.ackSeen && end.Difference(rev.lastACK) >= 0:
+				r.Flags |= TCPAnalysisSpuriousRetransmission
+			default:
+				r.Flags |= TCPAnalysisRetransmission
+			}
+		}
+	}
+	if tcp.ACK && rev.seen && rev.nextSeq.Difference(ack) > 0 {
+		r.Flags |= TCPAnalysisACKedUnseen
+	}
+
+	// remember the segment for its RTT, unless it was seen before
+	const resent = TCPAnalysisRetransmission | TCPAnalysisFastRetransmission | TCPAnalysisSpuriousRetransmission
+	if r.Flags&resent != 0 {
+		for i := range fwd.unacked {
+			if seq.Difference(fwd.unacked[i].end) > 0 {
+				fwd.unacked[i].retransmitted = true
+			}
+		}
+	} else if length > 0 && r.Flags&(TCPAnalysisOutOfOrder|TCPAnalysisKeepAlive) == 0 {
+		if len(fwd.unacked) >= t.options.MaxUnackedSegments {
+			fwd.unacked = append(fwd.unacked[:0], fwd.unacked[1:]...)
+		}
+		fwd.unacked = append(fwd.unacked, tcpAnalysisSegment{end: end, seen: ts})
+	}
+
+	// a zero window probe is not accepted by the receiver
+	if !fwd.seen || fwd.nextSeq.Difference(end) > 0 && r.Flags&TCPAnalysisZeroWindowProbe == 0 {
+		fwd.nextSeq, fwd.nextSeqTime = end, ts
+	}
+	fwd.seen = true
+	if tcp.ACK {
+		if r.Flags&TCPAnalysisDuplicateACK == 0 {
+			fwd.dupACKs = 0
+		}
+		fwd.lastACK, fwd.lastACKTime, fwd.ackSeen = ack, ts, true
+		r.ACKRTT = rev.acknowledge(ack, ts)
+	}
+	fwd.window, fwd.windowSeen = window, true
+	fwd.lastFlags = r.Flags
+	fwd.counters.add(r, payload)
+	return r
+}
@@ -0,0 +1,120 @@
+// Copyright 2026 The GoPacket Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package reassembly
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+type testAnalysisPacket struct {
+	ms         int // timestamp, in ms
+	c2s        bool
+	syn, isACK bool
+	seq, ack   uint32
+	window     uint16
+	payload    int
+	flags      TCPAnalysisFlags
+	rtt        time.Duration
+}
+
+func TestTCPAnalyzer(t *testing.T) {
+	const (
+		none = TCPAnalysisFlags(0)
+		ms   = time.Millisecond
+	)
+	packets := []testAnalysisPacket{
+		// handshake, the client scales its window by 4
+		{0, true, true, false, 1000, 0, 1000, 0, none, 0},
+		{10, false, true, true, 5000, 1001, 1000, 0, none, 10 * ms},
+		{30, true, false, true, 1001, 5001, 1000, 0, none, 20 * ms},
+		{40, true, false, true, 1001, 5001, 1000, 100, none, 0},
+		{50, false, false, true, 5001, 1101, 1000, 0, none, 10 * ms},
+		// 1101-1201 lost, then fast retransmitted
+		{60, true, false, true, 1201, 5001, 1000, 100, TCPAnalysisLostSegment, 0},
+		{61, false, false, true, 5001, 1101, 1000, 0, TCPAnalysisDuplicateACK, 0},
+		{62, false, false, true, 5001, 1101, 1000, 0, TCPAnalysisDuplicateACK, 0},
+		{63, true, false, true, 1101, 5001, 1000, 100, TCPAnalysisFastRetransmission, 0},
+		{70, false, false, true, 5001, 1301, 1000, 0, none, 0},
+		{200, true, false, true, 1101, 5001, 1000, 100, TCPAnalysisSpuriousRetransmission, 0},
+		{250, true, false, true, 1301, 5001, 1000, 10, none, 0},
+		{260, true, false, true, 1301, 5001, 1000, 10, TCPAnalysisRetransmission, 0},
+		{270, false, false, true, 5001, 1311, 1000, 0, none, 0},
+		// keep-alive
+		{300, true, false, true, 1310, 5001, 1000, 0, TCPAnalysisKeepAlive, 0},
+		{301, false, false, true, 5001, 1311, 1000, 0, TCPAnalysisKeepAliveACK, 0},
+		// zero window
+		{400, false, false, true, 5001, 1311, 0, 0, TCPAnalysisZeroWindow, 0},
+		{410, true, false, true, 1311, 5001, 1000, 1, TCPAnalysisZeroWindowProbe, 0},
+		{411, false, false, true, 5001, 1311, 0, 0, TCPAnalysisZeroWindow | TCPAnalysisZeroWindowProbeACK, 0},
+		{500, false, false, true, 5001, 1311, 1000, 0, TCPAnalysisWindowUpdate, 0},
+		{510, true, false, true, 1311, 5001, 1000, 1000, TCPAnalysisWindowFull, 0},
+		{520, false, false, true, 5001, 3000, 1000, 0, TCPAnalysisACKedUnseen, 10 * ms},
+		// out of order
+		{530, true, false, true, 2411, 5001, 1000, 100, TCPAnalysisLostSegment, 0},
+		{531, true, false, true, 2311, 5001, 1000, 100, TCPAnalysisOutOfOrder, 0},
+	}
+	a := NewTCPAnalyzer(TCPAnalyzerOptions{})
+	start := time.Unix(1000, 0)
+	for i, p := range packets {
+		tcp := &layers.TCP{
+			SYN:       p.syn,
+			ACK:       p.isACK,
+			Seq:       p.seq,
+			Ack:       p.ack,
+			Window:    p.window,
+			BaseLayer: layers.BaseLayer{Payload: bytes.Repeat([]byte{'x'}, p.payload)},
+		}
+		if p.syn && p.c2s {
+			tcp.Options = []layers.TCPOption{{OptionType: layers.TCPOptionKindWindowScale, OptionLength: 3, OptionData: []byte{2}}}
+		} else if p.syn {
+			tcp.Options = []layers.TCPOption{{OptionType: layers.TCPOptionKindWindowScale, OptionLength: 3, OptionData: []byte{0}}}
+		}
+		dir := TCPDirClientToServer
+		if !p.c2s {
+			dir = TCPDirServerToClient
+		}
+		r := a.Analyze(tcp, gopacket.CaptureInfo{Timestamp: start.Add(time.Duration(p.ms) * ms)}, dir)
+		if r.Flags != p.flags || r.ACKRTT != p.rtt {
+			t.Errorf("#%d: got %v (rtt %v), want %v (rtt %v)", i, r.Flags, r.ACKRTT, p.flags, p.rtt)
+		}
+		if i == 7 && r.DuplicateACKs != 2 {
+			t.Errorf("#%d: duplicate ACK number %d, want 2", i, r.DuplicateACKs)
+		}
+	}
+
+	s := a.Stats()
+	if s.HandshakeRTT != 30*ms || s.SYNToSYNACK != 10*ms || s.SYNACKToACK != 20*ms {
+		t.Errorf("handshake: %v, %v, %v", s.HandshakeRTT, s.SYNToSYNACK, s.SYNACKToACK)
+	}
+	c := s.ClientToServer
+	if c.Packets != 13 || c.Bytes != 1621 || c.Retransmissions != 1 || c.FastRetransmissions != 1 ||
+		c.SpuriousRetransmissions != 1 || c.OutOfOrder != 1 || c.LostSegments != 2 || c.KeepAlives != 1 ||
+		c.ZeroWindowProbes != 1 || c.WindowFull != 1 {
+		t.Errorf("client counters: %+v", c)
+	}
+	if c.ACKRTTs != 3 || c.ACKRTTMin != 10*ms || c.ACKRTTMax != 10*ms {
+		t.Errorf("client RTT: %+v", c)
+	}
+	sc := s.ServerToClient
+	if sc.Packets != 11 || sc.DuplicateACKs != 2 || sc.ZeroWindows != 2 || sc.WindowUpdates != 1 || sc.ACKedUnseen != 1 {
+		t.Errorf("server counters: %+v", sc)
+	}
+	if sc.ACKRTTs != 1 || sc.ACKRTTSum != 20*ms {
+		t.Errorf("server RTT: %+v", sc)
+	}
+}
+
+func TestTCPAnalysisFlagsString(t *testing.T) {
+	if s := (TCPAnalysisRetransmission | TCPAnalysisWindowFull).String(); s != "Retransmission|WindowFull" {
+		t.Errorf("got %q", s)
+	}
+}
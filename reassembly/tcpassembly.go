@@ -16,6 +16,7 @@
 // data in stream order to that object.  A concurrency-safe StreamPool keeps
 // track of all current Streams being reassembled, so multiple Assemblers may
 // run at once to assemble packets while taking advantage of multiple cores.
+// ShardedAssembler runs such Assemblers, spreading connections over them.
 //
 // TODO: Add simplest example
 package reassembly
@@ -26,6 +27,7 @@ import (
 	"fmt"
 	"log"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/gopacket"
@@ -430,6 +432,8 @@ type halfconnection struct {
 	created, lastSeen time.Time
 	stream            Stream
 	closed            bool
+	policy            OverlapPolicy
+	policySet         bool
 	// for stats
 	queuedBytes    int
 	queuedPackets  int
@@ -466,6 +470,10 @@ type connection struct {
 	key      key // client->server
 	c2s, s2c halfconnection
 	mu       sync.Mutex
+	// links of the StreamPool's list in assembly order, protected by
+	// its evictMu
+	lruPrev, lruNext *connection
+	inLRU            bool
 }
 
 func (c *connection) reset(k key, s Stream, ts time.Time) {
@@ -521,6 +529,11 @@ type AssemblerOptions struct {
 	// particular connection, the smallest sequence number will be flushed, along
 	// with any contiguous data.  If <= 0, this is ignored.
 	MaxBufferedPagesPerConnection int
+	// TargetPolicy chooses the OverlapPolicy of each half connection by
+	// its destination address, once per half connection.  If nil,
+	// OverlapLast is used.  It is called concurrently by the shards of a
+	// ShardedAssembler.
+	TargetPolicy func(dst gopacket.Endpoint) OverlapPolicy
 }
 
 // Assembler handles reassembling TCP streams.  It is not safe for
@@ -578,10 +591,11 @@ type AssemblerOptions struct {
 // is done there, then very little allocation is done ever, mostly to handle
 // large increases in bandwidth or numbers of connections.
 //
-// TODO:  The page caches used by an Assembler will grow to the size necessary
-// to handle a workload, and currently will never shrink.  This means that
-// traffic spikes can result in large memory usage which isn't garbage
-// collected when typical traffic levels return.
+// The page caches used by an Assembler will grow to the size necessary to
+// handle a workload, and shrink back once pages are returned, so that memory
+// used during traffic spikes can be garbage collected when typical traffic
+// levels return.  StreamPoolOptions bounds the memory used by out-of-order
+// data over all Assemblers sharing a StreamPool.
 type Assembler struct {
 	AssemblerOptions
 	ret      []byteContainer
@@ -590,6 +604,8 @@ type Assembler struct {
 	cacheLP  livePacket
 	cacheSG  reassemblyObject
 	start    bool
+	// overlapBuf holds the bytes of a packet modified by its overlap policy
+	overlapBuf []byte
 }
 
 // NewAssembler creates a new assembler.  Pass in the StreamPool
@@ -603,7 +619,7 @@ func NewAssembler(pool *StreamPool) *Assembler {
 	pool.mu.Unlock()
 	return &Assembler{
 		ret:              make([]byteContainer, 0, assemblerReturnValueInitialSize),
-		pc:               newPageCache(),
+		pc:               newPageCache(&pool.bufferedBytes),
 		connPool:         pool,
 		AssemblerOptions: DefaultAssemblerOptions,
 	}
@@ -672,6 +688,12 @@ func (a *Assembler) AssembleWithContext(netFlow gopacket.Flow, t *layers.TCP, ac
 	ci := ac.GetCaptureInfo()
 	timestamp := ci.Timestamp
 
+	if a.connPool.full(key) && !t.RST && (t.SYN || len(t.Payload) != 0) {
+		if *debugLog {
+			log.Printf("hit max connections: %v", a.connPool.options.MaxConnections)
+		}
+		a.evictConnection()
+	}
 	conn, half, rev = a.connPool.getConnection(key, false, timestamp, t, ac)
 	if conn == nil {
 		if *debugLog {
@@ -679,11 +701,18 @@ func (a *Assembler) AssembleWithContext(netFlow gopacket.Flow, t *layers.TCP, ac
 		}
 		return
 	}
+	a.connPool.touch(conn)
 	conn.mu.Lock()
 	defer conn.mu.Unlock()
 	if half.lastSeen.Before(timestamp) {
 		half.lastSeen = timestamp
 	}
+	if !half.policySet {
+		if a.TargetPolicy != nil {
+			half.policy = a.TargetPolicy(netFlow.Dst())
+		}
+		half.policySet = true
+	}
 	a.start = half.nextSeq == invalidSequence
 	if *debugLog {
 		if half.nextSeq < rev.ackSeq {
@@ -781,13 +810,22 @@ func (a *Assembler) AssembleWithContext(netFlow gopacket.Flow, t *layers.TCP, ac
 			half.nextSeq = half.nextSeq.Add(1)
 		}
 	}
-	if a.MaxBufferedPagesTotal > 0 && a.pc.used >= a.MaxBufferedPagesTotal {
-		if *debugLog {
-			log.Printf("hit global max buffer size: %+v, %v", a.AssemblerOptions, a.pc.used)
-		}
+	overPages := a.MaxBufferedPagesTotal > 0 && a.pc.used >= a.MaxBufferedPagesTotal
+	if overPages || a.connPool.overBudget() {
 		conn.mu.Unlock()
 		defer conn.mu.Lock()
-		a.flushOldestConnectionWithPages()
+		if overPages {
+			if *debugLog {
+				log.Printf("hit global max buffer size: %+v, %v", a.AssemblerOptions, a.pc.used)
+			}
+			a.flushOldestConnectionWithPages()
+		}
+		if a.connPool.overBudget() {
+			if *debugLog {
+				log.Printf("hit max buffered bytes: %v", a.connPool.options.MaxBufferedBytes)
+			}
+			a.evictBufferedBytes()
+		}
 	}
 	if *debugLog {
 		log.Printf("%v nextSeq:%d", key, half.nextSeq)
@@ -801,8 +839,8 @@ func (a *Assembler) AssembleWithContext(netFlow gopacket.Flow, t *layers.TCP, ac
 //  - new packet overlaps existing queued packets:
 //	a) consider "age" by timestamp (TODO)
 //	b) consider "age" by being present
-//	Then
-//      1) discard new overlapping part
+//	Then, depending on the OverlapPolicy of the half connection
+//      1) discard new overlapping part (copy queued part over it)
 //      2) overwrite queued part
 
 func (a *Assembler) checkOverlap(half *halfconnection, queue bool, ac AssemblerContext) {
@@ -811,6 +849,7 @@ func (a *Assembler) checkOverlap(half *halfconnection, queue bool, ac AssemblerC
 	bytes := a.cacheLP.bytes
 	start := a.cacheLP.seq
 	end := start.Add(len(bytes))
+	owned := false
 
 	a.dump("before checkOverlap", half)
 
@@ -844,6 +883,7 @@ func (a *Assembler) checkOverlap(half *halfconnection, queue bool, ac AssemblerC
 
 		diffStart := start.Difference(cur.seq)
 		diffEnd := end.Difference(curEnd)
+		bytes, owned = a.resolveOverlap(half, cur, start, bytes, owned, ac)
 
 		// end > cur.end && start < cur.start: drop (3)
 		if diffEnd <= 0 && diffStart >= 0 {
@@ -890,8 +930,8 @@ func (a *Assembler) checkOverlap(half *halfconnection, queue bool, ac AssemblerC
 			next = cur
 		} else
 
-		// end < cur.end && start > cur.start: replace bytes inside cur (6)
-		if diffEnd > 0 && diffStart < 0 {
+		// end <= cur.end && start >= cur.start: replace bytes inside cur (6)
+		if diffEnd >= 0 && diffStart <= 0 {
 			if *debugLog {
 				log.Printf("case 6\n")
 			}
@@ -1055,6 +1095,59 @@ func (a *Assembler) flushOldestConnectionWithPages() {
 	}
 }
 
+// evictBufferedBytes flushes the out-of-order data of connections chosen by
+// the pool's EvictionPolicy until the pool is below MaxBufferedBytes.
+func (a *Assembler) evictBufferedBytes() {
+	for a.connPool.overBudget() {
+		conn, k := a.connPool.evictionCandidate(true)
+		if conn == nil {
+			return
+		}
+		conn.mu.Lock()
+		if a.connPool.has(conn, k) {
+			for _, half := range []*halfconnection{&conn.c2s, &conn.s2c} {
+				for half.first != nil {
+					n := 0
+					for p := half.first; p != nil; p = p.next {
+						n += len(p.bytes)
+					}
+					a.skipFlush(conn, half)
+					for p := half.first; p != nil; p = p.next {
+						n -= len(p.bytes)
+					}
+					atomic.AddInt64(&a.connPool.evictedBytes, int64(n))
+				}
+			}
+		}
+		conn.mu.Unlock()
+	}
+}
+
+// evictConnection flushes and closes the connection chosen by the pool's
+// EvictionPolicy, to make room for a new one.
+func (a *Assembler) evictConnection() {
+	for {
+		conn, k := a.connPool.evictionCandidate(false)
+		if conn == nil {
+			return
+		}
+		conn.mu.Lock()
+		if !a.connPool.has(conn, k) {
+			// someone removed it while we were not holding its lock
+			conn.mu.Unlock()
+			continue
+		}
+		if *debugLog {
+			log.Printf("evicting connection %v", conn.key)
+		}
+		a.flushCloseConnection(conn)
+		conn.mu.Unlock()
+		a.connPool.remove(conn)
+		atomic.AddInt64(&a.connPool.evictedConnections, 1)
+		return
+	}
+}
+
 // Prepare send or queue
 func (a *Assembler) handleBytes(bytes []byte, seq Sequence, half *halfconnection, ci gopacket.CaptureInfo, start bool, end bool, final bool, action assemblerAction, ac AssemblerContext) assemblerAction {
 	a.cacheLP.bytes = bytes
@@ -0,0 +1,255 @@
+// Copyright 2026 The GoPacket Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+// Package tcpreader provides an implementation for reassembly.Stream which
+// presents the caller with an io.Reader per direction of the connection.
+//
+// It is the counterpart of the tcpassembly/tcpreader package for the
+// reassembly package: reassembled data can be handed to any Go library
+// reading from an io.Reader, like net/http:
+//
+//  type httpStreamFactory struct{}
+//  func (f *httpStreamFactory) New(net, transport gopacket.Flow, tcp *layers.TCP, ac reassembly.AssemblerContext) reassembly.Stream {
+//  	s := tcpreader.NewReaderStream(tcpreader.DefaultReaderStreamOptions)
+//  	go printRequests(s.Reader(reassembly.TCPDirClientToServer))
+//  	go printResponses(s.Reader(reassembly.TCPDirServerToClient))
+//  	return s
+//  }
+//  func printRequests(r *tcpreader.Reader) {
+//  	buf := bufio.NewReader(r)
+//  	for {
+//  		req, err := http.ReadRequest(buf)
+//  		if err == io.EOF {
+//  			return
+//  		} else if err != nil {
+//  			log.Println("Error parsing HTTP requests:", err)
+//  			r.Close()
+//  			return
+//  		}
+//  		fmt.Println("HTTP REQUEST at", r.CaptureInfo().Timestamp, ":", req)
+//  		io.Copy(ioutil.Discard, req.Body)
+//  	}
+//  }
+//
+// Both readers must be read until io.EOF, or closed: once a direction has
+// buffered ReaderStreamOptions.BufferSize bytes, the Assembler blocks until
+// they are read.
+package tcpreader
+
+import (
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/reassembly"
+)
+
+// GapError is returned by Reader.Read when data is missing before the next
+// bytes of the stream, because packets were lost or flushed past.  Reading
+// can go on after a GapError, with the data following the gap.
+type GapError struct {
+	// Skipped is the number of missing bytes, -1 if unknown (the start of
+	// the stream was not seen)
+	Skipped int
+}
+
+func (e *GapError) Error() string {
+	if e.Skipped < 0 {
+		return "tcpreader: start of stream missing"
+	}
+	return fmt.Sprintf("tcpreader: %d bytes missing", e.Skipped)
+}
+
+// ReaderStreamOptions provides options for a ReaderStream.
+type ReaderStreamOptions struct {
+	// BufferSize is the number of bytes buffered per direction before
+	// ReassembledSG blocks until they are read.  If <= 0, ReassembledSG
+	// blocks until all its data is read.
+	BufferSize int
+}
+
+// DefaultReaderStreamOptions provides default options for a ReaderStream.
+var DefaultReaderStreamOptions = ReaderStreamOptions{
+	BufferSize: 64 * 1024,
+}
+
+// chunk is the data of a ReassembledSG call.
+type chunk struct {
+	data []byte
+	ci   gopacket.CaptureInfo
+	skip int
+}
+
+// Reader is an io.ReadCloser over the data of one direction of a
+// ReaderStream.
+type Reader struct {
+	mu       sync.Mutex
+	cond     *sync.Cond
+	chunks   []chunk
+	buffered int
+	size     int
+	// skip is the gap before the next chunk
+	skip   int
+	eof    bool
+	closed bool
+	ci     gopacket.CaptureInfo
+}
+
+func newReader(size int) *Reader {
+	r := &Reader{size: size}
+	if r.size < 0 {
+		r.size = 0
+	}
+	r.cond = sync.NewCond(&r.mu)
+	return r
+}
+
+// write queues a copy of data, blocking while more than the buffer size is
+// queued.
+func (r *Reader) write(data []byte, ci gopacket.CaptureInfo, skip int, end bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed || r.eof {
+		return
+	}
+	if skip != 0 {
+		r.skip = skip
+	}
+	if len(data) > 0 {
+		r.chunks = append(r.chunks, chunk{
+			data: append([]byte(nil), data...),
+			ci:   ci,
+			skip: r.skip,
+		})
+		r.buffered += len(data)
+		r.skip = 0
+	}
+	r.eof = end
+	r.cond.Broadcast()
+	for !r.closed && r.buffered > r.size {
+		r.cond.Wait()
+	}
+}
+
+// end marks the end of the data, once the queued data is read.
+func (r *Reader) end() {
+	r.mu.Lock()
+	r.eof = true
+	r.cond.Broadcast()
+	r.mu.Unlock()
+}
+
+// Read implements io.Reader's Read function.  It blocks until data is
+// available, and returns at most the data of one ReassembledSG call.  It
+// returns a *GapError, without data, when data is missing before the next
+// bytes, and io.EOF once the direction is over and all data has been read.
+func (r *Reader) Read(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for len(r.chunks) == 0 && !r.eof && !r.closed {
+		r.cond.Wait()
+	}
+	if len(r.chunks) == 0 {
+		return 0, io.EOF
+	}
+	c := &r.chunks[0]
+	r.ci = c.ci
+	if c.skip != 0 {
+		err := &GapError{Skipped: c.skip}
+		c.skip = 0
+		return 0, err
+	}
+	n := copy(p, c.data)
+	c.data = c.data[n:]
+	if len(c.data) == 0 {
+		r.chunks[0] = chunk{}
+		r.chunks = r.chunks[1:]
+	}
+	r.buffered -= n
+	r.cond.Broadcast()
+	return n, nil
+}
+
+// CaptureInfo returns the CaptureInfo of the packet starting the reassembled
+// data the last Read returned bytes or a GapError from.
+func (r *Reader) CaptureInfo() gopacket.CaptureInfo {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.ci
+}
+
+// Close implements io.Closer's Close function, making Reader an
+// io.ReadCloser.  It discards all remaining and future data of the
+// direction without blocking the Assembler; Read then returns io.EOF.
+func (r *Reader) Close() error {
+	r.mu.Lock()
+	r.closed = true
+	r.chunks = nil
+	r.buffered = 0
+	r.cond.Broadcast()
+	r.mu.Unlock()
+	return nil
+}
+
+// ReaderStream implements reassembly.Stream, presenting the data of each
+// direction of a connection through a Reader.  Every packet is accepted;
+// embed a ReaderStream in another type to override Accept.
+//
+// IMPORTANT: both Readers must be read until io.EOF or closed, otherwise
+// TCP stream reassembly blocks once BufferSize bytes are buffered.  It's a
+// common pattern to start goroutines reading them in the factory's New
+// method.
+type ReaderStream struct {
+	readers [2]*Reader
+}
+
+// NewReaderStream returns a new ReaderStream.
+func NewReaderStream(options ReaderStreamOptions) *ReaderStream {
+	return &ReaderStream{
+		readers: [2]*Reader{newReader(options.BufferSize), newReader(options.BufferSize)},
+	}
+}
+
+// Reader returns the Reader of the data sent in direction dir.
+func (s *ReaderStream) Reader(dir reassembly.TCPFlowDirection) *Reader {
+	if dir == reassembly.TCPDirClientToServer {
+		return s.readers[0]
+	}
+	return s.readers[1]
+}
+
+// Accept implements reassembly.Stream's Accept function, accepting every
+// packet.
+func (s *ReaderStream) Accept(tcp *layers.TCP, ci gopacket.CaptureInfo, dir reassembly.TCPFlowDirection, nextSeq reassembly.Sequence, start *bool, ac reassembly.AssemblerContext) reassembly.PacketDecision {
+	return reassembly.KeepDecision
+}
+
+// ReassembledSG implements reassembly.Stream's ReassembledSG function,
+// queuing the data to the Reader of its direction.  A FIN or RST ends that
+// direction.
+func (s *ReaderStream) ReassembledSG(sg reassembly.ScatterGather, flushing bool, ac reassembly.AssemblerContext) {
+	dir, start, end, skip := sg.Info()
+	if start {
+		// nothing is missing before the start of the stream
+		skip = 0
+	}
+	length, _ := sg.Lengths()
+	var data []byte
+	if length > 0 {
+		data = sg.Fetch(length)
+	}
+	s.Reader(dir).write(data, sg.CaptureInfo(0), skip, end)
+}
+
+// ReassemblyComplete implements reassembly.Stream's ReassemblyComplete
+// function, ending both directions.
+func (s *ReaderStream) ReassemblyComplete(ac reassembly.AssemblerContext) bool {
+	s.readers[0].end()
+	s.readers[1].end()
+	return true
+}
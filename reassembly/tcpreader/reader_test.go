@@ -0,0 +1,114 @@
+// Copyright 2026 The GoPacket Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package tcpreader
+
+import (
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket/reassembly"
+	"github.com/google/gopacket/reassembly/internal/streamtest"
+)
+
+// newAssembler returns an Assembler whose ReaderStreams are sent on the
+// returned channel.
+func newAssembler(options ReaderStreamOptions) (*reassembly.Assembler, chan *ReaderStream) {
+	streams := make(chan *ReaderStream, 1)
+	a := reassembly.NewAssembler(reassembly.NewStreamPool(streamtest.Factory(func() reassembly.Stream {
+		s := NewReaderStream(options)
+		streams <- s
+		return s
+	})))
+	return a, streams
+}
+
+func TestReaderStream(t *testing.T) {
+	a, streams := newAssembler(DefaultReaderStreamOptions)
+	ts := time.Unix(1000, 0)
+	streamtest.Assemble(a, 80, ts, []streamtest.Packet{
+		{C2S: true, Seq: 100, SYN: true},
+		{Seq: 500, SYN: true},
+		{C2S: true, Seq: 101, Payload: "GET / "},
+		{C2S: true, Seq: 107, Payload: "HTTP/1.0\r\n"},
+		{Seq: 501, Payload: "HTTP/1.0 200 OK\r\n"},
+		// lost packet before, flushed below
+		{C2S: true, Seq: 200, Payload: "more"},
+		{C2S: true, Seq: 204, FIN: true},
+	})
+	a.FlushAll()
+	s := <-streams
+
+	c2s := s.Reader(reassembly.TCPDirClientToServer)
+	buf := make([]byte, 4)
+	var got []byte
+	var gaps []int
+	var timestamps []time.Time
+	for {
+		n, err := c2s.Read(buf)
+		if gap, ok := err.(*GapError); ok {
+			gaps = append(gaps, gap.Skipped)
+			timestamps = append(timestamps, c2s.CaptureInfo().Timestamp)
+			continue
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, buf[:n]...)
+	}
+	if string(got) != "GET / HTTP/1.0\r\nmore" {
+		t.Errorf("client data: got %q", got)
+	}
+	if len(gaps) != 1 || gaps[0] != 200-117 {
+		t.Errorf("gaps: got %v, want [%d]", gaps, 200-117)
+	} else if !timestamps[0].Equal(ts.Add(5 * time.Second)) {
+		t.Errorf("gap at %v, want %v", timestamps[0], ts.Add(5*time.Second))
+	}
+
+	data, err := ioutil.ReadAll(s.Reader(reassembly.TCPDirServerToClient))
+	if err != nil || string(data) != "HTTP/1.0 200 OK\r\n" {
+		t.Errorf("server data: got %q, %v", data, err)
+	}
+}
+
+func TestReaderStreamBackpressure(t *testing.T) {
+	a, streams := newAssembler(ReaderStreamOptions{BufferSize: 4})
+	done := make(chan struct{})
+	go func() {
+		streamtest.Assemble(a, 80, time.Unix(1000, 0), []streamtest.Packet{
+			{C2S: true, Seq: 100, SYN: true},
+			{C2S: true, Seq: 101, Payload: "0123"},
+			{C2S: true, Seq: 105, Payload: "456789"},
+			{Seq: 500, SYN: true, Payload: "ignored"},
+		})
+		a.FlushAll()
+		close(done)
+	}()
+	s := <-streams
+	c2s := s.Reader(reassembly.TCPDirClientToServer)
+	// the server side is not read
+	s.Reader(reassembly.TCPDirServerToClient).Close()
+
+	buf := make([]byte, 2)
+	if n, err := c2s.Read(buf); n != 2 || err != nil || string(buf) != "01" {
+		t.Fatalf("first read: %d, %v", n, err)
+	}
+	select {
+	case <-done:
+		t.Fatal("assembly not blocked by unread data")
+	case <-time.After(50 * time.Millisecond):
+	}
+	data, err := ioutil.ReadAll(c2s)
+	if err != nil || string(data) != "23456789" {
+		t.Errorf("got %q, %v", data, err)
+	}
+	<-done
+}
@@ -0,0 +1,238 @@
+// Copyright 2026 The GoPacket Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+// Package tlsstream provides an implementation for reassembly.Stream which
+// cuts the reassembled data of a connection into TLS records.
+//
+// layers.TLS only decodes records that are complete within the data it is
+// given, so certificates and other large handshake messages sent over
+// several TCP segments fail to decode packet by packet.  A Stream buffers
+// each direction until its records are complete, decodes every record as a
+// layers.TLS and passes it to a Handler, along with events for the
+// handshake messages, alerts and change cipher specs it holds:
+//
+//	type handler struct{}
+//	func (h *handler) Record(tls *layers.TLS, dir reassembly.TCPFlowDirection, ci gopacket.CaptureInfo) {}
+//	func (h *handler) Event(e tlsstream.Event) {
+//		if e.Type == tlsstream.EventHandshake && e.Handshake.ClientHello != nil {
+//			fmt.Println("ClientHello at", e.CaptureInfo.Timestamp)
+//		}
+//	}
+//
+//	type streamFactory struct{}
+//	func (f *streamFactory) New(net, transport gopacket.Flow, tcp *layers.TCP, ac reassembly.AssemblerContext) reassembly.Stream {
+//		return tlsstream.NewStream(&handler{})
+//	}
+//
+// Handshake messages fragmented over several records are rebuilt with a
+// layers.TLSHandshakeReassembler per direction.  When data is missing, or
+// does not look like TLS records, the direction is resynchronized on the
+// next plausible record header.
+package tlsstream
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/reassembly"
+	"github.com/google/gopacket/reassembly/internal/framing"
+)
+
+// maxRecordLength is the longest record body allowed by RFC 5246 section
+// 6.2.3: 2^14 bytes of plaintext, plus 2048 bytes of expansion.
+const maxRecordLength = 1<<14 + 2048
+
+// recordHeaderLength is the length of the content type, version and length
+// of a record.
+const recordHeaderLength = 5
+
+// EventType is the type of an Event.
+type EventType uint8
+
+// EventType known values.
+const (
+	// EventHandshake is a complete handshake message.
+	EventHandshake EventType = iota + 1
+	// EventChangeCipherSpec is a change cipher spec record: the following
+	// records of the direction are encrypted.
+	EventChangeCipherSpec
+	// EventAlert is an alert record.
+	EventAlert
+	// EventDesync means that data of the direction was lost or could not
+	// be decoded as TLS records, and that records are being looked for
+	// again.
+	EventDesync
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventHandshake:
+		return "Handshake"
+	case EventChangeCipherSpec:
+		return "ChangeCipherSpec"
+	case EventAlert:
+		return "Alert"
+	case EventDesync:
+		return "Desync"
+	}
+	return "Unknown"
+}
+
+// Event is something that happened on a direction of a TLS connection.
+type Event struct {
+	Type      EventType
+	Direction reassembly.TCPFlowDirection
+	// CaptureInfo is the one of the segment holding the last byte of the
+	// record of the event, or of the first data following a desync.
+	CaptureInfo gopacket.CaptureInfo
+	// Handshake is the message of an EventHandshake.
+	Handshake *layers.TLSHandshakeMessage
+	// Alert is the record of an EventAlert.  Alerts sent after a change
+	// cipher spec are encrypted, see layers.TLSAlertRecord.
+	Alert *layers.TLSAlertRecord
+	// Err is the reason of an EventDesync.
+	Err error
+}
+
+// Handler receives what a Stream decodes.  For every record, Record is
+// called first, then Event for each event of the record, so that events of
+// a direction come in the order they were sent.
+type Handler interface {
+	// Record is called with each complete record, decoded as a TLS layer
+	// holding just that record, and the CaptureInfo of the segment
+	// holding its last byte.  The layer is not reused by the Stream.
+	Record(tls *layers.TLS, dir reassembly.TCPFlowDirection, ci gopacket.CaptureInfo)
+	// Event is called for the handshake messages, alerts and change
+	// cipher specs of records, and when a direction loses track of
+	// records.
+	Event(e Event)
+}
+
+var errNotTLS = errors.New("tlsstream: data is not a TLS record")
+
+type direction struct {
+	records framing.Buffer
+	hs      layers.TLSHandshakeReassembler
+	// encrypted is set once a change cipher spec was seen
+	encrypted bool
+}
+
+// desync drops the incomplete handshake message of d, keeping track of
+// whether its records are encrypted.
+func (d *direction) desync() {
+	d.hs.Reset()
+	if d.encrypted {
+		d.hs.ChangeCipherSpec()
+	}
+}
+
+// Stream implements reassembly.Stream, decoding the TLS records of both
+// directions of a connection.  It is not safe for concurrent use, which the
+// reassembly package does not need.
+type Stream struct {
+	handler Handler
+	dirs    [2]direction
+}
+
+// NewStream creates a stream passing what it decodes to h.
+func NewStream(h Handler) *Stream {
+	s := &Stream{handler: h}
+	for i, dir := range []reassembly.TCPFlowDirection{reassembly.TCPDirClientToServer, reassembly.TCPDirServerToClient} {
+		d, dir := &s.dirs[i], dir
+		d.records = framing.Buffer{
+			Name:         "tlsstream",
+			HeaderLength: recordHeaderLength,
+			ValidHeader:  validHeader,
+			Invalid:      errNotTLS,
+			OnUnit: func(record []byte, ci gopacket.CaptureInfo) {
+				s.decode(d, dir, record, ci)
+			},
+			OnDesync: func(ci gopacket.CaptureInfo, err error) {
+				d.desync()
+				s.handler.Event(Event{Type: EventDesync, Direction: dir, CaptureInfo: ci, Err: err})
+			},
+		}
+	}
+	return s
+}
+
+func (s *Stream) direction(dir reassembly.TCPFlowDirection) *direction {
+	if dir == reassembly.TCPDirClientToServer {
+		return &s.dirs[0]
+	}
+	return &s.dirs[1]
+}
+
+// Accept implements reassembly.Stream's Accept function, accepting every
+// packet.
+func (s *Stream) Accept(tcp *layers.TCP, ci gopacket.CaptureInfo, dir reassembly.TCPFlowDirection, nextSeq reassembly.Sequence, start *bool, ac reassembly.AssemblerContext) reassembly.PacketDecision {
+	return reassembly.KeepDecision
+}
+
+// validHeader returns whether data starts with a plausible record header,
+// and the length of the record.
+func validHeader(data []byte) (int, bool) {
+	if data[0] < byte(layers.TLSChangeCipherSpec) || data[0] > byte(layers.TLSApplicationData) {
+		return 0, false
+	}
+	// SSL 3.0 to TLS 1.3, which still uses the TLS 1.2 record version
+	if data[1] != 3 || data[2] > 4 {
+		return 0, false
+	}
+	length := int(binary.BigEndian.Uint16(data[3:5]))
+	if length == 0 || length > maxRecordLength {
+		return 0, false
+	}
+	return recordHeaderLength + length, true
+}
+
+// ReassembledSG implements reassembly.Stream's ReassembledSG function,
+// buffering the data of its direction and decoding the records it
+// completes.
+func (s *Stream) ReassembledSG(sg reassembly.ScatterGather, flushing bool, ac reassembly.AssemblerContext) {
+	dir, _, _, _ := sg.Info()
+	s.direction(dir).records.Reassembled(sg)
+}
+
+// decode decodes a complete record, and passes it and its events to the
+// handler.
+func (s *Stream) decode(d *direction, dir reassembly.TCPFlowDirection, record []byte, ci gopacket.CaptureInfo) {
+	tls := &layers.TLS{}
+	if err := tls.DecodeWithReassembler(record, &d.hs, gopacket.NilDecodeFeedback); err != nil {
+		d.records.Desync(ci, err)
+		return
+	}
+	s.handler.Record(tls, dir, ci)
+
+	e := Event{Direction: dir, CaptureInfo: ci}
+	switch tls.RecordTypes[0] {
+	case layers.TLSHandshake:
+		e.Type = EventHandshake
+		msgs := tls.Handshake[0].Messages
+		for i := range msgs {
+			e.Handshake = &msgs[i]
+			s.handler.Event(e)
+		}
+	case layers.TLSChangeCipherSpec:
+		d.encrypted = true
+		e.Type = EventChangeCipherSpec
+		s.handler.Event(e)
+	case layers.TLSAlert:
+		e.Type = EventAlert
+		e.Alert = &tls.Alert[0]
+		s.handler.Event(e)
+	}
+}
+
+// ReassemblyComplete implements reassembly.Stream's ReassemblyComplete
+// function.  Incomplete records left in the buffers are dropped.
+func (s *Stream) ReassemblyComplete(ac reassembly.AssemblerContext) bool {
+	s.dirs[0].records.Reset()
+	s.dirs[1].records.Reset()
+	return true
+}
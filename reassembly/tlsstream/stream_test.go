@@ -0,0 +1,127 @@
+// Copyright 2026 The GoPacket Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package tlsstream
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/reassembly"
+	"github.com/google/gopacket/reassembly/internal/streamtest"
+)
+
+type testHandler struct {
+	records map[reassembly.TCPFlowDirection][]*layers.TLS
+	times   map[reassembly.TCPFlowDirection][]time.Time
+	events  map[reassembly.TCPFlowDirection][]Event
+}
+
+func (h *testHandler) Record(tls *layers.TLS, dir reassembly.TCPFlowDirection, ci gopacket.CaptureInfo) {
+	h.records[dir] = append(h.records[dir], tls)
+	h.times[dir] = append(h.times[dir], ci.Timestamp)
+}
+
+func (h *testHandler) Event(e Event) {
+	h.events[e.Direction] = append(h.events[e.Direction], e)
+}
+
+func record(ct layers.TLSType, body ...[]byte) []byte {
+	b := bytes.Join(body, nil)
+	return append([]byte{byte(ct), 3, 3, byte(len(b) >> 8), byte(len(b))}, b...)
+}
+
+func TestStream(t *testing.T) {
+	h := &testHandler{
+		records: map[reassembly.TCPFlowDirection][]*layers.TLS{},
+		times:   map[reassembly.TCPFlowDirection][]time.Time{},
+		events:  map[reassembly.TCPFlowDirection][]Event{},
+	}
+	a := reassembly.NewAssembler(reassembly.NewStreamPool(streamtest.Factory(func() reassembly.Stream { return NewStream(h) })))
+	ts := time.Unix(1000, 0)
+
+	// a Certificate message fragmented over two records, themselves
+	// spread over three segments
+	cert := bytes.Repeat([]byte{0x30}, 3000)
+	certMsg := append([]byte{11, 0, 0x0b, 0xbe, 0, 0x0b, 0xbb, 0, 0x0b, 0xb8}, cert...)
+	r1 := record(layers.TLSHandshake, certMsg[:2000])
+	r2 := record(layers.TLSHandshake, certMsg[2000:], []byte{14, 0, 0, 0})
+	alert := record(layers.TLSAlert, []byte{1, 0})
+	server := append(append(append([]byte(nil), r1...), r2...), alert...)
+
+	client := append(record(layers.TLSHandshake, []byte{16, 0, 0, 4, 1, 2, 3, 4}), record(layers.TLSChangeCipherSpec, []byte{1})...)
+	finished := record(layers.TLSHandshake, bytes.Repeat([]byte{0x17}, 40))
+
+	streamtest.Assemble(a, 443, ts, []streamtest.Packet{
+		{C2S: true, Seq: 100, SYN: true},
+		{Seq: 500, SYN: true},
+		{Seq: 501, Payload: string(server[:1000])},
+		{Seq: 1501, Payload: string(server[1000 : len(r1)+10])},
+		{Seq: 501 + uint32(len(r1)) + 10, Payload: string(server[len(r1)+10:])},
+		{C2S: true, Seq: 101, Payload: string(client)},
+		{C2S: true, Seq: 101 + uint32(len(client)), Payload: string(finished)},
+		// lost packet before, flushed below
+		{C2S: true, Seq: 2000, Payload: "xyz" + string(alert)},
+	})
+	a.FlushAll()
+
+	s2c := reassembly.TCPDirServerToClient
+	if len(h.records[s2c]) != 3 {
+		t.Fatalf("server records: got %d", len(h.records[s2c]))
+	}
+	for i, want := range []time.Time{ts.Add(3 * time.Second), ts.Add(4 * time.Second), ts.Add(4 * time.Second)} {
+		if !h.times[s2c][i].Equal(want) {
+			t.Errorf("server record %d at %v, want %v", i, h.times[s2c][i], want)
+		}
+	}
+	if !bytes.Equal(h.records[s2c][1].Contents, r2) {
+		t.Errorf("second server record: got %d bytes", len(h.records[s2c][1].Contents))
+	}
+	events := h.events[s2c]
+	if len(events) != 3 {
+		t.Fatalf("server events: got %+v", events)
+	}
+	if e := events[0]; e.Type != EventHandshake || e.Handshake.Certificate == nil ||
+		len(e.Handshake.Certificate.Certificates) != 1 || !bytes.Equal(e.Handshake.Certificate.Certificates[0], cert) {
+		t.Errorf("certificate event: %+v", e)
+	}
+	if e := events[1]; e.Type != EventHandshake || e.Handshake.Type != layers.TLSHandshakeServerHelloDone {
+		t.Errorf("server hello done event: %+v", e)
+	}
+	if e := events[2]; e.Type != EventAlert || e.Alert.Level != layers.TLSAlertWarning || e.Alert.Description != layers.TLSAlertCloseNotify {
+		t.Errorf("alert event: %+v", e)
+	}
+
+	c2s := reassembly.TCPDirClientToServer
+	if len(h.records[c2s]) != 4 {
+		t.Fatalf("client records: got %d", len(h.records[c2s]))
+	}
+	if hs := h.records[c2s][2].Handshake; len(hs) != 1 || !hs[0].Encrypted {
+		t.Errorf("handshake after change cipher spec: %+v", hs)
+	}
+	var types []EventType
+	for _, e := range h.events[c2s] {
+		types = append(types, e.Type)
+	}
+	want := []EventType{EventHandshake, EventChangeCipherSpec, EventDesync, EventAlert}
+	if len(types) != len(want) {
+		t.Fatalf("client events: got %v, want %v", types, want)
+	}
+	for i := range want {
+		if types[i] != want[i] {
+			t.Errorf("client event %d: got %v, want %v", i, types[i], want[i])
+		}
+	}
+	if e := h.events[c2s][0]; e.Handshake.ClientKeyExchange == nil || !bytes.Equal(e.Handshake.ClientKeyExchange.Data, []byte{1, 2, 3, 4}) {
+		t.Errorf("client key exchange event: %+v", e)
+	}
+	if e := h.events[c2s][2]; e.Err == nil || !e.CaptureInfo.Timestamp.Equal(ts.Add(7*time.Second)) {
+		t.Errorf("desync event: %+v", e)
+	}
+}
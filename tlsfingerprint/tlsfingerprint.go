@@ -0,0 +1,383 @@
+// Copyright 2026 The GoPacket Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+// Package tlsfingerprint computes JA3, JA3S and JA4 fingerprints of TLS
+// clients and servers.
+//
+// Fingerprints are computed from the ClientHello and ServerHello messages
+// decoded by layers.TLS, either straight from a packet:
+//
+//	if tls, ok := packet.Layer(layers.LayerTypeTLS).(*layers.TLS); ok {
+//	    clients, servers := tlsfingerprint.FromTLS(tls)
+//	    ...
+//	}
+//
+// or from the beginning of a reassembled TCP stream, when the hello does not
+// fit in a single packet:
+//
+//	hello, err := tlsfingerprint.ParseClientHello(streamData)
+//	if err == tlsfingerprint.ErrIncomplete {
+//	    // wait for more data
+//	}
+//	fp := tlsfingerprint.Client(hello, tlsfingerprint.ProtocolTCP)
+//
+// GREASE values (RFC 8701) are ignored everywhere, as required by both
+// fingerprint specifications.
+//
+// References
+//
+//	https://github.com/salesforce/ja3
+//	https://github.com/FoxIO-LLC/ja4/blob/main/technical_details/JA4.md
+package tlsfingerprint
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google/gopacket/layers"
+)
+
+// Protocol is the transport a ClientHello was carried on. It is the first
+// character of a JA4 fingerprint.
+type Protocol byte
+
+// Protocol known values
+const (
+	ProtocolTCP  Protocol = 't'
+	ProtocolQUIC Protocol = 'q'
+	ProtocolDTLS Protocol = 'd'
+)
+
+// ClientFingerprint holds the fingerprints of a ClientHello.
+type ClientFingerprint struct {
+	// JA3 is the full JA3 string, JA3Hash its MD5 digest.
+	JA3     string
+	JA3Hash string
+	// JA4 is the JA4 fingerprint, JA4R its raw (unhashed) form.
+	JA4  string
+	JA4R string
+}
+
+// ServerFingerprint holds the fingerprints of a ServerHello.
+type ServerFingerprint struct {
+	// JA3S is the full JA3S string, JA3SHash its MD5 digest.
+	JA3S     string
+	JA3SHash string
+}
+
+// IsGREASE returns true if v is one of the reserved GREASE values of RFC
+// 8701 (0x0a0a, 0x1a1a, ..., 0xfafa).
+func IsGREASE(v uint16) bool {
+	return v&0x0f0f == 0x0a0a && v>>8 == v&0xff
+}
+
+// Client computes the fingerprints of a ClientHello sent over proto.
+func Client(ch *layers.TLSClientHello, proto Protocol) ClientFingerprint {
+	var fp ClientFingerprint
+	fp.JA3 = JA3(ch)
+	fp.JA3Hash = md5Hex(fp.JA3)
+	fp.JA4, fp.JA4R = ja4(ch, proto)
+	return fp
+}
+
+// Server computes the fingerprints of a ServerHello.
+func Server(sh *layers.TLSServerHello) ServerFingerprint {
+	var fp ServerFingerprint
+	fp.JA3S = JA3S(sh)
+	fp.JA3SHash = md5Hex(fp.JA3S)
+	return fp
+}
+
+// FromTLS computes the fingerprints of every ClientHello and ServerHello
+// found in a TLS layer decoded from a TCP segment.
+func FromTLS(t *layers.TLS) (clients []ClientFingerprint, servers []ServerFingerprint) {
+	for i := range t.Handshake {
+		for _, m := range t.Handshake[i].Messages {
+			switch {
+			case m.ClientHello != nil:
+				clients = append(clients, Client(m.ClientHello, ProtocolTCP))
+			case m.ServerHello != nil:
+				servers = append(servers, Server(m.ServerHello))
+			}
+		}
+	}
+	return
+}
+
+// ErrIncomplete is returned by ParseClientHello and ParseServerHello when
+// data ends before the hello message does.
+var ErrIncomplete = errors.New("tlsfingerprint: incomplete hello message")
+
+// ParseClientHello decodes the ClientHello at the beginning of data, which
+// holds the first bytes sent by a TLS client (a sequence of TLS records,
+// e.g. from a reassembled stream). The hello may span several records.
+func ParseClientHello(data []byte) (*layers.TLSClientHello, error) {
+	m, err := parseHello(data, layers.TLSHandshakeClientHello)
+	if err != nil {
+		return nil, err
+	}
+	return m.ClientHello, nil
+}
+
+// ParseServerHello decodes the ServerHello at the beginning of data, which
+// holds the first bytes sent by a TLS server.
+func ParseServerHello(data []byte) (*layers.TLSServerHello, error) {
+	m, err := parseHello(data, layers.TLSHandshakeServerHello)
+	if err != nil {
+		return nil, err
+	}
+	return m.ServerHello, nil
+}
+
+func parseHello(data []byte, ht layers.TLSHandshakeType) (*layers.TLSHandshakeMessage, error) {
+	var hs layers.TLSHandshakeReassembler
+	for len(data) >= 5 {
+		if layers.TLSType(data[0]) != layers.TLSHandshake {
+			return nil, fmt.Errorf("tlsfingerprint: unexpected %s record", layers.TLSType(data[0]))
+		}
+		l := int(binary.BigEndian.Uint16(data[3:5]))
+		if len(data) < 5+l {
+			break
+		}
+		msgs, err := hs.Add(data[5 : 5+l])
+		if err != nil {
+			return nil, fmt.Errorf("tlsfingerprint: %v", err)
+		}
+		if len(msgs) > 0 {
+			if msgs[0].Type != ht {
+				return nil, fmt.Errorf("tlsfingerprint: expected %s, got %s", ht, msgs[0].Type)
+			}
+			return &msgs[0], nil
+		}
+		data = data[5+l:]
+	}
+	return nil, ErrIncomplete
+}
+
+// JA3 returns the JA3 string of a ClientHello:
+// SSLVersion,Ciphers,Extensions,EllipticCurves,EllipticCurvePointFormats
+func JA3(ch *layers.TLSClientHello) string {
+	var b strings.Builder
+	b.WriteString(strconv.Itoa(int(ch.Version)))
+	b.WriteByte(',')
+	for i, c := range nonGREASE(ciphers(ch.CipherSuites)) {
+		writeDecimal(&b, i, c)
+	}
+	b.WriteByte(',')
+	for i, e := range nonGREASE(extensions(ch.Extensions)) {
+		writeDecimal(&b, i, e)
+	}
+	b.WriteByte(',')
+	for i, g := range nonGREASE(groups(ch.SupportedGroups)) {
+		writeDecimal(&b, i, g)
+	}
+	b.WriteByte(',')
+	for i, f := range ch.ECPointFormats {
+		writeDecimal(&b, i, uint16(f))
+	}
+	return b.String()
+}
+
+// JA3S returns the JA3S string of a ServerHello:
+// SSLVersion,Cipher,Extensions
+func JA3S(sh *layers.TLSServerHello) string {
+	var b strings.Builder
+	b.WriteString(strconv.Itoa(int(sh.Version)))
+	b.WriteByte(',')
+	b.WriteString(strconv.Itoa(int(sh.CipherSuite)))
+	b.WriteByte(',')
+	for i, e := range nonGREASE(extensions(sh.Extensions)) {
+		writeDecimal(&b, i, e)
+	}
+	return b.String()
+}
+
+// JA4 returns the JA4 fingerprint of a ClientHello sent over proto.
+func JA4(ch *layers.TLSClientHello, proto Protocol) string {
+	fp, _ := ja4(ch, proto)
+	return fp
+}
+
+func ja4(ch *layers.TLSClientHello, proto Protocol) (fp, raw string) {
+	suites := nonGREASE(ciphers(ch.CipherSuites))
+	exts := nonGREASE(extensions(ch.Extensions))
+
+	// JA4_a
+	var a strings.Builder
+	a.WriteByte(byte(proto))
+	a.WriteString(ja4Version(ch))
+	if ch.HasExtension(layers.TLSExtServerName) {
+		a.WriteByte('d')
+	} else {
+		a.WriteByte('i')
+	}
+	fmt.Fprintf(&a, "%02d%02d", min99(len(suites)), min99(len(exts)))
+	a.WriteString(ja4ALPN(ch.ALPN))
+
+	// JA4_b: sorted cipher suites
+	sort.Slice(suites, func(i, j int) bool { return suites[i] < suites[j] })
+	b := hexList(suites)
+
+	// JA4_c: sorted extensions without SNI and ALPN, then signature
+	// algorithms in their original order
+	var sorted []uint16
+	for _, e := range exts {
+		if e != uint16(layers.TLSExtServerName) && e != uint16(layers.TLSExtALPN) {
+			sorted = append(sorted, e)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	c := hexList(sorted)
+	var sigs []uint16
+	for _, s := range ch.SignatureAlgorithms {
+		sigs = append(sigs, uint16(s))
+	}
+	if sigs = nonGREASE(sigs); len(sigs) > 0 {
+		c += "_" + hexList(sigs)
+	}
+
+	fp = a.String() + "_" + ja4Hash(b, len(suites) == 0) + "_" + ja4Hash(c, len(sorted) == 0)
+	raw = a.String() + "_" + b + "_" + c
+	return
+}
+
+func ja4Version(ch *layers.TLSClientHello) string {
+	v := ch.Version
+	var best uint16
+	for _, sv := range ch.SupportedVersions {
+		if !IsGREASE(uint16(sv)) && (best == 0 || laterVersion(uint16(sv), best)) {
+			best = uint16(sv)
+		}
+	}
+	if best != 0 {
+		v = layers.TLSVersion(best)
+	}
+	switch v {
+	case 0x0304:
+		return "13"
+	case 0x0303:
+		return "12"
+	case 0x0302:
+		return "11"
+	case 0x0301:
+		return "10"
+	case 0x0300:
+		return "s3"
+	case 0x0200:
+		return "s2"
+	case 0xfeff:
+		return "d1"
+	case 0xfefd:
+		return "d2"
+	case 0xfefc:
+		return "d3"
+	}
+	return "00"
+}
+
+// laterVersion returns whether version a is later than b. DTLS versions
+// count down from 0xfeff (DTLS 1.0).
+func laterVersion(a, b uint16) bool {
+	if a>>8 == 0xfe && b>>8 == 0xfe {
+		return a < b
+	}
+	return a > b
+}
+
+func ja4ALPN(alpn []string) string {
+	if len(alpn) == 0 || len(alpn[0]) == 0 {
+		return "00"
+	}
+	first, last := alpn[0][0], alpn[0][len(alpn[0])-1]
+	if isAlnum(first) && isAlnum(last) {
+		return string([]byte{first, last})
+	}
+	h := hex.EncodeToString([]byte(alpn[0]))
+	return string([]byte{h[0], h[len(h)-1]})
+}
+
+func ja4Hash(s string, empty bool) string {
+	if empty {
+		return "000000000000"
+	}
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+func isAlnum(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z'
+}
+
+func min99(n int) int {
+	if n > 99 {
+		return 99
+	}
+	return n
+}
+
+func md5Hex(s string) string {
+	sum := md5.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func writeDecimal(b *strings.Builder, i int, v uint16) {
+	if i > 0 {
+		b.WriteByte('-')
+	}
+	b.WriteString(strconv.Itoa(int(v)))
+}
+
+func hexList(vs []uint16) string {
+	var b strings.Builder
+	for i, v := range vs {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, "%04x", v)
+	}
+	return b.String()
+}
+
+func nonGREASE(vs []uint16) []uint16 {
+	out := vs[:0]
+	for _, v := range vs {
+		if !IsGREASE(v) {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func ciphers(cs []layers.TLSCipherSuite) []uint16 {
+	out := make([]uint16, len(cs))
+	for i, c := range cs {
+		out[i] = uint16(c)
+	}
+	return out
+}
+
+func extensions(es []layers.TLSExtension) []uint16 {
+	out := make([]uint16, len(es))
+	for i, e := range es {
+		out[i] = uint16(e.Type)
+	}
+	return out
+}
+
+func groups(gs []layers.TLSNamedGroup) []uint16 {
+	out := make([]uint16, len(gs))
+	for i, g := range gs {
+		out[i] = uint16(g)
+	}
+	return out
+}
@@ -0,0 +1,216 @@
+// Copyright 2026 The GoPacket Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package tlsfingerprint
+
+import (
+	"os"
+	"testing"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+)
+
+// test_tls.pcap holds, in order:
+//   - ClientHello and ServerHello of a TLS 1.0 session (scapy-ssl_tls
+//     RSA_WITH_AES_128_CBC_SHA.pcap, also used by the layers tests)
+//   - ClientHello and ServerHello of a TLS 1.3 session between crypto/tls
+//     client and server
+const testTLSPcap = "../pcap/test_tls.pcap"
+
+var testDecodeOptions = gopacket.DecodeOptions{DecodeStreamsAsDatagrams: true}
+
+var testGoldenClients = []ClientFingerprint{
+	{
+		JA3:     "769,49172-49162-57-56-136-135-49167-49157-53-132-49171-49161-51-50-154-153-69-68-49166-49156-47-150-65-49169-49159-49164-49154-5-4-49170-49160-22-19-49165-49155-10-21-18-9-20-17-8-6-3-255,11-10-35-15,14-13-25-11-12-24-9-10-22-23-8-6-7-20-21-4-5-18-19-1-2-3-15-16-17,0-1-2",
+		JA3Hash: "2648a3430cfab8cd9cfe83dd572bee30",
+		JA4:     "t10i450400_a38737f0bdfa_282f11336259",
+		JA4R:    "t10i450400_0003,0004,0005,0006,0008,0009,000a,0011,0012,0013,0014,0015,0016,002f,0032,0033,0035,0038,0039,0041,0044,0045,0084,0087,0088,0096,0099,009a,00ff,c002,c003,c004,c005,c007,c008,c009,c00a,c00c,c00d,c00e,c00f,c011,c012,c013,c014_000a,000b,000f,0023",
+	},
+	{
+		JA3:     "771,49195-49199-49196-49200-52393-52392-49161-49171-49162-49172-4865-4866-4867,0-11-65281-23-18-5-10-13-50-16-43-51,29-23-24-25,0",
+		JA3Hash: "95b6f6d62c2c0f5258859e829e0055f5",
+		JA4:     "t13d1312h2_f57a46bbacb6_a089bac06eae",
+		JA4R:    "t13d1312h2_1301,1302,1303,c009,c00a,c013,c014,c02b,c02c,c02f,c030,cca8,cca9_0005,000a,000b,000d,0012,0017,002b,0032,0033,ff01_0904,0905,0906,0804,0403,0807,0805,0806,0401,0501,0601,0503,0603,0201,0203",
+	},
+}
+
+var testGoldenServers = []ServerFingerprint{
+	{JA3S: "769,47,65281-35-15", JA3SHash: "d34cdf3ab2ca82a6542791bde391a97e"},
+	{JA3S: "771,4865,43-51", JA3SHash: "f4febc55ea12b31ae17cfb7e614afda8"},
+}
+
+func readTestPayloads(t *testing.T) [][]byte {
+	f, err := os.Open(testTLSPcap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	r, err := pcapgo.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var payloads [][]byte
+	for {
+		data, _, err := r.ReadPacketData()
+		if err != nil {
+			break
+		}
+		p := gopacket.NewPacket(data, r.LinkType(), testDecodeOptions)
+		tcp, ok := p.Layer(layers.LayerTypeTCP).(*layers.TCP)
+		if !ok {
+			t.Fatal("No TCP layer in test packet")
+		}
+		payloads = append(payloads, tcp.Payload)
+	}
+	return payloads
+}
+
+func TestGoldenPcap(t *testing.T) {
+	f, err := os.Open(testTLSPcap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	r, err := pcapgo.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var clients []ClientFingerprint
+	var servers []ServerFingerprint
+	for {
+		data, _, err := r.ReadPacketData()
+		if err != nil {
+			break
+		}
+		p := gopacket.NewPacket(data, r.LinkType(), testDecodeOptions)
+		tls, ok := p.Layer(layers.LayerTypeTLS).(*layers.TLS)
+		if !ok {
+			t.Fatal("No TLS layer in test packet")
+		}
+		c, s := FromTLS(tls)
+		clients = append(clients, c...)
+		servers = append(servers, s...)
+	}
+
+	if len(clients) != len(testGoldenClients) || len(servers) != len(testGoldenServers) {
+		t.Fatalf("Got %d client and %d server fingerprints", len(clients), len(servers))
+	}
+	for i, want := range testGoldenClients {
+		if clients[i] != want {
+			t.Errorf("Client %d mismatch:\ngot:  %+v\nwant: %+v", i, clients[i], want)
+		}
+	}
+	for i, want := range testGoldenServers {
+		if servers[i] != want {
+			t.Errorf("Server %d mismatch:\ngot:  %+v\nwant: %+v", i, servers[i], want)
+		}
+	}
+}
+
+func TestParseHelloFromStream(t *testing.T) {
+	payloads := readTestPayloads(t)
+	ch := payloads[2]
+
+	// Re-frame the ClientHello message as a stream of small records
+	msg := ch[5:]
+	var stream []byte
+	for len(msg) > 0 {
+		n := 50
+		if n > len(msg) {
+			n = len(msg)
+		}
+		stream = append(stream, 0x16, 0x03, 0x01, 0, byte(n))
+		stream = append(stream, msg[:n]...)
+		msg = msg[n:]
+	}
+
+	if _, err := ParseClientHello(stream[:len(stream)-1]); err != ErrIncomplete {
+		t.Errorf("Expected ErrIncomplete on truncated stream, got %v", err)
+	}
+	hello, err := ParseClientHello(stream)
+	if err != nil {
+		t.Fatal("ParseClientHello failed:", err)
+	}
+	if got := Client(hello, ProtocolTCP); got != testGoldenClients[1] {
+		t.Errorf("Stream fingerprint mismatch:\ngot:  %+v\nwant: %+v", got, testGoldenClients[1])
+	}
+	if _, err := ParseServerHello(stream); err == nil {
+		t.Error("ParseServerHello accepted a ClientHello")
+	}
+
+	sh, err := ParseServerHello(payloads[1])
+	if err != nil {
+		t.Fatal("ParseServerHello failed:", err)
+	}
+	if got := Server(sh); got != testGoldenServers[0] {
+		t.Errorf("Stream fingerprint mismatch:\ngot:  %+v\nwant: %+v", got, testGoldenServers[0])
+	}
+}
+
+func TestGREASE(t *testing.T) {
+	for v := 0; v < 0x10000; v++ {
+		want := v&0xff == v>>8 && v&0x0f == 0x0a
+		if got := IsGREASE(uint16(v)); got != want {
+			t.Errorf("IsGREASE(0x%04x) = %v", v, got)
+		}
+	}
+
+	payloads := readTestPayloads(t)
+	hello, err := ParseClientHello(payloads[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	hello.CipherSuites = append([]layers.TLSCipherSuite{0x0a0a}, hello.CipherSuites...)
+	hello.Extensions = append([]layers.TLSExtension{{Type: 0x2a2a}}, hello.Extensions...)
+	hello.Extensions = append(hello.Extensions, layers.TLSExtension{Type: 0xfafa, Data: []byte{0}})
+	hello.SupportedGroups = append([]layers.TLSNamedGroup{0x3a3a}, hello.SupportedGroups...)
+	hello.SupportedVersions = append([]layers.TLSVersion{0x4a4a}, hello.SupportedVersions...)
+	hello.SignatureAlgorithms = append([]layers.TLSSignatureScheme{0x5a5a}, hello.SignatureAlgorithms...)
+	if got := Client(hello, ProtocolTCP); got != testGoldenClients[1] {
+		t.Errorf("GREASE values not ignored:\ngot:  %+v\nwant: %+v", got, testGoldenClients[1])
+	}
+}
+
+func TestJA4Version(t *testing.T) {
+	for _, tc := range []struct {
+		version   layers.TLSVersion
+		supported []layers.TLSVersion
+		want      string
+	}{
+		{0x0303, nil, "12"},
+		{0x0303, []layers.TLSVersion{0x0a0a, 0x0304, 0x0303}, "13"},
+		{0x0303, []layers.TLSVersion{0x0303, 0x0304}, "13"},
+		{0xfefd, nil, "d2"},
+		{0xfefd, []layers.TLSVersion{0xfefc, 0xfefd}, "d3"},
+		{0xfefd, []layers.TLSVersion{0xfeff, 0xfefd}, "d2"},
+	} {
+		ch := &layers.TLSClientHello{Version: tc.version}
+		ch.SupportedVersions = tc.supported
+		if got := ja4Version(ch); got != tc.want {
+			t.Errorf("version %#04x, supported %v: got %q, want %q", tc.version, tc.supported, got, tc.want)
+		}
+	}
+}
+
+func TestJA4ALPN(t *testing.T) {
+	for _, tc := range []struct {
+		alpn []string
+		want string
+	}{
+		{nil, "00"},
+		{[]string{"h2"}, "h2"},
+		{[]string{"http/1.1", "h2"}, "h1"},
+		{[]string{"h"}, "hh"},
+		{[]string{"\xab"}, "ab"},
+		{[]string{"h2\xff"}, "6f"},
+	} {
+		if got := ja4ALPN(tc.alpn); got != tc.want {
+			t.Errorf("ALPN %q: got %q, want %q", tc.alpn, got, tc.want)
+		}
+	}
+}